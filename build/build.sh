@@ -45,8 +45,29 @@ fi
 
 export CGO_ENABLED=0
 
+BUILD_TAGS=""
+if [ "${FIPS:-false}" = true ]; then
+  # BoringCrypto requires cgo and is only available for amd64
+  if [ "${ARCH}" != "amd64" ]; then
+    echo "FIPS builds are only supported for amd64"
+    exit 1
+  fi
+
+  export CGO_ENABLED=1
+  export GOEXPERIMENT=boringcrypto
+  BUILD_TAGS="boringcrypto"
+
+  # crypto/tls/fipsonly is only provided by the Go+BoringCrypto releases,
+  # versioned like go1.12.<patch>b<n>, and since Go 1.19 by GOEXPERIMENT=boringcrypto
+  if ! go list -tags "${BUILD_TAGS}" crypto/tls/fipsonly > /dev/null 2>&1; then
+    echo "FIPS builds require a Go toolchain with BoringCrypto, $(go version) does not provide crypto/tls/fipsonly"
+    exit 1
+  fi
+fi
+
 go build \
   "${GOBUILD_FLAGS}" \
+  -tags "${BUILD_TAGS}" \
   -ldflags "-s -w \
     -X ${PKG}/version.RELEASE=${TAG} \
     -X ${PKG}/version.COMMIT=${GIT_COMMIT} \
@@ -27,6 +27,10 @@ resty \
   -I ./rootfs/etc/nginx/lua \
   --shdict "configuration_data 5M" \
   --shdict "certificate_data 16M" \
+  --shdict "canary_weights 1M" \
+  --shdict "access_events 1M" \
+  --shdict "usage 1M" \
+  --shdict "usage_exported 1M" \
   --shdict "balancer_ewma 1M" \
   --shdict "balancer_ewma_last_touched_at 1M" \
   ./rootfs/etc/nginx/lua/test/run.lua ${BUSTED_ARGS} ./rootfs/etc/nginx/lua/test/
@@ -20,9 +20,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"k8s.io/ingress-nginx/internal/audit"
+	"k8s.io/ingress-nginx/internal/debug"
+	"k8s.io/ingress-nginx/internal/history"
 	"k8s.io/ingress-nginx/internal/nginx"
 )
 
@@ -88,6 +97,29 @@ func main() {
 	}
 	certCmd.AddCommand(certGetCmd)
 
+	certReportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "Output the certificates synced to disk no Ingress uses anymore and the hosts serving the default certificate unexpectedly",
+		Run: func(cmd *cobra.Command, args []string) {
+			debugRequest(http.MethodGet, debug.CertificatesPath, nil)
+		},
+	}
+	certCmd.AddCommand(certReportCmd)
+
+	certLookupCmd := &cobra.Command{
+		Use:   "lookup [sni]",
+		Short: "Output the certificate the controller serves for the given SNI, without SNI when it is omitted",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			query := url.Values{}
+			if len(args) > 0 {
+				query.Set("sni", args[0])
+			}
+			debugRequest(http.MethodGet, debug.CertificateLookupPath, query)
+		},
+	}
+	certCmd.AddCommand(certLookupCmd)
+
 	rootCmd.AddCommand(certCmd)
 
 	generalCmd := &cobra.Command{
@@ -108,6 +140,122 @@ func main() {
 	}
 	rootCmd.AddCommand(confCmd)
 
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Output the revisions of the configuration history",
+		Run: func(cmd *cobra.Command, args []string) {
+			listRevisions()
+		},
+	}
+	rootCmd.AddCommand(historyCmd)
+
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback [revision]",
+		Short: "Apply again a revision of the configuration history, the previous one by default",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			revision := ""
+			if len(args) == 1 {
+				revision = args[0]
+			}
+			rollback(revision)
+		},
+	}
+	rootCmd.AddCommand(rollbackCmd)
+
+	auditCmd := &cobra.Command{
+		Use:   "audit [namespace/name]",
+		Short: "Output the audit trail of the configuration changes, optionally only the changes of an object",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			object := ""
+			if len(args) == 1 {
+				object = args[0]
+			}
+			auditTrail(object)
+		},
+	}
+	rootCmd.AddCommand(auditCmd)
+
+	logLevelsCmd := &cobra.Command{
+		Use:   "log-levels",
+		Short: "Output the verbosity of the components of the controller",
+		Run: func(cmd *cobra.Command, args []string) {
+			debugRequest(http.MethodGet, debug.LogLevelsPath, nil)
+		},
+	}
+	rootCmd.AddCommand(logLevelsCmd)
+
+	var logLevelDuration time.Duration
+	logLevelCmd := &cobra.Command{
+		Use:   "log-level [component] [level]",
+		Short: "Change the verbosity of a component of the controller, without level the component uses the default verbosity again",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			query := url.Values{"component": {args[0]}}
+			if len(args) == 2 {
+				query.Set("level", args[1])
+			}
+			if logLevelDuration != 0 {
+				query.Set("duration", logLevelDuration.String())
+			}
+			debugRequest(http.MethodPut, debug.LogLevelsPath, query)
+		},
+	}
+	logLevelCmd.Flags().DurationVar(&logLevelDuration, "duration", 0, "Restore the previous verbosity after this duration")
+	rootCmd.AddCommand(logLevelCmd)
+
+	debugHostsCmd := &cobra.Command{
+		Use:   "debug-hosts",
+		Short: "Output the hosts with the NGINX debug logs enabled",
+		Run: func(cmd *cobra.Command, args []string) {
+			debugRequest(http.MethodGet, debug.HostsPath, nil)
+		},
+	}
+	rootCmd.AddCommand(debugHostsCmd)
+
+	var debugHostDuration time.Duration
+	var debugHostDisable bool
+	debugHostCmd := &cobra.Command{
+		Use:   "debug-host [host]",
+		Short: "Enable the NGINX debug logs of a host for a while",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			query := url.Values{"host": {args[0]}}
+			if debugHostDisable {
+				debugRequest(http.MethodDelete, debug.HostsPath, query)
+				return
+			}
+
+			query.Set("duration", debugHostDuration.String())
+			debugRequest(http.MethodPut, debug.HostsPath, query)
+		},
+	}
+	debugHostCmd.Flags().DurationVar(&debugHostDuration, "duration", debug.DefaultHostDuration, "Disable the debug logs after this duration")
+	debugHostCmd.Flags().BoolVar(&debugHostDisable, "disable", false, "Disable the debug logs now")
+	rootCmd.AddCommand(debugHostCmd)
+
+	profilesCmd := &cobra.Command{
+		Use:   "profiles",
+		Short: "Output the profiles captured by the continuous profiling",
+		Run: func(cmd *cobra.Command, args []string) {
+			debugRequest(http.MethodGet, debug.ProfilesPath, nil)
+		},
+	}
+	rootCmd.AddCommand(profilesCmd)
+
+	var profileOutput string
+	profileCmd := &cobra.Command{
+		Use:   "profile [id]",
+		Short: "Download a profile captured by the continuous profiling, in the format of pprof",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			downloadProfile(args[0], profileOutput)
+		},
+	}
+	profileCmd.Flags().StringVarP(&profileOutput, "output", "o", "", "Write the profile to this file instead of the standard output")
+	rootCmd.AddCommand(profileCmd)
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -240,3 +388,125 @@ func readNginxConf() {
 
 	fmt.Println(conf)
 }
+
+func listRevisions() {
+	statusCode, body, requestErr := history.NewGetRequest(history.RevisionsPath)
+	if requestErr != nil {
+		fmt.Println(requestErr)
+		return
+	}
+	if statusCode != 200 {
+		fmt.Printf("Controller returned code %v\n", statusCode)
+		fmt.Println(string(body))
+		return
+	}
+
+	var prettyBuffer bytes.Buffer
+	indentErr := json.Indent(&prettyBuffer, body, "", "  ")
+	if indentErr != nil {
+		fmt.Println(indentErr)
+		return
+	}
+
+	fmt.Println(string(prettyBuffer.Bytes()))
+}
+
+func rollback(revision string) {
+	path := history.RollbackPath
+	if revision != "" {
+		if _, err := strconv.Atoi(revision); err != nil {
+			fmt.Printf("Invalid revision %v\n", revision)
+			return
+		}
+		path = path + "?revision=" + revision
+	}
+
+	statusCode, body, requestErr := history.NewPostRequest(path)
+	if requestErr != nil {
+		fmt.Println(requestErr)
+		return
+	}
+	if statusCode != 201 {
+		fmt.Printf("Controller returned code %v\n", statusCode)
+		fmt.Println(string(body))
+		return
+	}
+
+	var prettyBuffer bytes.Buffer
+	indentErr := json.Indent(&prettyBuffer, body, "", "  ")
+	if indentErr != nil {
+		fmt.Println(indentErr)
+		return
+	}
+
+	fmt.Println(string(prettyBuffer.Bytes()))
+}
+
+func auditTrail(object string) {
+	entries, err := audit.ReadFile(audit.File)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if object != "" {
+		entries = audit.Filter(entries, object)
+	}
+
+	printed, _ := json.MarshalIndent(entries, "", "  ")
+	fmt.Println(string(printed))
+}
+
+func debugRequest(method, path string, query url.Values) {
+	if len(query) > 0 {
+		path = path + "?" + query.Encode()
+	}
+
+	statusCode, body, requestErr := debug.NewRequest(method, path)
+	if requestErr != nil {
+		fmt.Println(requestErr)
+		return
+	}
+	if statusCode != 200 {
+		fmt.Printf("Controller returned code %v\n", statusCode)
+		fmt.Println(string(body))
+		return
+	}
+
+	var prettyBuffer bytes.Buffer
+	indentErr := json.Indent(&prettyBuffer, body, "", "  ")
+	if indentErr != nil {
+		fmt.Println(indentErr)
+		return
+	}
+
+	fmt.Println(string(prettyBuffer.Bytes()))
+}
+
+func downloadProfile(id, output string) {
+	if _, err := strconv.Atoi(id); err != nil {
+		fmt.Printf("Invalid profile %v\n", id)
+		return
+	}
+
+	statusCode, body, requestErr := debug.NewRequest(http.MethodGet, debug.ProfilesPath+"?id="+id)
+	if requestErr != nil {
+		fmt.Println(requestErr)
+		return
+	}
+	if statusCode != 200 {
+		fmt.Printf("Controller returned code %v\n", statusCode)
+		fmt.Println(string(body))
+		return
+	}
+
+	if output == "" {
+		os.Stdout.Write(body)
+		return
+	}
+
+	err := ioutil.WriteFile(output, body, 0644)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
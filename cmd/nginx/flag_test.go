@@ -18,8 +18,11 @@ package main
 
 import (
 	"flag"
+	"io/ioutil"
 	"os"
 	"testing"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
 )
 
 // resetForTesting clears all flag state and sets the usage function as directed.
@@ -75,3 +78,217 @@ func TestFlagConflict(t *testing.T) {
 		t.Fatalf("Expected an error parsing flags but none returned")
 	}
 }
+
+func TestTrafficAPIFlags(t *testing.T) {
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--traffic-api-address", "127.0.0.1:10247"}
+
+	_, _, err := parseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags without a traffic API token file but none returned")
+	}
+
+	tokenFile, err := ioutil.TempFile("", "traffic-api-token")
+	if err != nil {
+		t.Fatalf("Unexpected error creating temporal file: %v", err)
+	}
+	defer os.Remove(tokenFile.Name())
+
+	tokenFile.WriteString("secret\n")
+	tokenFile.Close()
+
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--traffic-api-address", "127.0.0.1:10247", "--traffic-api-token-file", tokenFile.Name()}
+
+	_, conf, err := parseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+
+	if conf.TrafficAPIToken != "secret" {
+		t.Errorf("Expected the token \"secret\" but got %q", conf.TrafficAPIToken)
+	}
+
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--traffic-api-address", "0.0.0.0:10247", "--traffic-api-token-file", tokenFile.Name()}
+
+	_, _, err = parseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags with a non loopback address but none returned")
+	}
+}
+
+func TestConfigurationAPIFlags(t *testing.T) {
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--configuration-api-address", "unix:/tmp/configuration.sock"}
+
+	_, conf, err := parseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags without a configuration API token file: %v", err)
+	}
+
+	if conf.ConfigurationAPIToken != "" {
+		t.Errorf("Expected an empty token but got %q", conf.ConfigurationAPIToken)
+	}
+
+	tokenFile, err := ioutil.TempFile("", "configuration-api-token")
+	if err != nil {
+		t.Fatalf("Unexpected error creating temporal file: %v", err)
+	}
+	defer os.Remove(tokenFile.Name())
+
+	tokenFile.WriteString("secret\n")
+	tokenFile.Close()
+
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--configuration-api-address", "127.0.0.1:10248", "--configuration-api-token-file", tokenFile.Name()}
+
+	_, conf, err = parseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+
+	if conf.ConfigurationAPIToken != "secret" {
+		t.Errorf("Expected the token \"secret\" but got %q", conf.ConfigurationAPIToken)
+	}
+
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--configuration-api-address", "10.0.0.1:10248"}
+
+	_, _, err = parseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags with a non loopback address but none returned")
+	}
+}
+
+func TestDashboardFlags(t *testing.T) {
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--dashboard-address", "127.0.0.1:10249"}
+
+	_, _, err := parseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags without a dashboard credentials file but none returned")
+	}
+
+	credentialsFile, err := ioutil.TempFile("", "dashboard-credentials")
+	if err != nil {
+		t.Fatalf("Unexpected error creating temporal file: %v", err)
+	}
+	defer os.Remove(credentialsFile.Name())
+
+	credentialsFile.WriteString("admin:secret\n")
+	credentialsFile.Close()
+
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--dashboard-address", "127.0.0.1:10249", "--dashboard-credentials-file", credentialsFile.Name()}
+
+	_, conf, err := parseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+
+	if conf.DashboardCredentials.User != "admin" || conf.DashboardCredentials.Password != "secret" {
+		t.Errorf("Expected the credentials admin:secret but got %+v", conf.DashboardCredentials)
+	}
+}
+
+func TestConfigFreezeWindowsFlag(t *testing.T) {
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--config-freeze-windows", "0 18 * * 5 6h; 0 0 24 12 * 48h"}
+
+	_, conf, err := parseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+
+	if len(conf.ConfigFreezeWindows) != 2 {
+		t.Errorf("Expected 2 freeze windows but got %v", len(conf.ConfigFreezeWindows))
+	}
+
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--config-freeze-windows", "0 18 * * 5"}
+
+	_, _, err = parseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags with an invalid freeze window but none returned")
+	}
+}
+
+func TestAuditConfigMapFlag(t *testing.T) {
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--audit-configmap", "ingress-nginx/audit"}
+
+	_, conf, err := parseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+
+	if conf.AuditConfigMap != "ingress-nginx/audit" {
+		t.Errorf("Expected the audit ConfigMap ingress-nginx/audit but got %v", conf.AuditConfigMap)
+	}
+
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--audit-configmap", "audit"}
+
+	_, _, err = parseFlags()
+	if err == nil {
+		t.Fatalf("Expected an error parsing flags with an invalid audit ConfigMap but none returned")
+	}
+}
+
+func TestIngressClassFlags(t *testing.T) {
+	ic := class.IngressClass
+	certs := class.DefaultSSLCertificates
+	defer func() {
+		class.IngressClass = ic
+		class.DefaultSSLCertificates = certs
+	}()
+
+	resetForTesting(func() { t.Fatal("Parsing failed") })
+
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+	os.Args = []string{"cmd", "--http-port", "0", "--https-port", "0", "--ingress-class", "nginx,nginx-*",
+		"--ingress-class-default-ssl-certificates", "nginx-internal=ingress/internal-tls, nginx-public=ingress/public-tls"}
+
+	_, _, err := parseFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing flags: %v", err)
+	}
+
+	if class.IngressClass != "nginx,nginx-*" {
+		t.Errorf("Expected the ingress classes nginx,nginx-* but got %v", class.IngressClass)
+	}
+	if len(class.DefaultSSLCertificates) != 2 || class.DefaultSSLCertificates["nginx-internal"] != "ingress/internal-tls" {
+		t.Errorf("Unexpected default certificates of the classes %v", class.DefaultSSLCertificates)
+	}
+
+	for _, args := range [][]string{
+		{"--ingress-class", "nginx,nginx-[a"},
+		{"--ingress-class-default-ssl-certificates", "ingress/internal-tls"},
+		{"--ingress-class-default-ssl-certificates", "nginx-internal=internal-tls"},
+	} {
+		resetForTesting(func() { t.Fatal("Parsing failed") })
+		os.Args = append([]string{"cmd", "--http-port", "0", "--https-port", "0"}, args...)
+
+		_, _, err = parseFlags()
+		if err == nil {
+			t.Errorf("Expected an error parsing the flags %v but none returned", args)
+		}
+	}
+}
@@ -19,22 +19,38 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
 
 	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/klog"
 
+	"k8s.io/ingress-nginx/internal/dashboard"
+	"k8s.io/ingress-nginx/internal/freeze"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	"k8s.io/ingress-nginx/internal/ingress/controller"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/logging"
 	ing_net "k8s.io/ingress-nginx/internal/net"
+	"k8s.io/ingress-nginx/internal/net/spiffe"
+	"k8s.io/ingress-nginx/internal/net/ssl"
 	"k8s.io/ingress-nginx/internal/nginx"
+	ing_profiling "k8s.io/ingress-nginx/internal/profiling"
+	"k8s.io/ingress-nginx/internal/traffic"
 )
 
+// maxmindEditionRegex matches the MaxMind edition IDs, i.e. GeoLite2-City
+var maxmindEditionRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
 func parseFlags() (bool, *controller.Configuration, error) {
 	var (
 		flags = pflag.NewFlagSet("", pflag.ExitOnError)
@@ -55,7 +71,12 @@ requests to the first port of this Service.`)
 		ingressClass = flags.String("ingress-class", "",
 			`Name of the ingress class this controller satisfies.
 The class of an Ingress object is set using the annotation "kubernetes.io/ingress.class".
-All ingress classes are satisfied if this parameter is left empty.`)
+All ingress classes are satisfied if this parameter is left empty.
+Several classes can be separated by commas, each one being a name or a glob pattern like "nginx-*".`)
+
+		classDefSSLCertificates = flags.String("ingress-class-default-ssl-certificates", "",
+			`Comma-separated list of class=namespace/name pairs defining the Secret containing the
+certificate used instead of the default certificate by the hosts of the Ingresses of a class.`)
 
 		configMap = flags.String("configmap", "",
 			`Name of the ConfigMap containing custom global configurations for the controller.`)
@@ -88,6 +109,14 @@ namespaces are watched if this parameter is left empty.`)
 
 		profiling = flags.Bool("profiling", true,
 			`Enable profiling via web interface host:port/debug/pprof/`)
+		continuousProfiling = flags.Bool("continuous-profiling", false,
+			`Capture CPU and heap profiles of the controller when the reloads or the heap spike, downloadable with the dbg tool.`)
+		profilingReloadThreshold = flags.Int("profiling-reload-threshold", 10,
+			`Number of reloads of NGINX in a minute triggering the capture of a CPU profile (--continuous-profiling).`)
+		profilingHeapThreshold = flags.Int("profiling-heap-threshold", 256,
+			`Growth of the heap of the controller, in megabytes, since the last heap profile triggering the capture of a new one (--continuous-profiling).`)
+		profilingHistorySize = flags.Int("profiling-history-size", 10,
+			`Number of profiles kept in memory by the continuous profiling, the oldest ones are dropped.`)
 
 		defSSLCertificate = flags.String("default-ssl-certificate", "",
 			`Secret containing a SSL certificate to be used by the default HTTPS server (catch-all).
@@ -106,6 +135,14 @@ Requires setting the publish-service parameter to a valid Service reference.`)
 
 		electionID = flags.String("election-id", "ingress-controller-leader",
 			`Election id to use for Ingress status updates.`)
+		electionLeaseDuration = flags.Duration("election-lease-duration", 30*time.Second,
+			`Duration the followers wait before taking over the leader tasks when the leader stops renewing its lease.
+The leader releases the lease when it shuts down, so the takeover only waits for the retry period.`)
+		electionRenewDeadline = flags.Duration("election-renew-deadline", 15*time.Second,
+			`Duration the leader retries to renew its lease before stopping the leader tasks.`)
+		electionRetryPeriod = flags.Duration("election-retry-period", 2*time.Second,
+			`Duration between two attempts to acquire or renew the lease. Lower values reduce the time needed by a
+follower to take over the leader tasks, i.e. 500ms, at the expense of more requests to the API server.`)
 
 		_ = flags.Bool("force-namespace-isolation", false,
 			`Force namespace isolation.
@@ -134,6 +171,10 @@ Requires the update-status parameter.`)
 Certificates uploaded to Kubernetes must have the "Authority Information Access" X.509 v3
 extension for this to succeed.`)
 
+		enableOCSPStapling = flags.Bool("enable-ocsp-stapling", false,
+			`Fetch the OCSP responses of the certificates defining an OCSP responder and staple
+them to the TLS handshakes. The responses are fetched again before they expire.`)
+
 		syncRateLimit = flags.Float32("sync-rate-limit", 0.3,
 			`Define the sync frequency upper limit`)
 
@@ -141,9 +182,26 @@ extension for this to succeed.`)
 			`Customized address to set as the load-balancer status of Ingress objects this controller satisfies.
 Requires the update-status parameter.`)
 
+		enableFIPSMode = flags.Bool("enable-fips-mode", false,
+			`Reject the certificates using keys or signatures not approved by FIPS 140-2, and restrict the TLS configuration of the
+controller to the approved versions, cipher suites and curves.`)
+
 		enableDynamicCertificates = flags.Bool("enable-dynamic-certificates", true,
 			`Dynamically update SSL certificates instead of reloading NGINX. Feature backed by OpenResty Lua libraries.`)
 
+		pemEncryptionKeyFile = flags.String("pem-encryption-key-file", "",
+			`The path of the file containing the key used to encrypt the certificates and keys stored on disk and only read by the
+controller. The key must contain 32 bytes, or 32 bytes encoded in base64. If not provided, the files are not encrypted
+and the dynamic certificates are only kept in memory.`)
+		requireTmpfsSSLDirectory = flags.Bool("require-tmpfs-ssl-directory", false,
+			`Refuse to start when the directory containing the certificates and keys (/etc/ingress-controller/ssl) is not a tmpfs mount,
+so they are never written to the disk of the node.`)
+		sslFilesGCInterval = flags.Duration("ssl-files-gc-interval", 10*time.Minute,
+			`Interval between two removals of the PEM and OCSP response files of the SSL directory no certificate uses anymore,
+i.e. the files of deleted secrets. The first removal happens after the initial synchronization. Zero disables the removals.`)
+		sslFilesGCDryRun = flags.Bool("ssl-files-gc-dry-run", false,
+			`Only log the PEM files that --ssl-files-gc-interval would remove.`)
+
 		enableMetrics = flags.Bool("enable-metrics", true,
 			`Enables the collection of NGINX metrics`)
 		metricsPerHost = flags.Bool("metrics-per-host", true,
@@ -155,10 +213,49 @@ Requires the update-status parameter.`)
 		sslProxyPort  = flags.Int("ssl-passthrough-proxy-port", 442, `Port to use internally for SSL Passthrough.`)
 		defServerPort = flags.Int("default-server-port", 8181, `Port to use for exposing the default server (catch-all).`)
 		healthzPort   = flags.Int("healthz-port", 10254, "Port to use for the healthz endpoint.")
+		dnsProxyPort  = flags.Int("dns-proxy-port", 10253, `Port to use internally for the DNS proxy, running when the configuration defines resolvers.`)
+
+		additionalHTTPSPorts = flags.IntSlice("additional-https-ports", []int{},
+			`Comma separated list of HTTPS ports of the servers requiring client certificates on a distinct listener,
+set by the nginx.ingress.kubernetes.io/auth-tls-port annotation.`)
+
+		internalHTTPPort = flags.Int("internal-http-port", 0,
+			`Port of the internal listener servicing HTTP traffic for the hosts of the Ingresses with the
+nginx.ingress.kubernetes.io/internal annotation. Disabled by default.`)
+		internalHTTPSPort = flags.Int("internal-https-port", 0,
+			`Port of the internal listener servicing HTTPS traffic for the hosts of the Ingresses with the
+nginx.ingress.kubernetes.io/internal annotation. Disabled by default.`)
 
 		disableCatchAll = flags.Bool("disable-catch-all", false,
 			`Disable support for catch-all Ingresses`)
 
+		strictAnnotationValidation = flags.Bool("strict-annotation-validation", false,
+			`Reject Ingresses containing unknown annotations with the annotations prefix or annotations with invalid values.`)
+
+		enableHostDelegation = flags.Bool("enable-host-delegation", false,
+			`Watch HostDelegation objects to restrict the paths of a host that Ingresses of other namespaces can define.
+Requires the HostDelegation custom resource definition.`)
+
+		enableIngressClassParams = flags.Bool("enable-ingress-class-params", false,
+			`Watch NginxIngressClassParams objects defining the default certificate, the allowed annotations and the default
+timeouts of the Ingresses of the class named like the object.
+Requires the NginxIngressClassParams custom resource definition.`)
+
+		enableEndpointWeights = flags.Bool("enable-endpoint-weights", false,
+			`Watch the Pods providing the endpoints to honor their nginx.ingress.kubernetes.io/endpoint-weight annotation,
+the share of the traffic sent to the endpoints of the Pod between 1 and 100 (default).`)
+
+		enableEndpointConditions = flags.Bool("enable-endpoint-conditions", false,
+			`Watch the Pods providing the endpoints to honor the nginx.ingress.kubernetes.io/endpoint-ready-condition annotation,
+the Pod condition that must be True before the Pod receives the traffic of the Ingress.`)
+
+		secretServiceAccount = flags.String("secret-service-account", "",
+			`Name of the service account of each namespace used to read the Secrets of the namespace, instead of watching the
+Secrets of the cluster. The controller does not need the permission to read the Secrets of the namespaces.`)
+		secretAccessMode = flags.String("secret-access-mode", "token",
+			`How the controller acts as the service account of --secret-service-account: "token" requests short-lived tokens
+of the service account, "impersonation" impersonates it.`)
+
 		validationWebhook = flags.String("validating-webhook", "",
 			`The address to start an admission controller on to validate incoming ingresses.
 Takes the form "<host>:port". If not provided, no admission controller is started.`)
@@ -166,6 +263,93 @@ Takes the form "<host>:port". If not provided, no admission controller is starte
 			`The path of the validating webhook certificate PEM.`)
 		validationWebhookKey = flags.String("validating-webhook-key", "",
 			`The path of the validating webhook key PEM.`)
+		webhookSPIFFESocket = flags.String("validating-webhook-spiffe-socket", "",
+			`The address of the SPIFFE Workload API providing the X.509 SVID of the validating webhook instead of
+--validating-webhook-certificate and --validating-webhook-key. Takes the form "unix:///path/to/socket".`)
+		webhookSPIFFEIDs = flags.String("validating-webhook-spiffe-authorized-ids", "",
+			`Comma separated list of the SPIFFE IDs allowed to call the validating webhook, authenticated with mutual TLS
+using the trust bundle of the SPIFFE Workload API. If not provided, the clients are not authenticated.`)
+		webhookCertificateSecret = flags.String("validating-webhook-certificate-secret", "",
+			`Secret (in the form "namespace/name") where the controller stores the CA and the certificate of the validating
+webhook it issues and renews, instead of --validating-webhook-certificate and --validating-webhook-key.`)
+		webhookService = flags.String("validating-webhook-service", "",
+			`Service (in the form "namespace/name") of the validating webhook, whose names are used in the certificate
+issued with --validating-webhook-certificate-secret.`)
+		webhookConfiguration = flags.String("validating-webhook-configuration", "",
+			`Name of the ValidatingWebhookConfiguration whose CA bundle is updated with the CA of --validating-webhook-certificate-secret.`)
+		webhookVerifyTLSSecrets = flags.Bool("validating-webhook-verify-tls-secrets", false,
+			`Reject the Ingresses referencing TLS Secrets that are missing, invalid, expired or not valid for the hosts of the Ingress.`)
+
+		trafficAPIAddress = flags.String("traffic-api-address", "",
+			`The address of the traffic management API used by progressive delivery controllers to adjust the weight of canary backends.
+Takes the form "<host>:port" using an address of the loopback interface or "unix:/path/to/socket". If not provided, the API is disabled.`)
+		trafficAPITokenFile = flags.String("traffic-api-token-file", "",
+			`The path of the file containing the bearer token required by the traffic management API.`)
+
+		configurationAPIAddress = flags.String("configuration-api-address", "",
+			`The address of the read-only API returning the running configuration (servers, locations, backends and certificates) as JSON.
+Takes the form "<host>:port" using an address of the loopback interface or "unix:/path/to/socket". If not provided, the API is disabled.`)
+		configurationAPITokenFile = flags.String("configuration-api-token-file", "",
+			`The path of the file containing the bearer token required by the configuration API. If not provided, the API does not require authentication.`)
+
+		dashboardAddress = flags.String("dashboard-address", "",
+			`The address of the web page displaying the hosts, paths, backends, certificates and recent reloads of the running configuration.
+Takes the form "<host>:port" using an address of the loopback interface or "unix:/path/to/socket". If not provided, the dashboard is disabled.`)
+		dashboardCredentialsFile = flags.String("dashboard-credentials-file", "",
+			`The path of the file containing the credentials required by the dashboard using the form <user>:<password>.`)
+
+		configFreezeWindows = flags.String("config-freeze-windows", "",
+			`List of windows, separated by semicolons, during which the configuration changes are postponed,
+except the endpoints of the existing backends and the renewed certificates.
+Each window contains a cron schedule evaluated in UTC followed by its duration, i.e. "0 18 * * 5 6h".`)
+
+		configHistorySize = flags.Int("config-history-size", 5,
+			`Number of applied configurations kept to allow rollbacks using the dbg tool. Set to 0 to disable the history.`)
+
+		auditSize = flags.Int("audit-size", 0,
+			`Number of configuration changes kept in the audit trail. The audit trail is disabled by default.`)
+		auditConfigMap = flags.String("audit-configmap", "",
+			`Name of the ConfigMap where each controller pod copies its audit trail of the configuration changes.
+Takes the form "namespace/name". The ConfigMap is created if it does not exist.`)
+
+		namespaceConfigMap = flags.String("namespace-configmap", "",
+			`Name of the ConfigMap written in each namespace with the server blocks rendered for its Ingresses,
+so the users of a namespace can review the NGINX configuration produced by their annotations.
+The ConfigMaps are only written by the leader. Empty disables the ConfigMaps.`)
+
+		appliedConfigMap = flags.String("applied-configmap", "",
+			`Name of the ConfigMap where each controller pod publishes the checksum of the applied configuration
+and the resource versions of the objects used to build it, so GitOps tools can detect drifts.
+Takes the form "namespace/name". The ConfigMap is created if it does not exist.`)
+
+		warmupHosts = flags.String("warmup-hosts", "",
+			`List of hosts, separated by commas, requested by the controller before its readiness endpoint (/readyz) succeeds.
+Each host can be followed by a path, i.e. "example.com,api.example.com/healthz". The requests must not return a
+404 or a server error, and the certificate must be valid for the host.`)
+		warmupTimeout = flags.Duration("warmup-timeout", 0,
+			`Maximum duration of the warm-up after the start of the controller. The readiness endpoint (/readyz) succeeds
+once it is elapsed even if the warm-up did not complete. Zero waits indefinitely.`)
+
+		probeHosts = flags.String("probe-hosts", "",
+			`List of hosts, separated by commas, periodically requested by the controller through NGINX to export the result
+and the latency of each request as metrics. Each host can be followed by a path, i.e. "example.com,api.example.com/healthz".
+The requests must not return a 404 or a server error, and the certificate must be valid for the host. Empty disables the probes.`)
+		probeInterval = flags.Duration("probe-interval", 30*time.Second,
+			`Interval between two requests of the hosts of --probe-hosts.`)
+
+		maxmindLicenseSecret = flags.String("maxmind-license-key-secret", "",
+			`Secret (namespace/name) containing the MaxMind license key in the key "license-key". When it is set the
+controller downloads the GeoIP2 databases, verifies their checksum and reloads NGINX when their content changed.`)
+		maxmindEditions = flags.String("maxmind-edition-ids", "GeoLite2-City,GeoLite2-ASN",
+			`Comma separated list of the MaxMind edition IDs downloaded with --maxmind-license-key-secret.`)
+		maxmindUpdateInterval = flags.Duration("maxmind-update-interval", 24*time.Hour,
+			`Interval between two checks of the GeoIP2 databases published by MaxMind.`)
+
+		logFormat = flags.String("log-format", "text",
+			`Format of the logs of the controller, "text" or "json" (one object by line with the component of the log).`)
+		logLevels = flags.String("log-levels", "",
+			`Comma separated list of the verbosity of components, i.e. "store=3,ssl=5", overriding -v for those components.
+The components are controller, nginx, ssl, store and template. The levels can be changed at runtime with the dbg tool.`)
 	)
 
 	flags.MarkDeprecated("status-port", `The status port is a unix socket now.`)
@@ -180,8 +364,22 @@ Takes the form "<host>:port". If not provided, no admission controller is starte
 	// https://github.com/kubernetes/kubernetes/issues/17162
 	flag.CommandLine.Parse([]string{})
 
+	if err := logging.Configure(*logLevels); err != nil {
+		return false, nil, fmt.Errorf("Invalid value in flag --log-levels: %v", err)
+	}
+
+	switch *logFormat {
+	case "text":
+	case "json":
+		if err := logging.EnableJSON(os.Stderr); err != nil {
+			return false, nil, fmt.Errorf("Unexpected error enabling the JSON logs: %v", err)
+		}
+	default:
+		return false, nil, fmt.Errorf("Invalid value in flag --log-format: %v", *logFormat)
+	}
+
 	pflag.VisitAll(func(flag *pflag.Flag) {
-		klog.V(2).Infof("FLAG: --%s=%q", flag.Name, flag.Value)
+		logging.V(2).Infof("FLAG: --%s=%q", flag.Name, flag.Value)
 	})
 
 	if *showVersion {
@@ -195,9 +393,33 @@ Takes the form "<host>:port". If not provided, no admission controller is starte
 			klog.Warningf("Only Ingresses with class %q will be processed by this Ingress controller", *ingressClass)
 		}
 
+		if err := class.Validate(*ingressClass); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --ingress-class: %v", err)
+		}
+
 		class.IngressClass = *ingressClass
 	}
 
+	classDefaultCertificates := map[string]string{}
+	for _, pair := range strings.Split(*classDefSSLCertificates, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return false, nil, fmt.Errorf("Invalid value in flag --ingress-class-default-ssl-certificates: %q is not a class=namespace/name pair", pair)
+		}
+
+		if _, _, err := k8s.ParseNameNS(parts[1]); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --ingress-class-default-ssl-certificates: %v", err)
+		}
+
+		classDefaultCertificates[parts[0]] = parts[1]
+	}
+	class.DefaultSSLCertificates = classDefaultCertificates
+
 	parser.AnnotationsPrefix = *annotationsPrefix
 
 	// check port collisions
@@ -217,6 +439,39 @@ Takes the form "<host>:port". If not provided, no admission controller is starte
 		return false, nil, fmt.Errorf("Port %v is already in use. Please check the flag --ssl-passthrough-proxy-port", *sslProxyPort)
 	}
 
+	for _, port := range *additionalHTTPSPorts {
+		if port == *httpPort || port == *httpsPort || port == *defServerPort || port == *sslProxyPort || port == *healthzPort {
+			return false, nil, fmt.Errorf("Port %v of the flag --additional-https-ports is already used by another flag", port)
+		}
+		if !ing_net.IsPortAvailable(port) {
+			return false, nil, fmt.Errorf("Port %v is already in use. Please check the flag --additional-https-ports", port)
+		}
+	}
+
+	reservedPorts := sets.NewInt(*httpPort, *httpsPort, *defServerPort, *sslProxyPort, *healthzPort)
+	reservedPorts.Insert(*additionalHTTPSPorts...)
+	internalPorts := []struct {
+		flag string
+		port int
+	}{
+		{"internal-http-port", *internalHTTPPort},
+		{"internal-https-port", *internalHTTPSPort},
+	}
+	for _, internal := range internalPorts {
+		if internal.port == 0 {
+			continue
+		}
+		if reservedPorts.Has(internal.port) {
+			return false, nil, fmt.Errorf("Port %v of the flag --%v is already used by another flag", internal.port, internal.flag)
+		}
+		if !ing_net.IsPortAvailable(internal.port) {
+			return false, nil, fmt.Errorf("Port %v is already in use. Please check the flag --%v", internal.port, internal.flag)
+		}
+	}
+	if *internalHTTPPort != 0 && *internalHTTPPort == *internalHTTPSPort {
+		return false, nil, fmt.Errorf("The flags --internal-http-port and --internal-https-port must use distinct ports")
+	}
+
 	if !*enableSSLChainCompletion {
 		klog.Warningf("SSL certificate chain completion is disabled (--enable-ssl-chain-completion=false)")
 	}
@@ -225,6 +480,221 @@ Takes the form "<host>:port". If not provided, no admission controller is starte
 		return false, nil, fmt.Errorf("Flags --publish-service and --publish-status-address are mutually exclusive")
 	}
 
+	freezeWindows, err := freeze.ParseWindows(*configFreezeWindows)
+	if err != nil {
+		return false, nil, fmt.Errorf("Invalid value in flag --config-freeze-windows: %v", err)
+	}
+
+	if *configHistorySize < 0 {
+		return false, nil, fmt.Errorf("Flag --config-history-size must be a positive number or zero")
+	}
+
+	if *electionLeaseDuration < time.Second {
+		return false, nil, fmt.Errorf("Flag --election-lease-duration must be at least one second")
+	}
+
+	if *electionRenewDeadline >= *electionLeaseDuration {
+		return false, nil, fmt.Errorf("Flag --election-renew-deadline must be lower than --election-lease-duration")
+	}
+
+	// the leader election adds a jitter of 20% to the retry period
+	if float64(*electionRetryPeriod)*1.2 >= float64(*electionRenewDeadline) || *electionRetryPeriod <= 0 {
+		return false, nil, fmt.Errorf("Flag --election-retry-period must be a positive duration lower than --election-renew-deadline")
+	}
+
+	if *auditSize < 0 {
+		return false, nil, fmt.Errorf("Flag --audit-size must be a positive number or zero")
+	}
+
+	if *auditConfigMap != "" {
+		if _, _, err := k8s.ParseNameNS(*auditConfigMap); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --audit-configmap: %v", err)
+		}
+	}
+
+	if *appliedConfigMap != "" {
+		if _, _, err := k8s.ParseNameNS(*appliedConfigMap); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --applied-configmap: %v", err)
+		}
+	}
+
+	warmupTargets, err := controller.ParseProbeTargets(*warmupHosts)
+	if err != nil {
+		return false, nil, fmt.Errorf("Invalid value in flag --warmup-hosts: %v", err)
+	}
+
+	probeTargets, err := controller.ParseProbeTargets(*probeHosts)
+	if err != nil {
+		return false, nil, fmt.Errorf("Invalid value in flag --probe-hosts: %v", err)
+	}
+
+	if *sslFilesGCInterval < 0 {
+		return false, nil, fmt.Errorf("Flag --ssl-files-gc-interval must not be negative")
+	}
+
+	if *probeInterval < time.Second {
+		return false, nil, fmt.Errorf("Flag --probe-interval must be at least one second")
+	}
+
+	geoIPEditions := []string{}
+	if *maxmindLicenseSecret != "" {
+		if _, _, err := k8s.ParseNameNS(*maxmindLicenseSecret); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --maxmind-license-key-secret: %v", err)
+		}
+
+		for _, edition := range strings.Split(*maxmindEditions, ",") {
+			edition = strings.TrimSpace(edition)
+			if edition == "" {
+				continue
+			}
+
+			if !maxmindEditionRegex.MatchString(edition) {
+				return false, nil, fmt.Errorf("Invalid value in flag --maxmind-edition-ids: %q is not a MaxMind edition ID", edition)
+			}
+
+			geoIPEditions = append(geoIPEditions, edition)
+		}
+
+		if len(geoIPEditions) == 0 {
+			return false, nil, fmt.Errorf("Flag --maxmind-license-key-secret requires at least one edition in --maxmind-edition-ids")
+		}
+
+		if *maxmindUpdateInterval < time.Hour {
+			return false, nil, fmt.Errorf("Flag --maxmind-update-interval must be at least one hour")
+		}
+	}
+
+	if *secretServiceAccount != "" {
+		if errs := validation.IsDNS1123Subdomain(*secretServiceAccount); len(errs) > 0 {
+			return false, nil, fmt.Errorf("Invalid value in flag --secret-service-account: %v", strings.Join(errs, ", "))
+		}
+	}
+
+	if *secretAccessMode != "token" && *secretAccessMode != "impersonation" {
+		return false, nil, fmt.Errorf("Invalid value in flag --secret-access-mode: %v", *secretAccessMode)
+	}
+
+	if *warmupTimeout < 0 {
+		return false, nil, fmt.Errorf("Flag --warmup-timeout must be a positive duration or zero")
+	}
+
+	if *namespaceConfigMap != "" {
+		if errs := validation.IsDNS1123Subdomain(*namespaceConfigMap); len(errs) > 0 {
+			return false, nil, fmt.Errorf("Invalid value in flag --namespace-configmap: %v", strings.Join(errs, ", "))
+		}
+	}
+
+	if *pemEncryptionKeyFile != "" {
+		key, err := ioutil.ReadFile(*pemEncryptionKeyFile)
+		if err != nil {
+			return false, nil, fmt.Errorf("Unexpected error reading the PEM encryption key: %v", err)
+		}
+
+		if err := ssl.SetPemEncryptionKey(key); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --pem-encryption-key-file: %v", err)
+		}
+	}
+
+	var authorizedSPIFFEIDs []string
+	if *webhookSPIFFESocket != "" {
+		if _, err := spiffe.ParseAddress(*webhookSPIFFESocket); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --validating-webhook-spiffe-socket: %v", err)
+		}
+
+		for _, id := range strings.Split(*webhookSPIFFEIDs, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" {
+				continue
+			}
+
+			id, err := spiffe.ParseID(id)
+			if err != nil {
+				return false, nil, fmt.Errorf("Invalid value in flag --validating-webhook-spiffe-authorized-ids: %v", err)
+			}
+
+			authorizedSPIFFEIDs = append(authorizedSPIFFEIDs, id)
+		}
+	} else if *webhookSPIFFEIDs != "" {
+		return false, nil, fmt.Errorf("Flag --validating-webhook-spiffe-authorized-ids requires --validating-webhook-spiffe-socket")
+	}
+
+	if *webhookCertificateSecret != "" {
+		if *webhookSPIFFESocket != "" {
+			return false, nil, fmt.Errorf("Flags --validating-webhook-certificate-secret and --validating-webhook-spiffe-socket are mutually exclusive")
+		}
+
+		if _, _, err := k8s.ParseNameNS(*webhookCertificateSecret); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --validating-webhook-certificate-secret: %v", err)
+		}
+
+		if _, _, err := k8s.ParseNameNS(*webhookService); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --validating-webhook-service: %v", err)
+		}
+	} else if *webhookService != "" || *webhookConfiguration != "" {
+		return false, nil, fmt.Errorf("Flags --validating-webhook-service and --validating-webhook-configuration require --validating-webhook-certificate-secret")
+	}
+
+	var trafficAPIToken string
+	if *trafficAPIAddress != "" {
+		if err := traffic.ValidateAddress(*trafficAPIAddress); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --traffic-api-address: %v", err)
+		}
+
+		if *trafficAPITokenFile == "" {
+			return false, nil, fmt.Errorf("Flag --traffic-api-token-file is required when --traffic-api-address is set")
+		}
+
+		token, err := ioutil.ReadFile(*trafficAPITokenFile)
+		if err != nil {
+			return false, nil, fmt.Errorf("Unexpected error reading the traffic management API token: %v", err)
+		}
+
+		trafficAPIToken = strings.TrimSpace(string(token))
+		if trafficAPIToken == "" {
+			return false, nil, fmt.Errorf("The file %v does not contain a traffic management API token", *trafficAPITokenFile)
+		}
+	}
+
+	var configurationAPIToken string
+	if *configurationAPIAddress != "" {
+		if err := traffic.ValidateAddress(*configurationAPIAddress); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --configuration-api-address: %v", err)
+		}
+
+		if *configurationAPITokenFile != "" {
+			token, err := ioutil.ReadFile(*configurationAPITokenFile)
+			if err != nil {
+				return false, nil, fmt.Errorf("Unexpected error reading the configuration API token: %v", err)
+			}
+
+			configurationAPIToken = strings.TrimSpace(string(token))
+			if configurationAPIToken == "" {
+				return false, nil, fmt.Errorf("The file %v does not contain a configuration API token", *configurationAPITokenFile)
+			}
+		}
+	}
+
+	var dashboardCredentials *dashboard.Credentials
+	if *dashboardAddress != "" {
+		if err := traffic.ValidateAddress(*dashboardAddress); err != nil {
+			return false, nil, fmt.Errorf("Invalid value in flag --dashboard-address: %v", err)
+		}
+
+		if *dashboardCredentialsFile == "" {
+			return false, nil, fmt.Errorf("Flag --dashboard-credentials-file is required when --dashboard-address is set")
+		}
+
+		content, err := ioutil.ReadFile(*dashboardCredentialsFile)
+		if err != nil {
+			return false, nil, fmt.Errorf("Unexpected error reading the dashboard credentials: %v", err)
+		}
+
+		dashboardCredentials, err = dashboard.ParseCredentials(string(content))
+		if err != nil {
+			return false, nil, fmt.Errorf("Invalid content in the file %v: %v", *dashboardCredentialsFile, err)
+		}
+	}
+
 	nginx.HealthPath = *defHealthzURL
 
 	if *defHealthCheckTimeout > 0 {
@@ -232,13 +702,41 @@ Takes the form "<host>:port". If not provided, no admission controller is starte
 	}
 
 	ngx_config.EnableSSLChainCompletion = *enableSSLChainCompletion
+	ngx_config.EnableOCSPStapling = *enableOCSPStapling
 	ngx_config.EnableDynamicCertificates = *enableDynamicCertificates
+	ngx_config.EnableFIPSMode = *enableFIPSMode
+
+	if *enableFIPSMode && !ssl.BoringCrypto() {
+		klog.Warningf("FIPS mode is enabled but the controller was not built with BoringCrypto (FIPS=true), its cryptographic module is not FIPS validated")
+	}
+
+	var continuousProfilingConfig *ing_profiling.Config
+	if *continuousProfiling {
+		if *profilingReloadThreshold < 1 {
+			return false, nil, fmt.Errorf("Invalid value in flag --profiling-reload-threshold: %v (must be positive)", *profilingReloadThreshold)
+		}
+		if *profilingHeapThreshold < 1 {
+			return false, nil, fmt.Errorf("Invalid value in flag --profiling-heap-threshold: %v (must be positive)", *profilingHeapThreshold)
+		}
+		if *profilingHistorySize < 1 {
+			return false, nil, fmt.Errorf("Invalid value in flag --profiling-history-size: %v (must be positive)", *profilingHistorySize)
+		}
+
+		continuousProfilingConfig = &ing_profiling.Config{
+			Size:            *profilingHistorySize,
+			ReloadThreshold: *profilingReloadThreshold,
+			HeapThreshold:   uint64(*profilingHeapThreshold) * 1024 * 1024,
+		}
+	}
 
 	config := &controller.Configuration{
 		APIServerHost:          *apiserverHost,
 		KubeConfigFile:         *kubeConfigFile,
 		UpdateStatus:           *updateStatus,
 		ElectionID:             *electionID,
+		ElectionLeaseDuration:  *electionLeaseDuration,
+		ElectionRenewDeadline:  *electionRenewDeadline,
+		ElectionRetryPeriod:    *electionRetryPeriod,
 		EnableProfiling:        *profiling,
 		EnableMetrics:          *enableMetrics,
 		MetricsPerHost:         *metricsPerHost,
@@ -261,11 +759,52 @@ Takes the form "<host>:port". If not provided, no admission controller is starte
 			HTTP:     *httpPort,
 			HTTPS:    *httpsPort,
 			SSLProxy: *sslProxyPort,
+			DNS:      *dnsProxyPort,
+
+			AdditionalHTTPS: *additionalHTTPSPorts,
+			InternalHTTP:    *internalHTTPPort,
+			InternalHTTPS:   *internalHTTPSPort,
 		},
-		DisableCatchAll:           *disableCatchAll,
-		ValidationWebhook:         *validationWebhook,
-		ValidationWebhookCertPath: *validationWebhookCert,
-		ValidationWebhookKeyPath:  *validationWebhookKey,
+		DisableCatchAll:            *disableCatchAll,
+		StrictAnnotationValidation: *strictAnnotationValidation,
+		EnableHostDelegation:       *enableHostDelegation,
+		EnableIngressClassParams:   *enableIngressClassParams,
+		EnableEndpointWeights:      *enableEndpointWeights,
+		EnableEndpointConditions:   *enableEndpointConditions,
+		SecretServiceAccount:       *secretServiceAccount,
+		RequireTmpfsSSLDirectory:   *requireTmpfsSSLDirectory,
+		SSLFilesGCInterval:         *sslFilesGCInterval,
+		SSLFilesGCDryRun:           *sslFilesGCDryRun,
+		SecretImpersonation:        *secretAccessMode == "impersonation",
+		ValidationWebhook:          *validationWebhook,
+		ValidationWebhookCertPath:  *validationWebhookCert,
+		ValidationWebhookKeyPath:   *validationWebhookKey,
+		WebhookSPIFFESocket:        *webhookSPIFFESocket,
+		WebhookSPIFFEIDs:           authorizedSPIFFEIDs,
+		WebhookCertificateSecret:   *webhookCertificateSecret,
+		WebhookService:             *webhookService,
+		WebhookConfiguration:       *webhookConfiguration,
+		WebhookVerifyTLSSecrets:    *webhookVerifyTLSSecrets,
+		TrafficAPIAddress:          *trafficAPIAddress,
+		TrafficAPIToken:            trafficAPIToken,
+		ConfigurationAPIAddress:    *configurationAPIAddress,
+		ConfigurationAPIToken:      configurationAPIToken,
+		DashboardAddress:           *dashboardAddress,
+		DashboardCredentials:       dashboardCredentials,
+		ConfigFreezeWindows:        freezeWindows,
+		ConfigHistorySize:          *configHistorySize,
+		AuditSize:                  *auditSize,
+		AuditConfigMap:             *auditConfigMap,
+		NamespaceConfigMap:         *namespaceConfigMap,
+		AppliedConfigMap:           *appliedConfigMap,
+		WarmupTargets:              warmupTargets,
+		WarmupTimeout:              *warmupTimeout,
+		ProbeTargets:               probeTargets,
+		ProbeInterval:              *probeInterval,
+		ContinuousProfiling:        continuousProfilingConfig,
+		GeoIPLicenseSecret:         *maxmindLicenseSecret,
+		GeoIPEditions:              geoIPEditions,
+		GeoIPUpdateInterval:        *maxmindUpdateInterval,
 	}
 
 	return false, config, nil
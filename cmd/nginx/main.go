@@ -41,9 +41,13 @@ import (
 
 	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress/controller"
+	"k8s.io/ingress-nginx/internal/ingress/controller/store"
 	"k8s.io/ingress-nginx/internal/ingress/metric"
 	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/logging"
 	"k8s.io/ingress-nginx/internal/net/ssl"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
+	"k8s.io/ingress-nginx/pkg/client/clientset/versioned"
 	"k8s.io/ingress-nginx/version"
 )
 
@@ -70,6 +74,16 @@ func main() {
 		klog.Fatal(err)
 	}
 
+	if conf.RequireTmpfsSSLDirectory {
+		tmpfs, err := file.IsTmpfs(file.DefaultSSLDirectory)
+		if err != nil {
+			klog.Fatalf("Error checking the filesystem of %v: %v", file.DefaultSSLDirectory, err)
+		}
+		if !tmpfs {
+			klog.Fatalf("The directory %v is not a tmpfs mount, refusing to store the certificates and keys on disk", file.DefaultSSLDirectory)
+		}
+	}
+
 	kubeClient, err := createApiserverClient(conf.APIServerHost, conf.KubeConfigFile)
 	if err != nil {
 		handleFatalInitError(err)
@@ -108,6 +122,46 @@ func main() {
 
 	conf.Client = kubeClient
 
+	if conf.EnableHostDelegation {
+		var available bool
+		available, err = k8s.CustomResourceAvailable(kubeClient, v1alpha1.SchemeGroupVersion.String(), "hostdelegations")
+		if err != nil {
+			klog.Fatalf("Error checking the HostDelegation custom resource definition: %v", err)
+		}
+		if !available {
+			klog.Fatal("The HostDelegation custom resource definition is not installed (--enable-host-delegation)")
+		}
+
+		conf.DelegationClient, err = createCustomResourceClient(conf.APIServerHost, conf.KubeConfigFile)
+		if err != nil {
+			klog.Fatalf("Error creating HostDelegation client: %v", err)
+		}
+	}
+
+	if conf.EnableIngressClassParams {
+		var available bool
+		available, err = k8s.CustomResourceAvailable(kubeClient, v1alpha1.SchemeGroupVersion.String(), "nginxingressclassparams")
+		if err != nil {
+			klog.Fatalf("Error checking the NginxIngressClassParams custom resource definition: %v", err)
+		}
+		if !available {
+			klog.Fatal("The NginxIngressClassParams custom resource definition is not installed (--enable-ingress-class-params)")
+		}
+
+		conf.IngressClassParamsClient, err = createCustomResourceClient(conf.APIServerHost, conf.KubeConfigFile)
+		if err != nil {
+			klog.Fatalf("Error creating NginxIngressClassParams client: %v", err)
+		}
+	}
+
+	if conf.SecretServiceAccount != "" {
+		conf.SecretReader, err = createSecretReader(conf.APIServerHost, conf.KubeConfigFile, kubeClient,
+			conf.SecretServiceAccount, conf.SecretImpersonation)
+		if err != nil {
+			klog.Fatalf("Error creating Secret reader: %v", err)
+		}
+	}
+
 	reg := prometheus.NewRegistry()
 
 	reg.MustRegister(prometheus.NewGoCollector())
@@ -116,9 +170,13 @@ func main() {
 		ReportErrors: true,
 	}))
 
+	// the autoscaling signals are exposed separately, so the autoscalers
+	// only scrape a few metrics
+	autoscalingReg := prometheus.NewRegistry()
+
 	mc := metric.NewDummyCollector()
 	if conf.EnableMetrics {
-		mc, err = metric.NewCollector(conf.MetricsPerHost, reg)
+		mc, err = metric.NewCollector(conf.MetricsPerHost, reg, autoscalingReg)
 		if err != nil {
 			klog.Fatalf("Error creating prometheus collector:  %v", err)
 		}
@@ -129,6 +187,7 @@ func main() {
 	go handleSigterm(ngx, func(code int) {
 		os.Exit(code)
 	})
+	go handleUpgrade(ngx)
 
 	mux := http.NewServeMux()
 
@@ -138,7 +197,11 @@ func main() {
 
 	registerHealthz(ngx, mux)
 	registerMetrics(reg, mux)
+	registerAutoscalingMetrics(autoscalingReg, mux)
 	registerHandlers(mux)
+	registerInventory(ngx, mux)
+
+	mux.HandleFunc("/log-levels", logging.ReadOnlyHandler)
 
 	go startHTTPServer(conf.ListenPorts.Health, mux)
 
@@ -166,6 +229,22 @@ func handleSigterm(ngx *controller.NGINXController, exit exiter) {
 	exit(exitCode)
 }
 
+// handleUpgrade upgrades the NGINX master process each time the
+// controller receives a SIGUSR2
+func handleUpgrade(ngx *controller.NGINXController) {
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGUSR2)
+	for range signalChan {
+		klog.Info("Received SIGUSR2, upgrading NGINX")
+		if err := ngx.UpgradeNginx(); err != nil {
+			klog.Errorf("Error upgrading NGINX: %v", err)
+			continue
+		}
+
+		klog.Info("NGINX upgraded")
+	}
+}
+
 // createApiserverClient creates a new Kubernetes REST client. apiserverHost is
 // the URL of the API server in the format protocol://address:port/pathPrefix,
 // kubeConfig is the location of a kubeconfig file. If defined, the kubeconfig
@@ -200,7 +279,7 @@ func createApiserverClient(apiserverHost, kubeConfig string) (*kubernetes.Client
 
 	var lastErr error
 	retries := 0
-	klog.V(2).Info("Trying to discover Kubernetes version")
+	logging.V(2).Info("Trying to discover Kubernetes version")
 	err = wait.ExponentialBackoff(defaultRetry, func() (bool, error) {
 		v, err = client.Discovery().ServerVersion()
 
@@ -209,7 +288,7 @@ func createApiserverClient(apiserverHost, kubeConfig string) (*kubernetes.Client
 		}
 
 		lastErr = err
-		klog.V(2).Infof("Unexpected error discovering Kubernetes version (attempt %v): %v", retries, err)
+		logging.V(2).Infof("Unexpected error discovering Kubernetes version (attempt %v): %v", retries, err)
 		retries++
 		return false, nil
 	})
@@ -230,6 +309,30 @@ func createApiserverClient(apiserverHost, kubeConfig string) (*kubernetes.Client
 	return client, nil
 }
 
+// createCustomResourceClient creates a new REST client for the custom
+// resources of the controller using the same configuration of the Kubernetes client.
+func createCustomResourceClient(apiserverHost, kubeConfig string) (versioned.Interface, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags(apiserverHost, kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return versioned.NewForConfig(cfg)
+}
+
+// createSecretReader creates a reader of the Secrets acting as the service
+// account serviceAccount of each namespace, using the same configuration of
+// the Kubernetes client.
+func createSecretReader(apiserverHost, kubeConfig string, client kubernetes.Interface,
+	serviceAccount string, impersonate bool) (store.SecretReader, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags(apiserverHost, kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.NewNamespacedSecretReader(client, cfg, serviceAccount, impersonate), nil
+}
+
 // Handler for fatal init errors. Prints a verbose error message and exits.
 func handleFatalInitError(err error) {
 	klog.Fatalf("Error while initiating a connection to the Kubernetes API server. "+
@@ -248,12 +351,34 @@ func registerHandlers(mux *http.ServeMux) {
 	})
 }
 
+// registerInventory exposes the modules, the template and the Lua code used by NGINX
+func registerInventory(ic *controller.NGINXController, mux *http.ServeMux) {
+	mux.HandleFunc("/inventory", func(w http.ResponseWriter, r *http.Request) {
+		b, err := json.Marshal(ic.Inventory())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+	})
+}
+
 func registerHealthz(ic *controller.NGINXController, mux *http.ServeMux) {
 	// expose health check endpoint (/healthz)
 	healthz.InstallHandler(mux,
 		healthz.PingHealthz,
 		ic,
 	)
+
+	// expose readiness endpoint (/readyz), failing during the warm-up
+	healthz.InstallPathHandler(mux, "/readyz",
+		healthz.PingHealthz,
+		ic,
+		healthz.NamedCheck("warmup", ic.CheckWarmup),
+	)
 }
 
 func registerMetrics(reg *prometheus.Registry, mux *http.ServeMux) {
@@ -267,6 +392,13 @@ func registerMetrics(reg *prometheus.Registry, mux *http.ServeMux) {
 
 }
 
+func registerAutoscalingMetrics(reg *prometheus.Registry, mux *http.ServeMux) {
+	mux.Handle(
+		"/metrics/autoscaling",
+		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}),
+	)
+}
+
 func registerProfiler(mux *http.ServeMux) {
 	mux.HandleFunc("/debug/pprof/", pprof.Index)
 	mux.HandleFunc("/debug/pprof/heap", pprof.Index)
@@ -20,13 +20,13 @@ import (
 	"os"
 	"os/exec"
 
-	"k8s.io/klog"
+	"k8s.io/ingress-nginx/internal/logging"
 )
 
 func nginxVersion() {
 	flag := "-v"
 
-	if klog.V(2) {
+	if logging.V(2) {
 		flag = "-V"
 	}
 
@@ -102,7 +102,7 @@ spec:
           readinessProbe:
             failureThreshold: 3
             httpGet:
-              path: /healthz
+              path: /readyz
               port: 10254
               scheme: HTTP
             periodSeconds: 10
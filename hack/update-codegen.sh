@@ -25,14 +25,15 @@ CODEGEN_PKG=${CODEGEN_PKG:-$(cd "${SCRIPT_ROOT}"; ls -d -1 ./vendor/k8s.io/code-
 # --output-base    because this script should also be able to run inside the vendor dir of
 #                  k8s.io/kubernetes. The output-base is needed for the generators to output into the vendor dir
 #                  instead of the $GOPATH directly. For normal projects this can be dropped.
-#${CODEGEN_PKG}/generate-groups.sh "deepcopy,client,informer,lister" \
-#  k8s.io/ingress-nginx/pkg/client k8s.io/ingress-nginx/pkg/apis \
-#  nginxingress:v1alpha1 \
-#  --output-base "$(dirname ${BASH_SOURCE})/../../.."
 mkdir -p ${CODEGEN_PKG}/hack
 cp ${SCRIPT_ROOT}/hack/boilerplate/boilerplate.go.txt ${CODEGEN_PKG}/hack/boilerplate.go.txt
 chmod +x ${CODEGEN_PKG}/*.sh
 
+${CODEGEN_PKG}/generate-groups.sh "deepcopy,client,informer,lister" \
+  k8s.io/ingress-nginx/pkg/client k8s.io/ingress-nginx/pkg/apis \
+  nginxingress:v1alpha1 \
+  --output-base "$(dirname ${BASH_SOURCE})/../../.."
+
 ${CODEGEN_PKG}/generate-groups.sh "deepcopy" \
   k8s.io/ingress-nginx/internal k8s.io/ingress-nginx/internal \
   .:ingress \
@@ -0,0 +1,449 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificate
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+const (
+	// the keys of the secret, in addition to tls.crt and tls.key
+	caBundleKey = "ca.crt"
+	caKeyKey    = "ca.key"
+
+	caValidity          = 10 * 365 * 24 * time.Hour
+	certificateValidity = 365 * 24 * time.Hour
+
+	// the secret is read again at this interval, to renew the certificates
+	// and serve the ones renewed by the other replicas
+	syncInterval = time.Hour
+)
+
+// Manager issues the serving certificate of the admission webhook with a CA
+// generated by the controller. Both are stored in a secret shared by the
+// replicas, renewed before they expire, and the CA bundle of the webhook
+// configuration is kept up to date.
+type Manager struct {
+	client kubernetes.Interface
+
+	secretNamespace string
+	secretName      string
+
+	serviceNamespace string
+	serviceName      string
+
+	// the name of the ValidatingWebhookConfiguration, the CA bundle is not
+	// updated when it is empty
+	webhookConfiguration string
+
+	lock        sync.RWMutex
+	certificate *tls.Certificate
+	err         error
+
+	now func() time.Time
+}
+
+// NewManager returns a manager storing the certificates in the secret
+// (namespace/name), issued for the names of the service (namespace/name)
+func NewManager(client kubernetes.Interface, secret, service, webhookConfiguration string) (*Manager, error) {
+	secretNamespace, secretName, err := k8s.ParseNameNS(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceNamespace, serviceName, err := k8s.ParseNameNS(service)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manager{
+		client:               client,
+		secretNamespace:      secretNamespace,
+		secretName:           secretName,
+		serviceNamespace:     serviceNamespace,
+		serviceName:          serviceName,
+		webhookConfiguration: webhookConfiguration,
+		err:                  fmt.Errorf("the certificate of the webhook was not issued yet"),
+		now:                  time.Now,
+	}, nil
+}
+
+// GetCertificate implements the tls.Config.GetCertificate interface
+func (m *Manager) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.certificate, m.err
+}
+
+// Run renews the certificates until the channel is closed
+func (m *Manager) Run(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := m.Sync(); err != nil {
+			klog.Errorf("Error syncing the certificate of the webhook: %v", err)
+		}
+	}, syncInterval, stopCh)
+}
+
+// Sync reads or renews the certificates of the secret, serves the current
+// one and updates the CA bundle of the webhook configuration
+func (m *Manager) Sync() error {
+	secret, err := m.syncSecret()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.X509KeyPair(secret.Data[apiv1.TLSCertKey], secret.Data[apiv1.TLSPrivateKeyKey])
+	if err != nil {
+		return fmt.Errorf("unexpected error loading the certificate of the secret %v/%v: %v", m.secretNamespace, m.secretName, err)
+	}
+
+	m.lock.Lock()
+	m.certificate, m.err = &cert, nil
+	m.lock.Unlock()
+
+	return m.syncWebhookConfiguration(secret.Data[caBundleKey])
+}
+
+func (m *Manager) syncSecret() (*apiv1.Secret, error) {
+	secrets := m.client.CoreV1().Secrets(m.secretNamespace)
+
+	var secret *apiv1.Secret
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		secret, err = secrets.Get(m.secretName, metav1.GetOptions{})
+		create := k8sErrors.IsNotFound(err)
+		if create {
+			secret = &apiv1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      m.secretName,
+					Namespace: m.secretNamespace,
+				},
+				Type: apiv1.SecretTypeTLS,
+			}
+		} else if err != nil {
+			return err
+		}
+
+		data, renewed, err := m.renew(secret.Data)
+		if err != nil || !renewed {
+			return err
+		}
+		secret.Data = data
+
+		if create {
+			secret, err = secrets.Create(secret)
+			// another replica created the secret first, its certificates are used
+			if k8sErrors.IsAlreadyExists(err) {
+				return k8sErrors.NewConflict(schema.GroupResource{Resource: "secrets"}, m.secretName, err)
+			}
+		} else {
+			secret, err = secrets.Update(secret)
+		}
+		if err != nil {
+			return err
+		}
+
+		klog.Infof("Renewed the certificate of the webhook in the secret %v/%v", m.secretNamespace, m.secretName)
+		return nil
+	})
+
+	return secret, err
+}
+
+// renew returns the content of the secret with the certificates that are
+// missing or expire soon replaced. The previous CAs are kept in the bundle
+// until they expire, the servers using an older certificate are trusted
+// until they read the secret again.
+func (m *Manager) renew(data map[string][]byte) (map[string][]byte, bool, error) {
+	now := m.now()
+	renewed := false
+
+	bundle := parseCertificates(data[caBundleKey])
+
+	ca, caKey, err := parseCA(bundle, data[caKeyKey])
+	if err != nil || expiresSoon(ca, now) {
+		ca, caKey, err = newCA(now)
+		if err != nil {
+			return nil, false, err
+		}
+		renewed = true
+	}
+
+	cert, err := parseCertificate(data[apiv1.TLSCertKey])
+	if renewed || err != nil || expiresSoon(cert, now) ||
+		cert.CheckSignatureFrom(ca) != nil || !sameNames(cert.DNSNames, m.dnsNames()) {
+		certPEM, keyPEM, err := m.newCertificate(ca, caKey, now)
+		if err != nil {
+			return nil, false, err
+		}
+
+		keyDER, err := x509.MarshalECPrivateKey(caKey)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return map[string][]byte{
+			caBundleKey:            encodeBundle(ca, bundle, now),
+			caKeyKey:               pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+			apiv1.TLSCertKey:       certPEM,
+			apiv1.TLSPrivateKeyKey: keyPEM,
+		}, true, nil
+	}
+
+	return data, false, nil
+}
+
+// dnsNames returns the names used by the kube API server to call the webhook
+func (m *Manager) dnsNames() []string {
+	return []string{
+		m.serviceName,
+		fmt.Sprintf("%v.%v", m.serviceName, m.serviceNamespace),
+		fmt.Sprintf("%v.%v.svc", m.serviceName, m.serviceNamespace),
+	}
+}
+
+func (m *Manager) newCertificate(ca *x509.Certificate, caKey *ecdsa.PrivateKey, now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := m.dnsNames()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: names[len(names)-1]},
+		DNSNames:     names,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(certificateValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+func (m *Manager) syncWebhookConfiguration(bundle []byte) error {
+	if m.webhookConfiguration == "" {
+		return nil
+	}
+
+	configurations := m.client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations()
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configuration, err := configurations.Get(m.webhookConfiguration, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		found := false
+		changed := false
+		for i := range configuration.Webhooks {
+			clientConfig := &configuration.Webhooks[i].ClientConfig
+			if clientConfig.Service == nil ||
+				clientConfig.Service.Namespace != m.serviceNamespace || clientConfig.Service.Name != m.serviceName {
+				continue
+			}
+
+			found = true
+			if !bytes.Equal(clientConfig.CABundle, bundle) {
+				clientConfig.CABundle = bundle
+				changed = true
+			}
+		}
+
+		if !found {
+			klog.Warningf("The validating webhook configuration %v does not call the service %v/%v", m.webhookConfiguration, m.serviceNamespace, m.serviceName)
+		}
+
+		if !changed {
+			return nil
+		}
+
+		_, err = configurations.Update(configuration)
+		if err != nil {
+			return err
+		}
+
+		klog.Infof("Updated the CA bundle of the validating webhook configuration %v", m.webhookConfiguration)
+		return nil
+	})
+}
+
+func newCA(now time.Time) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("nginx-ingress-webhook-ca@%v", now.Unix())},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ca, key, nil
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// parseCA returns the CA of the private key, the first certificate of the bundle
+func parseCA(bundle []*x509.Certificate, keyPEM []byte) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	if len(bundle) == 0 {
+		return nil, nil, fmt.Errorf("no CA certificate")
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, nil, fmt.Errorf("no CA private key")
+	}
+
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ca := bundle[0]
+	pub, ok := ca.PublicKey.(*ecdsa.PublicKey)
+	if !ok || pub.X.Cmp(key.X) != 0 || pub.Y.Cmp(key.Y) != 0 {
+		return nil, nil, fmt.Errorf("the CA private key does not match the CA certificate")
+	}
+
+	return ca, key, nil
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	certs := parseCertificates(certPEM)
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate")
+	}
+	return certs[0], nil
+}
+
+func parseCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			klog.Warningf("Ignoring an invalid certificate of the webhook secret: %v", err)
+			continue
+		}
+
+		certs = append(certs, cert)
+	}
+}
+
+// encodeBundle returns the CA followed by the previous CAs not expired yet
+func encodeBundle(ca *x509.Certificate, previous []*x509.Certificate, now time.Time) []byte {
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	for _, cert := range previous {
+		if cert.Equal(ca) || now.After(cert.NotAfter) {
+			continue
+		}
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return bundle
+}
+
+// expiresSoon returns true during the last third of the validity of the certificate
+func expiresSoon(cert *x509.Certificate, now time.Time) bool {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	return now.After(cert.NotAfter.Add(-lifetime / 3))
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
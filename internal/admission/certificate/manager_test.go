@@ -0,0 +1,212 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificate
+
+import (
+	"bytes"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"k8s.io/api/admissionregistration/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestManager(t *testing.T, client kubernetes.Interface, now *time.Time) *Manager {
+	m, err := NewManager(client, "ingress-nginx/webhook-certificate", "ingress-nginx/ingress-validation-webhook", "check-ingress")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m.now = func() time.Time { return *now }
+	return m
+}
+
+func syncManager(t *testing.T, m *Manager) (*x509.Certificate, []*x509.Certificate) {
+	if err := m.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cert, err := m.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	secret, err := m.client.CoreV1().Secrets("ingress-nginx").Get("webhook-certificate", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	configuration, err := m.client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations().Get("check-ingress", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(configuration.Webhooks[0].ClientConfig.CABundle, secret.Data[caBundleKey]) {
+		t.Errorf("expected the CA bundle of the secret in the webhook configuration")
+	}
+
+	if configuration.Webhooks[1].ClientConfig.CABundle != nil {
+		t.Errorf("expected the webhook calling another service to be unchanged")
+	}
+
+	return leaf, parseCertificates(secret.Data[caBundleKey])
+}
+
+func verify(t *testing.T, leaf *x509.Certificate, bundle []*x509.Certificate, now time.Time) {
+	roots := x509.NewCertPool()
+	for _, ca := range bundle {
+		roots.AddCert(ca)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:     "ingress-validation-webhook.ingress-nginx.svc",
+		Roots:       roots,
+		CurrentTime: now,
+	})
+	if err != nil {
+		t.Errorf("unexpected error verifying the certificate: %v", err)
+	}
+}
+
+func TestManager(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1beta1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "check-ingress"},
+		Webhooks: []v1beta1.ValidatingWebhook{
+			{
+				Name: "validate.nginx.ingress.kubernetes.io",
+				ClientConfig: v1beta1.WebhookClientConfig{
+					Service: &v1beta1.ServiceReference{Namespace: "ingress-nginx", Name: "ingress-validation-webhook"},
+				},
+			},
+			{
+				Name: "validate.example.com",
+				ClientConfig: v1beta1.WebhookClientConfig{
+					Service: &v1beta1.ServiceReference{Namespace: "default", Name: "other-webhook"},
+				},
+			},
+		},
+	})
+
+	now := time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)
+	m := newTestManager(t, client, &now)
+
+	if _, err := m.GetCertificate(nil); err == nil {
+		t.Errorf("expected an error before the first sync")
+	}
+
+	leaf, bundle := syncManager(t, m)
+	verify(t, leaf, bundle, now)
+	if len(bundle) != 1 {
+		t.Errorf("expected one CA but got %v", len(bundle))
+	}
+
+	secret, _ := client.CoreV1().Secrets("ingress-nginx").Get("webhook-certificate", metav1.GetOptions{})
+	if secret.Type != apiv1.SecretTypeTLS {
+		t.Errorf("expected a secret of type %v but got %v", apiv1.SecretTypeTLS, secret.Type)
+	}
+
+	// another replica serves the same certificate
+	other, _ := syncManager(t, newTestManager(t, client, &now))
+	if !other.Equal(leaf) {
+		t.Errorf("expected the certificate of the secret to be reused")
+	}
+
+	// the certificate is renewed during the last third of its validity
+	now = now.Add(200 * 24 * time.Hour)
+	renewed, _ := syncManager(t, m)
+	if !renewed.Equal(leaf) {
+		t.Errorf("expected the certificate to be valid for at least 200 days")
+	}
+
+	now = now.Add(50 * 24 * time.Hour)
+	renewed, renewedBundle := syncManager(t, m)
+	if renewed.Equal(leaf) {
+		t.Errorf("expected the certificate to be renewed")
+	}
+	if !renewedBundle[0].Equal(bundle[0]) {
+		t.Errorf("expected the CA to be kept")
+	}
+	verify(t, renewed, renewedBundle, now)
+
+	// the previous CA stays in the bundle when the CA is rotated
+	now = now.Add(7 * 365 * 24 * time.Hour)
+	rotated, rotatedBundle := syncManager(t, m)
+	if len(rotatedBundle) != 2 || rotatedBundle[0].Equal(bundle[0]) || !rotatedBundle[1].Equal(bundle[0]) {
+		t.Errorf("expected the new CA followed by the previous one")
+	}
+	verify(t, rotated, rotatedBundle, now)
+
+	// and is removed once expired
+	now = now.Add(4 * 365 * 24 * time.Hour)
+	_, expiredBundle := syncManager(t, m)
+	if len(expiredBundle) != 1 || !expiredBundle[0].Equal(rotatedBundle[0]) {
+		t.Errorf("expected the previous CA to be removed from the bundle")
+	}
+}
+
+func TestManagerServiceChanged(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1beta1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "check-ingress"},
+		Webhooks: []v1beta1.ValidatingWebhook{
+			{ClientConfig: v1beta1.WebhookClientConfig{Service: &v1beta1.ServiceReference{Namespace: "ingress-nginx", Name: "ingress-validation-webhook"}}},
+			{ClientConfig: v1beta1.WebhookClientConfig{Service: &v1beta1.ServiceReference{Namespace: "ingress-nginx", Name: "webhook"}}},
+		},
+	})
+
+	now := time.Now()
+	leaf, bundle := syncManager(t, newTestManager(t, client, &now))
+
+	m := newTestManager(t, client, &now)
+	m.serviceName = "webhook"
+	if err := m.Sync(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cert, _ := m.GetCertificate(nil)
+	renewed, _ := x509.ParseCertificate(cert.Certificate[0])
+	if renewed.Equal(leaf) {
+		t.Errorf("expected the certificate to be issued for the new service")
+	}
+
+	if err := renewed.VerifyHostname("webhook.ingress-nginx.svc"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if renewed.CheckSignatureFrom(bundle[0]) != nil {
+		t.Errorf("expected the CA to be kept")
+	}
+}
+
+func TestNewManager(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	if _, err := NewManager(client, "webhook-certificate", "ingress-nginx/webhook", ""); err == nil {
+		t.Errorf("expected an error with a secret without namespace")
+	}
+
+	if _, err := NewManager(client, "ingress-nginx/webhook-certificate", "webhook", ""); err == nil {
+		t.Errorf("expected an error with a service without namespace")
+	}
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/file"
+	"k8s.io/ingress-nginx/internal/logging"
+)
+
+// File is the location of the audit trail written by the controller
+var File = filepath.Join(file.AuditDirectory, "audit.json")
+
+const (
+	// Added indicates the object was not used in the previous configuration
+	Added = "added"
+	// Updated indicates the object changed after the previous configuration
+	Updated = "updated"
+	// Deleted indicates the object is not used anymore
+	Deleted = "deleted"
+)
+
+// Object describes an object used to build a configuration. Data contains
+// the checksum of each key of the object, so changes can be detected
+// without keeping the content.
+type Object struct {
+	Kind            string            `json:"kind"`
+	Namespace       string            `json:"namespace,omitempty"`
+	Name            string            `json:"name"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Data            map[string]string `json:"data,omitempty"`
+}
+
+func (o Object) key() string {
+	return fmt.Sprintf("%v/%v/%v", o.Kind, o.Namespace, o.Name)
+}
+
+// Change describes a change in an object used to build a configuration
+type Change struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Action is one of added, updated or deleted
+	Action string `json:"action"`
+	// ResourceVersion of the object, empty if the object was deleted
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// PreviousResourceVersion of the object, empty if the object was added
+	PreviousResourceVersion string `json:"previousResourceVersion,omitempty"`
+	// Keys contains the keys of the object that changed
+	Keys []string `json:"keys,omitempty"`
+}
+
+// Entry describes a configuration applied to NGINX
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Checksum of the resulting configuration
+	Checksum string `json:"checksum"`
+	// Reload indicates if NGINX was reloaded
+	Reload bool `json:"reload"`
+	// Initial indicates the first configuration applied by the controller.
+	// The changes of the objects are not recorded.
+	Initial bool `json:"initial,omitempty"`
+	// RollbackOf contains the revision of the configuration history applied again
+	RollbackOf int `json:"rollbackOf,omitempty"`
+	// Changes contains the objects changed since the previous configuration
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Sink persists the entries of the audit trail
+type Sink interface {
+	Write(entries []Entry) error
+}
+
+// Log keeps the last entries of the audit trail in memory and copies them
+// to the sinks after each change
+type Log struct {
+	mu sync.Mutex
+
+	size    int
+	sinks   []Sink
+	entries []Entry
+	objects []Object
+	started bool
+}
+
+// NewLog creates an audit trail keeping the last size entries
+func NewLog(size int, sinks ...Sink) *Log {
+	return &Log{
+		size:  size,
+		sinks: sinks,
+	}
+}
+
+// Record adds an entry with the changes of the objects since the previous entry.
+// Errors writing to the sinks are logged.
+func (l *Log) Record(entry Entry, objects []Object) Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	if l.started {
+		entry.Changes = Diff(l.objects, objects)
+	} else {
+		entry.Initial = true
+		l.started = true
+	}
+
+	l.objects = objects
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > l.size {
+		l.entries = l.entries[len(l.entries)-l.size:]
+	}
+
+	if data, err := json.Marshal(entry); err == nil {
+		logging.V(2).Infof("Configuration audit: %s", data)
+	}
+
+	for _, sink := range l.sinks {
+		err := sink.Write(l.entries)
+		if err != nil {
+			klog.Warningf("Unexpected error writing the audit trail: %v", err)
+		}
+	}
+
+	return entry
+}
+
+// Entries returns the entries of the audit trail, oldest first
+func (l *Log) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]Entry, len(l.entries))
+	copy(entries, l.entries)
+	return entries
+}
+
+// Diff returns the changes between two lists of objects, sorted by kind,
+// namespace and name
+func Diff(previous, current []Object) []Change {
+	objects := map[string]Object{}
+	for _, o := range previous {
+		objects[o.key()] = o
+	}
+
+	changes := []Change{}
+	for _, o := range current {
+		old, ok := objects[o.key()]
+		delete(objects, o.key())
+
+		if !ok {
+			changes = append(changes, Change{
+				Kind:            o.Kind,
+				Namespace:       o.Namespace,
+				Name:            o.Name,
+				Action:          Added,
+				ResourceVersion: o.ResourceVersion,
+			})
+			continue
+		}
+
+		keys := changedKeys(old.Data, o.Data)
+		if old.ResourceVersion == o.ResourceVersion && len(keys) == 0 {
+			continue
+		}
+
+		changes = append(changes, Change{
+			Kind:                    o.Kind,
+			Namespace:               o.Namespace,
+			Name:                    o.Name,
+			Action:                  Updated,
+			ResourceVersion:         o.ResourceVersion,
+			PreviousResourceVersion: old.ResourceVersion,
+			Keys:                    keys,
+		})
+	}
+
+	for _, o := range objects {
+		changes = append(changes, Change{
+			Kind:                    o.Kind,
+			Namespace:               o.Namespace,
+			Name:                    o.Name,
+			Action:                  Deleted,
+			PreviousResourceVersion: o.ResourceVersion,
+		})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind < changes[j].Kind
+		}
+		if changes[i].Namespace != changes[j].Namespace {
+			return changes[i].Namespace < changes[j].Namespace
+		}
+		return changes[i].Name < changes[j].Name
+	})
+
+	return changes
+}
+
+// changedKeys returns the sorted keys added, removed or with a different checksum
+func changedKeys(previous, current map[string]string) []string {
+	keys := []string{}
+	for k, v := range current {
+		if old, ok := previous[k]; !ok || old != v {
+			keys = append(keys, k)
+		}
+	}
+
+	for k := range previous {
+		if _, ok := current[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Checksum returns the checksum of the value of a key
+func Checksum(value []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(value))
+}
+
+// Filter returns the entries containing changes of the object, in the
+// form namespace/name or kind/namespace/name
+func Filter(entries []Entry, object string) []Entry {
+	filtered := []Entry{}
+	for _, entry := range entries {
+		for _, change := range entry.Changes {
+			if object == fmt.Sprintf("%v/%v", change.Namespace, change.Name) ||
+				object == fmt.Sprintf("%v/%v/%v", change.Kind, change.Namespace, change.Name) {
+				filtered = append(filtered, entry)
+				break
+			}
+		}
+	}
+
+	return filtered
+}
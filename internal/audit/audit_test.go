@@ -0,0 +1,120 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type fakeSink struct {
+	entries []Entry
+}
+
+func (f *fakeSink) Write(entries []Entry) error {
+	f.entries = entries
+	return nil
+}
+
+func TestDiff(t *testing.T) {
+	previous := []Object{
+		{Kind: "ConfigMap", Namespace: "ingress-nginx", Name: "config", ResourceVersion: "1", Data: map[string]string{"a": "1", "b": "2"}},
+		{Kind: "Ingress", Namespace: "default", Name: "foo", ResourceVersion: "2", Data: map[string]string{"spec": "1"}},
+		{Kind: "Ingress", Namespace: "default", Name: "bar", ResourceVersion: "3", Data: map[string]string{"spec": "1"}},
+	}
+
+	current := []Object{
+		{Kind: "ConfigMap", Namespace: "ingress-nginx", Name: "config", ResourceVersion: "4", Data: map[string]string{"a": "3", "c": "4"}},
+		{Kind: "Ingress", Namespace: "default", Name: "foo", ResourceVersion: "2", Data: map[string]string{"spec": "1"}},
+		{Kind: "Secret", Namespace: "default", Name: "tls", ResourceVersion: "5", Data: map[string]string{"tls.crt": "1"}},
+	}
+
+	expected := []Change{
+		{Kind: "ConfigMap", Namespace: "ingress-nginx", Name: "config", Action: Updated, ResourceVersion: "4", PreviousResourceVersion: "1", Keys: []string{"a", "b", "c"}},
+		{Kind: "Ingress", Namespace: "default", Name: "bar", Action: Deleted, PreviousResourceVersion: "3"},
+		{Kind: "Secret", Namespace: "default", Name: "tls", Action: Added, ResourceVersion: "5"},
+	}
+
+	changes := Diff(previous, current)
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected %+v but returned %+v", expected, changes)
+	}
+
+	if changes := Diff(current, current); len(changes) != 0 {
+		t.Errorf("expected no changes but returned %+v", changes)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	sink := &fakeSink{}
+	l := NewLog(3, sink)
+
+	objects := func(version int) []Object {
+		return []Object{{Kind: "Ingress", Namespace: "default", Name: "foo", ResourceVersion: fmt.Sprintf("%v", version)}}
+	}
+
+	entry := l.Record(Entry{Checksum: "0", Reload: true}, objects(0))
+	if !entry.Initial || len(entry.Changes) != 0 {
+		t.Errorf("expected an initial entry without changes but returned %+v", entry)
+	}
+
+	for i := 1; i <= 4; i++ {
+		entry = l.Record(Entry{Checksum: fmt.Sprintf("%v", i)}, objects(i))
+		if entry.Initial {
+			t.Errorf("unexpected initial entry %+v", entry)
+		}
+		if len(entry.Changes) != 1 || entry.Changes[0].ResourceVersion != fmt.Sprintf("%v", i) {
+			t.Errorf("unexpected changes %+v", entry.Changes)
+		}
+	}
+
+	entries := l.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries but returned %v", len(entries))
+	}
+	if entries[0].Checksum != "2" || entries[2].Checksum != "4" {
+		t.Errorf("expected the last entries but returned %+v", entries)
+	}
+	if !reflect.DeepEqual(sink.entries, entries) {
+		t.Errorf("expected the sink to contain %+v but returned %+v", entries, sink.entries)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	entries := []Entry{
+		{Checksum: "1", Changes: []Change{{Kind: "Ingress", Namespace: "default", Name: "foo"}}},
+		{Checksum: "2", Changes: []Change{{Kind: "Secret", Namespace: "default", Name: "foo"}}},
+		{Checksum: "3", Changes: []Change{{Kind: "Ingress", Namespace: "default", Name: "bar"}}},
+	}
+
+	testCases := []struct {
+		object   string
+		expected int
+	}{
+		{"default/foo", 2},
+		{"Secret/default/foo", 1},
+		{"default/bar", 1},
+		{"default/baz", 0},
+	}
+
+	for _, tc := range testCases {
+		if filtered := Filter(entries, tc.object); len(filtered) != tc.expected {
+			t.Errorf("%v: expected %v entries but returned %v", tc.object, tc.expected, len(filtered))
+		}
+	}
+}
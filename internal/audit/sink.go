@@ -0,0 +1,185 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/file"
+)
+
+// FileSink writes the audit trail to a file
+type FileSink struct {
+	Path string
+}
+
+// Write replaces the content of the file with the entries
+func (s FileSink) Write(entries []Entry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.Path), ".audit-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), file.ReadWriteByUser)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// ReadFile returns the entries of the audit trail written by a FileSink
+func ReadFile(path string) ([]Entry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ConfigMapSink writes the audit trail to a key of a ConfigMap.
+// Each controller pod uses its own key so the replicas can share the ConfigMap.
+type ConfigMapSink struct {
+	Client    clientset.Interface
+	Namespace string
+	Name      string
+	Key       string
+}
+
+// Write replaces the content of the key with the entries, creating the
+// ConfigMap if it does not exist
+func (s ConfigMapSink) Write(entries []Entry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	configMaps := s.Client.CoreV1().ConfigMaps(s.Namespace)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(s.Name, metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			_, err = configMaps.Create(&apiv1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      s.Name,
+					Namespace: s.Namespace,
+				},
+				Data: map[string]string{
+					s.Key: string(data),
+				},
+			})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[s.Key] = string(data)
+
+		_, err = configMaps.Update(cm)
+		return err
+	})
+}
+
+// AsyncSink writes the entries to a sink in the background, so the callers
+// of Write do not wait for slow sinks like the API server. Only the last
+// entries are written when several changes are recorded during a write.
+type AsyncSink struct {
+	sink Sink
+
+	mu      sync.Mutex
+	entries []Entry
+	pending chan struct{}
+}
+
+// NewAsyncSink creates an AsyncSink writing to sink until stopCh is closed
+func NewAsyncSink(sink Sink, stopCh <-chan struct{}) *AsyncSink {
+	s := &AsyncSink{
+		sink:    sink,
+		pending: make(chan struct{}, 1),
+	}
+
+	go s.run(stopCh)
+
+	return s
+}
+
+// Write queues the entries to be written and returns immediately
+func (s *AsyncSink) Write(entries []Entry) error {
+	s.mu.Lock()
+	s.entries = make([]Entry, len(entries))
+	copy(s.entries, entries)
+	s.mu.Unlock()
+
+	select {
+	case s.pending <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (s *AsyncSink) run(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-s.pending:
+			s.mu.Lock()
+			entries := s.entries
+			s.mu.Unlock()
+
+			err := s.sink.Write(entries)
+			if err != nil {
+				klog.Warningf("Unexpected error writing the audit trail: %v", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporal directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sink := FileSink{Path: filepath.Join(dir, "audit.json")}
+	err = sink.Write([]Entry{{Checksum: "1"}, {Checksum: "2"}})
+	if err != nil {
+		t.Fatalf("unexpected error writing the audit trail: %v", err)
+	}
+
+	entries, err := ReadFile(sink.Path)
+	if err != nil {
+		t.Fatalf("unexpected error reading the audit trail: %v", err)
+	}
+	if len(entries) != 2 || entries[1].Checksum != "2" {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+}
+
+func TestConfigMapSink(t *testing.T) {
+	client := testclient.NewSimpleClientset()
+
+	for _, key := range []string{"pod-1", "pod-2"} {
+		sink := ConfigMapSink{
+			Client:    client,
+			Namespace: "ingress-nginx",
+			Name:      "audit",
+			Key:       key,
+		}
+
+		err := sink.Write([]Entry{{Checksum: key}})
+		if err != nil {
+			t.Fatalf("unexpected error writing the audit trail: %v", err)
+		}
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("ingress-nginx").Get("audit", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error getting the ConfigMap: %v", err)
+	}
+
+	for _, key := range []string{"pod-1", "pod-2"} {
+		entries := []Entry{}
+		err := json.Unmarshal([]byte(cm.Data[key]), &entries)
+		if err != nil {
+			t.Fatalf("unexpected error decoding the entries of %v: %v", key, err)
+		}
+		if len(entries) != 1 || entries[0].Checksum != key {
+			t.Errorf("unexpected entries of %v: %+v", key, entries)
+		}
+	}
+}
+
+type blockingSink struct {
+	release chan struct{}
+	written chan []Entry
+}
+
+func (b *blockingSink) Write(entries []Entry) error {
+	<-b.release
+	b.written <- entries
+	return nil
+}
+
+func TestAsyncSink(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	sink := &blockingSink{
+		release: make(chan struct{}),
+		written: make(chan []Entry, 10),
+	}
+	s := NewAsyncSink(sink, stopCh)
+
+	// the writes must not wait for the blocked sink
+	for i := 1; i <= 3; i++ {
+		entries := []Entry{}
+		for j := 1; j <= i; j++ {
+			entries = append(entries, Entry{Checksum: fmt.Sprintf("%v", j)})
+		}
+		if err := s.Write(entries); err != nil {
+			t.Fatalf("unexpected error writing the audit trail: %v", err)
+		}
+	}
+
+	close(sink.release)
+
+	var last []Entry
+	err := wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		for {
+			select {
+			case last = <-sink.written:
+			default:
+				return len(last) == 3, nil
+			}
+		}
+	})
+	if err != nil {
+		t.Fatalf("expected the last entries to be written but returned %+v", last)
+	}
+}
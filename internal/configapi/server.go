@@ -0,0 +1,213 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// Version defines the version of the configuration API. Fields are only
+// added to the documents of a version, never renamed or removed.
+const Version = "v1"
+
+const (
+	configurationPath = "/" + Version + "/configuration"
+	serversPath       = "/" + Version + "/servers"
+	backendsPath      = "/" + Version + "/backends"
+	certificatesPath  = "/" + Version + "/certificates"
+)
+
+// Provider returns the configuration running in NGINX
+type Provider interface {
+	// RunningConfiguration returns the configuration applied by the last
+	// successful synchronization. The returned value must not be modified.
+	RunningConfiguration() *ingress.Configuration
+}
+
+// Certificate describes the SSL certificate used by a server. It never
+// contains the private key.
+type Certificate struct {
+	Hostname string    `json:"hostname"`
+	Secret   string    `json:"secret,omitempty"`
+	CN       []string  `json:"cn"`
+	Expires  time.Time `json:"expires"`
+	SHA      string    `json:"sha"`
+	FileName string    `json:"fileName"`
+}
+
+// Server implements a read-only HTTP API returning the running configuration
+// so external tools (i.e. dashboards or policy scanners) do not need to parse
+// the NGINX configuration file
+type Server struct {
+	Provider Provider
+	Token    string
+}
+
+// NewServer creates a new configuration API server. When the token is not
+// empty requests must contain it in the Authorization header using the
+// Bearer scheme.
+func NewServer(token string, p Provider) *Server {
+	return &Server{
+		Provider: p,
+		Token:    token,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case configurationPath, serversPath, backendsPath, certificatesPath:
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := sanitize(s.Provider.RunningConfiguration())
+
+	switch r.URL.Path {
+	case configurationPath:
+		writeJSON(w, cfg)
+	case serversPath:
+		writeJSON(w, filterServers(cfg.Servers, r.URL.Query().Get("hostname")))
+	case backendsPath:
+		writeJSON(w, filterBackends(cfg.Backends, r.URL.Query().Get("name")))
+	case certificatesPath:
+		writeJSON(w, Certificates(cfg.Servers))
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Token == "" {
+		return true
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+// sanitize returns a copy of the configuration without the content of the
+// certificates and private keys
+func sanitize(cfg *ingress.Configuration) *ingress.Configuration {
+	if cfg == nil {
+		return &ingress.Configuration{}
+	}
+
+	c := *cfg
+	c.Servers = make([]*ingress.Server, 0, len(cfg.Servers))
+	for _, server := range cfg.Servers {
+		srv := *server
+		srv.SSLCert.PemCertKey = ""
+		srv.SSLCert.Certificate = nil
+		c.Servers = append(c.Servers, &srv)
+	}
+
+	return &c
+}
+
+func filterServers(servers []*ingress.Server, hostname string) []*ingress.Server {
+	if hostname == "" {
+		return servers
+	}
+
+	filtered := []*ingress.Server{}
+	for _, server := range servers {
+		if server.Hostname == hostname {
+			filtered = append(filtered, server)
+		}
+	}
+
+	return filtered
+}
+
+func filterBackends(backends []*ingress.Backend, name string) []*ingress.Backend {
+	if name == "" {
+		return backends
+	}
+
+	filtered := []*ingress.Backend{}
+	for _, backend := range backends {
+		if backend.Name == name {
+			filtered = append(filtered, backend)
+		}
+	}
+
+	return filtered
+}
+
+// Certificates returns the certificates used by the servers, skipping the
+// servers without TLS
+func Certificates(servers []*ingress.Server) []Certificate {
+	certs := []Certificate{}
+	for _, server := range servers {
+		cert := server.SSLCert
+		if cert.PemFileName == "" && cert.PemCertKeyFileName == "" {
+			continue
+		}
+
+		c := Certificate{
+			Hostname: server.Hostname,
+			CN:       cert.CN,
+			Expires:  cert.ExpireTime,
+			SHA:      cert.PemSHA,
+			FileName: cert.PemFileName,
+		}
+
+		if c.FileName == "" {
+			c.FileName = cert.PemCertKeyFileName
+		}
+
+		if c.SHA == "" {
+			c.SHA = cert.PemCertKeySHA
+		}
+
+		if cert.Name != "" {
+			c.Secret = cert.Namespace + "/" + cert.Name
+		}
+
+		certs = append(certs, c)
+	}
+
+	return certs
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
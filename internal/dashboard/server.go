@@ -0,0 +1,269 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dashboard
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/audit"
+	"k8s.io/ingress-nginx/internal/configapi"
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// maxReloads defines the number of reloads displayed in the dashboard
+const maxReloads = 20
+
+// expirationWarning defines the time before the expiration of a
+// certificate when it is highlighted in the dashboard
+const expirationWarning = 14 * 24 * time.Hour
+
+// Provider returns the state displayed in the dashboard
+type Provider interface {
+	configapi.Provider
+	// AuditEntries returns the entries of the audit trail, oldest first
+	AuditEntries() []audit.Entry
+}
+
+// Credentials defines the user and password required by the dashboard
+type Credentials struct {
+	User     string
+	Password string
+}
+
+// ParseCredentials parses the credentials using the form <user>:<password>
+func ParseCredentials(value string) (*Credentials, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("the credentials must use the form <user>:<password>")
+	}
+
+	return &Credentials{
+		User:     parts[0],
+		Password: parts[1],
+	}, nil
+}
+
+// Server implements a read-only web page displaying the hosts, paths,
+// backends, certificates and recent reloads of the running configuration
+type Server struct {
+	Provider    Provider
+	Credentials *Credentials
+}
+
+// NewServer creates a new dashboard server. Requests must be authenticated
+// using the HTTP basic authentication scheme.
+func NewServer(credentials *Credentials, p Provider) *Server {
+	return &Server{
+		Provider:    p,
+		Credentials: credentials,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="ingress-nginx"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var buf bytes.Buffer
+	err := pageTemplate.Execute(&buf, newPage(s.Provider, time.Now()))
+	if err != nil {
+		klog.Errorf("Unexpected error rendering the dashboard: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Credentials == nil {
+		return false
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	validUser := subtle.ConstantTimeCompare([]byte(user), []byte(s.Credentials.User)) == 1
+	validPassword := subtle.ConstantTimeCompare([]byte(password), []byte(s.Credentials.Password)) == 1
+	return validUser && validPassword
+}
+
+type page struct {
+	Generated time.Time
+	Checksum  string
+	Hosts     []host
+	Backends  []backend
+	Certs     []certificate
+	Reloads   []reload
+}
+
+type host struct {
+	Hostname string
+	TLS      bool
+	Paths    []path
+}
+
+type path struct {
+	Path    string
+	Backend string
+	Ingress string
+}
+
+type backend struct {
+	Name      string
+	Endpoints int
+	Canaries  []string
+}
+
+type certificate struct {
+	configapi.Certificate
+	Status string
+}
+
+type reload struct {
+	Time       time.Time
+	Checksum   string
+	Initial    bool
+	RollbackOf int
+	Changes    []string
+}
+
+func newPage(p Provider, now time.Time) *page {
+	cfg := p.RunningConfiguration()
+	if cfg == nil {
+		cfg = &ingress.Configuration{}
+	}
+
+	pg := &page{
+		Generated: now,
+		Checksum:  cfg.ConfigurationChecksum,
+		Hosts:     []host{},
+		Backends:  []backend{},
+		Certs:     []certificate{},
+		Reloads:   []reload{},
+	}
+
+	for _, server := range cfg.Servers {
+		h := host{
+			Hostname: server.Hostname,
+			TLS:      server.SSLCert.PemFileName != "" || server.SSLCert.PemCertKeyFileName != "",
+		}
+
+		for _, location := range server.Locations {
+			pt := path{
+				Path:    location.Path,
+				Backend: location.Backend,
+			}
+
+			if location.Ingress != nil {
+				pt.Ingress = location.Ingress.Namespace + "/" + location.Ingress.Name
+			}
+
+			h.Paths = append(h.Paths, pt)
+		}
+
+		pg.Hosts = append(pg.Hosts, h)
+	}
+
+	for _, b := range cfg.Backends {
+		pg.Backends = append(pg.Backends, backend{
+			Name:      b.Name,
+			Endpoints: len(b.Endpoints),
+			Canaries:  b.AlternativeBackends,
+		})
+	}
+
+	for _, cert := range configapi.Certificates(cfg.Servers) {
+		pg.Certs = append(pg.Certs, certificate{
+			Certificate: cert,
+			Status:      certificateStatus(cert.Expires, now),
+		})
+	}
+
+	sort.SliceStable(pg.Certs, func(i, j int) bool {
+		return pg.Certs[i].Expires.Before(pg.Certs[j].Expires)
+	})
+
+	entries := p.AuditEntries()
+	for i := len(entries) - 1; i >= 0 && len(pg.Reloads) < maxReloads; i-- {
+		entry := entries[i]
+		if !entry.Reload {
+			continue
+		}
+
+		r := reload{
+			Time:       entry.Time,
+			Checksum:   entry.Checksum,
+			Initial:    entry.Initial,
+			RollbackOf: entry.RollbackOf,
+		}
+
+		for _, change := range entry.Changes {
+			name := change.Name
+			if change.Namespace != "" {
+				name = change.Namespace + "/" + name
+			}
+
+			r.Changes = append(r.Changes, fmt.Sprintf("%v %v %v", change.Action, change.Kind, name))
+		}
+
+		pg.Reloads = append(pg.Reloads, r)
+	}
+
+	return pg
+}
+
+// certificateStatus returns the CSS class used to display the expiration
+// of a certificate
+func certificateStatus(expires, now time.Time) string {
+	switch {
+	case !expires.After(now):
+		return "expired"
+	case expires.Sub(now) < expirationWarning:
+		return "warning"
+	default:
+		return "ok"
+	}
+}
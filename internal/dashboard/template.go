@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dashboard
+
+import (
+	"html/template"
+	"time"
+)
+
+var pageTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format("2006-01-02 15:04:05 MST")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>NGINX Ingress controller</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+.ok { color: #1a7f37; }
+.warning { color: #9a6700; font-weight: bold; }
+.expired { color: #cf222e; font-weight: bold; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>NGINX Ingress controller</h1>
+<p class="muted">Generated {{ date .Generated }}{{ if .Checksum }}, configuration checksum {{ .Checksum }}{{ end }}</p>
+
+<h2>Hosts</h2>
+<table>
+<tr><th>Host</th><th>TLS</th><th>Path</th><th>Backend</th><th>Ingress</th></tr>
+{{ range $host := .Hosts }}{{ range $i, $path := $host.Paths }}
+<tr>
+<td>{{ if eq $i 0 }}{{ $host.Hostname }}{{ end }}</td>
+<td>{{ if eq $i 0 }}{{ if $host.TLS }}yes{{ else }}no{{ end }}{{ end }}</td>
+<td>{{ $path.Path }}</td>
+<td>{{ $path.Backend }}</td>
+<td>{{ $path.Ingress }}</td>
+</tr>
+{{ end }}{{ else }}
+<tr><td colspan="5" class="muted">No hosts</td></tr>
+{{ end }}
+</table>
+
+<h2>Backends</h2>
+<table>
+<tr><th>Backend</th><th>Endpoints</th><th>Canaries</th></tr>
+{{ range .Backends }}
+<tr>
+<td>{{ .Name }}</td>
+<td{{ if eq .Endpoints 0 }} class="expired"{{ end }}>{{ .Endpoints }}</td>
+<td>{{ range .Canaries }}{{ . }} {{ end }}</td>
+</tr>
+{{ else }}
+<tr><td colspan="3" class="muted">No backends</td></tr>
+{{ end }}
+</table>
+
+<h2>Certificates</h2>
+<table>
+<tr><th>Host</th><th>Secret</th><th>Common names</th><th>Expires</th></tr>
+{{ range .Certs }}
+<tr>
+<td>{{ .Hostname }}</td>
+<td>{{ .Secret }}</td>
+<td>{{ range .CN }}{{ . }} {{ end }}</td>
+<td class="{{ .Status }}">{{ date .Expires }}</td>
+</tr>
+{{ else }}
+<tr><td colspan="4" class="muted">No certificates</td></tr>
+{{ end }}
+</table>
+
+<h2>Recent reloads</h2>
+<table>
+<tr><th>Time</th><th>Checksum</th><th>Changes</th></tr>
+{{ range .Reloads }}
+<tr>
+<td>{{ date .Time }}</td>
+<td>{{ .Checksum }}</td>
+<td>{{ if .Initial }}initial configuration{{ else if .RollbackOf }}rollback to revision {{ .RollbackOf }}{{ end }}{{ range .Changes }}<div>{{ . }}</div>{{ end }}</td>
+</tr>
+{{ else }}
+<tr><td colspan="3" class="muted">No reloads recorded in the audit trail</td></tr>
+{{ end }}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,340 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tv42/httpunix"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/logging"
+	"k8s.io/ingress-nginx/internal/profiling"
+)
+
+// Socket defines the location of the unix socket used by the controller to
+// serve the debug API
+var Socket = "/tmp/ingress-controller-debug.sock"
+
+const (
+	// LogLevelsPath defines the location used to read and change the
+	// verbosity of the components of the controller
+	LogLevelsPath = "/debug/log-levels"
+	// HostsPath defines the location used to enable the debug logs of
+	// NGINX for a host
+	HostsPath = "/debug/hosts"
+	// ProfilesPath defines the location of the profiles captured by the
+	// continuous profiling, a single profile is returned with ?id=
+	ProfilesPath = "/debug/profiles"
+	// CertificatesPath defines the location of the report of the SSL
+	// certificates synced to disk
+	CertificatesPath = "/debug/certificates"
+	// CertificateLookupPath defines the location used to find the SSL
+	// certificate served for the SNI given with ?sni=
+	CertificateLookupPath = "/debug/certificates/sni"
+
+	// DefaultHostDuration is the time the debug logs of a host are enabled
+	// when no duration is requested
+	DefaultHostDuration = 10 * time.Minute
+
+	socketLocation = "ingress-controller-debug"
+	requestTimeout = 60 * time.Second
+)
+
+// HostDebugger enables the debug logs of NGINX for a host
+type HostDebugger interface {
+	// EnableHostDebug enables the debug logs of the host during the
+	// duration and returns the time they are disabled again
+	EnableHostDebug(host string, duration time.Duration) (time.Time, error)
+	// DisableHostDebug disables the debug logs of the host
+	DisableHostDebug(host string) error
+	// DebugHosts returns the hosts with the debug logs enabled and the
+	// time they are disabled again
+	DebugHosts() map[string]time.Time
+}
+
+// CertificateReport describes the SSL certificates synced to disk that are
+// not used anymore and the hosts serving the default certificate although
+// their Ingress references a secret
+type CertificateReport struct {
+	// UnreferencedSecrets are the secrets no Ingress references
+	UnreferencedSecrets []string `json:"unreferencedSecrets"`
+	// SoonOrphanedSecrets are the secrets only referenced by Ingresses
+	// being deleted
+	SoonOrphanedSecrets []string `json:"soonOrphanedSecrets"`
+	// DefaultCertificateHosts contains the secret referenced by the TLS
+	// section of each host serving the default certificate
+	DefaultCertificateHosts map[string]string `json:"defaultCertificateHosts"`
+}
+
+// The values of CertificateLookup.Match
+const (
+	// MatchExact is a certificate of a server with the same hostname
+	MatchExact = "exact"
+	// MatchWildcard is a certificate of a server with a wildcard hostname
+	MatchWildcard = "wildcard"
+	// MatchRegex is a certificate of a server with a regular expression
+	// matching the hostname
+	MatchRegex = "regex"
+	// MatchDefault is the certificate of the default server
+	MatchDefault = "default"
+	// MatchFake is the fake certificate served when no server has a
+	// certificate for the hostname
+	MatchFake = "fake"
+)
+
+// CertificateLookup describes the SSL certificate served for a SNI
+type CertificateLookup struct {
+	// SNI is the server name requested, empty when the client does not
+	// support SNI
+	SNI string `json:"sni"`
+	// Hostname is the server name matching the SNI
+	Hostname string `json:"hostname,omitempty"`
+	// Match defines how the certificate was found
+	Match string `json:"match"`
+	// Dynamic is true when the certificate is served by Lua
+	Dynamic bool `json:"dynamic"`
+	// Secret is the key of the secret of the certificate, empty for the
+	// fake certificate
+	Secret string `json:"secret,omitempty"`
+	// CommonNames contains the hostnames of the certificate
+	CommonNames []string `json:"commonNames,omitempty"`
+	// Expires is the expiration time of the certificate
+	Expires time.Time `json:"expires"`
+	// SerialNumber is the serial number of the certificate in hexadecimal
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// ValidForSNI is true when the certificate is valid for the SNI
+	ValidForSNI bool `json:"validForSNI"`
+}
+
+// CertificateReporter reports the usage of the SSL certificates
+type CertificateReporter interface {
+	// CertificateReport returns the usage of the SSL certificates synced
+	// to disk, an error when the configuration is not synchronized yet
+	CertificateReport() (*CertificateReport, error)
+	// LookupCertificate returns the SSL certificate served for the SNI by
+	// the running configuration, an error when the configuration is not
+	// synchronized yet
+	LookupCertificate(sni string) (*CertificateLookup, error)
+}
+
+// Server exposes the debug API to the tools running in the controller Pod
+type Server struct {
+	HostDebugger        HostDebugger
+	CertificateReporter CertificateReporter
+	// Profiles is nil when the continuous profiling is disabled
+	Profiles *profiling.Recorder
+}
+
+// NewServer creates a new debug server
+func NewServer(hd HostDebugger, cr CertificateReporter, profiles *profiling.Recorder) *Server {
+	return &Server{
+		HostDebugger:        hd,
+		CertificateReporter: cr,
+		Profiles:            profiles,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case LogLevelsPath:
+		logging.Handler(w, r)
+	case HostsPath:
+		s.serveHosts(w, r)
+	case ProfilesPath:
+		s.serveProfiles(w, r)
+	case CertificatesPath:
+		s.serveCertificates(w, r)
+	case CertificateLookupPath:
+		s.serveCertificateLookup(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveHosts(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+			return
+		}
+
+		duration := DefaultHostDuration
+		if val := r.URL.Query().Get("duration"); val != "" {
+			var err error
+			duration, err = time.ParseDuration(val)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid duration %q", val), http.StatusBadRequest)
+				return
+			}
+		}
+
+		until, err := s.HostDebugger.EnableHostDebug(host, duration)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		klog.Infof("Enabled the NGINX debug logs of the host %v until %v", host, until.Format(time.RFC3339))
+	case http.MethodDelete:
+		if host == "" {
+			http.Error(w, "missing host", http.StatusBadRequest)
+			return
+		}
+
+		err := s.HostDebugger.DisableHostDebug(host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		klog.Infof("Disabled the NGINX debug logs of the host %v", host)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.HostDebugger.DebugHosts())
+}
+
+func (s *Server) serveProfiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.Profiles == nil {
+		http.Error(w, "the continuous profiling is disabled", http.StatusNotFound)
+		return
+	}
+
+	val := r.URL.Query().Get("id")
+	if val == "" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Profiles.List())
+		return
+	}
+
+	id, err := strconv.Atoi(val)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid profile %q", val), http.StatusBadRequest)
+		return
+	}
+
+	data, ok := s.Profiles.Get(id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("profile %v not found", id), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (s *Server) serveCertificates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, err := s.CertificateReporter.CertificateReport()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func (s *Server) serveCertificateLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lookup, err := s.CertificateReporter.LookupCertificate(r.URL.Query().Get("sni"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lookup)
+}
+
+// Listen announces on the unix socket of the debug server. Only the user
+// running the controller can connect to the socket.
+func Listen() (net.Listener, error) {
+	// remove the socket of a previous execution
+	os.Remove(Socket)
+
+	listener, err := net.Listen("unix", Socket)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(Socket, 0600)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// NewRequest creates a new request to the debug server
+func NewRequest(method, path string) (int, []byte, error) {
+	u := &httpunix.Transport{
+		DialTimeout:           1 * time.Second,
+		RequestTimeout:        requestTimeout,
+		ResponseHeaderTimeout: requestTimeout,
+	}
+	u.RegisterLocation(socketLocation, Socket)
+
+	req, err := http.NewRequest(method, fmt.Sprintf("http+unix://%v%v", socketLocation, path), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	res, err := (&http.Client{Transport: u}).Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return res.StatusCode, body, nil
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/profiling"
+)
+
+type fakeHostDebugger struct {
+	hosts    map[string]time.Time
+	duration time.Duration
+}
+
+func (f *fakeHostDebugger) EnableHostDebug(host string, duration time.Duration) (time.Time, error) {
+	if host != "example.com" {
+		return time.Time{}, fmt.Errorf("no server for the host %v", host)
+	}
+
+	f.duration = duration
+	f.hosts[host] = time.Now().Add(duration)
+	return f.hosts[host], nil
+}
+
+func (f *fakeHostDebugger) DisableHostDebug(host string) error {
+	if _, ok := f.hosts[host]; !ok {
+		return fmt.Errorf("the debug logs of the host %v are not enabled", host)
+	}
+
+	delete(f.hosts, host)
+	return nil
+}
+
+func (f *fakeHostDebugger) DebugHosts() map[string]time.Time {
+	return f.hosts
+}
+
+type fakeCertificateReporter struct {
+	report  *CertificateReport
+	lookups map[string]*CertificateLookup
+}
+
+func (f *fakeCertificateReporter) CertificateReport() (*CertificateReport, error) {
+	if f.report == nil {
+		return nil, fmt.Errorf("the configuration is not synchronized yet")
+	}
+
+	return f.report, nil
+}
+
+func (f *fakeCertificateReporter) LookupCertificate(sni string) (*CertificateLookup, error) {
+	if f.lookups == nil {
+		return nil, fmt.Errorf("the configuration is not synchronized yet")
+	}
+
+	return f.lookups[sni], nil
+}
+
+func TestServer(t *testing.T) {
+	hd := &fakeHostDebugger{hosts: map[string]time.Time{}}
+	server := NewServer(hd, &fakeCertificateReporter{}, nil)
+
+	testCases := []struct {
+		method   string
+		path     string
+		code     int
+		duration time.Duration
+		hosts    int
+	}{
+		{http.MethodGet, HostsPath, http.StatusOK, 0, 0},
+		{http.MethodPut, HostsPath + "?host=example.com", http.StatusOK, DefaultHostDuration, 1},
+		{http.MethodPut, HostsPath + "?host=example.com&duration=30m", http.StatusOK, 30 * time.Minute, 1},
+		{http.MethodPut, HostsPath + "?host=example.com&duration=foo", http.StatusBadRequest, 0, 1},
+		{http.MethodPut, HostsPath + "?host=foo.bar", http.StatusBadRequest, 0, 1},
+		{http.MethodPut, HostsPath, http.StatusBadRequest, 0, 1},
+		{http.MethodPost, HostsPath + "?host=example.com", http.StatusMethodNotAllowed, 0, 1},
+		{http.MethodDelete, HostsPath + "?host=foo.bar", http.StatusBadRequest, 0, 1},
+		{http.MethodDelete, HostsPath + "?host=example.com", http.StatusOK, 0, 0},
+		{http.MethodGet, LogLevelsPath, http.StatusOK, 0, 0},
+		{http.MethodGet, ProfilesPath, http.StatusNotFound, 0, 0},
+		{http.MethodGet, CertificatesPath, http.StatusServiceUnavailable, 0, 0},
+		{http.MethodGet, "/debug/foo", http.StatusNotFound, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		hd.duration = 0
+
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+		if w.Code != tc.code {
+			t.Errorf("%v %v: expected code %v but returned %v", tc.method, tc.path, tc.code, w.Code)
+		}
+		if hd.duration != tc.duration {
+			t.Errorf("%v %v: expected the duration %v but returned %v", tc.method, tc.path, tc.duration, hd.duration)
+		}
+		if len(hd.hosts) != tc.hosts {
+			t.Errorf("%v %v: expected %v hosts but returned %v", tc.method, tc.path, tc.hosts, len(hd.hosts))
+		}
+	}
+
+	hd.hosts["example.com"] = time.Now()
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, HostsPath, nil))
+
+	hosts := map[string]time.Time{}
+	err := json.Unmarshal(w.Body.Bytes(), &hosts)
+	if err != nil {
+		t.Fatalf("unexpected error decoding the hosts: %v", err)
+	}
+	if _, ok := hosts["example.com"]; !ok || len(hosts) != 1 {
+		t.Errorf("unexpected hosts %+v", hosts)
+	}
+}
+
+func TestServerProfiles(t *testing.T) {
+	profiles := profiling.NewRecorder(profiling.Config{Size: 1})
+	server := NewServer(&fakeHostDebugger{hosts: map[string]time.Time{}}, nil, profiles)
+
+	testCases := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodGet, ProfilesPath, http.StatusOK},
+		{http.MethodGet, ProfilesPath + "?id=1", http.StatusNotFound},
+		{http.MethodGet, ProfilesPath + "?id=foo", http.StatusBadRequest},
+		{http.MethodDelete, ProfilesPath, http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range testCases {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+		if w.Code != tc.code {
+			t.Errorf("%v %v: expected code %v but returned %v", tc.method, tc.path, tc.code, w.Code)
+		}
+	}
+}
+
+func TestServerCertificates(t *testing.T) {
+	cr := &fakeCertificateReporter{report: &CertificateReport{
+		UnreferencedSecrets:     []string{"default/old-tls"},
+		SoonOrphanedSecrets:     []string{},
+		DefaultCertificateHosts: map[string]string{"foo.bar": "default/foo-tls"},
+	}}
+	server := NewServer(&fakeHostDebugger{hosts: map[string]time.Time{}}, cr, nil)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, CertificatesPath, nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected code %v but returned %v", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, CertificatesPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected code %v but returned %v", http.StatusOK, w.Code)
+	}
+
+	report := &CertificateReport{}
+	err := json.Unmarshal(w.Body.Bytes(), report)
+	if err != nil {
+		t.Fatalf("unexpected error decoding the report: %v", err)
+	}
+	if len(report.UnreferencedSecrets) != 1 || report.DefaultCertificateHosts["foo.bar"] != "default/foo-tls" {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestServerCertificateLookup(t *testing.T) {
+	cr := &fakeCertificateReporter{}
+	server := NewServer(&fakeHostDebugger{hosts: map[string]time.Time{}}, cr, nil)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, CertificateLookupPath+"?sni=foo.bar", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected code %v but returned %v", http.StatusServiceUnavailable, w.Code)
+	}
+
+	cr.lookups = map[string]*CertificateLookup{
+		"foo.bar": {SNI: "foo.bar", Hostname: "*.bar", Match: MatchWildcard, Secret: "default/bar-tls", ValidForSNI: true},
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodPut, CertificateLookupPath+"?sni=foo.bar", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected code %v but returned %v", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, CertificateLookupPath+"?sni=foo.bar", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected code %v but returned %v", http.StatusOK, w.Code)
+	}
+
+	lookup := &CertificateLookup{}
+	err := json.Unmarshal(w.Body.Bytes(), lookup)
+	if err != nil {
+		t.Fatalf("unexpected error decoding the lookup: %v", err)
+	}
+	if lookup.Match != MatchWildcard || lookup.Secret != "default/bar-tls" || !lookup.ValidForSNI {
+		t.Errorf("unexpected lookup %+v", lookup)
+	}
+}
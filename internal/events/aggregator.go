@@ -0,0 +1,175 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// the repeated warnings are sent in a summary at this interval
+const aggregationInterval = time.Minute
+
+// Aggregator is an event recorder sending the first warning with a reason
+// about an object immediately, and the repeated ones in a periodic summary
+// with their count. The event correlator of client-go throttles the events
+// of each object, so a storm of identical warnings would otherwise hide
+// the new errors of the same object.
+type Aggregator struct {
+	recorder record.EventRecorder
+	interval time.Duration
+
+	lock     sync.Mutex
+	warnings map[key]*warning
+	timer    *time.Timer
+
+	now func() time.Time
+}
+
+type key struct {
+	kind      string
+	namespace string
+	name      string
+	reason    string
+}
+
+type warning struct {
+	// the last object and message, used in the summary
+	object  runtime.Object
+	message string
+	// number of warnings received since the last event sent
+	count int
+	// when the last event was sent
+	sent time.Time
+}
+
+// NewAggregator returns a recorder aggregating the repeated warnings sent
+// to the recorder
+func NewAggregator(recorder record.EventRecorder) *Aggregator {
+	return &Aggregator{
+		recorder: recorder,
+		interval: aggregationInterval,
+		warnings: map[key]*warning{},
+		now:      time.Now,
+	}
+}
+
+// Event implements the record.EventRecorder interface
+func (a *Aggregator) Event(object runtime.Object, eventtype, reason, message string) {
+	if eventtype != apiv1.EventTypeWarning {
+		a.recorder.Event(object, eventtype, reason, message)
+		return
+	}
+
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		a.recorder.Event(object, eventtype, reason, message)
+		return
+	}
+
+	k := key{
+		kind:      fmt.Sprintf("%T", object),
+		namespace: accessor.GetNamespace(),
+		name:      accessor.GetName(),
+		reason:    reason,
+	}
+
+	a.lock.Lock()
+
+	now := a.now()
+	w, ok := a.warnings[k]
+	if ok && (w.count > 0 || now.Sub(w.sent) < a.interval) {
+		w.object, w.message = object, message
+		w.count++
+		a.lock.Unlock()
+		return
+	}
+
+	a.warnings[k] = &warning{sent: now}
+	if a.timer == nil {
+		a.timer = time.AfterFunc(a.interval, a.flush)
+	}
+
+	a.lock.Unlock()
+
+	a.recorder.Event(object, eventtype, reason, message)
+}
+
+// Eventf implements the record.EventRecorder interface
+func (a *Aggregator) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	a.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// PastEventf implements the record.EventRecorder interface, the events
+// are not aggregated
+func (a *Aggregator) PastEventf(object runtime.Object, timestamp metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+	a.recorder.PastEventf(object, timestamp, eventtype, reason, messageFmt, args...)
+}
+
+// AnnotatedEventf implements the record.EventRecorder interface, the
+// events are not aggregated
+func (a *Aggregator) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	a.recorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+}
+
+type summary struct {
+	object  runtime.Object
+	reason  string
+	message string
+}
+
+// flush sends the summaries of the repeated warnings and forgets the
+// warnings not repeated during the last interval
+func (a *Aggregator) flush() {
+	a.lock.Lock()
+
+	now := a.now()
+	var summaries []summary
+	for k, w := range a.warnings {
+		if w.count > 0 {
+			summaries = append(summaries, summary{
+				object:  w.object,
+				reason:  k.reason,
+				message: fmt.Sprintf("%v (repeated %d times in the last %v)", w.message, w.count, now.Sub(w.sent).Round(time.Second)),
+			})
+			w.object, w.message, w.count, w.sent = nil, "", 0, now
+			continue
+		}
+
+		if now.Sub(w.sent) >= a.interval {
+			delete(a.warnings, k)
+		}
+	}
+
+	a.timer = nil
+	if len(a.warnings) > 0 {
+		a.timer = time.AfterFunc(a.interval, a.flush)
+	}
+
+	a.lock.Unlock()
+
+	for _, s := range summaries {
+		a.recorder.Event(s.object, apiv1.EventTypeWarning, s.reason, s.message)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package events
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	extensions "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestAggregator(now *time.Time) (*Aggregator, *record.FakeRecorder) {
+	recorder := record.NewFakeRecorder(100)
+	a := NewAggregator(recorder)
+	// the summaries are sent by the tests
+	a.interval = time.Hour
+	a.now = func() time.Time { return *now }
+	return a, recorder
+}
+
+func received(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func expectEvents(t *testing.T, recorder *record.FakeRecorder, expected ...string) {
+	events := received(recorder)
+	if len(events) != len(expected) {
+		t.Fatalf("expected the events %v but got %v", expected, events)
+	}
+
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("expected the event %q but got %q", expected[i], events[i])
+		}
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	now := time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)
+	a, recorder := newTestAggregator(&now)
+
+	ing := &extensions.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"}}
+	other := &extensions.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"}}
+
+	a.Event(ing, apiv1.EventTypeWarning, "InvalidAnnotation", "invalid rewrite-target")
+	a.Eventf(ing, apiv1.EventTypeWarning, "InvalidAnnotation", "invalid %v", "rewrite-target")
+	a.Event(ing, apiv1.EventTypeWarning, "InvalidAnnotation", "invalid ssl-redirect")
+	// novel errors are sent immediately
+	a.Event(ing, apiv1.EventTypeWarning, "AliasConflict", "alias used by default/api")
+	a.Event(other, apiv1.EventTypeWarning, "InvalidAnnotation", "invalid rewrite-target")
+	// the normal events are never aggregated
+	a.Event(ing, apiv1.EventTypeNormal, "UPDATE", "Ingress default/app")
+	a.Event(ing, apiv1.EventTypeNormal, "UPDATE", "Ingress default/app")
+
+	expectEvents(t, recorder,
+		"Warning InvalidAnnotation invalid rewrite-target",
+		"Warning AliasConflict alias used by default/api",
+		"Warning InvalidAnnotation invalid rewrite-target",
+		"Normal UPDATE Ingress default/app",
+		"Normal UPDATE Ingress default/app",
+	)
+
+	now = now.Add(time.Minute)
+	a.flush()
+	expectEvents(t, recorder, "Warning InvalidAnnotation invalid ssl-redirect (repeated 2 times in the last 1m0s)")
+
+	// the warnings of an aggregated storm stay aggregated
+	now = now.Add(10 * time.Minute)
+	a.Event(ing, apiv1.EventTypeWarning, "InvalidAnnotation", "invalid ssl-redirect")
+	expectEvents(t, recorder)
+
+	now = now.Add(time.Minute)
+	a.flush()
+	expectEvents(t, recorder, "Warning InvalidAnnotation invalid ssl-redirect (repeated 1 times in the last 11m0s)")
+
+	// and are sent immediately after a quiet interval
+	now = now.Add(2 * time.Hour)
+	a.flush()
+	expectEvents(t, recorder)
+
+	if len(a.warnings) != 0 {
+		t.Errorf("expected the quiet warnings to be forgotten but got %v", a.warnings)
+	}
+
+	a.Event(ing, apiv1.EventTypeWarning, "InvalidAnnotation", "invalid rewrite-target")
+	expectEvents(t, recorder, "Warning InvalidAnnotation invalid rewrite-target")
+}
+
+func TestAggregatorTimer(t *testing.T) {
+	recorder := record.NewFakeRecorder(100)
+	a := NewAggregator(recorder)
+	a.interval = 10 * time.Millisecond
+
+	ing := &extensions.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"}}
+	a.Event(ing, apiv1.EventTypeWarning, "InvalidAnnotation", "invalid rewrite-target")
+	a.Event(ing, apiv1.EventTypeWarning, "InvalidAnnotation", "invalid rewrite-target")
+
+	<-recorder.Events
+	select {
+	case event := <-recorder.Events:
+		if event[:len("Warning InvalidAnnotation invalid rewrite-target (repeated 1 times")] != "Warning InvalidAnnotation invalid rewrite-target (repeated 1 times" {
+			t.Errorf("unexpected summary %q", event)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no summary sent")
+	}
+}
@@ -26,11 +26,21 @@ const (
 	// The name of each file is <namespace>-<secret name>.pem. The content is the concatenated
 	// certificate and key.
 	DefaultSSLDirectory = "/etc/ingress-controller/ssl"
+
+	// HistoryDirectory defines the location where the last applied NGINX
+	// configurations are stored to allow rollbacks
+	HistoryDirectory = "/etc/ingress-controller/history"
+
+	// AuditDirectory defines the location of the audit trail of the
+	// configuration changes
+	AuditDirectory = "/etc/ingress-controller/audit"
 )
 
 var (
 	directories = []string{
 		DefaultSSLDirectory,
 		AuthDirectory,
+		HistoryDirectory,
+		AuditDirectory,
 	}
 )
@@ -0,0 +1,35 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package file
+
+import (
+	"syscall"
+)
+
+// magic number of the tmpfs filesystems, see statfs(2)
+const tmpfsMagic = 0x01021994
+
+// IsTmpfs returns true when the directory is in a tmpfs mount, so its files
+// are only kept in memory
+func IsTmpfs(directory string) (bool, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(directory, &stat); err != nil {
+		return false, err
+	}
+
+	return int64(stat.Type) == tmpfsMagic, nil
+}
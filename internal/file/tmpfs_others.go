@@ -0,0 +1,29 @@
+// +build !linux
+
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package file
+
+import (
+	"fmt"
+)
+
+// IsTmpfs returns true when the directory is in a tmpfs mount, so its files
+// are only kept in memory
+func IsTmpfs(directory string) (bool, error) {
+	return false, fmt.Errorf("tmpfs mounts are only detected on Linux")
+}
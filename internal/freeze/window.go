@@ -0,0 +1,226 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package freeze
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxDuration defines the maximum duration of a freeze window
+const MaxDuration = 7 * 24 * time.Hour
+
+// field defines the allowed values of a field of a schedule
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Window defines a period of time, starting at the times matched by a
+// cron schedule, during which the configuration changes are frozen
+type Window struct {
+	// Duration of the window after each start time
+	Duration time.Duration
+
+	spec string
+
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are true if the day of the month or the day of
+	// the week is *, following the cron rules to match days
+	domAny, dowAny bool
+}
+
+// ParseWindows parses a list of windows separated by semicolons. Each
+// window contains the five fields of a cron schedule (minute, hour, day
+// of month, month and day of week) followed by its duration, i.e.
+// "0 18 * * 5 6h" starts a window of six hours at 18:00 UTC on Fridays.
+func ParseWindows(spec string) ([]Window, error) {
+	windows := []Window{}
+	for _, s := range strings.Split(spec, ";") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+
+		w, err := ParseWindow(s)
+		if err != nil {
+			return nil, err
+		}
+
+		windows = append(windows, *w)
+	}
+
+	return windows, nil
+}
+
+// ParseWindow parses a single window, see ParseWindows
+func ParseWindow(spec string) (*Window, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields)+1 {
+		return nil, fmt.Errorf("invalid freeze window %q: expected the five fields of a cron schedule and a duration", spec)
+	}
+
+	w := &Window{
+		spec: strings.Join(parts, " "),
+	}
+
+	values := []*uint64{&w.minute, &w.hour, &w.dom, &w.month, &w.dow}
+	for i, f := range fields {
+		bits, err := parseField(parts[i], f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid freeze window %q: %v", spec, err)
+		}
+
+		*values[i] = bits
+	}
+
+	// 7 is also Sunday
+	if w.dow&(1<<7) != 0 {
+		w.dow |= 1
+	}
+
+	w.domAny = parts[2] == "*"
+	w.dowAny = parts[4] == "*"
+
+	d, err := time.ParseDuration(parts[len(fields)])
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration in freeze window %q: %v", spec, err)
+	}
+
+	if d < time.Minute || d > MaxDuration {
+		return nil, fmt.Errorf("invalid duration in freeze window %q: must be between 1m and %v", spec, MaxDuration)
+	}
+
+	w.Duration = d
+
+	return w, nil
+}
+
+// parseField returns the values of a comma separated list of values,
+// ranges (a-b) and steps (*/n or a-b/n) of a schedule field as a bit set
+func parseField(val string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(val, ",") {
+		expr, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			s, err := strconv.Atoi(item[i+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in %v %q", f.name, item)
+			}
+
+			expr, step = item[:i], s
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case expr == "*":
+		case strings.Contains(expr, "-"):
+			bounds := strings.SplitN(expr, "-", 2)
+			var err error
+			start, err = parseValue(bounds[0], f)
+			if err != nil {
+				return 0, err
+			}
+			end, err = parseValue(bounds[1], f)
+			if err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range in %v %q", f.name, item)
+			}
+		default:
+			v, err := parseValue(expr, f)
+			if err != nil {
+				return 0, err
+			}
+
+			start = v
+			if step == 1 {
+				end = v
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseValue(val string, f field) (int, error) {
+	v, err := strconv.Atoi(val)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %v %q, expected a value between %v and %v", f.name, val, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+// String returns the specification of the window
+func (w Window) String() string {
+	return w.spec
+}
+
+// matches returns true if the minute of t is a start time of the window
+func (w Window) matches(t time.Time) bool {
+	if w.minute&(1<<uint(t.Minute())) == 0 ||
+		w.hour&(1<<uint(t.Hour())) == 0 ||
+		w.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dom := w.dom&(1<<uint(t.Day())) != 0
+	dow := w.dow&(1<<uint(t.Weekday())) != 0
+	if w.domAny || w.dowAny {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// Active returns true if t is inside the window. Schedules are evaluated in UTC.
+func (w Window) Active(t time.Time) bool {
+	t = t.UTC()
+	for start := t.Truncate(time.Minute); t.Sub(start) < w.Duration; start = start.Add(-time.Minute) {
+		if w.matches(start) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Active returns the first window containing t or nil if there is none
+func Active(windows []Window, t time.Time) *Window {
+	for i := range windows {
+		if windows[i].Active(t) {
+			return &windows[i]
+		}
+	}
+
+	return nil
+}
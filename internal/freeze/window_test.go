@@ -0,0 +1,121 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package freeze
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindows(t *testing.T) {
+	testCases := []struct {
+		spec      string
+		windows   int
+		expectErr bool
+	}{
+		{"", 0, false},
+		{"0 18 * * 5 6h", 1, false},
+		{"0 18 * * 5 6h; 30 23 24 12 * 48h", 2, false},
+		{"*/15 8-18/2 1,15 1-12 1-5 15m;", 1, false},
+		{"0 18 * * 5", 0, true},
+		{"0 18 * * 5 6h 1h", 0, true},
+		{"60 18 * * 5 6h", 0, true},
+		{"0 24 * * 5 6h", 0, true},
+		{"0 18 0 * * 6h", 0, true},
+		{"0 18 * 13 * 6h", 0, true},
+		{"0 18 * * 8 6h", 0, true},
+		{"0 18-10 * * * 6h", 0, true},
+		{"*/0 18 * * * 6h", 0, true},
+		{"0 18 * * * 30s", 0, true},
+		{"0 18 * * * 169h", 0, true},
+		{"0 18 * * * tomorrow", 0, true},
+	}
+
+	for _, tc := range testCases {
+		windows, err := ParseWindows(tc.spec)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("expected an error parsing %q", tc.spec)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %v", tc.spec, err)
+			continue
+		}
+
+		if len(windows) != tc.windows {
+			t.Errorf("expected %v windows parsing %q but %v were returned", tc.windows, tc.spec, len(windows))
+		}
+	}
+}
+
+func TestWindowActive(t *testing.T) {
+	// 2019-11-29 is a Friday
+	date := func(day, hour, minute int) time.Time {
+		return time.Date(2019, time.November, day, hour, minute, 30, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		spec   string
+		t      time.Time
+		active bool
+	}{
+		{"0 18 * * 5 6h", date(29, 17, 59), false},
+		{"0 18 * * 5 6h", date(29, 18, 0), true},
+		{"0 18 * * 5 6h", date(29, 23, 59), true},
+		{"0 18 * * 5 6h", date(30, 0, 0), false},
+		{"0 18 * * 5 6h", date(28, 20, 0), false},
+		{"0 22 * * 7 4h", date(25, 1, 0), true},
+		{"0 9-17 * * 1-5 1h", date(29, 12, 10), true},
+		{"0 9-17 * * 1-5 1h", date(30, 12, 10), false},
+		// cron matches the day of month or the day of week when both are set
+		{"0 0 1 * 5 24h", date(29, 10, 0), true},
+		{"0 0 1 * 5 24h", date(28, 10, 0), false},
+		{"*/30 * * * * 10m", date(29, 10, 35), true},
+		{"*/30 * * * * 10m", date(29, 10, 45), false},
+	}
+
+	for _, tc := range testCases {
+		w, err := ParseWindow(tc.spec)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", tc.spec, err)
+		}
+
+		if w.Active(tc.t) != tc.active {
+			t.Errorf("expected window %q active=%v at %v", tc.spec, tc.active, tc.t)
+		}
+	}
+}
+
+func TestActive(t *testing.T) {
+	windows, err := ParseWindows("0 18 * * 5 6h; 0 6 * * * 1h")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Date(2019, time.November, 28, 6, 30, 0, 0, time.UTC)
+	if w := Active(windows, now); w == nil || w.String() != "0 6 * * * 1h" {
+		t.Errorf("expected the window \"0 6 * * * 1h\" to be active at %v but %v was returned", now, w)
+	}
+
+	now = time.Date(2019, time.November, 28, 8, 0, 0, 0, time.UTC)
+	if w := Active(windows, now); w != nil {
+		t.Errorf("expected no active window at %v but %v was returned", now, w)
+	}
+}
@@ -0,0 +1,304 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package geoip keeps the MaxMind GeoIP2 databases used by NGINX up to date
+package geoip
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/logging"
+)
+
+const (
+	// LicenseKey is the key of the secret containing the MaxMind license key
+	LicenseKey = "license-key"
+
+	downloadTimeout = 5 * time.Minute
+
+	// maxDatabaseSize is the maximum size of an extracted database
+	maxDatabaseSize = 512 * 1024 * 1024
+)
+
+// downloadURL is the MaxMind endpoint the databases are downloaded from
+var downloadURL = "https://download.maxmind.com/app/geoip_download"
+
+// Database describes a database downloaded by the updater
+type Database struct {
+	// Edition is the MaxMind edition ID, e.g. GeoLite2-City
+	Edition string
+	// BuildDate is the date MaxMind built the database at
+	BuildDate time.Time
+	// Checksum is the SHA256 checksum of the archive of the database
+	Checksum string
+}
+
+// Updater downloads the MaxMind databases with the license key of a secret
+// and replaces the databases of a directory when their content changed
+type Updater struct {
+	client kubernetes.Interface
+
+	secretNamespace string
+	secretName      string
+
+	editions  []string
+	directory string
+
+	httpClient *http.Client
+
+	lock      sync.RWMutex
+	databases map[string]Database
+}
+
+// NewUpdater returns an updater reading the license key in the secret
+// (namespace/name) and writing the databases of the editions in directory
+func NewUpdater(client kubernetes.Interface, secret string, editions []string, directory string) (*Updater, error) {
+	namespace, name, err := k8s.ParseNameNS(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(editions) == 0 {
+		return nil, fmt.Errorf("no GeoIP2 edition to download")
+	}
+
+	return &Updater{
+		client:          client,
+		secretNamespace: namespace,
+		secretName:      name,
+		editions:        editions,
+		directory:       directory,
+		httpClient:      &http.Client{Timeout: downloadTimeout},
+		databases:       make(map[string]Database),
+	}, nil
+}
+
+// Databases returns the databases downloaded, sorted by edition
+func (u *Updater) Databases() []Database {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	databases := []Database{}
+	for _, db := range u.databases {
+		databases = append(databases, db)
+	}
+
+	sort.Slice(databases, func(i, j int) bool {
+		return databases[i].Edition < databases[j].Edition
+	})
+
+	return databases
+}
+
+// Sync downloads the editions published since the last synchronization.
+// Returns true when the content of a database of the directory changed.
+func (u *Updater) Sync() (bool, error) {
+	secret, err := u.client.CoreV1().Secrets(u.secretNamespace).Get(u.secretName, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("error reading the MaxMind license key: %v", err)
+	}
+
+	licenseKey := strings.TrimSpace(string(secret.Data[LicenseKey]))
+	if licenseKey == "" {
+		return false, fmt.Errorf("the secret %v/%v does not contain the key %v", u.secretNamespace, u.secretName, LicenseKey)
+	}
+
+	changed := false
+	errs := []string{}
+	for _, edition := range u.editions {
+		updated, err := u.update(edition, licenseKey)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", edition, err))
+			continue
+		}
+
+		changed = changed || updated
+	}
+
+	if len(errs) > 0 {
+		return changed, fmt.Errorf("%v", strings.Join(errs, ", "))
+	}
+
+	return changed, nil
+}
+
+// update downloads an edition when the checksum published by MaxMind
+// differs from the one of the last download and replaces the database
+// when its content changed
+func (u *Updater) update(edition, licenseKey string) (bool, error) {
+	data, err := u.download(edition, licenseKey, "tar.gz.sha256")
+	if err != nil {
+		return false, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return false, fmt.Errorf("empty checksum")
+	}
+	checksum := strings.ToLower(fields[0])
+
+	u.lock.RLock()
+	current := u.databases[edition]
+	u.lock.RUnlock()
+
+	if current.Checksum == checksum {
+		logging.V(3).Infof("GeoIP2 database %v is up to date", edition)
+		return false, nil
+	}
+
+	archive, err := u.download(edition, licenseKey, "tar.gz")
+	if err != nil {
+		return false, err
+	}
+
+	sum := sha256.Sum256(archive)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return false, fmt.Errorf("the checksum of the archive does not match %v", checksum)
+	}
+
+	content, buildDate, err := extract(archive, edition)
+	if err != nil {
+		return false, err
+	}
+
+	name := filepath.Join(u.directory, edition+".mmdb")
+	changed := false
+
+	old, err := ioutil.ReadFile(name)
+	if err != nil || !bytes.Equal(old, content) {
+		err = replaceFile(name, content)
+		if err != nil {
+			return false, err
+		}
+
+		logging.V(2).Infof("GeoIP2 database %v updated (build date %v)", edition, buildDate.Format("2006-01-02"))
+		changed = true
+	}
+
+	u.lock.Lock()
+	u.databases[edition] = Database{
+		Edition:   edition,
+		BuildDate: buildDate,
+		Checksum:  checksum,
+	}
+	u.lock.Unlock()
+
+	return changed, nil
+}
+
+func (u *Updater) download(edition, licenseKey, suffix string) ([]byte, error) {
+	params := url.Values{}
+	params.Set("edition_id", edition)
+	params.Set("license_key", licenseKey)
+	params.Set("suffix", suffix)
+
+	resp, err := u.httpClient.Get(downloadURL + "?" + params.Encode())
+	if err != nil {
+		// the URL contains the license key
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %v downloading the %v file", resp.StatusCode, suffix)
+	}
+
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxDatabaseSize))
+}
+
+// extract returns the database of an archive published by MaxMind and its
+// build date, read from the name of the directory <edition>_<YYYYMMDD>
+func extract(archive []byte, edition string) ([]byte, time.Time, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, time.Time{}, fmt.Errorf("the archive does not contain %v.mmdb", edition)
+		}
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg || path.Base(hdr.Name) != edition+".mmdb" {
+			continue
+		}
+
+		dir := path.Dir(hdr.Name)
+		buildDate, err := time.Parse("20060102", dir[strings.LastIndex(dir, "_")+1:])
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("unexpected directory %v in the archive", dir)
+		}
+
+		content, err := ioutil.ReadAll(io.LimitReader(tr, maxDatabaseSize))
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		return content, buildDate, nil
+	}
+}
+
+// replaceFile writes a temporary file renamed to name, so NGINX never
+// reads a partially written database
+func replaceFile(name string, content []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
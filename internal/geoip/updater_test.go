@@ -0,0 +1,211 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package geoip
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func buildArchive(t *testing.T, dir, edition string, content []byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	files := map[string][]byte{
+		dir + "/COPYRIGHT.txt":        []byte("copyright"),
+		dir + "/" + edition + ".mmdb": content,
+	}
+	for _, name := range []string{dir + "/COPYRIGHT.txt", dir + "/" + edition + ".mmdb"} {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		tw.Write(files[name])
+	}
+
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// maxmind serves the archives of the editions, counting the downloads
+type maxmind struct {
+	archives  map[string][]byte
+	downloads int
+	// checksum overrides the checksum of the archives when it is not empty
+	checksum string
+}
+
+func (m *maxmind) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("license_key") != "secret-key" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	archive, ok := m.archives[r.URL.Query().Get("edition_id")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.URL.Query().Get("suffix") {
+	case "tar.gz":
+		m.downloads++
+		w.Write(archive)
+	case "tar.gz.sha256":
+		checksum := m.checksum
+		if checksum == "" {
+			sum := sha256.Sum256(archive)
+			checksum = hex.EncodeToString(sum[:])
+		}
+		fmt.Fprintf(w, "%v  archive.tar.gz\n", checksum)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newTestUpdater(t *testing.T, m *maxmind, licenseKey string) (*Updater, string, func()) {
+	server := httptest.NewServer(m)
+	downloadURL = server.URL
+
+	dir, err := ioutil.TempDir("", "geoip")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	client := fake.NewSimpleClientset(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "maxmind", Namespace: "ingress-nginx"},
+		Data:       map[string][]byte{LicenseKey: []byte(licenseKey)},
+	})
+
+	u, err := NewUpdater(client, "ingress-nginx/maxmind", []string{"GeoLite2-ASN"}, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return u, dir, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUpdaterSync(t *testing.T) {
+	m := &maxmind{archives: map[string][]byte{
+		"GeoLite2-ASN": buildArchive(t, "GeoLite2-ASN_20191217", "GeoLite2-ASN", []byte("asn-v1")),
+	}}
+
+	u, dir, cleanup := newTestUpdater(t, m, "secret-key\n")
+	defer cleanup()
+
+	changed, err := u.Sync()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !changed {
+		t.Errorf("expected the database to be written")
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "GeoLite2-ASN.mmdb"))
+	if err != nil || string(content) != "asn-v1" {
+		t.Fatalf("expected the content of the database but returned %q (%v)", content, err)
+	}
+
+	dbs := u.Databases()
+	if len(dbs) != 1 || !dbs[0].BuildDate.Equal(time.Date(2019, 12, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected databases: %+v", dbs)
+	}
+
+	// the archive is not downloaded again while the checksum is the same
+	changed, err = u.Sync()
+	if err != nil || changed || m.downloads != 1 {
+		t.Errorf("expected the database to be up to date (changed %v, downloads %v, error %v)", changed, m.downloads, err)
+	}
+
+	// a new archive with the same database does not change the file
+	m.archives["GeoLite2-ASN"] = buildArchive(t, "GeoLite2-ASN_20191224", "GeoLite2-ASN", []byte("asn-v1"))
+	changed, err = u.Sync()
+	if err != nil || changed || m.downloads != 2 {
+		t.Errorf("expected the identical database to be ignored (changed %v, downloads %v, error %v)", changed, m.downloads, err)
+	}
+	if dbs := u.Databases(); !dbs[0].BuildDate.Equal(time.Date(2019, 12, 24, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the build date of the last archive but returned %v", dbs[0].BuildDate)
+	}
+
+	m.archives["GeoLite2-ASN"] = buildArchive(t, "GeoLite2-ASN_20191231", "GeoLite2-ASN", []byte("asn-v2"))
+	changed, err = u.Sync()
+	if err != nil || !changed {
+		t.Errorf("expected the database to be replaced (changed %v, error %v)", changed, err)
+	}
+
+	content, _ = ioutil.ReadFile(filepath.Join(dir, "GeoLite2-ASN.mmdb"))
+	if string(content) != "asn-v2" {
+		t.Errorf("expected the new content of the database but returned %q", content)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("expected the temporary files to be removed but the directory contains %v files", len(files))
+	}
+}
+
+func TestUpdaterSyncErrors(t *testing.T) {
+	m := &maxmind{
+		archives: map[string][]byte{
+			"GeoLite2-ASN": buildArchive(t, "GeoLite2-ASN_20191217", "GeoLite2-ASN", []byte("asn-v1")),
+		},
+		checksum: "0000",
+	}
+
+	u, dir, cleanup := newTestUpdater(t, m, "secret-key")
+	defer cleanup()
+
+	changed, err := u.Sync()
+	if err == nil || changed {
+		t.Errorf("expected an error with an invalid checksum")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "GeoLite2-ASN.mmdb")); !os.IsNotExist(err) {
+		t.Errorf("expected the database not to be written")
+	}
+
+	u, _, cleanup = newTestUpdater(t, m, "wrong-key")
+	defer cleanup()
+
+	_, err = u.Sync()
+	if err == nil {
+		t.Fatalf("expected an error with an invalid license key")
+	}
+	if bytes.Contains([]byte(err.Error()), []byte("wrong-key")) {
+		t.Errorf("expected the license key not to be part of the error: %v", err)
+	}
+}
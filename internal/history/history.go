@@ -0,0 +1,265 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/file"
+)
+
+const (
+	nginxConfFile     = "nginx.conf"
+	configurationFile = "configuration.json"
+	revisionFile      = "revision.json"
+)
+
+// Source references an object used to build a configuration
+type Source struct {
+	Kind            string `json:"kind"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// Revision describes a configuration successfully applied to NGINX
+type Revision struct {
+	// Revision is the sequence number of the configuration
+	Revision int `json:"revision"`
+	// AppliedAt is the time when the configuration was applied
+	AppliedAt time.Time `json:"appliedAt"`
+	// Checksum of the configuration
+	Checksum string `json:"checksum"`
+	// RollbackOf contains the revision applied again by a rollback
+	RollbackOf int `json:"rollbackOf,omitempty"`
+	// Sources contains the objects used to build the configuration
+	Sources []Source `json:"sources,omitempty"`
+}
+
+// Store keeps the last applied configurations, each one in a directory
+// named after its revision containing the rendered nginx.conf and the
+// configuration sent to the Lua modules
+type Store struct {
+	directory string
+	size      int
+
+	mu   sync.Mutex
+	last int
+}
+
+// NewStore creates a store keeping the last size configurations in the
+// directory. Revisions found in the directory are kept.
+func NewStore(directory string, size int) (*Store, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("the size of the configuration history must be greater than zero")
+	}
+
+	err := os.MkdirAll(directory, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		directory: directory,
+		size:      size,
+	}
+
+	revisions, err := s.revisions()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revisions) > 0 {
+		s.last = revisions[0]
+	}
+
+	return s, nil
+}
+
+// revisions returns the revisions present in the directory, newest first
+func (s *Store) revisions() ([]int, error) {
+	entries, err := ioutil.ReadDir(s.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []int{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		revision, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(revisions)))
+	return revisions, nil
+}
+
+// Save stores a new revision of the configuration and removes the oldest
+// revisions exceeding the size of the store
+func (s *Store) Save(rev Revision, nginxConf, configuration []byte) (*Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rev.Revision = s.last + 1
+	if rev.AppliedAt.IsZero() {
+		rev.AppliedAt = time.Now()
+	}
+
+	metadata, err := json.Marshal(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp, err := ioutil.TempDir(s.directory, ".revision-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	files := map[string][]byte{
+		nginxConfFile:     nginxConf,
+		configurationFile: configuration,
+		revisionFile:      metadata,
+	}
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(tmp, name), content, file.ReadWriteByUser)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = os.Rename(tmp, s.path(rev.Revision))
+	if err != nil {
+		return nil, err
+	}
+
+	s.last = rev.Revision
+
+	revisions, err := s.revisions()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := s.size; i < len(revisions); i++ {
+		err := os.RemoveAll(s.path(revisions[i]))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &rev, nil
+}
+
+// List returns the stored revisions, newest first
+func (s *Store) List() ([]Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revisions, err := s.revisions()
+	if err != nil {
+		return nil, err
+	}
+
+	list := []Revision{}
+	for _, revision := range revisions {
+		rev, err := s.readRevision(revision)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, *rev)
+	}
+
+	return list, nil
+}
+
+// Previous returns the revision applied before the last one
+func (s *Store) Previous() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revisions, err := s.revisions()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(revisions) < 2 {
+		return 0, fmt.Errorf("there is no previous revision of the configuration")
+	}
+
+	return revisions[1], nil
+}
+
+// Get returns a revision with its nginx.conf and configuration
+func (s *Store) Get(revision int) (*Revision, []byte, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rev, err := s.readRevision(revision)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	nginxConf, err := ioutil.ReadFile(filepath.Join(s.path(revision), nginxConfFile))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	configuration, err := ioutil.ReadFile(filepath.Join(s.path(revision), configurationFile))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return rev, nginxConf, configuration, nil
+}
+
+func (s *Store) readRevision(revision int) (*Revision, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.path(revision), revisionFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("revision %v of the configuration not found", revision)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rev := &Revision{}
+	err = json.Unmarshal(data, rev)
+	if err != nil {
+		return nil, fmt.Errorf("invalid revision %v of the configuration: %v", revision, err)
+	}
+
+	return rev, nil
+}
+
+func (s *Store) path(revision int) string {
+	return filepath.Join(s.directory, strconv.Itoa(revision))
+}
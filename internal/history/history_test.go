@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func newTestStore(t *testing.T, size int) (*Store, string) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporal directory: %v", err)
+	}
+
+	s, err := NewStore(dir, size)
+	if err != nil {
+		t.Fatalf("unexpected error creating the store: %v", err)
+	}
+
+	return s, dir
+}
+
+func TestNewStoreInvalidSize(t *testing.T) {
+	_, err := NewStore(os.TempDir(), 0)
+	if err == nil {
+		t.Errorf("expected an error creating a store without revisions")
+	}
+}
+
+func TestSaveAndGet(t *testing.T) {
+	s, dir := newTestStore(t, 3)
+	defer os.RemoveAll(dir)
+
+	sources := []Source{{Kind: "Ingress", Namespace: "default", Name: "foo", ResourceVersion: "10"}}
+	rev, err := s.Save(Revision{Checksum: "abc", Sources: sources}, []byte("nginx.conf"), []byte("{}"))
+	if err != nil {
+		t.Fatalf("unexpected error saving a revision: %v", err)
+	}
+	if rev.Revision != 1 {
+		t.Errorf("expected revision 1 but returned %v", rev.Revision)
+	}
+	if rev.AppliedAt.IsZero() {
+		t.Errorf("expected the time of the revision to be set")
+	}
+
+	stored, nginxConf, configuration, err := s.Get(1)
+	if err != nil {
+		t.Fatalf("unexpected error getting the revision: %v", err)
+	}
+	if stored.Checksum != "abc" || len(stored.Sources) != 1 || stored.Sources[0] != sources[0] {
+		t.Errorf("unexpected stored revision %+v", stored)
+	}
+	if string(nginxConf) != "nginx.conf" {
+		t.Errorf("unexpected nginx.conf %q", nginxConf)
+	}
+	if string(configuration) != "{}" {
+		t.Errorf("unexpected configuration %q", configuration)
+	}
+
+	_, _, _, err = s.Get(2)
+	if err == nil {
+		t.Errorf("expected an error getting a missing revision")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s, dir := newTestStore(t, 3)
+	defer os.RemoveAll(dir)
+
+	_, err := s.Previous()
+	if err == nil {
+		t.Errorf("expected an error without a previous revision")
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err := s.Save(Revision{Checksum: fmt.Sprintf("%v", i)}, []byte("nginx.conf"), []byte("{}"))
+		if err != nil {
+			t.Fatalf("unexpected error saving a revision: %v", err)
+		}
+	}
+
+	revisions, err := s.List()
+	if err != nil {
+		t.Fatalf("unexpected error listing the revisions: %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("expected 3 revisions but returned %v", len(revisions))
+	}
+	for i, expected := range []int{5, 4, 3} {
+		if revisions[i].Revision != expected {
+			t.Errorf("expected revision %v at position %v but returned %v", expected, i, revisions[i].Revision)
+		}
+	}
+
+	previous, err := s.Previous()
+	if err != nil {
+		t.Fatalf("unexpected error getting the previous revision: %v", err)
+	}
+	if previous != 4 {
+		t.Errorf("expected previous revision 4 but returned %v", previous)
+	}
+
+	// the sequence continues after a restart
+	s, err = NewStore(dir, 3)
+	if err != nil {
+		t.Fatalf("unexpected error creating the store: %v", err)
+	}
+
+	rev, err := s.Save(Revision{}, []byte("nginx.conf"), []byte("{}"))
+	if err != nil {
+		t.Fatalf("unexpected error saving a revision: %v", err)
+	}
+	if rev.Revision != 6 {
+		t.Errorf("expected revision 6 but returned %v", rev.Revision)
+	}
+}
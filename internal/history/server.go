@@ -0,0 +1,176 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tv42/httpunix"
+	"k8s.io/klog"
+)
+
+// Socket defines the location of the unix socket used by the controller to
+// serve the configuration history
+var Socket = "/tmp/ingress-controller-history.sock"
+
+const (
+	// RevisionsPath defines the location of the list of stored revisions
+	RevisionsPath = "/configuration/revisions"
+	// RollbackPath defines the location used to apply a stored revision again
+	RollbackPath = "/configuration/rollback"
+
+	socketLocation = "ingress-controller-history"
+	requestTimeout = 60 * time.Second
+)
+
+// Rollbacker applies a stored revision of the configuration again
+type Rollbacker interface {
+	// Rollback applies the revision, or the previous one if it is zero,
+	// and returns the new revision created by the rollback
+	Rollback(revision int) (*Revision, error)
+}
+
+// Server exposes the configuration history and the rollback of the
+// configuration to the tools running in the controller Pod
+type Server struct {
+	Store      *Store
+	Rollbacker Rollbacker
+}
+
+// NewServer creates a new configuration history server
+func NewServer(store *Store, r Rollbacker) *Server {
+	return &Server{
+		Store:      store,
+		Rollbacker: r,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case RevisionsPath:
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		revisions, err := s.Store.List()
+		if err != nil {
+			klog.Errorf("Unexpected error listing the configuration history: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, revisions)
+	case RollbackPath:
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		revision := 0
+		if val := r.URL.Query().Get("revision"); val != "" {
+			var err error
+			revision, err = strconv.Atoi(val)
+			if err != nil || revision < 1 {
+				http.Error(w, fmt.Sprintf("invalid revision %q", val), http.StatusBadRequest)
+				return
+			}
+		}
+
+		rev, err := s.Rollbacker.Rollback(revision)
+		if err != nil {
+			klog.Errorf("Unexpected error rolling back the configuration: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, rev)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Listen announces on the unix socket of the configuration history server.
+// Only the user running the controller can connect to the socket.
+func Listen() (net.Listener, error) {
+	// remove the socket of a previous execution
+	os.Remove(Socket)
+
+	listener, err := net.Listen("unix", Socket)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(Socket, 0600)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// NewGetRequest creates a new GET request to the configuration history server
+func NewGetRequest(path string) (int, []byte, error) {
+	return newRequest(http.MethodGet, path)
+}
+
+// NewPostRequest creates a new POST request to the configuration history server
+func NewPostRequest(path string) (int, []byte, error) {
+	return newRequest(http.MethodPost, path)
+}
+
+func newRequest(method, path string) (int, []byte, error) {
+	u := &httpunix.Transport{
+		DialTimeout:           1 * time.Second,
+		RequestTimeout:        requestTimeout,
+		ResponseHeaderTimeout: requestTimeout,
+	}
+	u.RegisterLocation(socketLocation, Socket)
+
+	req, err := http.NewRequest(method, fmt.Sprintf("http+unix://%v%v", socketLocation, path), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	res, err := (&http.Client{Transport: u}).Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return res.StatusCode, body, nil
+}
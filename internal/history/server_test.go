@@ -0,0 +1,95 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type fakeRollbacker struct {
+	revision int
+}
+
+func (f *fakeRollbacker) Rollback(revision int) (*Revision, error) {
+	if revision > 10 {
+		return nil, fmt.Errorf("revision %v not found", revision)
+	}
+
+	f.revision = revision
+	return &Revision{Revision: 11, RollbackOf: revision}, nil
+}
+
+func TestServer(t *testing.T) {
+	s, dir := newTestStore(t, 3)
+	defer os.RemoveAll(dir)
+
+	_, err := s.Save(Revision{Checksum: "abc"}, []byte("nginx.conf"), []byte("{}"))
+	if err != nil {
+		t.Fatalf("unexpected error saving a revision: %v", err)
+	}
+
+	r := &fakeRollbacker{revision: -1}
+	server := NewServer(s, r)
+
+	testCases := []struct {
+		method   string
+		path     string
+		code     int
+		revision int
+	}{
+		{http.MethodGet, RevisionsPath, http.StatusOK, -1},
+		{http.MethodPost, RevisionsPath, http.StatusMethodNotAllowed, -1},
+		{http.MethodGet, RollbackPath, http.StatusMethodNotAllowed, -1},
+		{http.MethodPost, RollbackPath, http.StatusCreated, 0},
+		{http.MethodPost, RollbackPath + "?revision=3", http.StatusCreated, 3},
+		{http.MethodPost, RollbackPath + "?revision=foo", http.StatusBadRequest, -1},
+		{http.MethodPost, RollbackPath + "?revision=0", http.StatusBadRequest, -1},
+		{http.MethodPost, RollbackPath + "?revision=20", http.StatusInternalServerError, -1},
+		{http.MethodGet, "/configuration/foo", http.StatusNotFound, -1},
+	}
+
+	for _, tc := range testCases {
+		r.revision = -1
+
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+		if w.Code != tc.code {
+			t.Errorf("%v %v: expected code %v but returned %v", tc.method, tc.path, tc.code, w.Code)
+		}
+		if r.revision != tc.revision {
+			t.Errorf("%v %v: expected rollback of revision %v but returned %v", tc.method, tc.path, tc.revision, r.revision)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, RevisionsPath, nil))
+
+	revisions := []Revision{}
+	err = json.Unmarshal(w.Body.Bytes(), &revisions)
+	if err != nil {
+		t.Fatalf("unexpected error decoding the revisions: %v", err)
+	}
+	if len(revisions) != 1 || revisions[0].Checksum != "abc" {
+		t.Errorf("unexpected revisions %+v", revisions)
+	}
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package alias
 
 import (
+	"strings"
+
 	networking "k8s.io/api/networking/v1beta1"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
@@ -33,7 +35,17 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 }
 
 // Parse parses the annotations contained in the ingress rule
-// used to add an alias to the provided hosts
+// used to add an alias to the provided hosts. Aliases can be separated
+// by commas or spaces and may contain wildcards (i.e. *.example.com)
 func (a alias) Parse(ing *networking.Ingress) (interface{}, error) {
-	return parser.GetStringAnnotation("server-alias", ing)
+	val, err := parser.GetStringAnnotation("server-alias", ing)
+	if err != nil {
+		return "", err
+	}
+
+	aliases := strings.FieldsFunc(val, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+
+	return strings.Join(aliases, " "), nil
 }
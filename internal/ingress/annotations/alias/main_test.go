@@ -40,6 +40,8 @@ func TestParse(t *testing.T) {
 	}{
 		{map[string]string{annotation: "www.example.com"}, "www.example.com"},
 		{map[string]string{annotation: "*.example.com www.example.*"}, "*.example.com www.example.*"},
+		{map[string]string{annotation: "foo.example.com, bar.example.com"}, "foo.example.com bar.example.com"},
+		{map[string]string{annotation: " foo.example.com  bar.example.com,"}, "foo.example.com bar.example.com"},
 		{map[string]string{annotation: `~^www\d+\.example\.com$`}, `~^www\d+\.example\.com$`},
 		{map[string]string{annotation: ""}, ""},
 		{map[string]string{}, ""},
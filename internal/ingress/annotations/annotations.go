@@ -17,10 +17,16 @@ limitations under the License.
 package annotations
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
+
 	"github.com/imdario/mergo"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/canary"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/canonicalpath"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/modsecurity"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sslcipher"
+	"k8s.io/ingress-nginx/internal/logging"
 	"k8s.io/klog"
 
 	apiv1 "k8s.io/api/core/v1"
@@ -29,24 +35,36 @@ import (
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/alias"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/auth"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authexclude"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreqglobal"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/backendprotocol"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/bodystreaming"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/bodytransform"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/botchallenge"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/clientbodybuffersize"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/connection"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/connectionlimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/cors"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/customhttperrors"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/defaultbackend"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/endpointcondition"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/failover"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/hostregex"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/http2pushpreload"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/influxdb"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/internalonly"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ipwhitelist"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/loadbalancing"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/luarestywaf"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/plainhttp"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/podrouting"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/portinredirect"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxychain"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/redirect"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
@@ -57,7 +75,12 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sessionaffinity"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/snippet"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/sslpassthrough"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sslpin"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/timewindow"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/tlsfingerprint"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/trafficcapture"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamhashby"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamhost"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamvhost"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/xforwardedprefix"
 	"k8s.io/ingress-nginx/internal/ingress/errors"
@@ -72,8 +95,12 @@ type Ingress struct {
 	metav1.ObjectMeta
 	BackendProtocol      string
 	Alias                string
+	AuthExcludePaths     []string
 	BasicDigestAuth      auth.Config
+	BodyTransform        bodytransform.Config
+	BodyStreaming        bodystreaming.Config
 	Canary               canary.Config
+	CanonicalPath        canonicalpath.Config
 	CertificateAuth      authtls.Config
 	ClientBodyBufferSize string
 	ConfigurationSnippet string
@@ -83,10 +110,16 @@ type Ingress struct {
 	DefaultBackend       *apiv1.Service
 	//TODO: Change this back into an error when https://github.com/imdario/mergo/issues/100 is resolved
 	Denied             *string
+	EndpointCondition  string
 	ExternalAuth       authreq.Config
+	Failover           failover.Config
 	EnableGlobalAuth   bool
+	HostRegex          hostregex.Config
 	HTTP2PushPreload   bool
+	Internal           bool
+	PodRoutingBy       string
 	Proxy              proxy.Config
+	ProxyChain         proxychain.Config
 	RateLimit          ratelimit.Config
 	Redirect           redirect.Config
 	Rewrite            rewrite.Config
@@ -96,22 +129,37 @@ type Ingress struct {
 	ServiceUpstream    bool
 	SessionAffinity    sessionaffinity.Config
 	SSLPassthrough     bool
+	PlainHTTP          plainhttp.Config
 	UsePortInRedirects bool
 	UpstreamHashBy     upstreamhashby.Config
 	LoadBalancing      string
+	UpstreamHost       upstreamhost.Config
 	UpstreamVhost      string
 	Whitelist          ipwhitelist.SourceRange
 	XForwardedPrefix   string
 	SSLCiphers         string
+	SSLPins            sslpin.Config
+	MaxConnections     int
+	TrafficCapture     trafficcapture.Config
+	BotChallenge       botchallenge.Config
+	TLSFingerprint     tlsfingerprint.Config
+	TimeWindow         timewindow.Config
 	Logs               log.Config
 	LuaRestyWAF        luarestywaf.Config
 	InfluxDB           influxdb.Config
 	ModSecurity        modsecurity.Config
+	// InvalidAnnotations contains the annotations ignored because
+	// of an invalid value
+	InvalidAnnotations []parser.InvalidAnnotation
+	// UnknownAnnotations contains the annotations with the annotations
+	// prefix that are not read by any parser
+	UnknownAnnotations []string
 }
 
 // Extractor defines the annotation parsers to be used in the extraction of annotations
 type Extractor struct {
 	annotations map[string]parser.IngressAnnotation
+	resolver    resolver.Resolver
 }
 
 // NewAnnotationExtractor creates a new annotations extractor
@@ -119,8 +167,12 @@ func NewAnnotationExtractor(cfg resolver.Resolver) Extractor {
 	return Extractor{
 		map[string]parser.IngressAnnotation{
 			"Alias":                alias.NewParser(cfg),
+			"AuthExcludePaths":     authexclude.NewParser(cfg),
 			"BasicDigestAuth":      auth.NewParser(auth.AuthDirectory, cfg),
+			"BodyTransform":        bodytransform.NewParser(cfg),
+			"BodyStreaming":        bodystreaming.NewParser(cfg),
 			"Canary":               canary.NewParser(cfg),
+			"CanonicalPath":        canonicalpath.NewParser(cfg),
 			"CertificateAuth":      authtls.NewParser(cfg),
 			"ClientBodyBufferSize": clientbodybuffersize.NewParser(cfg),
 			"ConfigurationSnippet": snippet.NewParser(cfg),
@@ -128,10 +180,16 @@ func NewAnnotationExtractor(cfg resolver.Resolver) Extractor {
 			"CorsConfig":           cors.NewParser(cfg),
 			"CustomHTTPErrors":     customhttperrors.NewParser(cfg),
 			"DefaultBackend":       defaultbackend.NewParser(cfg),
+			"EndpointCondition":    endpointcondition.NewParser(cfg),
 			"ExternalAuth":         authreq.NewParser(cfg),
+			"Failover":             failover.NewParser(cfg),
 			"EnableGlobalAuth":     authreqglobal.NewParser(cfg),
+			"HostRegex":            hostregex.NewParser(cfg),
 			"HTTP2PushPreload":     http2pushpreload.NewParser(cfg),
+			"PlainHTTP":            plainhttp.NewParser(cfg),
+			"PodRoutingBy":         podrouting.NewParser(cfg),
 			"Proxy":                proxy.NewParser(cfg),
+			"ProxyChain":           proxychain.NewParser(cfg),
 			"RateLimit":            ratelimit.NewParser(cfg),
 			"Redirect":             redirect.NewParser(cfg),
 			"Rewrite":              rewrite.NewParser(cfg),
@@ -141,19 +199,28 @@ func NewAnnotationExtractor(cfg resolver.Resolver) Extractor {
 			"ServiceUpstream":      serviceupstream.NewParser(cfg),
 			"SessionAffinity":      sessionaffinity.NewParser(cfg),
 			"SSLPassthrough":       sslpassthrough.NewParser(cfg),
+			"Internal":             internalonly.NewParser(cfg),
 			"UsePortInRedirects":   portinredirect.NewParser(cfg),
 			"UpstreamHashBy":       upstreamhashby.NewParser(cfg),
 			"LoadBalancing":        loadbalancing.NewParser(cfg),
+			"UpstreamHost":         upstreamhost.NewParser(cfg),
 			"UpstreamVhost":        upstreamvhost.NewParser(cfg),
 			"Whitelist":            ipwhitelist.NewParser(cfg),
 			"XForwardedPrefix":     xforwardedprefix.NewParser(cfg),
 			"SSLCiphers":           sslcipher.NewParser(cfg),
+			"SSLPins":              sslpin.NewParser(cfg),
+			"MaxConnections":       connectionlimit.NewParser(cfg),
+			"TrafficCapture":       trafficcapture.NewParser(cfg),
+			"BotChallenge":         botchallenge.NewParser(cfg),
+			"TLSFingerprint":       tlsfingerprint.NewParser(cfg),
+			"TimeWindow":           timewindow.NewParser(cfg),
 			"Logs":                 log.NewParser(cfg),
 			"LuaRestyWAF":          luarestywaf.NewParser(cfg),
 			"InfluxDB":             influxdb.NewParser(cfg),
 			"BackendProtocol":      backendprotocol.NewParser(cfg),
 			"ModSecurity":          modsecurity.NewParser(cfg),
 		},
+		cfg,
 	}
 }
 
@@ -163,11 +230,16 @@ func (e Extractor) Extract(ing *networking.Ingress) *Ingress {
 		ObjectMeta: ing.ObjectMeta,
 	}
 
+	// track the invalid annotations read by the parsers in this extraction
+	parser.TrackInvalidAnnotations(ing)
+
 	data := make(map[string]interface{})
 	for name, annotationParser := range e.annotations {
 		val, err := annotationParser.Parse(ing)
-		klog.V(5).Infof("annotation %v in Ingress %v/%v: %v", name, ing.GetNamespace(), ing.GetName(), val)
+		logging.V(5).Infof("annotation %v in Ingress %v/%v: %v", name, ing.GetNamespace(), ing.GetName(), val)
 		if err != nil {
+			parser.RecordInvalidAnnotation(ing, err)
+
 			if errors.IsMissingAnnotations(err) {
 				continue
 			}
@@ -192,7 +264,7 @@ func (e Extractor) Extract(ing *networking.Ingress) *Ingress {
 				continue
 			}
 
-			klog.V(5).Infof("error reading %v annotation in Ingress %v/%v: %v", name, ing.GetNamespace(), ing.GetName(), err)
+			logging.V(5).Infof("error reading %v annotation in Ingress %v/%v: %v", name, ing.GetNamespace(), ing.GetName(), err)
 		}
 
 		if val != nil {
@@ -205,5 +277,32 @@ func (e Extractor) Extract(ing *networking.Ingress) *Ingress {
 		klog.Errorf("unexpected error merging extracted annotations: %v", err)
 	}
 
+	pia.UnknownAnnotations = UnknownAnnotations(ing)
+	pia.InvalidAnnotations = parser.InvalidAnnotations(ing)
+	for i := range pia.InvalidAnnotations {
+		pia.InvalidAnnotations[i].Default = e.defaultValue(pia.InvalidAnnotations[i].Name)
+	}
+
 	return pia
 }
+
+// defaultValue returns the value of the setting of the configuration
+// ConfigMap used by default for the annotation, or an empty string
+// if the annotation has no equivalent setting
+func (e Extractor) defaultValue(annotation string) string {
+	if e.resolver == nil {
+		return ""
+	}
+
+	name := strings.TrimPrefix(annotation, fmt.Sprintf("%v/", parser.AnnotationsPrefix))
+
+	def := reflect.ValueOf(e.resolver.GetDefaultBackend())
+	for i := 0; i < def.NumField(); i++ {
+		tag := strings.Split(def.Type().Field(i).Tag.Get("json"), ",")[0]
+		if tag == name {
+			return fmt.Sprintf("%v", def.Field(i).Interface())
+		}
+	}
+
+	return ""
+}
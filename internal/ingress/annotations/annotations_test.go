@@ -17,6 +17,7 @@ limitations under the License.
 package annotations
 
 import (
+	"reflect"
 	"testing"
 
 	apiv1 "k8s.io/api/core/v1"
@@ -301,6 +302,36 @@ func TestCustomHTTPErrors(t *testing.T) {
 	}
 }
 
+func TestInvalidAnnotations(t *testing.T) {
+	ec := NewAnnotationExtractor(mockCfg{})
+	ing := buildIngress()
+
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix("proxy-connect-timeout"): "abc",
+		parser.GetAnnotationWithPrefix("ssl-passthrough"):       "yes",
+		parser.GetAnnotationWithPrefix("pod-routing-by"):        "x-pod",
+		parser.GetAnnotationWithPrefix("proxy-read-timeout"):    "10",
+	})
+
+	expected := []parser.InvalidAnnotation{
+		{Name: parser.GetAnnotationWithPrefix("pod-routing-by"), Value: "x-pod", Default: ""},
+		{Name: parser.GetAnnotationWithPrefix("proxy-connect-timeout"), Value: "abc", Default: "0"},
+		{Name: parser.GetAnnotationWithPrefix("ssl-passthrough"), Value: "yes", Default: ""},
+	}
+
+	invalid := ec.Extract(ing).InvalidAnnotations
+	if !reflect.DeepEqual(invalid, expected) {
+		t.Errorf("expected %v but %v was returned", expected, invalid)
+	}
+
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix("proxy-read-timeout"): "10",
+	})
+	if invalid := ec.Extract(ing).InvalidAnnotations; len(invalid) != 0 {
+		t.Errorf("expected no invalid annotations but %v was returned", invalid)
+	}
+}
+
 /*
 func TestMergeLocationAnnotations(t *testing.T) {
 	// initial parameters
@@ -0,0 +1,65 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authexclude
+
+import (
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type authExclude struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new authentication exclusion annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return authExclude{r}
+}
+
+// Parse parses the annotations contained in the ingress to obtain
+// the list of paths that must not require authentication
+func (a authExclude) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("auth-exclude-paths", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, path := range strings.Split(val, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, " ;{}") {
+			return nil, ing_errors.NewInvalidAnnotationContent("auth-exclude-paths", val)
+		}
+
+		paths = append(paths, path)
+	}
+
+	if len(paths) == 0 {
+		return nil, ing_errors.NewInvalidAnnotationContent("auth-exclude-paths", val)
+	}
+
+	return paths, nil
+}
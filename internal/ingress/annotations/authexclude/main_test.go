@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authexclude
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	annotation := parser.GetAnnotationWithPrefix("auth-exclude-paths")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    []string
+		expectErr   bool
+	}{
+		{map[string]string{annotation: "/healthz"}, []string{"/healthz"}, false},
+		{map[string]string{annotation: "/healthz, /metrics,/webhooks/.*"}, []string{"/healthz", "/metrics", "/webhooks/.*"}, false},
+		{map[string]string{annotation: "healthz"}, nil, true},
+		{map[string]string{annotation: "/healthz;return 200"}, nil, true},
+		{map[string]string{annotation: ","}, nil, true},
+		{map[string]string{}, nil, true},
+		{nil, nil, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if testCase.expectErr {
+			if err == nil {
+				t.Errorf("expected an error but none returned, annotations: %s", testCase.annotations)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error %v, annotations: %s", err, testCase.annotations)
+		}
+
+		paths, ok := result.([]string)
+		if !ok {
+			t.Fatalf("expected a []string type")
+		}
+		if !reflect.DeepEqual(paths, testCase.expected) {
+			t.Errorf("expected %v but returned %v, annotations: %s", testCase.expected, paths, testCase.annotations)
+		}
+	}
+}
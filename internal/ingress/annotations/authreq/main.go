@@ -22,13 +22,12 @@ import (
 	"regexp"
 	"strings"
 
-	"k8s.io/klog"
-
 	networking "k8s.io/api/networking/v1beta1"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/logging"
 	"k8s.io/ingress-nginx/internal/sets"
 )
 
@@ -42,6 +41,20 @@ type Config struct {
 	ResponseHeaders []string `json:"responseHeaders,omitempty"`
 	RequestRedirect string   `json:"requestRedirect"`
 	AuthSnippet     string   `json:"authSnippet"`
+	// ResponseHeadersToClient adds the auth response headers to the
+	// response sent to the client
+	ResponseHeadersToClient bool `json:"responseHeadersToClient,omitempty"`
+	// ForwardHeaders restricts the client headers sent to the auth service.
+	// If empty all the client headers are forwarded.
+	ForwardHeaders []string `json:"forwardHeaders,omitempty"`
+	// RequestHeaders contains static headers added to the auth subrequest
+	RequestHeaders map[string]string `json:"requestHeaders,omitempty"`
+	// MaxBodySize enables forwarding the request body to the auth service
+	// up to the configured size
+	MaxBodySize string `json:"maxBodySize,omitempty"`
+	// CacheCookie is the name of the session cookie used as key to cache
+	// the responses of the auth service
+	CacheCookie string `json:"cacheCookie,omitempty"`
 }
 
 // Equal tests for equality between two Config types
@@ -76,6 +89,30 @@ func (e1 *Config) Equal(e2 *Config) bool {
 	if e1.AuthSnippet != e2.AuthSnippet {
 		return false
 	}
+	if e1.ResponseHeadersToClient != e2.ResponseHeadersToClient {
+		return false
+	}
+
+	match = sets.StringElementsMatch(e1.ForwardHeaders, e2.ForwardHeaders)
+	if !match {
+		return false
+	}
+
+	if len(e1.RequestHeaders) != len(e2.RequestHeaders) {
+		return false
+	}
+	for name, value := range e1.RequestHeaders {
+		if v, ok := e2.RequestHeaders[name]; !ok || v != value {
+			return false
+		}
+	}
+
+	if e1.MaxBodySize != e2.MaxBodySize {
+		return false
+	}
+	if e1.CacheCookie != e2.CacheCookie {
+		return false
+	}
 
 	return true
 }
@@ -83,6 +120,13 @@ func (e1 *Config) Equal(e2 *Config) bool {
 var (
 	methods      = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "CONNECT", "OPTIONS", "TRACE"}
 	headerRegexp = regexp.MustCompile(`^[a-zA-Z\d\-_]+$`)
+	// headerValueRegexp restricts static header values to characters
+	// that do not require escaping in the NGINX configuration
+	headerValueRegexp = regexp.MustCompile(`^[a-zA-Z\d\-_\.:/=+@ ]+$`)
+	bodySizeRegexp    = regexp.MustCompile(`^\d+[kKmM]?$`)
+	cookieNameRegexp  = regexp.MustCompile(`^[a-zA-Z\d\-_]+$`)
+	// responseHeaderRegexp matches <header>[*][:<new header>[*]]
+	responseHeaderRegexp = regexp.MustCompile(`^([a-zA-Z\d\-_]+)(\*?)(?::([a-zA-Z\d\-_]*)(\*?))?$`)
 )
 
 // ValidMethod checks is the provided string a valid HTTP method
@@ -104,6 +148,102 @@ func ValidHeader(header string) bool {
 	return headerRegexp.Match([]byte(header))
 }
 
+// ValidResponseHeader checks is the provided string a valid auth response
+// header definition. The definition can be a header name (X-Auth-User), a
+// header name followed by the name used in the upstream request
+// (X-Auth-User:X-User), a prefix matching several headers (X-Auth-*) or a
+// prefix followed by its replacement (X-Auth-*:X-* or X-Auth-*:* to strip it).
+func ValidResponseHeader(header string) bool {
+	_, _, _, err := ParseResponseHeader(header)
+	return err == nil
+}
+
+// ParseResponseHeader parses an auth response header definition returning
+// the header (or prefix) to copy, the name (or prefix) to use in the upstream
+// request and if the definition is a prefix
+func ParseResponseHeader(header string) (string, string, bool, error) {
+	m := responseHeaderRegexp.FindStringSubmatch(header)
+	if m == nil {
+		return "", "", false, fmt.Errorf("invalid response header %v", header)
+	}
+
+	from, isPrefix := m[1], m[2] == "*"
+	to, toPrefix := m[3], m[4] == "*"
+	hasTarget := strings.Contains(header, ":")
+
+	if !hasTarget {
+		return from, from, isPrefix, nil
+	}
+
+	if isPrefix != toPrefix {
+		return "", "", false, fmt.Errorf("invalid response header %v, prefixes can only be renamed to prefixes", header)
+	}
+
+	if !isPrefix && len(to) == 0 {
+		return "", "", false, fmt.Errorf("invalid response header %v, the new header name is empty", header)
+	}
+
+	return from, to, isPrefix, nil
+}
+
+// ValidBodySize checks is the provided string a valid NGINX size (i.e. 8k)
+func ValidBodySize(size string) bool {
+	return bodySizeRegexp.MatchString(size)
+}
+
+// ValidCookieName checks is the provided string a cookie name that can be
+// used in a NGINX variable
+func ValidCookieName(name string) bool {
+	return cookieNameRegexp.MatchString(name)
+}
+
+// ParseHeaderList parses a comma separated list of header names
+func ParseHeaderList(list string) ([]string, error) {
+	headers := []string{}
+	for _, header := range strings.Split(list, ",") {
+		header = strings.TrimSpace(header)
+		if len(header) == 0 {
+			continue
+		}
+		if !ValidHeader(header) {
+			return nil, fmt.Errorf("invalid header name %v", header)
+		}
+		headers = append(headers, header)
+	}
+
+	return headers, nil
+}
+
+// ParseRequestHeaders parses a comma separated list of static headers
+// with the format <name>:<value> (i.e. X-Service-Id: ingress)
+func ParseRequestHeaders(list string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, header := range strings.Split(list, ",") {
+		header = strings.TrimSpace(header)
+		if len(header) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid header %v, expected <name>:<value>", header)
+		}
+
+		name := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if !ValidHeader(name) {
+			return nil, fmt.Errorf("invalid header name %v", name)
+		}
+		if !headerValueRegexp.MatchString(value) {
+			return nil, fmt.Errorf("invalid value for header %v", name)
+		}
+
+		headers[name] = value
+	}
+
+	return headers, nil
+}
+
 type authReq struct {
 	r resolver.Resolver
 }
@@ -135,12 +275,12 @@ func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
 	// Optional Parameters
 	signIn, err := parser.GetStringAnnotation("auth-signin", ing)
 	if err != nil {
-		klog.V(3).Infof("auth-signin annotation is undefined and will not be set")
+		logging.V(3).Infof("auth-signin annotation is undefined and will not be set")
 	}
 
 	authSnippet, err := parser.GetStringAnnotation("auth-snippet", ing)
 	if err != nil {
-		klog.V(3).Infof("auth-snippet annotation is undefined and will not be set")
+		logging.V(3).Infof("auth-snippet annotation is undefined and will not be set")
 	}
 
 	responseHeaders := []string{}
@@ -150,7 +290,7 @@ func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
 		for _, header := range harr {
 			header = strings.TrimSpace(header)
 			if len(header) > 0 {
-				if !ValidHeader(header) {
+				if !ValidResponseHeader(header) {
 					return nil, ing_errors.NewLocationDenied("invalid headers list")
 				}
 				responseHeaders = append(responseHeaders, header)
@@ -158,16 +298,51 @@ func (a authReq) Parse(ing *networking.Ingress) (interface{}, error) {
 		}
 	}
 
+	responseHeadersToClient, _ := parser.GetBoolAnnotation("auth-response-headers-to-client", ing)
+
 	requestRedirect, _ := parser.GetStringAnnotation("auth-request-redirect", ing)
 
+	forwardHeaders := []string{}
+	fstr, _ := parser.GetStringAnnotation("auth-forward-headers", ing)
+	if len(fstr) != 0 {
+		forwardHeaders, err = ParseHeaderList(fstr)
+		if err != nil {
+			return nil, ing_errors.NewLocationDenied(err.Error())
+		}
+	}
+
+	requestHeaders := map[string]string{}
+	rstr, _ := parser.GetStringAnnotation("auth-request-headers", ing)
+	if len(rstr) != 0 {
+		requestHeaders, err = ParseRequestHeaders(rstr)
+		if err != nil {
+			return nil, ing_errors.NewLocationDenied(err.Error())
+		}
+	}
+
+	maxBodySize, _ := parser.GetStringAnnotation("auth-max-body-size", ing)
+	if len(maxBodySize) != 0 && !ValidBodySize(maxBodySize) {
+		return nil, ing_errors.NewLocationDenied("invalid body size")
+	}
+
+	cacheCookie, _ := parser.GetStringAnnotation("auth-cache-cookie", ing)
+	if len(cacheCookie) != 0 && !ValidCookieName(cacheCookie) {
+		return nil, ing_errors.NewLocationDenied("invalid cache cookie name")
+	}
+
 	return &Config{
-		URL:             urlString,
-		Host:            authURL.Hostname(),
-		SigninURL:       signIn,
-		Method:          authMethod,
-		ResponseHeaders: responseHeaders,
-		RequestRedirect: requestRedirect,
-		AuthSnippet:     authSnippet,
+		URL:                     urlString,
+		Host:                    authURL.Hostname(),
+		SigninURL:               signIn,
+		Method:                  authMethod,
+		ResponseHeaders:         responseHeaders,
+		RequestRedirect:         requestRedirect,
+		AuthSnippet:             authSnippet,
+		ResponseHeadersToClient: responseHeadersToClient,
+		ForwardHeaders:          forwardHeaders,
+		RequestHeaders:          requestHeaders,
+		MaxBodySize:             maxBodySize,
+		CacheCookie:             cacheCookie,
 	}, nil
 }
 
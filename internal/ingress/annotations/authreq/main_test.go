@@ -152,6 +152,13 @@ func TestHeaderAnnotations(t *testing.T) {
 		{"two headers and empty entries", "http://goog.url", ",1,,2,", []string{"1", "2"}, false},
 		{"header with spaces", "http://goog.url", "1 2", []string{}, true},
 		{"header with other bad symbols", "http://goog.url", "1+2", []string{}, true},
+		{"renamed header", "http://goog.url", "X-Auth-User:X-User", []string{"X-Auth-User:X-User"}, false},
+		{"header prefix", "http://goog.url", "X-Auth-*", []string{"X-Auth-*"}, false},
+		{"renamed header prefix", "http://goog.url", "X-Auth-*:X-*,X-Remote-*:*", []string{"X-Auth-*:X-*", "X-Remote-*:*"}, false},
+		{"header renamed to empty name", "http://goog.url", "X-Auth-User:", []string{}, true},
+		{"header renamed to prefix", "http://goog.url", "X-Auth-User:X-*", []string{}, true},
+		{"prefix renamed to header", "http://goog.url", "X-Auth-*:X-User", []string{}, true},
+		{"only wildcard", "http://goog.url", "*", []string{}, true},
 	}
 
 	for _, test := range tests {
@@ -180,6 +187,129 @@ func TestHeaderAnnotations(t *testing.T) {
 	}
 }
 
+func TestResponseHeadersToClientAnnotation(t *testing.T) {
+	ing := buildIngress()
+
+	for _, value := range []string{"true", "false", ""} {
+		data := map[string]string{}
+		data[parser.GetAnnotationWithPrefix("auth-url")] = "http://goog.url"
+		data[parser.GetAnnotationWithPrefix("auth-response-headers-to-client")] = value
+		ing.SetAnnotations(data)
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+
+		u := i.(*Config)
+		if u.ResponseHeadersToClient != (value == "true") {
+			t.Errorf("expected %v but %v was returned for value %q", value == "true", u.ResponseHeadersToClient, value)
+		}
+	}
+}
+
+func TestRequestEnrichmentAnnotations(t *testing.T) {
+	ing := buildIngress()
+
+	tests := []struct {
+		title          string
+		forwardHeaders string
+		requestHeaders string
+		maxBodySize    string
+		expForward     []string
+		expRequest     map[string]string
+		expErr         bool
+	}{
+		{"nothing", "", "", "", []string{}, map[string]string{}, false},
+		{"forward headers", "Authorization, Cookie", "", "", []string{"Authorization", "Cookie"}, map[string]string{}, false},
+		{"invalid forward header", "Author ization", "", "", nil, nil, true},
+		{"request headers", "", "X-Service-Id: ingress, X-Env:prod", "", []string{}, map[string]string{"X-Service-Id": "ingress", "X-Env": "prod"}, false},
+		{"request header without value", "", "X-Service-Id", "", nil, nil, true},
+		{"request header with invalid value", "", "X-Service-Id: a;b", "", nil, nil, true},
+		{"request header with variable", "", "X-Service-Id: $host", "", nil, nil, true},
+		{"max body size", "", "", "8k", []string{}, map[string]string{}, false},
+		{"invalid max body size", "", "", "8 kb", nil, nil, true},
+	}
+
+	for _, test := range tests {
+		data := map[string]string{}
+		data[parser.GetAnnotationWithPrefix("auth-url")] = "http://goog.url"
+		data[parser.GetAnnotationWithPrefix("auth-forward-headers")] = test.forwardHeaders
+		data[parser.GetAnnotationWithPrefix("auth-request-headers")] = test.requestHeaders
+		data[parser.GetAnnotationWithPrefix("auth-max-body-size")] = test.maxBodySize
+		ing.SetAnnotations(data)
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if test.expErr {
+			if err == nil {
+				t.Errorf("%v: expected error but returned nil", test.title)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.title, err)
+			continue
+		}
+
+		u, ok := i.(*Config)
+		if !ok {
+			t.Errorf("%v: expected an External type", test.title)
+			continue
+		}
+
+		if !reflect.DeepEqual(u.ForwardHeaders, test.expForward) {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.title, test.expForward, u.ForwardHeaders)
+		}
+		if !reflect.DeepEqual(u.RequestHeaders, test.expRequest) {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.title, test.expRequest, u.RequestHeaders)
+		}
+		if u.MaxBodySize != test.maxBodySize {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.title, test.maxBodySize, u.MaxBodySize)
+		}
+	}
+}
+
+func TestCacheCookieAnnotation(t *testing.T) {
+	ing := buildIngress()
+
+	tests := []struct {
+		title  string
+		cookie string
+		expErr bool
+	}{
+		{"no cookie", "", false},
+		{"cookie", "_oauth2_proxy", false},
+		{"cookie with dashes", "session-id", false},
+		{"invalid cookie", "session id", true},
+		{"cookie with variable", "$host", true},
+	}
+
+	for _, test := range tests {
+		data := map[string]string{}
+		data[parser.GetAnnotationWithPrefix("auth-url")] = "http://goog.url"
+		data[parser.GetAnnotationWithPrefix("auth-cache-cookie")] = test.cookie
+		ing.SetAnnotations(data)
+
+		i, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if test.expErr {
+			if err == nil {
+				t.Errorf("%v: expected error but returned nil", test.title)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.title, err)
+			continue
+		}
+
+		u := i.(*Config)
+		if u.CacheCookie != test.cookie {
+			t.Errorf("%v: expected \"%v\" but \"%v\" was returned", test.title, test.cookie, u.CacheCookie)
+		}
+	}
+}
+
 func TestParseStringToURL(t *testing.T) {
 	validURL := "http://bar.foo.com/external-auth"
 	validParsedURL, _ := url.Parse(validURL)
@@ -17,11 +17,13 @@ limitations under the License.
 package authtls
 
 import (
+	"regexp"
+	"strconv"
+	"strings"
+
 	"github.com/pkg/errors"
 	networking "k8s.io/api/networking/v1beta1"
 
-	"regexp"
-
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
@@ -34,7 +36,12 @@ const (
 )
 
 var (
-	authVerifyClientRegex = regexp.MustCompile(`on|off|optional|optional_no_ca`)
+	authVerifyClientRegex = regexp.MustCompile(`^(on|off|optional|optional_no_ca)$`)
+	// the error page is a path, a named location or an URL rendered in the
+	// error_page directive, it must not terminate the directive
+	authErrorPageRegex = regexp.MustCompile(`^[^\s;{}"'\\]+$`)
+	// elements of the X-Forwarded-Client-Cert header
+	forwardedClientCertElements = []string{"Hash", "Cert", "Subject", "URI"}
 )
 
 // Config contains the AuthSSLCert used for mutual authentication
@@ -45,7 +52,13 @@ type Config struct {
 	ValidationDepth    int    `json:"validationDepth"`
 	ErrorPage          string `json:"errorPage"`
 	PassCertToUpstream bool   `json:"passCertToUpstream"`
-	AuthTLSError       string
+	// ForwardedClientCert contains the elements of the X-Forwarded-Client-Cert
+	// header sent to the upstream, the header is not sent when it is empty
+	ForwardedClientCert []string `json:"forwardedClientCert,omitempty"`
+	// Port is the additional HTTPS port requiring the client certificates,
+	// the other ports of the server do not authenticate the clients
+	Port         int `json:"port,omitempty"`
+	AuthTLSError string
 }
 
 // Equal tests for equality between two Config types
@@ -71,6 +84,12 @@ func (assl1 *Config) Equal(assl2 *Config) bool {
 	if assl1.PassCertToUpstream != assl2.PassCertToUpstream {
 		return false
 	}
+	if strings.Join(assl1.ForwardedClientCert, ",") != strings.Join(assl2.ForwardedClientCert, ",") {
+		return false
+	}
+	if assl1.Port != assl2.Port {
+		return false
+	}
 
 	return true
 }
@@ -108,18 +127,27 @@ func (a authTLS) Parse(ing *networking.Ingress) (interface{}, error) {
 	config.AuthSSLCert = *authCert
 
 	config.VerifyClient, err = parser.GetStringAnnotation("auth-tls-verify-client", ing)
-	if err != nil || !authVerifyClientRegex.MatchString(config.VerifyClient) {
+	if err != nil {
+		config.VerifyClient = defaultAuthVerifyClient
+	} else if !authVerifyClientRegex.MatchString(config.VerifyClient) {
+		parser.RecordInvalidAnnotation(ing, ing_errors.NewInvalidAnnotationContent("auth-tls-verify-client", config.VerifyClient))
 		config.VerifyClient = defaultAuthVerifyClient
 	}
 
 	config.ValidationDepth, err = parser.GetIntAnnotation("auth-tls-verify-depth", ing)
 	if err != nil || config.ValidationDepth == 0 {
 		config.ValidationDepth = defaultAuthTLSDepth
+	} else if config.ValidationDepth < 0 {
+		parser.RecordInvalidAnnotation(ing, ing_errors.NewInvalidAnnotationContent("auth-tls-verify-depth", strconv.Itoa(config.ValidationDepth)))
+		config.ValidationDepth = defaultAuthTLSDepth
 	}
 
 	config.ErrorPage, err = parser.GetStringAnnotation("auth-tls-error-page", ing)
 	if err != nil {
 		config.ErrorPage = ""
+	} else if !authErrorPageRegex.MatchString(config.ErrorPage) {
+		parser.RecordInvalidAnnotation(ing, ing_errors.NewInvalidAnnotationContent("auth-tls-error-page", config.ErrorPage))
+		config.ErrorPage = ""
 	}
 
 	config.PassCertToUpstream, err = parser.GetBoolAnnotation("auth-tls-pass-certificate-to-upstream", ing)
@@ -127,5 +155,58 @@ func (a authTLS) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.PassCertToUpstream = false
 	}
 
+	forwardedClientCert, err := parser.GetStringAnnotation("auth-tls-forwarded-client-cert", ing)
+	if err == nil {
+		config.ForwardedClientCert, err = parseForwardedClientCert(forwardedClientCert)
+		if err != nil {
+			parser.RecordInvalidAnnotation(ing, err)
+		}
+	}
+
+	// the server is denied when the port is invalid rather than accepting
+	// the clients without certificate on the port
+	config.Port, err = parser.GetIntAnnotation("auth-tls-port", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return &Config{}, ing_errors.NewLocationDenied(err.Error())
+	}
+	if config.Port < 0 || config.Port > 65535 {
+		return &Config{}, ing_errors.NewLocationDenied("invalid auth-tls-port " + strconv.Itoa(config.Port))
+	}
+
 	return config, nil
 }
+
+// parseForwardedClientCert returns the elements of a comma separated list,
+// without duplicates, ignoring the case of the names
+func parseForwardedClientCert(value string) ([]string, error) {
+	elements := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		found := ""
+		for _, element := range forwardedClientCertElements {
+			if strings.EqualFold(item, element) {
+				found = element
+				break
+			}
+		}
+		if found == "" {
+			return nil, ing_errors.NewInvalidAnnotationContent("auth-tls-forwarded-client-cert", value)
+		}
+
+		duplicate := false
+		for _, element := range elements {
+			if element == found {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			elements = append(elements, found)
+		}
+	}
+
+	return elements, nil
+}
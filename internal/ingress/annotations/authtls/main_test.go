@@ -17,6 +17,7 @@ limitations under the License.
 package authtls
 
 import (
+	"strings"
 	"testing"
 
 	api "k8s.io/api/core/v1"
@@ -182,6 +183,159 @@ func TestInvalidAnnotations(t *testing.T) {
 
 }
 
+func TestOptionalAnnotations(t *testing.T) {
+	testCases := []struct {
+		name         string
+		verifyClient string
+		depth        string
+		errorPage    string
+		expected     Config
+		invalid      []string
+	}{
+		{
+			name:         "optional_no_ca mode",
+			verifyClient: "optional_no_ca",
+			depth:        "3",
+			errorPage:    "https://login.example.com/?rd=$scheme://$host$request_uri",
+			expected:     Config{VerifyClient: "optional_no_ca", ValidationDepth: 3, ErrorPage: "https://login.example.com/?rd=$scheme://$host$request_uri"},
+		},
+		{
+			name:         "named location",
+			verifyClient: "optional",
+			depth:        "2",
+			errorPage:    "@client_certificate_error",
+			expected:     Config{VerifyClient: "optional", ValidationDepth: 2, ErrorPage: "@client_certificate_error"},
+		},
+		{
+			name:         "invalid values",
+			verifyClient: "online",
+			depth:        "-1",
+			errorPage:    "/error; return 200",
+			expected:     Config{VerifyClient: "on", ValidationDepth: 1, ErrorPage: ""},
+			invalid: []string{
+				parser.GetAnnotationWithPrefix("auth-tls-error-page"),
+				parser.GetAnnotationWithPrefix("auth-tls-verify-client"),
+				parser.GetAnnotationWithPrefix("auth-tls-verify-depth"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ing := buildIngress()
+			ing.SetAnnotations(map[string]string{
+				parser.GetAnnotationWithPrefix("auth-tls-secret"):        "default/demo-secret",
+				parser.GetAnnotationWithPrefix("auth-tls-verify-client"): tc.verifyClient,
+				parser.GetAnnotationWithPrefix("auth-tls-verify-depth"):  tc.depth,
+				parser.GetAnnotationWithPrefix("auth-tls-error-page"):    tc.errorPage,
+			})
+
+			parser.TrackInvalidAnnotations(ing)
+			i, err := NewParser(&mockSecret{}).Parse(ing)
+			invalid := parser.InvalidAnnotations(ing)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			u := i.(*Config)
+			if u.VerifyClient != tc.expected.VerifyClient {
+				t.Errorf("expected verify client %v but got %v", tc.expected.VerifyClient, u.VerifyClient)
+			}
+			if u.ValidationDepth != tc.expected.ValidationDepth {
+				t.Errorf("expected depth %v but got %v", tc.expected.ValidationDepth, u.ValidationDepth)
+			}
+			if u.ErrorPage != tc.expected.ErrorPage {
+				t.Errorf("expected error page %v but got %v", tc.expected.ErrorPage, u.ErrorPage)
+			}
+
+			if len(invalid) != len(tc.invalid) {
+				t.Fatalf("expected invalid annotations %v but got %v", tc.invalid, invalid)
+			}
+			for i, name := range tc.invalid {
+				if invalid[i].Name != name {
+					t.Errorf("expected invalid annotation %v but got %v", name, invalid[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestForwardedClientCert(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected []string
+		invalid  bool
+	}{
+		{"Hash,Subject,URI,Cert", []string{"Hash", "Subject", "URI", "Cert"}, false},
+		{"hash, uri, hash", []string{"Hash", "URI"}, false},
+		{"Hash,DNS", nil, true},
+	}
+
+	for _, tc := range testCases {
+		ing := buildIngress()
+		ing.SetAnnotations(map[string]string{
+			parser.GetAnnotationWithPrefix("auth-tls-secret"):                "default/demo-secret",
+			parser.GetAnnotationWithPrefix("auth-tls-forwarded-client-cert"): tc.value,
+		})
+
+		parser.TrackInvalidAnnotations(ing)
+		i, err := NewParser(&mockSecret{}).Parse(ing)
+		invalid := parser.InvalidAnnotations(ing)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tc.value, err)
+		}
+
+		u := i.(*Config)
+		if strings.Join(u.ForwardedClientCert, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("%v: expected %v but got %v", tc.value, tc.expected, u.ForwardedClientCert)
+		}
+		if tc.invalid != (len(invalid) == 1) {
+			t.Errorf("%v: expected invalid %v but got %v", tc.value, tc.invalid, invalid)
+		}
+	}
+}
+
+func TestPort(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected int
+		denied   bool
+	}{
+		{"", 0, false},
+		{"8443", 8443, false},
+		{"0", 0, false},
+		{"-1", 0, true},
+		{"70000", 0, true},
+		{"https", 0, true},
+	}
+
+	for _, tc := range testCases {
+		data := map[string]string{
+			parser.GetAnnotationWithPrefix("auth-tls-secret"): "default/demo-secret",
+		}
+		if tc.value != "" {
+			data[parser.GetAnnotationWithPrefix("auth-tls-port")] = tc.value
+		}
+		ing := buildIngress()
+		ing.SetAnnotations(data)
+
+		i, err := NewParser(&mockSecret{}).Parse(ing)
+		if tc.denied {
+			if !errors.IsLocationDenied(err) {
+				t.Errorf("%q: expected a location denied error but got %v", tc.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.value, err)
+		}
+
+		if port := i.(*Config).Port; port != tc.expected {
+			t.Errorf("%q: expected port %v but got %v", tc.value, tc.expected, port)
+		}
+	}
+}
+
 func TestEquals(t *testing.T) {
 	cfg1 := &Config{}
 	cfg2 := &Config{}
@@ -253,6 +407,15 @@ func TestEquals(t *testing.T) {
 	}
 	cfg2.PassCertToUpstream = true
 
+	// Different Forwarded Client Cert
+	cfg1.ForwardedClientCert = []string{"Hash"}
+	cfg2.ForwardedClientCert = []string{"Hash", "URI"}
+	result = cfg1.Equal(cfg2)
+	if result != false {
+		t.Errorf("Expected false")
+	}
+	cfg2.ForwardedClientCert = []string{"Hash"}
+
 	// Equal Configs
 	result = cfg1.Equal(cfg2)
 	if result != true {
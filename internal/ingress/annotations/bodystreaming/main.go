@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bodystreaming
+
+import (
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// the streamed bodies can be larger than a gigabyte
+var sizeRegex = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+
+// Config describes the streaming of the request bodies to the upstream,
+// without buffering them in memory or in temporary files
+type Config struct {
+	// Enabled disables the buffering of the request bodies
+	Enabled bool `json:"enabled"`
+	// MaxSize is the maximum size of a streamed body, replacing proxy-body-size
+	// +optional
+	MaxSize string `json:"maxSize,omitempty"`
+	// RequireContentLength rejects the requests with a chunked body, so the
+	// size of the body is checked before it is streamed to the upstream
+	// +optional
+	RequireContentLength bool `json:"requireContentLength,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Enabled != c2.Enabled {
+		return false
+	}
+	if c1.MaxSize != c2.MaxSize {
+		return false
+	}
+	if c1.RequireContentLength != c2.RequireContentLength {
+		return false
+	}
+
+	return true
+}
+
+type bodyStreaming struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new request body streaming annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return bodyStreaming{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to stream
+// the request bodies to the upstream
+func (a bodyStreaming) Parse(ing *networking.Ingress) (interface{}, error) {
+	enabled, err := parser.GetBoolAnnotation("request-body-streaming", ing)
+	if err != nil || !enabled {
+		return &Config{}, nil
+	}
+
+	config := &Config{Enabled: true}
+
+	maxSize, err := parser.GetStringAnnotation("request-body-streaming-max-size", ing)
+	if err == nil {
+		maxSize = strings.TrimSpace(maxSize)
+		if !sizeRegex.MatchString(maxSize) {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent("request-body-streaming-max-size", maxSize)
+		}
+		config.MaxSize = maxSize
+	}
+
+	config.RequireContentLength, _ = parser.GetBoolAnnotation("request-body-streaming-require-content-length", ing)
+
+	return config, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bodystreaming
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	enabled := parser.GetAnnotationWithPrefix("request-body-streaming")
+	maxSize := parser.GetAnnotationWithPrefix("request-body-streaming-max-size")
+	requireContentLength := parser.GetAnnotationWithPrefix("request-body-streaming-require-content-length")
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		expectErr   bool
+	}{
+		{map[string]string{}, &Config{}, false},
+		{map[string]string{enabled: "false", maxSize: "10g"}, &Config{}, false},
+		{map[string]string{enabled: "true"}, &Config{Enabled: true}, false},
+		{map[string]string{enabled: "true", maxSize: " 10g", requireContentLength: "true"},
+			&Config{Enabled: true, MaxSize: "10g", RequireContentLength: true}, false},
+		{map[string]string{enabled: "true", maxSize: "10GB"}, &Config{}, true},
+		{map[string]string{enabled: "true", maxSize: "-1"}, &Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if testCase.expectErr && err == nil {
+			t.Errorf("expected an error but none returned, annotations: %s", testCase.annotations)
+		}
+		if !testCase.expectErr && err != nil {
+			t.Errorf("unexpected error %v, annotations: %s", err, testCase.annotations)
+		}
+
+		if !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, result, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bodytransform
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	// Gzip compresses the request bodies sent to the upstream servers
+	Gzip = "gzip"
+	// Identity decompresses the gzip request bodies sent to the upstream servers
+	Identity = "identity"
+
+	// defaultMaxSize is the size of the largest body transformed by default
+	defaultMaxSize = 1024 * 1024
+	// maxMaxSize is the upper bound of the body-transform-max-size annotation,
+	// the bodies are transformed in memory
+	maxMaxSize = 16 * 1024 * 1024
+)
+
+var (
+	fieldRegex   = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+	charsetRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+	sizeRegex    = regexp.MustCompile(`^([0-9]+)([kKmM]?)$`)
+)
+
+// Config describes the transformations applied to the request and response bodies
+type Config struct {
+	// RequestEncoding is the encoding of the request bodies sent to the upstream servers
+	RequestEncoding string `json:"requestEncoding,omitempty"`
+	// RedactJSONFields contains the names of the fields redacted in JSON responses
+	RedactJSONFields []string `json:"redactJSONFields,omitempty"`
+	// Charset is added to the Content-Type of the responses without one
+	Charset string `json:"charset,omitempty"`
+	// MaxSize is the size in bytes of the largest body transformed
+	MaxSize int `json:"maxSize,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.RequestEncoding != c2.RequestEncoding {
+		return false
+	}
+	if len(c1.RedactJSONFields) != len(c2.RedactJSONFields) {
+		return false
+	}
+	for i := range c1.RedactJSONFields {
+		if c1.RedactJSONFields[i] != c2.RedactJSONFields[i] {
+			return false
+		}
+	}
+	if c1.Charset != c2.Charset {
+		return false
+	}
+	if c1.MaxSize != c2.MaxSize {
+		return false
+	}
+
+	return true
+}
+
+type bodyTransform struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new body transformation annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return bodyTransform{r}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to transform the request and response bodies
+func (a bodyTransform) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{
+		MaxSize: defaultMaxSize,
+	}
+
+	encoding, err := parser.GetStringAnnotation("request-body-encoding", ing)
+	if err == nil {
+		encoding = strings.ToLower(encoding)
+		if encoding != Gzip && encoding != Identity {
+			return &Config{}, invalid("request-body-encoding", encoding)
+		}
+		config.RequestEncoding = encoding
+	}
+
+	fields, err := parser.GetStringAnnotation("response-redact-json-fields", ing)
+	if err == nil {
+		for _, field := range strings.Split(fields, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			if !fieldRegex.MatchString(field) {
+				return &Config{}, invalid("response-redact-json-fields", fields)
+			}
+			config.RedactJSONFields = append(config.RedactJSONFields, field)
+		}
+	}
+
+	charset, err := parser.GetStringAnnotation("response-charset", ing)
+	if err == nil {
+		if !charsetRegex.MatchString(charset) {
+			return &Config{}, invalid("response-charset", charset)
+		}
+		config.Charset = charset
+	}
+
+	maxSize, err := parser.GetStringAnnotation("body-transform-max-size", ing)
+	if err == nil {
+		size, err := parseSize(maxSize)
+		if err != nil {
+			return &Config{}, invalid("body-transform-max-size", maxSize)
+		}
+		config.MaxSize = size
+	}
+
+	if config.RequestEncoding == "" && len(config.RedactJSONFields) == 0 && config.Charset == "" {
+		return &Config{}, ing_errors.ErrMissingAnnotations
+	}
+
+	return config, nil
+}
+
+// invalid denies the location, the responses must not be sent when
+// the fields to redact cannot be read
+func invalid(name, value string) error {
+	return ing_errors.NewLocationDenied(fmt.Sprintf("invalid value %q for annotation %v", value, name))
+}
+
+// parseSize converts a size using the NGINX syntax (1024, 8k, 1m) into bytes
+func parseSize(s string) (int, error) {
+	m := sizeRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %v", s)
+	}
+
+	size, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, err
+	}
+
+	switch strings.ToLower(m[2]) {
+	case "k":
+		size *= 1024
+	case "m":
+		size *= 1024 * 1024
+	}
+
+	if size < 1 || size > maxMaxSize {
+		return 0, fmt.Errorf("size %v must be between 1 and %v bytes", s, maxMaxSize)
+	}
+
+	return size, nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bodytransform
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	encoding := parser.GetAnnotationWithPrefix("request-body-encoding")
+	fields := parser.GetAnnotationWithPrefix("response-redact-json-fields")
+	charset := parser.GetAnnotationWithPrefix("response-charset")
+	maxSize := parser.GetAnnotationWithPrefix("body-transform-max-size")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		expectErr   bool
+	}{
+		{map[string]string{encoding: "gzip"}, &Config{RequestEncoding: Gzip, MaxSize: defaultMaxSize}, false},
+		{map[string]string{encoding: "Identity"}, &Config{RequestEncoding: Identity, MaxSize: defaultMaxSize}, false},
+		{map[string]string{fields: "password, token,,user.ssn"},
+			&Config{RedactJSONFields: []string{"password", "token", "user.ssn"}, MaxSize: defaultMaxSize}, false},
+		{map[string]string{charset: "utf-8"}, &Config{Charset: "utf-8", MaxSize: defaultMaxSize}, false},
+		{map[string]string{encoding: "gzip", maxSize: "512k"}, &Config{RequestEncoding: Gzip, MaxSize: 512 * 1024}, false},
+		{map[string]string{encoding: "gzip", maxSize: "2M"}, &Config{RequestEncoding: Gzip, MaxSize: 2 * 1024 * 1024}, false},
+		{map[string]string{encoding: "gzip", maxSize: "4096"}, &Config{RequestEncoding: Gzip, MaxSize: 4096}, false},
+		{map[string]string{encoding: "br"}, &Config{}, true},
+		{map[string]string{fields: "pass word"}, &Config{}, true},
+		{map[string]string{charset: "utf-8; x"}, &Config{}, true},
+		{map[string]string{encoding: "gzip", maxSize: "1g"}, &Config{}, true},
+		{map[string]string{encoding: "gzip", maxSize: "17m"}, &Config{}, true},
+		{map[string]string{encoding: "gzip", maxSize: "0"}, &Config{}, true},
+		{map[string]string{maxSize: "1m"}, &Config{}, true},
+		{map[string]string{}, &Config{}, true},
+		{nil, &Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if testCase.expectErr && err == nil {
+			t.Errorf("expected an error but none returned, annotations: %s", testCase.annotations)
+		}
+		if !testCase.expectErr && err != nil {
+			t.Errorf("unexpected error %v, annotations: %s", err, testCase.annotations)
+		}
+
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type but %T was returned", result)
+		}
+		if !config.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
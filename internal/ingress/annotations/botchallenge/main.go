@@ -0,0 +1,237 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package botchallenge
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	ing_net "k8s.io/ingress-nginx/internal/net"
+)
+
+const (
+	// JavaScript challenges are solved by any browser executing the page
+	JavaScript = "js"
+	// ProofOfWork challenges require the browser to find a hash with a
+	// number of leading zero bits
+	ProofOfWork = "pow"
+
+	defaultDifficulty = 16
+	minDifficulty     = 8
+	maxDifficulty     = 20
+)
+
+var (
+	asnRegex = regexp.MustCompile(`^[0-9]+$`)
+	// the patterns are passed to Lua, only printable ASCII characters are allowed
+	userAgentRegex = regexp.MustCompile(`^[\x20-\x7e]+$`)
+)
+
+// Config describes the challenge issued to the suspicious clients before
+// their requests are proxied to the backend
+type Config struct {
+	// Mode is the kind of challenge, js or pow. Empty when disabled
+	Mode string `json:"mode,omitempty"`
+	// Difficulty is the number of leading zero bits of the proof of work
+	Difficulty int `json:"difficulty,omitempty"`
+	// Rate is the number of requests per minute of a client address above
+	// which the client is challenged
+	Rate int `json:"rate,omitempty"`
+	// ASNs contains the autonomous systems of the challenged clients
+	ASNs []string `json:"asns,omitempty"`
+	// UserAgents contains the patterns of the challenged user agents
+	UserAgents []string `json:"userAgents,omitempty"`
+	// ExemptCIDRs contains the networks of the clients never challenged
+	ExemptCIDRs []string `json:"exemptCIDRs,omitempty"`
+	// ExemptUserAgents contains the patterns of the user agents never challenged
+	ExemptUserAgents []string `json:"exemptUserAgents,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Mode != c2.Mode {
+		return false
+	}
+	if c1.Difficulty != c2.Difficulty {
+		return false
+	}
+	if c1.Rate != c2.Rate {
+		return false
+	}
+	if !equalStrings(c1.ASNs, c2.ASNs) {
+		return false
+	}
+	if !equalStrings(c1.UserAgents, c2.UserAgents) {
+		return false
+	}
+	if !equalStrings(c1.ExemptCIDRs, c2.ExemptCIDRs) {
+		return false
+	}
+	if !equalStrings(c1.ExemptUserAgents, c2.ExemptUserAgents) {
+		return false
+	}
+
+	return true
+}
+
+func equalStrings(s1, s2 []string) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type botChallenge struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new bot challenge annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return botChallenge{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// challenge the clients matching any of the triggers (request rate,
+// autonomous system or user agent), or all the clients without trigger.
+func (a botChallenge) Parse(ing *networking.Ingress) (interface{}, error) {
+	mode, err := parser.GetStringAnnotation("bot-challenge", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	if mode != JavaScript && mode != ProofOfWork {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent("bot-challenge", mode)
+	}
+
+	config := &Config{Mode: mode}
+
+	if mode == ProofOfWork {
+		config.Difficulty = defaultDifficulty
+
+		difficulty, err := parser.GetIntAnnotation("bot-challenge-difficulty", ing)
+		if err == nil {
+			if difficulty < minDifficulty || difficulty > maxDifficulty {
+				return &Config{}, ing_errors.NewInvalidAnnotationConfiguration("bot-challenge-difficulty",
+					fmt.Sprintf("the difficulty must be between %v and %v", minDifficulty, maxDifficulty))
+			}
+			config.Difficulty = difficulty
+		}
+	}
+
+	rate, err := parser.GetIntAnnotation("bot-challenge-rate", ing)
+	if err == nil {
+		if rate <= 0 {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent("bot-challenge-rate", strconv.Itoa(rate))
+		}
+		config.Rate = rate
+	}
+
+	asns, err := parser.GetStringAnnotation("bot-challenge-asns", ing)
+	if err == nil {
+		for _, asn := range splitList(asns) {
+			if !asnRegex.MatchString(asn) {
+				return &Config{}, ing_errors.NewInvalidAnnotationContent("bot-challenge-asns", asns)
+			}
+			config.ASNs = append(config.ASNs, asn)
+		}
+	}
+
+	config.UserAgents, err = parseUserAgents("bot-challenge-user-agents", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	config.ExemptUserAgents, err = parseUserAgents("bot-challenge-exempt-user-agents", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	cidrs, err := parser.GetStringAnnotation("bot-challenge-exempt-cidrs", ing)
+	if err == nil {
+		ipnets, ips, err := ing_net.ParseIPNets(splitList(cidrs)...)
+		if err != nil {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent("bot-challenge-exempt-cidrs", cidrs)
+		}
+
+		for k := range ipnets {
+			config.ExemptCIDRs = append(config.ExemptCIDRs, k)
+		}
+		// the Lua module only matches networks
+		for _, ip := range ips {
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			config.ExemptCIDRs = append(config.ExemptCIDRs, (&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}).String())
+		}
+		sort.Strings(config.ExemptCIDRs)
+	}
+
+	return config, nil
+}
+
+// parseUserAgents returns the valid regular expressions of the annotation
+func parseUserAgents(name string, ing *networking.Ingress) ([]string, error) {
+	val, err := parser.GetStringAnnotation(name, ing)
+	if err != nil {
+		return nil, nil
+	}
+
+	patterns := []string{}
+	for _, pattern := range splitList(val) {
+		if !userAgentRegex.MatchString(pattern) {
+			return nil, ing_errors.NewInvalidAnnotationContent(name, val)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, ing_errors.NewInvalidAnnotationContent(name, val)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, nil
+}
+
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
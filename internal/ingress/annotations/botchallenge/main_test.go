@@ -0,0 +1,85 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package botchallenge
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	mode := parser.GetAnnotationWithPrefix("bot-challenge")
+	difficulty := parser.GetAnnotationWithPrefix("bot-challenge-difficulty")
+	rate := parser.GetAnnotationWithPrefix("bot-challenge-rate")
+	asns := parser.GetAnnotationWithPrefix("bot-challenge-asns")
+	userAgents := parser.GetAnnotationWithPrefix("bot-challenge-user-agents")
+	exemptCIDRs := parser.GetAnnotationWithPrefix("bot-challenge-exempt-cidrs")
+	exemptUserAgents := parser.GetAnnotationWithPrefix("bot-challenge-exempt-user-agents")
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		expectErr   bool
+	}{
+		{map[string]string{}, &Config{}, true},
+		{map[string]string{mode: "captcha"}, &Config{}, true},
+		{map[string]string{mode: "js"}, &Config{Mode: "js"}, false},
+		{map[string]string{mode: "js", difficulty: "20"}, &Config{Mode: "js"}, false},
+		{map[string]string{mode: "pow"}, &Config{Mode: "pow", Difficulty: 16}, false},
+		{map[string]string{mode: "pow", difficulty: "12"}, &Config{Mode: "pow", Difficulty: 12}, false},
+		{map[string]string{mode: "pow", difficulty: "32"}, &Config{}, true},
+		{map[string]string{mode: "js", rate: "120"}, &Config{Mode: "js", Rate: 120}, false},
+		{map[string]string{mode: "js", rate: "-1"}, &Config{}, true},
+		{map[string]string{mode: "js", asns: "16509, 14061"}, &Config{Mode: "js", ASNs: []string{"16509", "14061"}}, false},
+		{map[string]string{mode: "js", asns: "AS16509"}, &Config{}, true},
+		{map[string]string{mode: "js", userAgents: "python-requests, ^curl/"}, &Config{Mode: "js", UserAgents: []string{"python-requests", "^curl/"}}, false},
+		{map[string]string{mode: "js", userAgents: "(scrapy"}, &Config{}, true},
+		{map[string]string{mode: "js", exemptUserAgents: "Googlebot"}, &Config{Mode: "js", ExemptUserAgents: []string{"Googlebot"}}, false},
+		{map[string]string{mode: "js", exemptCIDRs: "10.0.0.0/8, 192.168.1.1, 2001:db8::/32"}, &Config{Mode: "js", ExemptCIDRs: []string{"10.0.0.0/8", "192.168.1.1/32", "2001:db8::/32"}}, false},
+		{map[string]string{mode: "js", exemptCIDRs: "10.0.0.0/33"}, &Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if testCase.expectErr && err == nil {
+			t.Errorf("expected an error but none returned, annotations: %s", testCase.annotations)
+		}
+		if !testCase.expectErr && err != nil {
+			t.Errorf("unexpected error %v, annotations: %s", err, testCase.annotations)
+		}
+
+		if !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, result, testCase.annotations)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package canonicalpath
+
+import (
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	// TrailingSlashAdd redirects the paths without a trailing slash
+	TrailingSlashAdd = "add"
+	// TrailingSlashRemove redirects the paths with a trailing slash
+	TrailingSlashRemove = "remove"
+)
+
+// Config describes the canonicalization of the paths of the requests, so the
+// locations and the upstream servers see the same path
+type Config struct {
+	// MergeSlashes is on to proxy the paths with the slashes merged, as NGINX
+	// does to choose the location, or off to choose the location with the
+	// slashes of the request. The default behavior of NGINX is kept when empty.
+	// +optional
+	MergeSlashes string `json:"mergeSlashes,omitempty"`
+	// TrailingSlash redirects the requests to the path with a trailing slash,
+	// add, or without, remove
+	// +optional
+	TrailingSlash string `json:"trailingSlash,omitempty"`
+	// NormalizePercentEncoding proxies the percent-encoded paths as decoded by
+	// NGINX to choose the location
+	// +optional
+	NormalizePercentEncoding bool `json:"normalizePercentEncoding,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.MergeSlashes != c2.MergeSlashes {
+		return false
+	}
+	if c1.TrailingSlash != c2.TrailingSlash {
+		return false
+	}
+	if c1.NormalizePercentEncoding != c2.NormalizePercentEncoding {
+		return false
+	}
+
+	return true
+}
+
+type canonicalPath struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new path canonicalization annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return canonicalPath{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// canonicalize the paths of the requests
+func (a canonicalPath) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	mergeSlashes, err := parser.GetBoolAnnotation("merge-slashes", ing)
+	if err == nil {
+		config.MergeSlashes = "off"
+		if mergeSlashes {
+			config.MergeSlashes = "on"
+		}
+	}
+
+	trailingSlash, err := parser.GetStringAnnotation("trailing-slash", ing)
+	if err == nil {
+		trailingSlash = strings.ToLower(strings.TrimSpace(trailingSlash))
+		if trailingSlash != TrailingSlashAdd && trailingSlash != TrailingSlashRemove {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent("trailing-slash", trailingSlash)
+		}
+		config.TrailingSlash = trailingSlash
+	}
+
+	config.NormalizePercentEncoding, _ = parser.GetBoolAnnotation("normalize-percent-encoding", ing)
+
+	return config, nil
+}
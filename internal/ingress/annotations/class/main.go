@@ -17,9 +17,15 @@ limitations under the License.
 package class
 
 import (
-	"k8s.io/klog"
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
 
 	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/logging"
 )
 
 const (
@@ -35,17 +41,54 @@ var (
 
 	// IngressClass sets the runtime ingress class to use
 	// An empty string means accept all ingresses without
-	// annotation and the ones configured with class nginx
+	// annotation and the ones configured with class nginx.
+	// It can contain several classes separated by commas, each one
+	// being a name or a glob pattern like nginx-* matched against the
+	// annotation. IngressClass objects are not read.
 	IngressClass = "nginx"
+
+	// DefaultSSLCertificates contains the secret of the certificate used
+	// instead of the default certificate by the hosts of each class
+	DefaultSSLCertificates = map[string]string{}
 )
 
+// invalidNameChars matches the characters not allowed in the name of a
+// Kubernetes object
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
+// classes returns the names and patterns of IngressClass
+func classes() []string {
+	var classes []string
+	for _, c := range strings.Split(IngressClass, ",") {
+		classes = append(classes, strings.TrimSpace(c))
+	}
+
+	return classes
+}
+
+// matches returns true if the class of an Ingress matches one of the
+// names or patterns of IngressClass
+func matches(ingress string) bool {
+	for _, c := range classes() {
+		if c == ingress {
+			return true
+		}
+
+		if ok, err := path.Match(c, ingress); err == nil && ok && ingress != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
 // IsValid returns true if the given Ingress either doesn't specify
-// the ingress.class annotation, or it's set to the configured in the
-// ingress controller.
+// the ingress.class annotation, or it's set to one of the classes
+// configured in the ingress controller.
 func IsValid(ing *networking.Ingress) bool {
 	ingress, ok := ing.GetAnnotations()[IngressKey]
 	if !ok {
-		klog.V(3).Infof("annotation %v is not present in ingress %v/%v", IngressKey, ing.Namespace, ing.Name)
+		logging.V(3).Infof("annotation %v is not present in ingress %v/%v", IngressKey, ing.Namespace, ing.Name)
 	}
 
 	// we have 2 valid combinations
@@ -55,9 +98,62 @@ func IsValid(ing *networking.Ingress) bool {
 	// and 2 invalid combinations
 	// 3 - ingress with default class | fixed annotation on ingress
 	// 4 - ingress with specific class | different annotation on ingress
-	if ingress == "" && IngressClass == DefaultClass {
+	if ingress == "" && matches(DefaultClass) {
 		return true
 	}
 
-	return ingress == IngressClass
+	return matches(ingress)
+}
+
+// Of returns the class of a valid Ingress, the default class when the
+// annotation is blank
+func Of(ing *networking.Ingress) string {
+	ingress := ing.GetAnnotations()[IngressKey]
+	if ingress == "" {
+		return DefaultClass
+	}
+
+	return ingress
+}
+
+// DefaultSSLCertificate returns the secret of the default certificate of
+// the class of an Ingress, or an empty string
+func DefaultSSLCertificate(ing *networking.Ingress) string {
+	return DefaultSSLCertificates[Of(ing)]
+}
+
+// IsDefaultSSLCertificate returns true if the secret contains the default
+// certificate of a class
+func IsDefaultSSLCertificate(key string) bool {
+	for _, secret := range DefaultSSLCertificates {
+		if secret == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks the patterns of a list of classes separated by commas
+func Validate(classes string) error {
+	for _, c := range strings.Split(classes, ",") {
+		c = strings.TrimSpace(c)
+		if _, err := path.Match(c, ""); err != nil {
+			return fmt.Errorf("invalid ingress class pattern %q: %v", c, err)
+		}
+	}
+
+	return nil
+}
+
+// Name returns a name of the classes of the controller valid as part of
+// the name of a Kubernetes object. The name of a single class is returned
+// unchanged, a hash of the value avoids the conflicts between the others.
+func Name() string {
+	if !strings.ContainsAny(IngressClass, ",*?[]\\") {
+		return IngressClass
+	}
+
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(IngressClass), "-"), "-")
+	return fmt.Sprintf("%v-%x", name, sha256.Sum256([]byte(IngressClass)))[:len(name)+9]
 }
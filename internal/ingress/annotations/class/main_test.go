@@ -45,6 +45,14 @@ func TestIsValidClass(t *testing.T) {
 		{"custom", "custom", "nginx", true},
 		{"", "killer", "nginx", false},
 		{"custom", "nginx", "nginx", false},
+		{"internal", "nginx,internal", "nginx", true},
+		{"", "nginx, internal", "nginx", true},
+		{"", "internal,external", "nginx", false},
+		{"nginx-internal", "nginx-*", "nginx", true},
+		{"nginx", "nginx-*", "nginx", false},
+		{"", "nginx-*", "nginx", false},
+		{"", "*", "nginx", true},
+		{"custom", "nginx,cust[a-z]m", "nginx", true},
 	}
 
 	ing := &networking.Ingress{
@@ -68,3 +76,73 @@ func TestIsValidClass(t *testing.T) {
 		}
 	}
 }
+
+func TestValidate(t *testing.T) {
+	if err := Validate("nginx, nginx-*,inter[a-z]al"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := Validate("nginx,inter[nal"); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestName(t *testing.T) {
+	ic := IngressClass
+	defer func() {
+		IngressClass = ic
+	}()
+
+	IngressClass = "nginx-internal"
+	if name := Name(); name != "nginx-internal" {
+		t.Errorf("expected nginx-internal but %v returned", name)
+	}
+
+	IngressClass = "nginx,Internal"
+	name := Name()
+	if len(name) != len("nginx-internal-")+8 || name[:len("nginx-internal-")] != "nginx-internal-" {
+		t.Errorf("unexpected name %v", name)
+	}
+
+	IngressClass = "nginx-*"
+	if Name() == name || Name()[:len("nginx-")] != "nginx-" {
+		t.Errorf("unexpected name %v", Name())
+	}
+}
+
+func TestDefaultSSLCertificate(t *testing.T) {
+	dc := DefaultClass
+	certs := DefaultSSLCertificates
+	defer func() {
+		DefaultClass = dc
+		DefaultSSLCertificates = certs
+	}()
+
+	DefaultClass = "nginx"
+	DefaultSSLCertificates = map[string]string{"nginx": "default/nginx-tls", "internal": "default/internal-tls"}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        "foo",
+			Namespace:   api.NamespaceDefault,
+			Annotations: map[string]string{},
+		},
+	}
+
+	if cert := DefaultSSLCertificate(ing); cert != "default/nginx-tls" {
+		t.Errorf("expected default/nginx-tls but %v returned", cert)
+	}
+
+	ing.Annotations[IngressKey] = "internal"
+	if cert := DefaultSSLCertificate(ing); cert != "default/internal-tls" {
+		t.Errorf("expected default/internal-tls but %v returned", cert)
+	}
+
+	ing.Annotations[IngressKey] = "external"
+	if cert := DefaultSSLCertificate(ing); cert != "" {
+		t.Errorf("expected no certificate but %v returned", cert)
+	}
+
+	if !IsDefaultSSLCertificate("default/internal-tls") || IsDefaultSSLCertificate("default/external-tls") {
+		t.Errorf("unexpected default certificates")
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionlimit
+
+import (
+	"strconv"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type connectionLimit struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new connection limit annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return connectionLimit{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to limit
+// the concurrent connections of the server name
+func (a connectionLimit) Parse(ing *networking.Ingress) (interface{}, error) {
+	limit, err := parser.GetIntAnnotation("max-connections-per-host", ing)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 0 {
+		return 0, ing_errors.NewInvalidAnnotationContent("max-connections-per-host", strconv.Itoa(limit))
+	}
+
+	return limit, nil
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package endpointcondition
+
+import (
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type endpointCondition struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new endpoint ready condition annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return endpointCondition{r}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to send traffic only to the endpoints of the Pods with a condition
+// (i.e. ingress-warmup-complete) set to True. The value of the annotation
+// is the type of the condition.
+func (a endpointCondition) Parse(ing *networking.Ingress) (interface{}, error) {
+	condition, err := parser.GetStringAnnotation("endpoint-ready-condition", ing)
+	if err != nil {
+		return "", err
+	}
+
+	if errs := validation.IsQualifiedName(condition); len(errs) > 0 {
+		return "", ing_errors.NewInvalidAnnotationContent("endpoint-ready-condition", condition)
+	}
+
+	return condition, nil
+}
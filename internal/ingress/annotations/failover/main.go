@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package failover
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	defaultMaxFails    = 3
+	defaultFailTimeout = 10
+)
+
+var (
+	serviceRegex = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	portRegex    = regexp.MustCompile(`^([0-9]+|[a-z0-9]([-a-z0-9]*[a-z0-9])?)$`)
+)
+
+// Config describes the Service receiving the requests when all the
+// endpoints of the backend are down
+type Config struct {
+	// Service is the name of the Service, in the namespace of the Ingress
+	Service string `json:"service"`
+	// Port is the port of the Service. The port of the backend is used when empty
+	Port string `json:"port,omitempty"`
+	// MaxFails is the number of consecutive failed requests marking the backend down
+	MaxFails int `json:"maxFails"`
+	// FailTimeout is the number of seconds the backend is considered down
+	FailTimeout int `json:"failTimeout"`
+}
+
+type failover struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new failover annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return failover{r}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to send the requests to another Service when the backend is down
+func (f failover) Parse(ing *networking.Ingress) (interface{}, error) {
+	s, err := parser.GetStringAnnotation("failover-service", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	service, port := s, ""
+	if i := strings.Index(s, ":"); i != -1 {
+		service, port = s[:i], s[i+1:]
+		if !portRegex.MatchString(port) {
+			return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid port in failover-service %v", s))
+		}
+	}
+
+	if !serviceRegex.MatchString(service) {
+		return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid service name in failover-service %v", s))
+	}
+
+	maxFails, err := parser.GetIntAnnotation("failover-max-fails", ing)
+	if err != nil || maxFails < 1 {
+		maxFails = defaultMaxFails
+	}
+
+	failTimeout, err := parser.GetIntAnnotation("failover-fail-timeout", ing)
+	if err != nil || failTimeout < 1 {
+		failTimeout = defaultFailTimeout
+	}
+
+	return &Config{
+		Service:     service,
+		Port:        port,
+		MaxFails:    maxFails,
+		FailTimeout: failTimeout,
+	}, nil
+}
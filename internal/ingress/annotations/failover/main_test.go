@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package failover
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	service := parser.GetAnnotationWithPrefix("failover-service")
+	maxFails := parser.GetAnnotationWithPrefix("failover-max-fails")
+	failTimeout := parser.GetAnnotationWithPrefix("failover-fail-timeout")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		expectErr   bool
+	}{
+		{map[string]string{service: "other-cluster"}, &Config{Service: "other-cluster", MaxFails: 3, FailTimeout: 10}, false},
+		{map[string]string{service: "other-cluster:8080"}, &Config{Service: "other-cluster", Port: "8080", MaxFails: 3, FailTimeout: 10}, false},
+		{map[string]string{service: "other-cluster:http"}, &Config{Service: "other-cluster", Port: "http", MaxFails: 3, FailTimeout: 10}, false},
+		{map[string]string{service: "other-cluster", maxFails: "5", failTimeout: "30"}, &Config{Service: "other-cluster", MaxFails: 5, FailTimeout: 30}, false},
+		{map[string]string{service: "other-cluster", maxFails: "0", failTimeout: "-1"}, &Config{Service: "other-cluster", MaxFails: 3, FailTimeout: 10}, false},
+		{map[string]string{service: "Other_Cluster"}, &Config{}, true},
+		{map[string]string{service: "other-cluster:"}, &Config{}, true},
+		{map[string]string{service: "default/other-cluster"}, &Config{}, true},
+		{map[string]string{maxFails: "5"}, &Config{}, true},
+		{map[string]string{}, &Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if testCase.expectErr && err == nil {
+			t.Errorf("expected an error but none returned, annotations: %s", testCase.annotations)
+		}
+		if !testCase.expectErr && err != nil {
+			t.Errorf("unexpected error %v, annotations: %s", err, testCase.annotations)
+		}
+
+		if !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, result, testCase.annotations)
+		}
+	}
+}
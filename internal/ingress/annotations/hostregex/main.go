@@ -0,0 +1,172 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hostregex
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// reservedVariables contains the variables defined by the NGINX template
+// that cannot be used as name of a capture group
+var reservedVariables = sets.NewString(
+	"balancer_ewma_score",
+	"best_http_host",
+	"canary_variant",
+	"canary_weight",
+	"ingress_name",
+	"location_path",
+	"namespace",
+	"pass_access_scheme",
+	"pass_port",
+	"pass_server_port",
+	"proxy_alternative_upstream_name",
+	"proxy_host",
+	"proxy_upstream_name",
+	"service_name",
+	"service_port",
+	"target",
+	"the_real_ip",
+)
+
+// IsReservedVariable returns true when name is a variable defined by the
+// NGINX template
+func IsReservedVariable(name string) bool {
+	return reservedVariables.Has(name)
+}
+
+// Config contains the regular expression used as server name in
+// addition to the host of the Ingress rules
+type Config struct {
+	// Regex is the regular expression matched against the Host header,
+	// without the leading ~
+	Regex string `json:"regex"`
+	// Priority defines the order in which the regular expressions of
+	// the servers are evaluated. Higher values are evaluated first.
+	Priority int `json:"priority"`
+	// Captures contains the names of the capture groups of the regular
+	// expression, available as NGINX variables in the locations
+	Captures []string `json:"captures,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Regex != c2.Regex || c1.Priority != c2.Priority {
+		return false
+	}
+
+	return sets.NewString(c1.Captures...).Equal(sets.NewString(c2.Captures...))
+}
+
+// Matches returns true if the regular expression matches the host
+func (c Config) Matches(host string) bool {
+	re, err := regexp.Compile(c.Regex)
+	if err != nil {
+		return false
+	}
+
+	return re.MatchString(host)
+}
+
+type hostRegex struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new host regex annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return hostRegex{r}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to match the Host header with a regular expression (i.e.
+// ~^api-(?<tenant>.+)\.example\.com$). The expression must be anchored
+// and the named capture groups are exposed as NGINX variables.
+func (a hostRegex) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("host-regex", ing)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := ParseRegex(val)
+	if err != nil {
+		klog.Warningf("Ignoring host-regex annotation of Ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+		return nil, ing_errors.NewInvalidAnnotationContent("host-regex", val)
+	}
+
+	priority, err := parser.GetIntAnnotation("host-regex-priority", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return nil, err
+	}
+	config.Priority = priority
+
+	return config, nil
+}
+
+// ParseRegex validates a host regular expression and returns its
+// configuration with the names of the capture groups
+func ParseRegex(val string) (*Config, error) {
+	regex := strings.TrimPrefix(strings.TrimSpace(val), "~")
+	if regex == "" {
+		return nil, fmt.Errorf("the regular expression is empty")
+	}
+
+	if strings.ContainsAny(regex, " \t\n\"';") {
+		return nil, fmt.Errorf("the regular expression %q contains whitespaces, quotes or semicolons", regex)
+	}
+
+	if !strings.HasPrefix(regex, "^") || !strings.HasSuffix(regex, "$") {
+		return nil, fmt.Errorf("the regular expression %q must start with ^ and end with $", regex)
+	}
+
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q: %v", regex, err)
+	}
+
+	captures := []string{}
+	for _, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+
+		if reservedVariables.Has(name) {
+			return nil, fmt.Errorf("the capture group %q uses the name of a variable defined by the controller", name)
+		}
+
+		captures = append(captures, name)
+	}
+
+	return &Config{
+		Regex:    regex,
+		Captures: captures,
+	}, nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hostregex
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	regexAnnotation := parser.GetAnnotationWithPrefix("host-regex")
+	priorityAnnotation := parser.GetAnnotationWithPrefix("host-regex-priority")
+
+	ap := NewParser(&resolver.Mock{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		expectErr   bool
+	}{
+		{map[string]string{regexAnnotation: `~^api-(?<tenant>.+)\.example\.com$`}, &Config{Regex: `^api-(?<tenant>.+)\.example\.com$`, Captures: []string{"tenant"}}, false},
+		{map[string]string{regexAnnotation: `^(?P<app>[a-z]+)-(?<env>[a-z]+)\.example\.com$`, priorityAnnotation: "10"}, &Config{Regex: `^(?P<app>[a-z]+)-(?<env>[a-z]+)\.example\.com$`, Priority: 10, Captures: []string{"app", "env"}}, false},
+		{map[string]string{regexAnnotation: `^[a-z]{2,3}\.example\.com$`}, &Config{Regex: `^[a-z]{2,3}\.example\.com$`, Captures: []string{}}, false},
+		{map[string]string{regexAnnotation: `^api\.example\.com$`, priorityAnnotation: "high"}, nil, true},
+		{map[string]string{regexAnnotation: `api\.example\.com`}, nil, true},
+		{map[string]string{regexAnnotation: `^api(\.example\.com$`}, nil, true},
+		{map[string]string{regexAnnotation: `^(?<namespace>.+)\.example\.com$`}, nil, true},
+		{map[string]string{regexAnnotation: `^"api"\.example\.com$`}, nil, true},
+		{map[string]string{regexAnnotation: `^api\.example\.com$;return 200`}, nil, true},
+		{map[string]string{regexAnnotation: "~"}, nil, true},
+		{map[string]string{}, nil, true},
+		{nil, nil, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if testCase.expectErr && err == nil {
+			t.Errorf("expected an error but none returned, annotations: %s", testCase.annotations)
+		}
+		if !testCase.expectErr && err != nil {
+			t.Errorf("unexpected error %v, annotations: %s", err, testCase.annotations)
+		}
+
+		if testCase.expected == nil {
+			continue
+		}
+
+		config, ok := result.(*Config)
+		if !ok {
+			t.Errorf("expected a *Config but %T was returned, annotations: %s", result, testCase.annotations)
+			continue
+		}
+
+		if !reflect.DeepEqual(config, testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	config := Config{Regex: `^api-(?<tenant>.+)\.example\.com$`}
+
+	if !config.Matches("api-demo.example.com") {
+		t.Errorf("expected api-demo.example.com to match %v", config.Regex)
+	}
+	if config.Matches("api.example.com") {
+		t.Errorf("expected api.example.com not to match %v", config.Regex)
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+)
+
+// knownAnnotations contains the annotations, without prefix, read by the
+// annotation parsers. New annotations must be added to this list to be
+// accepted by the strict annotation validation.
+var knownAnnotations = sets.NewString(
+	"access-denied-time-windows",
+	"access-time-window-code",
+	"access-time-windows",
+	"access-time-zone",
+	"affinity",
+	"allowed-tls-fingerprints",
+	"alias-redirect",
+	"alias-redirect-code",
+	"app-root",
+	"auth-cache-cookie",
+	"auth-exclude-paths",
+	"auth-forward-headers",
+	"auth-max-body-size",
+	"auth-method",
+	"auth-realm",
+	"auth-request-headers",
+	"auth-request-redirect",
+	"auth-response-headers",
+	"auth-response-headers-to-client",
+	"auth-secret",
+	"auth-signin",
+	"auth-snippet",
+	"auth-tls-error-page",
+	"auth-tls-forwarded-client-cert",
+	"auth-tls-pass-certificate-to-upstream",
+	"auth-tls-port",
+	"auth-tls-secret",
+	"auth-tls-verify-client",
+	"auth-tls-verify-depth",
+	"auth-type",
+	"auth-url",
+	"backend-protocol",
+	"body-transform-max-size",
+	"bot-challenge",
+	"bot-challenge-asns",
+	"bot-challenge-difficulty",
+	"bot-challenge-exempt-cidrs",
+	"bot-challenge-exempt-user-agents",
+	"bot-challenge-rate",
+	"bot-challenge-user-agents",
+	"canary",
+	"canary-by-cookie",
+	"canary-by-header",
+	"canary-by-header-value",
+	"canary-weight",
+	"client-body-buffer-size",
+	"configuration-snippet",
+	"connection-proxy-header",
+	"cors-allow-credentials",
+	"cors-allow-headers",
+	"cors-allow-methods",
+	"cors-allow-origin",
+	"cors-max-age",
+	"custom-http-errors",
+	"default-backend",
+	"denied-tls-fingerprints",
+	"enable-access-log",
+	"enable-cors",
+	"enable-global-auth",
+	"enable-influxdb",
+	"enable-modsecurity",
+	"enable-owasp-core-rules",
+	"enable-rewrite-log",
+	"endpoint-ready-condition",
+	"failover-fail-timeout",
+	"failover-max-fails",
+	"failover-service",
+	"force-ssl-redirect",
+	"from-to-www-redirect",
+	"host-regex",
+	"host-regex-priority",
+	"http2-push-preload",
+	"influxdb-host",
+	"influxdb-measurement",
+	"influxdb-port",
+	"influxdb-server-name",
+	"limit-connections",
+	"limit-rate",
+	"limit-rate-after",
+	"limit-rpm",
+	"limit-rps",
+	"limit-whitelist",
+	"load-balance",
+	"lua-resty-waf",
+	"lua-resty-waf-allow-unknown-content-types",
+	"lua-resty-waf-debug",
+	"lua-resty-waf-extra-rules",
+	"lua-resty-waf-ignore-rulesets",
+	"lua-resty-waf-process-multipart-body",
+	"lua-resty-waf-score-threshold",
+	"max-connections-per-host",
+	"merge-slashes",
+	"modsecurity-snippet",
+	"modsecurity-transaction-id",
+	"normalize-percent-encoding",
+	"permanent-redirect",
+	"permanent-redirect-code",
+	"plain-http-action",
+	"plain-http-redirect-code",
+	"pod-routing-by",
+	"preserve-host",
+	"proxy-body-size",
+	"proxy-buffer-size",
+	"proxy-buffering",
+	"proxy-buffers-number",
+	"proxy-chain-address",
+	"proxy-chain-secret",
+	"proxy-connect-timeout",
+	"proxy-cookie-domain",
+	"proxy-cookie-path",
+	"proxy-next-upstream",
+	"proxy-next-upstream-timeout",
+	"proxy-next-upstream-tries",
+	"proxy-read-timeout",
+	"proxy-redirect-from",
+	"proxy-redirect-to",
+	"proxy-request-buffering",
+	"proxy-send-timeout",
+	"proxy-ssl-ca-configmap",
+	"proxy-ssl-name",
+	"proxy-ssl-secret",
+	"proxy-ssl-verify",
+	"proxy-ssl-verify-depth",
+	"proxy-timeout-budget",
+	"request-body-encoding",
+	"request-body-streaming",
+	"request-body-streaming-max-size",
+	"request-body-streaming-require-content-length",
+	"response-charset",
+	"response-redact-json-fields",
+	"rewrite-target",
+	"satisfy",
+	"secure-verify-ca-secret",
+	"server-alias",
+	"server-snippet",
+	"service-upstream",
+	"session-cookie-change-on-failure",
+	"session-cookie-expires",
+	"session-cookie-max-age",
+	"session-cookie-name",
+	"session-cookie-path",
+	"ssl-ciphers",
+	"ssl-passthrough",
+	"internal",
+	"ssl-pins",
+	"ssl-pins-override",
+	"ssl-redirect",
+	"ssl-redirect-code",
+	"ssl-redirect-exclude-paths",
+	"ssl-redirect-port",
+	"temporal-redirect",
+	"traffic-capture-args",
+	"traffic-capture-body",
+	"traffic-capture-headers",
+	"traffic-capture-sample-rate",
+	"traffic-capture-sink",
+	"trailing-slash",
+	"upstream-hash-by",
+	"upstream-hash-by-subset",
+	"upstream-hash-by-subset-size",
+	"upstream-host",
+	"upstream-vhost",
+	"use-port-in-redirects",
+	"use-regex",
+	"whitelist-source-range",
+	"x-forwarded-prefix",
+)
+
+// UnknownAnnotations returns the annotations of the Ingress that contain
+// the annotations prefix but are not read by any annotation parser
+func UnknownAnnotations(ing *networking.Ingress) []string {
+	prefix := fmt.Sprintf("%v/", parser.AnnotationsPrefix)
+
+	unknown := []string{}
+	for name := range ing.GetAnnotations() {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !knownAnnotations.Has(strings.TrimPrefix(name, prefix)) {
+			unknown = append(unknown, name)
+		}
+	}
+
+	sort.Strings(unknown)
+	return unknown
+}
+
+// ValidationError returns an error describing the unknown annotations and
+// the annotations with an invalid value of an Ingress, or nil if all the
+// annotations are valid
+func ValidationError(pia *Ingress) error {
+	reasons := []string{}
+
+	if len(pia.UnknownAnnotations) > 0 {
+		reasons = append(reasons, fmt.Sprintf("unknown annotations %v", strings.Join(pia.UnknownAnnotations, ", ")))
+	}
+
+	for _, invalid := range pia.InvalidAnnotations {
+		reasons = append(reasons, fmt.Sprintf("annotation %v contains an invalid value %q", invalid.Name, invalid.Value))
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("invalid annotations in Ingress %v/%v: %v", pia.Namespace, pia.Name, strings.Join(reasons, "; "))
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package annotations
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+)
+
+func TestUnknownAnnotations(t *testing.T) {
+	ing := buildIngress()
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix("proxy-body-size"): "1m",
+		parser.GetAnnotationWithPrefix("proxy-body-szie"): "1m",
+		parser.GetAnnotationWithPrefix("affinity"):        "cookie",
+		parser.GetAnnotationWithPrefix("rewrite-targett"): "/",
+		"kubernetes.io/ingress.class":                     "nginx",
+	})
+
+	expected := []string{
+		parser.GetAnnotationWithPrefix("proxy-body-szie"),
+		parser.GetAnnotationWithPrefix("rewrite-targett"),
+	}
+
+	unknown := UnknownAnnotations(ing)
+	if !reflect.DeepEqual(unknown, expected) {
+		t.Errorf("expected %v but %v was returned", expected, unknown)
+	}
+}
+
+func TestKnownAnnotationsContainsParsedAnnotations(t *testing.T) {
+	annotationRegex := regexp.MustCompile(`Get(String|Int|Bool)Annotation\("([^"]+)"`)
+
+	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range annotationRegex.FindAllStringSubmatch(string(content), -1) {
+			if !knownAnnotations.Has(m[2]) {
+				t.Errorf("annotation %v read in %v is not included in the known annotations", m[2], path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	ec := NewAnnotationExtractor(mockCfg{})
+	ing := buildIngress()
+
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix("proxy-body-size"): "1m",
+	})
+	if err := ValidationError(ec.Extract(ing)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	ing.SetAnnotations(map[string]string{
+		parser.GetAnnotationWithPrefix("proxy-body-szie"):       "1m",
+		parser.GetAnnotationWithPrefix("proxy-connect-timeout"): "abc",
+	})
+	err := ValidationError(ec.Extract(ing))
+	if err == nil {
+		t.Fatalf("expected an error but none returned")
+	}
+
+	for _, name := range []string{"proxy-body-szie", "proxy-connect-timeout"} {
+		if !strings.Contains(err.Error(), parser.GetAnnotationWithPrefix(name)) {
+			t.Errorf("expected error %q to contain the annotation %v", err, name)
+		}
+	}
+}
@@ -18,8 +18,10 @@ package parser
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	networking "k8s.io/api/networking/v1beta1"
 
@@ -36,6 +38,97 @@ type IngressAnnotation interface {
 	Parse(ing *networking.Ingress) (interface{}, error)
 }
 
+// InvalidAnnotation contains an annotation with a value that cannot be used
+type InvalidAnnotation struct {
+	// Name contains the name of the annotation including the prefix
+	Name string
+	// Value contains the invalid value
+	Value string
+	// Default contains the value used instead of the invalid one,
+	// empty if it is unknown
+	Default string
+}
+
+var (
+	trackedMu sync.Mutex
+	// trackedCond is signaled when an Ingress is no longer tracked
+	trackedCond = sync.NewCond(&trackedMu)
+	// tracked contains the invalid annotations read from the Ingresses
+	// passed to TrackInvalidAnnotations, by namespace/name. The Ingresses
+	// are removed by InvalidAnnotations at the end of each extraction.
+	tracked = map[string][]InvalidAnnotation{}
+)
+
+func trackedKey(ing *networking.Ingress) string {
+	return fmt.Sprintf("%v/%v", ing.Namespace, ing.Name)
+}
+
+// TrackInvalidAnnotations starts recording the annotations with an
+// invalid value read from the Ingress. The concurrent extractions of
+// the same Ingress, e.g. by the admission webhook, wait for each other.
+func TrackInvalidAnnotations(ing *networking.Ingress) {
+	trackedMu.Lock()
+	defer trackedMu.Unlock()
+
+	key := trackedKey(ing)
+	for {
+		if _, ok := tracked[key]; !ok {
+			break
+		}
+		trackedCond.Wait()
+	}
+
+	tracked[key] = []InvalidAnnotation{}
+}
+
+// RecordInvalidAnnotation records the annotation contained in an
+// InvalidContent error if the Ingress is being tracked
+func RecordInvalidAnnotation(ing *networking.Ingress, err error) {
+	ic, ok := err.(errors.InvalidContent)
+	if !ok || ic.Annotation == "" {
+		return
+	}
+
+	name := ic.Annotation
+	if !strings.HasPrefix(name, fmt.Sprintf("%v/", AnnotationsPrefix)) {
+		name = GetAnnotationWithPrefix(name)
+	}
+
+	trackedMu.Lock()
+	defer trackedMu.Unlock()
+
+	key := trackedKey(ing)
+	invalid, ok := tracked[key]
+	if !ok {
+		return
+	}
+
+	for _, i := range invalid {
+		if i.Name == name {
+			return
+		}
+	}
+
+	tracked[key] = append(invalid, InvalidAnnotation{Name: name, Value: ic.Value})
+}
+
+// InvalidAnnotations stops tracking the Ingress and returns the
+// annotations with an invalid value read since TrackInvalidAnnotations
+func InvalidAnnotations(ing *networking.Ingress) []InvalidAnnotation {
+	trackedMu.Lock()
+	key := trackedKey(ing)
+	invalid := tracked[key]
+	delete(tracked, key)
+	trackedCond.Broadcast()
+	trackedMu.Unlock()
+
+	sort.Slice(invalid, func(i, j int) bool {
+		return invalid[i].Name < invalid[j].Name
+	})
+
+	return invalid
+}
+
 type ingAnnotations map[string]string
 
 func (a ingAnnotations) parseBool(name string) (bool, error) {
@@ -93,7 +186,9 @@ func GetBoolAnnotation(name string, ing *networking.Ingress) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return ingAnnotations(ing.GetAnnotations()).parseBool(v)
+	b, err := ingAnnotations(ing.GetAnnotations()).parseBool(v)
+	RecordInvalidAnnotation(ing, err)
+	return b, err
 }
 
 // GetStringAnnotation extracts a string from an Ingress annotation
@@ -104,7 +199,9 @@ func GetStringAnnotation(name string, ing *networking.Ingress) (string, error) {
 		return "", err
 	}
 
-	return ingAnnotations(ing.GetAnnotations()).parseString(v)
+	s, err := ingAnnotations(ing.GetAnnotations()).parseString(v)
+	RecordInvalidAnnotation(ing, err)
+	return s, err
 }
 
 // GetIntAnnotation extracts an int from an Ingress annotation
@@ -114,7 +211,9 @@ func GetIntAnnotation(name string, ing *networking.Ingress) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	return ingAnnotations(ing.GetAnnotations()).parseInt(v)
+	i, err := ingAnnotations(ing.GetAnnotations()).parseInt(v)
+	RecordInvalidAnnotation(ing, err)
+	return i, err
 }
 
 // GetAnnotationWithPrefix returns the prefix of ingress annotations
@@ -122,6 +221,50 @@ func GetAnnotationWithPrefix(suffix string) string {
 	return fmt.Sprintf("%v/%v", AnnotationsPrefix, suffix)
 }
 
+// MergeAnnotations returns the annotations used to configure an Ingress.
+// The defaults (annotation names without prefix, i.e. proxy-body-size) are
+// overridden by the annotations of the Ingress. If the list of allowed
+// annotations is not empty, only the annotations (and defaults) contained
+// in the list are used, so the value from the configuration ConfigMap
+// applies for any other annotation. The names of the ignored annotations
+// are returned as the second value.
+func MergeAnnotations(annotations, defaults map[string]string, allowed []string) (map[string]string, []string) {
+	isAllowed := func(name string) bool {
+		if len(allowed) == 0 {
+			return true
+		}
+		for _, a := range allowed {
+			if a == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	prefix := fmt.Sprintf("%v/", AnnotationsPrefix)
+	merged := map[string]string{}
+	ignored := []string{}
+
+	for name, value := range defaults {
+		if !isAllowed(name) {
+			ignored = append(ignored, name)
+			continue
+		}
+		merged[GetAnnotationWithPrefix(name)] = value
+	}
+
+	for name, value := range annotations {
+		if strings.HasPrefix(name, prefix) && !isAllowed(strings.TrimPrefix(name, prefix)) {
+			ignored = append(ignored, name)
+			continue
+		}
+		merged[name] = value
+	}
+
+	sort.Strings(ignored)
+	return merged, ignored
+}
+
 func normalizeString(input string) string {
 	trimmedContent := []string{}
 	for _, line := range strings.Split(input, "\n") {
@@ -17,11 +17,15 @@ limitations under the License.
 package parser
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	api "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1beta1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/errors"
 )
 
 func buildIngress() *networking.Ingress {
@@ -162,3 +166,78 @@ func TestGetIntAnnotation(t *testing.T) {
 		delete(data, test.field)
 	}
 }
+
+func TestMergeAnnotations(t *testing.T) {
+	bodySize := GetAnnotationWithPrefix("proxy-body-size")
+	rewrite := GetAnnotationWithPrefix("rewrite-target")
+
+	tests := []struct {
+		title       string
+		annotations map[string]string
+		defaults    map[string]string
+		allowed     []string
+		expected    map[string]string
+		ignored     []string
+	}{
+		{"no annotations", nil, nil, nil, map[string]string{}, []string{}},
+		{"only defaults",
+			nil,
+			map[string]string{"proxy-body-size": "8m"},
+			nil,
+			map[string]string{bodySize: "8m"},
+			[]string{}},
+		{"annotations override defaults",
+			map[string]string{bodySize: "1m", "kubernetes.io/ingress.class": "nginx"},
+			map[string]string{"proxy-body-size": "8m", "rewrite-target": "/"},
+			nil,
+			map[string]string{bodySize: "1m", rewrite: "/", "kubernetes.io/ingress.class": "nginx"},
+			[]string{}},
+		{"only allowed annotations are used",
+			map[string]string{bodySize: "1m", rewrite: "/other", "kubernetes.io/ingress.class": "nginx"},
+			map[string]string{"proxy-body-size": "8m", "ssl-redirect": "false"},
+			[]string{"rewrite-target"},
+			map[string]string{rewrite: "/other", "kubernetes.io/ingress.class": "nginx"},
+			[]string{bodySize, "proxy-body-size", "ssl-redirect"}},
+	}
+
+	for _, test := range tests {
+		merged, ignored := MergeAnnotations(test.annotations, test.defaults, test.allowed)
+		if !reflect.DeepEqual(merged, test.expected) {
+			t.Errorf("%v: expected %v but %v was returned", test.title, test.expected, merged)
+		}
+		if !reflect.DeepEqual(ignored, test.ignored) {
+			t.Errorf("%v: expected %v to be ignored but %v was returned", test.title, test.ignored, ignored)
+		}
+	}
+}
+
+func TestTrackInvalidAnnotations(t *testing.T) {
+	ing := buildIngress()
+	TrackInvalidAnnotations(ing)
+
+	// the Ingress is tracked by namespace/name, whatever the copy
+	copied := ing.DeepCopy()
+	RecordInvalidAnnotation(copied, errors.NewInvalidAnnotationContent("ssl-passthrough", "yes"))
+
+	// a concurrent extraction of the same Ingress waits for the first one
+	done := make(chan []InvalidAnnotation)
+	go func() {
+		TrackInvalidAnnotations(copied)
+		done <- InvalidAnnotations(copied)
+	}()
+
+	select {
+	case <-done:
+		t.Fatalf("expected the concurrent extraction to wait")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	expected := []InvalidAnnotation{{Name: GetAnnotationWithPrefix("ssl-passthrough"), Value: "yes"}}
+	if invalid := InvalidAnnotations(ing); !reflect.DeepEqual(invalid, expected) {
+		t.Errorf("expected %v but %v was returned", expected, invalid)
+	}
+
+	if invalid := <-done; len(invalid) != 0 {
+		t.Errorf("expected no invalid annotations but %v was returned", invalid)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plainhttp
+
+import (
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const (
+	// ActionRedirect redirects the plain HTTP requests to HTTPS on the same
+	// host and port, keeping the path of the request
+	ActionRedirect = "redirect"
+	// ActionReject closes the connection without any response
+	ActionReject = "reject"
+	// ActionPage serves the page of the default backend with the code 497
+	ActionPage = "page"
+
+	defaultRedirectCode = 308
+)
+
+// Config describes what happens when a plain HTTP request is sent to the
+// HTTPS port of a server, NGINX returns a 400 by default
+type Config struct {
+	// Action is redirect, reject or page, empty to keep the default behavior
+	// +optional
+	Action string `json:"action,omitempty"`
+	// RedirectCode is the status code of the redirects to HTTPS
+	// +optional
+	RedirectCode int `json:"redirectCode,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Action != c2.Action {
+		return false
+	}
+	if c1.RedirectCode != c2.RedirectCode {
+		return false
+	}
+
+	return true
+}
+
+type plainHTTP struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new plain HTTP annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return plainHTTP{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to
+// configure the response to the plain HTTP requests sent to the HTTPS port
+func (a plainHTTP) Parse(ing *networking.Ingress) (interface{}, error) {
+	action, err := parser.GetStringAnnotation("plain-http-action", ing)
+	if err != nil {
+		return &Config{}, nil
+	}
+
+	action = strings.ToLower(strings.TrimSpace(action))
+	switch action {
+	case ActionRedirect:
+	case ActionReject, ActionPage:
+		return &Config{Action: action}, nil
+	default:
+		return &Config{}, ing_errors.NewInvalidAnnotationContent("plain-http-action", action)
+	}
+
+	code, err := parser.GetIntAnnotation("plain-http-redirect-code", ing)
+	if err != nil {
+		code = defaultRedirectCode
+	}
+
+	switch code {
+	case 301, 302, 303, 307, 308:
+	default:
+		return &Config{}, ing_errors.NewInvalidAnnotationContent("plain-http-redirect-code", code)
+	}
+
+	return &Config{Action: action, RedirectCode: code}, nil
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podrouting
+
+import (
+	"regexp"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// nginxVariableRegex matches an NGINX variable like $http_x_pod or a
+// regex capture group like $1
+var nginxVariableRegex = regexp.MustCompile(`^\$[A-Za-z0-9_]+$`)
+
+type podRouting struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new pod routing annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return podRouting{r}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to route a request to a single pod of the backend. The value
+// of the annotation is an NGINX variable that must contain the pod
+// name (i.e. web-2) or the ordinal of a StatefulSet pod (i.e. 2).
+func (a podRouting) Parse(ing *networking.Ingress) (interface{}, error) {
+	routingBy, err := parser.GetStringAnnotation("pod-routing-by", ing)
+	if err != nil {
+		return "", err
+	}
+
+	if !nginxVariableRegex.MatchString(routingBy) {
+		return "", ing_errors.NewInvalidAnnotationContent("pod-routing-by", routingBy)
+	}
+
+	return routingBy, nil
+}
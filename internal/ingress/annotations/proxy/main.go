@@ -40,6 +40,9 @@ type Config struct {
 	ProxyRedirectTo     string `json:"proxyRedirectTo"`
 	RequestBuffering    string `json:"requestBuffering"`
 	ProxyBuffering      string `json:"proxyBuffering"`
+	// TimeoutBudget is the total time in seconds allowed to serve a request,
+	// forwarded to the backend as a deadline. Zero when disabled
+	TimeoutBudget int `json:"timeoutBudget"`
 }
 
 // Equal tests for equality between two Configuration types
@@ -95,6 +98,9 @@ func (l1 *Config) Equal(l2 *Config) bool {
 	if l1.ProxyBuffering != l2.ProxyBuffering {
 		return false
 	}
+	if l1.TimeoutBudget != l2.TimeoutBudget {
+		return false
+	}
 
 	return true
 }
@@ -191,5 +197,24 @@ func (a proxy) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.ProxyBuffering = defBackend.ProxyBuffering
 	}
 
+	// the timeouts of NGINX are measured between two operations, none of
+	// them can exceed the budget and the retries stop when it is spent
+	budget, err := parser.GetIntAnnotation("proxy-timeout-budget", ing)
+	if err == nil && budget > 0 {
+		config.TimeoutBudget = budget
+		config.ConnectTimeout = withinBudget(config.ConnectTimeout, budget)
+		config.SendTimeout = withinBudget(config.SendTimeout, budget)
+		config.ReadTimeout = withinBudget(config.ReadTimeout, budget)
+		config.NextUpstreamTimeout = withinBudget(config.NextUpstreamTimeout, budget)
+	}
+
 	return config, nil
 }
+
+// withinBudget returns the timeout bounded by the budget, 0 means no timeout
+func withinBudget(timeout, budget int) int {
+	if timeout <= 0 || timeout > budget {
+		return budget
+	}
+	return timeout
+}
@@ -189,3 +189,45 @@ func TestProxyWithNoAnnotation(t *testing.T) {
 		t.Errorf("expected on as request-buffering but returned %v", p.RequestBuffering)
 	}
 }
+
+func TestProxyWithTimeoutBudget(t *testing.T) {
+	ing := buildIngress()
+
+	data := map[string]string{}
+	data[parser.GetAnnotationWithPrefix("proxy-connect-timeout")] = "5"
+	data[parser.GetAnnotationWithPrefix("proxy-timeout-budget")] = "12"
+	ing.SetAnnotations(data)
+
+	i, err := NewParser(mockBackend{}).Parse(ing)
+	if err != nil {
+		t.Fatalf("unexpected error parsing a valid")
+	}
+	p, ok := i.(*Config)
+	if !ok {
+		t.Fatalf("expected a Config type")
+	}
+	if p.TimeoutBudget != 12 {
+		t.Errorf("expected 12 as timeout-budget but returned %v", p.TimeoutBudget)
+	}
+	if p.ConnectTimeout != 5 {
+		t.Errorf("expected 5 as connect-timeout but returned %v", p.ConnectTimeout)
+	}
+	if p.SendTimeout != 12 {
+		t.Errorf("expected 12 as send-timeout but returned %v", p.SendTimeout)
+	}
+	if p.ReadTimeout != 12 {
+		t.Errorf("expected 12 as read-timeout but returned %v", p.ReadTimeout)
+	}
+	if p.NextUpstreamTimeout != 12 {
+		t.Errorf("expected 12 as next-upstream-timeout but returned %v", p.NextUpstreamTimeout)
+	}
+
+	data[parser.GetAnnotationWithPrefix("proxy-timeout-budget")] = "-1"
+	ing.SetAnnotations(data)
+
+	i, _ = NewParser(mockBackend{}).Parse(ing)
+	p = i.(*Config)
+	if p.TimeoutBudget != 0 || p.ReadTimeout != 20 {
+		t.Errorf("expected an invalid timeout-budget to be ignored but returned %+v", p)
+	}
+}
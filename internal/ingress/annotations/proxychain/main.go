@@ -0,0 +1,155 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxychain
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+var hostRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*$`)
+
+// Config describes the upstream proxy used to reach the backend of a location
+type Config struct {
+	// Address is the host:port of the upstream proxy
+	Address string `json:"address"`
+	// Secret is the namespace/name of the secret containing the proxy credentials
+	Secret string `json:"secret"`
+	// Credentials contains the base64 encoded username:password sent to the proxy
+	Credentials string `json:"-"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Address != c2.Address {
+		return false
+	}
+	if c1.Secret != c2.Secret {
+		return false
+	}
+	if c1.Credentials != c2.Credentials {
+		return false
+	}
+
+	return true
+}
+
+type proxyChain struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new proxy chain annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return proxyChain{r}
+}
+
+// Parse parses the annotations contained in the ingress
+// rule used to forward the requests through an upstream proxy
+func (a proxyChain) Parse(ing *networking.Ingress) (interface{}, error) {
+	address, err := parser.GetStringAnnotation("proxy-chain-address", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	if !validAddress(address) {
+		return &Config{}, ing_errors.NewLocationDenied(fmt.Sprintf("invalid proxy chain address %v (expected host:port)", address))
+	}
+
+	config := &Config{
+		Address: address,
+	}
+
+	s, err := parser.GetStringAnnotation("proxy-chain-secret", ing)
+	if err != nil {
+		if ing_errors.IsMissingAnnotations(err) {
+			return config, nil
+		}
+
+		return &Config{}, ing_errors.LocationDenied{
+			Reason: errors.Wrap(err, "error reading secret name from annotation"),
+		}
+	}
+
+	sns, sname, err := cache.SplitMetaNamespaceKey(s)
+	if err != nil {
+		return &Config{}, ing_errors.LocationDenied{
+			Reason: errors.Wrap(err, "error reading secret name from annotation"),
+		}
+	}
+
+	if sns == "" {
+		sns = ing.Namespace
+	}
+
+	name := fmt.Sprintf("%v/%v", sns, sname)
+	secret, err := a.r.GetSecret(name)
+	if err != nil {
+		return &Config{}, ing_errors.LocationDenied{
+			Reason: errors.Wrapf(err, "unexpected error reading secret %v", name),
+		}
+	}
+
+	username, ok := secret.Data["username"]
+	if !ok || len(username) == 0 {
+		return &Config{}, ing_errors.LocationDenied{
+			Reason: errors.Errorf("the secret %v does not contain a key with value username", name),
+		}
+	}
+
+	password := secret.Data["password"]
+
+	config.Secret = name
+	config.Credentials = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", username, password)))
+
+	return config, nil
+}
+
+// validAddress checks the address is a host or IP address followed by a port
+func validAddress(address string) bool {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return false
+	}
+
+	if net.ParseIP(host) != nil {
+		return true
+	}
+
+	return hostRegex.MatchString(host)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxychain
+
+import (
+	"fmt"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+type mockSecret struct {
+	resolver.Mock
+}
+
+func (m mockSecret) GetSecret(name string) (*api.Secret, error) {
+	switch name {
+	case "default/proxy-credentials":
+		return &api.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Namespace: api.NamespaceDefault,
+				Name:      "proxy-credentials",
+			},
+			Data: map[string][]byte{
+				"username": []byte("user"),
+				"password": []byte("pass"),
+			},
+		}, nil
+	case "default/empty":
+		return &api.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Namespace: api.NamespaceDefault,
+				Name:      "empty",
+			},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("secret %v not found", name)
+}
+
+func TestParse(t *testing.T) {
+	address := parser.GetAnnotationWithPrefix("proxy-chain-address")
+	secret := parser.GetAnnotationWithPrefix("proxy-chain-secret")
+
+	ap := NewParser(mockSecret{})
+	if ap == nil {
+		t.Fatalf("expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		expectErr   bool
+	}{
+		{map[string]string{address: "egress.proxy.svc:3128"}, &Config{Address: "egress.proxy.svc:3128"}, false},
+		{map[string]string{address: "10.0.0.1:8080"}, &Config{Address: "10.0.0.1:8080"}, false},
+		{map[string]string{address: "[fd00::1]:8080"}, &Config{Address: "[fd00::1]:8080"}, false},
+		{map[string]string{address: "egress:3128", secret: "proxy-credentials"},
+			&Config{Address: "egress:3128", Secret: "default/proxy-credentials", Credentials: "dXNlcjpwYXNz"}, false},
+		{map[string]string{address: "egress:3128", secret: "default/proxy-credentials"},
+			&Config{Address: "egress:3128", Secret: "default/proxy-credentials", Credentials: "dXNlcjpwYXNz"}, false},
+		{map[string]string{address: "egress:3128", secret: "empty"}, &Config{}, true},
+		{map[string]string{address: "egress:3128", secret: "missing"}, &Config{}, true},
+		{map[string]string{address: "egress"}, &Config{}, true},
+		{map[string]string{address: "egress:0"}, &Config{}, true},
+		{map[string]string{address: "egress:http"}, &Config{}, true},
+		{map[string]string{address: "egress;proxy:3128"}, &Config{}, true},
+		{map[string]string{secret: "proxy-credentials"}, &Config{}, true},
+		{map[string]string{}, &Config{}, true},
+		{nil, &Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := ap.Parse(ing)
+		if testCase.expectErr && err == nil {
+			t.Errorf("expected an error but none returned, annotations: %s", testCase.annotations)
+		}
+		if !testCase.expectErr && err != nil {
+			t.Errorf("unexpected error %v, annotations: %s", err, testCase.annotations)
+		}
+
+		config, ok := result.(*Config)
+		if !ok {
+			t.Fatalf("expected a Config type but %T was returned", result)
+		}
+		if !config.Equal(testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, config, testCase.annotations)
+		}
+	}
+}
@@ -35,6 +35,10 @@ type Config struct {
 	URL       string `json:"url"`
 	Code      int    `json:"code"`
 	FromToWWW bool   `json:"fromToWWW"`
+	// FromAliases contains the list of hosts redirected to the host of the rule
+	FromAliases []string `json:"fromAliases,omitempty"`
+	// FromAliasesCode is the status code used in the redirect from the aliases
+	FromAliasesCode int `json:"fromAliasesCode,omitempty"`
 }
 
 type redirect struct {
@@ -53,6 +57,11 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 func (r redirect) Parse(ing *networking.Ingress) (interface{}, error) {
 	r3w, _ := parser.GetBoolAnnotation("from-to-www-redirect", ing)
 
+	aliases, aliasesCode, err := parseAliasRedirect(ing)
+	if err != nil {
+		return nil, err
+	}
+
 	tr, err := parser.GetStringAnnotation("temporal-redirect", ing)
 	if err != nil && !errors.IsMissingAnnotations(err) {
 		return nil, err
@@ -64,9 +73,11 @@ func (r redirect) Parse(ing *networking.Ingress) (interface{}, error) {
 		}
 
 		return &Config{
-			URL:       tr,
-			Code:      http.StatusFound,
-			FromToWWW: r3w,
+			URL:             tr,
+			Code:            http.StatusFound,
+			FromToWWW:       r3w,
+			FromAliases:     aliases,
+			FromAliasesCode: aliasesCode,
 		}, nil
 	}
 
@@ -84,17 +95,56 @@ func (r redirect) Parse(ing *networking.Ingress) (interface{}, error) {
 		prc = defaultPermanentRedirectCode
 	}
 
-	if pr != "" || r3w {
+	if pr != "" || r3w || len(aliases) > 0 {
 		return &Config{
-			URL:       pr,
-			Code:      prc,
-			FromToWWW: r3w,
+			URL:             pr,
+			Code:            prc,
+			FromToWWW:       r3w,
+			FromAliases:     aliases,
+			FromAliasesCode: aliasesCode,
 		}, nil
 	}
 
 	return nil, errors.ErrMissingAnnotations
 }
 
+// parseAliasRedirect returns the list of hosts to redirect from, read
+// from the alias-redirect annotation, and the status code to use
+func parseAliasRedirect(ing *networking.Ingress) ([]string, int, error) {
+	ar, err := parser.GetStringAnnotation("alias-redirect", ing)
+	if err != nil {
+		if errors.IsMissingAnnotations(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+
+	aliases := []string{}
+	for _, alias := range strings.Split(ar, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			continue
+		}
+
+		if strings.ContainsAny(alias, "/:* ") {
+			return nil, 0, errors.NewInvalidAnnotationContent("alias-redirect", ar)
+		}
+
+		aliases = append(aliases, alias)
+	}
+
+	arc, err := parser.GetIntAnnotation("alias-redirect-code", ing)
+	if err != nil && !errors.IsMissingAnnotations(err) {
+		return nil, 0, err
+	}
+
+	if arc < http.StatusMultipleChoices || arc > http.StatusPermanentRedirect {
+		arc = defaultPermanentRedirectCode
+	}
+
+	return aliases, arc, nil
+}
+
 // Equal tests for equality between two Redirect types
 func (r1 *Config) Equal(r2 *Config) bool {
 	if r1 == r2 {
@@ -112,6 +162,17 @@ func (r1 *Config) Equal(r2 *Config) bool {
 	if r1.FromToWWW != r2.FromToWWW {
 		return false
 	}
+	if r1.FromAliasesCode != r2.FromAliasesCode {
+		return false
+	}
+	if len(r1.FromAliases) != len(r2.FromAliases) {
+		return false
+	}
+	for i, alias := range r1.FromAliases {
+		if alias != r2.FromAliases[i] {
+			return false
+		}
+	}
 	return true
 }
 
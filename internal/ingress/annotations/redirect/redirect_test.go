@@ -135,6 +135,63 @@ func TestTemporalRedirect(t *testing.T) {
 	}
 }
 
+func TestAliasRedirect(t *testing.T) {
+	rp := NewParser(resolver.Mock{})
+	if rp == nil {
+		t.Fatalf("Expected a parser.IngressAnnotation but returned nil")
+	}
+
+	testCases := map[string]struct {
+		aliases       string
+		code          string
+		expectAliases []string
+		expectCode    int
+		expectErr     bool
+	}{
+		"default code":     {"old.example.com, example.net", "", []string{"old.example.com", "example.net"}, defaultPermanentRedirectCode, false},
+		"custom code":      {"old.example.com", "308", []string{"old.example.com"}, http.StatusPermanentRedirect, false},
+		"invalid code":     {"old.example.com", "418", []string{"old.example.com"}, defaultPermanentRedirectCode, false},
+		"invalid alias":    {"old.example.com/path", "", nil, 0, true},
+		"wildcard alias":   {"*.example.com", "", nil, 0, true},
+		"alias with ports": {"old.example.com:8080", "", nil, 0, true},
+	}
+
+	for n, tc := range testCases {
+		t.Run(n, func(t *testing.T) {
+			ing := new(networking.Ingress)
+
+			data := make(map[string]string, 2)
+			data[parser.GetAnnotationWithPrefix("alias-redirect")] = tc.aliases
+			if tc.code != "" {
+				data[parser.GetAnnotationWithPrefix("alias-redirect-code")] = tc.code
+			}
+			ing.SetAnnotations(data)
+
+			i, err := rp.Parse(ing)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected an error parsing %v", tc.aliases)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error with ingress: %v", err)
+			}
+
+			redirect, ok := i.(*Config)
+			if !ok {
+				t.Fatalf("Expected a Redirect type")
+			}
+			if !reflect.DeepEqual(redirect.FromAliases, tc.expectAliases) {
+				t.Errorf("Expected %v as aliases but returned %v", tc.expectAliases, redirect.FromAliases)
+			}
+			if redirect.FromAliasesCode != tc.expectCode {
+				t.Errorf("Expected %v as alias redirect code but returned %v", tc.expectCode, redirect.FromAliasesCode)
+			}
+		})
+	}
+}
+
 func TestIsValidURL(t *testing.T) {
 
 	invalid := "ok.com"
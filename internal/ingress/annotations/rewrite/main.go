@@ -17,9 +17,13 @@ limitations under the License.
 package rewrite
 
 import (
+	"strconv"
+	"strings"
+
 	networking "k8s.io/api/networking/v1beta1"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
@@ -31,6 +35,18 @@ type Config struct {
 	SSLRedirect bool `json:"sslRedirect"`
 	// ForceSSLRedirect indicates if the location section is accessible SSL only
 	ForceSSLRedirect bool `json:"forceSSLRedirect"`
+	// SSLRedirectCode is the status code of the redirects to HTTPS, the
+	// http-redirect-code of the configuration is used when it is 0
+	// +optional
+	SSLRedirectCode int `json:"sslRedirectCode,omitempty"`
+	// SSLRedirectExcludePaths contains the prefixes of the paths of the
+	// requests not redirected to HTTPS
+	// +optional
+	SSLRedirectExcludePaths []string `json:"sslRedirectExcludePaths,omitempty"`
+	// SSLRedirectPort is the HTTPS port used in the redirects, the port
+	// exposed to the clients when the controller is behind a NAT
+	// +optional
+	SSLRedirectPort int `json:"sslRedirectPort,omitempty"`
 	// AppRoot defines the Application Root that the Controller must redirect if it's in '/' context
 	AppRoot string `json:"appRoot"`
 	// UseRegex indicates whether or not the locations use regex paths
@@ -54,6 +70,20 @@ func (r1 *Config) Equal(r2 *Config) bool {
 	if r1.ForceSSLRedirect != r2.ForceSSLRedirect {
 		return false
 	}
+	if r1.SSLRedirectCode != r2.SSLRedirectCode {
+		return false
+	}
+	if len(r1.SSLRedirectExcludePaths) != len(r2.SSLRedirectExcludePaths) {
+		return false
+	}
+	for i, path := range r1.SSLRedirectExcludePaths {
+		if path != r2.SSLRedirectExcludePaths[i] {
+			return false
+		}
+	}
+	if r1.SSLRedirectPort != r2.SSLRedirectPort {
+		return false
+	}
 	if r1.AppRoot != r2.AppRoot {
 		return false
 	}
@@ -90,8 +120,45 @@ func (a rewrite) Parse(ing *networking.Ingress) (interface{}, error) {
 		config.ForceSSLRedirect = a.r.GetDefaultBackend().ForceSSLRedirect
 	}
 
+	config.SSLRedirectCode, err = parser.GetIntAnnotation("ssl-redirect-code", ing)
+	if err == nil && !isRedirectCode(config.SSLRedirectCode) {
+		parser.RecordInvalidAnnotation(ing, ing_errors.NewInvalidAnnotationContent("ssl-redirect-code", strconv.Itoa(config.SSLRedirectCode)))
+		config.SSLRedirectCode = 0
+	}
+
+	excludePaths, _ := parser.GetStringAnnotation("ssl-redirect-exclude-paths", ing)
+	for _, path := range strings.Split(excludePaths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if !strings.HasPrefix(path, "/") {
+			parser.RecordInvalidAnnotation(ing, ing_errors.NewInvalidAnnotationContent("ssl-redirect-exclude-paths", path))
+			continue
+		}
+		config.SSLRedirectExcludePaths = append(config.SSLRedirectExcludePaths, path)
+	}
+
+	config.SSLRedirectPort, err = parser.GetIntAnnotation("ssl-redirect-port", ing)
+	if err != nil {
+		config.SSLRedirectPort = a.r.GetDefaultBackend().SSLRedirectPort
+	} else if config.SSLRedirectPort <= 0 || config.SSLRedirectPort > 65535 {
+		parser.RecordInvalidAnnotation(ing, ing_errors.NewInvalidAnnotationContent("ssl-redirect-port", strconv.Itoa(config.SSLRedirectPort)))
+		config.SSLRedirectPort = a.r.GetDefaultBackend().SSLRedirectPort
+	}
+
 	config.AppRoot, _ = parser.GetStringAnnotation("app-root", ing)
 	config.UseRegex, _ = parser.GetBoolAnnotation("use-regex", ing)
 
 	return config, nil
 }
+
+// isRedirectCode returns true for the status codes of the redirects to HTTPS
+func isRedirectCode(code int) bool {
+	switch code {
+	case 301, 302, 307, 308:
+		return true
+	}
+
+	return false
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package rewrite
 
 import (
+	"reflect"
 	"testing"
 
 	api "k8s.io/api/core/v1"
@@ -70,11 +71,12 @@ func buildIngress() *networking.Ingress {
 
 type mockBackend struct {
 	resolver.Mock
-	redirect bool
+	redirect     bool
+	redirectPort int
 }
 
 func (m mockBackend) GetDefaultBackend() defaults.Backend {
-	return defaults.Backend{SSLRedirect: m.redirect}
+	return defaults.Backend{SSLRedirect: m.redirect, SSLRedirectPort: m.redirectPort}
 }
 
 func TestWithoutAnnotations(t *testing.T) {
@@ -162,6 +164,46 @@ func TestForceSSLRedirect(t *testing.T) {
 		t.Errorf("Expected true but returned false")
 	}
 }
+
+func TestSSLRedirectOptions(t *testing.T) {
+	code := parser.GetAnnotationWithPrefix("ssl-redirect-code")
+	excludePaths := parser.GetAnnotationWithPrefix("ssl-redirect-exclude-paths")
+	port := parser.GetAnnotationWithPrefix("ssl-redirect-port")
+
+	testCases := []struct {
+		annotations  map[string]string
+		code         int
+		excludePaths []string
+		port         int
+	}{
+		{map[string]string{}, 0, nil, 8443},
+		{map[string]string{code: "301", port: "443"}, 301, nil, 443},
+		{map[string]string{code: "303", port: "0"}, 0, nil, 8443},
+		{map[string]string{excludePaths: "/.well-known/acme-challenge/, /healthz ,"}, 0, []string{"/.well-known/acme-challenge/", "/healthz"}, 8443},
+		{map[string]string{excludePaths: "healthz,/status", port: "70000"}, 0, []string{"/status"}, 8443},
+	}
+
+	ing := buildIngress()
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+
+		i, _ := NewParser(mockBackend{redirectPort: 8443}).Parse(ing)
+		redirect, ok := i.(*Config)
+		if !ok {
+			t.Fatalf("expected a Redirect type")
+		}
+		if redirect.SSLRedirectCode != testCase.code {
+			t.Errorf("expected %v as redirect code but returned %v, annotations: %s", testCase.code, redirect.SSLRedirectCode, testCase.annotations)
+		}
+		if !reflect.DeepEqual(redirect.SSLRedirectExcludePaths, testCase.excludePaths) {
+			t.Errorf("expected %v as excluded paths but returned %v, annotations: %s", testCase.excludePaths, redirect.SSLRedirectExcludePaths, testCase.annotations)
+		}
+		if redirect.SSLRedirectPort != testCase.port {
+			t.Errorf("expected %v as redirect port but returned %v, annotations: %s", testCase.port, redirect.SSLRedirectPort, testCase.annotations)
+		}
+	}
+}
+
 func TestAppRoot(t *testing.T) {
 	ing := buildIngress()
 
@@ -18,17 +18,54 @@ package secureupstream
 
 import (
 	"fmt"
+	"regexp"
 
 	"github.com/pkg/errors"
 	networking "k8s.io/api/networking/v1beta1"
 
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/k8s"
 )
 
+const defaultVerifyDepth = 1
+
+var serverNameRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*$`)
+
 // Config describes SSL backend configuration
 type Config struct {
 	CACert resolver.AuthSSLCert `json:"caCert"`
+	// Verify enables the verification of the certificate presented by the upstream servers
+	Verify bool `json:"verify"`
+	// VerifyDepth sets the maximum length of the upstream certificate chain
+	VerifyDepth int `json:"verifyDepth"`
+	// ServerName is the name sent using SNI and used to verify the upstream certificate
+	ServerName string `json:"serverName"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if !(&c1.CACert).Equal(&c2.CACert) {
+		return false
+	}
+	if c1.Verify != c2.Verify {
+		return false
+	}
+	if c1.VerifyDepth != c2.VerifyDepth {
+		return false
+	}
+	if c1.ServerName != c2.ServerName {
+		return false
+	}
+
+	return true
 }
 
 type su struct {
@@ -41,29 +78,77 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 }
 
 // Parse parses the annotations contained in the ingress
-// rule used to indicate if the upstream servers should use SSL
+// rule used to indicate if the upstream servers should use SSL.
+// When a CA is configured the certificate of the upstream servers is
+// verified and the location is denied if the CA cannot be obtained.
 func (a su) Parse(ing *networking.Ingress) (interface{}, error) {
 	bp, _ := parser.GetStringAnnotation("backend-protocol", ing)
 	ca, _ := parser.GetStringAnnotation("secure-verify-ca-secret", ing)
+	caSecret, _ := parser.GetStringAnnotation("proxy-ssl-secret", ing)
+	caConfigMap, _ := parser.GetStringAnnotation("proxy-ssl-ca-configmap", ing)
 	secure := &Config{
 		CACert: resolver.AuthSSLCert{},
 	}
 
-	if (bp != "HTTPS" && bp != "GRPCS") && ca != "" {
+	hasCA := ca != "" || caSecret != "" || caConfigMap != ""
+	if (bp != "HTTPS" && bp != "GRPCS") && hasCA {
 		return secure,
-			errors.Errorf("trying to use CA from secret %v/%v on a non secure backend", ing.Namespace, ca)
+			errors.Errorf("trying to use a CA in Ingress %v/%v on a non secure backend", ing.Namespace, ing.Name)
 	}
-	if ca == "" {
-		return secure, nil
+
+	if caSecret != "" && caConfigMap != "" {
+		return secure, ing_errors.NewLocationDenied("only one of proxy-ssl-secret and proxy-ssl-ca-configmap can be set")
 	}
-	caCert, err := a.r.GetAuthCertificate(fmt.Sprintf("%v/%v", ing.Namespace, ca))
+
+	serverName, err := parser.GetStringAnnotation("proxy-ssl-name", ing)
+	if err == nil {
+		if !serverNameRegex.MatchString(serverName) {
+			return secure, ing_errors.NewLocationDenied(fmt.Sprintf("invalid proxy-ssl-name %q", serverName))
+		}
+		secure.ServerName = serverName
+	}
+
+	secure.VerifyDepth, err = parser.GetIntAnnotation("proxy-ssl-verify-depth", ing)
+	if err != nil || secure.VerifyDepth <= 0 {
+		secure.VerifyDepth = defaultVerifyDepth
+	}
+
+	verify, err := parser.GetBoolAnnotation("proxy-ssl-verify", ing)
 	if err != nil {
-		return secure, errors.Wrap(err, "error obtaining certificate")
+		verify = hasCA
 	}
-	if caCert == nil {
+
+	if !hasCA {
+		if verify {
+			return secure, ing_errors.NewLocationDenied("proxy-ssl-verify requires a CA defined with proxy-ssl-secret or proxy-ssl-ca-configmap")
+		}
 		return secure, nil
 	}
-	return &Config{
-		CACert: *caCert,
-	}, nil
+
+	var caCert *resolver.AuthSSLCert
+	switch {
+	case caConfigMap != "":
+		if _, _, err := k8s.ParseNameNS(caConfigMap); err != nil {
+			return secure, ing_errors.NewLocationDenied(err.Error())
+		}
+		caCert, err = a.r.GetConfigMapCACertificate(caConfigMap)
+	case caSecret != "":
+		if _, _, err := k8s.ParseNameNS(caSecret); err != nil {
+			return secure, ing_errors.NewLocationDenied(err.Error())
+		}
+		caCert, err = a.r.GetAuthCertificate(caSecret)
+	default:
+		caCert, err = a.r.GetAuthCertificate(fmt.Sprintf("%v/%v", ing.Namespace, ca))
+	}
+	if err != nil {
+		return secure, ing_errors.LocationDenied{Reason: errors.Wrap(err, "error obtaining certificate")}
+	}
+	if caCert == nil || caCert.CAFileName == "" {
+		return secure, ing_errors.NewLocationDenied("the CA used to verify the upstream certificate does not contain the key ca.crt")
+	}
+
+	secure.CACert = *caCert
+	secure.Verify = verify
+
+	return secure, nil
 }
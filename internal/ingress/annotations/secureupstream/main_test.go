@@ -26,6 +26,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
@@ -66,7 +67,8 @@ func buildIngress() *networking.Ingress {
 
 type mockCfg struct {
 	resolver.Mock
-	certs map[string]resolver.AuthSSLCert
+	certs      map[string]resolver.AuthSSLCert
+	configMaps map[string]resolver.AuthSSLCert
 }
 
 func (cfg mockCfg) GetAuthCertificate(secret string) (*resolver.AuthSSLCert, error) {
@@ -76,6 +78,13 @@ func (cfg mockCfg) GetAuthCertificate(secret string) (*resolver.AuthSSLCert, err
 	return nil, fmt.Errorf("secret not found: %v", secret)
 }
 
+func (cfg mockCfg) GetConfigMapCACertificate(name string) (*resolver.AuthSSLCert, error) {
+	if cert, ok := cfg.configMaps[name]; ok {
+		return &cert, nil
+	}
+	return nil, fmt.Errorf("configmap not found: %v", name)
+}
+
 func TestNoCA(t *testing.T) {
 	ing := buildIngress()
 	data := map[string]string{}
@@ -101,7 +110,7 @@ func TestAnnotations(t *testing.T) {
 
 	_, err := NewParser(mockCfg{
 		certs: map[string]resolver.AuthSSLCert{
-			"default/secure-verify-ca": {},
+			"default/secure-verify-ca": {CAFileName: "/ssl/ca-default-secure-verify-ca.pem"},
 		},
 	}).Parse(ing)
 	if err != nil {
@@ -136,3 +145,72 @@ func TestSecretOnNonSecure(t *testing.T) {
 		t.Error("Expected CA secret on non secure backend error on ingress")
 	}
 }
+
+func TestProxySSL(t *testing.T) {
+	caFile := "/ssl/ca-default-ca.pem"
+	r := mockCfg{
+		certs: map[string]resolver.AuthSSLCert{
+			"default/ca":       {Secret: "default/ca", CAFileName: caFile},
+			"default/tls-only": {Secret: "default/tls-only"},
+		},
+		configMaps: map[string]resolver.AuthSSLCert{
+			"default/ca-bundle": {Secret: "default/ca-bundle", CAFileName: caFile},
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    *Config
+		denied      bool
+	}{
+		{"secret", map[string]string{"proxy-ssl-secret": "default/ca"},
+			&Config{CACert: r.certs["default/ca"], Verify: true, VerifyDepth: 1}, false},
+		{"configmap", map[string]string{"proxy-ssl-ca-configmap": "default/ca-bundle"},
+			&Config{CACert: r.configMaps["default/ca-bundle"], Verify: true, VerifyDepth: 1}, false},
+		{"legacy secret", map[string]string{"secure-verify-ca-secret": "ca"},
+			&Config{CACert: r.certs["default/ca"], Verify: true, VerifyDepth: 1}, false},
+		{"server name and depth", map[string]string{"proxy-ssl-secret": "default/ca", "proxy-ssl-name": "app.example.com", "proxy-ssl-verify-depth": "3"},
+			&Config{CACert: r.certs["default/ca"], Verify: true, VerifyDepth: 3, ServerName: "app.example.com"}, false},
+		{"verification disabled", map[string]string{"proxy-ssl-secret": "default/ca", "proxy-ssl-verify": "false"},
+			&Config{CACert: r.certs["default/ca"], Verify: false, VerifyDepth: 1}, false},
+		{"server name without CA", map[string]string{"proxy-ssl-name": "app.example.com"},
+			&Config{VerifyDepth: 1, ServerName: "app.example.com"}, false},
+		{"invalid server name", map[string]string{"proxy-ssl-secret": "default/ca", "proxy-ssl-name": "app.example.com; return 200"}, nil, true},
+		{"verification without CA", map[string]string{"proxy-ssl-verify": "true"}, nil, true},
+		{"missing secret", map[string]string{"proxy-ssl-secret": "default/unknown"}, nil, true},
+		{"missing configmap", map[string]string{"proxy-ssl-ca-configmap": "default/unknown"}, nil, true},
+		{"secret without CA", map[string]string{"proxy-ssl-secret": "default/tls-only"}, nil, true},
+		{"secret without namespace", map[string]string{"proxy-ssl-secret": "ca"}, nil, true},
+		{"secret and configmap", map[string]string{"proxy-ssl-secret": "default/ca", "proxy-ssl-ca-configmap": "default/ca-bundle"}, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ing := buildIngress()
+			data := map[string]string{
+				parser.GetAnnotationWithPrefix("backend-protocol"): "HTTPS",
+			}
+			for k, v := range tc.annotations {
+				data[parser.GetAnnotationWithPrefix(k)] = v
+			}
+			ing.SetAnnotations(data)
+
+			result, err := NewParser(r).Parse(ing)
+			if tc.denied {
+				if !ing_errors.IsLocationDenied(err) {
+					t.Errorf("expected the location to be denied but returned %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !tc.expected.Equal(result.(*Config)) {
+				t.Errorf("expected %+v but returned %+v", tc.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sslpin
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+// pinPrefix is the prefix of the SPKI pins, as used by curl --pinnedpubkey
+const pinPrefix = "sha256/"
+
+// Config contains the SPKI pins the SSL certificate of the hosts of an
+// Ingress must match
+type Config struct {
+	// Enabled is true when the certificates must match the pins. It stays
+	// true when none of the pins is valid, all the certificates are refused.
+	Enabled bool `json:"enabled"`
+	// Pins contains the sorted sha256/<base64> pins of the public keys
+	Pins []string `json:"pins,omitempty"`
+	// Override is the pin of a public key accepted although it does not
+	// match the pins, used to rotate the key before updating the pins
+	Override string `json:"override,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Enabled != c2.Enabled || c1.Override != c2.Override {
+		return false
+	}
+	if len(c1.Pins) != len(c2.Pins) {
+		return false
+	}
+	for i := range c1.Pins {
+		if c1.Pins[i] != c2.Pins[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+type sslPin struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new SSL pin annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return sslPin{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to pin the
+// public key of the SSL certificate of the hosts. The invalid pins are
+// ignored, the valid ones are still enforced.
+func (a sslPin) Parse(ing *networking.Ingress) (interface{}, error) {
+	val, err := parser.GetStringAnnotation("ssl-pins", ing)
+	if err != nil {
+		return &Config{}, nil
+	}
+
+	config := &Config{Enabled: true}
+	for _, pin := range strings.Split(val, ",") {
+		pin = strings.TrimSpace(pin)
+		if pin == "" {
+			continue
+		}
+		if !isValidPin(pin) {
+			parser.RecordInvalidAnnotation(ing, ing_errors.NewInvalidAnnotationContent("ssl-pins", val))
+			continue
+		}
+		config.Pins = append(config.Pins, pin)
+	}
+	sort.Strings(config.Pins)
+
+	override, err := parser.GetStringAnnotation("ssl-pins-override", ing)
+	if err == nil {
+		override = strings.TrimSpace(override)
+		if isValidPin(override) {
+			config.Override = override
+		} else {
+			parser.RecordInvalidAnnotation(ing, ing_errors.NewInvalidAnnotationContent("ssl-pins-override", override))
+		}
+	}
+
+	return config, nil
+}
+
+// isValidPin checks the pin is the sha256/ prefix followed by a base64
+// encoded SHA-256 hash
+func isValidPin(pin string) bool {
+	if !strings.HasPrefix(pin, pinPrefix) {
+		return false
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, pinPrefix))
+	return err == nil && len(sum) == sha256.Size
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sslpin
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	pins := parser.GetAnnotationWithPrefix("ssl-pins")
+	override := parser.GetAnnotationWithPrefix("ssl-pins-override")
+
+	pin1 := "sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	pin2 := "sha256/LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		expected    *Config
+		invalid     []string
+	}{
+		{"without annotations", map[string]string{}, &Config{}, nil},
+		{"pins", map[string]string{pins: pin2 + ", " + pin1}, &Config{Enabled: true, Pins: []string{pin1, pin2}}, nil},
+		{"override", map[string]string{pins: pin1, override: pin2}, &Config{Enabled: true, Pins: []string{pin1}, Override: pin2}, nil},
+		{"override without pins", map[string]string{override: pin2}, &Config{}, nil},
+		{"invalid pin", map[string]string{pins: pin1 + ",sha1/2jmj7l5rSw0yVb/vlWAYkK/YBwk="}, &Config{Enabled: true, Pins: []string{pin1}}, []string{pins}},
+		{"no valid pin", map[string]string{pins: "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="}, &Config{Enabled: true}, []string{pins}},
+		{"invalid override", map[string]string{pins: pin1, override: "true"}, &Config{Enabled: true, Pins: []string{pin1}}, []string{override}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ing := &networking.Ingress{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:      "foo",
+					Namespace: api.NamespaceDefault,
+				},
+				Spec: networking.IngressSpec{},
+			}
+			ing.SetAnnotations(tc.annotations)
+
+			parser.TrackInvalidAnnotations(ing)
+			result, err := NewParser(&resolver.Mock{}).Parse(ing)
+			invalid := parser.InvalidAnnotations(ing)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("expected %+v but returned %+v", tc.expected, result)
+			}
+
+			var names []string
+			for _, i := range invalid {
+				names = append(names, i.Name)
+			}
+			if !reflect.DeepEqual(names, tc.invalid) {
+				t.Errorf("expected the invalid annotations %v but returned %v", tc.invalid, names)
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	c1 := &Config{Enabled: true, Pins: []string{"sha256/a"}}
+	c2 := &Config{Enabled: true, Pins: []string{"sha256/a"}}
+	if !c1.Equal(c2) {
+		t.Errorf("expected equal configurations")
+	}
+
+	c2.Override = "sha256/b"
+	if c1.Equal(c2) {
+		t.Errorf("expected different configurations")
+	}
+
+	c2 = &Config{Enabled: true, Pins: []string{"sha256/b"}}
+	if c1.Equal(c2) {
+		t.Errorf("expected different configurations")
+	}
+
+	if c1.Equal(nil) {
+		t.Errorf("expected different configurations")
+	}
+}
+
+func TestIsValidPin(t *testing.T) {
+	cases := map[string]bool{
+		"sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=": true,
+		"sha256/47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU":  false,
+		"47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=":        false,
+		"sha1/2jmj7l5rSw0yVb/vlWAYkK/YBwk=":                   false,
+		"sha256/2jmj7l5rSw0yVb/vlWAYkK/YBwk=":                 false,
+		"sha256/":                                             false,
+	}
+
+	for pin, valid := range cases {
+		if isValidPin(pin) != valid {
+			t.Errorf("expected isValidPin(%q) to be %v", pin, valid)
+		}
+	}
+}
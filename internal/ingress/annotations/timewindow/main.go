@@ -0,0 +1,385 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package timewindow
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/sets"
+)
+
+const (
+	defaultTimeZone   = "UTC"
+	defaultStatusCode = http.StatusServiceUnavailable
+)
+
+// Config describes the time windows during which the requests are allowed
+type Config struct {
+	// Allowed contains the cron-like expressions of the minutes during
+	// which the requests are allowed. The requests are allowed at any time
+	// when it is empty.
+	// +optional
+	Allowed []string `json:"allowed,omitempty"`
+	// Denied contains the cron-like expressions of the minutes during which
+	// the requests are denied, i.e. scheduled maintenances. It takes
+	// precedence over Allowed.
+	// +optional
+	Denied []string `json:"denied,omitempty"`
+	// TimeZone is the IANA time zone of the expressions
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// StatusCode is returned outside of the time windows, 403 or 503
+	// +optional
+	StatusCode int `json:"statusCode,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if !sets.StringElementsMatch(c1.Allowed, c2.Allowed) {
+		return false
+	}
+	if !sets.StringElementsMatch(c1.Denied, c2.Denied) {
+		return false
+	}
+	if c1.TimeZone != c2.TimeZone {
+		return false
+	}
+	if c1.StatusCode != c2.StatusCode {
+		return false
+	}
+
+	return true
+}
+
+// Enabled returns true when the requests depend on the time
+func (c Config) Enabled() bool {
+	return len(c.Allowed) > 0 || len(c.Denied) > 0
+}
+
+// Window contains the values matched by each field of a cron-like
+// expression. A nil field matches any value.
+type Window struct {
+	Minutes  []int
+	Hours    []int
+	Days     []int
+	Months   []int
+	Weekdays []int
+}
+
+// field describes the values of a field of the expressions
+type field struct {
+	name  string
+	min   int
+	max   int
+	names []string
+}
+
+var (
+	fields = []field{
+		{name: "minute", min: 0, max: 59},
+		{name: "hour", min: 0, max: 23},
+		{name: "day of month", min: 1, max: 31},
+		{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+		// 7 is also accepted for Sunday
+		{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+	}
+)
+
+// ParseWindow parses a cron-like expression with the five fields minute,
+// hour, day of month, month and day of week. Each field is a comma separated
+// list of *, values or ranges, with an optional /step.
+func ParseWindow(expr string) (*Window, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("the time window %q must contain %v fields", expr, len(fields))
+	}
+
+	values := make([][]int, len(fields))
+	for i, f := range fields {
+		v, err := f.parse(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v of the time window %q: %v", f.name, expr, err)
+		}
+		values[i] = v
+	}
+
+	// Sunday is 0
+	if weekdays := values[4]; weekdays != nil {
+		days := map[int]bool{}
+		for _, d := range weekdays {
+			days[d%7] = true
+		}
+		values[4] = sortedKeys(days)
+	}
+
+	return &Window{
+		Minutes:  values[0],
+		Hours:    values[1],
+		Days:     values[2],
+		Months:   values[3],
+		Weekdays: values[4],
+	}, nil
+}
+
+// parse returns the values matched by the field, nil for any value
+func (f field) parse(val string) ([]int, error) {
+	if val == "*" {
+		return nil, nil
+	}
+
+	matched := map[int]bool{}
+	for _, item := range strings.Split(val, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i != -1 {
+			s, err := strconv.Atoi(item[i+1:])
+			if err != nil || s < 1 {
+				return nil, fmt.Errorf("invalid step %q", item[i+1:])
+			}
+			step = s
+			item = item[:i]
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if start, err = f.value(bounds[0]); err != nil {
+				return nil, err
+			}
+			if end, err = f.value(bounds[1]); err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, fmt.Errorf("invalid range %q", item)
+			}
+		default:
+			v, err := f.value(item)
+			if err != nil {
+				return nil, err
+			}
+			start = v
+			if step == 1 {
+				end = v
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			matched[v] = true
+		}
+	}
+
+	return sortedKeys(matched), nil
+}
+
+// value returns a value of the field, a number or a name
+func (f field) value(val string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(val, name) {
+			return f.min + i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(val)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q, expected a value between %v and %v", val, f.min, f.max)
+	}
+
+	return v, nil
+}
+
+func sortedKeys(m map[int]bool) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func contains(values []int, v int) bool {
+	if values == nil {
+		return true
+	}
+
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matches returns true if the minute of t matches the window. Like cron,
+// the day matches either the day of month or the day of week when both
+// are restricted.
+func (w Window) Matches(t time.Time) bool {
+	if !contains(w.Minutes, t.Minute()) || !contains(w.Hours, t.Hour()) || !contains(w.Months, int(t.Month())) {
+		return false
+	}
+
+	if w.Days != nil && w.Weekdays != nil {
+		return contains(w.Days, t.Day()) || contains(w.Weekdays, int(t.Weekday()))
+	}
+
+	return contains(w.Days, t.Day()) && contains(w.Weekdays, int(t.Weekday()))
+}
+
+// Offset is the offset to UTC of a time zone, in seconds, until a time
+type Offset struct {
+	// Ends is the Unix time at which the offset changes
+	Ends   int64
+	Offset int
+}
+
+// Offsets returns the offsets to UTC of the time zone between from and to,
+// and the offset used after to
+func Offsets(loc *time.Location, from, to time.Time) ([]Offset, int) {
+	var offsets []Offset
+
+	_, offset := from.In(loc).Zone()
+	for t := from; t.Before(to); t = t.Add(time.Hour) {
+		next := t.Add(time.Hour)
+		_, nextOffset := next.In(loc).Zone()
+		if nextOffset == offset {
+			continue
+		}
+
+		// the offsets change on a second between t and next
+		low, high := t.Unix(), next.Unix()
+		for high-low > 1 {
+			mid := (low + high) / 2
+			if _, o := time.Unix(mid, 0).In(loc).Zone(); o == offset {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+
+		offsets = append(offsets, Offset{Ends: high, Offset: offset})
+		offset = nextOffset
+	}
+
+	return offsets, offset
+}
+
+type timeWindow struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new time window annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return timeWindow{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to allow
+// the requests during time windows only
+func (a timeWindow) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	var err error
+	config.Allowed, err = parseWindows("access-time-windows", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	config.Denied, err = parseWindows("access-denied-time-windows", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	if !config.Enabled() {
+		return config, nil
+	}
+
+	config.TimeZone = defaultTimeZone
+	tz, err := parser.GetStringAnnotation("access-time-zone", ing)
+	if err == nil {
+		tz = strings.TrimSpace(tz)
+		if _, err := LoadLocation(tz); err != nil {
+			klog.Warningf("Ignoring access-time-zone annotation of Ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+			return &Config{}, ing_errors.NewInvalidAnnotationContent("access-time-zone", tz)
+		}
+		config.TimeZone = tz
+	}
+
+	config.StatusCode = defaultStatusCode
+	code, err := parser.GetIntAnnotation("access-time-window-code", ing)
+	if err == nil {
+		if code != http.StatusForbidden && code != http.StatusServiceUnavailable {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent("access-time-window-code", code)
+		}
+		config.StatusCode = code
+	}
+
+	return config, nil
+}
+
+// parseWindows returns the expressions separated by semicolons of the
+// annotation, with their fields separated by a single space
+func parseWindows(name string, ing *networking.Ingress) ([]string, error) {
+	val, err := parser.GetStringAnnotation(name, ing)
+	if err != nil {
+		return nil, nil
+	}
+
+	var windows []string
+	for _, expr := range strings.Split(val, ";") {
+		expr = strings.Join(strings.Fields(expr), " ")
+		if expr == "" {
+			continue
+		}
+
+		if _, err := ParseWindow(expr); err != nil {
+			klog.Warningf("Ignoring %v annotation of Ingress %v/%v: %v", name, ing.Namespace, ing.Name, err)
+			return nil, ing_errors.NewInvalidAnnotationContent(name, val)
+		}
+
+		windows = append(windows, expr)
+	}
+
+	return windows, nil
+}
+
+// LoadLocation returns the IANA time zone, the local time zone of the
+// controller is not accepted
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("invalid time zone %q", name)
+	}
+
+	return time.LoadLocation(name)
+}
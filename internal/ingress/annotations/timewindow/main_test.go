@@ -0,0 +1,176 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package timewindow
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	allowed := parser.GetAnnotationWithPrefix("access-time-windows")
+	denied := parser.GetAnnotationWithPrefix("access-denied-time-windows")
+	timeZone := parser.GetAnnotationWithPrefix("access-time-zone")
+	code := parser.GetAnnotationWithPrefix("access-time-window-code")
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		expectErr   bool
+	}{
+		{map[string]string{}, &Config{}, false},
+		{map[string]string{timeZone: "Europe/Paris", code: "403"}, &Config{}, false},
+		{map[string]string{allowed: "* 9-17  * * mon-fri ; "},
+			&Config{Allowed: []string{"* 9-17 * * mon-fri"}, TimeZone: "UTC", StatusCode: 503}, false},
+		{map[string]string{allowed: "* 9-17 * * 1-5;* 10-12 * * 6", denied: "0-29 2 1 * *", timeZone: "Europe/Paris", code: "403"},
+			&Config{Allowed: []string{"* 9-17 * * 1-5", "* 10-12 * * 6"}, Denied: []string{"0-29 2 1 * *"}, TimeZone: "Europe/Paris", StatusCode: 403}, false},
+		{map[string]string{allowed: "* 9-17 * *"}, &Config{}, true},
+		{map[string]string{denied: "* 25 * * *"}, &Config{}, true},
+		{map[string]string{allowed: "* * * * *", timeZone: "Mars/Olympus"}, &Config{}, true},
+		{map[string]string{allowed: "* * * * *", timeZone: "Local"}, &Config{}, true},
+		{map[string]string{allowed: "* * * * *", code: "404"}, &Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if testCase.expectErr && err == nil {
+			t.Errorf("expected an error but none returned, annotations: %s", testCase.annotations)
+		}
+		if !testCase.expectErr && err != nil {
+			t.Errorf("unexpected error %v, annotations: %s", err, testCase.annotations)
+		}
+
+		if !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, result, testCase.annotations)
+		}
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	testCases := []struct {
+		expr      string
+		expected  *Window
+		expectErr bool
+	}{
+		{"* * * * *", &Window{}, false},
+		{"*/15 9-17/4 1,15 jan-mar 5-7", &Window{
+			Minutes:  []int{0, 15, 30, 45},
+			Hours:    []int{9, 13, 17},
+			Days:     []int{1, 15},
+			Months:   []int{1, 2, 3},
+			Weekdays: []int{0, 5, 6},
+		}, false},
+		{"30/10 * * * SUN", &Window{Minutes: []int{30, 40, 50}, Weekdays: []int{0}}, false},
+		{"* * * *", nil, true},
+		{"60 * * * *", nil, true},
+		{"* * 0 * *", nil, true},
+		{"* 17-9 * * *", nil, true},
+		{"*/0 * * * *", nil, true},
+		{"* * * foo *", nil, true},
+	}
+
+	for _, tc := range testCases {
+		w, err := ParseWindow(tc.expr)
+		if tc.expectErr {
+			if err == nil {
+				t.Errorf("expected an error parsing %q but none returned", tc.expr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %v", tc.expr, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(w, tc.expected) {
+			t.Errorf("expected %+v but returned %+v parsing %q", tc.expected, w, tc.expr)
+		}
+	}
+}
+
+func TestWindowMatches(t *testing.T) {
+	// Monday
+	monday := time.Date(2019, time.July, 1, 10, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		expr     string
+		time     time.Time
+		expected bool
+	}{
+		{"* * * * *", monday, true},
+		{"* 9-17 * * mon-fri", monday, true},
+		{"* 9-17 * * mon-fri", monday.Add(-24 * time.Hour), false},
+		{"* 9-17 * * mon-fri", monday.Add(8 * time.Hour), false},
+		{"0-29 10 * * *", monday, false},
+		// the day of month or the day of week
+		{"* * 30 * mon", monday.Add(-24 * time.Hour), true},
+		{"* * 30 * mon", monday.Add(24 * time.Hour), false},
+		{"* * * jul *", monday, true},
+		{"* * * aug *", monday, false},
+	}
+
+	for _, tc := range testCases {
+		w, err := ParseWindow(tc.expr)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %q: %v", tc.expr, err)
+		}
+
+		if matched := w.Matches(tc.time); matched != tc.expected {
+			t.Errorf("expected %q matching %v to be %v", tc.expr, tc.time, tc.expected)
+		}
+	}
+}
+
+func TestOffsets(t *testing.T) {
+	loc, err := LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skipf("the time zone database is not available: %v", err)
+	}
+
+	from := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	offsets, last := Offsets(loc, from, from.AddDate(1, 0, 0))
+
+	expected := []Offset{
+		{Ends: time.Date(2019, time.March, 31, 1, 0, 0, 0, time.UTC).Unix(), Offset: 3600},
+		{Ends: time.Date(2019, time.October, 27, 1, 0, 0, 0, time.UTC).Unix(), Offset: 7200},
+	}
+	if !reflect.DeepEqual(offsets, expected) {
+		t.Errorf("expected %+v but returned %+v", expected, offsets)
+	}
+
+	if last != 3600 {
+		t.Errorf("expected the last offset to be 3600 but %v was returned", last)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tlsfingerprint
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+var (
+	// the MD5 hash of the JA3 string
+	ja3Regex = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	ja4Regex = regexp.MustCompile(`^[tq][0-9s][0-9][di][0-9]{4}[0-9a-z]{2}_[0-9a-f]{12}_[0-9a-f]{12}$`)
+)
+
+// Config contains the TLS client fingerprints allowed or denied in a location.
+// The fingerprints are JA3 hashes or JA4 fingerprints.
+type Config struct {
+	// Allowed contains the only fingerprints allowed when not empty
+	Allowed []string `json:"allowed,omitempty"`
+	// Denied contains the fingerprints of the denied clients
+	Denied []string `json:"denied,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if !equalStrings(c1.Allowed, c2.Allowed) {
+		return false
+	}
+	if !equalStrings(c1.Denied, c2.Denied) {
+		return false
+	}
+
+	return true
+}
+
+func equalStrings(s1, s2 []string) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type tlsFingerprint struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new TLS fingerprint annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return tlsFingerprint{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to allow
+// or deny the clients by TLS fingerprint
+func (a tlsFingerprint) Parse(ing *networking.Ingress) (interface{}, error) {
+	allowed, err := parseFingerprints("allowed-tls-fingerprints", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	denied, err := parseFingerprints("denied-tls-fingerprints", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	return &Config{Allowed: allowed, Denied: denied}, nil
+}
+
+// parseFingerprints returns the sorted fingerprints of the annotation
+func parseFingerprints(name string, ing *networking.Ingress) ([]string, error) {
+	val, err := parser.GetStringAnnotation(name, ing)
+	if err != nil {
+		return nil, nil
+	}
+
+	fingerprints := []string{}
+	for _, fingerprint := range strings.Split(val, ",") {
+		fingerprint = strings.ToLower(strings.TrimSpace(fingerprint))
+		if fingerprint == "" {
+			continue
+		}
+		if !ja3Regex.MatchString(fingerprint) && !ja4Regex.MatchString(fingerprint) {
+			return nil, ing_errors.NewInvalidAnnotationContent(name, val)
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+
+	sort.Strings(fingerprints)
+	return fingerprints, nil
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trafficcapture
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+const defaultSampleRate = 0.01
+
+var (
+	fileRegex   = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+	topicRegex  = regexp.MustCompile(`^/[a-zA-Z0-9._-]+$`)
+	headerRegex = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+	argRegex    = regexp.MustCompile(`^[a-zA-Z0-9_.\-\[\]]+$`)
+)
+
+// Config describes the capture of the requests used to replay the traffic
+type Config struct {
+	// Sink is the destination of the captured requests, file://<name>,
+	// http://<host>/<path> or kafka://<host>/<topic>
+	Sink string `json:"sink,omitempty"`
+	// SampleRate is the fraction of the requests captured
+	SampleRate float64 `json:"sampleRate,omitempty"`
+	// MaxRPS is the maximum number of requests captured per second
+	MaxRPS int `json:"maxRPS,omitempty"`
+	// Headers contains the names of the headers captured
+	Headers []string `json:"headers,omitempty"`
+	// Args contains the names of the query string arguments captured
+	Args []string `json:"args,omitempty"`
+	// Body enables the capture of the request bodies
+	Body bool `json:"body,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Sink != c2.Sink {
+		return false
+	}
+	if c1.SampleRate != c2.SampleRate {
+		return false
+	}
+	if c1.MaxRPS != c2.MaxRPS {
+		return false
+	}
+	if !equalStrings(c1.Headers, c2.Headers) {
+		return false
+	}
+	if !equalStrings(c1.Args, c2.Args) {
+		return false
+	}
+	if c1.Body != c2.Body {
+		return false
+	}
+
+	return true
+}
+
+func equalStrings(s1, s2 []string) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type trafficCapture struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new traffic capture annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return trafficCapture{r}
+}
+
+// Parse parses the annotations contained in the ingress rule
+// used to capture a sample of the requests. The limits of the
+// configuration map can't be exceeded: the sample rate is capped
+// while denied headers and bodies disable the capture.
+func (a trafficCapture) Parse(ing *networking.Ingress) (interface{}, error) {
+	defBackend := a.r.GetDefaultBackend()
+
+	sink, err := parser.GetStringAnnotation("traffic-capture-sink", ing)
+	if err != nil {
+		return &Config{}, err
+	}
+
+	sink, err = parseSink(sink)
+	if err != nil {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent("traffic-capture-sink", err.Error())
+	}
+
+	config := &Config{
+		Sink:       sink,
+		SampleRate: defaultSampleRate,
+		MaxRPS:     defBackend.TrafficCaptureMaxRPS,
+	}
+
+	rate, err := parser.GetStringAnnotation("traffic-capture-sample-rate", ing)
+	if err == nil {
+		config.SampleRate, err = strconv.ParseFloat(rate, 64)
+		if err != nil || config.SampleRate <= 0 || config.SampleRate > 1 {
+			return &Config{}, ing_errors.NewInvalidAnnotationContent("traffic-capture-sample-rate", rate)
+		}
+	}
+
+	if config.SampleRate > defBackend.TrafficCaptureMaxSampleRate {
+		klog.Warningf("traffic-capture-sample-rate %v of Ingress %v/%v exceeds the maximum, using %v",
+			config.SampleRate, ing.Namespace, ing.Name, defBackend.TrafficCaptureMaxSampleRate)
+		config.SampleRate = defBackend.TrafficCaptureMaxSampleRate
+	}
+
+	denied := map[string]bool{}
+	for _, header := range splitList(defBackend.TrafficCaptureDeniedHeaders) {
+		denied[strings.ToLower(header)] = true
+	}
+
+	headers, err := parser.GetStringAnnotation("traffic-capture-headers", ing)
+	if err == nil {
+		for _, header := range splitList(headers) {
+			header = strings.ToLower(header)
+			if !headerRegex.MatchString(header) {
+				return &Config{}, ing_errors.NewInvalidAnnotationContent("traffic-capture-headers", headers)
+			}
+			if denied[header] {
+				return &Config{}, ing_errors.NewInvalidAnnotationConfiguration("traffic-capture-headers",
+					fmt.Sprintf("the header %v can't be captured", header))
+			}
+			config.Headers = append(config.Headers, header)
+		}
+	}
+
+	args, err := parser.GetStringAnnotation("traffic-capture-args", ing)
+	if err == nil {
+		for _, arg := range splitList(args) {
+			if !argRegex.MatchString(arg) {
+				return &Config{}, ing_errors.NewInvalidAnnotationContent("traffic-capture-args", args)
+			}
+			config.Args = append(config.Args, arg)
+		}
+	}
+
+	body, err := parser.GetBoolAnnotation("traffic-capture-body", ing)
+	if err == nil && body {
+		if !defBackend.AllowTrafficCaptureBody {
+			return &Config{}, ing_errors.NewInvalidAnnotationConfiguration("traffic-capture-body",
+				"the capture of the request bodies is not allowed")
+		}
+		config.Body = true
+	}
+
+	return config, nil
+}
+
+// parseSink validates the sink and returns it in the form read by the Lua module
+func parseSink(sink string) (string, error) {
+	u, err := url.Parse(sink)
+	if err != nil {
+		return "", err
+	}
+
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("the sink %v can't contain credentials, a query or a fragment", sink)
+	}
+
+	switch u.Scheme {
+	case "file":
+		// the files are always written in the capture directory
+		name := strings.TrimPrefix(u.Host+u.Path, "/")
+		if !fileRegex.MatchString(name) {
+			return "", fmt.Errorf("invalid file name in sink %v", sink)
+		}
+		return fmt.Sprintf("file://%v", name), nil
+	case "http":
+		if u.Hostname() == "" {
+			return "", fmt.Errorf("missing host in sink %v", sink)
+		}
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		return fmt.Sprintf("http://%v%v", u.Host, path), nil
+	case "kafka":
+		if u.Hostname() == "" {
+			return "", fmt.Errorf("missing host in sink %v", sink)
+		}
+		path := strings.TrimSuffix(u.Path, "/")
+		if !topicRegex.MatchString(path) {
+			return "", fmt.Errorf("invalid topic in sink %v", sink)
+		}
+		return fmt.Sprintf("kafka://%v%v", u.Host, path), nil
+	}
+
+	return "", fmt.Errorf("unsupported scheme in sink %v (expected file, http or kafka)", sink)
+}
+
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstreamhost
+
+import (
+	"regexp"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+var (
+	// hostRegex matches a host name with an optional port
+	hostRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*(:[0-9]{1,5})?$`)
+	// variableRegex matches a single NGINX variable
+	variableRegex = regexp.MustCompile(`^\$[a-zA-Z0-9_]+$`)
+)
+
+// Config describes the Host header sent to the upstream servers
+type Config struct {
+	// Host is the Host header sent to the upstream servers, a host name
+	// or an NGINX variable like $http_x_original_host
+	// +optional
+	Host string `json:"host,omitempty"`
+	// Preserve sends the Host header of the request unchanged, even when
+	// the host is read from X-Forwarded-Host
+	// +optional
+	Preserve bool `json:"preserve,omitempty"`
+}
+
+// Equal tests for equality between two Config types
+func (c1 *Config) Equal(c2 *Config) bool {
+	if c1 == c2 {
+		return true
+	}
+	if c1 == nil || c2 == nil {
+		return false
+	}
+	if c1.Host != c2.Host {
+		return false
+	}
+	if c1.Preserve != c2.Preserve {
+		return false
+	}
+
+	return true
+}
+
+// IsVariable returns true when the Host header is read from an NGINX variable
+func (c Config) IsVariable() bool {
+	return strings.HasPrefix(c.Host, "$")
+}
+
+// HostName returns the static host name sent to the upstream servers without
+// its port, empty when the Host header is not static
+func (c Config) HostName() string {
+	if c.Host == "" || c.IsVariable() {
+		return ""
+	}
+
+	return strings.SplitN(c.Host, ":", 2)[0]
+}
+
+type upstreamHost struct {
+	r resolver.Resolver
+}
+
+// NewParser creates a new upstream Host header annotation parser
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return upstreamHost{r}
+}
+
+// Parse parses the annotations contained in the ingress rule used to define
+// the Host header sent to the upstream servers
+func (a upstreamHost) Parse(ing *networking.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	config.Preserve, _ = parser.GetBoolAnnotation("preserve-host", ing)
+
+	// upstream-vhost defines the Host header sent to the upstream servers
+	// when these annotations are not used
+	_, err := parser.GetStringAnnotation("upstream-vhost", ing)
+	vhost := err == nil
+
+	host, err := parser.GetStringAnnotation("upstream-host", ing)
+	if err != nil {
+		if config.Preserve && vhost {
+			return &Config{}, ing_errors.NewInvalidAnnotationConfiguration("preserve-host",
+				"the Host header cannot be both preserved and defined with upstream-vhost")
+		}
+
+		return config, nil
+	}
+
+	host = strings.TrimSpace(host)
+	if !hostRegex.MatchString(host) && !variableRegex.MatchString(host) {
+		return &Config{}, ing_errors.NewInvalidAnnotationContent("upstream-host", host)
+	}
+
+	if config.Preserve {
+		return &Config{}, ing_errors.NewInvalidAnnotationConfiguration("upstream-host",
+			"the Host header cannot be both defined and preserved with preserve-host")
+	}
+
+	if vhost {
+		return &Config{}, ing_errors.NewInvalidAnnotationConfiguration("upstream-host",
+			"the Host header cannot be defined with both upstream-host and upstream-vhost")
+	}
+
+	config.Host = host
+
+	return config, nil
+}
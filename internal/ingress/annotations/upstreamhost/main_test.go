@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upstreamhost
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func TestParse(t *testing.T) {
+	upstreamHost := parser.GetAnnotationWithPrefix("upstream-host")
+	preserveHost := parser.GetAnnotationWithPrefix("preserve-host")
+	upstreamVhost := parser.GetAnnotationWithPrefix("upstream-vhost")
+
+	testCases := []struct {
+		annotations map[string]string
+		expected    *Config
+		expectErr   bool
+	}{
+		{map[string]string{}, &Config{}, false},
+		{map[string]string{upstreamHost: "api.example.com"}, &Config{Host: "api.example.com"}, false},
+		{map[string]string{upstreamHost: " api.example.com:8443 "}, &Config{Host: "api.example.com:8443"}, false},
+		{map[string]string{upstreamHost: "$http_x_original_host"}, &Config{Host: "$http_x_original_host"}, false},
+		{map[string]string{preserveHost: "true"}, &Config{Preserve: true}, false},
+		{map[string]string{preserveHost: "false", upstreamHost: "api.example.com"}, &Config{Host: "api.example.com"}, false},
+		{map[string]string{upstreamHost: "api.example.com; more_set_headers"}, &Config{}, true},
+		{map[string]string{upstreamHost: "$host$request_uri"}, &Config{}, true},
+		{map[string]string{upstreamHost: "api.example.com", preserveHost: "true"}, &Config{}, true},
+		{map[string]string{upstreamVhost: "vhost.example.com"}, &Config{}, false},
+		{map[string]string{upstreamHost: "api.example.com", upstreamVhost: "vhost.example.com"}, &Config{}, true},
+		{map[string]string{preserveHost: "true", upstreamVhost: "vhost.example.com"}, &Config{}, true},
+	}
+
+	ing := &networking.Ingress{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "foo",
+			Namespace: api.NamespaceDefault,
+		},
+		Spec: networking.IngressSpec{},
+	}
+
+	for _, testCase := range testCases {
+		ing.SetAnnotations(testCase.annotations)
+		result, err := NewParser(&resolver.Mock{}).Parse(ing)
+		if testCase.expectErr && err == nil {
+			t.Errorf("expected an error but none returned, annotations: %s", testCase.annotations)
+		}
+		if !testCase.expectErr && err != nil {
+			t.Errorf("unexpected error %v, annotations: %s", err, testCase.annotations)
+		}
+
+		if !reflect.DeepEqual(result, testCase.expected) {
+			t.Errorf("expected %+v but returned %+v, annotations: %s", testCase.expected, result, testCase.annotations)
+		}
+	}
+}
+
+func TestHostName(t *testing.T) {
+	testCases := []struct {
+		config   Config
+		expected string
+	}{
+		{Config{}, ""},
+		{Config{Preserve: true}, ""},
+		{Config{Host: "$http_x_original_host"}, ""},
+		{Config{Host: "api.example.com"}, "api.example.com"},
+		{Config{Host: "api.example.com:8443"}, "api.example.com"},
+	}
+
+	for _, testCase := range testCases {
+		if name := testCase.config.HostName(); name != testCase.expected {
+			t.Errorf("expected %q but returned %q for %+v", testCase.expected, name, testCase.config)
+		}
+	}
+}
@@ -17,12 +17,43 @@ limitations under the License.
 package upstreamvhost
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
 	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
 
+	"k8s.io/ingress-nginx/internal/ingress/annotations/hostregex"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
+var (
+	// vhostTokenRegex matches the parts of an upstream vhost: a numbered
+	// capture group, a named variable, with or without braces, or a literal
+	vhostTokenRegex = regexp.MustCompile(`^(?:\$\{([1-9])\}|\$([1-9])|\$\{([a-zA-Z_][a-zA-Z0-9_]*)\}|\$([a-zA-Z_][a-zA-Z0-9_]*)|[a-zA-Z0-9._:-]+)`)
+
+	// nginxVariables contains the variables of NGINX available to build
+	// the upstream vhost, in addition to the ones with the prefixes below
+	nginxVariables = sets.NewString(
+		"host",
+		"hostname",
+		"remote_addr",
+		"request_method",
+		"scheme",
+		"server_addr",
+		"server_name",
+		"server_port",
+		"ssl_server_name",
+	)
+
+	nginxVariablePrefixes = []string{"arg_", "cookie_", "http_"}
+)
+
 type upstreamVhost struct {
 	r resolver.Resolver
 }
@@ -36,5 +67,165 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 // used to indicate if the location/s contains a fragment of
 // configuration to be included inside the paths of the rules
 func (a upstreamVhost) Parse(ing *networking.Ingress) (interface{}, error) {
-	return parser.GetStringAnnotation("upstream-vhost", ing)
+	vhost, err := parser.GetStringAnnotation("upstream-vhost", ing)
+	if err != nil {
+		return "", err
+	}
+
+	vhost = strings.TrimSpace(vhost)
+
+	variables, groups, err := parseVhost(vhost)
+	if err != nil {
+		klog.Warningf("Ignoring upstream-vhost annotation of Ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+		return "", ing_errors.NewInvalidAnnotationContent("upstream-vhost", vhost)
+	}
+
+	if len(variables) == 0 && groups == 0 {
+		return vhost, nil
+	}
+
+	captures, err := ingressCaptures(ing, groups > 0)
+	if err != nil {
+		return "", ing_errors.NewInvalidAnnotationConfiguration("upstream-vhost", err.Error())
+	}
+
+	if groups > captures.groups {
+		return "", ing_errors.NewInvalidAnnotationConfiguration("upstream-vhost",
+			fmt.Sprintf("the capture group $%v is not defined by every path of the Ingress", groups))
+	}
+
+	for _, name := range variables {
+		if !isKnownVariable(name) && !captures.names.Has(name) {
+			return "", ing_errors.NewInvalidAnnotationConfiguration("upstream-vhost",
+				fmt.Sprintf("the variable $%v is not defined by NGINX, the controller or a named capture group of the Ingress", name))
+		}
+	}
+
+	return vhost, nil
+}
+
+// parseVhost returns the names of the variables of the upstream vhost and
+// the highest numbered capture group it uses
+func parseVhost(vhost string) ([]string, int, error) {
+	if vhost == "" {
+		return nil, 0, fmt.Errorf("the upstream vhost is empty")
+	}
+
+	var variables []string
+	groups := 0
+
+	for rest := vhost; rest != ""; {
+		match := vhostTokenRegex.FindStringSubmatch(rest)
+		if match == nil {
+			return nil, 0, fmt.Errorf("invalid content %q in the upstream vhost %q", rest, vhost)
+		}
+
+		switch {
+		case match[1] != "" || match[2] != "":
+			group, _ := strconv.Atoi(match[1] + match[2])
+			if group > groups {
+				groups = group
+			}
+		case match[3] != "":
+			variables = append(variables, match[3])
+		case match[4] != "":
+			variables = append(variables, match[4])
+		}
+
+		rest = rest[len(match[0]):]
+	}
+
+	return variables, groups, nil
+}
+
+// isKnownVariable returns true when the variable is defined by NGINX or
+// by the template of the controller
+func isKnownVariable(name string) bool {
+	if nginxVariables.Has(name) || hostregex.IsReservedVariable(name) {
+		return true
+	}
+
+	for _, prefix := range nginxVariablePrefixes {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// captures describes the capture groups available in the locations of
+// an Ingress
+type captures struct {
+	// groups is the lowest number of capture groups of the paths
+	groups int
+	// names contains the named capture groups of the host regex and of
+	// every path
+	names sets.String
+}
+
+// ingressCaptures returns the capture groups of the host regex and of the
+// regular expressions of the paths of the Ingress. Numbered groups are only
+// available when the paths are regular expressions.
+func ingressCaptures(ing *networking.Ingress, numbered bool) (*captures, error) {
+	hostNames := sets.NewString()
+	if val, err := parser.GetStringAnnotation("host-regex", ing); err == nil {
+		if config, err := hostregex.ParseRegex(val); err == nil {
+			hostNames.Insert(config.Captures...)
+		}
+	}
+
+	var paths []string
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+
+		for _, path := range rule.HTTP.Paths {
+			paths = append(paths, path.Path)
+		}
+	}
+
+	useRegex, _ := parser.GetBoolAnnotation("use-regex", ing)
+	rewriteTarget, _ := parser.GetStringAnnotation("rewrite-target", ing)
+	if !useRegex && rewriteTarget == "" || len(paths) == 0 {
+		if numbered {
+			return nil, fmt.Errorf("capture groups require the paths of the Ingress rules to use the use-regex or rewrite-target annotations")
+		}
+
+		return &captures{names: hostNames}, nil
+	}
+
+	var groups int
+	var pathNames sets.String
+	for i, path := range paths {
+		re, err := regexp.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("the capture groups of the path %q cannot be verified: %v", path, err)
+		}
+
+		names := sets.NewString()
+		for _, name := range re.SubexpNames() {
+			if name != "" {
+				names.Insert(name)
+			}
+		}
+
+		// the groups are only available when every path defines them
+		if i == 0 {
+			groups = re.NumSubexp()
+			pathNames = names
+			continue
+		}
+
+		if re.NumSubexp() < groups {
+			groups = re.NumSubexp()
+		}
+		pathNames = pathNames.Intersection(names)
+	}
+
+	return &captures{
+		groups: groups,
+		names:  hostNames.Union(pathNames),
+	}, nil
 }
@@ -53,3 +53,71 @@ func TestParse(t *testing.T) {
 		t.Errorf("expected %v but got %v", "ok.com", vhost)
 	}
 }
+
+func TestParseVariables(t *testing.T) {
+	upstreamVhost := parser.GetAnnotationWithPrefix("upstream-vhost")
+	useRegex := parser.GetAnnotationWithPrefix("use-regex")
+	rewriteTarget := parser.GetAnnotationWithPrefix("rewrite-target")
+	hostRegex := parser.GetAnnotationWithPrefix("host-regex")
+
+	testCases := []struct {
+		name        string
+		annotations map[string]string
+		paths       []string
+		expected    string
+		expectErr   bool
+	}{
+		{"static", map[string]string{upstreamVhost: "api.example.com:8080"}, nil, "api.example.com:8080", false},
+		{"NGINX variable", map[string]string{upstreamVhost: "$host"}, nil, "$host", false},
+		{"header variable", map[string]string{upstreamVhost: "${http_x_tenant}.tenants.example.com"}, nil, "${http_x_tenant}.tenants.example.com", false},
+		{"controller variable", map[string]string{upstreamVhost: "$service_name.$namespace.svc"}, nil, "$service_name.$namespace.svc", false},
+		{"unknown variable", map[string]string{upstreamVhost: "$tenant.example.com"}, nil, "", true},
+		{"host regex capture", map[string]string{upstreamVhost: "$tenant.example.com", hostRegex: `~^api-(?P<tenant>.+)\.example\.com$`}, nil, "$tenant.example.com", false},
+		{"path capture", map[string]string{upstreamVhost: "$1.example.com", useRegex: "true"}, []string{"/tenants/([a-z]+)/", "/t/([a-z]+)"}, "$1.example.com", false},
+		{"path capture with rewrite", map[string]string{upstreamVhost: "${2}-$1.example.com", rewriteTarget: "/$3"}, []string{"/([a-z]+)/([a-z]+)(/|$)(.*)"}, "${2}-$1.example.com", false},
+		{"path capture missing in a path", map[string]string{upstreamVhost: "$2.example.com", useRegex: "true"}, []string{"/([a-z]+)/([a-z]+)", "/([a-z]+)"}, "", true},
+		{"path capture without regex", map[string]string{upstreamVhost: "$1.example.com"}, []string{"/tenants/([a-z]+)/"}, "", true},
+		{"named path capture", map[string]string{upstreamVhost: "$tenant.example.com", useRegex: "true"}, []string{"/(?P<tenant>[a-z]+)/"}, "$tenant.example.com", false},
+		{"named path capture missing in a path", map[string]string{upstreamVhost: "$tenant.example.com", useRegex: "true"}, []string{"/(?P<tenant>[a-z]+)/", "/static"}, "", true},
+		{"quotes", map[string]string{upstreamVhost: `api.example.com"; proxy_pass http://evil;`}, nil, "", true},
+		{"whitespace", map[string]string{upstreamVhost: "api.example.com $host"}, nil, "", true},
+		{"empty variable", map[string]string{upstreamVhost: "api.$"}, nil, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ing := &networking.Ingress{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:        "foo",
+					Namespace:   api.NamespaceDefault,
+					Annotations: tc.annotations,
+				},
+			}
+
+			if len(tc.paths) > 0 {
+				rule := networking.IngressRule{
+					Host: "api.example.com",
+					IngressRuleValue: networking.IngressRuleValue{
+						HTTP: &networking.HTTPIngressRuleValue{},
+					},
+				}
+				for _, path := range tc.paths {
+					rule.HTTP.Paths = append(rule.HTTP.Paths, networking.HTTPIngressPath{Path: path})
+				}
+				ing.Spec.Rules = []networking.IngressRule{rule}
+			}
+
+			result, err := NewParser(&resolver.Mock{}).Parse(ing)
+			if tc.expectErr && err == nil {
+				t.Errorf("expected an error but none returned")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+
+			if result != tc.expected {
+				t.Errorf("expected %q but returned %q", tc.expected, result)
+			}
+		})
+	}
+}
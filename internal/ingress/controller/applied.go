@@ -0,0 +1,99 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/audit"
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+// appliedConfiguration describes the configuration applied by a controller
+// pod, published so GitOps tools can detect drifts between the manifests and
+// the configuration served by NGINX
+type appliedConfiguration struct {
+	// Checksum is the checksum of the configuration recorded in the audit trail
+	Checksum string `json:"checksum"`
+	// Applied is the time when the configuration was applied
+	Applied time.Time `json:"applied"`
+	// RollbackOf is the revision of the configuration history applied again
+	// by a rollback, in which case the objects may not match the configuration
+	RollbackOf int `json:"rollbackOf,omitempty"`
+	// Objects contains the objects used to build the configuration with their
+	// resource versions and the checksum of each key
+	Objects []audit.Object `json:"objects"`
+}
+
+// publishAppliedConfiguration writes the applied configuration in the key of
+// the pod in the applied configuration ConfigMap when it changes, removing the
+// keys of the controller pods missing from the store. Errors are logged and
+// the configuration is written again in the next synchronization.
+func (n *NGINXController) publishAppliedConfiguration(ings []*ingress.Ingress, pcfg *ingress.Configuration, rollbackOf int) {
+	if n.cfg.AppliedConfigMap == "" {
+		return
+	}
+
+	applied := &appliedConfiguration{
+		Checksum:   configurationChecksum(pcfg),
+		RollbackOf: rollbackOf,
+		Objects:    n.auditObjects(ings, pcfg),
+	}
+
+	if n.applied != nil && n.applied.Checksum == applied.Checksum &&
+		n.applied.RollbackOf == applied.RollbackOf && reflect.DeepEqual(n.applied.Objects, applied.Objects) {
+		return
+	}
+
+	applied.Applied = time.Now().UTC()
+
+	data, err := json.Marshal(applied)
+	if err != nil {
+		klog.Warningf("Unexpected error encoding the applied configuration: %v", err)
+		return
+	}
+
+	// the keys of the pods not running anymore are removed
+	pods := sets.NewString(n.store.ListControllerPodNames()...)
+	pods.Insert(n.podInfo.Name)
+
+	ns, name, _ := k8s.ParseNameNS(n.cfg.AppliedConfigMap)
+	err = updateConfigMap(n.cfg.Client, ns, name, func(cm *apiv1.ConfigMap) {
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		for key := range cm.Data {
+			if !pods.Has(key) {
+				delete(cm.Data, key)
+			}
+		}
+		cm.Data[n.podInfo.Name] = string(data)
+	})
+	if err != nil {
+		klog.Warningf("Unexpected error writing the applied configuration in ConfigMap %v: %v", n.cfg.AppliedConfigMap, err)
+		return
+	}
+
+	n.applied = applied
+}
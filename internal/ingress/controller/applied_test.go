@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+func TestPublishAppliedConfiguration(t *testing.T) {
+	client := fake.NewSimpleClientset(&apiv1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "applied", Namespace: "ingress-nginx"},
+		Data:       map[string]string{"pod-0": "{}", "pod-2": "{}"},
+	})
+	n := &NGINXController{
+		cfg: &Configuration{
+			Client:           client,
+			AppliedConfigMap: "ingress-nginx/applied",
+		},
+		podInfo: &k8s.PodInfo{Name: "pod-1"},
+		store:   fakeIngressStore{pods: []string{"pod-1", "pod-2"}},
+	}
+
+	ing := namespaceConfigIngress("team-a")
+	ing.ResourceVersion = "1"
+	ings := []*ingress.Ingress{ing}
+	pcfg := &ingress.Configuration{}
+
+	read := func() appliedConfiguration {
+		cm, err := client.CoreV1().ConfigMaps("ingress-nginx").Get("applied", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error reading the ConfigMap: %v", err)
+		}
+
+		applied := appliedConfiguration{}
+		err = json.Unmarshal([]byte(cm.Data["pod-1"]), &applied)
+		if err != nil {
+			t.Fatalf("unexpected error decoding the applied configuration: %v", err)
+		}
+
+		return applied
+	}
+
+	n.publishAppliedConfiguration(ings, pcfg, 0)
+
+	cm, err := client.CoreV1().ConfigMaps("ingress-nginx").Get("applied", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error reading the ConfigMap: %v", err)
+	}
+	if _, ok := cm.Data["pod-0"]; ok {
+		t.Errorf("expected the key of the missing pod to be removed")
+	}
+	if _, ok := cm.Data["pod-2"]; !ok {
+		t.Errorf("expected the key of the running pod to be kept")
+	}
+
+	first := read()
+	if first.Checksum != configurationChecksum(pcfg) {
+		t.Errorf("expected checksum %v but returned %v", configurationChecksum(pcfg), first.Checksum)
+	}
+	if len(first.Objects) != 1 || first.Objects[0].Name != "ingress" || first.Objects[0].ResourceVersion != "1" {
+		t.Errorf("unexpected objects %+v", first.Objects)
+	}
+
+	n.publishAppliedConfiguration(ings, pcfg, 0)
+	if !read().Applied.Equal(first.Applied) {
+		t.Errorf("expected the applied configuration not to be written again without changes")
+	}
+
+	ing.ResourceVersion = "2"
+	n.publishAppliedConfiguration(ings, pcfg, 0)
+
+	second := read()
+	if second.Objects[0].ResourceVersion != "2" {
+		t.Errorf("expected resource version 2 but returned %v", second.Objects[0].ResourceVersion)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mitchellh/hashstructure"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/audit"
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// auditObjects returns the ConfigMap, the Ingresses and the Secrets with
+// the SSL certificates used to build the configuration
+func (n *NGINXController) auditObjects(ings []*ingress.Ingress, pcfg *ingress.Configuration) []audit.Object {
+	objects := []audit.Object{}
+
+	if n.cfg.ConfigMapName != "" {
+		cm, err := n.store.GetConfigMap(n.cfg.ConfigMapName)
+		if err == nil {
+			data := map[string]string{}
+			for k, v := range cm.Data {
+				data[k] = audit.Checksum([]byte(v))
+			}
+
+			objects = append(objects, audit.Object{
+				Kind:            "ConfigMap",
+				Namespace:       cm.Namespace,
+				Name:            cm.Name,
+				ResourceVersion: cm.ResourceVersion,
+				Data:            data,
+			})
+		}
+	}
+
+	for _, ing := range ings {
+		data := map[string]string{}
+		for k, v := range ing.Annotations {
+			data["annotations."+k] = audit.Checksum([]byte(v))
+		}
+
+		spec, err := json.Marshal(ing.Spec)
+		if err == nil {
+			data["spec"] = audit.Checksum(spec)
+		}
+
+		objects = append(objects, audit.Object{
+			Kind:            "Ingress",
+			Namespace:       ing.Namespace,
+			Name:            ing.Name,
+			ResourceVersion: ing.ResourceVersion,
+			Data:            data,
+		})
+	}
+
+	secrets := map[string]bool{}
+	for _, server := range pcfg.Servers {
+		if server.SSLCert.Name == "" {
+			continue
+		}
+
+		key := fmt.Sprintf("%v/%v", server.SSLCert.Namespace, server.SSLCert.Name)
+		if secrets[key] {
+			continue
+		}
+		secrets[key] = true
+
+		secret, err := n.store.GetSecret(key)
+		if err != nil {
+			continue
+		}
+
+		data := map[string]string{}
+		for k, v := range secret.Data {
+			data[k] = audit.Checksum(v)
+		}
+
+		objects = append(objects, audit.Object{
+			Kind:            "Secret",
+			Namespace:       secret.Namespace,
+			Name:            secret.Name,
+			ResourceVersion: secret.ResourceVersion,
+			Data:            data,
+		})
+	}
+
+	return objects
+}
+
+// configurationChecksum returns the checksum of the configuration recorded
+// in the audit trail
+func configurationChecksum(pcfg *ingress.Configuration) string {
+	hash, err := hashstructure.Hash(pcfg, &hashstructure.HashOptions{
+		TagName: "json",
+	})
+	if err != nil {
+		klog.Warningf("Unexpected error computing the checksum of the configuration: %v", err)
+	}
+
+	return fmt.Sprintf("%v", hash)
+}
+
+// recordAudit adds the applied configuration to the audit trail
+func (n *NGINXController) recordAudit(ings []*ingress.Ingress, pcfg *ingress.Configuration, reload bool, rollbackOf int) {
+	if n.audit == nil {
+		return
+	}
+
+	n.audit.Record(audit.Entry{
+		Checksum:   configurationChecksum(pcfg),
+		Reload:     reload,
+		RollbackOf: rollbackOf,
+	}, n.auditObjects(ings, pcfg))
+}
+
+// AuditEntries returns the entries of the audit trail, oldest first
+func (n *NGINXController) AuditEntries() []audit.Entry {
+	if n.audit == nil {
+		return nil
+	}
+
+	return n.audit.Entries()
+}
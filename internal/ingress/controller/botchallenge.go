@@ -0,0 +1,100 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/nginx"
+)
+
+// botChallengeKey is the key of the Secret containing the secret used to
+// sign the clearances of the bot challenges
+const botChallengeKey = "key"
+
+// botChallengeConfig is the configuration used by Lua to issue and check
+// the clearances of the bot challenges
+type botChallengeConfig struct {
+	Key string `json:"key"`
+	TTL int    `json:"ttl"`
+}
+
+// configureBotChallenge sends the key signing the clearances to NGINX, only
+// if it changed since the last call. Without Secret the key is generated by
+// the controller: the clearances are then only valid in its replica and
+// until it restarts.
+func (n *NGINXController) configureBotChallenge() error {
+	cfg := n.store.GetBackendConfiguration()
+
+	botChallenge := botChallengeConfig{
+		TTL: cfg.BotChallengeClearanceTTL,
+	}
+
+	if cfg.BotChallengeSecret != "" {
+		secret, err := n.store.GetSecret(cfg.BotChallengeSecret)
+		if err != nil {
+			klog.Warningf("Error reading the bot challenge secret %v, using a generated key: %v", cfg.BotChallengeSecret, err)
+		} else if key, ok := secret.Data[botChallengeKey]; !ok || len(key) == 0 {
+			klog.Warningf("Secret %v does not contain the key %q, using a generated key", cfg.BotChallengeSecret, botChallengeKey)
+		} else {
+			botChallenge.Key = string(key)
+		}
+	}
+
+	if botChallenge.Key == "" {
+		if n.botChallengeKey == "" {
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return err
+			}
+			n.botChallengeKey = hex.EncodeToString(key)
+		}
+		botChallenge.Key = n.botChallengeKey
+	}
+
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%v:%v", botChallenge.TTL, botChallenge.Key))))
+	if checksum == n.botChallengeChecksum {
+		return nil
+	}
+
+	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/bot-challenge", "application/json", botChallenge)
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	n.botChallengeChecksum = checksum
+	return nil
+}
+
+// syncBotChallenge configures the bot challenges, the errors are only logged
+// because the challenges must not prevent the synchronization
+func (n *NGINXController) syncBotChallenge() {
+	err := n.configureBotChallenge()
+	if err != nil {
+		klog.Warningf("Unexpected failure configuring the bot challenge: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/nginx"
+)
+
+func TestConfigureBotChallenge(t *testing.T) {
+	listener, err := net.Listen("unix", nginx.StatusSocket)
+	if err != nil {
+		t.Fatalf("crating unix listener: %s", err)
+	}
+	defer listener.Close()
+	defer os.Remove(nginx.StatusSocket)
+
+	configs := []botChallengeConfig{}
+	server := &httptest.Server{
+		Listener: listener,
+		Config: &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/configuration/bot-challenge" {
+					t.Errorf("unknown request to %s", r.URL.Path)
+				}
+
+				b, _ := ioutil.ReadAll(r.Body)
+				var config botChallengeConfig
+				if err := json.Unmarshal(b, &config); err != nil {
+					t.Errorf("unexpected bot challenge configuration %s: %v", b, err)
+				}
+				configs = append(configs, config)
+				w.WriteHeader(http.StatusCreated)
+			}),
+		},
+	}
+	defer server.Close()
+	server.Start()
+
+	cfg := ngx_config.NewDefault()
+	cfg.BotChallengeSecret = "default/bot-challenge"
+	fs := fakeDebugTokenStore{
+		fakeIngressStore: fakeIngressStore{configuration: cfg},
+		secrets:          map[string]*corev1.Secret{},
+	}
+	n := &NGINXController{store: fs}
+
+	// the generated key is kept between the calls
+	for i := 0; i < 2; i++ {
+		if err := n.configureBotChallenge(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(configs) != 1 {
+		t.Fatalf("expected one request but %v were sent", len(configs))
+	}
+	if len(configs[0].Key) != 64 || configs[0].TTL != 3600 {
+		t.Errorf("expected a generated key but the configuration was %+v", configs[0])
+	}
+
+	fs.secrets["default/bot-challenge"] = &corev1.Secret{
+		Data: map[string][]byte{"key": []byte("s3cr3t")},
+	}
+	n.store = fs
+
+	if err := n.configureBotChallenge(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(configs) != 2 || configs[1].Key != "s3cr3t" {
+		t.Errorf("expected the key of the secret but requests were %+v", configs)
+	}
+}
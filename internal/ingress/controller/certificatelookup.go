@@ -0,0 +1,203 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/ingress-nginx/internal/debug"
+	"k8s.io/ingress-nginx/internal/ingress"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/net/ssl"
+)
+
+// LookupCertificate returns the SSL certificate served for the SNI by the
+// running configuration, following the lookup of Lua when the dynamic
+// certificates are enabled and the server name selection of NGINX otherwise
+func (n *NGINXController) LookupCertificate(sni string) (*debug.CertificateLookup, error) {
+	pcfg := n.RunningConfiguration()
+	if pcfg.Equal(&ingress.Configuration{}) {
+		return nil, fmt.Errorf("the configuration is not synchronized yet")
+	}
+
+	return lookupCertificate(pcfg, sni, ngx_config.EnableDynamicCertificates, n.cfg.FakeCertificate), nil
+}
+
+// lookupCertificate finds the SSL certificate served for the SNI. An empty
+// SNI is a client without SNI support.
+func lookupCertificate(pcfg *ingress.Configuration, sni string, dynamic bool, fake *ingress.SSLCert) *debug.CertificateLookup {
+	var hostname, match string
+	var cert *ingress.SSLCert
+	if dynamic {
+		hostname, match, cert = lookupDynamicCertificate(pcfg, sni)
+	} else {
+		hostname, match, cert = lookupStaticCertificate(pcfg, sni)
+	}
+
+	if cert == nil {
+		match, cert = debug.MatchFake, fake
+	}
+
+	lookup := &debug.CertificateLookup{
+		SNI:      sni,
+		Hostname: hostname,
+		Match:    match,
+		Dynamic:  dynamic,
+	}
+
+	if cert == nil {
+		return lookup
+	}
+
+	if cert.Name != "" {
+		lookup.Secret = fmt.Sprintf("%v/%v", cert.Namespace, cert.Name)
+	}
+	lookup.CommonNames = cert.CN
+	lookup.Expires = cert.ExpireTime
+	if cert.Certificate != nil {
+		lookup.SerialNumber = cert.Certificate.SerialNumber.Text(16)
+		lookup.ValidForSNI = sni != "" && ssl.VerifyHostname(sni, cert.Certificate) == nil
+	}
+
+	return lookup
+}
+
+// lookupDynamicCertificate follows certificate.lua: the hostname, then the
+// hostname with a wildcard as first label and finally the default server
+func lookupDynamicCertificate(pcfg *ingress.Configuration, sni string) (string, string, *ingress.SSLCert) {
+	certs := map[string]*ingress.SSLCert{}
+	for _, sc := range dynamicCertificates(pcfg) {
+		if sc.cert.PemCertKey == "" && sc.cert.PemCertKeyFileName == "" && len(sc.cert.PemCertKeyCompressed) == 0 {
+			continue
+		}
+
+		certs[sc.hostname] = sc.cert
+	}
+
+	hostname := strings.TrimSuffix(sni, ".")
+	if hostname == "" {
+		hostname = defServerName
+	}
+
+	if cert, ok := certs[hostname]; ok {
+		if hostname == defServerName {
+			return hostname, debug.MatchDefault, cert
+		}
+		return hostname, debug.MatchExact, cert
+	}
+
+	if i := strings.Index(hostname, "."); i > 0 {
+		wildcard := "*" + hostname[i:]
+		if cert, ok := certs[wildcard]; ok {
+			return wildcard, debug.MatchWildcard, cert
+		}
+	}
+
+	if cert, ok := certs[defServerName]; ok {
+		return defServerName, debug.MatchDefault, cert
+	}
+
+	return "", "", nil
+}
+
+// lookupStaticCertificate follows the server name selection of NGINX among
+// the servers listening for HTTPS: the exact names, the longest leading
+// wildcard, the regular expressions in order and finally the default server
+func lookupStaticCertificate(pcfg *ingress.Configuration, sni string) (string, string, *ingress.SSLCert) {
+	names := map[string]*ingress.SSLCert{}
+	var regexs []*ingress.Server
+	var defaultCert *ingress.SSLCert
+
+	add := func(name string, cert *ingress.SSLCert) {
+		// the first server with a name is used by NGINX
+		if _, ok := names[name]; !ok {
+			names[name] = cert
+		}
+	}
+
+	for _, server := range pcfg.Servers {
+		if server.SSLCert.PemFileName == "" {
+			continue
+		}
+
+		if server.Hostname == defServerName {
+			defaultCert = &server.SSLCert
+			continue
+		}
+
+		add(server.Hostname, &server.SSLCert)
+		for _, alias := range strings.Fields(server.Alias) {
+			add(alias, &server.SSLCert)
+		}
+
+		if server.HostRegex.Regex != "" {
+			regexs = append(regexs, server)
+		}
+	}
+
+	// the redirects are defined before the servers in nginx.conf
+	for _, redirect := range buildRedirects(pcfg.Servers) {
+		if redirect.SSLCert.PemFileName != "" {
+			names[redirect.From] = &redirect.SSLCert
+		}
+	}
+
+	hostname := strings.ToLower(strings.TrimSuffix(sni, "."))
+	if hostname != "" {
+		if cert, ok := names[hostname]; ok && !strings.HasPrefix(hostname, "*.") {
+			return hostname, debug.MatchExact, cert
+		}
+
+		// the longest wildcard matching the hostname wins
+		for i := strings.Index(hostname, "."); i > 0; i = nextLabel(hostname, i) {
+			wildcard := "*" + hostname[i:]
+			if cert, ok := names[wildcard]; ok {
+				return wildcard, debug.MatchWildcard, cert
+			}
+		}
+
+		for _, server := range regexs {
+			re, err := regexp.Compile(server.HostRegex.Regex)
+			if err != nil {
+				continue
+			}
+
+			if re.MatchString(hostname) {
+				return server.HostRegex.Regex, debug.MatchRegex, &server.SSLCert
+			}
+		}
+	}
+
+	if defaultCert != nil {
+		return defServerName, debug.MatchDefault, defaultCert
+	}
+
+	return "", "", nil
+}
+
+// nextLabel returns the position of the dot after the label starting after
+// the dot at i, or -1
+func nextLabel(hostname string, i int) int {
+	j := strings.Index(hostname[i+1:], ".")
+	if j < 0 {
+		return -1
+	}
+
+	return i + 1 + j
+}
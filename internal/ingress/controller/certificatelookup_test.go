@@ -0,0 +1,124 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/x509"
+	"math/big"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/debug"
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/hostregex"
+)
+
+func newLookupCert(name string, serial int64, hosts ...string) ingress.SSLCert {
+	return ingress.SSLCert{
+		ObjectMeta:  metav1.ObjectMeta{Namespace: "default", Name: name},
+		Certificate: &x509.Certificate{SerialNumber: big.NewInt(serial), DNSNames: hosts},
+		CN:          hosts,
+		PemFileName: "/etc/ingress-controller/ssl/default-" + name + ".pem",
+		PemSHA:      name,
+		PemCertKey:  name,
+	}
+}
+
+func TestLookupCertificate(t *testing.T) {
+	fake := &ingress.SSLCert{
+		Certificate: &x509.Certificate{SerialNumber: big.NewInt(1)},
+		CN:          []string{"Kubernetes Ingress Controller Fake Certificate"},
+	}
+
+	withoutCert := &ingress.Server{Hostname: "plain.example.com"}
+	withoutCert.SSLCert.PemFileName = fake.PemFileName
+
+	pcfg := &ingress.Configuration{
+		Servers: []*ingress.Server{
+			{Hostname: defServerName, SSLCert: newLookupCert("default-tls", 2, "default.example.com")},
+			{Hostname: "foo.example.com", Alias: "bar.example.com invalid.example.org", SSLCert: newLookupCert("foo-tls", 3, "foo.example.com", "bar.example.com")},
+			{Hostname: "*.example.com", SSLCert: newLookupCert("wildcard-tls", 4, "*.example.com")},
+			{Hostname: "*.sub.example.com", SSLCert: newLookupCert("sub-tls", 5, "*.sub.example.com")},
+			{Hostname: "www.example.net", RedirectFromToWWW: true, SSLCert: newLookupCert("www-tls", 6, "www.example.net", "example.net")},
+			{Hostname: "app.example.io", HostRegex: hostregex.Config{Regex: `^[a-z]+-app\.example\.io$`}, SSLCert: newLookupCert("app-tls", 7, "*.example.io")},
+			withoutCert,
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		sni      string
+		dynamic  bool
+		hostname string
+		match    string
+		secret   string
+		valid    bool
+	}{
+		{"exact host", "foo.example.com", true, "foo.example.com", debug.MatchExact, "default/foo-tls", true},
+		{"exact host with a trailing dot", "foo.example.com.", true, "foo.example.com", debug.MatchExact, "default/foo-tls", true},
+		{"valid alias", "bar.example.com", true, "bar.example.com", debug.MatchExact, "default/foo-tls", true},
+		{"alias not valid for the certificate", "invalid.example.org", true, defServerName, debug.MatchDefault, "default/default-tls", false},
+		{"redirect", "example.net", true, "example.net", debug.MatchExact, "default/www-tls", true},
+		{"wildcard of the first label", "baz.example.com", true, "*.example.com", debug.MatchWildcard, "default/wildcard-tls", true},
+		{"wildcard of a single label only", "a.b.example.com", true, defServerName, debug.MatchDefault, "default/default-tls", false},
+		{"longest wildcard", "a.sub.example.com", true, "*.sub.example.com", debug.MatchWildcard, "default/sub-tls", true},
+		{"regular expressions are not used", "foo-app.example.io", true, defServerName, debug.MatchDefault, "default/default-tls", false},
+		{"server without certificate", "plain.example.com", true, "*.example.com", debug.MatchWildcard, "default/wildcard-tls", true},
+		{"without SNI", "", true, defServerName, debug.MatchDefault, "default/default-tls", false},
+
+		{"static exact host", "FOO.example.com", false, "foo.example.com", debug.MatchExact, "default/foo-tls", true},
+		{"static alias not valid for the certificate", "invalid.example.org", false, "invalid.example.org", debug.MatchExact, "default/foo-tls", false},
+		{"static redirect", "example.net", false, "example.net", debug.MatchExact, "default/www-tls", true},
+		{"static wildcard of several labels", "a.b.example.com", false, "*.example.com", debug.MatchWildcard, "default/wildcard-tls", false},
+		{"static longest wildcard", "a.sub.example.com", false, "*.sub.example.com", debug.MatchWildcard, "default/sub-tls", true},
+		{"static regular expression", "foo-app.example.io", false, `^[a-z]+-app\.example\.io$`, debug.MatchRegex, "default/app-tls", true},
+		{"static server without certificate", "plain.example.com", false, "*.example.com", debug.MatchWildcard, "default/wildcard-tls", true},
+		{"static without SNI", "", false, defServerName, debug.MatchDefault, "default/default-tls", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lookup := lookupCertificate(pcfg, tc.sni, tc.dynamic, fake)
+			if lookup.Hostname != tc.hostname || lookup.Match != tc.match || lookup.Secret != tc.secret || lookup.ValidForSNI != tc.valid {
+				t.Errorf("unexpected lookup %+v", lookup)
+			}
+			if lookup.SNI != tc.sni || lookup.Dynamic != tc.dynamic {
+				t.Errorf("unexpected lookup %+v", lookup)
+			}
+		})
+	}
+}
+
+func TestLookupCertificateFake(t *testing.T) {
+	fake := &ingress.SSLCert{Certificate: &x509.Certificate{SerialNumber: big.NewInt(255)}}
+	pcfg := &ingress.Configuration{
+		Servers: []*ingress.Server{
+			{Hostname: "foo.example.com", SSLCert: newLookupCert("foo-tls", 3, "foo.example.com")},
+		},
+	}
+
+	lookup := lookupCertificate(pcfg, "bar.example.com", true, fake)
+	if lookup.Match != debug.MatchFake || lookup.Hostname != "" || lookup.Secret != "" || lookup.SerialNumber != "ff" {
+		t.Errorf("unexpected lookup %+v", lookup)
+	}
+
+	lookup = lookupCertificate(pcfg, "bar.example.com", false, nil)
+	if lookup.Match != debug.MatchFake || lookup.SerialNumber != "" {
+		t.Errorf("unexpected lookup %+v", lookup)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/debug"
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
+	"k8s.io/ingress-nginx/internal/ingress/controller/store"
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+// certificateReportInterval is the period of the report of the SSL
+// certificates exported as metrics
+const certificateReportInterval = 5 * time.Minute
+
+// CertificateReport returns the SSL certificates synced to disk that are not
+// used anymore and the hosts of the running configuration serving the
+// default certificate although their Ingress references a secret
+func (n *NGINXController) CertificateReport() (*debug.CertificateReport, error) {
+	pcfg := n.RunningConfiguration()
+	if pcfg.Equal(&ingress.Configuration{}) {
+		return nil, fmt.Errorf("the configuration is not synchronized yet")
+	}
+
+	return buildCertificateReport(n.store, pcfg.Servers, n.cfg.DefaultSSLCertificate), nil
+}
+
+// reportCertificates exports the report of the SSL certificates until the
+// channel is closed
+func (n *NGINXController) reportCertificates(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		report, err := n.CertificateReport()
+		if err != nil {
+			return
+		}
+
+		if len(report.UnreferencedSecrets) > 0 {
+			klog.Infof("SSL certificates of secrets no Ingress references: %v", report.UnreferencedSecrets)
+		}
+		for host, secret := range report.DefaultCertificateHosts {
+			klog.Warningf("Host %q serves the default certificate instead of the certificate of the secret %q", host, secret)
+		}
+
+		n.metricCollector.SetSSLCertificateUsage(report.UnreferencedSecrets, report.SoonOrphanedSecrets, report.DefaultCertificateHosts)
+	}, certificateReportInterval, stopCh)
+}
+
+// buildCertificateReport compares the SSL certificates synced to disk with
+// their references and the certificates used by the servers. The custom
+// default certificates of the controller and of the classes are never reported.
+func buildCertificateReport(s store.Storer, servers []*ingress.Server, defaultSSLCertificate string) *debug.CertificateReport {
+	report := &debug.CertificateReport{
+		UnreferencedSecrets:     []string{},
+		SoonOrphanedSecrets:     []string{},
+		DefaultCertificateHosts: map[string]string{},
+	}
+
+	ingresses := s.ListIngresses(nil)
+
+	deleting := sets.NewString()
+	for _, ing := range ingresses {
+		if ing.DeletionTimestamp != nil {
+			deleting.Insert(k8s.MetaNamespaceKey(ing))
+		}
+	}
+
+	defaults := sets.NewString(defaultSSLCertificate)
+	for _, params := range s.ListIngressClassParams() {
+		defaults.Insert(params.Spec.DefaultSSLCertificate)
+	}
+
+	for _, cert := range s.ListLocalSSLCerts() {
+		key := k8s.MetaNamespaceKey(cert)
+		if defaults.Has(key) || class.IsDefaultSSLCertificate(key) {
+			continue
+		}
+
+		refs := s.GetSecretReferences(key)
+		if len(refs) == 0 {
+			report.UnreferencedSecrets = append(report.UnreferencedSecrets, key)
+			continue
+		}
+
+		if deleting.HasAll(refs...) {
+			report.SoonOrphanedSecrets = append(report.SoonOrphanedSecrets, key)
+		}
+	}
+
+	sort.Strings(report.UnreferencedSecrets)
+	sort.Strings(report.SoonOrphanedSecrets)
+
+	var defaultCertificate string
+	for _, server := range servers {
+		if server.Hostname == defServerName {
+			defaultCertificate = server.SSLCert.PemSHA
+		}
+	}
+
+	if defaultCertificate == "" {
+		return report
+	}
+
+	for _, server := range servers {
+		if server.Hostname == defServerName || server.SSLCert.PemSHA != defaultCertificate {
+			continue
+		}
+
+		// an empty secretName uses the default certificate on purpose
+		secret := tlsSecretKey(server.Hostname, ingresses)
+		if secret != "" && secret != defaultSSLCertificate {
+			report.DefaultCertificateHosts[server.Hostname] = secret
+		}
+	}
+
+	return report
+}
+
+// tlsSecretKey returns the key of the secret referenced by the first TLS
+// section listing the host, or an empty string
+func tlsSecretKey(host string, ingresses []*ingress.Ingress) string {
+	for _, ing := range ingresses {
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName != "" && sets.NewString(tls.Hosts...).Has(host) {
+				return fmt.Sprintf("%v/%v", ing.Namespace, tls.SecretName)
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+type fakeCertificateStore struct {
+	fakeIngressStore
+	certs      []*ingress.SSLCert
+	references map[string][]string
+}
+
+func (fcs fakeCertificateStore) ListLocalSSLCerts() []*ingress.SSLCert {
+	return fcs.certs
+}
+
+func (fcs fakeCertificateStore) GetSecretReferences(key string) []string {
+	return fcs.references[key]
+}
+
+func newTLSIngress(name, host, secret string, deleting bool) *ingress.Ingress {
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: networking.IngressSpec{
+				TLS: []networking.IngressTLS{
+					{Hosts: []string{host}, SecretName: secret},
+				},
+			},
+		},
+	}
+
+	if deleting {
+		now := metav1.Now()
+		ing.DeletionTimestamp = &now
+	}
+
+	return ing
+}
+
+func TestBuildCertificateReport(t *testing.T) {
+	cert := func(name, sha string) *ingress.SSLCert {
+		return &ingress.SSLCert{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			PemSHA:     sha,
+		}
+	}
+
+	s := fakeCertificateStore{
+		fakeIngressStore: fakeIngressStore{
+			ingresses: []*ingress.Ingress{
+				newTLSIngress("foo", "foo.bar", "foo-tls", false),
+				newTLSIngress("bar", "bar.baz", "bar-tls", true),
+				newTLSIngress("empty", "empty.bar", "", false),
+				newTLSIngress("custom", "custom.bar", "custom-default-tls", false),
+			},
+		},
+		certs: []*ingress.SSLCert{
+			cert("old-tls", "a"),
+			cert("foo-tls", "b"),
+			cert("bar-tls", "c"),
+			cert("custom-default-tls", "d"),
+			cert("abandoned-tls", "e"),
+		},
+		references: map[string][]string{
+			"default/foo-tls": {"default/foo"},
+			"default/bar-tls": {"default/bar"},
+		},
+	}
+
+	servers := []*ingress.Server{
+		{Hostname: defServerName, SSLCert: ingress.SSLCert{PemSHA: "fake"}},
+		{Hostname: "foo.bar", SSLCert: ingress.SSLCert{PemSHA: "fake"}},
+		{Hostname: "bar.baz", SSLCert: ingress.SSLCert{PemSHA: "c"}},
+		{Hostname: "empty.bar", SSLCert: ingress.SSLCert{PemSHA: "fake"}},
+		{Hostname: "custom.bar", SSLCert: ingress.SSLCert{PemSHA: "fake"}},
+		{Hostname: "plain.bar"},
+	}
+
+	report := buildCertificateReport(s, servers, "default/custom-default-tls")
+
+	expected := []string{"default/abandoned-tls", "default/old-tls"}
+	if !reflect.DeepEqual(report.UnreferencedSecrets, expected) {
+		t.Errorf("expected the unreferenced secrets %v but returned %v", expected, report.UnreferencedSecrets)
+	}
+
+	expected = []string{"default/bar-tls"}
+	if !reflect.DeepEqual(report.SoonOrphanedSecrets, expected) {
+		t.Errorf("expected the soon orphaned secrets %v but returned %v", expected, report.SoonOrphanedSecrets)
+	}
+
+	expectedHosts := map[string]string{"foo.bar": "default/foo-tls"}
+	if !reflect.DeepEqual(report.DefaultCertificateHosts, expectedHosts) {
+		t.Errorf("expected the default certificate hosts %v but returned %v", expectedHosts, report.DefaultCertificateHosts)
+	}
+}
+
+func TestBuildCertificateReportWithoutDefaultServer(t *testing.T) {
+	s := fakeCertificateStore{
+		fakeIngressStore: fakeIngressStore{
+			ingresses: []*ingress.Ingress{newTLSIngress("foo", "foo.bar", "foo-tls", false)},
+		},
+	}
+
+	report := buildCertificateReport(s, []*ingress.Server{{Hostname: "foo.bar"}}, "")
+	if len(report.UnreferencedSecrets) != 0 || len(report.SoonOrphanedSecrets) != 0 || len(report.DefaultCertificateHosts) != 0 {
+		t.Errorf("expected an empty report but returned %+v", report)
+	}
+}
@@ -21,13 +21,15 @@ import (
 	"strconv"
 	"time"
 
-	"k8s.io/klog"
-
 	apiv1 "k8s.io/api/core/v1"
 
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/defaults"
+	"k8s.io/ingress-nginx/internal/logging"
+	"k8s.io/ingress-nginx/internal/modsecurity"
+	"k8s.io/ingress-nginx/internal/net/dns"
 	"k8s.io/ingress-nginx/internal/runtime"
+	"k8s.io/ingress-nginx/internal/syslog"
 )
 
 var (
@@ -35,6 +37,10 @@ var (
 	EnableSSLChainCompletion = false
 	// EnableDynamicCertificates Dynamically update SSL certificates instead of reloading NGINX
 	EnableDynamicCertificates = true
+	// EnableOCSPStapling Staple the OCSP responses of the certificates defining an OCSP responder
+	EnableOCSPStapling = false
+	// EnableFIPSMode Restrict the certificates and the TLS configuration of the controller to FIPS approved algorithms
+	EnableFIPSMode = false
 )
 
 const (
@@ -115,6 +121,12 @@ type Configuration struct {
 	// By default access logs go to /var/log/nginx/access.log
 	AccessLogPath string `json:"access-log-path,omitempty"`
 
+	// AccessLogDestinations sets the destinations of the access logs, replacing
+	// AccessLogPath and the syslog server if defined
+	// http://nginx.org/en/docs/http/ngx_http_log_module.html#access_log
+	// By default this is empty
+	AccessLogDestinations []LogDestination `json:"access-log-destinations,omitempty"`
+
 	// WorkerCPUAffinity bind nginx worker processes to CPUs this will improve response latency
 	// http://nginx.org/en/docs/ngx_core_module.html#worker_cpu_affinity
 	// By default this is disabled
@@ -124,6 +136,12 @@ type Configuration struct {
 	// By default error logs go to /var/log/nginx/error.log
 	ErrorLogPath string `json:"error-log-path,omitempty"`
 
+	// ErrorLogDestinations sets the destinations of the error logs, replacing
+	// ErrorLogPath and the syslog server if defined
+	// http://nginx.org/en/docs/ngx_core_module.html#error_log
+	// By default this is empty
+	ErrorLogDestinations []LogDestination `json:"error-log-destinations,omitempty"`
+
 	// EnableDynamicTLSRecords enables dynamic TLS record sizes
 	// https://blog.cloudflare.com/optimizing-tls-over-tcp-to-reduce-latency
 	// By default this is enabled
@@ -137,6 +155,25 @@ type Configuration struct {
 	// By default this is disabled
 	EnableOWASPCoreRules bool `json:"enable-owasp-modsecurity-crs"`
 
+	// ModsecurityAuditMetrics exports the number of ModSecurity audit entries
+	// matching each rule, read by the controller in the audit log
+	ModsecurityAuditMetrics bool `json:"modsecurity-audit-metrics"`
+
+	// ModsecurityAuditSink is the HTTP endpoint (http[s]://<host>[:<port>]/<path>)
+	// the ModSecurity audit entries are sent to by the controller, in batches
+	// Default: empty (disabled)
+	ModsecurityAuditSink string `json:"modsecurity-audit-sink"`
+
+	// WAFEngine is the engine applying the ModSecurity annotations, modsecurity
+	// or coraza. Coraza runs outside of NGINX and is reached through CorazaURL
+	// By default this is modsecurity
+	WAFEngine string `json:"waf-engine"`
+
+	// CorazaURL is the endpoint (http[s]://<host>[:<port>]/<path>) of the Coraza
+	// proxy inspecting the requests when WAFEngine is coraza
+	// Default: empty
+	CorazaURL string `json:"coraza-url"`
+
 	// ClientHeaderBufferSize allows to configure a custom buffer
 	// size for reading client request header
 	// http://nginx.org/en/docs/http/ngx_http_core_module.html#client_header_buffer_size
@@ -241,6 +278,44 @@ type Configuration struct {
 	// http://nginx.org/en/docs/http/ngx_http_log_module.html#log_format
 	LogFormatStream string `json:"log-format-stream,omitempty"`
 
+	// EnableBackendMetadata sets the variables $backend_namespace, $backend_service
+	// and $backend_pod with the namespace, service and pod of the endpoint
+	// chosen by the balancer, so they can be used in the log formats
+	// By default this is disabled
+	EnableBackendMetadata bool `json:"enable-backend-metadata"`
+
+	// AddBackendMetadataHeaders adds the headers X-Backend-Namespace, X-Backend-Service
+	// and X-Backend-Pod to the responses. Requires EnableBackendMetadata.
+	// By default this is disabled
+	AddBackendMetadataHeaders bool `json:"add-backend-metadata-headers"`
+
+	// DebugTokenSecret is the namespace/name of a Secret containing in the
+	// key "key" the secret used to sign the tokens enabling debug headers
+	// in the responses. By default this is empty, disabling the debug headers
+	DebugTokenSecret string `json:"debug-token-secret"`
+
+	// DebugTokenMaxTTL is the maximum lifetime in seconds of a debug token
+	// Default: 3600
+	DebugTokenMaxTTL int `json:"debug-token-max-ttl"`
+
+	// BotChallengeSecret is the namespace/name of a Secret containing in the
+	// key "key" the secret used to sign the clearances of the clients which
+	// solved a bot challenge. By default this is empty, using a key generated
+	// by each controller
+	BotChallengeSecret string `json:"bot-challenge-secret"`
+
+	// BotChallengeClearanceTTL is the lifetime in seconds of the clearance
+	// of the clients which solved a bot challenge
+	// Default: 3600
+	BotChallengeClearanceTTL int `json:"bot-challenge-clearance-ttl"`
+
+	// EnableTLSFingerprints enables the computation of the JA3 and JA4
+	// fingerprints of the TLS clients by the SSL passthrough proxy, used
+	// by the variables $tls_ja3 and $tls_ja4 and the TLS fingerprint
+	// annotations. Requires the flag --enable-ssl-passthrough
+	// Default: false
+	EnableTLSFingerprints bool `json:"enable-tls-fingerprints"`
+
 	// If disabled, a worker process will accept one new connection at a time.
 	// Otherwise, a worker process will accept all new connections at a time.
 	// http://nginx.org/en/docs/ngx_core_module.html#multi_accept
@@ -537,6 +612,26 @@ type Configuration struct {
 	// LocationSnippet adds custom configuration to all the locations in the nginx configuration
 	LocationSnippet string `json:"location-snippet"`
 
+	// MaxHostsPerNamespace sets the maximum number of hosts defined by the Ingresses
+	// of a namespace. The Ingresses adding hosts over the limit are ignored.
+	// Default: 0 (unlimited)
+	MaxHostsPerNamespace int `json:"max-hosts-per-namespace"`
+
+	// MaxLocationsPerIngress sets the maximum number of paths of an Ingress.
+	// The Ingresses over the limit are ignored.
+	// Default: 0 (unlimited)
+	MaxLocationsPerIngress int `json:"max-locations-per-ingress"`
+
+	// MaxSnippetLength sets the maximum length of the snippet annotations of an
+	// Ingress. The Ingresses with a longer snippet are ignored.
+	// Default: 0 (unlimited)
+	MaxSnippetLength int `json:"max-snippet-length"`
+
+	// MaxServerBlocks sets the maximum number of servers of the configuration.
+	// The Ingresses adding hosts over the limit are ignored.
+	// Default: 0 (unlimited)
+	MaxServerBlocks int `json:"max-server-blocks"`
+
 	// HTTPRedirectCode sets the HTTP status code to be used in redirects.
 	// Supported codes are 301,302,307 and 308
 	// Default: 308
@@ -563,6 +658,69 @@ type Configuration struct {
 	// Default: 503
 	LimitConnStatusCode int `json:"limit-conn-status-code"`
 
+	// MaxConnections is the maximum number of concurrent connections, the
+	// requests being processed, of all the servers. 0 disables the limit.
+	MaxConnections int `json:"max-connections"`
+
+	// MaxConnectionsPerHost is the maximum number of concurrent connections of
+	// each server, replaced by the max-connections-per-host annotation. 0 disables the limit.
+	MaxConnectionsPerHost int `json:"max-connections-per-host"`
+
+	// ConnectionLimitStatusCode is the status code of the requests rejected by
+	// max-connections and max-connections-per-host. 0 uses LimitConnStatusCode.
+	// Default: 0
+	ConnectionLimitStatusCode int `json:"connection-limit-status-code"`
+
+	// ConnectionLimitRetryAfter is the value in seconds of the Retry-After header
+	// of the requests rejected by max-connections and max-connections-per-host
+	// Default: 1
+	ConnectionLimitRetryAfter int `json:"connection-limit-retry-after"`
+
+	// NoEndpointsRetryAfter is the value in seconds of the Retry-After header
+	// of the 503 responses of the backends without endpoints. 0 disables the header.
+	// Default: 0
+	NoEndpointsRetryAfter int `json:"no-endpoints-retry-after"`
+
+	// NoEndpointsRetryAfterMax is the maximum value of the Retry-After header,
+	// doubled from NoEndpointsRetryAfter while the backend has no endpoints.
+	// Default: 0 (the Retry-After header does not grow)
+	NoEndpointsRetryAfterMax int `json:"no-endpoints-retry-after-max"`
+
+	// NoEndpointsPage is the HTML page sent in the 503 responses of the
+	// backends without endpoints, i.e. while an application is deployed
+	NoEndpointsPage string `json:"no-endpoints-page"`
+
+	// RejectAmbiguousRequests rejects with a 400 status code the requests
+	// defining both the Transfer-Encoding and the Content-Length headers, several
+	// Content-Length headers or a Transfer-Encoding other than chunked, which
+	// servers behind the controller could interpret differently.
+	// Default: false
+	RejectAmbiguousRequests bool `json:"reject-ambiguous-requests"`
+
+	// NormalizeDuplicateHeaders merges the values of a request header defined
+	// several times in a single header and rejects the requests defining several
+	// times a header allowing only one value, like Host or Authorization.
+	// Default: false
+	NormalizeDuplicateHeaders bool `json:"normalize-duplicate-headers"`
+
+	// MaxRequestHeaders is the maximum number of headers of a request,
+	// the requests defining more headers are rejected. 0 disables the limit.
+	// Default: 0
+	MaxRequestHeaders int `json:"max-request-headers"`
+
+	// MaxRequestHeaderSize is the maximum size in bytes of the name and the
+	// value of a request header, the requests with a larger header are rejected.
+	// 0 disables the limit.
+	// Default: 0
+	MaxRequestHeaderSize int `json:"max-request-header-size"`
+
+	// DecodePathTraversal decodes the percent-encoded dots, slashes and backslashes
+	// of the path of the requests, so the upstream servers receive the path used
+	// to choose the location. The requests still traversing the path after a
+	// second decoding are rejected.
+	// Default: false
+	DecodePathTraversal bool `json:"decode-path-traversal"`
+
 	// EnableSyslog enables the configuration for remote logging in NGINX
 	EnableSyslog bool `json:"enable-syslog"`
 	// SyslogHost FQDN or IP address where the logs should be sent
@@ -603,6 +761,95 @@ type Configuration struct {
 
 	// Block all requests with given Referer headers
 	BlockReferers []string `json:"block-referers"`
+
+	// NamespaceDefaultsConfigMap is the name of the ConfigMap, located in the
+	// namespace of each Ingress, that contains the default annotations for the
+	// Ingresses of the namespace. The keys are annotation names without prefix.
+	// Default: empty (disabled)
+	NamespaceDefaultsConfigMap string `json:"namespace-defaults-configmap"`
+
+	// AllowedAnnotationOverrides is the list of annotations (without prefix)
+	// that can be set in namespace defaults and Ingress annotations.
+	// Any other annotation uses the value from this ConfigMap.
+	// Default: empty (all annotations are allowed)
+	AllowedAnnotationOverrides []string `json:"allowed-annotation-overrides"`
+
+	// ConfigFreeze postpones the configuration changes, except the endpoints
+	// of the existing backends and the renewed certificates, until it is disabled
+	// Default: false
+	ConfigFreeze bool `json:"config-freeze"`
+
+	// AccessEventsSink is the HTTP collector (http://<host>/<path>) or the Kafka
+	// topic (kafka://<host>/<topic>, through a Kafka REST proxy) receiving the
+	// access events of the requests
+	// Default: empty (disabled)
+	AccessEventsSink string `json:"access-events-sink"`
+
+	// AccessEventsBatchSize is the maximum number of access events sent at once
+	AccessEventsBatchSize int `json:"access-events-batch-size"`
+
+	// AccessEventsFlushInterval is the number of seconds between two batches
+	AccessEventsFlushInterval int `json:"access-events-flush-interval"`
+
+	// AccessEventsBufferSize is the maximum number of access events buffered by
+	// each NGINX worker while the sink is unavailable. The new events are dropped
+	// when it is reached.
+	AccessEventsBufferSize int `json:"access-events-buffer-size"`
+
+	// EnableUsageAccounting counts the requests and the bytes received and sent
+	// for each Ingress, exposed as Prometheus metrics for chargeback
+	// Default: false
+	EnableUsageAccounting bool `json:"enable-usage-accounting"`
+
+	// UsageExportSink is the HTTP collector (http://<host>/<path>), the Kafka
+	// topic (kafka://<host>/<topic>, through a Kafka REST proxy) or the file
+	// (file://<name>, in /var/log/nginx/usage/) receiving the usage of each
+	// Ingress periodically
+	// Default: empty (disabled)
+	UsageExportSink string `json:"usage-export-sink"`
+
+	// UsageExportFormat is the format of the exported usage, json or csv.
+	// The records are always JSON objects for Kafka.
+	UsageExportFormat string `json:"usage-export-format"`
+
+	// UsageExportInterval is the number of seconds between two exports
+	UsageExportInterval int `json:"usage-export-interval"`
+
+	// SharedStateBackend is the Redis server (redis://[:<password>@]<host>[:<port>][/<db>])
+	// sharing the canary weights and the failover verdicts between the replicas
+	// Default: empty (disabled)
+	SharedStateBackend string `json:"shared-state-backend"`
+
+	// SharedStatePrefix is the prefix of the keys written in the shared state
+	// backend, so several controllers can use the same server
+	SharedStatePrefix string `json:"shared-state-prefix"`
+
+	// SharedStateSyncInterval is the number of seconds between two
+	// synchronizations of each NGINX worker with the shared state backend
+	SharedStateSyncInterval int `json:"shared-state-sync-interval"`
+
+	// SharedStateTimeout is the timeout in milliseconds of the requests sent to
+	// the shared state backend
+	SharedStateTimeout int `json:"shared-state-timeout"`
+
+	// Resolvers are the DNS servers used by NGINX and the chain completion
+	// instead of the nameservers of /etc/resolv.conf. The queries are forwarded
+	// by a DNS proxy running in the controller, which supports DNS over TLS
+	// and DNS over HTTPS.
+	// Default: empty (the nameservers of /etc/resolv.conf)
+	Resolvers []dns.Upstream `json:"resolvers"`
+
+	// ZoneResolvers are the DNS servers resolving the names of a zone and its
+	// subdomains. The servers of the longest matching zone are used.
+	ZoneResolvers map[string][]dns.Upstream `json:"zone-resolvers"`
+
+	// ResolverNegativeTTL is the maximum number of seconds the NXDOMAIN and
+	// empty answers are cached. 0 keeps the TTL returned by the DNS servers.
+	ResolverNegativeTTL int `json:"resolver-negative-ttl"`
+
+	// DNSProxyPort is the port of the DNS proxy of the controller when the
+	// configuration requires it, set before rendering the template
+	DNSProxyPort int `json:"-"`
 }
 
 // NewDefault returns the default nginx configuration
@@ -618,7 +865,7 @@ func NewDefault() Configuration {
 	defNginxStatusIpv4Whitelist = append(defNginxStatusIpv4Whitelist, "127.0.0.1")
 	defNginxStatusIpv6Whitelist = append(defNginxStatusIpv6Whitelist, "::1")
 	defProxyDeadlineDuration := time.Duration(5) * time.Second
-	defGlobalExternalAuth := GlobalExternalAuth{"", "", "", "", append(defResponseHeaders, ""), "", ""}
+	defGlobalExternalAuth := GlobalExternalAuth{"", "", "", "", append(defResponseHeaders, ""), "", "", false, []string{}, map[string]string{}, "", ""}
 
 	cfg := Configuration{
 		AllowBackendServerHeader:         false,
@@ -627,6 +874,8 @@ func NewDefault() Configuration {
 		EnableAccessLogForDefaultBackend: false,
 		WorkerCPUAffinity:                "",
 		ErrorLogPath:                     "/var/log/nginx/error.log",
+		AccessLogDestinations:            []LogDestination{},
+		ErrorLogDestinations:             []LogDestination{},
 		BlockCIDRs:                       defBlockEntity,
 		BlockUserAgents:                  defBlockEntity,
 		BlockReferers:                    defBlockEntity,
@@ -660,6 +909,11 @@ func NewDefault() Configuration {
 		LargeClientHeaderBuffers:         "4 8k",
 		LogFormatEscapeJSON:              false,
 		LogFormatStream:                  logFormatStream,
+		EnableBackendMetadata:            false,
+		AddBackendMetadataHeaders:        false,
+		DebugTokenSecret:                 "",
+		DebugTokenMaxTTL:                 3600,
+		BotChallengeClearanceTTL:         3600,
 		LogFormatUpstream:                logFormatUpstream,
 		EnableMultiAccept:                true,
 		MaxWorkerConnections:             16384,
@@ -694,27 +948,31 @@ func NewDefault() Configuration {
 		UseHTTP2:                         true,
 		ProxyStreamTimeout:               "600s",
 		Backend: defaults.Backend{
-			ProxyBodySize:            bodySize,
-			ProxyConnectTimeout:      5,
-			ProxyReadTimeout:         60,
-			ProxySendTimeout:         60,
-			ProxyBuffersNumber:       4,
-			ProxyBufferSize:          "4k",
-			ProxyCookieDomain:        "off",
-			ProxyCookiePath:          "off",
-			ProxyNextUpstream:        "error timeout",
-			ProxyNextUpstreamTimeout: 0,
-			ProxyNextUpstreamTries:   3,
-			ProxyRequestBuffering:    "on",
-			ProxyRedirectFrom:        "off",
-			ProxyRedirectTo:          "off",
-			SSLRedirect:              true,
-			CustomHTTPErrors:         []int{},
-			WhitelistSourceRange:     []string{},
-			SkipAccessLogURLs:        []string{},
-			LimitRate:                0,
-			LimitRateAfter:           0,
-			ProxyBuffering:           "off",
+			ProxyBodySize:               bodySize,
+			ProxyConnectTimeout:         5,
+			ProxyReadTimeout:            60,
+			ProxySendTimeout:            60,
+			ProxyBuffersNumber:          4,
+			ProxyBufferSize:             "4k",
+			ProxyCookieDomain:           "off",
+			ProxyCookiePath:             "off",
+			ProxyNextUpstream:           "error timeout",
+			ProxyNextUpstreamTimeout:    0,
+			ProxyNextUpstreamTries:      3,
+			ProxyRequestBuffering:       "on",
+			ProxyRedirectFrom:           "off",
+			ProxyRedirectTo:             "off",
+			SSLRedirect:                 true,
+			CustomHTTPErrors:            []int{},
+			WhitelistSourceRange:        []string{},
+			SkipAccessLogURLs:           []string{},
+			LimitRate:                   0,
+			LimitRateAfter:              0,
+			ProxyBuffering:              "off",
+			TrafficCaptureMaxSampleRate: 0.1,
+			TrafficCaptureMaxRPS:        100,
+			TrafficCaptureDeniedHeaders: "authorization,cookie,proxy-authorization,set-cookie,x-api-key,x-auth-token,x-csrf-token,x-forwarded-for,x-real-ip",
+			AllowTrafficCaptureBody:     false,
 		},
 		UpstreamKeepaliveConnections: 32,
 		UpstreamKeepaliveTimeout:     60,
@@ -736,13 +994,26 @@ func NewDefault() Configuration {
 		DatadogOperationNameOverride: "nginx.handle",
 		LimitReqStatusCode:           503,
 		LimitConnStatusCode:          503,
+		ConnectionLimitRetryAfter:    1,
 		SyslogPort:                   514,
 		NoTLSRedirectLocations:       "/.well-known/acme-challenge",
 		NoAuthLocations:              "/.well-known/acme-challenge",
 		GlobalExternalAuth:           defGlobalExternalAuth,
+		AccessEventsBatchSize:        100,
+		AccessEventsFlushInterval:    1,
+		AccessEventsBufferSize:       10000,
+		UsageExportFormat:            "json",
+		UsageExportInterval:          60,
+		SharedStatePrefix:            "ingress-nginx",
+		SharedStateSyncInterval:      1,
+		SharedStateTimeout:           100,
+		Resolvers:                    []dns.Upstream{},
+		ZoneResolvers:                map[string][]dns.Upstream{},
+		WAFEngine:                    "modsecurity",
 	}
 
-	if klog.V(5) {
+	// the debug mode of NGINX follows the verbosity of the management of NGINX
+	if logging.Level("nginx") >= 5 {
 		cfg.ErrorLogLevel = "debug"
 	}
 
@@ -760,6 +1031,74 @@ func (cfg Configuration) BuildLogFormatUpstream() string {
 	return cfg.LogFormatUpstream
 }
 
+// SyslogRemotes returns the syslog servers NGINX cannot send the logs to
+// without a relay
+func (cfg Configuration) SyslogRemotes() []syslog.Remote {
+	remotes := []syslog.Remote{}
+	seen := map[syslog.Remote]bool{}
+
+	destinations := append([]LogDestination{}, cfg.AccessLogDestinations...)
+	destinations = append(destinations, cfg.ErrorLogDestinations...)
+	for _, destination := range destinations {
+		if destination.Relay == nil || seen[*destination.Relay] {
+			continue
+		}
+
+		seen[*destination.Relay] = true
+		remotes = append(remotes, *destination.Relay)
+	}
+
+	return remotes
+}
+
+// ModsecurityAuditConfig returns what the controller does with the ModSecurity audit log
+func (cfg Configuration) ModsecurityAuditConfig() modsecurity.Config {
+	return modsecurity.Config{
+		Metrics: cfg.ModsecurityAuditMetrics,
+		Sink:    cfg.ModsecurityAuditSink,
+	}
+}
+
+// UseCoraza returns true when the ModSecurity annotations are applied by the Coraza proxy
+func (cfg Configuration) UseCoraza() bool {
+	return cfg.WAFEngine == "coraza" && cfg.CorazaURL != ""
+}
+
+// RequestNormalizationEnabled returns true when a request normalization rule is enabled
+func (cfg Configuration) RequestNormalizationEnabled() bool {
+	return cfg.RejectAmbiguousRequests || cfg.NormalizeDuplicateHeaders ||
+		cfg.MaxRequestHeaders > 0 || cfg.MaxRequestHeaderSize > 0 || cfg.DecodePathTraversal
+}
+
+// DNSProxyConfig returns the configuration of the DNS proxy of the controller
+func (cfg Configuration) DNSProxyConfig() dns.Config {
+	negativeTTL := 0
+	if cfg.ResolverNegativeTTL > 0 {
+		negativeTTL = cfg.ResolverNegativeTTL
+	}
+
+	return dns.Config{
+		Upstreams:   cfg.Resolvers,
+		Zones:       cfg.ZoneResolvers,
+		NegativeTTL: uint32(negativeTTL),
+	}
+}
+
+// LogDestination defines a destination of the access or error logs
+type LogDestination struct {
+	// Type is file, stderr or syslog
+	Type string `json:"type"`
+	// Target is the destination used in the access_log and error_log directives
+	Target string `json:"target"`
+	// Format is the name of the log_format used by the access logs
+	Format string `json:"format,omitempty"`
+	// Severity is the level of the error logs
+	Severity string `json:"severity,omitempty"`
+	// Relay is the syslog server reached through the controller when
+	// NGINX cannot send the logs directly (TCP and TLS)
+	Relay *syslog.Remote `json:"relay,omitempty"`
+}
+
 // TemplateConfig contains the nginx configuration to render the file nginx.conf
 type TemplateConfig struct {
 	ProxySetHeaders           map[string]string
@@ -781,11 +1120,15 @@ type TemplateConfig struct {
 	PublishService            *apiv1.Service
 	EnableDynamicCertificates bool
 	EnableMetrics             bool
-
-	PID          string
-	StatusSocket string
-	StatusPath   string
-	StreamSocket string
+	// DebugHosts contains the hosts with the NGINX debug logs enabled
+	DebugHosts map[string]bool
+
+	PID                    string
+	StatusSocket           string
+	StatusPath             string
+	AccessEventsStatusPath string
+	UsageStatusPath        string
+	StreamSocket           string
 }
 
 // ListenPorts describe the ports required to run the
@@ -796,6 +1139,14 @@ type ListenPorts struct {
 	Health   int
 	Default  int
 	SSLProxy int
+	DNS      int
+	// AdditionalHTTPS contains the HTTPS ports of the servers requiring
+	// client certificates on a distinct listener
+	AdditionalHTTPS []int
+	// InternalHTTP and InternalHTTPS are the ports of the internal listener,
+	// the only one serving the hosts of the internal Ingresses
+	InternalHTTP  int
+	InternalHTTPS int
 }
 
 // GlobalExternalAuth describe external authentication configuration for the
@@ -809,4 +1160,18 @@ type GlobalExternalAuth struct {
 	ResponseHeaders []string `json:"responseHeaders,omitempty"`
 	RequestRedirect string   `json:"requestRedirect"`
 	AuthSnippet     string   `json:"authSnippet"`
+	// ResponseHeadersToClient adds the auth response headers to the
+	// response sent to the client
+	ResponseHeadersToClient bool `json:"responseHeadersToClient,omitempty"`
+	// ForwardHeaders restricts the client headers sent to the auth service.
+	// If empty all the client headers are forwarded.
+	ForwardHeaders []string `json:"forwardHeaders,omitempty"`
+	// RequestHeaders contains static headers added to the auth subrequest
+	RequestHeaders map[string]string `json:"requestHeaders,omitempty"`
+	// MaxBodySize enables forwarding the request body to the auth service
+	// up to the configured size
+	MaxBodySize string `json:"maxBodySize,omitempty"`
+	// CacheCookie is the name of the session cookie used as key to cache
+	// the responses of the auth service
+	CacheCookie string `json:"cacheCookie,omitempty"`
 }
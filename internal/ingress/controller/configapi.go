@@ -0,0 +1,39 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// RunningConfiguration returns the configuration applied by the last
+// successful synchronization
+func (n *NGINXController) RunningConfiguration() *ingress.Configuration {
+	n.runningConfigLock.RLock()
+	defer n.runningConfigLock.RUnlock()
+
+	return n.runningConfig
+}
+
+// setRunningConfig replaces the running configuration. The configuration
+// must not be modified after this call.
+func (n *NGINXController) setRunningConfig(pcfg *ingress.Configuration) {
+	n.runningConfigLock.Lock()
+	defer n.runningConfigLock.Unlock()
+
+	n.runningConfig = pcfg
+}
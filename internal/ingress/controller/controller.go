@@ -17,7 +17,10 @@ limitations under the License.
 package controller
 
 import (
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -30,13 +33,26 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/ingress-nginx/internal/dashboard"
+	"k8s.io/ingress-nginx/internal/freeze"
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/auth"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/failover"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sslpin"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/controller/store"
 	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/logging"
+	"k8s.io/ingress-nginx/internal/net/ssl"
+	"k8s.io/ingress-nginx/internal/profiling"
+	"k8s.io/ingress-nginx/internal/task"
+	"k8s.io/ingress-nginx/pkg/client/clientset/versioned"
 	"k8s.io/klog"
 )
 
@@ -46,6 +62,12 @@ const (
 	rootLocation    = "/"
 )
 
+// podOrdinalRegex extracts the ordinal from the name of a StatefulSet Pod
+var podOrdinalRegex = regexp.MustCompile(`-(\d+)$`)
+
+// streamTimeoutRegex matches the NGINX times accepted by the timeout of a UDP service
+var streamTimeoutRegex = regexp.MustCompile(`^[1-9][0-9]*(ms|s|m|h)?$`)
+
 // Configuration contains all the settings required by an Ingress controller
 type Configuration struct {
 	APIServerHost  string
@@ -75,12 +97,20 @@ type Configuration struct {
 	ElectionID             string
 	UpdateStatusOnShutdown bool
 
+	ElectionLeaseDuration time.Duration
+	ElectionRenewDeadline time.Duration
+	ElectionRetryPeriod   time.Duration
+
 	ListenPorts *ngx_config.ListenPorts
 
 	EnableSSLPassthrough bool
 
 	EnableProfiling bool
 
+	// ContinuousProfiling defines when the profiles of the controller are
+	// captured, nil disables the continuous profiling
+	ContinuousProfiling *profiling.Config
+
 	EnableMetrics  bool
 	MetricsPerHost bool
 
@@ -90,10 +120,75 @@ type Configuration struct {
 
 	DisableCatchAll bool
 
+	StrictAnnotationValidation bool
+
 	ValidationWebhook         string
 	ValidationWebhookCertPath string
 	ValidationWebhookKeyPath  string
 
+	// the SVID of the SPIFFE Workload API replaces the certificate and key of the webhook
+	WebhookSPIFFESocket string
+	WebhookSPIFFEIDs    []string
+
+	// the certificate of the webhook is issued by the controller and stored in this secret
+	WebhookCertificateSecret string
+	WebhookService           string
+	WebhookConfiguration     string
+
+	// the TLS Secrets referenced by the Ingresses are verified by the webhook
+	WebhookVerifyTLSSecrets bool
+
+	TrafficAPIAddress string
+	TrafficAPIToken   string
+
+	ConfigurationAPIAddress string
+	ConfigurationAPIToken   string
+
+	DashboardAddress     string
+	DashboardCredentials *dashboard.Credentials
+
+	EnableHostDelegation bool
+	DelegationClient     versioned.Interface
+
+	EnableIngressClassParams bool
+	IngressClassParamsClient versioned.Interface
+
+	EnableEndpointWeights    bool
+	EnableEndpointConditions bool
+
+	SecretServiceAccount string
+	SecretImpersonation  bool
+	SecretReader         store.SecretReader
+
+	RequireTmpfsSSLDirectory bool
+
+	// the PEM files no certificate uses are removed from the SSL directory
+	// every SSLFilesGCInterval, only logged with SSLFilesGCDryRun
+	SSLFilesGCInterval time.Duration
+	SSLFilesGCDryRun   bool
+
+	ConfigFreezeWindows []freeze.Window
+
+	ConfigHistorySize int
+
+	AuditSize      int
+	AuditConfigMap string
+
+	NamespaceConfigMap string
+
+	AppliedConfigMap string
+
+	WarmupTargets []ProbeTarget
+	WarmupTimeout time.Duration
+
+	ProbeTargets  []ProbeTarget
+	ProbeInterval time.Duration
+
+	// the GeoIP2 databases are downloaded with the MaxMind license key of this secret
+	GeoIPLicenseSecret  string
+	GeoIPEditions       []string
+	GeoIPUpdateInterval time.Duration
+
 	GlobalExternalAuth *ngx_config.GlobalExternalAuth
 }
 
@@ -107,28 +202,110 @@ func (n NGINXController) GetPublishService() *apiv1.Service {
 	return s
 }
 
+// isPrioritySync returns true when the element of the sync queue has a high
+// priority
+func isPrioritySync(key interface{}) bool {
+	item, ok := key.(task.Element)
+	return ok && item.Priority == task.HighPriority
+}
+
+// forceSync enqueues a synchronization which is not skipped when no object
+// changed, for the changes made outside of the objects of the store
+func (n *NGINXController) forceSync(name string) {
+	n.syncLock.Lock()
+	n.syncedRevision = 0
+	n.syncLock.Unlock()
+
+	n.syncQueue.EnqueueTask(task.GetDummyObject(name))
+}
+
 // syncIngress collects all the pieces required to assemble the NGINX
 // configuration file and passes the resulting data structures to the backend
 // (OnUpdate) when a reload is deemed necessary.
-func (n *NGINXController) syncIngress(interface{}) error {
+func (n *NGINXController) syncIngress(key interface{}) (syncErr error) {
 	n.syncRateLimiter.Accept()
 
 	if n.syncQueue.IsShuttingDown() {
 		return nil
 	}
 
+	n.syncLock.Lock()
+	defer n.syncLock.Unlock()
+
+	// the configuration is only built again if an object changed
+	// since the last synchronization applying all the changes
+	revision, stable := n.store.GetObjectsRevision()
+	if stable && revision != 0 && revision == n.syncedRevision {
+		logging.V(3).Infof("No object changed since revision %v, skipping configuration synchronization.", revision)
+		n.warmup.setSynced()
+		return nil
+	}
+
+	n.syncedRevision = 0
+	if !stable {
+		revision = 0
+	}
+
 	ings := n.store.ListIngresses(nil)
 	hosts, servers, pcfg := n.getConfiguration(ings)
 
 	n.metricCollector.SetSSLExpireTime(servers)
+	n.metricCollector.SetSSLCAExpireTime(n.store.ListLocalSSLCerts())
+	n.metricCollector.SetSSLCertificateExpireTime(n.store.ListLocalSSLCerts(), servers)
+	n.metricCollector.SetBackendsWithoutEndpoints(pcfg)
+
+	sources := n.configurationSources(ings)
+	if n.rollbackSources != nil {
+		if sourcesEqual(n.rollbackSources, sources) {
+			pcfg = frozenConfiguration(n.runningConfig, pcfg)
+			revision = 0
+		} else {
+			klog.Infof("Objects changed after the rollback of the configuration, applying the new configuration.")
+			n.rollbackSources = nil
+		}
+	}
+
+	// a synchronization with a high priority only applies the changes of the
+	// endpoints and certificates, the other changes are applied when the
+	// element is processed again with a normal priority. Without running
+	// configuration, after a restart, the whole configuration is applied.
+	if isPrioritySync(key) && !n.runningConfig.Equal(&ingress.Configuration{}) {
+		if priority := frozenConfiguration(n.runningConfig, pcfg); !priority.Equal(pcfg) {
+			klog.Infof("Applying the changes of endpoints and certificates before the other configuration changes.")
+			pcfg = priority
+			revision = 0
+
+			defer func() {
+				if syncErr == nil {
+					syncErr = task.ErrPartialSync
+				}
+			}()
+		}
+	}
 
 	if n.runningConfig.Equal(pcfg) {
-		klog.V(3).Infof("No configuration change detected, skipping backend reload.")
+		logging.V(3).Infof("No configuration change detected, skipping backend reload.")
+		n.syncDebugToken()
+		n.syncBotChallenge()
+		n.syncedRevision = revision
+		n.warmup.setSynced()
 		return nil
 	}
 
+	if reason := n.configFreezeReason(time.Now()); reason != "" && !n.runningConfig.Equal(&ingress.Configuration{}) {
+		pcfg = frozenConfiguration(n.runningConfig, pcfg)
+		revision = 0
+		if n.runningConfig.Equal(pcfg) {
+			klog.Infof("Configuration changes detected but %v, postponing the changes.", reason)
+			return nil
+		}
+
+		klog.Infof("Configuration changes detected but %v, applying only endpoints and certificates.", reason)
+	}
+
 	n.metricCollector.SetHosts(hosts)
 
+	reloaded := false
 	if !n.IsDynamicConfigurationEnough(pcfg) {
 		klog.Infof("Configuration changes detected, backend reload required.")
 
@@ -138,7 +315,8 @@ func (n *NGINXController) syncIngress(interface{}) error {
 
 		pcfg.ConfigurationChecksum = fmt.Sprintf("%v", hash)
 
-		err := n.OnUpdate(*pcfg)
+		var err error
+		reloaded, err = n.OnUpdate(*pcfg)
 		if err != nil {
 			n.metricCollector.IncReloadErrorCount()
 			n.metricCollector.ConfigSuccess(hash, false)
@@ -146,9 +324,11 @@ func (n *NGINXController) syncIngress(interface{}) error {
 			return err
 		}
 
-		klog.Infof("Backend successfully reloaded.")
 		n.metricCollector.ConfigSuccess(hash, true)
-		n.metricCollector.IncReloadCount()
+		if reloaded {
+			klog.Infof("Backend successfully reloaded.")
+			n.metricCollector.IncReloadCount()
+		}
 	}
 
 	isFirstSync := n.runningConfig.Equal(&ingress.Configuration{})
@@ -166,10 +346,11 @@ func (n *NGINXController) syncIngress(interface{}) error {
 		Jitter:   0.1,
 	}
 
+	backendMetadata := n.store.GetBackendConfiguration().EnableBackendMetadata
 	err := wait.ExponentialBackoff(retry, func() (bool, error) {
-		err := configureDynamically(pcfg)
+		err := configureDynamically(n.fileSystem, pcfg, backendMetadata)
 		if err == nil {
-			klog.V(2).Infof("Dynamic reconfiguration succeeded.")
+			logging.V(2).Infof("Dynamic reconfiguration succeeded.")
 			return true, nil
 		}
 
@@ -181,11 +362,30 @@ func (n *NGINXController) syncIngress(interface{}) error {
 		return err
 	}
 
+	n.syncDebugToken()
+	n.syncBotChallenge()
+
 	ri := getRemovedIngresses(n.runningConfig, pcfg)
 	re := getRemovedHosts(n.runningConfig, pcfg)
 	n.metricCollector.RemoveMetrics(ri, re)
+	n.metricCollector.RemoveStreamMetrics(getRemovedStreamServices(n.runningConfig, pcfg))
+
+	n.setRunningConfig(pcfg)
+	n.syncedRevision = revision
+	n.warmup.setSynced()
+
+	n.recordAudit(ings, pcfg, reloaded, 0)
+	n.publishAppliedConfiguration(ings, pcfg, 0)
 
-	n.runningConfig = pcfg
+	if reloaded && n.history != nil {
+		content, err := ioutil.ReadFile(cfgPath)
+		if err == nil {
+			_, err = n.saveRevision(content, pcfg, sources, 0)
+		}
+		if err != nil {
+			klog.Warningf("Unexpected error saving the configuration history: %v", err)
+		}
+	}
 
 	return nil
 }
@@ -213,16 +413,59 @@ func (n *NGINXController) CheckIngress(ing *networking.Ingress) error {
 		return nil
 	}
 
+	if err := checkHostDelegations(ing, n.getHostDelegations()); err != nil {
+		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+		return err
+	}
+
 	filter := func(toCheck *ingress.Ingress) bool {
 		return toCheck.ObjectMeta.Namespace == ing.ObjectMeta.Namespace &&
 			toCheck.ObjectMeta.Name == ing.ObjectMeta.Name
 	}
 
+	parsed := annotations.NewAnnotationExtractor(n.store).Extract(ing)
+	if n.cfg.StrictAnnotationValidation {
+		if err := annotations.ValidationError(parsed); err != nil {
+			n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+			return err
+		}
+	}
+
 	ings := n.store.ListIngresses(filter)
-	ings = append(ings, &ingress.Ingress{
+	if err := checkHostRegex(ing, ings); err != nil {
+		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+		return err
+	}
+
+	if err := checkInternal(ing, parsed, ings, n.internalListenerEnabled()); err != nil {
+		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+		return err
+	}
+
+	if err := checkUpstreamHost(ing, parsed, n.store.GetService); err != nil {
+		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+		return err
+	}
+
+	if n.cfg.WebhookVerifyTLSSecrets {
+		if err := checkTLSSecrets(ing, n.store.GetSecret, time.Now()); err != nil {
+			n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+			return err
+		}
+	}
+
+	toCheck := &ingress.Ingress{
 		Ingress:           *ing,
-		ParsedAnnotations: annotations.NewAnnotationExtractor(n.store).Extract(ing),
-	})
+		ParsedAnnotations: parsed,
+	}
+
+	others := applyHostDelegations(ings, n.getHostDelegations())
+	if err := checkQuota(toCheck, others, newQuota(n.store.GetBackendConfiguration())); err != nil {
+		n.metricCollector.IncCheckErrorCount(ing.ObjectMeta.Namespace, ing.Name)
+		return err
+	}
+
+	ings = append(ings, toCheck)
 
 	_, _, pcfg := n.getConfiguration(ings)
 
@@ -249,7 +492,7 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 	if configmapName == "" {
 		return []ingress.L4Service{}
 	}
-	klog.V(3).Infof("Obtaining information about %v stream services from ConfigMap %q", proto, configmapName)
+	logging.V(3).Infof("Obtaining information about %v stream services from ConfigMap %q", proto, configmapName)
 	_, _, err := k8s.ParseNameNS(configmapName)
 	if err != nil {
 		klog.Errorf("Error parsing ConfigMap reference %q: %v", configmapName, err)
@@ -269,8 +512,16 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 		n.cfg.ListenPorts.Health,
 		n.cfg.ListenPorts.Default,
 	}
+	rp = append(rp, n.cfg.ListenPorts.AdditionalHTTPS...)
+	if n.cfg.ListenPorts.InternalHTTP != 0 {
+		rp = append(rp, n.cfg.ListenPorts.InternalHTTP)
+	}
+	if n.cfg.ListenPorts.InternalHTTPS != 0 {
+		rp = append(rp, n.cfg.ListenPorts.InternalHTTPS)
+	}
 	reserverdPorts := sets.NewInt(rp...)
 	// svcRef format: <(str)namespace>/<(str)service>:<(intstr)port>[:<("PROXY")decode>:<("PROXY")encode>]
+	// or, for the UDP services, <(str)namespace>/<(str)service>:<(intstr)port>[:<(str)option>=<(str)value>...]
 	for port, svcRef := range configmap.Data {
 		externalPort, err := strconv.Atoi(port)
 		if err != nil {
@@ -304,6 +555,16 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 			klog.Warningf("%v", err)
 			continue
 		}
+		backend := ingress.L4Backend{
+			Name:          svcName,
+			Namespace:     svcNs,
+			Port:          intstr.FromString(svcPort),
+			Protocol:      proto,
+			ProxyProtocol: svcProxyProtocol,
+		}
+		if proto == apiv1.ProtocolUDP {
+			parseUDPOptions(nsSvcPort[2:], externalPort, &backend)
+		}
 		svc, err := n.store.GetService(nsName)
 		if err != nil {
 			klog.Warningf("Error getting Service %q: %v", nsName, err)
@@ -313,21 +574,21 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 		targetPort, err := strconv.Atoi(svcPort)
 		if err != nil {
 			// not a port number, fall back to using port name
-			klog.V(3).Infof("Searching Endpoints with %v port name %q for Service %q", proto, svcPort, nsName)
+			logging.V(3).Infof("Searching Endpoints with %v port name %q for Service %q", proto, svcPort, nsName)
 			for _, sp := range svc.Spec.Ports {
 				if sp.Name == svcPort {
 					if sp.Protocol == proto {
-						endps = getEndpoints(svc, &sp, proto, n.store.GetServiceEndpoints)
+						endps = getEndpoints(svc, &sp, proto, n.store.GetServiceEndpoints, n.store.GetEndpointWeight)
 						break
 					}
 				}
 			}
 		} else {
-			klog.V(3).Infof("Searching Endpoints with %v port number %d for Service %q", proto, targetPort, nsName)
+			logging.V(3).Infof("Searching Endpoints with %v port number %d for Service %q", proto, targetPort, nsName)
 			for _, sp := range svc.Spec.Ports {
 				if sp.Port == int32(targetPort) {
 					if sp.Protocol == proto {
-						endps = getEndpoints(svc, &sp, proto, n.store.GetServiceEndpoints)
+						endps = getEndpoints(svc, &sp, proto, n.store.GetServiceEndpoints, n.store.GetEndpointWeight)
 						break
 					}
 				}
@@ -340,14 +601,8 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 			continue
 		}
 		svcs = append(svcs, ingress.L4Service{
-			Port: externalPort,
-			Backend: ingress.L4Backend{
-				Name:          svcName,
-				Namespace:     svcNs,
-				Port:          intstr.FromString(svcPort),
-				Protocol:      proto,
-				ProxyProtocol: svcProxyProtocol,
-			},
+			Port:      externalPort,
+			Backend:   backend,
 			Endpoints: endps,
 			Service:   svc,
 		})
@@ -359,6 +614,47 @@ func (n *NGINXController) getStreamServices(configmapName string, proto apiv1.Pr
 	return svcs
 }
 
+// parseUDPOptions sets the options of a UDP service following its port, i.e.
+// "kube-system/kube-dns:53:responses=1:timeout=3s". An invalid option is ignored.
+func parseUDPOptions(options []string, externalPort int, backend *ingress.L4Backend) {
+	for _, option := range options {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			klog.Warningf("Ignoring option %q of UDP port %d: the options are of the form name=value", option, externalPort)
+			continue
+		}
+
+		name, value := strings.ToLower(kv[0]), kv[1]
+		switch name {
+		case "timeout":
+			if !streamTimeoutRegex.MatchString(value) {
+				klog.Warningf("Ignoring option %q of UDP port %d: invalid timeout", option, externalPort)
+				continue
+			}
+			backend.ProxyTimeout = value
+		case "responses":
+			responses, err := strconv.Atoi(value)
+			if err != nil || responses < 0 {
+				klog.Warningf("Ignoring option %q of UDP port %d: the number of responses must be zero or more", option, externalPort)
+				continue
+			}
+			backend.ProxyResponses = &responses
+		case "affinity":
+			if value != "source-ip" && value != "none" {
+				klog.Warningf("Ignoring option %q of UDP port %d: the affinity must be source-ip or none", option, externalPort)
+				continue
+			}
+			if value == "source-ip" {
+				backend.Affinity = value
+			} else {
+				backend.Affinity = ""
+			}
+		default:
+			klog.Warningf("Ignoring unknown option %q of UDP port %d", option, externalPort)
+		}
+	}
+}
+
 // getDefaultUpstream returns the upstream associated with the default backend.
 // Configures the upstream to return HTTP code 503 in case of error.
 func (n *NGINXController) getDefaultUpstream() *ingress.Backend {
@@ -379,7 +675,7 @@ func (n *NGINXController) getDefaultUpstream() *ingress.Backend {
 		return upstream
 	}
 
-	endps := getEndpoints(svc, &svc.Spec.Ports[0], apiv1.ProtocolTCP, n.store.GetServiceEndpoints)
+	endps := getEndpoints(svc, &svc.Spec.Ports[0], apiv1.ProtocolTCP, n.store.GetServiceEndpoints, n.store.GetEndpointWeight)
 	if len(endps) == 0 {
 		klog.Warningf("Service %q does not have any active Endpoint", svcKey)
 		endps = []ingress.Endpoint{n.DefaultEndpoint()}
@@ -392,6 +688,9 @@ func (n *NGINXController) getDefaultUpstream() *ingress.Backend {
 
 // getConfiguration returns the configuration matching the standard kubernetes ingress
 func (n *NGINXController) getConfiguration(ingresses []*ingress.Ingress) (sets.String, []*ingress.Server, *ingress.Configuration) {
+	ingresses = applyHostDelegations(ingresses, n.getHostDelegations())
+	ingresses = applyQuota(ingresses, newQuota(n.store.GetBackendConfiguration()))
+
 	upstreams, servers := n.getBackendServers(ingresses)
 	var passUpstreams []*ingress.SSLPassthroughBackend
 
@@ -401,8 +700,10 @@ func (n *NGINXController) getConfiguration(ingresses []*ingress.Ingress) (sets.S
 		if !hosts.Has(server.Hostname) {
 			hosts.Insert(server.Hostname)
 		}
-		if server.Alias != "" && !hosts.Has(server.Alias) {
-			hosts.Insert(server.Alias)
+		for _, alias := range strings.Fields(server.Alias) {
+			if !hosts.Has(alias) {
+				hosts.Insert(alias)
+			}
 		}
 
 		if !server.SSLPassthrough {
@@ -439,6 +740,8 @@ func (n *NGINXController) getConfiguration(ingresses []*ingress.Ingress) (sets.S
 // backend.  An upstream can be used in multiple servers if the namespace,
 // service name and port are the same.
 func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*ingress.Backend, []*ingress.Server) {
+	ingresses = n.filterInternalIngresses(ingresses)
+
 	du := n.getDefaultUpstream()
 	upstreams := n.createUpstreams(ingresses, du)
 	servers := n.createServers(ingresses, upstreams, du)
@@ -462,27 +765,32 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 
 			if rule.HTTP == nil &&
 				host != defServerName {
-				klog.V(3).Infof("Ingress %q does not contain any HTTP rule, using default backend", ingKey)
+				logging.V(3).Infof("Ingress %q does not contain any HTTP rule, using default backend", ingKey)
 				continue
 			}
 
-			if server.AuthTLSError == "" && anns.CertificateAuth.AuthTLSError != "" {
-				server.AuthTLSError = anns.CertificateAuth.AuthTLSError
-			}
+			if anns.CertificateAuth.Port != 0 {
+				// the client certificates are only required on the listener of the port
+				n.addServerListener(server, anns.CertificateAuth, ingKey)
+			} else {
+				if server.AuthTLSError == "" && anns.CertificateAuth.AuthTLSError != "" {
+					server.AuthTLSError = anns.CertificateAuth.AuthTLSError
+				}
 
-			if server.CertificateAuth.CAFileName == "" {
-				server.CertificateAuth = anns.CertificateAuth
-				if server.CertificateAuth.Secret != "" && server.CertificateAuth.CAFileName == "" {
-					klog.V(3).Infof("Secret %q has no 'ca.crt' key, mutual authentication disabled for Ingress %q",
-						server.CertificateAuth.Secret, ingKey)
+				if server.CertificateAuth.CAFileName == "" {
+					server.CertificateAuth = anns.CertificateAuth
+					if server.CertificateAuth.Secret != "" && server.CertificateAuth.CAFileName == "" {
+						logging.V(3).Infof("Secret %q has no 'ca.crt' key, mutual authentication disabled for Ingress %q",
+							server.CertificateAuth.Secret, ingKey)
+					}
+				} else {
+					logging.V(3).Infof("Server %q is already configured for mutual authentication (Ingress %q)",
+						server.Hostname, ingKey)
 				}
-			} else {
-				klog.V(3).Infof("Server %q is already configured for mutual authentication (Ingress %q)",
-					server.Hostname, ingKey)
 			}
 
 			if rule.HTTP == nil {
-				klog.V(3).Infof("Ingress %q does not contain any HTTP rule, using default backend", ingKey)
+				logging.V(3).Infof("Ingress %q does not contain any HTTP rule, using default backend", ingKey)
 				continue
 			}
 
@@ -507,12 +815,12 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 						addLoc = false
 
 						if !loc.IsDefBackend {
-							klog.V(3).Infof("Location %q already configured for server %q with upstream %q (Ingress %q)",
+							logging.V(3).Infof("Location %q already configured for server %q with upstream %q (Ingress %q)",
 								loc.Path, server.Hostname, loc.Backend, ingKey)
 							break
 						}
 
-						klog.V(3).Infof("Replacing location %q for server %q with upstream %q to use upstream %q (Ingress %q)",
+						logging.V(3).Infof("Replacing location %q for server %q with upstream %q to use upstream %q (Ingress %q)",
 							loc.Path, server.Hostname, loc.Backend, ups.Name, ingKey)
 
 						loc.Backend = ups.Name
@@ -521,17 +829,14 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 						loc.Service = ups.Service
 						loc.Ingress = ing
 						locationApplyAnnotations(loc, anns)
-
-						if loc.Redirect.FromToWWW {
-							server.RedirectFromToWWW = true
-						}
+						serverApplyRedirects(server, loc)
 						break
 					}
 				}
 
 				// new location
 				if addLoc {
-					klog.V(3).Infof("Adding location %q for server %q with upstream %q (Ingress %q)",
+					logging.V(3).Infof("Adding location %q for server %q with upstream %q (Ingress %q)",
 						nginxPath, server.Hostname, ups.Name, ingKey)
 
 					loc := &ingress.Location{
@@ -543,10 +848,8 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 						Ingress:      ing,
 					}
 					locationApplyAnnotations(loc, anns)
+					serverApplyRedirects(server, loc)
 
-					if loc.Redirect.FromToWWW {
-						server.RedirectFromToWWW = true
-					}
 					server.Locations = append(server.Locations, loc)
 				}
 
@@ -573,6 +876,10 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 					locs[host] = append(locs[host], path.Path)
 				}
 			}
+
+			if len(anns.AuthExcludePaths) > 0 {
+				addAuthExcludedLocations(server, ing)
+			}
 		}
 
 		// set aside canary ingresses to merge later
@@ -592,16 +899,20 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 	for _, upstream := range upstreams {
 		aUpstreams = append(aUpstreams, upstream)
 
+		if upstream.PodRoutingBy != "" {
+			aUpstreams = append(aUpstreams, createPodUpstreams(upstream)...)
+		}
+
 		isHTTPSfrom := []*ingress.Server{}
 		for _, server := range servers {
 			for _, location := range server.Locations {
 				if shouldCreateUpstreamForLocationDefaultBackend(upstream, location) {
 					sp := location.DefaultBackend.Spec.Ports[0]
-					endps := getEndpoints(location.DefaultBackend, &sp, apiv1.ProtocolTCP, n.store.GetServiceEndpoints)
+					endps := getEndpoints(location.DefaultBackend, &sp, apiv1.ProtocolTCP, n.store.GetServiceEndpoints, n.store.GetEndpointWeight)
 					if len(endps) > 0 {
 
 						name := fmt.Sprintf("custom-default-backend-%v", location.DefaultBackend.GetName())
-						klog.V(3).Infof("Creating \"%v\" upstream based on default backend annotation", name)
+						logging.V(3).Infof("Creating \"%v\" upstream based on default backend annotation", name)
 
 						nb := upstream.DeepCopy()
 						nb.Name = name
@@ -610,7 +921,7 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 						location.DefaultBackendUpstreamName = name
 
 						if len(upstream.Endpoints) == 0 {
-							klog.V(3).Infof("Upstream %q has no active Endpoint, so using custom default backend for location %q in server %q (Service \"%v/%v\")",
+							logging.V(3).Infof("Upstream %q has no active Endpoint, so using custom default backend for location %q in server %q (Service \"%v/%v\")",
 								upstream.Name, location.Path, server.Hostname, location.DefaultBackend.Namespace, location.DefaultBackend.Name)
 
 							location.Backend = name
@@ -654,7 +965,7 @@ func (n *NGINXController) getBackendServers(ingresses []*ingress.Ingress) ([]*in
 	})
 
 	sort.SliceStable(aServers, func(i, j int) bool {
-		return aServers[i].Hostname < aServers[j].Hostname
+		return serverLess(aServers[i], aServers[j])
 	})
 
 	return aUpstreams, aServers
@@ -666,6 +977,10 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 	upstreams := make(map[string]*ingress.Backend)
 	upstreams[defUpstreamName] = du
 
+	// failover upstreams are created once all the Ingresses are processed, so
+	// that a Service used as failover and as backend keeps its configuration
+	failovers := []failoverUpstream{}
+
 	for _, ing := range data {
 		anns := ing.ParsedAnnotations
 
@@ -673,7 +988,7 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 		if ing.Spec.Backend != nil {
 			defBackend = upstreamName(ing.Namespace, ing.Spec.Backend.ServiceName, ing.Spec.Backend.ServicePort)
 
-			klog.V(3).Infof("Creating upstream %q", defBackend)
+			logging.V(3).Infof("Creating upstream %q", defBackend)
 			upstreams[defBackend] = newUpstream(defBackend)
 
 			upstreams[defBackend].SecureCACert = anns.SecureUpstream.CACert
@@ -687,6 +1002,19 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 				upstreams[defBackend].LoadBalancing = n.store.GetBackendConfiguration().LoadBalancing
 			}
 
+			upstreams[defBackend].PodRoutingBy = anns.PodRoutingBy
+			upstreams[defBackend].EndpointCondition = anns.EndpointCondition
+			upstreams[defBackend].ServiceUpstream = anns.ServiceUpstream
+
+			if anns.Failover.Service != "" {
+				failovers = append(failovers, failoverUpstream{
+					backend:   upstreams[defBackend],
+					namespace: ing.Namespace,
+					port:      ing.Spec.Backend.ServicePort,
+					config:    anns.Failover,
+				})
+			}
+
 			svcKey := fmt.Sprintf("%v/%v", ing.Namespace, ing.Spec.Backend.ServiceName)
 
 			// add the service ClusterIP as a single Endpoint instead of individual Endpoints
@@ -712,6 +1040,7 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 
 			if len(upstreams[defBackend].Endpoints) == 0 {
 				endps, err := n.serviceEndpoints(svcKey, ing.Spec.Backend.ServicePort.String())
+				endps = filterEndpoints(endps, anns.EndpointCondition, n.store.HasEndpointCondition)
 				upstreams[defBackend].Endpoints = append(upstreams[defBackend].Endpoints, endps...)
 				if err != nil {
 					klog.Warningf("Error creating upstream %q: %v", defBackend, err)
@@ -737,7 +1066,7 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 					continue
 				}
 
-				klog.V(3).Infof("Creating upstream %q", name)
+				logging.V(3).Infof("Creating upstream %q", name)
 				upstreams[name] = newUpstream(name)
 				upstreams[name].Port = path.Backend.ServicePort
 
@@ -752,6 +1081,19 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 					upstreams[name].LoadBalancing = n.store.GetBackendConfiguration().LoadBalancing
 				}
 
+				upstreams[name].PodRoutingBy = anns.PodRoutingBy
+				upstreams[name].EndpointCondition = anns.EndpointCondition
+				upstreams[name].ServiceUpstream = anns.ServiceUpstream
+
+				if anns.Failover.Service != "" {
+					failovers = append(failovers, failoverUpstream{
+						backend:   upstreams[name],
+						namespace: ing.Namespace,
+						port:      path.Backend.ServicePort,
+						config:    anns.Failover,
+					})
+				}
+
 				svcKey := fmt.Sprintf("%v/%v", ing.Namespace, path.Backend.ServiceName)
 
 				// add the service ClusterIP as a single Endpoint instead of individual Endpoints
@@ -781,7 +1123,7 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 						klog.Warningf("Error obtaining Endpoints for Service %q: %v", svcKey, err)
 						continue
 					}
-					upstreams[name].Endpoints = endp
+					upstreams[name].Endpoints = filterEndpoints(endp, anns.EndpointCondition, n.store.HasEndpointCondition)
 				}
 
 				s, err := n.store.GetService(svcKey)
@@ -795,9 +1137,192 @@ func (n *NGINXController) createUpstreams(data []*ingress.Ingress, du *ingress.B
 		}
 	}
 
+	for _, f := range failovers {
+		n.setFailoverUpstream(upstreams, f)
+	}
+
 	return upstreams
 }
 
+// failoverUpstream references an upstream configured with the failover-*
+// annotations of an Ingress.
+type failoverUpstream struct {
+	backend   *ingress.Backend
+	namespace string
+	port      intstr.IntOrString
+	config    failover.Config
+}
+
+// setFailoverUpstream configures the failover of the given upstream, creating
+// the upstream of the failover Service when it does not exist yet. The failover
+// upstream has no server and only receives requests from the Lua balancer when
+// all the endpoints of the primary upstream are down.
+func (n *NGINXController) setFailoverUpstream(upstreams map[string]*ingress.Backend, f failoverUpstream) {
+	port := f.port
+	if f.config.Port != "" {
+		port = intstr.Parse(f.config.Port)
+	}
+
+	name := upstreamName(f.namespace, f.config.Service, port)
+	if name == f.backend.Name {
+		klog.Warningf("Ignoring failover of upstream %q to itself", name)
+		return
+	}
+
+	f.backend.Failover = ingress.FailoverConfig{
+		Backend:     name,
+		MaxFails:    f.config.MaxFails,
+		FailTimeout: f.config.FailTimeout,
+	}
+
+	if _, ok := upstreams[name]; ok {
+		return
+	}
+
+	logging.V(3).Infof("Creating failover upstream %q for upstream %q", name, f.backend.Name)
+	upstreams[name] = newUpstream(name)
+	upstreams[name].Port = port
+	upstreams[name].NoServer = true
+	upstreams[name].LoadBalancing = n.store.GetBackendConfiguration().LoadBalancing
+
+	svcKey := fmt.Sprintf("%v/%v", f.namespace, f.config.Service)
+
+	endps, err := n.serviceEndpoints(svcKey, port.String())
+	if err != nil {
+		klog.Warningf("Error obtaining Endpoints for failover Service %q: %v", svcKey, err)
+	}
+	upstreams[name].Endpoints = endps
+
+	s, err := n.store.GetService(svcKey)
+	if err != nil {
+		klog.Warningf("Error obtaining failover Service %q: %v", svcKey, err)
+	}
+	upstreams[name].Service = s
+}
+
+// addAuthExcludedLocations adds to the server a location without authentication
+// for each path listed in the auth-exclude-paths annotation of the Ingress. The
+// location is a copy of the location of the Ingress with the longest path
+// matching the excluded path.
+func addAuthExcludedLocations(server *ingress.Server, ing *ingress.Ingress) {
+	ingKey := k8s.MetaNamespaceKey(ing)
+
+	for _, excludedPath := range ing.ParsedAnnotations.AuthExcludePaths {
+		var base, existing *ingress.Location
+		for _, loc := range server.Locations {
+			if loc.Path == excludedPath {
+				existing = loc
+				break
+			}
+
+			if loc.Ingress != ing || !strings.HasPrefix(excludedPath, loc.Path) {
+				continue
+			}
+
+			if base == nil || len(loc.Path) > len(base.Path) {
+				base = loc
+			}
+		}
+
+		if existing != nil {
+			if existing.Ingress == ing {
+				// the path is defined in the Ingress, or was already added by
+				// another rule of the Ingress
+				disableLocationAuth(existing)
+				continue
+			}
+
+			klog.Warningf("Location %q already configured for server %q, skipping authentication exclusion (Ingress %q)",
+				excludedPath, server.Hostname, ingKey)
+			continue
+		}
+
+		if base == nil {
+			klog.Warningf("No location of Ingress %q in server %q matches the path %q, skipping authentication exclusion",
+				ingKey, server.Hostname, excludedPath)
+			continue
+		}
+
+		logging.V(3).Infof("Adding location %q without authentication for server %q (Ingress %q)",
+			excludedPath, server.Hostname, ingKey)
+
+		loc := *base
+		loc.Path = excludedPath
+		disableLocationAuth(&loc)
+		server.Locations = append(server.Locations, &loc)
+	}
+}
+
+// disableLocationAuth removes the basic, digest and external authentication
+// of a location, and excludes it from the client certificate authentication
+// of the server
+func disableLocationAuth(loc *ingress.Location) {
+	loc.BasicDigestAuth = auth.Config{}
+	loc.ExternalAuth = authreq.Config{}
+	loc.EnableGlobalAuth = false
+	loc.CertificateAuthExcluded = true
+}
+
+// serverApplyRedirects configures the redirects to the server defined in
+// the annotations of a location.
+func serverApplyRedirects(server *ingress.Server, loc *ingress.Location) {
+	if loc.Redirect.FromToWWW {
+		server.RedirectFromToWWW = true
+	}
+
+	if len(loc.Redirect.FromAliases) == 0 {
+		return
+	}
+
+	aliases := sets.NewString(server.RedirectFromAliases...)
+	aliases.Insert(loc.Redirect.FromAliases...)
+	server.RedirectFromAliases = aliases.List()
+
+	if server.RedirectFromAliasesCode == 0 {
+		server.RedirectFromAliasesCode = loc.Redirect.FromAliasesCode
+	}
+}
+
+// createPodUpstreams creates one upstream for each Pod providing an Endpoint
+// of the given upstream. The upstreams are referenced in the PodBackends field
+// using the name of the Pod and, for Pods managed by a StatefulSet, its ordinal.
+func createPodUpstreams(upstream *ingress.Backend) []*ingress.Backend {
+	podUpstreams := []*ingress.Backend{}
+	upstream.PodBackends = map[string]string{}
+
+	for _, endpoint := range upstream.Endpoints {
+		if endpoint.Target == nil || endpoint.Target.Kind != "Pod" || endpoint.Target.Name == "" {
+			continue
+		}
+
+		podName := endpoint.Target.Name
+		if _, ok := upstream.PodBackends[podName]; ok {
+			// the Pod exposes more than one port for the same Service and
+			// was already added
+			continue
+		}
+
+		name := fmt.Sprintf("%v-pod-%v", upstream.Name, podName)
+		logging.V(3).Infof("Creating upstream %q for Pod %q", name, podName)
+
+		nb := upstream.DeepCopy()
+		nb.Name = name
+		nb.NoServer = true
+		nb.PodRoutingBy = ""
+		nb.PodBackends = nil
+		nb.AlternativeBackends = nil
+		nb.Endpoints = []ingress.Endpoint{endpoint}
+		podUpstreams = append(podUpstreams, nb)
+
+		upstream.PodBackends[podName] = name
+		if ordinal := podOrdinalRegex.FindStringSubmatch(podName); ordinal != nil {
+			upstream.PodBackends[ordinal[1]] = name
+		}
+	}
+
+	return podUpstreams
+}
+
 // getServiceClusterEndpoint returns an Endpoint corresponding to the ClusterIP
 // field of a Service.
 func (n *NGINXController) getServiceClusterEndpoint(svcKey string, backend *networking.IngressBackend) (endpoint ingress.Endpoint, err error) {
@@ -842,7 +1367,7 @@ func (n *NGINXController) serviceEndpoints(svcKey, backendPort string) ([]ingres
 		return upstreams, err
 	}
 
-	klog.V(3).Infof("Obtaining ports information for Service %q", svcKey)
+	logging.V(3).Infof("Obtaining ports information for Service %q", svcKey)
 
 	// Ingress with an ExternalName Service and no port defined for that Service
 	if svc.Spec.Type == apiv1.ServiceTypeExternalName {
@@ -857,7 +1382,7 @@ func (n *NGINXController) serviceEndpoints(svcKey, backendPort string) ([]ingres
 			Port:       int32(externalPort),
 			TargetPort: intstr.FromString(backendPort),
 		}
-		endps := getEndpoints(svc, &servicePort, apiv1.ProtocolTCP, n.store.GetServiceEndpoints)
+		endps := getEndpoints(svc, &servicePort, apiv1.ProtocolTCP, n.store.GetServiceEndpoints, n.store.GetEndpointWeight)
 		if len(endps) == 0 {
 			klog.Warningf("Service %q does not have any active Endpoint.", svcKey)
 			return upstreams, nil
@@ -873,7 +1398,7 @@ func (n *NGINXController) serviceEndpoints(svcKey, backendPort string) ([]ingres
 			servicePort.TargetPort.String() == backendPort ||
 			servicePort.Name == backendPort {
 
-			endps := getEndpoints(svc, &servicePort, apiv1.ProtocolTCP, n.store.GetServiceEndpoints)
+			endps := getEndpoints(svc, &servicePort, apiv1.ProtocolTCP, n.store.GetServiceEndpoints, n.store.GetEndpointWeight)
 			if len(endps) == 0 {
 				klog.Warningf("Service %q does not have any active Endpoint.", svcKey)
 			}
@@ -899,6 +1424,9 @@ func (n *NGINXController) overridePemFileNameAndPemSHA(cert *ingress.SSLCert) {
 	// TODO(elvinefendi): This is again another hacky way of avoiding Nginx reload when certificate
 	// changes in dynamic SSL mode since FakeCertificate never changes.
 	cert.PemSHA = n.cfg.FakeCertificate.PemSHA
+
+	// the OCSP response is stapled by Lua with the certificate
+	cert.OCSPResponseFile = ""
 }
 
 // createServers builds a map of host name to Server structs from a map of
@@ -909,7 +1437,8 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 	du *ingress.Backend) map[string]*ingress.Server {
 
 	servers := make(map[string]*ingress.Server, len(data))
-	aliases := make(map[string]string, len(data))
+	aliases := make(map[string]serverAlias, len(data))
+	pins := make(map[string]sslpin.Config)
 
 	bdef := n.store.GetDefaultBackend()
 	ngxProxy := proxy.Config{
@@ -971,7 +1500,7 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 		un := du.Name
 
 		if anns.Canary.Enabled {
-			klog.V(2).Infof("Ingress %v is marked as Canary, ignoring", ingKey)
+			logging.V(2).Infof("Ingress %v is marked as Canary, ignoring", ingKey)
 			continue
 		}
 
@@ -985,7 +1514,7 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				// special "catch all" case, Ingress with a backend but no rule
 				defLoc := servers[defServerName].Locations[0]
 				if defLoc.IsDefBackend && len(ing.Spec.Rules) == 0 {
-					klog.V(2).Infof("Ingress %q defines a backend but no rule. Using it to configure the catch-all server %q",
+					logging.V(2).Infof("Ingress %q defines a backend but no rule. Using it to configure the catch-all server %q",
 						ingKey, defServerName)
 
 					defLoc.IsDefBackend = false
@@ -1000,7 +1529,7 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 					defLoc.Redirect = originalRedirect
 					defLoc.Rewrite = originalRewrite
 				} else {
-					klog.V(3).Infof("Ingress %q defines both a backend and rules. Using its backend as default upstream for all its rules.",
+					logging.V(3).Infof("Ingress %q defines both a backend and rules. Using its backend as default upstream for all its rules.",
 						ingKey)
 				}
 			}
@@ -1011,6 +1540,12 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 			if host == "" {
 				host = defServerName
 			}
+
+			// the pins apply to the certificate of the host whatever the Ingress defining it
+			if _, ok := pins[host]; !ok && anns.SSLPins.Enabled {
+				pins[host] = anns.SSLPins
+			}
+
 			if _, ok := servers[host]; ok {
 				// server already configured
 				continue
@@ -1031,20 +1566,31 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				},
 				SSLPassthrough: anns.SSLPassthrough,
 				SSLCiphers:     anns.SSLCiphers,
+				PlainHTTP:      anns.PlainHTTP,
+				MaxConnections: anns.MaxConnections,
 			}
 		}
 	}
 
-	// configure default location, alias, and SSL
+	n.configureInternalServers(data, servers)
+
+	// configure default location, alias, host regex and SSL
+	regexes := make(map[string]string)
+	classCertificates := make(map[string]*ingress.SSLCert)
 	for _, ing := range data {
 		ingKey := k8s.MetaNamespaceKey(ing)
 		anns := ing.ParsedAnnotations
 
 		if anns.Canary.Enabled {
-			klog.V(2).Infof("Ingress %v is marked as Canary, ignoring", ingKey)
+			logging.V(2).Infof("Ingress %v is marked as Canary, ignoring", ingKey)
 			continue
 		}
 
+		n.configureHostRegex(ing, servers, regexes)
+
+		// the hosts of the Ingress use the default certificate of its class when there is one
+		ingDefaultCertificate := n.classDefaultCertificate(ing, defaultCertificate, classCertificates)
+
 		for _, rule := range ing.Spec.Rules {
 			host := rule.Host
 			if host == "" {
@@ -1053,10 +1599,17 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 
 			if anns.Alias != "" {
 				if servers[host].Alias == "" {
-					servers[host].Alias = anns.Alias
-					if _, ok := aliases["Alias"]; !ok {
-						aliases["Alias"] = host
+					var serverAliases []string
+					for _, alias := range strings.Fields(anns.Alias) {
+						if sa, ok := aliases[alias]; ok && sa.host != host {
+							n.rejectAlias(ing, alias, fmt.Sprintf("alias %q is already configured for server %q", alias, sa.host))
+							continue
+						}
+
+						aliases[alias] = serverAlias{host: host, ing: ing}
+						serverAliases = append(serverAliases, alias)
 					}
+					servers[host].Alias = strings.Join(serverAliases, " ")
 				} else {
 					klog.Warningf("Aliases already configured for server %q, skipping (Ingress %q)",
 						host, ingKey)
@@ -1077,21 +1630,31 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 				servers[host].SSLCiphers = anns.SSLCiphers
 			}
 
+			// only configure the plain HTTP requests if the server does not have it previously configured
+			if servers[host].PlainHTTP.Action == "" && anns.PlainHTTP.Action != "" {
+				servers[host].PlainHTTP = anns.PlainHTTP
+			}
+
+			// only add a connection limit if the server does not have one previously configured
+			if servers[host].MaxConnections == 0 && anns.MaxConnections > 0 {
+				servers[host].MaxConnections = anns.MaxConnections
+			}
+
 			// only add a certificate if the server does not have one previously configured
 			if servers[host].SSLCert.PemFileName != "" {
 				continue
 			}
 
 			if len(ing.Spec.TLS) == 0 {
-				klog.V(3).Infof("Ingress %q does not contains a TLS section.", ingKey)
+				logging.V(3).Infof("Ingress %q does not contains a TLS section.", ingKey)
 				continue
 			}
 
 			tlsSecretName := extractTLSSecretName(host, ing, n.store.GetLocalSSLCert)
 
 			if tlsSecretName == "" {
-				klog.V(3).Infof("Host %q is listed in the TLS section but secretName is empty. Using default certificate.", host)
-				servers[host].SSLCert = *defaultCertificate
+				logging.V(3).Infof("Host %q is listed in the TLS section but secretName is empty. Using default certificate.", host)
+				servers[host].SSLCert = *ingDefaultCertificate
 				continue
 			}
 
@@ -1099,24 +1662,22 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 			cert, err := n.store.GetLocalSSLCert(secrKey)
 			if err != nil {
 				klog.Warningf("Error getting SSL certificate %q: %v. Using default certificate", secrKey, err)
-				servers[host].SSLCert = *defaultCertificate
+				servers[host].SSLCert = *ingDefaultCertificate
 				continue
 			}
 
-			err = cert.Certificate.VerifyHostname(host)
+			err = ssl.VerifyHostname(host, cert.Certificate)
 			if err != nil {
-				klog.Warningf("Unexpected error validating SSL certificate %q for server %q: %v", secrKey, host, err)
-				klog.Warning("Validating certificate against DNS names. This will be deprecated in a future version.")
-				// check the Common Name field
-				// https://github.com/golang/go/issues/22922
-				err := verifyHostname(host, cert.Certificate)
-				if err != nil {
-					klog.Warningf("SSL certificate %q does not contain a Common Name or Subject Alternative Name for server %q: %v",
-						secrKey, host, err)
-					klog.Warningf("Using default certificate")
-					servers[host].SSLCert = *defaultCertificate
-					continue
-				}
+				klog.Warningf("SSL certificate %q does not contain a Common Name or Subject Alternative Name for server %q: %v",
+					secrKey, host, err)
+				klog.Warningf("Using default certificate")
+				servers[host].SSLCert = *ingDefaultCertificate
+				continue
+			}
+
+			if p, ok := pins[host]; ok && !n.acceptPinnedCertificate(ing, host, secrKey, cert, p) {
+				servers[host].SSLCert = *ingDefaultCertificate
+				continue
 			}
 
 			if ngx_config.EnableDynamicCertificates {
@@ -1131,16 +1692,138 @@ func (n *NGINXController) createServers(data []*ingress.Ingress,
 		}
 	}
 
-	for alias, host := range aliases {
-		if _, ok := servers[alias]; ok {
-			klog.Warningf("Conflicting hostname (%v) and alias (%v). Removing alias to avoid conflicts.", host, alias)
-			servers[host].Alias = ""
+	for alias, sa := range aliases {
+		if _, ok := servers[alias]; !ok {
+			continue
+		}
+
+		n.rejectAlias(sa.ing, alias, fmt.Sprintf("a server with hostname %q already exists", alias))
+
+		var serverAliases []string
+		for _, a := range strings.Fields(servers[sa.host].Alias) {
+			if a != alias {
+				serverAliases = append(serverAliases, a)
+			}
 		}
+		servers[sa.host].Alias = strings.Join(serverAliases, " ")
 	}
 
 	return servers
 }
 
+// addServerListener adds to the server the listener of the additional HTTPS
+// port requiring the client certificates of the Ingress. The first Ingress
+// configuring a port defines its client certificate authentication.
+func (n *NGINXController) addServerListener(server *ingress.Server, certificateAuth authtls.Config, ingKey string) {
+	port := certificateAuth.Port
+	if server.Internal {
+		klog.Warningf("Server %q is internal, ignoring the client certificate authentication on the additional HTTPS port %v of Ingress %q",
+			server.Hostname, port, ingKey)
+		return
+	}
+
+	if !sets.NewInt(n.cfg.ListenPorts.AdditionalHTTPS...).Has(port) {
+		klog.Warningf("Port %v of Ingress %q is not an additional HTTPS port (--additional-https-ports), ignoring the client certificate authentication",
+			port, ingKey)
+		return
+	}
+
+	for _, listener := range server.Listeners {
+		if listener.Port == port {
+			logging.V(3).Infof("Port %v of server %q is already configured for mutual authentication (Ingress %q)",
+				port, server.Hostname, ingKey)
+			return
+		}
+	}
+
+	if certificateAuth.Secret != "" && certificateAuth.CAFileName == "" {
+		logging.V(3).Infof("Secret %q has no 'ca.crt' key, mutual authentication disabled on port %v for Ingress %q",
+			certificateAuth.Secret, port, ingKey)
+	}
+
+	server.Listeners = append(server.Listeners, ingress.ServerListener{
+		Port:            port,
+		CertificateAuth: certificateAuth,
+	})
+	sort.SliceStable(server.Listeners, func(i, j int) bool {
+		return server.Listeners[i].Port < server.Listeners[j].Port
+	})
+}
+
+// serverAlias references the server and the Ingress defining an alias
+type serverAlias struct {
+	host string
+	ing  *ingress.Ingress
+}
+
+// rejectAlias logs and records an event in the Ingress defining an alias
+// that cannot be used because it collides with another host.
+func (n *NGINXController) rejectAlias(ing *ingress.Ingress, alias, reason string) {
+	klog.Warningf("Removing alias %q from Ingress %q to avoid conflicts: %v", alias, k8s.MetaNamespaceKey(ing), reason)
+
+	n.recorder.Eventf(&ing.Ingress, apiv1.EventTypeWarning, "AliasConflict",
+		"Removing alias %q to avoid conflicts: %v", alias, reason)
+}
+
+// classDefaultCertificate returns the default certificate of the class of the
+// Ingress, defined by the parameters of the class or the flags, or the default
+// certificate of the controller. The certificates are loaded once in the cache.
+func (n *NGINXController) classDefaultCertificate(ing *ingress.Ingress, defaultCertificate *ingress.SSLCert,
+	cache map[string]*ingress.SSLCert) *ingress.SSLCert {
+
+	key := class.DefaultSSLCertificate(&ing.Ingress)
+	if params, err := n.store.GetIngressClassParams(class.Of(&ing.Ingress)); err == nil && params.Spec.DefaultSSLCertificate != "" {
+		key = params.Spec.DefaultSSLCertificate
+	}
+	if key == "" {
+		return defaultCertificate
+	}
+
+	if certificate, ok := cache[key]; ok {
+		return certificate
+	}
+
+	certificate, err := n.store.GetLocalSSLCert(key)
+	if err != nil {
+		klog.Warningf("Error loading the default certificate %q of the class %q, falling back to default certificate: %v", key, class.Of(&ing.Ingress), err)
+		certificate = defaultCertificate
+	} else if ngx_config.EnableDynamicCertificates {
+		n.overridePemFileNameAndPemSHA(certificate)
+	}
+
+	cache[key] = certificate
+	return certificate
+}
+
+// acceptPinnedCertificate checks the public keys of the certificates of the
+// Secret, RSA and ECDSA, match the pins of the host or the override, the
+// certificate must not be used otherwise
+func (n *NGINXController) acceptPinnedCertificate(ing *ingress.Ingress, host, secrKey string, cert *ingress.SSLCert, pins sslpin.Config) bool {
+	certs := []*x509.Certificate{cert.Certificate}
+	if cert.ECDSA != nil {
+		certs = append(certs, cert.ECDSA.Certificate)
+	}
+
+	for _, c := range certs {
+		err := ssl.VerifyPins(c, pins.Pins)
+		if err == nil {
+			continue
+		}
+
+		if c != nil && pins.Override != "" && pins.Override == ssl.SPKIPin(c) {
+			klog.Warningf("SSL certificate %q of server %q accepted by the pins override: %v. Add the pin to the pins of the server.", secrKey, host, err)
+			continue
+		}
+
+		klog.Warningf("Refusing the SSL certificate %q of server %q: %v. Using default certificate", secrKey, host, err)
+		n.recorder.Eventf(&ing.Ingress, apiv1.EventTypeWarning, "CertificatePinMismatch",
+			"Refusing the SSL certificate %q of server %q: %v", secrKey, host, err)
+		return false
+	}
+
+	return true
+}
+
 func locationApplyAnnotations(loc *ingress.Location, anns *annotations.Ingress) {
 	loc.BasicDigestAuth = anns.BasicDigestAuth
 	loc.ClientBodyBufferSize = anns.ClientBodyBufferSize
@@ -1154,6 +1837,7 @@ func locationApplyAnnotations(loc *ingress.Location, anns *annotations.Ingress)
 	loc.Redirect = anns.Redirect
 	loc.Rewrite = anns.Rewrite
 	loc.UpstreamVhost = anns.UpstreamVhost
+	loc.UpstreamHost = anns.UpstreamHost
 	loc.Whitelist = anns.Whitelist
 	loc.Denied = anns.Denied
 	loc.XForwardedPrefix = anns.XForwardedPrefix
@@ -1164,6 +1848,15 @@ func locationApplyAnnotations(loc *ingress.Location, anns *annotations.Ingress)
 	loc.InfluxDB = anns.InfluxDB
 	loc.DefaultBackend = anns.DefaultBackend
 	loc.BackendProtocol = anns.BackendProtocol
+	loc.ProxySSL = anns.SecureUpstream
+	loc.ProxyChain = anns.ProxyChain
+	loc.BodyTransform = anns.BodyTransform
+	loc.BodyStreaming = anns.BodyStreaming
+	loc.CanonicalPath = anns.CanonicalPath
+	loc.TrafficCapture = anns.TrafficCapture
+	loc.BotChallenge = anns.BotChallenge
+	loc.TLSFingerprint = anns.TLSFingerprint
+	loc.TimeWindow = anns.TimeWindow
 	loc.CustomHTTPErrors = anns.CustomHTTPErrors
 	loc.ModSecurity = anns.ModSecurity
 	loc.Satisfy = anns.Satisfy
@@ -1192,7 +1885,7 @@ func mergeAlternativeBackend(priUps *ingress.Backend, altUps *ingress.Backend) b
 
 	for _, ab := range priUps.AlternativeBackends {
 		if ab == altUps.Name {
-			klog.V(2).Infof("skip merge alternative backend %v into %v, it's already present", altUps.Name, priUps.Name)
+			logging.V(2).Infof("skip merge alternative backend %v into %v, it's already present", altUps.Name, priUps.Name)
 			return true
 		}
 	}
@@ -1226,7 +1919,7 @@ func mergeAlternativeBackends(ing *ingress.Ingress, upstreams map[string]*ingres
 				priUps := upstreams[loc.Backend]
 
 				if canMergeBackend(priUps, altUps) {
-					klog.V(2).Infof("matching backend %v found for alternative backend %v",
+					logging.V(2).Infof("matching backend %v found for alternative backend %v",
 						priUps.Name, altUps.Name)
 
 					merged = mergeAlternativeBackend(priUps, altUps)
@@ -1267,7 +1960,7 @@ func mergeAlternativeBackends(ing *ingress.Ingress, upstreams map[string]*ingres
 				priUps := upstreams[loc.Backend]
 
 				if canMergeBackend(priUps, altUps) && loc.Path == path.Path {
-					klog.V(2).Infof("matching backend %v found for alternative backend %v",
+					logging.V(2).Infof("matching backend %v found for alternative backend %v",
 						priUps.Name, altUps.Name)
 
 					merged = mergeAlternativeBackend(priUps, altUps)
@@ -1318,11 +2011,11 @@ func extractTLSSecretName(host string, ing *ingress.Ingress,
 			continue
 		}
 
-		err = cert.Certificate.VerifyHostname(host)
+		err = ssl.VerifyHostname(host, cert.Certificate)
 		if err != nil {
 			continue
 		}
-		klog.V(3).Infof("Found SSL certificate matching host %q: %q", host, secrKey)
+		logging.V(3).Infof("Found SSL certificate matching host %q: %q", host, secrKey)
 		return tls.SecretName
 	}
 
@@ -1350,6 +2043,22 @@ func getRemovedHosts(rucfg, newcfg *ingress.Configuration) []string {
 	return old.Difference(new).List()
 }
 
+// getRemovedStreamServices returns the TCP and UDP services not exposed anymore,
+// as protocol/namespace/service/port like the labels of the stream metrics
+func getRemovedStreamServices(rucfg, newcfg *ingress.Configuration) []string {
+	streamServices := func(cfg *ingress.Configuration) sets.String {
+		services := sets.NewString()
+		for _, l4Services := range [][]ingress.L4Service{cfg.TCPEndpoints, cfg.UDPEndpoints} {
+			for _, svc := range l4Services {
+				services.Insert(fmt.Sprintf("%v/%v/%v/%v", svc.Backend.Protocol, svc.Backend.Namespace, svc.Backend.Name, svc.Backend.Port.String()))
+			}
+		}
+		return services
+	}
+
+	return streamServices(rucfg).Difference(streamServices(newcfg)).List()
+}
+
 func getRemovedIngresses(rucfg, newcfg *ingress.Configuration) []string {
 	oldIngresses := sets.NewString()
 	newIngresses := sets.NewString()
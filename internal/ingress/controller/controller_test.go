@@ -26,6 +26,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -36,11 +37,18 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
 
 	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/canary"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/failover"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/sslpin"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/ingress/controller/store"
@@ -49,16 +57,22 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 	"k8s.io/ingress-nginx/internal/k8s"
 	"k8s.io/ingress-nginx/internal/net/ssl"
+	"k8s.io/ingress-nginx/internal/task"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
 )
 
 const fakeCertificateName = "default-fake-certificate"
 
 type fakeIngressStore struct {
-	ingresses []*ingress.Ingress
+	ingresses     []*ingress.Ingress
+	delegations   []*v1alpha1.HostDelegation
+	classParams   []*v1alpha1.NginxIngressClassParams
+	configuration ngx_config.Configuration
+	pods          []string
 }
 
-func (fakeIngressStore) GetBackendConfiguration() ngx_config.Configuration {
-	return ngx_config.Configuration{}
+func (fis fakeIngressStore) GetBackendConfiguration() ngx_config.Configuration {
+	return fis.configuration
 }
 
 func (fakeIngressStore) GetConfigMap(key string) (*corev1.ConfigMap, error) {
@@ -77,14 +91,34 @@ func (fakeIngressStore) GetServiceEndpoints(key string) (*corev1.Endpoints, erro
 	return nil, fmt.Errorf("test error")
 }
 
+func (fakeIngressStore) GetEndpointWeight(target *corev1.ObjectReference) int {
+	return 0
+}
+
+func (fakeIngressStore) HasEndpointCondition(target *corev1.ObjectReference, condition string) bool {
+	return true
+}
+
+func (fakeIngressStore) GetSecretReferences(key string) []string {
+	return []string{}
+}
+
 func (fis fakeIngressStore) ListIngresses(store.IngressFilterFunc) []*ingress.Ingress {
 	return fis.ingresses
 }
 
+func (fakeIngressStore) GetObjectsRevision() (uint64, bool) {
+	return 0, false
+}
+
 func (fakeIngressStore) GetRunningControllerPodsCount() int {
 	return 0
 }
 
+func (fis fakeIngressStore) ListControllerPodNames() []string {
+	return fis.pods
+}
+
 func (fakeIngressStore) GetLocalSSLCert(name string) (*ingress.SSLCert, error) {
 	return nil, fmt.Errorf("test error")
 }
@@ -97,10 +131,31 @@ func (fakeIngressStore) GetAuthCertificate(string) (*resolver.AuthSSLCert, error
 	return nil, fmt.Errorf("test error")
 }
 
+func (fakeIngressStore) GetConfigMapCACertificate(string) (*resolver.AuthSSLCert, error) {
+	return nil, fmt.Errorf("test error")
+}
+
 func (fakeIngressStore) GetDefaultBackend() defaults.Backend {
 	return defaults.Backend{}
 }
 
+func (fis fakeIngressStore) ListHostDelegations() []*v1alpha1.HostDelegation {
+	return fis.delegations
+}
+
+func (fis fakeIngressStore) GetIngressClassParams(ingressClass string) (*v1alpha1.NginxIngressClassParams, error) {
+	for _, params := range fis.classParams {
+		if params.Name == ingressClass {
+			return params, nil
+		}
+	}
+	return nil, fmt.Errorf("test error")
+}
+
+func (fis fakeIngressStore) ListIngressClassParams() []*v1alpha1.NginxIngressClassParams {
+	return fis.classParams
+}
+
 func (fakeIngressStore) Run(stopCh chan struct{}) {}
 
 type testNginxTestCommand struct {
@@ -144,6 +199,18 @@ func (fakeTemplate) Write(conf config.TemplateConfig) ([]byte, error) {
 	return r, nil
 }
 
+func (fakeTemplate) WriteServers(conf config.TemplateConfig) (map[string]string, error) {
+	r := map[string]string{}
+	for _, s := range conf.Servers {
+		paths := []string{}
+		for _, l := range s.Locations {
+			paths = append(paths, l.Path)
+		}
+		r[s.Hostname] = strings.Join(paths, ",")
+	}
+	return r, nil
+}
+
 func TestCheckIngress(t *testing.T) {
 	defer func() {
 		filepath.Walk(os.TempDir(), func(path string, info os.FileInfo, err error) error {
@@ -238,6 +305,28 @@ func TestCheckIngress(t *testing.T) {
 			}
 		})
 
+		t.Run("When strict annotation validation is enabled", func(t *testing.T) {
+			nginx.command = testNginxTestCommand{
+				t:        t,
+				err:      nil,
+				expected: "_,test.example.com",
+			}
+			nginx.cfg.StrictAnnotationValidation = true
+			defer func() {
+				nginx.cfg.StrictAnnotationValidation = false
+				delete(ing.ObjectMeta.Annotations, parser.GetAnnotationWithPrefix("proxy-body-szie"))
+			}()
+
+			if nginx.CheckIngress(ing) != nil {
+				t.Errorf("with valid annotations, no error should be returned")
+			}
+
+			ing.ObjectMeta.Annotations[parser.GetAnnotationWithPrefix("proxy-body-szie")] = "1m"
+			if nginx.CheckIngress(ing) == nil {
+				t.Errorf("with an unknown annotation, an error should be returned")
+			}
+		})
+
 		t.Run("When the ingress is in a different namespace than the watched one", func(t *testing.T) {
 			nginx.command = testNginxTestCommand{
 				t:   t,
@@ -1081,6 +1170,67 @@ func TestGetBackendServers(t *testing.T) {
 				}
 			},
 		},
+		{
+			Ingresses: []*ingress.Ingress{
+				{
+					Ingress: networking.Ingress{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "example",
+							Namespace: "example",
+						},
+						Spec: networking.IngressSpec{
+							Rules: []networking.IngressRule{
+								{
+									Host: "example.com",
+								},
+							},
+						},
+					},
+					ParsedAnnotations: &annotations.Ingress{
+						Alias: "*.example.com www.example.com shared.example.com",
+					},
+				},
+				{
+					Ingress: networking.Ingress{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "www",
+							Namespace: "other",
+						},
+						Spec: networking.IngressSpec{
+							Rules: []networking.IngressRule{
+								{
+									Host: "www.example.com",
+								},
+								{
+									Host: "foo.bar",
+								},
+							},
+						},
+					},
+					ParsedAnnotations: &annotations.Ingress{
+						Alias: "shared.example.com",
+					},
+				},
+			},
+			Validate: func(servers []*ingress.Server) {
+				if len(servers) != 4 {
+					t.Errorf("servers count should be 4, got %d", len(servers))
+					return
+				}
+
+				aliases := map[string]string{}
+				for _, s := range servers {
+					aliases[s.Hostname] = s.Alias
+				}
+
+				if aliases["example.com"] != "*.example.com shared.example.com" {
+					t.Errorf("server example.com should have aliases '*.example.com shared.example.com', got '%s'", aliases["example.com"])
+				}
+				if aliases["foo.bar"] != "" {
+					t.Errorf("server foo.bar should not have aliases, got '%s'", aliases["foo.bar"])
+				}
+			},
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -1089,6 +1239,386 @@ func TestGetBackendServers(t *testing.T) {
 	}
 }
 
+func TestAddAuthExcludedLocations(t *testing.T) {
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "example",
+				Namespace: "default",
+			},
+		},
+		ParsedAnnotations: &annotations.Ingress{
+			AuthExcludePaths: []string{"/api/healthz", "/metrics", "/other"},
+		},
+	}
+	other := &ingress.Ingress{}
+
+	server := &ingress.Server{
+		Hostname: "example.com",
+		Locations: []*ingress.Location{
+			{
+				Path:         "/",
+				Backend:      "default-web-80",
+				Ingress:      ing,
+				ExternalAuth: authreq.Config{URL: "http://auth.example.com"},
+			},
+			{
+				Path:             "/api",
+				Backend:          "default-api-80",
+				Ingress:          ing,
+				ExternalAuth:     authreq.Config{URL: "http://auth.example.com"},
+				EnableGlobalAuth: true,
+			},
+			{
+				Path:         "/metrics",
+				Backend:      "default-web-80",
+				Ingress:      ing,
+				ExternalAuth: authreq.Config{URL: "http://auth.example.com"},
+			},
+			{
+				Path:         "/other",
+				Backend:      "default-other-80",
+				Ingress:      other,
+				ExternalAuth: authreq.Config{URL: "http://auth.example.com"},
+			},
+		},
+	}
+
+	addAuthExcludedLocations(server, ing)
+
+	if len(server.Locations) != 5 {
+		t.Fatalf("expected 5 locations but got %v", len(server.Locations))
+	}
+
+	healthz := server.Locations[4]
+	if healthz.Path != "/api/healthz" || healthz.Backend != "default-api-80" {
+		t.Errorf("expected location /api/healthz using backend default-api-80 but got %v using %v", healthz.Path, healthz.Backend)
+	}
+	if healthz.ExternalAuth.URL != "" || healthz.EnableGlobalAuth || !healthz.CertificateAuthExcluded {
+		t.Errorf("expected location %v to not require authentication", healthz.Path)
+	}
+	if server.Locations[1].ExternalAuth.URL == "" || server.Locations[1].CertificateAuthExcluded {
+		t.Errorf("expected location /api to require authentication")
+	}
+	if server.Locations[2].ExternalAuth.URL != "" {
+		t.Errorf("expected location /metrics to not require authentication")
+	}
+	if server.Locations[3].ExternalAuth.URL == "" {
+		t.Errorf("expected location /other of another Ingress to require authentication")
+	}
+}
+
+func TestCreatePodUpstreams(t *testing.T) {
+	upstream := &ingress.Backend{
+		Name:         "default-web-80",
+		PodRoutingBy: "$http_x_pod_ordinal",
+		Endpoints: []ingress.Endpoint{
+			{Address: "10.0.0.1", Port: "8080", Target: &corev1.ObjectReference{Kind: "Pod", Name: "web-0"}},
+			{Address: "10.0.0.2", Port: "8080", Target: &corev1.ObjectReference{Kind: "Pod", Name: "web-1"}},
+			{Address: "10.0.0.2", Port: "8081", Target: &corev1.ObjectReference{Kind: "Pod", Name: "web-1"}},
+			{Address: "10.0.0.3", Port: "8080"},
+		},
+	}
+
+	podUpstreams := createPodUpstreams(upstream)
+	if len(podUpstreams) != 2 {
+		t.Fatalf("expected 2 pod upstreams but got %v", len(podUpstreams))
+	}
+
+	for _, ups := range podUpstreams {
+		if !ups.NoServer {
+			t.Errorf("expected upstream %v to have no server", ups.Name)
+		}
+		if ups.PodRoutingBy != "" || len(ups.PodBackends) != 0 {
+			t.Errorf("expected upstream %v to not route by pod", ups.Name)
+		}
+		if len(ups.Endpoints) != 1 {
+			t.Errorf("expected upstream %v to contain one endpoint but got %v", ups.Name, len(ups.Endpoints))
+		}
+	}
+
+	expected := map[string]string{
+		"0":     "default-web-80-pod-web-0",
+		"web-0": "default-web-80-pod-web-0",
+		"1":     "default-web-80-pod-web-1",
+		"web-1": "default-web-80-pod-web-1",
+	}
+	if len(upstream.PodBackends) != len(expected) {
+		t.Fatalf("expected %v pod backends but got %v", expected, upstream.PodBackends)
+	}
+	for pod, name := range expected {
+		if upstream.PodBackends[pod] != name {
+			t.Errorf("expected pod %v to use upstream %v but got %v", pod, name, upstream.PodBackends[pod])
+		}
+	}
+}
+
+func TestGetBackendServersFailover(t *testing.T) {
+	ctl := newNGINXController(t)
+
+	newIngress := func(name, host, service string, anns *annotations.Ingress) *ingress.Ingress {
+		return &ingress.Ingress{
+			Ingress: networking.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "example",
+				},
+				Spec: networking.IngressSpec{
+					Rules: []networking.IngressRule{
+						{
+							Host: host,
+							IngressRuleValue: networking.IngressRuleValue{
+								HTTP: &networking.HTTPIngressRuleValue{
+									Paths: []networking.HTTPIngressPath{
+										{
+											Path: "/",
+											Backend: networking.IngressBackend{
+												ServiceName: service,
+												ServicePort: intstr.FromInt(80),
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			ParsedAnnotations: anns,
+		}
+	}
+
+	ingresses := []*ingress.Ingress{
+		newIngress("primary", "primary.example.com", "primary", &annotations.Ingress{
+			Failover: failover.Config{Service: "other-cluster", Port: "8080", MaxFails: 3, FailTimeout: 10},
+		}),
+		newIngress("shared", "shared.example.com", "shared", &annotations.Ingress{
+			Failover: failover.Config{Service: "backup", MaxFails: 5, FailTimeout: 30},
+		}),
+		newIngress("backup", "backup.example.com", "backup", &annotations.Ingress{}),
+		newIngress("self", "self.example.com", "self", &annotations.Ingress{
+			Failover: failover.Config{Service: "self", MaxFails: 3, FailTimeout: 10},
+		}),
+	}
+
+	upstreams, _ := ctl.getBackendServers(ingresses)
+
+	byName := map[string]*ingress.Backend{}
+	for _, upstream := range upstreams {
+		byName[upstream.Name] = upstream
+	}
+
+	testCases := []struct {
+		upstream string
+		failover ingress.FailoverConfig
+		noServer bool
+	}{
+		{"example-primary-80", ingress.FailoverConfig{Backend: "example-other-cluster-8080", MaxFails: 3, FailTimeout: 10}, false},
+		{"example-other-cluster-8080", ingress.FailoverConfig{}, true},
+		{"example-shared-80", ingress.FailoverConfig{Backend: "example-backup-80", MaxFails: 5, FailTimeout: 30}, false},
+		{"example-backup-80", ingress.FailoverConfig{}, false},
+		{"example-self-80", ingress.FailoverConfig{}, false},
+	}
+
+	for _, tc := range testCases {
+		upstream, ok := byName[tc.upstream]
+		if !ok {
+			t.Errorf("expected upstream %v to exist", tc.upstream)
+			continue
+		}
+
+		if upstream.Failover != tc.failover {
+			t.Errorf("expected upstream %v to have failover %+v but got %+v", tc.upstream, tc.failover, upstream.Failover)
+		}
+		if upstream.NoServer != tc.noServer {
+			t.Errorf("expected upstream %v to have NoServer %v but got %v", tc.upstream, tc.noServer, upstream.NoServer)
+		}
+	}
+}
+
+type fakeSSLCertStore struct {
+	fakeIngressStore
+	certs map[string]*ingress.SSLCert
+}
+
+func (fss fakeSSLCertStore) GetLocalSSLCert(name string) (*ingress.SSLCert, error) {
+	cert, ok := fss.certs[name]
+	if !ok {
+		return nil, fmt.Errorf("certificate %v not found", name)
+	}
+
+	// the controller modifies the certificates in dynamic mode
+	c := *cert
+	return &c, nil
+}
+
+func TestCreateServersWithSSLPins(t *testing.T) {
+	newCert := func(name, host, key string) *ingress.SSLCert {
+		cert := fakeX509Cert([]string{host})
+		cert.RawSubjectPublicKeyInfo = []byte(key)
+		return &ingress.SSLCert{
+			ObjectMeta:  metav1.ObjectMeta{Namespace: "example", Name: name},
+			Certificate: cert,
+			PemFileName: "/etc/ingress-controller/ssl/example-" + name + ".pem",
+		}
+	}
+
+	pinned := newCert("pinned-tls", "pinned.example.com", "key-a")
+	rotated := newCert("rotated-tls", "rotated.example.com", "key-b")
+	wrong := newCert("wrong-tls", "*.example.com", "key-b")
+	shared := newCert("shared-tls", "shared.example.com", "key-b")
+	pinA := ssl.SPKIPin(pinned.Certificate)
+	pinB := ssl.SPKIPin(rotated.Certificate)
+
+	// the ECDSA certificate of the Secret must be pinned too
+	dual := newCert("dual-tls", "*.example.com", "key-a")
+	dual.ECDSA = newCert("dual-tls", "*.example.com", "key-b")
+
+	newIngress := func(name, host, secret string, pins sslpin.Config) *ingress.Ingress {
+		ing := &ingress.Ingress{
+			Ingress: networking.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "example"},
+				Spec: networking.IngressSpec{
+					Rules: []networking.IngressRule{{Host: host}},
+				},
+			},
+			ParsedAnnotations: &annotations.Ingress{SSLPins: pins},
+		}
+		if secret != "" {
+			ing.Spec.TLS = []networking.IngressTLS{{Hosts: []string{host}, SecretName: secret}}
+		}
+		return ing
+	}
+
+	ingresses := []*ingress.Ingress{
+		newIngress("pinned", "pinned.example.com", "pinned-tls", sslpin.Config{Enabled: true, Pins: []string{pinA}}),
+		newIngress("rotated", "rotated.example.com", "rotated-tls", sslpin.Config{Enabled: true, Pins: []string{pinA}, Override: pinB}),
+		newIngress("wrong", "wrong.example.com", "wrong-tls", sslpin.Config{Enabled: true, Pins: []string{pinA}}),
+		newIngress("shared", "shared.example.com", "shared-tls", sslpin.Config{}),
+		newIngress("shared-pins", "shared.example.com", "", sslpin.Config{Enabled: true, Pins: []string{pinA}}),
+		newIngress("unpinned", "unpinned.example.com", "wrong-tls", sslpin.Config{}),
+		newIngress("dual", "dual.example.com", "dual-tls", sslpin.Config{Enabled: true, Pins: []string{pinA}}),
+		newIngress("dual-pinned", "dual-pinned.example.com", "dual-tls", sslpin.Config{Enabled: true, Pins: []string{pinA, pinB}}),
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	fake := &ingress.SSLCert{PemFileName: "/etc/ingress-controller/ssl/default-fake-certificate.pem"}
+	ctl := &NGINXController{
+		store: fakeSSLCertStore{certs: map[string]*ingress.SSLCert{
+			"example/pinned-tls":  pinned,
+			"example/rotated-tls": rotated,
+			"example/wrong-tls":   wrong,
+			"example/shared-tls":  shared,
+			"example/dual-tls":    dual,
+		}},
+		cfg:      &Configuration{FakeCertificate: fake},
+		recorder: recorder,
+	}
+
+	du := &ingress.Backend{Name: "upstream-default-backend"}
+	servers := ctl.createServers(ingresses, map[string]*ingress.Backend{}, du)
+
+	testCases := []struct {
+		host   string
+		secret string
+	}{
+		{"pinned.example.com", "pinned-tls"},
+		{"rotated.example.com", "rotated-tls"},
+		{"wrong.example.com", ""},
+		{"shared.example.com", ""},
+		{"unpinned.example.com", "wrong-tls"},
+		{"dual.example.com", ""},
+		{"dual-pinned.example.com", "dual-tls"},
+	}
+
+	for _, tc := range testCases {
+		server, ok := servers[tc.host]
+		if !ok {
+			t.Errorf("expected a server for %v", tc.host)
+			continue
+		}
+		if server.SSLCert.Name != tc.secret {
+			t.Errorf("expected server %v to use the certificate %q but %q is used", tc.host, tc.secret, server.SSLCert.Name)
+		}
+	}
+
+	if len(recorder.Events) != 3 {
+		t.Errorf("expected 3 events but %v were recorded", len(recorder.Events))
+	}
+}
+
+func TestCreateServersWithClassDefaultCertificate(t *testing.T) {
+	certs := class.DefaultSSLCertificates
+	defer func() { class.DefaultSSLCertificates = certs }()
+	class.DefaultSSLCertificates = map[string]string{"internal": "ingress/internal-tls"}
+
+	newIngress := func(name, host, ingressClass string) *ingress.Ingress {
+		return &ingress.Ingress{
+			Ingress: networking.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        name,
+					Namespace:   "example",
+					Annotations: map[string]string{class.IngressKey: ingressClass},
+				},
+				Spec: networking.IngressSpec{
+					Rules: []networking.IngressRule{{Host: host}},
+					TLS:   []networking.IngressTLS{{Hosts: []string{host}}},
+				},
+			},
+			ParsedAnnotations: &annotations.Ingress{},
+		}
+	}
+
+	ingresses := []*ingress.Ingress{
+		newIngress("internal", "internal.example.com", "internal"),
+		newIngress("public", "public.example.com", "nginx"),
+		newIngress("partners", "partners.example.com", "partners"),
+	}
+
+	fake := &ingress.SSLCert{
+		ObjectMeta:  metav1.ObjectMeta{Name: "fake"},
+		PemFileName: "/etc/ingress-controller/ssl/default-fake-certificate.pem",
+	}
+	ctl := &NGINXController{
+		store: fakeSSLCertStore{
+			fakeIngressStore: fakeIngressStore{
+				classParams: []*v1alpha1.NginxIngressClassParams{{
+					ObjectMeta: metav1.ObjectMeta{Name: "partners"},
+					Spec:       v1alpha1.NginxIngressClassParamsSpec{DefaultSSLCertificate: "ingress/partners-tls"},
+				}},
+			},
+			certs: map[string]*ingress.SSLCert{
+				"ingress/internal-tls": {
+					ObjectMeta:  metav1.ObjectMeta{Namespace: "ingress", Name: "internal-tls"},
+					PemFileName: "/etc/ingress-controller/ssl/ingress-internal-tls.pem",
+				},
+				"ingress/partners-tls": {
+					ObjectMeta:  metav1.ObjectMeta{Namespace: "ingress", Name: "partners-tls"},
+					PemFileName: "/etc/ingress-controller/ssl/ingress-partners-tls.pem",
+				},
+			},
+		},
+		cfg:      &Configuration{FakeCertificate: fake},
+		recorder: record.NewFakeRecorder(10),
+	}
+
+	du := &ingress.Backend{Name: "upstream-default-backend"}
+	servers := ctl.createServers(ingresses, map[string]*ingress.Backend{}, du)
+
+	if name := servers["internal.example.com"].SSLCert.Name; name != "internal-tls" {
+		t.Errorf("expected the default certificate of the class but %q is used", name)
+	}
+	if name := servers["partners.example.com"].SSLCert.Name; name != "partners-tls" {
+		t.Errorf("expected the default certificate of the class parameters but %q is used", name)
+	}
+	if name := servers["public.example.com"].SSLCert.Name; name != "fake" {
+		t.Errorf("expected the default certificate but %q is used", name)
+	}
+	if name := servers[defServerName].SSLCert.Name; name != "fake" {
+		t.Errorf("expected the default certificate for the catch-all server but %q is used", name)
+	}
+}
+
 func newNGINXController(t *testing.T) *NGINXController {
 	ns := v1.NamespaceDefault
 	pod := &k8s.PodInfo{
@@ -1127,6 +1657,12 @@ func newNGINXController(t *testing.T) *NGINXController {
 		fs,
 		channels.NewRingChannel(10),
 		pod,
+		false,
+		false,
+		nil,
+		nil,
+		nil,
+		false,
 		false)
 
 	sslCert := ssl.GetFakeSSLCert(fs)
@@ -1142,6 +1678,42 @@ func newNGINXController(t *testing.T) *NGINXController {
 		cfg:        config,
 		command:    NewNginxCommand(),
 		fileSystem: fs,
+		recorder:   record.NewFakeRecorder(100),
+
+		debugHosts:     map[string]time.Time{},
+		debugHostsLock: &sync.Mutex{},
+	}
+}
+
+func TestParseUDPOptions(t *testing.T) {
+	one := 1
+	zero := 0
+
+	testCases := []struct {
+		name     string
+		options  []string
+		expected ingress.L4Backend
+	}{
+		{"no option", []string{}, ingress.L4Backend{}},
+		{"dns", []string{"responses=1", "timeout=3s"}, ingress.L4Backend{ProxyTimeout: "3s", ProxyResponses: &one}},
+		{"syslog", []string{"responses=0", "affinity=source-ip"}, ingress.L4Backend{ProxyResponses: &zero, Affinity: "source-ip"}},
+		{"no affinity", []string{"affinity=source-ip", "affinity=none"}, ingress.L4Backend{}},
+		{"invalid timeout", []string{"timeout=3 s"}, ingress.L4Backend{}},
+		{"zero timeout", []string{"timeout=0"}, ingress.L4Backend{}},
+		{"negative responses", []string{"responses=-1"}, ingress.L4Backend{}},
+		{"invalid affinity", []string{"affinity=cookie"}, ingress.L4Backend{}},
+		{"proxy protocol", []string{"PROXY", "timeout=10m"}, ingress.L4Backend{ProxyTimeout: "10m"}},
+		{"unknown option", []string{"retries=2"}, ingress.L4Backend{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := ingress.L4Backend{}
+			parseUDPOptions(tc.options, 53, &backend)
+			if !backend.Equal(&tc.expected) {
+				t.Errorf("expected %+v but returned %+v", tc.expected, backend)
+			}
+		})
 	}
 }
 
@@ -1155,3 +1727,60 @@ func fakeX509Cert(dnsNames []string) *x509.Certificate {
 		},
 	}
 }
+
+type revisionIngressStore struct {
+	fakeIngressStore
+	revision uint64
+	listed   *int
+}
+
+func (s revisionIngressStore) GetObjectsRevision() (uint64, bool) {
+	return s.revision, true
+}
+
+func (s revisionIngressStore) ListIngresses(filter store.IngressFilterFunc) []*ingress.Ingress {
+	*s.listed++
+	return s.fakeIngressStore.ListIngresses(filter)
+}
+
+func TestSyncIngressForced(t *testing.T) {
+	listed := 0
+	n := &NGINXController{
+		store:           revisionIngressStore{fakeIngressStore: fakeIngressStore{configuration: ngx_config.NewDefault()}, revision: 5, listed: &listed},
+		cfg:             &Configuration{FakeCertificate: &ingress.SSLCert{}, ListenPorts: &ngx_config.ListenPorts{Default: 80}},
+		syncLock:        &sync.Mutex{},
+		syncQueue:       task.NewTaskQueue(func(interface{}) error { return nil }),
+		syncRateLimiter: flowcontrol.NewFakeAlwaysRateLimiter(),
+		metricCollector: metric.DummyCollector{},
+		warmup:          newWarmup(),
+		syncedRevision:  5,
+	}
+
+	// no object changed since the last synchronization
+	if err := n.syncIngress(task.GetDummyObject("sync")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if listed != 0 {
+		t.Fatalf("expected the synchronization to be skipped")
+	}
+
+	// i.e. the template changed, the revision of the objects is the same
+	n.forceSync("template-change")
+	if n.syncedRevision != 0 {
+		t.Fatalf("expected the next synchronization not to be skipped")
+	}
+
+	_, _, pcfg := n.getConfiguration(nil)
+	n.runningConfig = pcfg
+	listed = 0
+
+	if err := n.syncIngress(task.GetDummyObject("template-change")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if listed == 0 {
+		t.Errorf("expected the configuration to be built again")
+	}
+	if n.syncedRevision != 5 {
+		t.Errorf("expected revision 5 to be synchronized but %v was", n.syncedRevision)
+	}
+}
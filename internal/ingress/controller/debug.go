@@ -0,0 +1,178 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// maxDebugHostDuration is the longest time the debug logs of a host can be
+// enabled, the NGINX logs at the debug level are large
+const maxDebugHostDuration = time.Hour
+
+// EnableHostDebug enables the NGINX debug logs of the server of a host
+// during the duration. NGINX is reloaded when the logs are enabled and
+// when they are disabled again.
+func (n *NGINXController) EnableHostDebug(host string, duration time.Duration) (time.Time, error) {
+	if duration <= 0 || duration > maxDebugHostDuration {
+		return time.Time{}, fmt.Errorf("the duration must be positive and at most %v", maxDebugHostDuration)
+	}
+
+	if !hasServer(n.RunningConfiguration(), host) {
+		return time.Time{}, fmt.Errorf("no server for the host %v", host)
+	}
+
+	n.syncLock.Lock()
+	defer n.syncLock.Unlock()
+
+	until := time.Now().Add(duration)
+
+	n.debugHostsLock.Lock()
+	_, enabled := n.debugHosts[host]
+	n.debugHosts[host] = until
+	n.debugHostsLock.Unlock()
+
+	// the configuration only changes when the logs were disabled,
+	// otherwise only the end of the logs changes
+	if !enabled {
+		err := n.reloadDebugHosts()
+		if err != nil {
+			n.debugHostsLock.Lock()
+			delete(n.debugHosts, host)
+			n.debugHostsLock.Unlock()
+			return time.Time{}, err
+		}
+	}
+
+	time.AfterFunc(duration, func() {
+		n.expireHostDebug(host)
+	})
+
+	return until, nil
+}
+
+// DisableHostDebug disables the NGINX debug logs of the server of a host
+func (n *NGINXController) DisableHostDebug(host string) error {
+	n.syncLock.Lock()
+	defer n.syncLock.Unlock()
+
+	n.debugHostsLock.Lock()
+	_, enabled := n.debugHosts[host]
+	delete(n.debugHosts, host)
+	n.debugHostsLock.Unlock()
+
+	if !enabled {
+		return fmt.Errorf("the debug logs of the host %v are not enabled", host)
+	}
+
+	return n.reloadDebugHosts()
+}
+
+// DebugHosts returns the hosts with the NGINX debug logs enabled and the
+// time the logs are disabled again
+func (n *NGINXController) DebugHosts() map[string]time.Time {
+	n.debugHostsLock.Lock()
+	defer n.debugHostsLock.Unlock()
+
+	hosts := make(map[string]time.Time, len(n.debugHosts))
+	for host, until := range n.debugHosts {
+		hosts[host] = until
+	}
+
+	return hosts
+}
+
+// expireHostDebug disables the NGINX debug logs of a host at the end of
+// the requested duration
+func (n *NGINXController) expireHostDebug(host string) {
+	if n.syncQueue.IsShuttingDown() {
+		return
+	}
+
+	n.syncLock.Lock()
+	defer n.syncLock.Unlock()
+
+	n.debugHostsLock.Lock()
+	until, enabled := n.debugHosts[host]
+	// the logs were enabled again after this timer was started
+	if !enabled || time.Now().Before(until) {
+		n.debugHostsLock.Unlock()
+		return
+	}
+	delete(n.debugHosts, host)
+	n.debugHostsLock.Unlock()
+
+	err := n.reloadDebugHosts()
+	if err != nil {
+		klog.Errorf("Unexpected error disabling the NGINX debug logs of the host %v: %v", host, err)
+		return
+	}
+
+	klog.Infof("Disabled the NGINX debug logs of the host %v", host)
+}
+
+// activeDebugHosts returns the hosts with the NGINX debug logs enabled at
+// the given time, used to render the configuration
+func (n *NGINXController) activeDebugHosts(now time.Time) map[string]bool {
+	n.debugHostsLock.Lock()
+	defer n.debugHostsLock.Unlock()
+
+	hosts := map[string]bool{}
+	for host, until := range n.debugHosts {
+		if now.Before(until) {
+			hosts[host] = true
+		}
+	}
+
+	return hosts
+}
+
+// reloadDebugHosts renders the running configuration again with the
+// current debug hosts and reloads NGINX. It must be called with the sync
+// lock held.
+func (n *NGINXController) reloadDebugHosts() error {
+	pcfg := n.RunningConfiguration()
+	if pcfg.Equal(&ingress.Configuration{}) {
+		return fmt.Errorf("the configuration is not synchronized yet")
+	}
+
+	reloaded, err := n.OnUpdate(*pcfg)
+	if err != nil {
+		n.metricCollector.IncReloadErrorCount()
+		return err
+	}
+
+	if reloaded {
+		n.metricCollector.IncReloadCount()
+	}
+	return nil
+}
+
+func hasServer(pcfg *ingress.Configuration, host string) bool {
+	for _, server := range pcfg.Servers {
+		if server.Hostname == host {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+func TestEnableHostDebug(t *testing.T) {
+	n := &NGINXController{
+		runningConfig: &ingress.Configuration{
+			Servers: []*ingress.Server{{Hostname: "example.com"}},
+		},
+		runningConfigLock: &sync.RWMutex{},
+		syncLock:          &sync.Mutex{},
+		debugHosts:        map[string]time.Time{},
+		debugHostsLock:    &sync.Mutex{},
+	}
+
+	if _, err := n.EnableHostDebug("example.com", 2*time.Hour); err == nil {
+		t.Errorf("expected an error with a duration longer than %v", maxDebugHostDuration)
+	}
+	if _, err := n.EnableHostDebug("example.com", 0); err == nil {
+		t.Errorf("expected an error without duration")
+	}
+	if _, err := n.EnableHostDebug("foo.bar", time.Minute); err == nil {
+		t.Errorf("expected an error for a host without server")
+	}
+	if err := n.DisableHostDebug("example.com"); err == nil {
+		t.Errorf("expected an error for a host without debug logs")
+	}
+
+	if len(n.DebugHosts()) != 0 {
+		t.Errorf("expected no debug host but returned %v", n.DebugHosts())
+	}
+}
+
+func TestActiveDebugHosts(t *testing.T) {
+	now := time.Now()
+	n := &NGINXController{
+		debugHosts: map[string]time.Time{
+			"example.com": now.Add(time.Minute),
+			"foo.bar":     now.Add(-time.Minute),
+		},
+		debugHostsLock: &sync.Mutex{},
+	}
+
+	hosts := n.activeDebugHosts(now)
+	if len(hosts) != 1 || !hosts["example.com"] {
+		t.Errorf("expected only example.com but returned %v", hosts)
+	}
+
+	if hosts := n.activeDebugHosts(now.Add(2 * time.Minute)); len(hosts) != 0 {
+		t.Errorf("expected no debug host after the expiration but returned %v", hosts)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/task"
+)
+
+// debugTokenKey is the key of the Secret containing the secret used to
+// sign the debug tokens
+const debugTokenKey = "key"
+
+// debugTokenConfig is the configuration used by Lua to check the debug tokens
+type debugTokenConfig struct {
+	Key    string `json:"key"`
+	MaxTTL int    `json:"maxTTL"`
+}
+
+// configureDebugToken sends the secret used to check the debug tokens to
+// NGINX, only if it changed since the last call. The secret is kept in
+// memory and never written in the configuration files.
+func (n *NGINXController) configureDebugToken() error {
+	cfg := n.store.GetBackendConfiguration()
+
+	debugToken := debugTokenConfig{
+		MaxTTL: cfg.DebugTokenMaxTTL,
+	}
+
+	if cfg.DebugTokenSecret != "" {
+		secret, err := n.store.GetSecret(cfg.DebugTokenSecret)
+		if err != nil {
+			klog.Warningf("Error reading the debug token secret %v, debug headers disabled: %v", cfg.DebugTokenSecret, err)
+		} else if key, ok := secret.Data[debugTokenKey]; !ok || len(key) == 0 {
+			klog.Warningf("Secret %v does not contain the key %q, debug headers disabled", cfg.DebugTokenSecret, debugTokenKey)
+		} else {
+			debugToken.Key = string(key)
+		}
+	}
+
+	// nothing to disable if the debug headers were never enabled
+	if debugToken.Key == "" && n.debugTokenChecksum == "" {
+		return nil
+	}
+
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%v:%v", debugToken.MaxTTL, debugToken.Key))))
+	if checksum == n.debugTokenChecksum {
+		return nil
+	}
+
+	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/debug-token", "application/json", debugToken)
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	n.debugTokenChecksum = checksum
+	return nil
+}
+
+// syncDebugToken configures the debug tokens, the errors are only logged
+// because the debug headers must not prevent the synchronization
+func (n *NGINXController) syncDebugToken() {
+	err := n.configureDebugToken()
+	if err != nil {
+		klog.Warningf("Unexpected failure configuring the debug token: %v", err)
+	}
+}
+
+// resetDebugToken forgets the debug token configuration sent to a NGINX
+// master process which died and synchronizes the configuration, so the
+// debug token is sent again to the new master process.
+func (n *NGINXController) resetDebugToken() {
+	n.syncLock.Lock()
+	n.debugTokenChecksum = ""
+	// the synchronization must not be skipped even if no object changed
+	n.syncedRevision = 0
+	n.syncLock.Unlock()
+
+	n.syncQueue.EnqueueTask(task.GetDummyObject("nginx-respawn"))
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/task"
+)
+
+type fakeDebugTokenStore struct {
+	fakeIngressStore
+	secrets map[string]*corev1.Secret
+}
+
+func (fs fakeDebugTokenStore) GetSecret(key string) (*corev1.Secret, error) {
+	secret, ok := fs.secrets[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %v not found", key)
+	}
+	return secret, nil
+}
+
+func TestConfigureDebugToken(t *testing.T) {
+	listener, err := net.Listen("unix", nginx.StatusSocket)
+	if err != nil {
+		t.Fatalf("crating unix listener: %s", err)
+	}
+	defer listener.Close()
+	defer os.Remove(nginx.StatusSocket)
+
+	bodies := []string{}
+	server := &httptest.Server{
+		Listener: listener,
+		Config: &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/configuration/debug-token" {
+					t.Errorf("unknown request to %s", r.URL.Path)
+				}
+
+				b, _ := ioutil.ReadAll(r.Body)
+				bodies = append(bodies, string(b))
+				w.WriteHeader(http.StatusCreated)
+			}),
+		},
+	}
+	defer server.Close()
+	server.Start()
+
+	cfg := ngx_config.NewDefault()
+	cfg.DebugTokenSecret = "default/debug-token"
+	fs := fakeDebugTokenStore{
+		fakeIngressStore: fakeIngressStore{configuration: cfg},
+		secrets:          map[string]*corev1.Secret{},
+	}
+	n := &NGINXController{
+		store:     fs,
+		syncLock:  &sync.Mutex{},
+		syncQueue: task.NewTaskQueue(func(interface{}) error { return nil }),
+	}
+
+	// never enabled
+	if err := n.configureDebugToken(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bodies) != 0 {
+		t.Fatalf("expected no request but %v were sent", len(bodies))
+	}
+
+	fs.secrets["default/debug-token"] = &corev1.Secret{
+		Data: map[string][]byte{"key": []byte("s3cr3t")},
+	}
+	n.store = fs
+
+	for i := 0; i < 2; i++ {
+		if err := n.configureDebugToken(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("expected one request but %v were sent", len(bodies))
+	}
+	if bodies[0] != `{"key":"s3cr3t","maxTTL":3600}` {
+		t.Errorf("unexpected debug token configuration %v", bodies[0])
+	}
+
+	// the debug token is sent again to a new NGINX master process
+	n.syncedRevision = 1
+	n.resetDebugToken()
+	if n.syncedRevision != 0 {
+		t.Errorf("expected the next synchronization not to be skipped")
+	}
+	if err := n.configureDebugToken(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bodies) != 2 || bodies[1] != bodies[0] {
+		t.Fatalf("expected the debug token to be sent again but requests were %v", bodies)
+	}
+
+	delete(fs.secrets, "default/debug-token")
+	if err := n.configureDebugToken(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bodies) != 3 || bodies[2] != `{"key":"","maxTTL":3600}` {
+		t.Errorf("expected the debug headers to be disabled but requests were %v", bodies)
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
+)
+
+// getHostDelegations returns the HostDelegation owning each host. When more
+// than one HostDelegation claims the same host the oldest one is used.
+func (n *NGINXController) getHostDelegations() map[string]*v1alpha1.HostDelegation {
+	delegations := make(map[string]*v1alpha1.HostDelegation)
+	if !n.cfg.EnableHostDelegation {
+		return delegations
+	}
+
+	for _, hd := range n.store.ListHostDelegations() {
+		host := hd.Spec.Host
+		if host == "" {
+			klog.Warningf("Ignoring HostDelegation %v without host", k8s.MetaNamespaceKey(hd))
+			continue
+		}
+
+		if owner, ok := delegations[host]; ok {
+			klog.Warningf("Ignoring HostDelegation %v, host %v is owned by HostDelegation %v",
+				k8s.MetaNamespaceKey(hd), host, k8s.MetaNamespaceKey(owner))
+			continue
+		}
+
+		delegations[host] = hd
+	}
+
+	return delegations
+}
+
+// applyHostDelegations returns the Ingresses without the rules and TLS hosts
+// that are not allowed by the HostDelegation owning the host. Ingresses
+// without changes are returned as is.
+func applyHostDelegations(ingresses []*ingress.Ingress, delegations map[string]*v1alpha1.HostDelegation) []*ingress.Ingress {
+	if len(delegations) == 0 {
+		return ingresses
+	}
+
+	result := make([]*ingress.Ingress, 0, len(ingresses))
+	for _, ing := range ingresses {
+		spec, rejected := delegatedSpec(&ing.Spec, ing.Namespace, delegations)
+		if len(rejected) == 0 {
+			result = append(result, ing)
+			continue
+		}
+
+		for _, msg := range rejected {
+			klog.Warningf("Ignoring %v in Ingress %v", msg, k8s.MetaNamespaceKey(ing))
+		}
+
+		copyIng := *ing
+		copyIng.Spec = *spec
+		result = append(result, &copyIng)
+	}
+
+	return result
+}
+
+// checkHostDelegations returns an error if the Ingress contains rules
+// that are not allowed by the HostDelegation owning the host
+func checkHostDelegations(ing *networking.Ingress, delegations map[string]*v1alpha1.HostDelegation) error {
+	_, rejected := delegatedSpec(&ing.Spec, ing.Namespace, delegations)
+	if len(rejected) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("the Ingress contains rules not delegated to namespace %v: %v", ing.Namespace, strings.Join(rejected, ", "))
+}
+
+// delegatedSpec returns a copy of the Ingress spec without the rules and TLS
+// hosts not allowed in the namespace and a description of the removed items
+func delegatedSpec(spec *networking.IngressSpec, namespace string, delegations map[string]*v1alpha1.HostDelegation) (*networking.IngressSpec, []string) {
+	rejected := []string{}
+	delegated := spec.DeepCopy()
+
+	rules := make([]networking.IngressRule, 0, len(delegated.Rules))
+	for _, rule := range delegated.Rules {
+		hd, ok := delegations[rule.Host]
+		if !ok || hd.Namespace == namespace {
+			rules = append(rules, rule)
+			continue
+		}
+
+		if rule.HTTP == nil {
+			rejected = append(rejected, fmt.Sprintf("host %v", rule.Host))
+			continue
+		}
+
+		paths := make([]networking.HTTPIngressPath, 0, len(rule.HTTP.Paths))
+		for _, path := range rule.HTTP.Paths {
+			if !isDelegated(hd, namespace, path.Path) {
+				rejected = append(rejected, fmt.Sprintf("path %v of host %v", path.Path, rule.Host))
+				continue
+			}
+
+			paths = append(paths, path)
+		}
+
+		if len(paths) == 0 {
+			continue
+		}
+
+		rule.HTTP.Paths = paths
+		rules = append(rules, rule)
+	}
+	delegated.Rules = rules
+
+	tls := make([]networking.IngressTLS, 0, len(delegated.TLS))
+	for _, t := range delegated.TLS {
+		hosts := make([]string, 0, len(t.Hosts))
+		for _, host := range t.Hosts {
+			if hd, ok := delegations[host]; ok && hd.Namespace != namespace {
+				rejected = append(rejected, fmt.Sprintf("TLS host %v", host))
+				continue
+			}
+
+			hosts = append(hosts, host)
+		}
+
+		if len(hosts) == 0 && len(t.Hosts) > 0 {
+			continue
+		}
+
+		t.Hosts = hosts
+		tls = append(tls, t)
+	}
+	delegated.TLS = tls
+
+	return delegated, rejected
+}
+
+// isDelegated returns true if the HostDelegation allows Ingresses of the
+// namespace to define the path
+func isDelegated(hd *v1alpha1.HostDelegation, namespace, path string) bool {
+	if path == "" {
+		path = rootLocation
+	}
+
+	for _, delegation := range hd.Spec.Delegations {
+		if !hasNamespace(delegation.Namespaces, namespace) {
+			continue
+		}
+
+		prefix := strings.TrimSuffix(delegation.PathPrefix, "/")
+		if prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasNamespace(namespaces []string, namespace string) bool {
+	for _, ns := range namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+
+	return false
+}
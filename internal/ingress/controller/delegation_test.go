@@ -0,0 +1,221 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
+)
+
+func newHostDelegation(namespace, host string, created time.Time, delegations ...v1alpha1.Delegation) *v1alpha1.HostDelegation {
+	return &v1alpha1.HostDelegation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "delegation",
+			Namespace:         namespace,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: v1alpha1.HostDelegationSpec{
+			Host:        host,
+			Delegations: delegations,
+		},
+	}
+}
+
+func newDelegatedIngress(namespace, host string, tls bool, paths ...string) *ingress.Ingress {
+	httpPaths := []networking.HTTPIngressPath{}
+	for _, path := range paths {
+		httpPaths = append(httpPaths, networking.HTTPIngressPath{
+			Path: path,
+			Backend: networking.IngressBackend{
+				ServiceName: "http-svc",
+			},
+		})
+	}
+
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: namespace,
+			},
+			Spec: networking.IngressSpec{
+				Rules: []networking.IngressRule{
+					{
+						Host: host,
+						IngressRuleValue: networking.IngressRuleValue{
+							HTTP: &networking.HTTPIngressRuleValue{
+								Paths: httpPaths,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if tls {
+		ing.Spec.TLS = []networking.IngressTLS{{Hosts: []string{host}, SecretName: "tls"}}
+	}
+
+	return ing
+}
+
+func ingressPaths(ing *ingress.Ingress) []string {
+	paths := []string{}
+	for _, rule := range ing.Spec.Rules {
+		for _, path := range rule.HTTP.Paths {
+			paths = append(paths, path.Path)
+		}
+	}
+	return paths
+}
+
+func TestIsDelegated(t *testing.T) {
+	hd := newHostDelegation("platform", "example.com", time.Now(),
+		v1alpha1.Delegation{PathPrefix: "/cart", Namespaces: []string{"cart"}},
+		v1alpha1.Delegation{PathPrefix: "/static/", Namespaces: []string{"cart", "web"}},
+	)
+
+	testCases := []struct {
+		namespace string
+		path      string
+		expected  bool
+	}{
+		{"cart", "/cart", true},
+		{"cart", "/cart/checkout", true},
+		{"cart", "/cartography", false},
+		{"cart", "/", false},
+		{"cart", "", false},
+		{"cart", "/static/css", true},
+		{"web", "/static", true},
+		{"web", "/cart", false},
+		{"other", "/static", false},
+	}
+
+	for _, tc := range testCases {
+		if result := isDelegated(hd, tc.namespace, tc.path); result != tc.expected {
+			t.Errorf("expected %v for path %v in namespace %v but returned %v", tc.expected, tc.path, tc.namespace, result)
+		}
+	}
+
+	root := newHostDelegation("platform", "example.com", time.Now(),
+		v1alpha1.Delegation{PathPrefix: "/", Namespaces: []string{"web"}},
+	)
+	if !isDelegated(root, "web", "/anything") {
+		t.Errorf("expected all the paths to be delegated with the prefix /")
+	}
+}
+
+func TestApplyHostDelegations(t *testing.T) {
+	delegations := map[string]*v1alpha1.HostDelegation{
+		"example.com": newHostDelegation("platform", "example.com", time.Now(),
+			v1alpha1.Delegation{PathPrefix: "/cart", Namespaces: []string{"cart"}},
+		),
+	}
+
+	owner := newDelegatedIngress("platform", "example.com", true, "/")
+	cart := newDelegatedIngress("cart", "example.com", true, "/cart", "/admin")
+	intruder := newDelegatedIngress("other", "example.com", false, "/")
+	unrelated := newDelegatedIngress("other", "other.example.com", true, "/")
+
+	result := applyHostDelegations([]*ingress.Ingress{owner, cart, intruder, unrelated}, delegations)
+	if len(result) != 4 {
+		t.Fatalf("expected 4 ingresses but returned %v", len(result))
+	}
+
+	if result[0] != owner {
+		t.Errorf("expected the Ingress of the owner to not change")
+	}
+
+	if result[3] != unrelated {
+		t.Errorf("expected the Ingress of a host without HostDelegation to not change")
+	}
+
+	paths := ingressPaths(result[1])
+	if len(paths) != 1 || paths[0] != "/cart" {
+		t.Errorf("expected only the delegated path /cart but returned %v", paths)
+	}
+
+	if len(result[1].Spec.TLS) != 0 {
+		t.Errorf("expected the TLS host of a delegated Ingress to be removed but returned %v", result[1].Spec.TLS)
+	}
+
+	if len(cart.Spec.Rules[0].HTTP.Paths) != 2 || len(cart.Spec.TLS) != 1 {
+		t.Errorf("expected the original Ingress to not change")
+	}
+
+	if len(result[2].Spec.Rules) != 0 {
+		t.Errorf("expected no rules for an Ingress without delegated paths but returned %v", result[2].Spec.Rules)
+	}
+
+	if result := applyHostDelegations([]*ingress.Ingress{cart}, nil); result[0] != cart {
+		t.Errorf("expected no changes without HostDelegations")
+	}
+}
+
+func TestCheckHostDelegations(t *testing.T) {
+	delegations := map[string]*v1alpha1.HostDelegation{
+		"example.com": newHostDelegation("platform", "example.com", time.Now(),
+			v1alpha1.Delegation{PathPrefix: "/cart", Namespaces: []string{"cart"}},
+		),
+	}
+
+	if err := checkHostDelegations(&newDelegatedIngress("cart", "example.com", false, "/cart").Ingress, delegations); err != nil {
+		t.Errorf("unexpected error for a delegated path: %v", err)
+	}
+
+	if err := checkHostDelegations(&newDelegatedIngress("cart", "example.com", false, "/admin").Ingress, delegations); err == nil {
+		t.Errorf("expected an error for a path not delegated")
+	}
+
+	if err := checkHostDelegations(&newDelegatedIngress("cart", "example.com", true, "/cart").Ingress, delegations); err == nil {
+		t.Errorf("expected an error for a TLS host not delegated")
+	}
+}
+
+func TestGetHostDelegations(t *testing.T) {
+	now := time.Now()
+	oldest := newHostDelegation("platform", "example.com", now.Add(-time.Hour))
+	newest := newHostDelegation("other", "example.com", now)
+
+	n := &NGINXController{
+		cfg: &Configuration{},
+		store: fakeIngressStore{
+			delegations: []*v1alpha1.HostDelegation{oldest, newest, newHostDelegation("empty", "", now)},
+		},
+	}
+
+	if delegations := n.getHostDelegations(); len(delegations) != 0 {
+		t.Errorf("expected no HostDelegations when the feature is disabled but returned %v", delegations)
+	}
+
+	n.cfg.EnableHostDelegation = true
+	delegations := n.getHostDelegations()
+	if len(delegations) != 1 {
+		t.Fatalf("expected one HostDelegation but returned %v", delegations)
+	}
+
+	if delegations["example.com"] != oldest {
+		t.Errorf("expected the oldest HostDelegation to own the host")
+	}
+}
@@ -30,11 +30,14 @@ import (
 
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/logging"
 )
 
 // getEndpoints returns a list of Endpoint structs for a given service/target port combination.
+// The weight of each endpoint is returned by getEndpointWeight.
 func getEndpoints(s *corev1.Service, port *corev1.ServicePort, proto corev1.Protocol,
-	getServiceEndpoints func(string) (*corev1.Endpoints, error)) []ingress.Endpoint {
+	getServiceEndpoints func(string) (*corev1.Endpoints, error),
+	getEndpointWeight func(*corev1.ObjectReference) int) []ingress.Endpoint {
 
 	upsServers := []ingress.Endpoint{}
 
@@ -50,7 +53,7 @@ func getEndpoints(s *corev1.Service, port *corev1.ServicePort, proto corev1.Prot
 
 	// ExternalName services
 	if s.Spec.Type == corev1.ServiceTypeExternalName {
-		klog.V(3).Infof("Ingress using Service %q of type ExternalName.", svcKey)
+		logging.V(3).Infof("Ingress using Service %q of type ExternalName.", svcKey)
 
 		targetPort := port.TargetPort.IntValue()
 		if targetPort <= 0 {
@@ -90,7 +93,7 @@ func getEndpoints(s *corev1.Service, port *corev1.ServicePort, proto corev1.Prot
 		})
 	}
 
-	klog.V(3).Infof("Getting Endpoints for Service %q and port %v", svcKey, port.String())
+	logging.V(3).Infof("Getting Endpoints for Service %q and port %v", svcKey, port.String())
 	ep, err := getServiceEndpoints(svcKey)
 	if err != nil {
 		klog.Warningf("Error obtaining Endpoints for Service %q: %v", svcKey, err)
@@ -126,6 +129,7 @@ func getEndpoints(s *corev1.Service, port *corev1.ServicePort, proto corev1.Prot
 					Address: epAddress.IP,
 					Port:    fmt.Sprintf("%v", targetPort),
 					Target:  epAddress.TargetRef,
+					Weight:  getEndpointWeight(epAddress.TargetRef),
 				}
 				upsServers = append(upsServers, ups)
 				processedUpstreamServers[ep] = struct{}{}
@@ -133,6 +137,28 @@ func getEndpoints(s *corev1.Service, port *corev1.ServicePort, proto corev1.Prot
 		}
 	}
 
-	klog.V(3).Infof("Endpoints found for Service %q: %v", svcKey, upsServers)
+	logging.V(3).Infof("Endpoints found for Service %q: %v", svcKey, upsServers)
 	return upsServers
 }
+
+// filterEndpoints returns the endpoints provided by a Pod containing the
+// condition, checked by hasCondition. All the endpoints are returned when the
+// condition is empty.
+func filterEndpoints(endpoints []ingress.Endpoint, condition string,
+	hasCondition func(*corev1.ObjectReference, string) bool) []ingress.Endpoint {
+
+	if condition == "" {
+		return endpoints
+	}
+
+	filtered := []ingress.Endpoint{}
+	for _, ep := range endpoints {
+		if !hasCondition(ep.Target, condition) {
+			logging.V(3).Infof("Skipping Endpoint %v:%v without the condition %q", ep.Address, ep.Port, condition)
+			continue
+		}
+		filtered = append(filtered, ep)
+	}
+
+	return filtered
+}
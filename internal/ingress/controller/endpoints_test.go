@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/ingress-nginx/internal/ingress"
 )
@@ -385,10 +386,83 @@ func TestGetEndpoints(t *testing.T) {
 
 	for _, testCase := range tests {
 		t.Run(testCase.name, func(t *testing.T) {
-			result := getEndpoints(testCase.svc, testCase.port, testCase.proto, testCase.fn)
+			result := getEndpoints(testCase.svc, testCase.port, testCase.proto, testCase.fn, noEndpointWeight)
 			if len(testCase.result) != len(result) {
 				t.Errorf("Expected %d Endpoints but got %d", len(testCase.result), len(result))
 			}
 		})
 	}
 }
+
+func noEndpointWeight(*corev1.ObjectReference) int {
+	return 0
+}
+
+func TestGetEndpointsWeight(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeClusterIP,
+		},
+	}
+	port := &corev1.ServicePort{TargetPort: intstr.FromInt(8080)}
+
+	getServiceEndpoints := func(string) (*corev1.Endpoints, error) {
+		return &corev1.Endpoints{
+			Subsets: []corev1.EndpointSubset{
+				{
+					Addresses: []corev1.EndpointAddress{
+						{IP: "10.0.0.1", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "app-1"}},
+						{IP: "10.0.0.2", TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "app-2"}},
+					},
+					Ports: []corev1.EndpointPort{{Protocol: corev1.ProtocolTCP, Port: 8080}},
+				},
+			},
+		}, nil
+	}
+	getEndpointWeight := func(target *corev1.ObjectReference) int {
+		if target.Name == "app-2" {
+			return 25
+		}
+		return 0
+	}
+
+	result := getEndpoints(svc, port, corev1.ProtocolTCP, getServiceEndpoints, getEndpointWeight)
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 Endpoints but got %d", len(result))
+	}
+	if result[0].Weight != 0 {
+		t.Errorf("Expected the default weight for %v but got %d", result[0].Address, result[0].Weight)
+	}
+	if result[1].Weight != 25 {
+		t.Errorf("Expected a weight of 25 for %v but got %d", result[1].Address, result[1].Weight)
+	}
+}
+
+func TestFilterEndpoints(t *testing.T) {
+	endpoints := []ingress.Endpoint{
+		{Address: "10.0.0.1", Port: "8080", Target: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "app-1"}},
+		{Address: "10.0.0.2", Port: "8080", Target: &corev1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "app-2"}},
+	}
+	hasCondition := func(target *corev1.ObjectReference, condition string) bool {
+		return target.Name == "app-2" && condition == "ingress-warmup-complete"
+	}
+
+	result := filterEndpoints(endpoints, "", hasCondition)
+	if len(result) != 2 {
+		t.Errorf("Expected 2 Endpoints without condition but got %d", len(result))
+	}
+
+	result = filterEndpoints(endpoints, "ingress-warmup-complete", hasCondition)
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 Endpoint but got %d", len(result))
+	}
+	if result[0].Address != "10.0.0.2" {
+		t.Errorf("Expected the Endpoint 10.0.0.2 but got %v", result[0].Address)
+	}
+
+	result = filterEndpoints(endpoints, "other", hasCondition)
+	if len(result) != 0 {
+		t.Errorf("Expected no Endpoint but got %d", len(result))
+	}
+}
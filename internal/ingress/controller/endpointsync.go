@@ -0,0 +1,204 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/logging"
+	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/task"
+)
+
+// luaEndpoints is the change of the endpoints of a backend posted to Lua
+type luaEndpoints struct {
+	Name      string             `json:"name"`
+	Endpoints []ingress.Endpoint `json:"endpoints"`
+}
+
+// syncEndpoints applies the changes of the Endpoints of a Service to the
+// backends of the running configuration through the Lua balancer, without
+// building the whole configuration. The changes modifying the structure of
+// the configuration are applied by a synchronization of the sync queue.
+func (n *NGINXController) syncEndpoints(key interface{}) error {
+	svcKey := fmt.Sprintf("%v", key.(task.Element).Key)
+
+	n.syncLock.Lock()
+	defer n.syncLock.Unlock()
+
+	changed, err := n.changedEndpointsBackends(svcKey)
+	if err == nil && len(changed) == 0 {
+		logging.V(3).Infof("No endpoint change detected for Service %q.", svcKey)
+		return nil
+	}
+
+	if err == nil {
+		err = configureEndpoints(changed, n.store.GetBackendConfiguration().EnableBackendMetadata)
+	}
+
+	if err != nil {
+		logging.V(2).Infof("Synchronizing the configuration for the Endpoints of Service %q: %v", svcKey, err)
+		n.syncQueue.EnqueueSkippablePriorityTask(cache.ExplicitKey(svcKey))
+		return nil
+	}
+
+	n.setRunningConfig(replaceBackends(n.runningConfig, changed))
+
+	names := make([]string, 0, len(changed))
+	for _, backend := range changed {
+		names = append(names, backend.Name)
+	}
+	klog.Infof("Endpoints of Service %q updated without synchronization of the configuration (backends %v).", svcKey, strings.Join(names, ", "))
+
+	return nil
+}
+
+// changedEndpointsBackends returns copies of the backends of the running
+// configuration using the Service whose endpoints changed, with their new
+// endpoints. An error is returned when the change requires a synchronization
+// of the configuration:
+//   - the initial synchronization did not happen yet
+//   - the Service is the default backend, the default-backend annotation of a
+//     location or a TCP or UDP service
+//   - the requests of a backend are routed to its pods
+//   - a backend has no endpoint anymore or had no endpoint, the locations
+//     using it are configured differently
+func (n *NGINXController) changedEndpointsBackends(svcKey string) ([]*ingress.Backend, error) {
+	running := n.runningConfig
+	if running.Equal(&ingress.Configuration{}) {
+		return nil, fmt.Errorf("the initial synchronization is pending")
+	}
+
+	if svcKey == n.cfg.DefaultService {
+		return nil, fmt.Errorf("the Service is the default backend")
+	}
+
+	for _, l4 := range running.TCPEndpoints {
+		if fmt.Sprintf("%v/%v", l4.Backend.Namespace, l4.Backend.Name) == svcKey {
+			return nil, fmt.Errorf("the Service is used by a TCP service")
+		}
+	}
+	for _, l4 := range running.UDPEndpoints {
+		if fmt.Sprintf("%v/%v", l4.Backend.Namespace, l4.Backend.Name) == svcKey {
+			return nil, fmt.Errorf("the Service is used by a UDP service")
+		}
+	}
+
+	for _, server := range running.Servers {
+		for _, location := range server.Locations {
+			if location.DefaultBackend != nil && k8s.MetaNamespaceKey(location.DefaultBackend) == svcKey {
+				return nil, fmt.Errorf("the Service is the default backend of location %q of server %q", location.Path, server.Hostname)
+			}
+		}
+	}
+
+	var backends []*ingress.Backend
+	for _, backend := range running.Backends {
+		// the custom default backends are copies of the backend of the
+		// location with the endpoints of the Service of the annotation
+		if strings.HasPrefix(backend.Name, "custom-default-backend-") {
+			continue
+		}
+
+		if backend.Service != nil && k8s.MetaNamespaceKey(backend.Service) == svcKey {
+			backends = append(backends, backend)
+		}
+	}
+
+	var changed []*ingress.Backend
+	for _, backend := range backends {
+		if backend.PodRoutingBy != "" {
+			return nil, fmt.Errorf("the requests of backend %q are routed to its pods", backend.Name)
+		}
+
+		// the endpoint is the ClusterIP of the Service
+		if backend.ServiceUpstream {
+			continue
+		}
+
+		endps, err := n.serviceEndpoints(svcKey, backend.Port.String())
+		if err != nil {
+			return nil, err
+		}
+		endps = filterEndpoints(endps, backend.EndpointCondition, n.store.HasEndpointCondition)
+
+		if (len(endps) == 0) != (len(backend.Endpoints) == 0) {
+			return nil, fmt.Errorf("the backend %q has no endpoint anymore or had no endpoint", backend.Name)
+		}
+
+		updated := *backend
+		updated.Endpoints = endps
+		if updated.Equal(backend) {
+			continue
+		}
+
+		changed = append(changed, &updated)
+	}
+
+	return changed, nil
+}
+
+// replaceBackends returns a copy of the configuration with the given backends
+// replacing the backends with the same name
+func replaceBackends(pcfg *ingress.Configuration, backends []*ingress.Backend) *ingress.Configuration {
+	byName := make(map[string]*ingress.Backend, len(backends))
+	for _, backend := range backends {
+		byName[backend.Name] = backend
+	}
+
+	updated := *pcfg
+	updated.Backends = make([]*ingress.Backend, 0, len(pcfg.Backends))
+	for _, backend := range pcfg.Backends {
+		if b, ok := byName[backend.Name]; ok {
+			backend = b
+		}
+		updated.Backends = append(updated.Backends, backend)
+	}
+
+	return &updated
+}
+
+// configureEndpoints POSTs the endpoints of the given backends to an internal
+// HTTP endpoint handled by Lua, which replaces the endpoints of these backends
+// only.
+func configureEndpoints(backends []*ingress.Backend, backendMetadata bool) error {
+	changes := make([]luaEndpoints, 0, len(backends))
+	for _, backend := range luaBackends(backends, backendMetadata) {
+		changes = append(changes, luaEndpoints{
+			Name:      backend.Name,
+			Endpoints: backend.Endpoints,
+		})
+	}
+
+	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/endpoints", "application/json", changes)
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	return nil
+}
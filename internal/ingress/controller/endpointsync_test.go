@@ -0,0 +1,134 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+func TestChangedEndpointsBackends(t *testing.T) {
+	newService := func(name string) *apiv1.Service {
+		return &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "example"}}
+	}
+
+	running := &ingress.Configuration{
+		Backends: []*ingress.Backend{
+			{
+				Name:      "example-app-80",
+				Service:   newService("app"),
+				Port:      intstr.FromInt(80),
+				Endpoints: []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}},
+			},
+			{
+				Name:         "example-pods-80",
+				Service:      newService("pods"),
+				Port:         intstr.FromInt(80),
+				PodRoutingBy: "header-x-pod",
+			},
+			{
+				Name:            "example-upstream-80",
+				Service:         newService("upstream"),
+				Port:            intstr.FromInt(80),
+				ServiceUpstream: true,
+			},
+		},
+		Servers: []*ingress.Server{
+			{
+				Hostname: "app.example.com",
+				Locations: []*ingress.Location{
+					{Path: "/", DefaultBackend: newService("errors")},
+				},
+			},
+		},
+		TCPEndpoints: []ingress.L4Service{
+			{Backend: ingress.L4Backend{Name: "redis", Namespace: "example"}},
+		},
+		UDPEndpoints: []ingress.L4Service{
+			{Backend: ingress.L4Backend{Name: "dns", Namespace: "example"}},
+		},
+	}
+
+	testCases := []struct {
+		name      string
+		running   *ingress.Configuration
+		svcKey    string
+		expectErr bool
+	}{
+		{"initial synchronization pending", &ingress.Configuration{}, "example/app", true},
+		{"default backend", running, "example/default", true},
+		{"TCP service", running, "example/redis", true},
+		{"UDP service", running, "example/dns", true},
+		{"default backend of a location", running, "example/errors", true},
+		{"routing to the pods", running, "example/pods", true},
+		{"service upstream", running, "example/upstream", false},
+		{"unused service", running, "example/unused", false},
+		// the fake store does not return the Service
+		{"used service", running, "example/app", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := &NGINXController{
+				store:         fakeIngressStore{},
+				cfg:           &Configuration{DefaultService: "example/default"},
+				runningConfig: tc.running,
+			}
+
+			changed, err := n.changedEndpointsBackends(tc.svcKey)
+			if tc.expectErr && err == nil {
+				t.Errorf("expected an error but none was returned")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(changed) != 0 {
+				t.Errorf("expected no changed backend but %v returned", len(changed))
+			}
+		})
+	}
+}
+
+func TestReplaceBackends(t *testing.T) {
+	pcfg := &ingress.Configuration{
+		Backends: []*ingress.Backend{
+			{Name: "example-app-80", Endpoints: []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}}},
+			{Name: "example-api-80", Endpoints: []ingress.Endpoint{{Address: "10.0.0.2", Port: "8080"}}},
+		},
+	}
+	changed := &ingress.Backend{Name: "example-api-80", Endpoints: []ingress.Endpoint{{Address: "10.0.0.3", Port: "8080"}}}
+
+	updated := replaceBackends(pcfg, []*ingress.Backend{changed})
+
+	if len(updated.Backends) != 2 {
+		t.Fatalf("expected 2 backends but %v returned", len(updated.Backends))
+	}
+	if updated.Backends[0] != pcfg.Backends[0] {
+		t.Errorf("expected the unchanged backend to be kept")
+	}
+	if updated.Backends[1] != changed {
+		t.Errorf("expected the backend %v to be replaced", changed.Name)
+	}
+	if pcfg.Backends[1].Endpoints[0].Address != "10.0.0.2" {
+		t.Errorf("expected the original configuration not to be modified")
+	}
+}
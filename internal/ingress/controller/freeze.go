@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/freeze"
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/task"
+)
+
+// configFreezeReason returns a description of the configuration freeze
+// active at t, or an empty string if the configuration is not frozen
+func (n *NGINXController) configFreezeReason(t time.Time) string {
+	if n.store.GetBackendConfiguration().ConfigFreeze {
+		return "the configuration freeze is enabled in the configuration ConfigMap"
+	}
+
+	if w := freeze.Active(n.cfg.ConfigFreezeWindows, t); w != nil {
+		return fmt.Sprintf("the freeze window %q is active", w)
+	}
+
+	return ""
+}
+
+// watchConfigFreezeWindows triggers a synchronization when a freeze window
+// ends, to apply the configuration changes postponed during the window
+func (n *NGINXController) watchConfigFreezeWindows(stopCh chan struct{}) {
+	wasActive := freeze.Active(n.cfg.ConfigFreezeWindows, time.Now()) != nil
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			active := freeze.Active(n.cfg.ConfigFreezeWindows, now) != nil
+			if wasActive && !active {
+				klog.Info("Configuration freeze window ended, applying postponed changes")
+				n.syncQueue.EnqueueTask(task.GetDummyObject("config-freeze-end"))
+			}
+
+			wasActive = active
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// frozenConfiguration returns the running configuration updated with the
+// changes allowed during a configuration freeze: the endpoints of the
+// existing backends and the certificates of the existing servers
+func frozenConfiguration(running, pcfg *ingress.Configuration) *ingress.Configuration {
+	frozen := *running
+	frozen.ControllerPodsCount = pcfg.ControllerPodsCount
+
+	backends := make(map[string]*ingress.Backend, len(pcfg.Backends))
+	for _, backend := range pcfg.Backends {
+		backends[backend.Name] = backend
+	}
+
+	frozen.Backends = make([]*ingress.Backend, 0, len(running.Backends))
+	for _, backend := range running.Backends {
+		updated, ok := backends[backend.Name]
+		if !ok {
+			frozen.Backends = append(frozen.Backends, backend)
+			continue
+		}
+
+		backend = backend.DeepCopy()
+		backend.Endpoints = updated.Endpoints
+		frozen.Backends = append(frozen.Backends, backend)
+	}
+
+	servers := make(map[string]*ingress.Server, len(pcfg.Servers))
+	for _, server := range pcfg.Servers {
+		servers[server.Hostname] = server
+	}
+
+	frozen.Servers = make([]*ingress.Server, 0, len(running.Servers))
+	for _, server := range running.Servers {
+		updated, ok := servers[server.Hostname]
+		if !ok || updated.SSLCert.PemFileName == "" || (&server.SSLCert).Equal(&updated.SSLCert) {
+			frozen.Servers = append(frozen.Servers, server)
+			continue
+		}
+
+		renewed := *server
+		renewed.SSLCert = updated.SSLCert
+		frozen.Servers = append(frozen.Servers, &renewed)
+	}
+
+	return &frozen
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/freeze"
+	"k8s.io/ingress-nginx/internal/ingress"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+)
+
+func TestConfigFreezeReason(t *testing.T) {
+	windows, err := freeze.ParseWindows("0 18 * * 5 6h")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	friday := time.Date(2019, time.November, 29, 20, 0, 0, 0, time.UTC)
+	thursday := time.Date(2019, time.November, 28, 20, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		toggle bool
+		t      time.Time
+		frozen bool
+	}{
+		{"no freeze", false, thursday, false},
+		{"window active", false, friday, true},
+		{"enabled in the ConfigMap", true, thursday, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := &NGINXController{
+				cfg: &Configuration{
+					ConfigFreezeWindows: windows,
+				},
+				store: fakeIngressStore{
+					configuration: ngx_config.Configuration{
+						ConfigFreeze: tc.toggle,
+					},
+				},
+			}
+
+			reason := n.configFreezeReason(tc.t)
+			if tc.frozen && reason == "" {
+				t.Errorf("expected the configuration to be frozen")
+			}
+			if !tc.frozen && reason != "" {
+				t.Errorf("expected the configuration not to be frozen but got %q", reason)
+			}
+		})
+	}
+}
+
+func TestFrozenConfiguration(t *testing.T) {
+	running := &ingress.Configuration{
+		Backends: []*ingress.Backend{
+			{
+				Name:      "default-app-80",
+				Endpoints: []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}},
+			},
+			{
+				Name:      "default-removed-80",
+				Endpoints: []ingress.Endpoint{{Address: "10.0.0.2", Port: "8080"}},
+			},
+		},
+		Servers: []*ingress.Server{
+			{
+				Hostname: "app.example.com",
+				SSLCert:  ingress.SSLCert{PemFileName: "/etc/ingress-controller/ssl/app.pem", PemSHA: "old"},
+				Locations: []*ingress.Location{
+					{Path: "/", Backend: "default-app-80"},
+				},
+			},
+			{
+				Hostname: "removed.example.com",
+				Locations: []*ingress.Location{
+					{Path: "/", Backend: "default-removed-80"},
+				},
+			},
+		},
+		BackendConfigChecksum: "1",
+	}
+
+	pcfg := &ingress.Configuration{
+		Backends: []*ingress.Backend{
+			{
+				Name:      "default-app-80",
+				Endpoints: []ingress.Endpoint{{Address: "10.0.0.3", Port: "8080"}},
+			},
+			{
+				Name:      "default-added-80",
+				Endpoints: []ingress.Endpoint{{Address: "10.0.0.4", Port: "8080"}},
+			},
+		},
+		Servers: []*ingress.Server{
+			{
+				Hostname: "app.example.com",
+				SSLCert:  ingress.SSLCert{PemFileName: "/etc/ingress-controller/ssl/app.pem", PemSHA: "new"},
+				Locations: []*ingress.Location{
+					{Path: "/api", Backend: "default-app-80"},
+				},
+			},
+			{
+				Hostname: "added.example.com",
+				Locations: []*ingress.Location{
+					{Path: "/", Backend: "default-added-80"},
+				},
+			},
+		},
+		BackendConfigChecksum: "2",
+		ControllerPodsCount:   2,
+	}
+
+	frozen := frozenConfiguration(running, pcfg)
+
+	if len(frozen.Backends) != 2 || frozen.Backends[0].Name != "default-app-80" || frozen.Backends[1].Name != "default-removed-80" {
+		t.Fatalf("expected the backends of the running configuration but got %v", frozen.Backends)
+	}
+	if frozen.Backends[0].Endpoints[0].Address != "10.0.0.3" {
+		t.Errorf("expected the updated endpoints of the backend but got %v", frozen.Backends[0].Endpoints)
+	}
+	if running.Backends[0].Endpoints[0].Address != "10.0.0.1" {
+		t.Errorf("expected the running configuration not to be modified")
+	}
+
+	if len(frozen.Servers) != 2 || frozen.Servers[0].Hostname != "app.example.com" || frozen.Servers[1].Hostname != "removed.example.com" {
+		t.Fatalf("expected the servers of the running configuration but got %v", frozen.Servers)
+	}
+	if frozen.Servers[0].SSLCert.PemSHA != "new" {
+		t.Errorf("expected the renewed certificate of the server")
+	}
+	if frozen.Servers[0].Locations[0].Path != "/" {
+		t.Errorf("expected the locations of the running configuration but got %v", frozen.Servers[0].Locations[0].Path)
+	}
+	if running.Servers[0].SSLCert.PemSHA != "old" {
+		t.Errorf("expected the running configuration not to be modified")
+	}
+
+	if frozen.BackendConfigChecksum != "1" {
+		t.Errorf("expected the checksum of the running configuration but got %v", frozen.BackendConfigChecksum)
+	}
+	if frozen.ControllerPodsCount != 2 {
+		t.Errorf("expected the updated number of controller pods but got %v", frozen.ControllerPodsCount)
+	}
+}
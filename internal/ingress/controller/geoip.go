@@ -0,0 +1,59 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/geoip"
+)
+
+// geoIPDirectory contains the GeoIP databases used by NGINX
+const geoIPDirectory = "/etc/nginx/geoip"
+
+// databaseUpdater downloads the GeoIP2 databases, see geoip.Updater
+type databaseUpdater interface {
+	Sync() (bool, error)
+	Databases() []geoip.Database
+}
+
+// updateGeoIPDatabases downloads the GeoIP2 databases published by MaxMind
+// until the channel is closed
+func (n *NGINXController) updateGeoIPDatabases(stopCh <-chan struct{}) {
+	wait.Until(n.syncGeoIPDatabases, n.cfg.GeoIPUpdateInterval, stopCh)
+}
+
+// syncGeoIPDatabases downloads the GeoIP2 databases once. The configuration
+// is synchronized when the content of a database changed, so NGINX is only
+// reloaded in that case.
+func (n *NGINXController) syncGeoIPDatabases() {
+	changed, err := n.geoIPUpdater.Sync()
+	if err != nil {
+		klog.Errorf("Error updating the GeoIP2 databases: %v", err)
+	}
+
+	for _, db := range n.geoIPUpdater.Databases() {
+		n.metricCollector.SetGeoIPDatabase(db.Edition, db.BuildDate)
+	}
+
+	if changed {
+		klog.Infof("GeoIP2 databases updated, synchronizing the configuration")
+		// the objects did not change, the synchronization must not be skipped
+		n.forceSync("geoip-update")
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/geoip"
+	"k8s.io/ingress-nginx/internal/ingress/metric"
+	"k8s.io/ingress-nginx/internal/task"
+)
+
+type fakeDatabaseUpdater struct {
+	changed bool
+}
+
+func (u fakeDatabaseUpdater) Sync() (bool, error) {
+	return u.changed, nil
+}
+
+func (fakeDatabaseUpdater) Databases() []geoip.Database {
+	return []geoip.Database{{Edition: "GeoLite2-City", BuildDate: time.Now()}}
+}
+
+func TestSyncGeoIPDatabases(t *testing.T) {
+	n := &NGINXController{
+		syncLock:        &sync.Mutex{},
+		syncQueue:       task.NewTaskQueue(func(interface{}) error { return nil }),
+		metricCollector: metric.DummyCollector{},
+		geoIPUpdater:    fakeDatabaseUpdater{},
+		syncedRevision:  5,
+	}
+
+	n.syncGeoIPDatabases()
+	if n.syncedRevision != 5 {
+		t.Errorf("expected no synchronization when the databases did not change")
+	}
+
+	// the objects did not change but NGINX must load the new databases
+	n.geoIPUpdater = fakeDatabaseUpdater{changed: true}
+	n.syncGeoIPDatabases()
+	if n.syncedRevision != 0 {
+		t.Errorf("expected the next synchronization not to be skipped")
+	}
+}
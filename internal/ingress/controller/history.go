@@ -0,0 +1,166 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/history"
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// configurationSources returns the objects used to build the configuration
+// from the Ingresses, sorted by kind, namespace and name
+func (n *NGINXController) configurationSources(ings []*ingress.Ingress) []history.Source {
+	sources := []history.Source{}
+
+	if n.cfg.ConfigMapName != "" {
+		cm, err := n.store.GetConfigMap(n.cfg.ConfigMapName)
+		if err == nil {
+			sources = append(sources, history.Source{
+				Kind:            "ConfigMap",
+				Namespace:       cm.Namespace,
+				Name:            cm.Name,
+				ResourceVersion: cm.ResourceVersion,
+			})
+		}
+	}
+
+	for _, ing := range ings {
+		sources = append(sources, history.Source{
+			Kind:            "Ingress",
+			Namespace:       ing.Namespace,
+			Name:            ing.Name,
+			ResourceVersion: ing.ResourceVersion,
+		})
+	}
+
+	sort.SliceStable(sources, func(i, j int) bool {
+		if sources[i].Kind != sources[j].Kind {
+			return sources[i].Kind < sources[j].Kind
+		}
+		if sources[i].Namespace != sources[j].Namespace {
+			return sources[i].Namespace < sources[j].Namespace
+		}
+		return sources[i].Name < sources[j].Name
+	})
+
+	return sources
+}
+
+// sourcesEqual returns true if both lists contain the same objects with
+// the same resource versions
+func sourcesEqual(s1, s2 []history.Source) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// saveRevision stores the applied configuration in the configuration history
+func (n *NGINXController) saveRevision(content []byte, pcfg *ingress.Configuration, sources []history.Source, rollbackOf int) (*history.Revision, error) {
+	if n.history == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(pcfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.history.Save(history.Revision{
+		Checksum:   pcfg.ConfigurationChecksum,
+		RollbackOf: rollbackOf,
+		Sources:    sources,
+	}, content, data)
+}
+
+// Rollback applies again a revision of the configuration history, or
+// the previous one if the revision is zero. The endpoints of the backends
+// and the certificates of the servers are updated with the current ones.
+// The rolled back configuration is kept until the source objects change.
+func (n *NGINXController) Rollback(revision int) (*history.Revision, error) {
+	if n.history == nil {
+		return nil, fmt.Errorf("the configuration history is disabled")
+	}
+
+	n.syncLock.Lock()
+	defer n.syncLock.Unlock()
+
+	var err error
+	if revision == 0 {
+		revision, err = n.history.Previous()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rev, content, data, err := n.history.Get(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &ingress.Configuration{}
+	err = json.Unmarshal(data, stored)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration in revision %v: %v", revision, err)
+	}
+
+	ings := n.store.ListIngresses(nil)
+	_, _, current := n.getConfiguration(ings)
+	pcfg := frozenConfiguration(stored, current)
+
+	err = n.testTemplate(content)
+	if err != nil {
+		return nil, err
+	}
+
+	err = n.reload(content)
+	if err != nil {
+		n.metricCollector.IncReloadErrorCount()
+		return nil, err
+	}
+
+	n.metricCollector.IncReloadCount()
+
+	err = configureDynamically(n.fileSystem, pcfg, n.store.GetBackendConfiguration().EnableBackendMetadata)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error reconfiguring NGINX: %v", err)
+	}
+
+	n.setRunningConfig(pcfg)
+	n.rollbackSources = n.configurationSources(ings)
+	n.syncedRevision = 0
+
+	n.recordAudit(ings, pcfg, true, revision)
+	n.publishAppliedConfiguration(ings, pcfg, revision)
+
+	klog.Infof("Configuration rolled back to revision %v", revision)
+
+	return n.saveRevision(content, pcfg, rev.Sources, revision)
+}
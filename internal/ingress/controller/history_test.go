@@ -0,0 +1,47 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/ingress-nginx/internal/history"
+)
+
+func TestSourcesEqual(t *testing.T) {
+	s1 := []history.Source{
+		{Kind: "ConfigMap", Namespace: "ingress-nginx", Name: "config", ResourceVersion: "1"},
+		{Kind: "Ingress", Namespace: "default", Name: "foo", ResourceVersion: "2"},
+	}
+
+	testCases := []struct {
+		title    string
+		s2       []history.Source
+		expected bool
+	}{
+		{"same objects", []history.Source{s1[0], s1[1]}, true},
+		{"missing object", []history.Source{s1[0]}, false},
+		{"new resource version", []history.Source{s1[0], {Kind: "Ingress", Namespace: "default", Name: "foo", ResourceVersion: "3"}}, false},
+		{"no objects", nil, false},
+	}
+
+	for _, tc := range testCases {
+		if result := sourcesEqual(s1, tc.s2); result != tc.expected {
+			t.Errorf("%v: expected %v but returned %v", tc.title, tc.expected, result)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/hostregex"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ing_errors "k8s.io/ingress-nginx/internal/ingress/errors"
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+// configureHostRegex adds the regular expression of the host-regex
+// annotation to the server of the first rule with a host matched by it.
+// regexes contains the host of the server using each regular expression.
+func (n *NGINXController) configureHostRegex(ing *ingress.Ingress, servers map[string]*ingress.Server, regexes map[string]string) {
+	config := ing.ParsedAnnotations.HostRegex
+	if config.Regex == "" {
+		return
+	}
+
+	host := hostRegexServer(config, ing.Spec.Rules)
+	if host == "" {
+		n.rejectHostRegex(ing, config.Regex, "the regular expression does not match the host of any rule")
+		return
+	}
+
+	if other, ok := regexes[config.Regex]; ok && other != host {
+		n.rejectHostRegex(ing, config.Regex, fmt.Sprintf("the regular expression is already configured for server %q", other))
+		return
+	}
+
+	server := servers[host]
+	if server.HostRegex.Regex != "" && server.HostRegex.Regex != config.Regex {
+		klog.Warningf("Host regex already configured for server %q, skipping (Ingress %q)", host, k8s.MetaNamespaceKey(ing))
+		return
+	}
+
+	regexes[config.Regex] = host
+	server.HostRegex = config
+}
+
+// hostRegexServer returns the host of the first rule matched by the regular
+// expression or an empty string if there is no such rule
+func hostRegexServer(config hostregex.Config, rules []networking.IngressRule) string {
+	for _, rule := range rules {
+		if rule.Host != "" && config.Matches(rule.Host) {
+			return rule.Host
+		}
+	}
+
+	return ""
+}
+
+// rejectHostRegex logs and records an event in the Ingress defining a host
+// regex that cannot be used
+func (n *NGINXController) rejectHostRegex(ing *ingress.Ingress, regex, reason string) {
+	klog.Warningf("Ignoring host regex %q of Ingress %q: %v", regex, k8s.MetaNamespaceKey(ing), reason)
+
+	n.recorder.Eventf(&ing.Ingress, apiv1.EventTypeWarning, "HostRegexConflict",
+		"Ignoring host regex %q: %v", regex, reason)
+}
+
+// checkHostRegex returns an error if the host-regex annotation of the
+// Ingress is invalid, does not match the host of any of its rules or is
+// already used for another host by one of the Ingresses
+func checkHostRegex(ing *networking.Ingress, ingresses []*ingress.Ingress) error {
+	val, err := parser.GetStringAnnotation("host-regex", ing)
+	if ing_errors.IsMissingAnnotations(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	config, err := hostregex.ParseRegex(val)
+	if err != nil {
+		return fmt.Errorf("invalid host-regex annotation: %v", err)
+	}
+
+	_, err = parser.GetIntAnnotation("host-regex-priority", ing)
+	if err != nil && !ing_errors.IsMissingAnnotations(err) {
+		return fmt.Errorf("invalid host-regex-priority annotation: %v", err)
+	}
+
+	host := hostRegexServer(*config, ing.Spec.Rules)
+	if host == "" {
+		return fmt.Errorf("the host-regex %q does not match the host of any rule of the Ingress", config.Regex)
+	}
+
+	for _, other := range ingresses {
+		if other.ParsedAnnotations == nil || other.ParsedAnnotations.HostRegex.Regex != config.Regex {
+			continue
+		}
+
+		otherHost := hostRegexServer(other.ParsedAnnotations.HostRegex, other.Spec.Rules)
+		if otherHost != "" && otherHost != host {
+			return fmt.Errorf("the host-regex %q is already configured for host %q by Ingress %v", config.Regex, otherHost, k8s.MetaNamespaceKey(other))
+		}
+	}
+
+	return nil
+}
+
+// serverLess defines the order of the servers in the configuration. NGINX
+// evaluates the regular expressions in the order of appearance, so the
+// servers with a host regex are sorted after the rest by priority and then
+// by hostname.
+func serverLess(s1, s2 *ingress.Server) bool {
+	r1 := s1.HostRegex.Regex != ""
+	r2 := s2.HostRegex.Regex != ""
+	if r1 != r2 {
+		return r2
+	}
+
+	if r1 && s1.HostRegex.Priority != s2.HostRegex.Priority {
+		return s1.HostRegex.Priority > s2.HostRegex.Priority
+	}
+
+	return s1.Hostname < s2.Hostname
+}
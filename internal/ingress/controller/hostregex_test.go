@@ -0,0 +1,148 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/hostregex"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+)
+
+func newHostRegexIngress(name, host, regex string, priority int) *ingress.Ingress {
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   "example",
+				Annotations: map[string]string{},
+			},
+			Spec: networking.IngressSpec{
+				Rules: []networking.IngressRule{
+					{
+						Host: host,
+						IngressRuleValue: networking.IngressRuleValue{
+							HTTP: &networking.HTTPIngressRuleValue{
+								Paths: []networking.HTTPIngressPath{
+									{
+										Path: "/",
+										Backend: networking.IngressBackend{
+											ServiceName: "http-svc",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		ParsedAnnotations: &annotations.Ingress{},
+	}
+
+	if regex != "" {
+		ing.Annotations[parser.GetAnnotationWithPrefix("host-regex")] = regex
+		ing.ParsedAnnotations.HostRegex = hostregex.Config{
+			Regex:    regex,
+			Priority: priority,
+		}
+	}
+
+	return ing
+}
+
+func TestGetBackendServersHostRegex(t *testing.T) {
+	ctl := newNGINXController(t)
+
+	ingresses := []*ingress.Ingress{
+		newHostRegexIngress("exact", "www.example.com", "", 0),
+		newHostRegexIngress("api", "api-demo.example.com", `^api-(?<tenant>.+)\.example\.com$`, 0),
+		newHostRegexIngress("admin", "admin-demo.example.com", `^admin-(?<tenant>.+)\.example\.com$`, 10),
+		newHostRegexIngress("mismatch", "foo.example.com", `^bar\.example\.com$`, 0),
+		newHostRegexIngress("duplicate", "api-other.example.com", `^api-(?<tenant>.+)\.example\.com$`, 0),
+	}
+
+	_, servers := ctl.getBackendServers(ingresses)
+
+	expected := []struct {
+		hostname string
+		regex    string
+	}{
+		{"_", ""},
+		{"api-other.example.com", ""},
+		{"foo.example.com", ""},
+		{"www.example.com", ""},
+		{"admin-demo.example.com", `^admin-(?<tenant>.+)\.example\.com$`},
+		{"api-demo.example.com", `^api-(?<tenant>.+)\.example\.com$`},
+	}
+
+	if len(servers) != len(expected) {
+		t.Fatalf("expected %v servers but %v were returned", len(expected), len(servers))
+	}
+
+	for i, e := range expected {
+		if servers[i].Hostname != e.hostname {
+			t.Errorf("expected server %v to be %q but %q was returned", i, e.hostname, servers[i].Hostname)
+		}
+		if servers[i].HostRegex.Regex != e.regex {
+			t.Errorf("expected regex %q for server %q but %q was returned", e.regex, servers[i].Hostname, servers[i].HostRegex.Regex)
+		}
+	}
+}
+
+func TestCheckHostRegex(t *testing.T) {
+	existing := []*ingress.Ingress{
+		newHostRegexIngress("api", "api-demo.example.com", `^api-(?<tenant>.+)\.example\.com$`, 0),
+	}
+
+	testCases := []struct {
+		name      string
+		ing       *ingress.Ingress
+		expectErr bool
+	}{
+		{"without annotation", newHostRegexIngress("app", "app.example.com", "", 0), false},
+		{"valid regex", newHostRegexIngress("app", "app-demo.example.com", `~^app-(?<tenant>.+)\.example\.com$`, 0), false},
+		{"same regex and host", newHostRegexIngress("app", "api-demo.example.com", `^api-(?<tenant>.+)\.example\.com$`, 0), false},
+		{"regex used by another host", newHostRegexIngress("app", "api-other.example.com", `^api-(?<tenant>.+)\.example\.com$`, 0), true},
+		{"host not matched", newHostRegexIngress("app", "app.example.com", `^api\.example\.com$`, 0), true},
+		{"invalid regex", newHostRegexIngress("app", "app.example.com", `^app(\.example\.com$`, 0), true},
+		{"reserved capture", newHostRegexIngress("app", "app.example.com", `^(?<namespace>.+)\.example\.com$`, 0), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkHostRegex(&tc.ing.Ingress, existing)
+			if tc.expectErr && err == nil {
+				t.Errorf("expected an error but none was returned")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	ing := newHostRegexIngress("app", "app-demo.example.com", `^app-(?<tenant>.+)\.example\.com$`, 0)
+	ing.Annotations[parser.GetAnnotationWithPrefix("host-regex-priority")] = "high"
+	if err := checkHostRegex(&ing.Ingress, existing); err == nil {
+		t.Errorf("expected an error with an invalid priority")
+	}
+}
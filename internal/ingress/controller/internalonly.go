@@ -0,0 +1,166 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	networking "k8s.io/api/networking/v1beta1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+// internalListenerEnabled indicates if the controller has an internal listener,
+// the ports serving the hosts of the internal Ingresses
+func (n *NGINXController) internalListenerEnabled() bool {
+	return n.cfg.ListenPorts.InternalHTTP != 0 || n.cfg.ListenPorts.InternalHTTPS != 0
+}
+
+// filterInternalIngresses removes from the internal Ingresses what would be
+// exposed on the public listeners: the rules without host, served by the
+// catch-all server, and the Ingresses when there is no internal listener.
+func (n *NGINXController) filterInternalIngresses(ingresses []*ingress.Ingress) []*ingress.Ingress {
+	filtered := make([]*ingress.Ingress, 0, len(ingresses))
+	for _, ing := range ingresses {
+		if ing.ParsedAnnotations == nil || !ing.ParsedAnnotations.Internal {
+			filtered = append(filtered, ing)
+			continue
+		}
+
+		ingKey := k8s.MetaNamespaceKey(ing)
+		if !n.internalListenerEnabled() {
+			klog.Warningf("Ingress %q is internal but the controller has no internal listener (--internal-http-port or --internal-https-port), ignoring", ingKey)
+			continue
+		}
+
+		var rules []networking.IngressRule
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host == "" {
+				klog.Warningf("Ignoring a rule without host of Ingress %q: the catch-all server of an internal Ingress would be public", ingKey)
+				continue
+			}
+			rules = append(rules, rule)
+		}
+
+		// the backend of an Ingress without rules configures the catch-all server
+		if len(rules) == 0 {
+			klog.Warningf("Ingress %q is internal but does not define any host, ignoring", ingKey)
+			continue
+		}
+
+		if len(rules) != len(ing.Spec.Rules) {
+			internalIng := *ing
+			internalIng.Spec.Rules = rules
+			ing = &internalIng
+		}
+
+		filtered = append(filtered, ing)
+	}
+
+	return filtered
+}
+
+// configureInternalServers marks the servers of the hosts defined by an
+// internal Ingress. A host is internal as soon as one of its Ingresses is,
+// the paths of the other Ingresses of the host are not exposed publicly.
+func (n *NGINXController) configureInternalServers(data []*ingress.Ingress, servers map[string]*ingress.Server) {
+	for _, ing := range data {
+		if !ing.ParsedAnnotations.Internal {
+			continue
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			if server, ok := servers[rule.Host]; ok {
+				server.Internal = true
+			}
+		}
+	}
+
+	for _, ing := range data {
+		if ing.ParsedAnnotations.Internal {
+			continue
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			if server, ok := servers[rule.Host]; ok && server.Internal {
+				klog.Warningf("Ingress %q is not internal but host %q is, its paths are only exposed on the internal listener",
+					k8s.MetaNamespaceKey(ing), rule.Host)
+			}
+		}
+	}
+
+	for _, server := range servers {
+		// the TLS connections are passed through on the public HTTPS port
+		if server.Internal && server.SSLPassthrough {
+			klog.Warningf("Server %q is internal, disabling SSL passthrough", server.Hostname)
+			server.SSLPassthrough = false
+		}
+	}
+}
+
+// checkInternal validates an Ingress cannot expose an internal host on a
+// public listener, or change the exposure of a host defined by other Ingresses
+func checkInternal(ing *networking.Ingress, parsed *annotations.Ingress, ingresses []*ingress.Ingress, enabled bool) error {
+	if parsed.Internal {
+		if !enabled {
+			return fmt.Errorf("the internal annotation requires an internal listener, the controller does not define --internal-http-port or --internal-https-port")
+		}
+
+		if len(ing.Spec.Rules) == 0 {
+			return fmt.Errorf("an internal Ingress must define the hosts of its rules, the catch-all server is public")
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host == "" {
+				return fmt.Errorf("an internal Ingress must define the hosts of its rules, the catch-all server is public")
+			}
+		}
+
+		if parsed.SSLPassthrough {
+			return fmt.Errorf("an internal Ingress cannot use SSL passthrough, the TLS connections are passed through on the public HTTPS port")
+		}
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		if rule.Host == "" {
+			continue
+		}
+
+		for _, other := range ingresses {
+			internal := other.ParsedAnnotations != nil && other.ParsedAnnotations.Internal
+			if internal == parsed.Internal {
+				continue
+			}
+
+			for _, otherRule := range other.Spec.Rules {
+				if otherRule.Host != rule.Host {
+					continue
+				}
+
+				if internal {
+					return fmt.Errorf("host %q is internal, defined by the internal Ingress %v", rule.Host, k8s.MetaNamespaceKey(other))
+				}
+				return fmt.Errorf("host %q is public, defined by the Ingress %v without the internal annotation", rule.Host, k8s.MetaNamespaceKey(other))
+			}
+		}
+	}
+
+	return nil
+}
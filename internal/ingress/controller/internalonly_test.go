@@ -0,0 +1,177 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+)
+
+func newInternalIngress(name string, internal bool, hosts ...string) *ingress.Ingress {
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "example",
+			},
+		},
+		ParsedAnnotations: &annotations.Ingress{
+			Internal: internal,
+		},
+	}
+
+	for _, host := range hosts {
+		ing.Spec.Rules = append(ing.Spec.Rules, networking.IngressRule{
+			Host: host,
+			IngressRuleValue: networking.IngressRuleValue{
+				HTTP: &networking.HTTPIngressRuleValue{
+					Paths: []networking.HTTPIngressPath{
+						{
+							Path: "/",
+							Backend: networking.IngressBackend{
+								ServiceName: "http-svc",
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+
+	return ing
+}
+
+func TestFilterInternalIngresses(t *testing.T) {
+	ctl := newNGINXController(t)
+
+	ingresses := []*ingress.Ingress{
+		newInternalIngress("public", false, "www.example.com", ""),
+		newInternalIngress("admin", true, "admin.example.com", ""),
+		newInternalIngress("catch-all", true, ""),
+		newInternalIngress("no-rules", true),
+	}
+
+	filtered := ctl.filterInternalIngresses(ingresses)
+	if len(filtered) != 1 || filtered[0].Name != "public" {
+		t.Errorf("expected only the public Ingress without internal listener but %v were returned", len(filtered))
+	}
+
+	ctl.cfg.ListenPorts.InternalHTTP = 8080
+
+	filtered = ctl.filterInternalIngresses(ingresses)
+	if len(filtered) != 2 {
+		t.Fatalf("expected 2 Ingresses but %v were returned", len(filtered))
+	}
+
+	if len(filtered[0].Spec.Rules) != 2 {
+		t.Errorf("expected the rules of the public Ingress to be kept")
+	}
+
+	if len(filtered[1].Spec.Rules) != 1 || filtered[1].Spec.Rules[0].Host != "admin.example.com" {
+		t.Errorf("expected the rule without host of the internal Ingress to be removed")
+	}
+
+	if len(ingresses[1].Spec.Rules) != 2 {
+		t.Errorf("expected the original internal Ingress to be left unchanged")
+	}
+}
+
+func TestGetBackendServersInternal(t *testing.T) {
+	ctl := newNGINXController(t)
+	ctl.cfg.ListenPorts.InternalHTTP = 8080
+
+	passthrough := newInternalIngress("passthrough", true, "passthrough.example.com")
+	passthrough.ParsedAnnotations.SSLPassthrough = true
+
+	ingresses := []*ingress.Ingress{
+		newInternalIngress("public", false, "www.example.com", "admin.example.com"),
+		newInternalIngress("admin", true, "admin.example.com"),
+		passthrough,
+	}
+
+	_, servers := ctl.getBackendServers(ingresses)
+
+	expected := map[string]bool{
+		"_":                       false,
+		"admin.example.com":       true,
+		"passthrough.example.com": true,
+		"www.example.com":         false,
+	}
+
+	if len(servers) != len(expected) {
+		t.Fatalf("expected %v servers but %v were returned", len(expected), len(servers))
+	}
+
+	for _, server := range servers {
+		internal, ok := expected[server.Hostname]
+		if !ok {
+			t.Errorf("unexpected server %q", server.Hostname)
+			continue
+		}
+		if server.Internal != internal {
+			t.Errorf("expected server %q to be internal: %v", server.Hostname, internal)
+		}
+		if server.SSLPassthrough {
+			t.Errorf("expected SSL passthrough to be disabled for server %q", server.Hostname)
+		}
+	}
+}
+
+func TestCheckInternal(t *testing.T) {
+	existing := []*ingress.Ingress{
+		newInternalIngress("admin", true, "admin.example.com"),
+		newInternalIngress("public", false, "www.example.com"),
+	}
+
+	passthrough := newInternalIngress("app", true, "app.example.com")
+	passthrough.ParsedAnnotations.SSLPassthrough = true
+
+	testCases := []struct {
+		name      string
+		ing       *ingress.Ingress
+		enabled   bool
+		expectErr bool
+	}{
+		{"public", newInternalIngress("app", false, "app.example.com"), false, false},
+		{"public without host", newInternalIngress("app", false, ""), false, false},
+		{"internal", newInternalIngress("app", true, "app.example.com"), true, false},
+		{"internal host of another internal Ingress", newInternalIngress("app", true, "admin.example.com"), true, false},
+		{"internal without listener", newInternalIngress("app", true, "app.example.com"), false, true},
+		{"internal without rules", newInternalIngress("app", true), true, true},
+		{"internal without host", newInternalIngress("app", true, "app.example.com", ""), true, true},
+		{"internal with SSL passthrough", passthrough, true, true},
+		{"internal host of a public Ingress", newInternalIngress("app", true, "www.example.com"), true, true},
+		{"public host of an internal Ingress", newInternalIngress("app", false, "admin.example.com"), true, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkInternal(&tc.ing.Ingress, tc.ing.ParsedAnnotations, existing, tc.enabled)
+			if tc.expectErr && err == nil {
+				t.Errorf("expected an error but none was returned")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"k8s.io/ingress-nginx/internal/inventory"
+)
+
+// updateInventory collects the modules, the template and the Lua code used
+// by NGINX after a reload
+func (n *NGINXController) updateInventory() {
+	components := inventory.Collect(cfgPath, tmplPath, luaPath)
+
+	n.inventoryLock.Lock()
+	n.inventory = components
+	n.inventoryLock.Unlock()
+
+	n.metricCollector.SetInventory(components)
+}
+
+// Inventory returns the modules, the template and the Lua code used by NGINX
+// since the last reload
+func (n *NGINXController) Inventory() []inventory.Component {
+	n.inventoryLock.RLock()
+	defer n.inventoryLock.RUnlock()
+
+	return n.inventory
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+)
+
+// namespaceServers returns, by namespace, the servers containing only the
+// locations of the Ingresses of the namespace. The locations not created by
+// an Ingress (i.e. the default backend) are kept in all the namespaces.
+func namespaceServers(servers []*ingress.Server) map[string][]*ingress.Server {
+	namespaces := map[string][]*ingress.Server{}
+
+	for _, server := range servers {
+		owners := map[string]bool{}
+		for _, location := range server.Locations {
+			if location.Ingress != nil {
+				owners[location.Ingress.Namespace] = true
+			}
+		}
+
+		for namespace := range owners {
+			srv := *server
+			srv.Locations = []*ingress.Location{}
+			for _, location := range server.Locations {
+				if location.Ingress == nil || location.Ingress.Namespace == namespace {
+					srv.Locations = append(srv.Locations, location)
+				}
+			}
+
+			namespaces[namespace] = append(namespaces[namespace], &srv)
+		}
+	}
+
+	return namespaces
+}
+
+// namespaceConfigKey returns the key of a server block in the ConfigMap.
+// The wildcard of the hostname is not a valid character in a key.
+func namespaceConfigKey(hostname string) string {
+	return strings.Replace(hostname, "*", "wildcard", 1) + ".conf"
+}
+
+// publishNamespaceConfigs writes the server blocks rendered for the Ingresses
+// of each namespace in a ConfigMap of the namespace, so the tenants can review
+// the configuration produced by their annotations. Only the leader writes the
+// ConfigMaps and they are deleted when a namespace does not contain Ingresses.
+func (n *NGINXController) publishNamespaceConfigs(tc ngx_config.TemplateConfig) {
+	if n.cfg.NamespaceConfigMap == "" || atomic.LoadInt32(&n.isLeader) == 0 {
+		return
+	}
+
+	published := map[string]map[string]string{}
+	for namespace, servers := range namespaceServers(tc.Servers) {
+		ntc := tc
+		ntc.Servers = servers
+		ntc.RedirectServers = buildRedirects(servers)
+
+		blocks, err := n.t.WriteServers(ntc)
+		if err != nil {
+			klog.Warningf("Unexpected error rendering the server blocks of namespace %v: %v", namespace, err)
+			published[namespace] = n.namespaceConfigs[namespace]
+			continue
+		}
+
+		data := map[string]string{}
+		for hostname, block := range blocks {
+			data[namespaceConfigKey(hostname)] = block
+		}
+
+		if !reflect.DeepEqual(data, n.namespaceConfigs[namespace]) {
+			err = n.writeNamespaceConfig(namespace, data)
+			if err != nil {
+				klog.Warningf("Unexpected error writing ConfigMap %v/%v: %v", namespace, n.cfg.NamespaceConfigMap, err)
+				// an empty entry forces a new attempt in the next synchronization
+				data = nil
+			}
+		}
+
+		published[namespace] = data
+	}
+
+	for namespace, data := range n.namespaceConfigs {
+		if _, ok := published[namespace]; ok {
+			continue
+		}
+
+		err := n.cfg.Client.CoreV1().ConfigMaps(namespace).Delete(n.cfg.NamespaceConfigMap, &metav1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			klog.Warningf("Unexpected error deleting ConfigMap %v/%v: %v", namespace, n.cfg.NamespaceConfigMap, err)
+			published[namespace] = data
+		}
+	}
+
+	n.namespaceConfigs = published
+}
+
+// writeNamespaceConfig replaces the data of the namespace configuration
+// ConfigMap, creating it if it does not exist
+func (n *NGINXController) writeNamespaceConfig(namespace string, data map[string]string) error {
+	return updateConfigMap(n.cfg.Client, namespace, n.cfg.NamespaceConfigMap, func(cm *apiv1.ConfigMap) {
+		cm.Data = data
+	})
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+)
+
+func namespaceConfigIngress(namespace string) *ingress.Ingress {
+	return &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "ingress",
+				Namespace: namespace,
+			},
+		},
+	}
+}
+
+func namespaceConfigServers() []*ingress.Server {
+	return []*ingress.Server{
+		{
+			Hostname: "_",
+			Locations: []*ingress.Location{
+				{Path: "/", IsDefBackend: true},
+			},
+		},
+		{
+			Hostname: "example.com",
+			Locations: []*ingress.Location{
+				{Path: "/api", Ingress: namespaceConfigIngress("team-a")},
+				{Path: "/shop", Ingress: namespaceConfigIngress("team-b")},
+				{Path: "/", IsDefBackend: true},
+			},
+		},
+		{
+			Hostname: "*.team-a.com",
+			Locations: []*ingress.Location{
+				{Path: "/", Ingress: namespaceConfigIngress("team-a")},
+			},
+		},
+	}
+}
+
+func TestNamespaceServers(t *testing.T) {
+	namespaces := namespaceServers(namespaceConfigServers())
+
+	paths := map[string]map[string][]string{}
+	for namespace, servers := range namespaces {
+		paths[namespace] = map[string][]string{}
+		for _, server := range servers {
+			for _, location := range server.Locations {
+				paths[namespace][server.Hostname] = append(paths[namespace][server.Hostname], location.Path)
+			}
+		}
+	}
+
+	expected := map[string]map[string][]string{
+		"team-a": {
+			"example.com":  {"/api", "/"},
+			"*.team-a.com": {"/"},
+		},
+		"team-b": {
+			"example.com": {"/shop", "/"},
+		},
+	}
+
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected %v but returned %v", expected, paths)
+	}
+}
+
+func TestPublishNamespaceConfigs(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	n := &NGINXController{
+		cfg: &Configuration{
+			Client:             client,
+			NamespaceConfigMap: "ingress-nginx-config",
+		},
+		t: fakeTemplate{},
+	}
+
+	tc := ngx_config.TemplateConfig{Servers: namespaceConfigServers()}
+
+	n.publishNamespaceConfigs(tc)
+	if len(n.namespaceConfigs) != 0 {
+		t.Fatalf("expected the ConfigMaps to only be written by the leader")
+	}
+
+	n.isLeader = 1
+	n.publishNamespaceConfigs(tc)
+
+	expected := map[string]map[string]string{
+		"team-a": {
+			"example.com.conf":         "/api,/",
+			"wildcard.team-a.com.conf": "/",
+		},
+		"team-b": {
+			"example.com.conf": "/shop,/",
+		},
+	}
+
+	for namespace, data := range expected {
+		cm, err := client.CoreV1().ConfigMaps(namespace).Get("ingress-nginx-config", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error reading the ConfigMap of namespace %v: %v", namespace, err)
+		}
+
+		if !reflect.DeepEqual(cm.Data, data) {
+			t.Errorf("expected %v in the ConfigMap of namespace %v but returned %v", data, namespace, cm.Data)
+		}
+	}
+
+	tc.Servers = tc.Servers[:2]
+	tc.Servers[1].Locations = tc.Servers[1].Locations[:1]
+	n.publishNamespaceConfigs(tc)
+
+	_, err := client.CoreV1().ConfigMaps("team-b").Get("ingress-nginx-config", metav1.GetOptions{})
+	if err == nil {
+		t.Errorf("expected the ConfigMap of namespace team-b to be deleted")
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("team-a").Get("ingress-nginx-config", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error reading the ConfigMap of namespace team-a: %v", err)
+	}
+
+	data := map[string]string{"example.com.conf": "/api"}
+	if !reflect.DeepEqual(cm.Data, data) {
+		t.Errorf("expected %v in the ConfigMap of namespace team-a but returned %v", data, cm.Data)
+	}
+}
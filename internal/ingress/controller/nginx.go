@@ -18,6 +18,9 @@ package controller
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -42,13 +45,22 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes/scheme"
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/flowcontrol"
 	"k8s.io/klog"
 	"k8s.io/kubernetes/pkg/util/filesystem"
 
+	"k8s.io/ingress-nginx/internal/admission/certificate"
 	adm_controler "k8s.io/ingress-nginx/internal/admission/controller"
+	"k8s.io/ingress-nginx/internal/audit"
+	"k8s.io/ingress-nginx/internal/configapi"
+	"k8s.io/ingress-nginx/internal/dashboard"
+	"k8s.io/ingress-nginx/internal/debug"
+	"k8s.io/ingress-nginx/internal/events"
 	"k8s.io/ingress-nginx/internal/file"
+	"k8s.io/ingress-nginx/internal/geoip"
+	"k8s.io/ingress-nginx/internal/history"
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
@@ -57,12 +69,19 @@ import (
 	ngx_template "k8s.io/ingress-nginx/internal/ingress/controller/template"
 	"k8s.io/ingress-nginx/internal/ingress/metric"
 	"k8s.io/ingress-nginx/internal/ingress/status"
+	"k8s.io/ingress-nginx/internal/inventory"
 	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/logging"
+	"k8s.io/ingress-nginx/internal/modsecurity"
 	ing_net "k8s.io/ingress-nginx/internal/net"
 	"k8s.io/ingress-nginx/internal/net/dns"
+	"k8s.io/ingress-nginx/internal/net/spiffe"
 	"k8s.io/ingress-nginx/internal/net/ssl"
 	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/profiling"
+	"k8s.io/ingress-nginx/internal/syslog"
 	"k8s.io/ingress-nginx/internal/task"
+	"k8s.io/ingress-nginx/internal/traffic"
 	"k8s.io/ingress-nginx/internal/watch"
 )
 
@@ -72,6 +91,11 @@ const (
 
 var (
 	tmplPath = "/etc/nginx/template/nginx.tmpl"
+
+	// the files read by NGINX with the configuration, a change requires a
+	// reload even if the configuration is identical
+	includedDirectories = []string{file.DefaultSSLDirectory, file.AuthDirectory, geoIPDirectory}
+	includedFiles       = []string{"/etc/nginx/opentracing.json"}
 )
 
 // NewNGINXController creates a new NGINX Ingress controller.
@@ -94,21 +118,40 @@ func NewNGINXController(config *Configuration, mc metric.Collector, fs file.File
 		cfg:             config,
 		syncRateLimiter: flowcontrol.NewTokenBucketRateLimiter(config.SyncRateLimit, 1),
 
-		recorder: eventBroadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{
+		recorder: events.NewAggregator(eventBroadcaster.NewRecorder(scheme.Scheme, apiv1.EventSource{
 			Component: "nginx-ingress-controller",
-		}),
+		})),
 
 		stopCh:   make(chan struct{}),
 		updateCh: channels.NewRingChannel(1024),
+		ngxErrCh: make(chan error),
 
 		stopLock: &sync.Mutex{},
+		syncLock: &sync.Mutex{},
+
+		syslogRelays: syslog.NewRelays(),
+		dnsProxy:     dns.NewProxy(fmt.Sprintf("127.0.0.1:%v", config.ListenPorts.DNS), h),
+
+		modsecurityAuditor: modsecurity.NewAuditor(modsecurity.AuditLog, mc),
 
 		fileSystem: fs,
 
-		runningConfig: new(ingress.Configuration),
+		runningConfig:     new(ingress.Configuration),
+		runningConfigLock: &sync.RWMutex{},
+
+		inventory:     []inventory.Component{},
+		inventoryLock: &sync.RWMutex{},
+
+		warmup: newWarmup(),
+
+		debugHosts:     map[string]time.Time{},
+		debugHostsLock: &sync.Mutex{},
 
 		Proxy: &TCPProxy{},
 
+		tlsFingerprints:      &tlsFingerprintCounter{},
+		tlsFingerprintsQueue: make(chan tlsFingerprintData, tlsFingerprintsQueueSize),
+
 		metricCollector: mc,
 
 		command: NewNginxCommand(),
@@ -118,16 +161,76 @@ func NewNGINXController(config *Configuration, mc metric.Collector, fs file.File
 		n.validationWebhookServer = &http.Server{
 			Addr:      config.ValidationWebhook,
 			Handler:   adm_controler.NewAdmissionControllerServer(&adm_controler.IngressAdmission{Checker: n}),
-			TLSConfig: ssl.NewTLSListener(n.cfg.ValidationWebhookCertPath, n.cfg.ValidationWebhookKeyPath).TLSConfig(),
+			TLSConfig: n.validationWebhookTLSConfig(),
+		}
+	}
+
+	if n.cfg.TrafficAPIAddress != "" {
+		n.trafficServer = &http.Server{
+			Handler: traffic.NewServer(n.cfg.TrafficAPIToken, n),
+		}
+	}
+
+	if n.cfg.ConfigurationAPIAddress != "" {
+		n.configurationServer = &http.Server{
+			Handler: configapi.NewServer(n.cfg.ConfigurationAPIToken, n),
+		}
+	}
+
+	if n.cfg.DashboardAddress != "" {
+		n.dashboardServer = &http.Server{
+			Handler: dashboard.NewServer(n.cfg.DashboardCredentials, n),
+		}
+	}
+
+	if n.cfg.ConfigHistorySize > 0 {
+		hs, err := history.NewStore(file.HistoryDirectory, n.cfg.ConfigHistorySize)
+		if err != nil {
+			klog.Warningf("Unexpected error creating the configuration history, rollbacks are disabled: %v", err)
+		} else {
+			n.history = hs
+			n.historyServer = &http.Server{
+				Handler: history.NewServer(n.history, n),
+			}
 		}
 	}
 
+	if n.cfg.ContinuousProfiling != nil {
+		n.profiles = profiling.NewRecorder(*n.cfg.ContinuousProfiling)
+	}
+
+	if n.cfg.GeoIPLicenseSecret != "" {
+		n.geoIPUpdater, err = geoip.NewUpdater(config.Client, n.cfg.GeoIPLicenseSecret, n.cfg.GeoIPEditions, geoIPDirectory)
+		if err != nil {
+			klog.Fatalf("Error creating the GeoIP2 databases updater: %v", err)
+		}
+	}
+
+	n.debugServer = &http.Server{
+		Handler: debug.NewServer(n, n, n.profiles),
+	}
+
 	pod, err := k8s.GetPodDetails(config.Client)
 	if err != nil {
 		klog.Fatalf("unexpected error obtaining pod information: %v", err)
 	}
 	n.podInfo = pod
 
+	if n.cfg.AuditSize > 0 {
+		sinks := []audit.Sink{audit.FileSink{Path: audit.File}}
+		if n.cfg.AuditConfigMap != "" {
+			ns, name, _ := k8s.ParseNameNS(n.cfg.AuditConfigMap)
+			sinks = append(sinks, audit.NewAsyncSink(audit.ConfigMapSink{
+				Client:    config.Client,
+				Namespace: ns,
+				Name:      name,
+				Key:       pod.Name,
+			}, n.stopCh))
+		}
+
+		n.audit = audit.NewLog(n.cfg.AuditSize, sinks...)
+	}
+
 	n.store = store.New(
 		config.Namespace,
 		config.ConfigMapName,
@@ -139,9 +242,16 @@ func NewNGINXController(config *Configuration, mc metric.Collector, fs file.File
 		fs,
 		n.updateCh,
 		pod,
-		config.DisableCatchAll)
+		config.DisableCatchAll,
+		config.StrictAnnotationValidation,
+		config.DelegationClient,
+		config.IngressClassParamsClient,
+		config.SecretReader,
+		config.EnableEndpointWeights,
+		config.EnableEndpointConditions)
 
 	n.syncQueue = task.NewTaskQueue(n.syncIngress)
+	n.endpointsQueue = task.NewTaskQueue(n.syncEndpoints)
 
 	if config.UpdateStatus {
 		n.syncStatus = status.NewStatusSyncer(pod, status.Config{
@@ -170,7 +280,7 @@ Error loading new template: %v
 
 		n.t = template
 		klog.Info("New NGINX configuration template loaded.")
-		n.syncQueue.EnqueueTask(task.GetDummyObject("template-change"))
+		n.forceSync("template-change")
 	}
 
 	ngxTpl, err := ngx_template.NewTemplate(tmplPath, fs)
@@ -191,7 +301,7 @@ Error loading new template: %v
 	}
 
 	filesToWatch := []string{}
-	err = filepath.Walk("/etc/nginx/geoip/", func(path string, info os.FileInfo, err error) error {
+	err = filepath.Walk(geoIPDirectory, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -211,7 +321,7 @@ Error loading new template: %v
 	for _, f := range filesToWatch {
 		_, err = watch.NewFileWatcher(f, func() {
 			klog.Infof("File %v changed. Reloading NGINX", f)
-			n.syncQueue.EnqueueTask(task.GetDummyObject("file-change"))
+			n.forceSync("file-change")
 		})
 		if err != nil {
 			klog.Fatalf("Error creating file watcher for %v: %v", f, err)
@@ -231,6 +341,10 @@ type NGINXController struct {
 
 	syncQueue *task.Queue
 
+	// endpointsQueue applies the changes of the Endpoints without
+	// synchronization of the configuration
+	endpointsQueue *task.Queue
+
 	syncStatus status.Syncer
 
 	syncRateLimiter flowcontrol.RateLimiter
@@ -240,14 +354,69 @@ type NGINXController struct {
 	// allowing concurrent stoppers leads to stack traces.
 	stopLock *sync.Mutex
 
+	// syncLock serializes the synchronizations of the configuration and
+	// the rollbacks requested through the configuration history server
+	syncLock *sync.Mutex
+
 	stopCh   chan struct{}
 	updateCh *channels.RingChannel
 
 	// ngxErrCh is used to detect errors with the NGINX processes
 	ngxErrCh chan error
 
+	// debugTokenChecksum is the checksum of the debug token configuration
+	// sent to NGINX
+	debugTokenChecksum string
+
+	// botChallengeKey is the key signing the clearances of the bot
+	// challenges when no Secret is configured
+	botChallengeKey string
+
+	// botChallengeChecksum is the checksum of the bot challenge
+	// configuration sent to NGINX
+	botChallengeChecksum string
+
+	// tlsFingerprints counts the connections of each TLS fingerprint
+	// since the last export of the metrics
+	tlsFingerprints *tlsFingerprintCounter
+
+	// tlsFingerprintsQueue contains the fingerprints of the connections
+	// waiting to be sent to NGINX
+	tlsFingerprintsQueue chan tlsFingerprintData
+
+	// syslogRelays forwards the logs to the syslog servers NGINX
+	// cannot reach directly
+	syslogRelays *syslog.Relays
+
+	// dnsProxy forwards the DNS queries of NGINX and the chain completion
+	// when the configuration defines resolvers
+	dnsProxy *dns.Proxy
+
+	// modsecurityAuditor reads the ModSecurity audit log to export the
+	// rules matched by the requests and ship the audit entries
+	modsecurityAuditor *modsecurity.Auditor
+
+	// upgradeStopCh stops watching the NGINX master process started by
+	// the last binary upgrade
+	upgradeStopCh chan struct{}
+
 	// runningConfig contains the running configuration in the Backend
 	runningConfig *ingress.Configuration
+	// runningConfigLock protects runningConfig from the readers running
+	// outside of the synchronization loop (i.e. the configuration API)
+	runningConfigLock *sync.RWMutex
+
+	// inventory contains the code used by NGINX after the last reload
+	inventory     []inventory.Component
+	inventoryLock *sync.RWMutex
+
+	// syncedRevision contains the revision of the objects applied by the last
+	// synchronization, zero if some changes were not applied
+	syncedRevision uint64
+
+	// rollbackSources contains the objects present when the configuration
+	// was rolled back. The rolled back configuration is kept until they change.
+	rollbackSources []history.Source
 
 	t ngx_template.TemplateWriter
 
@@ -266,39 +435,122 @@ type NGINXController struct {
 	metricCollector metric.Collector
 
 	validationWebhookServer *http.Server
+	// issues the certificate of the webhook when it is managed by the controller
+	webhookCertificates *certificate.Manager
+
+	// downloads the GeoIP2 databases when the controller manages them
+	geoIPUpdater databaseUpdater
+
+	trafficServer *http.Server
+
+	configurationServer *http.Server
+
+	dashboardServer *http.Server
+
+	history       *history.Store
+	historyServer *http.Server
+
+	debugServer *http.Server
+
+	// profiles captures the profiles of the controller when the reloads or
+	// the heap spike, nil if the continuous profiling is disabled
+	profiles *profiling.Recorder
+
+	// includedFilesChecksum is the checksum of the files included by the
+	// configuration at the last reload
+	includedFilesChecksum string
+	// includedFileChecksums keeps the checksum of each included file, so
+	// only the files modified since the last synchronization are read
+	includedFileChecksums map[string]fileChecksum
+
+	// debugHosts contains the hosts with the NGINX debug logs enabled and
+	// the time the logs are disabled again
+	debugHosts     map[string]time.Time
+	debugHostsLock *sync.Mutex
+
+	audit *audit.Log
+
+	// isLeader is set to 1 while the pod runs the leader tasks
+	isLeader int32
+
+	// stopLeaderElection stops the leader tasks and releases the lock
+	stopLeaderElection func()
+
+	// namespaceConfigs contains the data of the namespace configuration
+	// ConfigMaps written by the last synchronization, by namespace
+	namespaceConfigs map[string]map[string]string
+
+	// applied contains the last configuration published in the applied
+	// configuration ConfigMap
+	applied *appliedConfiguration
+
+	// warmup tracks the readiness of the controller after its start
+	warmup *warmup
 
 	command NginxExecTester
 }
 
+// validationWebhookTLSConfig returns the TLS configuration of the admission
+// controller, using the SVID of the SPIFFE Workload API or the certificate
+// issued by the controller when they are configured instead of the
+// certificate and key files
+func (n *NGINXController) validationWebhookTLSConfig() *tls.Config {
+	if n.cfg.WebhookSPIFFESocket != "" {
+		source, err := spiffe.NewSource(n.cfg.WebhookSPIFFESocket)
+		if err != nil {
+			klog.Fatalf("Error watching the SVID from the SPIFFE Workload API: %v", err)
+		}
+
+		return ssl.NewSPIFFETLSConfig(source, n.cfg.WebhookSPIFFEIDs)
+	}
+
+	if n.cfg.WebhookCertificateSecret != "" {
+		manager, err := certificate.NewManager(n.cfg.Client, n.cfg.WebhookCertificateSecret, n.cfg.WebhookService, n.cfg.WebhookConfiguration)
+		if err != nil {
+			klog.Fatalf("Error creating the certificate manager of the webhook: %v", err)
+		}
+
+		n.webhookCertificates = manager
+		return ssl.NewDynamicTLSConfig(manager.GetCertificate)
+	}
+
+	return ssl.NewTLSListener(n.cfg.ValidationWebhookCertPath, n.cfg.ValidationWebhookKeyPath).TLSConfig()
+}
+
 // Start starts a new NGINX master process running in the foreground.
 func (n *NGINXController) Start() {
 	klog.Info("Starting NGINX Ingress controller")
 
 	n.store.Run(n.stopCh)
+	n.warmup.setStoreSynced()
 
 	// we need to use the defined ingress class to allow multiple leaders
 	// in order to update information about ingress status
 	electionID := fmt.Sprintf("%v-%v", n.cfg.ElectionID, class.DefaultClass)
 	if class.IngressClass != "" {
-		electionID = fmt.Sprintf("%v-%v", n.cfg.ElectionID, class.IngressClass)
+		electionID = fmt.Sprintf("%v-%v", n.cfg.ElectionID, class.Name())
 	}
+	klog.Infof("Using the election ID %v", electionID)
 
-	setupLeaderElection(&leaderElectionConfig{
-		Client:     n.cfg.Client,
-		ElectionID: electionID,
+	n.stopLeaderElection = setupLeaderElection(&leaderElectionConfig{
+		Client:        n.cfg.Client,
+		ElectionID:    electionID,
+		LeaseDuration: n.cfg.ElectionLeaseDuration,
+		RenewDeadline: n.cfg.ElectionRenewDeadline,
+		RetryPeriod:   n.cfg.ElectionRetryPeriod,
+		Tasks:         n.leaderTasks(),
 		OnStartedLeading: func(stopCh chan struct{}) {
-			if n.syncStatus != nil {
-				go n.syncStatus.Run(stopCh)
-			}
-
 			n.metricCollector.OnStartedLeading(electionID)
 			// manually update SSL expiration metrics
 			// (to not wait for a reload)
-			n.metricCollector.SetSSLExpireTime(n.runningConfig.Servers)
+			n.metricCollector.SetSSLExpireTime(n.RunningConfiguration().Servers)
+			n.metricCollector.SetSSLCAExpireTime(n.store.ListLocalSSLCerts())
+			n.metricCollector.SetSSLCertificateExpireTime(n.store.ListLocalSSLCerts(), n.RunningConfiguration().Servers)
 		},
 		OnStoppedLeading: func() {
 			n.metricCollector.OnStoppedLeading(electionID)
 		},
+		OnTaskStatus: n.metricCollector.SetLeaderTask,
 		PodName:      n.podInfo.Name,
 		PodNamespace: n.podInfo.Namespace,
 	})
@@ -314,15 +566,28 @@ func (n *NGINXController) Start() {
 
 	if n.cfg.EnableSSLPassthrough {
 		n.setupSSLProxy()
+		go n.exportTLSFingerprints(n.stopCh)
+		go n.sendTLSFingerprints(n.stopCh)
 	}
 
 	klog.Info("Starting NGINX process")
 	n.start(cmd)
 
 	go n.syncQueue.Run(time.Second, n.stopCh)
+	go n.endpointsQueue.Run(time.Second, n.stopCh)
 	// force initial sync
 	n.syncQueue.EnqueueTask(task.GetDummyObject("initial-sync"))
 
+	if len(n.cfg.ProbeTargets) > 0 {
+		go n.runProber()
+	}
+
+	go n.reportCertificates(n.stopCh)
+
+	if n.cfg.SSLFilesGCInterval > 0 {
+		go n.collectSSLFiles(n.stopCh)
+	}
+
 	// In case of error the temporal configuration file will
 	// be available up to five minutes after the error
 	go func() {
@@ -335,6 +600,14 @@ func (n *NGINXController) Start() {
 		}
 	}()
 
+	if n.webhookCertificates != nil {
+		go n.webhookCertificates.Run(n.stopCh)
+	}
+
+	if n.geoIPUpdater != nil {
+		go n.updateGeoIPDatabases(n.stopCh)
+	}
+
 	if n.validationWebhookServer != nil {
 		klog.Infof("Starting validation webhook on %s with keys %s %s", n.validationWebhookServer.Addr, n.cfg.ValidationWebhookCertPath, n.cfg.ValidationWebhookKeyPath)
 		go func() {
@@ -342,6 +615,77 @@ func (n *NGINXController) Start() {
 		}()
 	}
 
+	if len(n.cfg.ConfigFreezeWindows) > 0 {
+		go n.watchConfigFreezeWindows(n.stopCh)
+	}
+
+	if n.historyServer != nil {
+		go func() {
+			listener, err := history.Listen()
+			if err != nil {
+				klog.Errorf("Unexpected error starting the configuration history server: %v", err)
+				return
+			}
+
+			klog.Error(n.historyServer.Serve(listener))
+		}()
+	}
+
+	if n.profiles != nil {
+		go n.profiles.Run(n.stopCh)
+	}
+
+	if n.debugServer != nil {
+		go func() {
+			listener, err := debug.Listen()
+			if err != nil {
+				klog.Errorf("Unexpected error starting the debug server: %v", err)
+				return
+			}
+
+			klog.Error(n.debugServer.Serve(listener))
+		}()
+	}
+
+	if n.trafficServer != nil {
+		klog.Infof("Starting traffic management API on %s", n.cfg.TrafficAPIAddress)
+		go func() {
+			listener, err := traffic.Listen(n.cfg.TrafficAPIAddress)
+			if err != nil {
+				klog.Errorf("Unexpected error starting the traffic management API: %v", err)
+				return
+			}
+
+			klog.Error(n.trafficServer.Serve(listener))
+		}()
+	}
+
+	if n.configurationServer != nil {
+		klog.Infof("Starting configuration API on %s", n.cfg.ConfigurationAPIAddress)
+		go func() {
+			listener, err := traffic.Listen(n.cfg.ConfigurationAPIAddress)
+			if err != nil {
+				klog.Errorf("Unexpected error starting the configuration API: %v", err)
+				return
+			}
+
+			klog.Error(n.configurationServer.Serve(listener))
+		}()
+	}
+
+	if n.dashboardServer != nil {
+		klog.Infof("Starting dashboard on %s", n.cfg.DashboardAddress)
+		go func() {
+			listener, err := traffic.Listen(n.cfg.DashboardAddress)
+			if err != nil {
+				klog.Errorf("Unexpected error starting the dashboard: %v", err)
+				return
+			}
+
+			klog.Error(n.dashboardServer.Serve(listener))
+		}()
+	}
+
 	for {
 		select {
 		case err := <-n.ngxErrCh:
@@ -365,19 +709,34 @@ func (n *NGINXController) Start() {
 					Pgid:    0,
 				}
 				n.start(cmd)
+
+				// the new master process does not know the debug token
+				go n.resetDebugToken()
 			}
 		case event := <-n.updateCh.Out():
 			if n.isShuttingDown {
 				break
 			}
 			if evt, ok := event.(store.Event); ok {
-				klog.V(3).Infof("Event %v received - object %v", evt.Type, evt.Obj)
+				logging.V(3).Infof("Event %v received - object %v", evt.Type, evt.Obj)
 				if evt.Type == store.ConfigurationEvent {
 					// TODO: is this necessary? Consider removing this special case
 					n.syncQueue.EnqueueTask(task.GetDummyObject("configmap-change"))
 					continue
 				}
 
+				// the changes of the endpoints are applied directly when
+				// they do not modify the structure of the configuration
+				if _, ok := evt.Obj.(*apiv1.Endpoints); ok {
+					n.endpointsQueue.EnqueueTask(evt.Obj)
+					continue
+				}
+
+				if eventPriority(evt) == task.HighPriority {
+					n.syncQueue.EnqueueSkippablePriorityTask(evt.Obj)
+					continue
+				}
+
 				n.syncQueue.EnqueueSkippableTask(evt.Obj)
 			} else {
 				klog.Warningf("Unexpected event type received %T", event)
@@ -388,6 +747,25 @@ func (n *NGINXController) Start() {
 	}
 }
 
+// eventPriority returns the priority of the synchronization of an event.
+// The changes of the TLS Secrets, i.e. certificate renewals, are applied
+// before the other changes. The changes of the endpoints do not reach the
+// sync queue, they are applied by the endpoints queue.
+func eventPriority(evt store.Event) task.Priority {
+	obj := evt.Obj
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	if secret, ok := obj.(*apiv1.Secret); ok {
+		if _, ok := secret.Data[apiv1.TLSCertKey]; ok {
+			return task.HighPriority
+		}
+	}
+
+	return task.NormalPriority
+}
+
 // Stop gracefully stops the NGINX master process.
 func (n *NGINXController) Stop() error {
 	n.isShuttingDown = true
@@ -402,10 +780,16 @@ func (n *NGINXController) Stop() error {
 	klog.Info("Shutting down controller queues")
 	close(n.stopCh)
 	go n.syncQueue.Shutdown()
+	go n.endpointsQueue.Shutdown()
 	if n.syncStatus != nil {
 		n.syncStatus.Shutdown()
 	}
 
+	if n.stopLeaderElection != nil {
+		klog.Info("Releasing the leader election lock")
+		n.stopLeaderElection()
+	}
+
 	if n.validationWebhookServer != nil {
 		klog.Info("Stopping admission controller")
 		err := n.validationWebhookServer.Close()
@@ -414,6 +798,46 @@ func (n *NGINXController) Stop() error {
 		}
 	}
 
+	if n.trafficServer != nil {
+		klog.Info("Stopping traffic management API")
+		err := n.trafficServer.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if n.configurationServer != nil {
+		klog.Info("Stopping configuration API")
+		err := n.configurationServer.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if n.dashboardServer != nil {
+		klog.Info("Stopping dashboard")
+		err := n.dashboardServer.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if n.historyServer != nil {
+		klog.Info("Stopping configuration history server")
+		err := n.historyServer.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if n.debugServer != nil {
+		klog.Info("Stopping debug server")
+		err := n.debugServer.Close()
+		if err != nil {
+			return err
+		}
+	}
+
 	// send stop signal to NGINX
 	klog.Info("Stopping NGINX process")
 	cmd := n.command.ExecCommand("-s", "quit")
@@ -434,6 +858,15 @@ func (n *NGINXController) Stop() error {
 		}
 	}
 
+	if n.upgradeStopCh != nil {
+		close(n.upgradeStopCh)
+		n.upgradeStopCh = nil
+	}
+
+	n.syslogRelays.Close()
+	n.dnsProxy.Close()
+	n.modsecurityAuditor.Close()
+
 	return nil
 }
 
@@ -462,6 +895,11 @@ func (n NGINXController) DefaultEndpoint() ingress.Endpoint {
 
 // generateTemplate returns the nginx configuration file content
 func (n NGINXController) generateTemplate(cfg ngx_config.Configuration, ingressCfg ingress.Configuration) ([]byte, error) {
+	return n.t.Write(n.templateConfig(cfg, ingressCfg))
+}
+
+// templateConfig returns the configuration used to render the nginx configuration file
+func (n NGINXController) templateConfig(cfg ngx_config.Configuration, ingressCfg ingress.Configuration) ngx_config.TemplateConfig {
 
 	if n.cfg.EnableSSLPassthrough {
 		servers := []*TCPServer{}
@@ -518,13 +956,13 @@ func (n NGINXController) generateTemplate(cfg ngx_config.Configuration, ingressC
 
 	if cfg.ServerNameHashBucketSize == 0 {
 		nameHashBucketSize := nginxHashBucketSize(longestName)
-		klog.V(3).Infof("Adjusting ServerNameHashBucketSize variable to %d", nameHashBucketSize)
+		logging.V(3).Infof("Adjusting ServerNameHashBucketSize variable to %d", nameHashBucketSize)
 		cfg.ServerNameHashBucketSize = nameHashBucketSize
 	}
 
 	serverNameHashMaxSize := nextPowerOf2(serverNameBytes)
 	if cfg.ServerNameHashMaxSize < serverNameHashMaxSize {
-		klog.V(3).Infof("Adjusting ServerNameHashMaxSize variable to %d", serverNameHashMaxSize)
+		logging.V(3).Infof("Adjusting ServerNameHashMaxSize variable to %d", serverNameHashMaxSize)
 		cfg.ServerNameHashMaxSize = serverNameHashMaxSize
 	}
 
@@ -532,23 +970,23 @@ func (n NGINXController) generateTemplate(cfg ngx_config.Configuration, ingressC
 		// the limit of open files is per worker process
 		// and we leave some room to avoid consuming all the FDs available
 		wp, err := strconv.Atoi(cfg.WorkerProcesses)
-		klog.V(3).Infof("Number of worker processes: %d", wp)
+		logging.V(3).Infof("Number of worker processes: %d", wp)
 		if err != nil {
 			wp = 1
 		}
 		maxOpenFiles := (rlimitMaxNumFiles() / wp) - 1024
-		klog.V(3).Infof("Maximum number of open file descriptors: %d", maxOpenFiles)
+		logging.V(3).Infof("Maximum number of open file descriptors: %d", maxOpenFiles)
 		if maxOpenFiles < 1024 {
 			// this means the value of RLIMIT_NOFILE is too low.
 			maxOpenFiles = 1024
 		}
-		klog.V(3).Infof("Adjusting MaxWorkerOpenFiles variable to %d", maxOpenFiles)
+		logging.V(3).Infof("Adjusting MaxWorkerOpenFiles variable to %d", maxOpenFiles)
 		cfg.MaxWorkerOpenFiles = maxOpenFiles
 	}
 
 	if cfg.MaxWorkerConnections == 0 {
 		maxWorkerConnections := int(math.Ceil(float64(cfg.MaxWorkerOpenFiles * 3.0 / 4)))
-		klog.V(3).Infof("Adjusting MaxWorkerConnections variable to %d", maxWorkerConnections)
+		logging.V(3).Infof("Adjusting MaxWorkerConnections variable to %d", maxWorkerConnections)
 		cfg.MaxWorkerConnections = maxWorkerConnections
 	}
 
@@ -614,17 +1052,20 @@ func (n NGINXController) generateTemplate(cfg ngx_config.Configuration, ingressC
 		PublishService:            n.GetPublishService(),
 		EnableDynamicCertificates: ngx_config.EnableDynamicCertificates,
 		EnableMetrics:             n.cfg.EnableMetrics,
+		DebugHosts:                n.activeDebugHosts(time.Now()),
 
-		HealthzURI:   nginx.HealthPath,
-		PID:          nginx.PID,
-		StatusSocket: nginx.StatusSocket,
-		StatusPath:   nginx.StatusPath,
-		StreamSocket: nginx.StreamSocket,
+		HealthzURI:             nginx.HealthPath,
+		PID:                    nginx.PID,
+		StatusSocket:           nginx.StatusSocket,
+		StatusPath:             nginx.StatusPath,
+		AccessEventsStatusPath: nginx.AccessEventsStatusPath,
+		UsageStatusPath:        nginx.UsageStatusPath,
+		StreamSocket:           nginx.StreamSocket,
 	}
 
 	tc.Cfg.Checksum = ingressCfg.ConfigurationChecksum
 
-	return n.t.Write(tc)
+	return tc
 }
 
 // testTemplate checks if the NGINX configuration inside the byte array is valid
@@ -662,30 +1103,83 @@ Error: %v
 // OnUpdate is called by the synchronization loop whenever configuration
 // changes were detected. The received backend Configuration is merged with the
 // configuration ConfigMap before generating the final configuration file.
-// Returns nil in case the backend was successfully reloaded.
-func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) error {
+// Returns false when the reload was skipped because the rendered configuration
+// and the files it includes are identical to the ones loaded by NGINX.
+func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) (bool, error) {
 	cfg := n.store.GetBackendConfiguration()
 	cfg.Resolver = n.resolver
 
-	content, err := n.generateTemplate(cfg, ingressCfg)
+	dnsCfg := cfg.DNSProxyConfig()
+	err := n.dnsProxy.Sync(dnsCfg)
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	if dnsCfg.Enabled() {
+		cfg.DNSProxyPort = n.cfg.ListenPorts.DNS
+		ssl.SetChainResolver(n.dnsProxy.Address())
+	} else {
+		ssl.SetChainResolver("")
+	}
+
+	tc := n.templateConfig(cfg, ingressCfg)
+	content, err := n.t.Write(tc)
+	if err != nil {
+		return false, err
 	}
 
 	if cfg.EnableOpentracing {
 		err := createOpentracingCfg(cfg)
 		if err != nil {
-			return err
+			return false, err
+		}
+	}
+
+	err = n.syslogRelays.Sync(cfg.SyslogRemotes())
+	if err != nil {
+		return false, err
+	}
+
+	n.modsecurityAuditor.Sync(cfg.ModsecurityAuditConfig())
+
+	// the files are read before the reload, a change made during the
+	// reload is detected by the next synchronization
+	src, _ := ioutil.ReadFile(cfgPath)
+	if n.includedFileChecksums == nil {
+		n.includedFileChecksums = map[string]fileChecksum{}
+	}
+	checksum := checksumIncludedFiles(n.includedFileChecksums)
+
+	reloaded := false
+	if bytes.Equal(src, content) && checksum == n.includedFilesChecksum {
+		klog.Infof("The rendered configuration is identical to the running one, skipping the reload.")
+		n.metricCollector.IncReloadSkippedCount()
+	} else {
+		err = n.testAndReload(src, content)
+		if err != nil {
+			return false, err
 		}
+
+		n.includedFilesChecksum = checksum
+		reloaded = true
 	}
 
-	err = n.testTemplate(content)
+	n.metricCollector.SetConnectionsCapacity(connectionsCapacity(tc.Cfg))
+	n.updateInventory()
+	n.publishNamespaceConfigs(tc)
+
+	return reloaded, nil
+}
+
+// testAndReload checks the new configuration, logs the differences with
+// the current one and reloads NGINX
+func (n *NGINXController) testAndReload(src, content []byte) error {
+	err := n.testTemplate(content)
 	if err != nil {
 		return err
 	}
 
-	if klog.V(2) {
-		src, _ := ioutil.ReadFile(cfgPath)
+	if logging.V(2) {
 		if !bytes.Equal(src, content) {
 			tmpfile, err := ioutil.TempFile("", "new-nginx-cfg")
 			if err != nil {
@@ -710,7 +1204,70 @@ func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) error {
 		}
 	}
 
-	err = ioutil.WriteFile(cfgPath, content, file.ReadWriteByUser)
+	return n.reload(content)
+}
+
+// fileChecksum is the checksum of a file, computed again when its size or
+// its modification time changed
+type fileChecksum struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// checksumIncludedFiles returns a checksum of the files read by NGINX with
+// the configuration: the certificates, the authentication files, the GeoIP
+// databases and the configuration of the tracer. Only the files not found
+// in checksums, or modified since, are read. The checksums are updated.
+func checksumIncludedFiles(checksums map[string]fileChecksum) string {
+	h := sha256.New()
+	found := map[string]bool{}
+
+	add := func(path string, info os.FileInfo) {
+		c, ok := checksums[path]
+		if !ok || c.size != info.Size() || !c.modTime.Equal(info.ModTime()) {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return
+			}
+
+			sum := sha256.Sum256(data)
+			c = fileChecksum{size: info.Size(), modTime: info.ModTime(), sum: hex.EncodeToString(sum[:])}
+			checksums[path] = c
+		}
+
+		found[path] = true
+		fmt.Fprintf(h, "%v:%v\n", path, c.sum)
+	}
+
+	for _, dir := range includedDirectories {
+		filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				add(path, info)
+			}
+			return nil
+		})
+	}
+
+	for _, path := range includedFiles {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			add(path, info)
+		}
+	}
+
+	// the files removed are forgotten
+	for path := range checksums {
+		if !found[path] {
+			delete(checksums, path)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// reload writes the NGINX configuration file and reloads NGINX
+func (n *NGINXController) reload(content []byte) error {
+	err := ioutil.WriteFile(cfgPath, content, file.ReadWriteByUser)
 	if err != nil {
 		return err
 	}
@@ -720,9 +1277,25 @@ func (n *NGINXController) OnUpdate(ingressCfg ingress.Configuration) error {
 		return fmt.Errorf("%v\n%v", err, string(o))
 	}
 
+	if n.profiles != nil {
+		n.profiles.Reloaded(time.Now())
+	}
+
 	return nil
 }
 
+// connectionsCapacity returns the maximum number of client connections of
+// all the NGINX workers. Each proxied request uses a second connection to
+// the upstream, so only half of the worker connections are for the clients.
+func connectionsCapacity(cfg ngx_config.Configuration) int {
+	wp, err := strconv.Atoi(cfg.WorkerProcesses)
+	if err != nil {
+		wp = 1
+	}
+
+	return wp * cfg.MaxWorkerConnections / 2
+}
+
 // nginxHashBucketSize computes the correct NGINX hash_bucket_size for a hash
 // with the given longest key.
 func nginxHashBucketSize(longestString int) int {
@@ -761,6 +1334,7 @@ func (n *NGINXController) setupSSLProxy() {
 			Port:          proxyPort,
 			ProxyProtocol: true,
 		},
+		OnClientHello: n.recordTLSFingerprint,
 	}
 
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", sslPort))
@@ -789,7 +1363,7 @@ func (n *NGINXController) setupSSLProxy() {
 				continue
 			}
 
-			klog.V(3).Infof("Handling connection from remote address %s to local %s", conn.RemoteAddr(), conn.LocalAddr())
+			logging.V(3).Infof("Handling connection from remote address %s to local %s", conn.RemoteAddr(), conn.LocalAddr())
 			go n.Proxy.Handle(conn)
 		}
 	}()
@@ -858,39 +1432,10 @@ func (n *NGINXController) IsDynamicConfigurationEnough(pcfg *ingress.Configurati
 }
 
 // configureDynamically encodes new Backends in JSON format and POSTs the
-// payload to an internal HTTP endpoint handled by Lua.
-func configureDynamically(pcfg *ingress.Configuration) error {
-	backends := make([]*ingress.Backend, len(pcfg.Backends))
-
-	for i, backend := range pcfg.Backends {
-		var service *apiv1.Service
-		if backend.Service != nil {
-			service = &apiv1.Service{Spec: backend.Service.Spec}
-		}
-		luaBackend := &ingress.Backend{
-			Name:                 backend.Name,
-			Port:                 backend.Port,
-			SSLPassthrough:       backend.SSLPassthrough,
-			SessionAffinity:      backend.SessionAffinity,
-			UpstreamHashBy:       backend.UpstreamHashBy,
-			LoadBalancing:        backend.LoadBalancing,
-			Service:              service,
-			NoServer:             backend.NoServer,
-			TrafficShapingPolicy: backend.TrafficShapingPolicy,
-			AlternativeBackends:  backend.AlternativeBackends,
-		}
-
-		var endpoints []ingress.Endpoint
-		for _, endpoint := range backend.Endpoints {
-			endpoints = append(endpoints, ingress.Endpoint{
-				Address: endpoint.Address,
-				Port:    endpoint.Port,
-			})
-		}
-
-		luaBackend.Endpoints = endpoints
-		backends[i] = luaBackend
-	}
+// payload to an internal HTTP endpoint handled by Lua. The names of the
+// services and pods of the endpoints are included if backendMetadata is true.
+func configureDynamically(fs file.Filesystem, pcfg *ingress.Configuration, backendMetadata bool) error {
+	backends := luaBackends(pcfg.Backends, backendMetadata)
 
 	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/backends", "application/json", backends)
 	if err != nil {
@@ -923,12 +1468,18 @@ func configureDynamically(pcfg *ingress.Configuration) error {
 		}
 
 		key := fmt.Sprintf("udp-%v-%v-%v", ep.Backend.Namespace, ep.Backend.Name, ep.Backend.Port.String())
-		streams = append(streams, ingress.Backend{
+		stream := ingress.Backend{
 			Name:      key,
 			Endpoints: ep.Endpoints,
 			Port:      intstr.FromInt(ep.Port),
 			Service:   service,
-		})
+		}
+		// the source IP affinity hashes the address of the client
+		if ep.Backend.Affinity == "source-ip" {
+			stream.LoadBalancing = "chash"
+			stream.UpstreamHashBy.UpstreamHashBy = "$remote_addr"
+		}
+		streams = append(streams, stream)
 	}
 
 	err = updateStreamConfiguration(streams)
@@ -948,7 +1499,7 @@ func configureDynamically(pcfg *ingress.Configuration) error {
 	}
 
 	if ngx_config.EnableDynamicCertificates {
-		err = configureCertificates(pcfg)
+		err = configureCertificates(fs, pcfg)
 		if err != nil {
 			return err
 		}
@@ -957,6 +1508,60 @@ func configureDynamically(pcfg *ingress.Configuration) error {
 	return nil
 }
 
+// luaBackends returns a copy of the backends containing only the
+// information used by the Lua balancer
+func luaBackends(backends []*ingress.Backend, backendMetadata bool) []*ingress.Backend {
+	luaBackends := make([]*ingress.Backend, len(backends))
+
+	for i, backend := range backends {
+		var service *apiv1.Service
+		if backend.Service != nil {
+			service = &apiv1.Service{Spec: backend.Service.Spec}
+			if backendMetadata {
+				service.Name = backend.Service.Name
+				service.Namespace = backend.Service.Namespace
+			}
+		}
+		luaBackend := &ingress.Backend{
+			Name:                 backend.Name,
+			Port:                 backend.Port,
+			SSLPassthrough:       backend.SSLPassthrough,
+			SessionAffinity:      backend.SessionAffinity,
+			UpstreamHashBy:       backend.UpstreamHashBy,
+			LoadBalancing:        backend.LoadBalancing,
+			Service:              service,
+			NoServer:             backend.NoServer,
+			TrafficShapingPolicy: backend.TrafficShapingPolicy,
+			AlternativeBackends:  backend.AlternativeBackends,
+			PodRoutingBy:         backend.PodRoutingBy,
+			PodBackends:          backend.PodBackends,
+			Failover:             backend.Failover,
+		}
+
+		var endpoints []ingress.Endpoint
+		for _, endpoint := range backend.Endpoints {
+			luaEndpoint := ingress.Endpoint{
+				Address: endpoint.Address,
+				Port:    endpoint.Port,
+				Weight:  endpoint.Weight,
+			}
+			if backendMetadata && endpoint.Target != nil && endpoint.Target.Kind == "Pod" {
+				luaEndpoint.Target = &apiv1.ObjectReference{
+					Namespace: endpoint.Target.Namespace,
+					Name:      endpoint.Target.Name,
+				}
+			}
+
+			endpoints = append(endpoints, luaEndpoint)
+		}
+
+		luaBackend.Endpoints = endpoints
+		luaBackends[i] = luaBackend
+	}
+
+	return luaBackends
+}
+
 func updateStreamConfiguration(streams []ingress.Backend) error {
 	conn, err := net.Dial("unix", nginx.StreamSocket)
 	if err != nil {
@@ -983,43 +1588,60 @@ func updateStreamConfiguration(streams []ingress.Backend) error {
 
 // configureCertificates JSON encodes certificates and POSTs it to an internal HTTP endpoint
 // that is handled by Lua
-func configureCertificates(pcfg *ingress.Configuration) error {
+func configureCertificates(fs file.Filesystem, pcfg *ingress.Configuration) error {
 	var servers []*ingress.Server
 
-	for _, server := range pcfg.Servers {
-		if server.SSLCert.PemCertKey == "" {
-			continue
+	// the certificates not kept in memory are read once from disk, the
+	// compressed ones are decompressed once
+	pemCertKeys := map[string]string{}
+	pemCertKey := func(cert *ingress.SSLCert) string {
+		if cert.PemCertKey != "" {
+			return cert.PemCertKey
 		}
 
-		servers = append(servers, &ingress.Server{
-			Hostname: server.Hostname,
-			SSLCert: ingress.SSLCert{
-				PemCertKey: server.SSLCert.PemCertKey,
-			},
-		})
+		key := cert.PemCertKeyFileName
+		if len(cert.PemCertKeyCompressed) > 0 {
+			key = "sha:" + cert.PemCertKeySHA
+		}
+		if key == "" {
+			return ""
+		}
 
-		if server.Alias != "" && ssl.IsValidHostname(server.Alias, server.SSLCert.CN) {
-			servers = append(servers, &ingress.Server{
-				Hostname: server.Alias,
-				SSLCert: ingress.SSLCert{
-					PemCertKey: server.SSLCert.PemCertKey,
-				},
-			})
+		if content, ok := pemCertKeys[key]; ok {
+			return content
+		}
+
+		content, err := ssl.ReadPemCertKey(fs, cert)
+		if err != nil {
+			klog.Warningf("Unexpected error reading certificate and key: %v", err)
 		}
+
+		pemCertKeys[key] = content
+		return content
 	}
 
-	redirects := buildRedirects(pcfg.Servers)
-	for _, redirect := range redirects {
-		if redirect.SSLCert.PemCertKey == "" {
+	for _, sc := range dynamicCertificates(pcfg) {
+		content := pemCertKey(sc.cert)
+		if content == "" {
 			continue
 		}
 
-		servers = append(servers, &ingress.Server{
-			Hostname: redirect.From,
+		server := &ingress.Server{
+			Hostname: sc.hostname,
 			SSLCert: ingress.SSLCert{
-				PemCertKey: redirect.SSLCert.PemCertKey,
+				PemCertKey:   content,
+				OCSPResponse: sc.cert.OCSPResponse,
 			},
-		})
+		}
+
+		// the ECDSA certificate is served to the clients supporting it
+		if sc.cert.ECDSA != nil {
+			if ecdsa := pemCertKey(sc.cert.ECDSA); ecdsa != "" {
+				server.SSLCert.ECDSA = &ingress.SSLCert{PemCertKey: ecdsa}
+			}
+		}
+
+		servers = append(servers, server)
 	}
 
 	statusCode, _, err := nginx.NewPostStatusRequest("/configuration/servers", "application/json", servers)
@@ -1034,6 +1656,37 @@ func configureCertificates(pcfg *ingress.Configuration) error {
 	return nil
 }
 
+// sniCertificate is the certificate served by Lua for a hostname
+type sniCertificate struct {
+	hostname string
+	cert     *ingress.SSLCert
+}
+
+// dynamicCertificates returns the certificates served by Lua in the order
+// they are posted, the last certificate of a hostname wins. The certificates
+// without content are skipped when they are posted.
+func dynamicCertificates(pcfg *ingress.Configuration) []sniCertificate {
+	var certs []sniCertificate
+
+	for _, server := range pcfg.Servers {
+		certs = append(certs, sniCertificate{hostname: server.Hostname, cert: &server.SSLCert})
+
+		for _, alias := range strings.Fields(server.Alias) {
+			if !ssl.IsValidHostname(alias, server.SSLCert.CN) {
+				continue
+			}
+
+			certs = append(certs, sniCertificate{hostname: alias, cert: &server.SSLCert})
+		}
+	}
+
+	for _, redirect := range buildRedirects(pcfg.Servers) {
+		certs = append(certs, sniCertificate{hostname: redirect.From, cert: &redirect.SSLCert})
+	}
+
+	return certs
+}
+
 const zipkinTmpl = `{
   "service_name": "{{ .ZipkinServiceName }}",
   "collector_host": "{{ .ZipkinCollectorHost }}",
@@ -1128,12 +1781,23 @@ type redirect struct {
 	From    string
 	To      string
 	SSLCert ingress.SSLCert
+	// Code is the status code of the redirect. When it is 0 the
+	// http-redirect-code from the configuration is used.
+	Code int
+	// Internal indicates the redirect is only served on the internal
+	// listener, like the server it redirects to.
+	Internal bool
 }
 
 func buildRedirects(servers []*ingress.Server) []*redirect {
 	names := sets.String{}
 	redirectServers := make([]*redirect, 0)
 
+	hostnames := sets.NewString()
+	for _, srv := range servers {
+		hostnames.Insert(srv.Hostname)
+	}
+
 	for _, srv := range servers {
 		if !srv.RedirectFromToWWW {
 			continue
@@ -1152,36 +1816,59 @@ func buildRedirects(servers []*ingress.Server) []*redirect {
 			continue
 		}
 
-		klog.V(3).Infof("Creating redirect from %q to %q", from, to)
-		found := false
-		for _, esrv := range servers {
-			if esrv.Hostname == from {
-				found = true
-				break
-			}
-		}
-
-		if found {
+		logging.V(3).Infof("Creating redirect from %q to %q", from, to)
+		if hostnames.Has(from) {
 			klog.Warningf("Already exists an Ingress with %q hostname. Skipping creation of redirection from %q to %q.", from, from, to)
 			continue
 		}
 
-		r := &redirect{
-			From: from,
-			To:   to,
-		}
+		redirectServers = append(redirectServers, newRedirect(from, srv, 0))
+		names.Insert(to)
+	}
 
-		if srv.SSLCert.PemSHA != "" {
-			if ssl.IsValidHostname(from, srv.SSLCert.CN) {
-				r.SSLCert = srv.SSLCert
-			} else {
-				klog.Warningf("the server %v has SSL configured but the SSL certificate does not contains a CN for %v. Redirects will not work for HTTPS to HTTPS", from, to)
+	froms := sets.NewString()
+	for _, r := range redirectServers {
+		froms.Insert(r.From)
+	}
+
+	for _, srv := range servers {
+		for _, from := range srv.RedirectFromAliases {
+			if hostnames.Has(from) {
+				klog.Warningf("Already exists an Ingress with %q hostname. Skipping creation of redirection from %q to %q.", from, from, srv.Hostname)
+				continue
 			}
-		}
 
-		redirectServers = append(redirectServers, r)
-		names.Insert(to)
+			if froms.Has(from) {
+				klog.Warningf("Already exists a redirection from %q. Skipping creation of redirection from %q to %q.", from, from, srv.Hostname)
+				continue
+			}
+
+			logging.V(3).Infof("Creating redirect from alias %q to %q", from, srv.Hostname)
+			redirectServers = append(redirectServers, newRedirect(from, srv, srv.RedirectFromAliasesCode))
+			froms.Insert(from)
+		}
 	}
 
 	return redirectServers
 }
+
+// newRedirect returns a redirect from the given host to the server,
+// reusing the SSL certificate of the server when it is valid for the host.
+func newRedirect(from string, srv *ingress.Server, code int) *redirect {
+	r := &redirect{
+		From:     from,
+		To:       srv.Hostname,
+		Code:     code,
+		Internal: srv.Internal,
+	}
+
+	if srv.SSLCert.PemSHA != "" {
+		if ssl.IsValidHostname(from, srv.SSLCert.CN) {
+			r.SSLCert = srv.SSLCert
+		} else {
+			klog.Warningf("the server %v has SSL configured but the SSL certificate does not contains a CN for %v. Redirects will not work for HTTPS to HTTPS", from, srv.Hostname)
+		}
+	}
+
+	return r
+}
@@ -24,16 +24,23 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
 
+	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/controller/store"
 	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/task"
 )
 
 func TestIsDynamicConfigurationEnough(t *testing.T) {
@@ -249,7 +256,12 @@ func TestConfigureDynamically(t *testing.T) {
 	ngx_config.EnableDynamicCertificates = false
 	defer func() { ngx_config.EnableDynamicCertificates = true }()
 
-	err = configureDynamically(commonConfig)
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error creating filesystem: %v", err)
+	}
+
+	err = configureDynamically(fs, commonConfig, false)
 	if err != nil {
 		t.Errorf("unexpected error posting dynamic configuration: %v", err)
 	}
@@ -259,6 +271,44 @@ func TestConfigureDynamically(t *testing.T) {
 	}
 }
 
+func TestLuaBackends(t *testing.T) {
+	backends := []*ingress.Backend{{
+		Name: "fakenamespace-myapp-80",
+		Service: &apiv1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "myapp", Namespace: "fakenamespace"},
+		},
+		Endpoints: []ingress.Endpoint{
+			{
+				Address: "10.0.0.1",
+				Port:    "8080",
+				Target:  &apiv1.ObjectReference{Kind: "Pod", Name: "myapp-0", Namespace: "fakenamespace", UID: "1"},
+			},
+			{
+				Address: "10.0.0.2",
+				Port:    "8080",
+			},
+		},
+	}}
+
+	lb := luaBackends(backends, false)
+	if lb[0].Service.Name != "" || lb[0].Endpoints[0].Target != nil {
+		t.Errorf("unexpected backend metadata: %v", lb[0])
+	}
+
+	lb = luaBackends(backends, true)
+	if lb[0].Service.Name != "myapp" || lb[0].Service.Namespace != "fakenamespace" {
+		t.Errorf("expected the service metadata but returned %v", lb[0].Service.ObjectMeta)
+	}
+
+	expected := &apiv1.ObjectReference{Name: "myapp-0", Namespace: "fakenamespace"}
+	if !reflect.DeepEqual(lb[0].Endpoints[0].Target, expected) {
+		t.Errorf("expected %v but returned %v", expected, lb[0].Endpoints[0].Target)
+	}
+	if lb[0].Endpoints[1].Target != nil {
+		t.Errorf("unexpected target %v", lb[0].Endpoints[1].Target)
+	}
+}
+
 func TestConfigureCertificates(t *testing.T) {
 	listener, err := net.Listen("unix", nginx.StatusSocket)
 	if err != nil {
@@ -279,6 +329,14 @@ func TestConfigureCertificates(t *testing.T) {
 		SSLCert: ingress.SSLCert{
 			PemCertKey: "fake-cert",
 		},
+	}, {
+		Hostname: "dual.fake",
+		SSLCert: ingress.SSLCert{
+			PemCertKey: "fake-rsa-cert",
+			ECDSA: &ingress.SSLCert{
+				PemCertKey: "fake-ecdsa-cert",
+			},
+		},
 	}}
 
 	server := &httptest.Server{
@@ -320,7 +378,12 @@ func TestConfigureCertificates(t *testing.T) {
 		Servers: servers,
 	}
 
-	err = configureCertificates(commonConfig)
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error creating filesystem: %v", err)
+	}
+
+	err = configureCertificates(fs, commonConfig)
 	if err != nil {
 		t.Errorf("unexpected error posting dynamic certificate configuration: %v", err)
 	}
@@ -366,6 +429,31 @@ func TestNginxHashBucketSize(t *testing.T) {
 	}
 }
 
+func TestEventPriority(t *testing.T) {
+	tlsSecret := &apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "tls"},
+		Data:       map[string][]byte{apiv1.TLSCertKey: []byte("cert"), apiv1.TLSPrivateKeyKey: []byte("key")},
+	}
+
+	testCases := []struct {
+		name     string
+		event    store.Event
+		expected task.Priority
+	}{
+		{"TLS secret", store.Event{Type: store.UpdateEvent, Obj: tlsSecret}, task.HighPriority},
+		{"deleted TLS secret", store.Event{Type: store.DeleteEvent, Obj: cache.DeletedFinalStateUnknown{Key: "default/tls", Obj: tlsSecret}}, task.HighPriority},
+		{"authentication secret", store.Event{Type: store.UpdateEvent, Obj: &apiv1.Secret{Data: map[string][]byte{"auth": []byte("user:pass")}}}, task.NormalPriority},
+		{"ingress", store.Event{Type: store.UpdateEvent, Obj: &networking.Ingress{}}, task.NormalPriority},
+		{"service", store.Event{Type: store.CreateEvent, Obj: &apiv1.Service{}}, task.NormalPriority},
+	}
+
+	for _, tc := range testCases {
+		if priority := eventPriority(tc.event); priority != tc.expected {
+			t.Errorf("%v: expected priority %v but %v was returned", tc.name, tc.expected, priority)
+		}
+	}
+}
+
 func TestNextPowerOf2(t *testing.T) {
 	// Powers of 2
 	actual := nextPowerOf2(2)
@@ -422,6 +510,18 @@ func TestNextPowerOf2(t *testing.T) {
 	}
 }
 
+func TestConnectionsCapacity(t *testing.T) {
+	actual := connectionsCapacity(ngx_config.Configuration{WorkerProcesses: "4", MaxWorkerConnections: 16384})
+	if actual != 32768 {
+		t.Errorf("TestConnectionsCapacity: expected %d but returned %d.", 32768, actual)
+	}
+
+	actual = connectionsCapacity(ngx_config.Configuration{WorkerProcesses: "auto", MaxWorkerConnections: 1024})
+	if actual != 512 {
+		t.Errorf("TestConnectionsCapacity: expected %d but returned %d.", 512, actual)
+	}
+}
+
 func TestCleanTempNginxCfg(t *testing.T) {
 	err := cleanTempNginxCfg()
 	if err != nil {
@@ -476,3 +576,102 @@ func TestCleanTempNginxCfg(t *testing.T) {
 		t.Errorf("expected one file but %d were found", len(files))
 	}
 }
+
+func TestBuildRedirects(t *testing.T) {
+	servers := []*ingress.Server{
+		{
+			Hostname:          "example.com",
+			RedirectFromToWWW: true,
+		},
+		{
+			Hostname:                "foo.bar",
+			RedirectFromAliases:     []string{"old.foo.bar", "www.example.com", "example.com"},
+			RedirectFromAliasesCode: http.StatusPermanentRedirect,
+		},
+	}
+
+	redirects := buildRedirects(servers)
+	if len(redirects) != 2 {
+		t.Fatalf("expected 2 redirects but got %v", len(redirects))
+	}
+
+	if redirects[0].From != "www.example.com" || redirects[0].To != "example.com" || redirects[0].Code != 0 {
+		t.Errorf("unexpected redirect from %v to %v (%v)", redirects[0].From, redirects[0].To, redirects[0].Code)
+	}
+
+	if redirects[1].From != "old.foo.bar" || redirects[1].To != "foo.bar" || redirects[1].Code != http.StatusPermanentRedirect {
+		t.Errorf("unexpected redirect from %v to %v (%v)", redirects[1].From, redirects[1].To, redirects[1].Code)
+	}
+}
+
+func TestChecksumIncludedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "included")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(dirs, files []string) {
+		includedDirectories, includedFiles = dirs, files
+	}(includedDirectories, includedFiles)
+
+	includedDirectories = []string{filepath.Join(dir, "ssl"), filepath.Join(dir, "missing")}
+	includedFiles = []string{filepath.Join(dir, "opentracing.json")}
+
+	if err := os.MkdirAll(filepath.Join(dir, "ssl", "ca"), 0700); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	checksums := map[string]fileChecksum{}
+
+	write("ssl/default-foo.pem", "certificate")
+	empty := checksumIncludedFiles(checksums)
+	if empty != checksumIncludedFiles(checksums) {
+		t.Errorf("expected the same checksum without changes")
+	}
+
+	write("opentracing.json", "{}")
+	tracer := checksumIncludedFiles(checksums)
+	if tracer == empty {
+		t.Errorf("expected a new checksum after the creation of a file")
+	}
+
+	write("ssl/ca/default-ca.pem", "ca")
+	ca := checksumIncludedFiles(checksums)
+	if ca == tracer {
+		t.Errorf("expected a new checksum after the creation of a file in a subdirectory")
+	}
+
+	write("ssl/default-foo.pem", "renewed certificate")
+	renewed := checksumIncludedFiles(checksums)
+	if renewed == ca {
+		t.Errorf("expected a new checksum after the change of a certificate")
+	}
+
+	// a file with the same size and modification time is not read again
+	info, err := os.Stat(filepath.Join(dir, "ssl/default-foo.pem"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	write("ssl/default-foo.pem", "revoked certificate")
+	if err := os.Chtimes(filepath.Join(dir, "ssl/default-foo.pem"), info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if checksumIncludedFiles(checksums) != renewed {
+		t.Errorf("expected the checksum of the unmodified file to be reused")
+	}
+
+	os.Remove(filepath.Join(dir, "opentracing.json"))
+	if checksumIncludedFiles(checksums) == renewed {
+		t.Errorf("expected a new checksum after the removal of a file")
+	}
+	if _, ok := checksums[filepath.Join(dir, "opentracing.json")]; ok {
+		t.Errorf("expected the checksum of the removed file to be forgotten")
+	}
+}
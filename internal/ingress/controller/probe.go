@@ -0,0 +1,172 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// timeout of each synthetic request sent through NGINX
+const probeRequestTimeout = 5 * time.Second
+
+// ProbeTarget is a host and a path requested through NGINX by the
+// controller to check the configuration serves it
+type ProbeTarget struct {
+	Host string
+	Path string
+}
+
+// ParseProbeTargets parses a list of hosts separated by commas, each one
+// followed by an optional path, i.e. "example.com,api.example.com/healthz"
+func ParseProbeTargets(value string) ([]ProbeTarget, error) {
+	targets := []ProbeTarget{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		target := ProbeTarget{Host: item, Path: "/"}
+		if i := strings.Index(item, "/"); i != -1 {
+			target.Host, target.Path = item[:i], item[i:]
+		}
+
+		if errs := validation.IsDNS1123Subdomain(target.Host); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid host %q: %v", target.Host, strings.Join(errs, ", "))
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// runningServers returns the servers of the running configuration by host
+func (n *NGINXController) runningServers() map[string]*ingress.Server {
+	servers := map[string]*ingress.Server{}
+	for _, server := range n.RunningConfiguration().Servers {
+		servers[server.Hostname] = server
+	}
+	return servers
+}
+
+// probe sends a synthetic request for the target through the NGINX server
+// of its host, using TLS when the server has a certificate
+func (n *NGINXController) probe(servers map[string]*ingress.Server, target ProbeTarget) error {
+	server, ok := servers[target.Host]
+	if !ok {
+		return fmt.Errorf("no server configured for the host")
+	}
+
+	// the template configures TLS for the servers with a PEM file
+	secure := server.SSLCert.PemFileName != ""
+
+	port := n.cfg.ListenPorts.HTTP
+	if secure {
+		port = n.cfg.ListenPorts.HTTPS
+	}
+
+	return sendProbe(fmt.Sprintf("127.0.0.1:%v", port), target, secure)
+}
+
+// runProber probes the targets every interval until the controller stops,
+// exporting the result and the latency of each request
+func (n *NGINXController) runProber() {
+	failing := map[ProbeTarget]bool{}
+
+	wait.Until(func() {
+		servers := n.runningServers()
+		for _, target := range n.cfg.ProbeTargets {
+			start := time.Now()
+			err := n.probe(servers, target)
+			n.metricCollector.SetProbe(target.Host, target.Path, err == nil, time.Since(start))
+
+			// only the changes are logged to keep the logs readable
+			if err != nil && !failing[target] {
+				klog.Warningf("Probe of %v%v failed: %v", target.Host, target.Path, err)
+			} else if err == nil && failing[target] {
+				klog.Infof("Probe of %v%v succeeded again.", target.Host, target.Path)
+			}
+			failing[target] = err != nil
+		}
+	}, n.cfg.ProbeInterval, n.stopCh)
+}
+
+// sendProbe sends a request for the target to NGINX listening on address.
+// The request fails when the response is a 404 or a server error, or when
+// the certificate is not valid for the host (i.e. the fake certificate).
+func sendProbe(address string, target ProbeTarget, secure bool) error {
+	dialer := &net.Dialer{Timeout: probeRequestTimeout}
+
+	client := &http.Client{
+		Timeout: probeRequestTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			TLSClientConfig: &tls.Config{
+				ServerName: target.Host,
+				// the certificate is verified for the host below, its chain
+				// may not be trusted by the controller
+				InsecureSkipVerify: true,
+			},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+
+	resp, err := client.Get(fmt.Sprintf("%v://%v%v", scheme, target.Host, target.Path))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if secure {
+		if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+			return fmt.Errorf("no certificate presented")
+		}
+
+		if err := resp.TLS.PeerCertificates[0].VerifyHostname(target.Host); err != nil {
+			return err
+		}
+	}
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/metric"
+)
+
+func TestParseProbeTargets(t *testing.T) {
+	targets, err := ParseProbeTargets("example.com, api.example.com/healthz?full=1,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ProbeTarget{
+		{Host: "example.com", Path: "/"},
+		{Host: "api.example.com", Path: "/healthz?full=1"},
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected %v but returned %v", expected, targets)
+	}
+
+	for _, value := range []string{"*.example.com", "Example.com/", "example.com:443"} {
+		if _, err := ParseProbeTargets(value); err == nil {
+			t.Errorf("expected an error parsing %q", value)
+		}
+	}
+}
+
+func TestSendProbe(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	address := strings.TrimPrefix(server.URL, "https://")
+
+	// the certificate of the test server is valid for example.com
+	err := sendProbe(address, ProbeTarget{Host: "example.com", Path: "/"}, true)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = sendProbe(address, ProbeTarget{Host: "example.com", Path: "/missing"}, true)
+	if err == nil {
+		t.Errorf("expected an error for a 404 response")
+	}
+
+	err = sendProbe(address, ProbeTarget{Host: "foo.bar", Path: "/"}, true)
+	if err == nil {
+		t.Errorf("expected an error for a certificate not valid for the host")
+	}
+}
+
+type probeCollector struct {
+	metric.DummyCollector
+	results chan bool
+}
+
+func (pc probeCollector) SetProbe(host, path string, success bool, latency time.Duration) {
+	pc.results <- success
+}
+
+func TestRunProber(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	httpPort, _ := strconv.Atoi(port)
+
+	results := make(chan bool, 2)
+	n := &NGINXController{
+		cfg: &Configuration{
+			ListenPorts:   &ngx_config.ListenPorts{HTTP: httpPort},
+			ProbeTargets:  []ProbeTarget{{Host: "example.com", Path: "/"}, {Host: "foo.bar", Path: "/"}},
+			ProbeInterval: time.Hour,
+		},
+		runningConfig: &ingress.Configuration{
+			Servers: []*ingress.Server{{Hostname: "example.com"}},
+		},
+		runningConfigLock: &sync.RWMutex{},
+		metricCollector:   probeCollector{results: results},
+		stopCh:            make(chan struct{}),
+	}
+
+	go n.runProber()
+	defer close(n.stopCh)
+
+	if success := <-results; !success {
+		t.Errorf("expected a successful probe of example.com")
+	}
+
+	// foo.bar has no server in the running configuration
+	if success := <-results; success {
+		t.Errorf("expected a failed probe of foo.bar")
+	}
+}
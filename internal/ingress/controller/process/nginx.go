@@ -29,8 +29,17 @@ import (
 	"k8s.io/klog"
 )
 
-// IsRespawnIfRequired checks if error type is exec.ExitError or not
+// IsRespawnIfRequired checks if error type is exec.ExitError or MasterExitedError
 func IsRespawnIfRequired(err error) bool {
+	if exited, ok := err.(*MasterExitedError); ok {
+		klog.Warningf(`
+-------------------------------------------------------------------------------
+NGINX master process died: %v
+-------------------------------------------------------------------------------
+`, exited)
+		return true
+	}
+
 	exitError, ok := err.(*exec.ExitError)
 	if !ok {
 		return false
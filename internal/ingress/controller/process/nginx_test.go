@@ -33,6 +33,7 @@ func TestIsRespawnIfRequired(t *testing.T) {
 		{&exec.ExitError{
 			ProcessState: &os.ProcessState{},
 		}, true},
+		{&MasterExitedError{PID: 1}, true},
 	}
 
 	for _, tc := range cases {
@@ -0,0 +1,75 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package process
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// MasterExitedError is returned when a NGINX master process not started
+// by the controller, after a binary upgrade, exits
+type MasterExitedError struct {
+	PID int
+}
+
+func (e *MasterExitedError) Error() string {
+	return fmt.Sprintf("NGINX master process %v exited", e.PID)
+}
+
+// ReadPID returns the process ID contained in a pid file
+func ReadPID(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid pid file %v: %v", path, err)
+	}
+
+	return pid, nil
+}
+
+// IsProcessRunning returns true if a process with the given ID exists
+func IsProcessRunning(pid int) bool {
+	err := syscall.Kill(pid, syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+// WaitForExit waits until the process exits, returning a MasterExitedError,
+// or until stopCh is closed, returning nil
+func WaitForExit(pid int, interval time.Duration, stopCh <-chan struct{}) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !IsProcessRunning(pid) {
+				return &MasterExitedError{PID: pid}
+			}
+		case <-stopCh:
+			return nil
+		}
+	}
+}
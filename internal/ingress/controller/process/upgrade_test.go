@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadPID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pid")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "nginx.pid")
+
+	if _, err := ReadPID(path); err == nil {
+		t.Errorf("expected an error reading a missing pid file")
+	}
+
+	err = ioutil.WriteFile(path, []byte("1234\n"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pid, err := ReadPID(path)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if pid != 1234 {
+		t.Errorf("expected pid 1234 but returned %v", pid)
+	}
+
+	err = ioutil.WriteFile(path, []byte("nginx"), 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := ReadPID(path); err == nil {
+		t.Errorf("expected an error reading an invalid pid file")
+	}
+}
+
+func TestWaitForExit(t *testing.T) {
+	if !IsProcessRunning(os.Getpid()) {
+		t.Fatalf("expected the current process to be running")
+	}
+
+	cmd := exec.Command("sleep", "0.1")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("unable to start a process: %v", err)
+	}
+
+	pid := cmd.Process.Pid
+	go cmd.Wait()
+
+	err := WaitForExit(pid, 10*time.Millisecond, make(chan struct{}))
+	exited, ok := err.(*MasterExitedError)
+	if !ok {
+		t.Fatalf("expected a MasterExitedError but returned %v", err)
+	}
+	if exited.PID != pid {
+		t.Errorf("expected pid %v but returned %v", pid, exited.PID)
+	}
+
+	stopCh := make(chan struct{})
+	close(stopCh)
+
+	err = WaitForExit(os.Getpid(), time.Hour, stopCh)
+	if err != nil {
+		t.Errorf("expected no error after stop but returned %v", err)
+	}
+}
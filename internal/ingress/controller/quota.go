@@ -0,0 +1,172 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+// quota contains the limits of the configuration size, zero is unlimited
+type quota struct {
+	hostsPerNamespace   int
+	locationsPerIngress int
+	snippetLength       int
+	serverBlocks        int
+}
+
+func newQuota(cfg ngx_config.Configuration) quota {
+	return quota{
+		hostsPerNamespace:   cfg.MaxHostsPerNamespace,
+		locationsPerIngress: cfg.MaxLocationsPerIngress,
+		snippetLength:       cfg.MaxSnippetLength,
+		serverBlocks:        cfg.MaxServerBlocks,
+	}
+}
+
+func (q quota) unlimited() bool {
+	return q.hostsPerNamespace <= 0 && q.locationsPerIngress <= 0 && q.snippetLength <= 0 && q.serverBlocks <= 0
+}
+
+// applyQuota returns the Ingresses within the limits of the quota. The
+// Ingresses are evaluated by creation time, so the oldest hosts are kept
+// when a limit is reached.
+func applyQuota(ingresses []*ingress.Ingress, q quota) []*ingress.Ingress {
+	if q.unlimited() {
+		return ingresses
+	}
+
+	accepted, rejected := evaluateQuota(ingresses, q)
+	for key, reason := range rejected {
+		klog.Warningf("Ignoring Ingress %v: %v", key, reason)
+	}
+
+	return accepted
+}
+
+// checkQuota returns an error if the Ingress is not within the limits of the
+// quota once added to the other Ingresses
+func checkQuota(ing *ingress.Ingress, others []*ingress.Ingress, q quota) error {
+	if q.unlimited() {
+		return nil
+	}
+
+	ingresses := append([]*ingress.Ingress{}, others...)
+	ingresses = append(ingresses, ing)
+
+	// a new Ingress does not have a creation time yet and is the newest
+	sort.SliceStable(ingresses, func(i, j int) bool {
+		it := ingresses[i].CreationTimestamp
+		jt := ingresses[j].CreationTimestamp
+		if it.IsZero() || jt.IsZero() {
+			return !it.IsZero() && jt.IsZero()
+		}
+		return it.Before(&jt)
+	})
+
+	_, rejected := evaluateQuota(ingresses, q)
+	if reason, ok := rejected[k8s.MetaNamespaceKey(ing)]; ok {
+		return fmt.Errorf("the Ingress exceeds the quota of the controller: %v", reason)
+	}
+
+	return nil
+}
+
+// evaluateQuota returns the accepted Ingresses and the reason of the
+// rejection of the others by key
+func evaluateQuota(ingresses []*ingress.Ingress, q quota) ([]*ingress.Ingress, map[string]string) {
+	accepted := make([]*ingress.Ingress, 0, len(ingresses))
+	rejected := map[string]string{}
+
+	servers := sets.NewString()
+	namespaceHosts := map[string]sets.String{}
+
+	for _, ing := range ingresses {
+		reason := ""
+
+		locations := 0
+		hosts := sets.NewString()
+		for _, rule := range ing.Spec.Rules {
+			// the catch-all server is shared by all the namespaces
+			if rule.Host != "" {
+				hosts.Insert(rule.Host)
+			}
+
+			if rule.HTTP != nil {
+				locations += len(rule.HTTP.Paths)
+			}
+		}
+
+		nsHosts, ok := namespaceHosts[ing.Namespace]
+		if !ok {
+			nsHosts = sets.NewString()
+			namespaceHosts[ing.Namespace] = nsHosts
+		}
+
+		newHosts := hosts.Difference(nsHosts)
+		newServers := hosts.Difference(servers)
+
+		switch {
+		case q.locationsPerIngress > 0 && locations > q.locationsPerIngress:
+			reason = fmt.Sprintf("%v paths, the maximum is %v", locations, q.locationsPerIngress)
+		case q.snippetLength > 0 && longestSnippet(ing) > q.snippetLength:
+			reason = fmt.Sprintf("snippet of %v characters, the maximum is %v", longestSnippet(ing), q.snippetLength)
+		case q.hostsPerNamespace > 0 && nsHosts.Len()+newHosts.Len() > q.hostsPerNamespace:
+			reason = fmt.Sprintf("namespace %v would define %v hosts, the maximum is %v",
+				ing.Namespace, nsHosts.Len()+newHosts.Len(), q.hostsPerNamespace)
+		case q.serverBlocks > 0 && servers.Len()+newServers.Len() > q.serverBlocks:
+			reason = fmt.Sprintf("the configuration would contain %v servers, the maximum is %v",
+				servers.Len()+newServers.Len(), q.serverBlocks)
+		}
+
+		if reason != "" {
+			rejected[k8s.MetaNamespaceKey(ing)] = reason
+			continue
+		}
+
+		nsHosts.Insert(newHosts.UnsortedList()...)
+		servers.Insert(newServers.UnsortedList()...)
+		accepted = append(accepted, ing)
+	}
+
+	return accepted, rejected
+}
+
+// longestSnippet returns the length of the longest snippet annotation
+func longestSnippet(ing *ingress.Ingress) int {
+	longest := 0
+	for name, value := range ing.Annotations {
+		if !strings.HasPrefix(name, parser.AnnotationsPrefix+"/") || !strings.HasSuffix(name, "-snippet") {
+			continue
+		}
+
+		if len(value) > longest {
+			longest = len(value)
+		}
+	}
+
+	return longest
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+func newQuotaIngress(namespace, name string, created time.Time, hosts ...string) *ingress.Ingress {
+	rules := []networking.IngressRule{}
+	for _, host := range hosts {
+		rules = append(rules, networking.IngressRule{
+			Host: host,
+			IngressRuleValue: networking.IngressRuleValue{
+				HTTP: &networking.HTTPIngressRuleValue{
+					Paths: []networking.HTTPIngressPath{
+						{Path: "/", Backend: networking.IngressBackend{ServiceName: "http-svc"}},
+						{Path: "/api", Backend: networking.IngressBackend{ServiceName: "http-svc"}},
+					},
+				},
+			},
+		})
+	}
+
+	ts := metav1.Time{}
+	if !created.IsZero() {
+		ts = metav1.NewTime(created)
+	}
+
+	return &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespace,
+				CreationTimestamp: ts,
+			},
+			Spec: networking.IngressSpec{Rules: rules},
+		},
+	}
+}
+
+func ingressNames(ings []*ingress.Ingress) string {
+	names := []string{}
+	for _, ing := range ings {
+		names = append(names, ing.Namespace+"/"+ing.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestApplyQuota(t *testing.T) {
+	now := time.Now()
+
+	snippet := newQuotaIngress("team-a", "snippet", now, "snippet.example.com")
+	snippet.Annotations = map[string]string{
+		"nginx.ingress.kubernetes.io/configuration-snippet": strings.Repeat("#", 100),
+	}
+
+	ingresses := []*ingress.Ingress{
+		newQuotaIngress("team-a", "first", now.Add(-3*time.Hour), "a.example.com", "b.example.com"),
+		// redefines a host of the namespace
+		newQuotaIngress("team-a", "same-host", now.Add(-2*time.Hour), "a.example.com"),
+		newQuotaIngress("team-a", "third-host", now.Add(-1*time.Hour), "c.example.com"),
+		newQuotaIngress("team-b", "other", now.Add(-1*time.Hour), "d.example.com"),
+		newQuotaIngress("team-b", "catch-all", now, ""),
+		newQuotaIngress("team-c", "paths", now, "e.example.com", "f.example.com", "g.example.com"),
+		snippet,
+	}
+
+	testCases := []struct {
+		name     string
+		quota    quota
+		expected string
+	}{
+		{
+			"unlimited",
+			quota{},
+			"team-a/first,team-a/same-host,team-a/third-host,team-b/other,team-b/catch-all,team-c/paths,team-a/snippet",
+		},
+		{
+			"hosts per namespace",
+			quota{hostsPerNamespace: 2},
+			"team-a/first,team-a/same-host,team-b/other,team-b/catch-all",
+		},
+		{
+			"locations per Ingress",
+			quota{locationsPerIngress: 4},
+			"team-a/first,team-a/same-host,team-a/third-host,team-b/other,team-b/catch-all,team-a/snippet",
+		},
+		{
+			"snippet length",
+			quota{snippetLength: 50},
+			"team-a/first,team-a/same-host,team-a/third-host,team-b/other,team-b/catch-all,team-c/paths",
+		},
+		{
+			"server blocks",
+			quota{serverBlocks: 4},
+			"team-a/first,team-a/same-host,team-a/third-host,team-b/other,team-b/catch-all",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			accepted := ingressNames(applyQuota(ingresses, tc.quota))
+			if accepted != tc.expected {
+				t.Errorf("expected %v but returned %v", tc.expected, accepted)
+			}
+		})
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	now := time.Now()
+	q := quota{hostsPerNamespace: 2}
+
+	others := []*ingress.Ingress{
+		newQuotaIngress("team-a", "first", now.Add(-2*time.Hour), "a.example.com"),
+		newQuotaIngress("team-a", "second", now.Add(-1*time.Hour), "b.example.com"),
+	}
+
+	// a new Ingress is evaluated after the existing ones
+	err := checkQuota(newQuotaIngress("team-a", "new", time.Time{}, "c.example.com"), others, q)
+	if err == nil {
+		t.Errorf("expected an error for a third host in the namespace")
+	}
+
+	err = checkQuota(newQuotaIngress("team-a", "new", time.Time{}, "a.example.com"), others, q)
+	if err != nil {
+		t.Errorf("unexpected error for a host of the namespace: %v", err)
+	}
+
+	err = checkQuota(newQuotaIngress("team-b", "new", time.Time{}, "c.example.com"), others, q)
+	if err != nil {
+		t.Errorf("unexpected error for another namespace: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/file"
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// minStaleSSLFileAge protects the files written by a synchronization of a
+// secret not finished yet, the certificate is added to the store after
+const minStaleSSLFileAge = 5 * time.Minute
+
+// collectSSLFiles removes the stale PEM files of the SSL directory after the
+// initial synchronization and then every interval, until the channel is closed
+func (n *NGINXController) collectSSLFiles(stopCh <-chan struct{}) {
+	// the certificates of the store are only complete after the initial synchronization
+	err := wait.PollImmediateUntil(time.Second, func() (bool, error) {
+		return !n.RunningConfiguration().Equal(&ingress.Configuration{}), nil
+	}, stopCh)
+	if err != nil {
+		return
+	}
+
+	wait.Until(func() {
+		n.removeStaleSSLFiles(time.Now())
+	}, n.cfg.SSLFilesGCInterval, stopCh)
+}
+
+// removeStaleSSLFiles removes the PEM files of the SSL directory no
+// certificate uses anymore, left behind by deleted or renamed secrets, and
+// returns their paths. With SSLFilesGCDryRun the files are only logged.
+func (n *NGINXController) removeStaleSSLFiles(now time.Time) []string {
+	infos, err := n.fileSystem.ReadDir(file.DefaultSSLDirectory)
+	if err != nil {
+		klog.Warningf("Error reading the SSL directory %v: %v", file.DefaultSSLDirectory, err)
+		return nil
+	}
+
+	used := n.usedSSLFiles()
+
+	stale := []string{}
+	for _, info := range infos {
+		if info.IsDir() || !isPemFile(info.Name()) {
+			continue
+		}
+
+		path := filepath.Join(file.DefaultSSLDirectory, info.Name())
+		if used.Has(path) || now.Sub(info.ModTime()) < minStaleSSLFileAge {
+			continue
+		}
+
+		stale = append(stale, path)
+	}
+
+	if len(stale) == 0 {
+		return stale
+	}
+
+	if n.cfg.SSLFilesGCDryRun {
+		klog.Infof("Stale SSL files (dry run, not removed): %v", stale)
+		n.metricCollector.AddSSLStaleFiles("dry_run", len(stale))
+		return stale
+	}
+
+	removed := []string{}
+	for _, path := range stale {
+		err := n.fileSystem.Remove(path)
+		if err != nil {
+			klog.Warningf("Error removing the stale SSL file %v: %v", path, err)
+			continue
+		}
+
+		removed = append(removed, path)
+	}
+
+	klog.Infof("Removed stale SSL files: %v", removed)
+	n.metricCollector.AddSSLStaleFiles("removed", len(removed))
+
+	return removed
+}
+
+// usedSSLFiles returns the paths of the files of the certificates of the
+// store and of the certificates and CAs of the running configuration
+func (n *NGINXController) usedSSLFiles() sets.String {
+	used := sets.NewString()
+	add := func(paths ...string) {
+		for _, path := range paths {
+			if path != "" {
+				used.Insert(filepath.Clean(path))
+			}
+		}
+	}
+	addCert := func(cert *ingress.SSLCert) {
+		add(cert.PemFileName, cert.CAFileName, cert.PemCertKeyFileName, cert.OCSPResponseFile)
+		if cert.ECDSA != nil {
+			add(cert.ECDSA.PemFileName, cert.ECDSA.PemCertKeyFileName, cert.ECDSA.OCSPResponseFile)
+		}
+	}
+
+	if n.cfg.FakeCertificate != nil {
+		addCert(n.cfg.FakeCertificate)
+	}
+
+	for _, cert := range n.store.ListLocalSSLCerts() {
+		addCert(cert)
+	}
+
+	pcfg := n.RunningConfiguration()
+	for _, server := range pcfg.Servers {
+		addCert(&server.SSLCert)
+		add(server.CertificateAuth.CAFileName)
+		for _, listener := range server.Listeners {
+			add(listener.CertificateAuth.CAFileName)
+		}
+
+		for _, location := range server.Locations {
+			add(location.ProxySSL.CACert.CAFileName)
+		}
+	}
+
+	for _, backend := range pcfg.Backends {
+		add(backend.SecureCACert.CAFileName)
+	}
+
+	// the file of the DH parameters is written when the configuration is rendered
+	if dhParam := n.store.GetBackendConfiguration().SSLDHParam; dhParam != "" {
+		add(fmt.Sprintf("%v/%v.pem", file.DefaultSSLDirectory, strings.Replace(dhParam, "/", "-", -1)))
+	}
+
+	return used
+}
+
+// pemFileRegex matches the certificates, keys, CAs and OCSP responses
+// written to the SSL directory, encrypted or not, and their temporary files
+// left behind by an interrupted write
+var pemFileRegex = regexp.MustCompile(`\.(pem(\.enc)?|ocsp)[0-9]*$`)
+
+func isPemFile(name string) bool {
+	return pemFileRegex.MatchString(name)
+}
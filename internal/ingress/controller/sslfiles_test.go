@@ -0,0 +1,167 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/ingress-nginx/internal/file"
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/secureupstream"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/metric"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+)
+
+func sslFile(name string) string {
+	return fmt.Sprintf("%v/%v", file.DefaultSSLDirectory, name)
+}
+
+func newSSLFilesController(t *testing.T, dryRun bool) *NGINXController {
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{
+		"default-fake-certificate.pem",
+		"default-foo.pem",
+		"default-foo.pem.enc",
+		"default-foo.ocsp",
+		"default-deleted.pem",
+		"default-deleted.ocsp",
+		"ca-default-deleted.pem",
+		"ca-default-auth.pem",
+		"ca-configmap-default-upstream.pem",
+		"default-dhparam.pem",
+		"default-renamed.pem.enc",
+		"default-foo.pem.enc123456",
+		"readme.txt",
+	} {
+		f, err := fs.Create(sslFile(name))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		f.Close()
+
+		err = fs.Chtimes(sslFile(name), old, old)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// written by a synchronization not finished yet
+	f, err := fs.Create(sslFile("default-new.pem"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Close()
+
+	s := fakeCertificateStore{
+		fakeIngressStore: fakeIngressStore{
+			configuration: ngx_config.Configuration{SSLDHParam: "default/dhparam"},
+		},
+		certs: []*ingress.SSLCert{
+			{PemCertKeyFileName: sslFile("default-foo.pem.enc"), PemFileName: sslFile("default-foo.pem"), OCSPResponseFile: sslFile("default-foo.ocsp")},
+		},
+	}
+
+	return &NGINXController{
+		cfg: &Configuration{
+			FakeCertificate:  &ingress.SSLCert{PemFileName: sslFile("default-fake-certificate.pem")},
+			SSLFilesGCDryRun: dryRun,
+		},
+		store:           s,
+		fileSystem:      fs,
+		metricCollector: metric.DummyCollector{},
+		runningConfig: &ingress.Configuration{
+			Servers: []*ingress.Server{
+				{
+					Hostname: "foo.bar",
+					CertificateAuth: authtls.Config{
+						AuthSSLCert: resolver.AuthSSLCert{CAFileName: sslFile("ca-default-auth.pem")},
+					},
+					Locations: []*ingress.Location{
+						{
+							Path: "/",
+							ProxySSL: secureupstream.Config{
+								CACert: resolver.AuthSSLCert{CAFileName: sslFile("ca-configmap-default-upstream.pem")},
+							},
+						},
+					},
+				},
+			},
+		},
+		runningConfigLock: &sync.RWMutex{},
+	}
+}
+
+func TestRemoveStaleSSLFiles(t *testing.T) {
+	n := newSSLFilesController(t, false)
+
+	removed := n.removeStaleSSLFiles(time.Now())
+	sort.Strings(removed)
+
+	expected := []string{
+		sslFile("ca-default-deleted.pem"),
+		sslFile("default-deleted.ocsp"),
+		sslFile("default-deleted.pem"),
+		sslFile("default-foo.pem.enc123456"),
+		sslFile("default-renamed.pem.enc"),
+	}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("expected the removal of %v but returned %v", expected, removed)
+	}
+
+	for _, path := range expected {
+		if _, err := n.fileSystem.Stat(path); err == nil {
+			t.Errorf("expected the file %v to be removed", path)
+		}
+	}
+
+	for _, name := range []string{"default-foo.pem", "default-foo.ocsp", "default-new.pem", "default-dhparam.pem", "readme.txt"} {
+		if _, err := n.fileSystem.Stat(sslFile(name)); err != nil {
+			t.Errorf("expected the file %v to be kept: %v", name, err)
+		}
+	}
+
+	if removed := n.removeStaleSSLFiles(time.Now()); len(removed) != 0 {
+		t.Errorf("expected no stale files after the removal but returned %v", removed)
+	}
+}
+
+func TestRemoveStaleSSLFilesDryRun(t *testing.T) {
+	n := newSSLFilesController(t, true)
+
+	stale := n.removeStaleSSLFiles(time.Now())
+	if len(stale) != 5 {
+		t.Fatalf("expected 5 stale files but returned %v", stale)
+	}
+
+	for _, path := range stale {
+		if _, err := n.fileSystem.Stat(path); err != nil {
+			t.Errorf("expected the file %v to be kept in dry run: %v", path, err)
+		}
+	}
+}
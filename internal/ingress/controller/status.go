@@ -19,6 +19,8 @@ package controller
 import (
 	"context"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"k8s.io/klog"
@@ -30,8 +32,19 @@ import (
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/client-go/tools/record"
+
+	"k8s.io/ingress-nginx/internal/logging"
 )
 
+// leaderTask is work done only by the leader, so a single pod updates the
+// objects shared by the replicas (i.e. the status of the Ingresses)
+type leaderTask struct {
+	Name string
+	// Run is started when the pod becomes the leader and must return
+	// when stopCh is closed
+	Run func(stopCh chan struct{})
+}
+
 type leaderElectionConfig struct {
 	PodName      string
 	PodNamespace string
@@ -40,17 +53,59 @@ type leaderElectionConfig struct {
 
 	ElectionID string
 
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+
+	Tasks []leaderTask
+
 	OnStartedLeading func(chan struct{})
 	OnStoppedLeading func()
+
+	// OnTaskStatus is called when a task starts and when it returns
+	OnTaskStatus func(task string, running bool)
 }
 
-func setupLeaderElection(config *leaderElectionConfig) {
+// runLeaderTasks starts the tasks of the leader, stopped when stopCh is closed
+func runLeaderTasks(config *leaderElectionConfig, stopCh chan struct{}, running *sync.WaitGroup) {
+	for _, t := range config.Tasks {
+		running.Add(1)
+		go func(t leaderTask) {
+			defer running.Done()
+
+			logging.V(2).Infof("Starting leader task %v", t.Name)
+			if config.OnTaskStatus != nil {
+				config.OnTaskStatus(t.Name, true)
+			}
+
+			t.Run(stopCh)
+
+			logging.V(2).Infof("Leader task %v stopped", t.Name)
+			if config.OnTaskStatus != nil {
+				config.OnTaskStatus(t.Name, false)
+			}
+		}(t)
+	}
+}
+
+// setupLeaderElection starts the leader election. The returned function stops
+// the tasks and then releases the lock, so another pod takes over the tasks
+// without waiting for the lease to expire (i.e. during rolling upgrades).
+func setupLeaderElection(config *leaderElectionConfig) func() {
 	var elector *leaderelection.LeaderElector
 
-	// start a new context
-	ctx := context.Background()
+	// start a new context, canceled when the pod stops participating in the election
+	ctx, stop := context.WithCancel(context.Background())
+
+	// closed to stop the tasks before releasing the lock
+	shutdownCh := make(chan struct{})
+	// closed when the elector returned after releasing the lock
+	stoppedCh := make(chan struct{})
+
+	var running sync.WaitGroup
 
 	var cancelContext context.CancelFunc
+	var cancelLock sync.Mutex
 
 	var newLeaderCtx = func(ctx context.Context) context.CancelFunc {
 		// allow to cancel the context in case we stop being the leader
@@ -59,28 +114,53 @@ func setupLeaderElection(config *leaderElectionConfig) {
 		return cancel
 	}
 
-	var stopCh chan struct{}
 	callbacks := leaderelection.LeaderCallbacks{
-		OnStartedLeading: func(ctx context.Context) {
-			klog.V(2).Infof("I am the new leader")
-			stopCh = make(chan struct{})
+		OnStartedLeading: func(leaderCtx context.Context) {
+			logging.V(2).Infof("I am the new leader")
+
+			select {
+			case <-shutdownCh:
+				// the tasks are not started while the election is stopped
+				return
+			default:
+			}
+
+			// the tasks are stopped as soon as the lease is lost or before it is released
+			stopCh := make(chan struct{})
+			go func() {
+				select {
+				case <-leaderCtx.Done():
+				case <-shutdownCh:
+				}
+				close(stopCh)
+			}()
 
 			if config.OnStartedLeading != nil {
 				config.OnStartedLeading(stopCh)
 			}
+
+			runLeaderTasks(config, stopCh, &running)
 		},
 		OnStoppedLeading: func() {
-			klog.V(2).Info("I am not leader anymore")
-			close(stopCh)
+			logging.V(2).Info("I am not leader anymore")
+
+			if config.OnStoppedLeading != nil {
+				config.OnStoppedLeading()
+			}
+
+			cancelLock.Lock()
+			defer cancelLock.Unlock()
 
 			// cancel the context
 			cancelContext()
 
-			cancelContext = newLeaderCtx(ctx)
-
-			if config.OnStoppedLeading != nil {
-				config.OnStoppedLeading()
+			if ctx.Err() != nil {
+				// the election was stopped
+				close(stoppedCh)
+				return
 			}
+
+			cancelContext = newLeaderCtx(ctx)
 		},
 		OnNewLeader: func(identity string) {
 			klog.Infof("new leader elected: %v", identity)
@@ -104,14 +184,15 @@ func setupLeaderElection(config *leaderElectionConfig) {
 		},
 	}
 
-	ttl := 30 * time.Second
 	var err error
 
 	elector, err = leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
 		Lock:          &lock,
-		LeaseDuration: ttl,
-		RenewDeadline: ttl / 2,
-		RetryPeriod:   ttl / 4,
+		LeaseDuration: config.LeaseDuration,
+		RenewDeadline: config.RenewDeadline,
+		RetryPeriod:   config.RetryPeriod,
+
+		ReleaseOnCancel: true,
 
 		Callbacks: callbacks,
 	})
@@ -119,5 +200,57 @@ func setupLeaderElection(config *leaderElectionConfig) {
 		klog.Fatalf("unexpected error starting leader election: %v", err)
 	}
 
+	cancelLock.Lock()
 	cancelContext = newLeaderCtx(ctx)
+	cancelLock.Unlock()
+
+	return func() {
+		timeout := time.After(config.RenewDeadline)
+
+		close(shutdownCh)
+		tasksDone := make(chan struct{})
+		go func() {
+			running.Wait()
+			close(tasksDone)
+		}()
+
+		select {
+		case <-tasksDone:
+		case <-timeout:
+			klog.Warningf("Timeout waiting for the leader tasks to stop")
+		}
+
+		stop()
+
+		select {
+		case <-stoppedCh:
+		case <-timeout:
+			klog.Warningf("Timeout releasing the leader election lock")
+		}
+	}
+}
+
+// leaderTasks returns the work of the controller done only by the leader
+func (n *NGINXController) leaderTasks() []leaderTask {
+	tasks := []leaderTask{}
+
+	if n.syncStatus != nil {
+		tasks = append(tasks, leaderTask{
+			Name: "ingress-status",
+			Run:  n.syncStatus.Run,
+		})
+	}
+
+	if n.cfg.NamespaceConfigMap != "" {
+		tasks = append(tasks, leaderTask{
+			Name: "namespace-configmaps",
+			Run: func(stopCh chan struct{}) {
+				atomic.StoreInt32(&n.isLeader, 1)
+				<-stopCh
+				atomic.StoreInt32(&n.isLeader, 0)
+			},
+		})
+	}
+
+	return tasks
 }
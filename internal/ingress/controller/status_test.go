@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+func TestLeaderElectionTasks(t *testing.T) {
+	client := fake.NewSimpleClientset()
+
+	var lock sync.Mutex
+	status := map[string]bool{}
+	started := make(chan struct{})
+
+	stop := setupLeaderElection(&leaderElectionConfig{
+		Client:        client,
+		ElectionID:    "ingress-controller-leader-nginx",
+		PodName:       "pod-1",
+		PodNamespace:  "ingress-nginx",
+		LeaseDuration: time.Second,
+		RenewDeadline: 500 * time.Millisecond,
+		RetryPeriod:   100 * time.Millisecond,
+		Tasks: []leaderTask{
+			{
+				Name: "test",
+				Run: func(stopCh chan struct{}) {
+					close(started)
+					<-stopCh
+				},
+			},
+		},
+		OnTaskStatus: func(task string, running bool) {
+			lock.Lock()
+			defer lock.Unlock()
+			status[task] = running
+		},
+	})
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the leader task to be started")
+	}
+
+	stop()
+
+	lock.Lock()
+	running, ok := status["test"]
+	lock.Unlock()
+	if !ok || running {
+		t.Errorf("expected the leader task to be stopped before the lock is released")
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("ingress-nginx").Get("ingress-controller-leader-nginx", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error reading the lock: %v", err)
+	}
+
+	record := resourcelock.LeaderElectionRecord{}
+	err = json.Unmarshal([]byte(cm.Annotations[resourcelock.LeaderElectionRecordAnnotationKey]), &record)
+	if err != nil {
+		t.Fatalf("unexpected error decoding the lock: %v", err)
+	}
+	if record.HolderIdentity != "" {
+		t.Errorf("expected the lock to be released but it is held by %v", record.HolderIdentity)
+	}
+}
@@ -19,6 +19,7 @@ package store
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"k8s.io/klog"
 
@@ -26,20 +27,38 @@ import (
 	networking "k8s.io/api/networking/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
+	"k8s.io/ingress-nginx/internal/logging"
 	"k8s.io/ingress-nginx/internal/net/ssl"
 )
 
+// interval between two verifications of the files of the certificates
+const sslIntegrityCheckInterval = 5 * time.Minute
+
+// interval between two checks of the OCSP responses to fetch again
+const ocspRefreshCheckInterval = 5 * time.Minute
+
+// newSSLCertStore returns the store of the certificates and keys. The dynamic
+// certificates are kept in memory, unless they must be encrypted on disk.
+func newSSLCertStore(fs file.Filesystem) ssl.SSLCertStore {
+	if ngx_config.EnableDynamicCertificates && !ssl.PemEncryptionEnabled() {
+		return ssl.NewMemorySSLCertStore(fs)
+	}
+
+	return ssl.NewDiskSSLCertStore(fs)
+}
+
 // syncSecret synchronizes the content of a TLS Secret (certificate(s), secret
-// key) with the filesystem. The resulting files can be used by NGINX.
+// key) with the store of the certificates. The resulting files can be used by NGINX.
 func (s *k8sStore) syncSecret(key string) {
 	s.syncSecretMu.Lock()
 	defer s.syncSecretMu.Unlock()
 
-	klog.V(3).Infof("Syncing Secret %q", key)
+	logging.V(3).Infof("Syncing Secret %q", key)
 
-	// TODO: getPemCertificate should not write to disk to avoid unnecessary overhead
 	cert, err := s.getPemCertificate(key)
 	if err != nil {
 		if !isErrSecretForAuth(err) {
@@ -57,6 +76,7 @@ func (s *k8sStore) syncSecret(key string) {
 		}
 		klog.Infof("Updating Secret %q in the local store", key)
 		s.sslStore.Update(key, cert)
+		s.requestOCSPRefresh(cert)
 		// this update must trigger an update
 		// (like an update event from a change in Ingress)
 		s.sendDummyEvent()
@@ -65,15 +85,123 @@ func (s *k8sStore) syncSecret(key string) {
 
 	klog.Infof("Adding Secret %q to the local store", key)
 	s.sslStore.Add(key, cert)
+	s.requestOCSPRefresh(cert)
 	// this update must trigger an update
 	// (like an update event from a change in Ingress)
 	s.sendDummyEvent()
 }
 
+// verifySSLCertificates writes again the files of the certificates modified
+// or removed since they were written
+func (s *k8sStore) verifySSLCertificates() {
+	for _, key := range s.sslStore.ListKeys() {
+		// the files are not verified while they are written
+		var err error
+		s.syncSecretMu.Lock()
+		if cert, exists := s.sslStore.Get(key); exists {
+			err = ssl.VerifyPemFiles(s.filesystem, cert.(*ingress.SSLCert))
+		}
+		s.syncSecretMu.Unlock()
+
+		if err != nil {
+			klog.Warningf("Integrity verification of the files of Secret %q failed, writing them again: %v", key, err)
+			s.syncSecret(key)
+		}
+	}
+}
+
+// runOCSPRefresh fetches the OCSP responses every ocspRefreshCheckInterval
+// or when a Secret whose response must be fetched is synced
+func (s *k8sStore) runOCSPRefresh(stopCh chan struct{}) {
+	ticker := time.NewTicker(ocspRefreshCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.refreshOCSPResponses()
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		case <-s.ocspRefreshCh:
+		}
+	}
+}
+
+// refreshOCSPResponses fetches the OCSP responses of the certificates which
+// must be fetched again before they expire. The responders are contacted
+// without holding syncSecretMu.
+func (s *k8sStore) refreshOCSPResponses() {
+	now := time.Now()
+	for _, key := range s.sslStore.ListKeys() {
+		var cur *ingress.SSLCert
+		s.syncSecretMu.Lock()
+		if cert, exists := s.sslStore.Get(key); exists && ssl.NeedsOCSPRefresh(cert.(*ingress.SSLCert), now) {
+			cur = cert.(*ingress.SSLCert)
+		}
+		s.syncSecretMu.Unlock()
+
+		if cur == nil {
+			continue
+		}
+
+		logging.V(3).Infof("Fetching the OCSP response of Secret %q", key)
+
+		cert := *cur
+		err := ssl.UpdateOCSPResponse(s.filesystem, &cert)
+		if err != nil {
+			klog.Warningf("Error fetching the OCSP response of Secret %q (CN: %v), fetching it again in a few minutes: %v", key, cert.CN, err)
+		}
+
+		s.syncSecretMu.Lock()
+		s.updateOCSPResponse(key, cur, &cert)
+		s.syncSecretMu.Unlock()
+	}
+}
+
+// updateOCSPResponse replaces cur with cert, containing the OCSP response
+// fetched again, unless the Secret was synced in the meantime
+func (s *k8sStore) updateOCSPResponse(key string, cur, cert *ingress.SSLCert) {
+	stored, exists := s.sslStore.Get(key)
+	if !exists || stored.(*ingress.SSLCert) != cur {
+		return
+	}
+
+	// namespace/secretName -> namespace-secretName
+	nsSecName := strings.Replace(key, "/", "-", -1)
+	err := s.sslCertStore.StoreOCSPResponse(nsSecName, cert)
+	if err != nil {
+		klog.Warningf("Error storing the OCSP response of Secret %q: %v", key, err)
+		return
+	}
+
+	s.sslStore.Update(key, cert)
+
+	if cert.OCSPResponseSHA != cur.OCSPResponseSHA || cert.OCSPResponseFile != cur.OCSPResponseFile {
+		klog.Infof("Updating the OCSP response of Secret %q", key)
+		// a new response must trigger an update, the revision incremented by
+		// sendDummyEvent keeps the synchronization from being skipped
+		s.sendDummyEvent()
+	}
+}
+
+// requestOCSPRefresh wakes up the refresh of the OCSP responses if the
+// response of cert must be fetched
+func (s *k8sStore) requestOCSPRefresh(cert *ingress.SSLCert) {
+	if !ngx_config.EnableOCSPStapling || !ssl.NeedsOCSPRefresh(cert, time.Now()) {
+		return
+	}
+
+	select {
+	case s.ocspRefreshCh <- struct{}{}:
+	default:
+	}
+}
+
 // getPemCertificate receives a secret, and creates a ingress.SSLCert as return.
 // It parses the secret and verifies if it's a keypair, or a 'ca.crt' secret only.
 func (s *k8sStore) getPemCertificate(secretName string) (*ingress.SSLCert, error) {
-	secret, err := s.listers.Secret.ByKey(secretName)
+	secret, err := s.GetSecret(secretName)
 	if err != nil {
 		return nil, err
 	}
@@ -96,30 +224,29 @@ func (s *k8sStore) getPemCertificate(secretName string) (*ingress.SSLCert, error
 			return nil, fmt.Errorf("key 'tls.key' missing from Secret %q", secretName)
 		}
 
-		sslCert, err = ssl.CreateSSLCert(cert, key)
+		sslCert, err = ssl.CreateTLSSecretCert(secret.Data)
 		if err != nil {
 			return nil, fmt.Errorf("unexpected error creating SSL Cert: %v", err)
 		}
 
-		if !ngx_config.EnableDynamicCertificates || len(ca) > 0 {
-			err = ssl.StoreSSLCertOnDisk(s.filesystem, nsSecName, sslCert)
-			if err != nil {
-				return nil, fmt.Errorf("error while storing certificate and key: %v", err)
-			}
+		if cur, err := s.GetLocalSSLCert(secretName); err == nil {
+			// the OCSP response is fetched by refreshOCSPResponses
+			ssl.CopyOCSPResponse(sslCert, cur)
 		}
 
-		if len(ca) > 0 {
-			err = ssl.ConfigureCACertWithCertAndKey(s.filesystem, nsSecName, ca, sslCert)
-			if err != nil {
-				return nil, fmt.Errorf("error configuring CA certificate: %v", err)
-			}
+		err = s.sslCertStore.Store(nsSecName, sslCert, ca)
+		if err != nil {
+			return nil, err
 		}
 
 		msg := fmt.Sprintf("Configuring Secret %q for TLS encryption (CN: %v)", secretName, sslCert.CN)
+		if sslCert.ECDSA != nil {
+			msg += " with an ECDSA certificate"
+		}
 		if ca != nil {
 			msg += " and authentication"
 		}
-		klog.V(3).Info(msg)
+		logging.V(3).Info(msg)
 
 	} else if ca != nil && len(ca) > 0 {
 		sslCert, err = ssl.CreateCACert(ca)
@@ -134,7 +261,7 @@ func (s *k8sStore) getPemCertificate(secretName string) (*ingress.SSLCert, error
 
 		// makes this secret in 'syncSecret' to be used for Certificate Authentication
 		// this does not enable Certificate Authentication
-		klog.V(3).Infof("Configuring Secret %q for TLS authentication", secretName)
+		logging.V(3).Infof("Configuring Secret %q for TLS authentication", secretName)
 
 	} else {
 		if auth != nil {
@@ -150,9 +277,43 @@ func (s *k8sStore) getPemCertificate(secretName string) (*ingress.SSLCert, error
 	return sslCert, nil
 }
 
+// GetConfigMapCACertificate is used by the proxy-ssl annotations to get a CA
+// from the key ca.crt of a ConfigMap. The CA is written to the filesystem.
+func (s *k8sStore) GetConfigMapCACertificate(name string) (*resolver.AuthSSLCert, error) {
+	cmap, err := s.listers.ConfigMap.ByKey(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ca, ok := cmap.Data["ca.crt"]
+	if !ok || ca == "" {
+		return nil, fmt.Errorf("key 'ca.crt' missing from ConfigMap %q", name)
+	}
+
+	sslCert, err := ssl.CreateCACert([]byte(ca))
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error creating CA Cert from ConfigMap %q: %v", name, err)
+	}
+
+	// namespace/name -> configmap-namespace-name
+	nsName := "configmap-" + strings.Replace(name, "/", "-", -1)
+	err = ssl.ConfigureCACert(s.filesystem, nsName, []byte(ca), sslCert)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring CA certificate: %v", err)
+	}
+
+	return &resolver.AuthSSLCert{
+		Secret:     name,
+		CAFileName: sslCert.CAFileName,
+		PemSHA:     sslCert.PemSHA,
+	}, nil
+}
+
 // sendDummyEvent sends a dummy event to trigger an update
-// This is used in when a secret change
+// This is used in when a secret change. The revision of the objects is
+// incremented, otherwise the synchronization is skipped.
 func (s *k8sStore) sendDummyEvent() {
+	s.revisions.bump()
 	s.updateCh.In() <- Event{
 		Type: UpdateEvent,
 		Obj: &networking.Ingress{
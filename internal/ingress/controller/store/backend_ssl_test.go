@@ -18,11 +18,17 @@ package store
 
 import (
 	"encoding/base64"
+	"sync"
+	"testing"
 
+	"github.com/eapache/channels"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	testclient "k8s.io/client-go/kubernetes/fake"
 	cache_client "k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/net/ssl"
 )
 
 const (
@@ -221,3 +227,67 @@ func TestGetPemCertificate(t *testing.T) {
 	}
 }
 */
+
+func TestUpdateOCSPResponse(t *testing.T) {
+	updateCh := channels.NewRingChannel(1024)
+	s := &k8sStore{
+		sslStore:     NewSSLCertTracker(),
+		sslCertStore: ssl.NewMemorySSLCertStore(newFS(t)),
+		updateCh:     updateCh,
+		syncSecretMu: &sync.Mutex{},
+		revisions:    newObjectRevisions(),
+	}
+
+	key := "default/foo_secret"
+	cur := &ingress.SSLCert{PemSHA: "1"}
+	s.sslStore.Add(key, cur)
+
+	cert := *cur
+	cert.OCSPResponse = []byte("response")
+	cert.OCSPResponseSHA = "2"
+	s.updateOCSPResponse(key, cur, &cert)
+
+	stored, err := s.GetLocalSSLCert(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stored.OCSPResponseSHA != "2" {
+		t.Errorf("expected the OCSP response to be updated")
+	}
+
+	if updateCh.Len() != 1 {
+		t.Errorf("expected an update event but %v were sent", updateCh.Len())
+	}
+
+	// the configuration using the new response must be applied
+	revision, stable := s.GetObjectsRevision()
+	if !stable || revision != 1 {
+		t.Errorf("expected the stable revision 1 but returned %v (stable: %v)", revision, stable)
+	}
+
+	// the Secret synced while the response is fetched is not replaced
+	synced := &ingress.SSLCert{PemSHA: "3"}
+	s.sslStore.Update(key, synced)
+
+	other := *stored
+	other.OCSPResponseSHA = "4"
+	s.updateOCSPResponse(key, stored, &other)
+
+	stored, err = s.GetLocalSSLCert(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stored != synced {
+		t.Errorf("expected the Secret synced again to be kept")
+	}
+
+	if updateCh.Len() != 1 {
+		t.Errorf("expected no other update event but %v were sent", updateCh.Len())
+	}
+
+	if revision, _ := s.GetObjectsRevision(); revision != 1 {
+		t.Errorf("expected the revision not to change but returned %v", revision)
+	}
+}
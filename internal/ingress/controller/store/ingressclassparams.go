@@ -0,0 +1,40 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"k8s.io/client-go/tools/cache"
+
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
+)
+
+// IngressClassParamsLister makes a Store that lists NginxIngressClassParams.
+type IngressClassParamsLister struct {
+	cache.Store
+}
+
+// ByKey returns the NginxIngressClassParams matching key in the local NginxIngressClassParams Store.
+func (icpl *IngressClassParamsLister) ByKey(key string) (*v1alpha1.NginxIngressClassParams, error) {
+	params, exists, err := icpl.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NotExistsError(key)
+	}
+	return params.(*v1alpha1.NginxIngressClassParams), nil
+}
@@ -17,10 +17,61 @@ limitations under the License.
 package store
 
 import (
+	"strconv"
+
+	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 )
 
+// endpointWeightAnnotation is the annotation of the Pods setting the share of
+// the traffic sent to their endpoints, between 1 and 100
+const endpointWeightAnnotation = "endpoint-weight"
+
 // PodLister makes a Store that lists Pods.
 type PodLister struct {
 	cache.Store
 }
+
+// ByKey returns the Pod matching key in the local Pod Store.
+func (pl *PodLister) ByKey(key string) (*apiv1.Pod, error) {
+	p, exists, err := pl.GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, NotExistsError(key)
+	}
+	return p.(*apiv1.Pod), nil
+}
+
+// hasCondition returns true when the Pod contains the condition with the
+// status True
+func hasCondition(pod *apiv1.Pod, condition string) bool {
+	for _, c := range pod.Status.Conditions {
+		if string(c.Type) == condition {
+			return c.Status == apiv1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// endpointWeight returns the weight of the endpoints of a Pod, 0 when the
+// annotation is not set or is invalid
+func endpointWeight(pod *apiv1.Pod) int {
+	value, ok := pod.Annotations[parser.GetAnnotationWithPrefix(endpointWeightAnnotation)]
+	if !ok {
+		return 0
+	}
+
+	weight, err := strconv.Atoi(value)
+	if err != nil || weight < 1 || weight > 100 {
+		klog.Warningf("Ignoring invalid endpoint weight %q of Pod %v/%v, it must be between 1 and 100", value, pod.Namespace, pod.Name)
+		return 0
+	}
+
+	return weight
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestGetEndpointWeight(t *testing.T) {
+	newPod := func(name, weight string) *apiv1.Pod {
+		pod := &apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		}
+		if weight != "" {
+			pod.Annotations = map[string]string{"nginx.ingress.kubernetes.io/endpoint-weight": weight}
+		}
+		return pod
+	}
+
+	s := &k8sStore{listers: &Lister{}, endpointWeights: true}
+	s.listers.BackendPod.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, pod := range []*apiv1.Pod{
+		newPod("default", ""),
+		newPod("half", "50"),
+		newPod("zero", "0"),
+		newPod("too-high", "101"),
+		newPod("invalid", "fast"),
+	} {
+		s.listers.BackendPod.Add(pod)
+	}
+
+	testCases := []struct {
+		target *apiv1.ObjectReference
+		weight int
+	}{
+		{nil, 0},
+		{&apiv1.ObjectReference{Kind: "Node", Namespace: "default", Name: "half"}, 0},
+		{&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "missing"}, 0},
+		{&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "default"}, 0},
+		{&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "half"}, 50},
+		{&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "zero"}, 0},
+		{&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "too-high"}, 0},
+		{&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "invalid"}, 0},
+	}
+
+	for _, tc := range testCases {
+		weight := s.GetEndpointWeight(tc.target)
+		if weight != tc.weight {
+			t.Errorf("expected a weight of %d for %v but got %d", tc.weight, tc.target, weight)
+		}
+	}
+
+	disabled := &k8sStore{listers: &Lister{}}
+	if weight := disabled.GetEndpointWeight(&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "half"}); weight != 0 {
+		t.Errorf("expected no weight when the weights are disabled but got %d", weight)
+	}
+}
+
+func TestHasEndpointCondition(t *testing.T) {
+	newPod := func(name string, status apiv1.ConditionStatus) *apiv1.Pod {
+		pod := &apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		}
+		if status != "" {
+			pod.Status.Conditions = []apiv1.PodCondition{
+				{Type: apiv1.PodReady, Status: apiv1.ConditionTrue},
+				{Type: "ingress-warmup-complete", Status: status},
+			}
+		}
+		return pod
+	}
+
+	s := &k8sStore{listers: &Lister{}, endpointConditions: true}
+	s.listers.BackendPod.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, pod := range []*apiv1.Pod{
+		newPod("warm", apiv1.ConditionTrue),
+		newPod("cold", apiv1.ConditionFalse),
+		newPod("unknown", ""),
+	} {
+		s.listers.BackendPod.Add(pod)
+	}
+
+	testCases := []struct {
+		target   *apiv1.ObjectReference
+		expected bool
+	}{
+		{nil, true},
+		{&apiv1.ObjectReference{Kind: "Node", Namespace: "default", Name: "cold"}, true},
+		{&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "missing"}, false},
+		{&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "warm"}, true},
+		{&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "cold"}, false},
+		{&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "unknown"}, false},
+	}
+
+	for _, tc := range testCases {
+		if ok := s.HasEndpointCondition(tc.target, "ingress-warmup-complete"); ok != tc.expected {
+			t.Errorf("expected %v for %v but got %v", tc.expected, tc.target, ok)
+		}
+	}
+
+	disabled := &k8sStore{listers: &Lister{}}
+	if !disabled.HasEndpointCondition(&apiv1.ObjectReference{Kind: "Pod", Namespace: "default", Name: "cold"}, "ingress-warmup-complete") {
+		t.Errorf("expected the condition to be ignored when the conditions are disabled")
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"github.com/mitchellh/hashstructure"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
+)
+
+// contentFunc returns the content of an object used to build the configuration
+type contentFunc func(obj interface{}) interface{}
+
+// objectRevisions keeps a hash of the content of each object used to build
+// the configuration and a revision incremented every time one of them
+// changes, so a synchronization without changes is skipped without building
+// and comparing the configurations. The revision does not tell which objects
+// changed, any change requires the whole configuration to be built again.
+type objectRevisions struct {
+	mu sync.Mutex
+
+	hashes   map[string]uint64
+	revision uint64
+	// inFlight is the number of events being handled
+	inFlight int
+}
+
+func newObjectRevisions() *objectRevisions {
+	return &objectRevisions{
+		hashes: map[string]uint64{},
+	}
+}
+
+// Revision returns the current revision. The revision is not stable while
+// an event is being handled, as the listers may not contain the change yet.
+func (r *objectRevisions) Revision() (uint64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.revision, r.inFlight == 0
+}
+
+func (r *objectRevisions) begin() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.inFlight++
+}
+
+// update stores the hash of the object, incrementing the revision if it changed
+func (r *objectRevisions) update(kind string, obj interface{}, content contentFunc) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.Warningf("Unexpected error obtaining the key of %T: %v", obj, err)
+	}
+
+	var hash uint64
+	if err == nil {
+		hash, err = hashstructure.Hash(content(obj), nil)
+		if err != nil {
+			klog.Warningf("Unexpected error computing the hash of %v %v: %v", kind, key, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.inFlight--
+
+	key = fmt.Sprintf("%v/%v", kind, key)
+	if old, ok := r.hashes[key]; ok && old == hash && err == nil {
+		return
+	}
+
+	r.hashes[key] = hash
+	r.revision++
+}
+
+// remove deletes the hash of the object, incrementing the revision
+func (r *objectRevisions) remove(kind string, obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		klog.Warningf("Unexpected error obtaining the key of %T: %v", obj, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.inFlight--
+
+	delete(r.hashes, fmt.Sprintf("%v/%v", kind, key))
+	r.revision++
+}
+
+// bump increments the revision for a change which is not an event of an
+// informer, i.e. the certificates written from the Secrets
+func (r *objectRevisions) bump() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revision++
+}
+
+// handler wraps the event handler of an informer to keep the revision of
+// its objects. The revision is updated after the event is handled.
+func (r *objectRevisions) handler(kind string, content contentFunc, h cache.ResourceEventHandlerFuncs) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			r.begin()
+			defer r.update(kind, obj, content)
+
+			if h.AddFunc != nil {
+				h.AddFunc(obj)
+			}
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			r.begin()
+			defer r.update(kind, cur, content)
+
+			if h.UpdateFunc != nil {
+				h.UpdateFunc(old, cur)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			r.begin()
+			defer r.remove(kind, obj)
+
+			if h.DeleteFunc != nil {
+				h.DeleteFunc(obj)
+			}
+		},
+	}
+}
+
+func ingressContent(obj interface{}) interface{} {
+	ing := obj.(*networkingv1beta1.Ingress)
+	return []interface{}{ing.Annotations, ing.Spec}
+}
+
+func endpointsContent(obj interface{}) interface{} {
+	return obj.(*corev1.Endpoints).Subsets
+}
+
+func serviceContent(obj interface{}) interface{} {
+	svc := obj.(*corev1.Service)
+	return []interface{}{svc.Annotations, svc.Spec}
+}
+
+func secretContent(obj interface{}) interface{} {
+	secret := obj.(*corev1.Secret)
+
+	// the checksums avoid hashing the content byte by byte
+	data := map[string]string{}
+	for k, v := range secret.Data {
+		data[k] = fmt.Sprintf("%x", sha256.Sum256(v))
+	}
+
+	return []interface{}{secret.Type, data}
+}
+
+func configMapContent(obj interface{}) interface{} {
+	return obj.(*corev1.ConfigMap).Data
+}
+
+func podContent(obj interface{}) interface{} {
+	pod := obj.(*corev1.Pod)
+	return []interface{}{pod.Labels, pod.Status.Phase}
+}
+
+func backendPodContent(obj interface{}) interface{} {
+	pod := obj.(*corev1.Pod)
+
+	conditions := map[corev1.PodConditionType]corev1.ConditionStatus{}
+	for _, c := range pod.Status.Conditions {
+		conditions[c.Type] = c.Status
+	}
+
+	return []interface{}{pod.Annotations[parser.GetAnnotationWithPrefix(endpointWeightAnnotation)], conditions}
+}
+
+func hostDelegationContent(obj interface{}) interface{} {
+	return obj.(*v1alpha1.HostDelegation).Spec
+}
+
+func ingressClassParamsContent(obj interface{}) interface{} {
+	return obj.(*v1alpha1.NginxIngressClassParams).Spec
+}
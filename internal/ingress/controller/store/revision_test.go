@@ -0,0 +1,102 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestObjectRevisions(t *testing.T) {
+	r := newObjectRevisions()
+
+	stableInHandler := true
+	h := r.handler("ConfigMap", configMapContent, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			_, stableInHandler = r.Revision()
+		},
+	})
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "config",
+			Namespace:       "default",
+			ResourceVersion: "1",
+		},
+		Data: map[string]string{"foo": "bar"},
+	}
+
+	expectRevision := func(title string, expected uint64) {
+		revision, stable := r.Revision()
+		if !stable {
+			t.Errorf("%v: expected a stable revision", title)
+		}
+		if revision != expected {
+			t.Errorf("%v: expected revision %v but returned %v", title, expected, revision)
+		}
+	}
+
+	h.AddFunc(cm)
+	expectRevision("add", 1)
+	if stableInHandler {
+		t.Errorf("expected an unstable revision while the event is handled")
+	}
+
+	// a new resource version without changes in the content
+	same := cm.DeepCopy()
+	same.ResourceVersion = "2"
+	h.UpdateFunc(cm, same)
+	expectRevision("update without changes", 1)
+
+	changed := same.DeepCopy()
+	changed.ResourceVersion = "3"
+	changed.Data["foo"] = "baz"
+	h.UpdateFunc(same, changed)
+	expectRevision("update", 2)
+
+	h.DeleteFunc(cache.DeletedFinalStateUnknown{Key: "default/config", Obj: changed})
+	expectRevision("delete", 3)
+
+	h.AddFunc(changed)
+	expectRevision("add after delete", 4)
+
+	// the certificates written from the Secrets
+	r.bump()
+	expectRevision("bump", 5)
+}
+
+func TestSecretContent(t *testing.T) {
+	s1 := &corev1.Secret{Data: map[string][]byte{"tls.crt": []byte("foo")}}
+	s2 := &corev1.Secret{Data: map[string][]byte{"tls.crt": []byte("bar")}}
+
+	r := newObjectRevisions()
+	h := r.handler("Secret", secretContent, cache.ResourceEventHandlerFuncs{})
+
+	h.AddFunc(s1)
+	h.UpdateFunc(s1, s1.DeepCopy())
+	if revision, _ := r.Revision(); revision != 1 {
+		t.Errorf("expected revision 1 but returned %v", revision)
+	}
+
+	h.UpdateFunc(s1, s2)
+	if revision, _ := r.Revision(); revision != 2 {
+		t.Errorf("expected revision 2 but returned %v", revision)
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/k8s"
+)
+
+// lifetime requested for the tokens of the service accounts, the minimum
+// accepted by the TokenRequest API
+const secretTokenExpiration = int64(600)
+
+// interval between two reads of the Secrets used by the controller, as
+// the changes of the Secrets are not watched
+const secretRefreshInterval = 1 * time.Minute
+
+// SecretReader reads the Secrets from the API server when the controller is
+// not allowed to watch the Secrets of the cluster
+type SecretReader interface {
+	Get(namespace, name string) (*corev1.Secret, error)
+}
+
+// namespacedSecretReader reads the Secrets of each namespace with the
+// identity of a service account of the same namespace, so the controller
+// only reads the Secrets the tenants allowed this service account to read
+type namespacedSecretReader struct {
+	client         clientset.Interface
+	config         *rest.Config
+	serviceAccount string
+	// impersonate uses impersonation instead of short-lived tokens
+	impersonate bool
+
+	newClient func(*rest.Config) (clientset.Interface, error)
+	now       func() time.Time
+
+	lock    sync.Mutex
+	clients map[string]*namespaceClient
+}
+
+type namespaceClient struct {
+	client clientset.Interface
+	// renewAt is the time when the token must be requested again,
+	// zero when the client does not use a token
+	renewAt time.Time
+}
+
+// NewNamespacedSecretReader returns a SecretReader using the service account
+// serviceAccount of the namespace of each Secret. The controller requests a
+// short-lived token of the service account with the TokenRequest API, or
+// impersonates it when impersonate is true.
+func NewNamespacedSecretReader(client clientset.Interface, config *rest.Config, serviceAccount string, impersonate bool) SecretReader {
+	return &namespacedSecretReader{
+		client:         client,
+		config:         config,
+		serviceAccount: serviceAccount,
+		impersonate:    impersonate,
+		newClient: func(cfg *rest.Config) (clientset.Interface, error) {
+			return clientset.NewForConfig(cfg)
+		},
+		now:     time.Now,
+		clients: map[string]*namespaceClient{},
+	}
+}
+
+// Get returns the Secret using the identity of the service account of its namespace
+func (r *namespacedSecretReader) Get(namespace, name string) (*corev1.Secret, error) {
+	client, err := r.namespaceClient(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+}
+
+// namespaceClient returns the client of the namespace, creating it when it
+// does not exist or its token must be renewed. The lock is not held while the
+// token is requested, two concurrent calls may both create a client.
+func (r *namespacedSecretReader) namespaceClient(namespace string) (clientset.Interface, error) {
+	now := r.now()
+
+	r.lock.Lock()
+	nc, ok := r.clients[namespace]
+	r.lock.Unlock()
+
+	if ok && (nc.renewAt.IsZero() || now.Before(nc.renewAt)) {
+		return nc.client, nil
+	}
+
+	nc = &namespaceClient{}
+
+	var cfg *rest.Config
+	if r.impersonate {
+		cfg = rest.CopyConfig(r.config)
+		cfg.Impersonate = rest.ImpersonationConfig{
+			UserName: fmt.Sprintf("system:serviceaccount:%v:%v", namespace, r.serviceAccount),
+		}
+	} else {
+		expiration := secretTokenExpiration
+		tr, err := r.client.CoreV1().ServiceAccounts(namespace).CreateToken(r.serviceAccount, &authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				ExpirationSeconds: &expiration,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not request a token for the service account %v/%v: %v", namespace, r.serviceAccount, err)
+		}
+
+		// the token is renewed when 80% of its lifetime elapsed
+		lifetime := tr.Status.ExpirationTimestamp.Sub(now)
+		nc.renewAt = now.Add(lifetime * 8 / 10)
+
+		cfg = rest.AnonymousClientConfig(r.config)
+		cfg.BearerToken = tr.Status.Token
+	}
+
+	client, err := r.newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	nc.client = client
+
+	r.lock.Lock()
+	r.clients[namespace] = nc
+	r.lock.Unlock()
+
+	return client, nil
+}
+
+// readSecret reads a Secret missing from the local store with the
+// SecretReader. The missing Secrets are only read again by refreshSecrets.
+func (s *k8sStore) readSecret(key string) (*corev1.Secret, error) {
+	s.missingSecretsMu.Lock()
+	missing := s.missingSecrets.Has(key)
+	s.missingSecretsMu.Unlock()
+
+	if missing {
+		return nil, NotExistsError(key)
+	}
+
+	// the lock is not held while the Secret is read from the API server
+	secret, err := s.fetchSecret(key)
+	if err != nil {
+		s.missingSecretsMu.Lock()
+		s.missingSecrets.Insert(key)
+		s.missingSecretsMu.Unlock()
+		return nil, err
+	}
+
+	if err := s.listers.Secret.Add(secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// fetchSecret returns a NotExistsError when the Secret does not exist or
+// the service account of its namespace is not allowed to read it
+func (s *k8sStore) fetchSecret(key string) (*corev1.Secret, error) {
+	namespace, name, err := k8s.ParseNameNS(key)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := s.secretReader.Get(namespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, NotExistsError(key)
+		}
+
+		klog.Warningf("Error reading Secret %v: %v", key, err)
+		if apierrors.IsForbidden(err) {
+			return nil, NotExistsError(key)
+		}
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// secretReferenced returns true when the Secret is used by the controller
+func (s *k8sStore) secretReferenced(key string) bool {
+	return s.isDefaultSSLCertificate(key) || len(s.secretIngressMap.Reference(key)) > 0
+}
+
+// refreshSecrets reads again the Secrets used by the controller, and
+// handles the changes like the events of an informer
+func (s *k8sStore) refreshSecrets() {
+	s.missingSecretsMu.Lock()
+	keys := sets.NewString(s.listers.Secret.ListKeys()...).Union(s.missingSecrets)
+	s.missingSecretsMu.Unlock()
+
+	for _, key := range keys.List() {
+		old, exists, _ := s.listers.Secret.GetByKey(key)
+
+		if !s.secretReferenced(key) {
+			s.forgetSecret(key, old, exists, false)
+			continue
+		}
+
+		secret, err := s.fetchSecret(key)
+		if err != nil {
+			if _, ok := err.(NotExistsError); ok {
+				s.forgetSecret(key, old, exists, true)
+			}
+			continue
+		}
+
+		s.missingSecretsMu.Lock()
+		s.missingSecrets.Delete(key)
+		s.missingSecretsMu.Unlock()
+
+		if exists {
+			if err := s.listers.Secret.Update(secret); err != nil {
+				klog.Errorf("Error updating Secret %v in local store: %v", key, err)
+				continue
+			}
+			s.secretHandler.OnUpdate(old, secret)
+			continue
+		}
+
+		if err := s.listers.Secret.Add(secret); err != nil {
+			klog.Errorf("Error adding Secret %v to local store: %v", key, err)
+			continue
+		}
+		s.secretHandler.OnAdd(secret)
+	}
+}
+
+// forgetSecret removes a Secret from the local store, and keeps its key in
+// the missing Secrets while it is referenced
+func (s *k8sStore) forgetSecret(key string, old interface{}, exists, referenced bool) {
+	s.missingSecretsMu.Lock()
+	if referenced {
+		s.missingSecrets.Insert(key)
+	} else {
+		s.missingSecrets.Delete(key)
+	}
+	s.missingSecretsMu.Unlock()
+
+	if !exists {
+		return
+	}
+
+	if err := s.listers.Secret.Delete(old); err != nil {
+		klog.Errorf("Error removing Secret %v from local store: %v", key, err)
+		return
+	}
+	s.secretHandler.OnDelete(old)
+}
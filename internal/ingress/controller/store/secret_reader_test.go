@@ -0,0 +1,209 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package store
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNamespacedSecretReaderToken(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "tls"}}
+
+	client := fake.NewSimpleClientset()
+	requests := 0
+	client.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		requests++
+
+		tr := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+		if *tr.Spec.ExpirationSeconds != secretTokenExpiration {
+			t.Errorf("expected an expiration of %v seconds but got %v", secretTokenExpiration, *tr.Spec.ExpirationSeconds)
+		}
+		tr.Status = authenticationv1.TokenRequestStatus{
+			Token:               "token",
+			ExpirationTimestamp: metav1.NewTime(time.Unix(0, 0).Add(10 * time.Minute)),
+		}
+		return true, tr, nil
+	})
+
+	now := time.Unix(0, 0)
+	reader := NewNamespacedSecretReader(client, &rest.Config{Host: "https://apiserver", BearerToken: "controller"}, "ingress-tls", false).(*namespacedSecretReader)
+	reader.now = func() time.Time { return now }
+
+	var configs []*rest.Config
+	reader.newClient = func(cfg *rest.Config) (clientset.Interface, error) {
+		configs = append(configs, cfg)
+		return fake.NewSimpleClientset(secret), nil
+	}
+
+	got, err := reader.Get("tenant", "tls")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Name != "tls" {
+		t.Errorf("expected the Secret tls but got %v", got.Name)
+	}
+	if configs[0].BearerToken != "token" {
+		t.Errorf("expected the token of the service account but got %q", configs[0].BearerToken)
+	}
+
+	// the token is reused until 80% of its lifetime elapsed
+	now = now.Add(7 * time.Minute)
+	if _, err := reader.Get("tenant", "tls"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 token request but got %v", requests)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := reader.Get("tenant", "tls"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 token requests but got %v", requests)
+	}
+}
+
+func TestNamespacedSecretReaderImpersonation(t *testing.T) {
+	reader := NewNamespacedSecretReader(fake.NewSimpleClientset(), &rest.Config{Host: "https://apiserver"}, "ingress-tls", true).(*namespacedSecretReader)
+
+	var cfg *rest.Config
+	reader.newClient = func(c *rest.Config) (clientset.Interface, error) {
+		cfg = c
+		return fake.NewSimpleClientset(), nil
+	}
+
+	_, err := reader.Get("tenant", "tls")
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected a not found error but got %v", err)
+	}
+
+	if cfg.Impersonate.UserName != "system:serviceaccount:tenant:ingress-tls" {
+		t.Errorf("unexpected impersonated user %q", cfg.Impersonate.UserName)
+	}
+}
+
+type fakeSecretReader struct {
+	secrets map[string]*corev1.Secret
+	reads   int
+}
+
+func (r *fakeSecretReader) Get(namespace, name string) (*corev1.Secret, error) {
+	r.reads++
+
+	if name == "forbidden" {
+		return nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, name, nil)
+	}
+
+	secret, ok := r.secrets[namespace+"/"+name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, name)
+	}
+	return secret, nil
+}
+
+func TestRefreshSecrets(t *testing.T) {
+	reader := &fakeSecretReader{secrets: map[string]*corev1.Secret{}}
+
+	var events []string
+	s := &k8sStore{
+		listers:          &Lister{},
+		secretReader:     reader,
+		secretIngressMap: NewObjectRefMap(),
+		missingSecrets:   sets.NewString(),
+		missingSecretsMu: &sync.Mutex{},
+		secretHandler: cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { events = append(events, "add") },
+			UpdateFunc: func(old, cur interface{}) { events = append(events, "update") },
+			DeleteFunc: func(obj interface{}) { events = append(events, "delete") },
+		},
+	}
+	s.listers.Secret.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	s.secretIngressMap.Insert("tenant/app", "tenant/tls", "tenant/forbidden")
+
+	if _, err := s.GetSecret("tenant/tls"); err == nil {
+		t.Fatalf("expected an error reading a missing Secret")
+	}
+	if _, err := s.GetSecret("tenant/forbidden"); err == nil {
+		t.Fatalf("expected an error reading a forbidden Secret")
+	}
+
+	// the missing Secrets are not read again until the next refresh
+	if _, err := s.GetSecret("tenant/tls"); err == nil {
+		t.Fatalf("expected an error reading a missing Secret")
+	}
+	if reader.reads != 2 {
+		t.Errorf("expected 2 reads but got %v", reader.reads)
+	}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "tls"}}
+	reader.secrets["tenant/tls"] = secret
+	s.refreshSecrets()
+
+	if _, err := s.GetSecret("tenant/tls"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s.refreshSecrets()
+
+	delete(reader.secrets, "tenant/tls")
+	s.refreshSecrets()
+
+	if _, err := s.GetSecret("tenant/tls"); err == nil {
+		t.Fatalf("expected an error reading a deleted Secret")
+	}
+
+	// the Secrets not referenced anymore are forgotten
+	s.secretIngressMap.Delete("tenant/app")
+	reads := reader.reads
+	s.refreshSecrets()
+
+	if reader.reads != reads {
+		t.Errorf("expected no reads of the Secrets not referenced but got %v", reader.reads-reads)
+	}
+	if s.missingSecrets.Len() != 0 {
+		t.Errorf("expected no missing Secrets but got %v", s.missingSecrets.List())
+	}
+
+	expected := []string{"add", "update", "delete"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected the events %v but got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("expected the events %v but got %v", expected, events)
+		}
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"io/ioutil"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -35,6 +37,8 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/informers"
 	clientset "k8s.io/client-go/kubernetes"
@@ -43,6 +47,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 
+	"k8s.io/ingress-nginx/internal/events"
 	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations"
@@ -54,6 +59,11 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/errors"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/internal/logging"
+	"k8s.io/ingress-nginx/internal/net/ssl"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
+	"k8s.io/ingress-nginx/pkg/client/clientset/versioned"
+	"k8s.io/ingress-nginx/pkg/client/informers/externalversions"
 )
 
 // IngressFilterFunc decides if an Ingress should be omitted or not
@@ -77,26 +87,58 @@ type Storer interface {
 	// GetServiceEndpoints returns the Endpoints of a Service matching key.
 	GetServiceEndpoints(key string) (*corev1.Endpoints, error)
 
+	// GetEndpointWeight returns the weight of the endpoint provided by target,
+	// read from the endpoint-weight annotation of the Pod. 0 means the default weight.
+	GetEndpointWeight(target *corev1.ObjectReference) int
+
+	// HasEndpointCondition returns true when the Pod providing the endpoint
+	// contains the condition with the status True.
+	HasEndpointCondition(target *corev1.ObjectReference, condition string) bool
+
 	// ListIngresses returns a list of all Ingresses in the store.
 	ListIngresses(IngressFilterFunc) []*ingress.Ingress
 
 	// GetRunningControllerPodsCount returns the number of Running ingress-nginx controller Pods.
 	GetRunningControllerPodsCount() int
 
+	// ListControllerPodNames returns the names of the ingress-nginx controller Pods.
+	ListControllerPodNames() []string
+
 	// GetLocalSSLCert returns the local copy of a SSLCert
 	GetLocalSSLCert(name string) (*ingress.SSLCert, error)
 
 	// ListLocalSSLCerts returns the list of local SSLCerts
 	ListLocalSSLCerts() []*ingress.SSLCert
 
+	// GetSecretReferences returns the keys of the Ingresses referencing the Secret matching key
+	GetSecretReferences(key string) []string
+
 	// GetAuthCertificate resolves a given secret name into an SSL certificate.
 	// The secret must contain 3 keys named:
 	//   ca.crt: contains the certificate chain used for authentication
 	GetAuthCertificate(string) (*resolver.AuthSSLCert, error)
 
+	// GetConfigMapCACertificate resolves a given configmap name into a CA certificate.
+	// The configmap must contain the key ca.crt
+	GetConfigMapCACertificate(string) (*resolver.AuthSSLCert, error)
+
 	// GetDefaultBackend returns the default backend configuration
 	GetDefaultBackend() defaults.Backend
 
+	// ListHostDelegations returns a list of all HostDelegations in the store.
+	ListHostDelegations() []*v1alpha1.HostDelegation
+
+	// GetIngressClassParams returns the NginxIngressClassParams of an ingress class.
+	GetIngressClassParams(ingressClass string) (*v1alpha1.NginxIngressClassParams, error)
+
+	// ListIngressClassParams returns a list of all NginxIngressClassParams in the store.
+	ListIngressClassParams() []*v1alpha1.NginxIngressClassParams
+
+	// GetObjectsRevision returns a revision incremented every time an object used
+	// to build the configuration changes. The revision is not stable, and must not
+	// be used to skip a synchronization, while a change is being handled.
+	GetObjectsRevision() (uint64, bool)
+
 	// Run initiates the synchronization of the controllers
 	Run(stopCh chan struct{})
 }
@@ -129,6 +171,13 @@ type Informer struct {
 	Secret    cache.SharedIndexInformer
 	ConfigMap cache.SharedIndexInformer
 	Pod       cache.SharedIndexInformer
+
+	// BackendPod watches the Pods providing the endpoints, only when the
+	// weights or the conditions of the endpoints are enabled
+	BackendPod cache.SharedIndexInformer
+
+	HostDelegation     cache.SharedIndexInformer
+	IngressClassParams cache.SharedIndexInformer
 }
 
 // Lister contains object listers (stores).
@@ -140,6 +189,9 @@ type Lister struct {
 	ConfigMap             ConfigMapLister
 	IngressWithAnnotation IngressWithAnnotationsLister
 	Pod                   PodLister
+	BackendPod            PodLister
+	HostDelegation        HostDelegationLister
+	IngressClassParams    IngressClassParamsLister
 }
 
 // NotExistsError is returned when an object does not exist in a local store.
@@ -154,21 +206,52 @@ func (e NotExistsError) Error() string {
 func (i *Informer) Run(stopCh chan struct{}) {
 	go i.Endpoint.Run(stopCh)
 	go i.Service.Run(stopCh)
-	go i.Secret.Run(stopCh)
 	go i.ConfigMap.Run(stopCh)
 	go i.Pod.Run(stopCh)
 
-	// wait for all involved caches to be synced before processing items
-	// from the queue
-	if !cache.WaitForCacheSync(stopCh,
+	synced := []cache.InformerSynced{
 		i.Endpoint.HasSynced,
 		i.Service.HasSynced,
-		i.Secret.HasSynced,
 		i.ConfigMap.HasSynced,
-	) {
+	}
+
+	// the Secrets are not watched when they are read with the identity
+	// of a service account of each namespace
+	if i.Secret != nil {
+		go i.Secret.Run(stopCh)
+		synced = append(synced, i.Secret.HasSynced)
+	}
+
+	if i.BackendPod != nil {
+		go i.BackendPod.Run(stopCh)
+		synced = append(synced, i.BackendPod.HasSynced)
+	}
+
+	// wait for all involved caches to be synced before processing items
+	// from the queue
+	if !cache.WaitForCacheSync(stopCh, synced...) {
 		runtime.HandleError(fmt.Errorf("Timed out waiting for caches to sync"))
 	}
 
+	if i.HostDelegation != nil {
+		go i.HostDelegation.Run(stopCh)
+		if !cache.WaitForCacheSync(stopCh,
+			i.HostDelegation.HasSynced,
+		) {
+			runtime.HandleError(fmt.Errorf("Timed out waiting for caches to sync"))
+		}
+	}
+
+	// the parameters of the classes are required to parse the annotations
+	if i.IngressClassParams != nil {
+		go i.IngressClassParams.Run(stopCh)
+		if !cache.WaitForCacheSync(stopCh,
+			i.IngressClassParams.HasSynced,
+		) {
+			runtime.HandleError(fmt.Errorf("Timed out waiting for caches to sync"))
+		}
+	}
+
 	// in big clusters, deltas can keep arriving even after HasSynced
 	// functions have returned 'true'
 	time.Sleep(1 * time.Second)
@@ -208,20 +291,56 @@ type k8sStore struct {
 	// secret in the annotations.
 	secretIngressMap ObjectRefMap
 
+	// configMapIngressMap contains information about which ingress references
+	// a configmap in the annotations.
+	configMapIngressMap ObjectRefMap
+
 	filesystem file.Filesystem
 
+	// sslCertStore keeps the certificates and keys of the Secrets, in memory
+	// when they are configured dynamically
+	sslCertStore ssl.SSLCertStore
+
 	// updateCh
 	updateCh *channels.RingChannel
 
 	// syncSecretMu protects against simultaneous invocations of syncSecret
 	syncSecretMu *sync.Mutex
 
+	// ocspRefreshCh wakes up the refresh of the OCSP responses when a Secret
+	// whose response must be fetched is synced
+	ocspRefreshCh chan struct{}
+
 	// backendConfigMu protects against simultaneous read/write of backendConfig
 	backendConfigMu *sync.RWMutex
 
 	defaultSSLCertificate string
 
 	pod *k8s.PodInfo
+
+	// recorder records events of the Ingresses with invalid annotations
+	recorder record.EventRecorder
+
+	// strictAnnotationValidation ignores Ingresses containing unknown
+	// annotations or annotations with invalid values
+	strictAnnotationValidation bool
+
+	// revisions keeps the revision of the objects used to build the configuration
+	revisions *objectRevisions
+
+	// secretReader reads the Secrets when they are not watched
+	secretReader SecretReader
+
+	// endpointWeights reads the weights of the endpoints from the Pods
+	endpointWeights bool
+	// endpointConditions reads the conditions of the endpoints from the Pods
+	endpointConditions bool
+	// secretHandler handles the changes of the Secrets found by refreshSecrets
+	secretHandler cache.ResourceEventHandler
+	// missingSecrets contains the keys of the referenced Secrets that do not
+	// exist or that the service account is not allowed to read
+	missingSecrets   sets.String
+	missingSecretsMu *sync.Mutex
 }
 
 // New creates a new object store to be used in the ingress controller
@@ -232,20 +351,37 @@ func New(
 	fs file.Filesystem,
 	updateCh *channels.RingChannel,
 	pod *k8s.PodInfo,
-	disableCatchAll bool) Storer {
+	disableCatchAll bool,
+	strictAnnotationValidation bool,
+	delegationClient versioned.Interface,
+	classParamsClient versioned.Interface,
+	secretReader SecretReader,
+	endpointWeights bool,
+	endpointConditions bool) Storer {
 
 	store := &k8sStore{
 		informers:             &Informer{},
 		listers:               &Lister{},
 		sslStore:              NewSSLCertTracker(),
 		filesystem:            fs,
+		sslCertStore:          newSSLCertStore(fs),
 		updateCh:              updateCh,
 		backendConfig:         ngx_config.NewDefault(),
 		syncSecretMu:          &sync.Mutex{},
+		ocspRefreshCh:         make(chan struct{}, 1),
 		backendConfigMu:       &sync.RWMutex{},
 		secretIngressMap:      NewObjectRefMap(),
+		configMapIngressMap:   NewObjectRefMap(),
 		defaultSSLCertificate: defaultSSLCertificate,
 		pod:                   pod,
+		revisions:             newObjectRevisions(),
+		secretReader:          secretReader,
+		endpointWeights:       endpointWeights,
+		endpointConditions:    endpointConditions,
+		missingSecrets:        sets.NewString(),
+		missingSecretsMu:      &sync.Mutex{},
+
+		strictAnnotationValidation: strictAnnotationValidation,
 	}
 
 	eventBroadcaster := record.NewBroadcaster()
@@ -253,9 +389,10 @@ func New(
 	eventBroadcaster.StartRecordingToSink(&clientcorev1.EventSinkImpl{
 		Interface: client.CoreV1().Events(namespace),
 	})
-	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{
+	recorder := events.NewAggregator(eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{
 		Component: "nginx-ingress-controller",
-	})
+	}))
+	store.recorder = recorder
 
 	// k8sStore fulfills resolver.Resolver interface
 	store.annotations = annotations.NewAnnotationExtractor(store)
@@ -278,8 +415,13 @@ func New(
 	store.informers.Endpoint = infFactory.Core().V1().Endpoints().Informer()
 	store.listers.Endpoint.Store = store.informers.Endpoint.GetStore()
 
-	store.informers.Secret = infFactory.Core().V1().Secrets().Informer()
-	store.listers.Secret.Store = store.informers.Secret.GetStore()
+	if secretReader == nil {
+		store.informers.Secret = infFactory.Core().V1().Secrets().Informer()
+		store.listers.Secret.Store = store.informers.Secret.GetStore()
+	} else {
+		// the Secrets are read on demand, see GetSecret
+		store.listers.Secret.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+	}
 
 	store.informers.ConfigMap = infFactory.Core().V1().ConfigMaps().Informer()
 	store.listers.ConfigMap.Store = store.informers.ConfigMap.GetStore()
@@ -287,6 +429,26 @@ func New(
 	store.informers.Service = infFactory.Core().V1().Services().Informer()
 	store.listers.Service.Store = store.informers.Service.GetStore()
 
+	if endpointWeights || endpointConditions {
+		store.informers.BackendPod = infFactory.Core().V1().Pods().Informer()
+		store.listers.BackendPod.Store = store.informers.BackendPod.GetStore()
+	}
+
+	if delegationClient != nil {
+		delegationFactory := externalversions.NewSharedInformerFactoryWithOptions(delegationClient, resyncPeriod,
+			externalversions.WithNamespace(namespace))
+
+		store.informers.HostDelegation = delegationFactory.Nginx().V1alpha1().HostDelegations().Informer()
+		store.listers.HostDelegation.Store = store.informers.HostDelegation.GetStore()
+	}
+
+	if classParamsClient != nil {
+		classParamsFactory := externalversions.NewSharedInformerFactory(classParamsClient, resyncPeriod)
+
+		store.informers.IngressClassParams = classParamsFactory.Nginx().V1alpha1().NginxIngressClassParamses().Informer()
+		store.listers.IngressClassParams.Store = store.informers.IngressClassParams.GetStore()
+	}
+
 	labelSelector := labels.SelectorFromSet(store.pod.Labels)
 	store.informers.Pod = cache.NewSharedIndexInformer(
 		&cache.ListWatch{
@@ -335,6 +497,7 @@ func New(
 
 		key := k8s.MetaNamespaceKey(ing)
 		store.secretIngressMap.Delete(key)
+		store.configMapIngressMap.Delete(key)
 
 		updateCh.In() <- Event{
 			Type: DeleteEvent,
@@ -358,6 +521,7 @@ func New(
 
 			store.syncIngress(ing)
 			store.updateSecretIngressMap(ing)
+			store.updateConfigMapIngressMap(ing)
 			store.syncSecrets(ing)
 
 			updateCh.In() <- Event{
@@ -393,12 +557,13 @@ func New(
 
 				recorder.Eventf(curIng, corev1.EventTypeNormal, "UPDATE", fmt.Sprintf("Ingress %s/%s", curIng.Namespace, curIng.Name))
 			} else {
-				klog.V(3).Infof("No changes on ingress %v/%v. Skipping update", curIng.Namespace, curIng.Name)
+				logging.V(3).Infof("No changes on ingress %v/%v. Skipping update", curIng.Namespace, curIng.Name)
 				return
 			}
 
 			store.syncIngress(curIng)
 			store.updateSecretIngressMap(curIng)
+			store.updateConfigMapIngressMap(curIng)
 			store.syncSecrets(curIng)
 
 			updateCh.In() <- Event{
@@ -413,8 +578,15 @@ func New(
 			sec := obj.(*corev1.Secret)
 			key := k8s.MetaNamespaceKey(sec)
 
-			if store.defaultSSLCertificate == key {
-				store.syncSecret(store.defaultSSLCertificate)
+			if store.isDefaultSSLCertificate(key) {
+				store.syncSecret(key)
+			}
+
+			if store.isConfigurationSecret(key) {
+				updateCh.In() <- Event{
+					Type: CreateEvent,
+					Obj:  obj,
+				}
 			}
 
 			// find references in ingresses and update local ssl certs
@@ -440,8 +612,15 @@ func New(
 				sec := cur.(*corev1.Secret)
 				key := k8s.MetaNamespaceKey(sec)
 
-				if store.defaultSSLCertificate == key {
-					store.syncSecret(store.defaultSSLCertificate)
+				if store.isDefaultSSLCertificate(key) {
+					store.syncSecret(key)
+				}
+
+				if store.isConfigurationSecret(key) {
+					updateCh.In() <- Event{
+						Type: UpdateEvent,
+						Obj:  cur,
+					}
 				}
 
 				// find references in ingresses and update local ssl certs
@@ -483,6 +662,13 @@ func New(
 
 			key := k8s.MetaNamespaceKey(sec)
 
+			if store.isConfigurationSecret(key) {
+				updateCh.In() <- Event{
+					Type: DeleteEvent,
+					Obj:  obj,
+				}
+			}
+
 			// find references in ingresses
 			if ings := store.secretIngressMap.Reference(key); len(ings) > 0 {
 				klog.Infof("secret %v was deleted and it is used in ingress annotations. Parsing...", key)
@@ -541,6 +727,17 @@ func New(
 					Type: ConfigurationEvent,
 					Obj:  obj,
 				}
+			} else if store.isNamespaceDefaults(cm) {
+				store.syncNamespaceIngresses(cm.Namespace)
+				updateCh.In() <- Event{
+					Type: ConfigurationEvent,
+					Obj:  obj,
+				}
+			} else if store.syncConfigMapIngresses(key) {
+				updateCh.In() <- Event{
+					Type: ConfigurationEvent,
+					Obj:  obj,
+				}
 			}
 		},
 		UpdateFunc: func(old, cur interface{}) {
@@ -565,6 +762,17 @@ func New(
 						store.syncIngress(ing)
 					}
 
+					updateCh.In() <- Event{
+						Type: ConfigurationEvent,
+						Obj:  cur,
+					}
+				} else if store.isNamespaceDefaults(cm) {
+					store.syncNamespaceIngresses(cm.Namespace)
+					updateCh.In() <- Event{
+						Type: ConfigurationEvent,
+						Obj:  cur,
+					}
+				} else if store.syncConfigMapIngresses(key) {
 					updateCh.In() <- Event{
 						Type: ConfigurationEvent,
 						Obj:  cur,
@@ -572,6 +780,109 @@ func New(
 				}
 			}
 		},
+		DeleteFunc: func(obj interface{}) {
+			cm, ok := obj.(*corev1.ConfigMap)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					klog.Errorf("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				cm, ok = tombstone.Obj.(*corev1.ConfigMap)
+				if !ok {
+					klog.Errorf("Tombstone contained object that is not a ConfigMap: %#v", obj)
+					return
+				}
+			}
+
+			if store.isNamespaceDefaults(cm) {
+				store.syncNamespaceIngresses(cm.Namespace)
+				updateCh.In() <- Event{
+					Type: ConfigurationEvent,
+					Obj:  obj,
+				}
+			} else if store.syncConfigMapIngresses(k8s.MetaNamespaceKey(cm)) {
+				updateCh.In() <- Event{
+					Type: ConfigurationEvent,
+					Obj:  obj,
+				}
+			}
+		},
+	}
+
+	hdEventHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			updateCh.In() <- Event{
+				Type: CreateEvent,
+				Obj:  obj,
+			}
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldHD := old.(*v1alpha1.HostDelegation)
+			curHD := cur.(*v1alpha1.HostDelegation)
+
+			if reflect.DeepEqual(oldHD.Spec, curHD.Spec) {
+				return
+			}
+
+			updateCh.In() <- Event{
+				Type: UpdateEvent,
+				Obj:  cur,
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			updateCh.In() <- Event{
+				Type: DeleteEvent,
+				Obj:  obj,
+			}
+		},
+	}
+
+	// the Ingresses of the class are parsed again when the parameters change
+	icpEventHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			params := obj.(*v1alpha1.NginxIngressClassParams)
+			store.syncIngressClassParams(params)
+			updateCh.In() <- Event{
+				Type: ConfigurationEvent,
+				Obj:  obj,
+			}
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldParams := old.(*v1alpha1.NginxIngressClassParams)
+			curParams := cur.(*v1alpha1.NginxIngressClassParams)
+
+			if reflect.DeepEqual(oldParams.Spec, curParams.Spec) {
+				return
+			}
+
+			store.syncIngressClassParams(curParams)
+			updateCh.In() <- Event{
+				Type: ConfigurationEvent,
+				Obj:  cur,
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			params, ok := obj.(*v1alpha1.NginxIngressClassParams)
+			if !ok {
+				tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					klog.Errorf("couldn't get object from tombstone %#v", obj)
+					return
+				}
+				params, ok = tombstone.Obj.(*v1alpha1.NginxIngressClassParams)
+				if !ok {
+					klog.Errorf("Tombstone contained object that is not a NginxIngressClassParams: %#v", obj)
+					return
+				}
+			}
+
+			store.syncClassIngresses(params.Name)
+			updateCh.In() <- Event{
+				Type: ConfigurationEvent,
+				Obj:  obj,
+			}
+		},
 	}
 
 	podEventHandler := cache.ResourceEventHandlerFuncs{
@@ -602,12 +913,42 @@ func New(
 		},
 	}
 
-	store.informers.Ingress.AddEventHandler(ingEventHandler)
-	store.informers.Endpoint.AddEventHandler(epEventHandler)
-	store.informers.Secret.AddEventHandler(secrEventHandler)
-	store.informers.ConfigMap.AddEventHandler(cmEventHandler)
-	store.informers.Service.AddEventHandler(cache.ResourceEventHandlerFuncs{})
-	store.informers.Pod.AddEventHandler(podEventHandler)
+	// the changes of the Endpoints follow the other changes of the Pods
+	bpEventHandler := cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, cur interface{}) {
+			oldPod := old.(*corev1.Pod)
+			curPod := cur.(*corev1.Pod)
+
+			if reflect.DeepEqual(backendPodContent(oldPod), backendPodContent(curPod)) {
+				return
+			}
+
+			updateCh.In() <- Event{
+				Type: UpdateEvent,
+				Obj:  cur,
+			}
+		},
+	}
+
+	revisions := store.revisions
+	store.informers.Ingress.AddEventHandler(revisions.handler("Ingress", ingressContent, ingEventHandler))
+	store.informers.Endpoint.AddEventHandler(revisions.handler("Endpoints", endpointsContent, epEventHandler))
+	store.secretHandler = revisions.handler("Secret", secretContent, secrEventHandler)
+	if store.informers.Secret != nil {
+		store.informers.Secret.AddEventHandler(store.secretHandler)
+	}
+	store.informers.ConfigMap.AddEventHandler(revisions.handler("ConfigMap", configMapContent, cmEventHandler))
+	store.informers.Service.AddEventHandler(revisions.handler("Service", serviceContent, cache.ResourceEventHandlerFuncs{}))
+	store.informers.Pod.AddEventHandler(revisions.handler("Pod", podContent, podEventHandler))
+	if store.informers.BackendPod != nil {
+		store.informers.BackendPod.AddEventHandler(revisions.handler("BackendPod", backendPodContent, bpEventHandler))
+	}
+	if store.informers.HostDelegation != nil {
+		store.informers.HostDelegation.AddEventHandler(revisions.handler("HostDelegation", hostDelegationContent, hdEventHandler))
+	}
+	if store.informers.IngressClassParams != nil {
+		store.informers.IngressClassParams.AddEventHandler(revisions.handler("NginxIngressClassParams", ingressClassParamsContent, icpEventHandler))
+	}
 
 	// do not wait for informers to read the configmap configuration
 	ns, name, _ := k8s.ParseNameNS(configmap)
@@ -630,7 +971,7 @@ func isCatchAllIngress(spec networkingv1beta1.IngressSpec) bool {
 // annotation to a go struct
 func (s *k8sStore) syncIngress(ing *networkingv1beta1.Ingress) {
 	key := k8s.MetaNamespaceKey(ing)
-	klog.V(3).Infof("updating annotations information for ingress %v", key)
+	logging.V(3).Infof("updating annotations information for ingress %v", key)
 
 	copyIng := &networkingv1beta1.Ingress{}
 	ing.ObjectMeta.DeepCopyInto(&copyIng.ObjectMeta)
@@ -649,20 +990,190 @@ func (s *k8sStore) syncIngress(ing *networkingv1beta1.Ingress) {
 		}
 	}
 
+	parsed := s.annotations.Extract(s.ingressWithDefaults(ing))
+	if s.strictAnnotationValidation {
+		if err := annotations.ValidationError(parsed); err != nil {
+			klog.Errorf("ignoring ingress %v because of --strict-annotation-validation: %v", key, err)
+			s.recorder.Event(ing, corev1.EventTypeWarning, "AnnotationValidation", err.Error())
+
+			err = s.listers.IngressWithAnnotation.Delete(ing)
+			if err != nil {
+				klog.Error(err)
+			}
+			return
+		}
+	}
+
+	// the events are only recorded when the invalid annotations change, the
+	// previous ones are removed with the Ingress
+	if prev, err := s.listers.IngressWithAnnotation.ByKey(key); err != nil || prev.ParsedAnnotations == nil ||
+		!invalidAnnotationsEqual(prev.ParsedAnnotations.InvalidAnnotations, parsed.InvalidAnnotations) {
+		for _, invalid := range parsed.InvalidAnnotations {
+			s.recordInvalidAnnotation(ing, invalid)
+		}
+	}
+
 	err := s.listers.IngressWithAnnotation.Update(&ingress.Ingress{
 		Ingress:           *copyIng,
-		ParsedAnnotations: s.annotations.Extract(ing),
+		ParsedAnnotations: parsed,
 	})
 	if err != nil {
 		klog.Error(err)
 	}
 }
 
+func invalidAnnotationsEqual(a, b []parser.InvalidAnnotation) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// recordInvalidAnnotation logs and records an event for an annotation
+// ignored because of an invalid value
+func (s *k8sStore) recordInvalidAnnotation(ing *networkingv1beta1.Ingress, invalid parser.InvalidAnnotation) {
+	defValue := "the default value"
+	if invalid.Default != "" {
+		defValue = fmt.Sprintf("the default value %q", invalid.Default)
+	}
+
+	msg := fmt.Sprintf("annotation %v contains an invalid value %q, using %v", invalid.Name, invalid.Value, defValue)
+	klog.Warningf("Ingress %v/%v: %v", ing.Namespace, ing.Name, msg)
+
+	s.recorder.Event(ing, corev1.EventTypeWarning, "InvalidAnnotation", msg)
+}
+
+// ingressWithDefaults returns a copy of the Ingress containing the annotations
+// used to configure it, merging the defaults of the namespace with the
+// annotations of the Ingress and removing the annotations not allowed by the
+// allowed-annotation-overrides setting. The parameters of the class of the
+// Ingress restrict the allowed annotations and define the default timeouts.
+func (s *k8sStore) ingressWithDefaults(ing *networkingv1beta1.Ingress) *networkingv1beta1.Ingress {
+	cfg := s.GetBackendConfiguration()
+	// the parameters are nil when the class does not have parameters
+	params, _ := s.GetIngressClassParams(class.Of(ing))
+
+	if cfg.NamespaceDefaultsConfigMap == "" && len(cfg.AllowedAnnotationOverrides) == 0 && params == nil {
+		return ing
+	}
+
+	defaults := map[string]string{}
+	if cfg.NamespaceDefaultsConfigMap != "" {
+		key := fmt.Sprintf("%v/%v", ing.Namespace, cfg.NamespaceDefaultsConfigMap)
+		cmap, err := s.listers.ConfigMap.ByKey(key)
+		if err == nil {
+			defaults = cmap.Data
+		} else {
+			logging.V(3).Infof("no namespace defaults for ingress %v/%v: %v", ing.Namespace, ing.Name, err)
+		}
+	}
+
+	annotations := ing.GetAnnotations()
+	if params != nil {
+		var ignored []string
+		annotations, ignored = parser.MergeAnnotations(annotations, defaults, params.Spec.AllowedAnnotations)
+		if len(ignored) > 0 {
+			klog.Warningf("ignoring annotations %v of ingress %v/%v: not included in the allowed annotations of NginxIngressClassParams %v", strings.Join(ignored, ", "), ing.Namespace, ing.Name, params.Name)
+		}
+
+		// the annotations are merged, only the timeouts of the class remain
+		defaults = classTimeouts(params)
+	}
+
+	annotations, ignored := parser.MergeAnnotations(annotations, defaults, cfg.AllowedAnnotationOverrides)
+	if len(ignored) > 0 {
+		klog.Warningf("ignoring annotations %v of ingress %v/%v: not included in %v", strings.Join(ignored, ", "), ing.Namespace, ing.Name, "allowed-annotation-overrides")
+	}
+
+	withDefaults := ing.DeepCopy()
+	withDefaults.SetAnnotations(annotations)
+
+	return withDefaults
+}
+
+// isNamespaceDefaults checks if the ConfigMap contains the default
+// annotations of a namespace
+func (s *k8sStore) isNamespaceDefaults(cmap *corev1.ConfigMap) bool {
+	name := s.GetBackendConfiguration().NamespaceDefaultsConfigMap
+	return name != "" && cmap.Name == name
+}
+
+// classTimeouts returns the default timeouts of the Ingresses of a class as
+// annotations without prefix
+func classTimeouts(params *v1alpha1.NginxIngressClassParams) map[string]string {
+	timeouts := map[string]string{}
+	if params.Spec.Timeouts == nil {
+		return timeouts
+	}
+
+	for name, value := range map[string]int{
+		"proxy-connect-timeout": params.Spec.Timeouts.Connect,
+		"proxy-send-timeout":    params.Spec.Timeouts.Send,
+		"proxy-read-timeout":    params.Spec.Timeouts.Read,
+	} {
+		if value > 0 {
+			timeouts[name] = strconv.Itoa(value)
+		}
+	}
+
+	return timeouts
+}
+
+// syncIngressClassParams reads the default certificate of a class and parses
+// again the annotations of the Ingresses of the class
+func (s *k8sStore) syncIngressClassParams(params *v1alpha1.NginxIngressClassParams) {
+	if params.Spec.DefaultSSLCertificate != "" {
+		s.syncSecret(params.Spec.DefaultSSLCertificate)
+	}
+
+	s.syncClassIngresses(params.Name)
+}
+
+// syncClassIngresses parses again the annotations of the Ingresses of a class
+func (s *k8sStore) syncClassIngresses(ingressClass string) {
+	for _, ingKey := range s.listers.IngressWithAnnotation.List() {
+		key := k8s.MetaNamespaceKey(ingKey)
+		ing, err := s.getIngress(key)
+		if err != nil {
+			klog.Errorf("could not find Ingress %v in local store: %v", key, err)
+			continue
+		}
+		if class.Of(ing) != ingressClass {
+			continue
+		}
+		s.syncIngress(ing)
+	}
+}
+
+// syncNamespaceIngresses parses again the annotations of the Ingresses
+// located in a namespace
+func (s *k8sStore) syncNamespaceIngresses(namespace string) {
+	for _, ingKey := range s.listers.IngressWithAnnotation.List() {
+		key := k8s.MetaNamespaceKey(ingKey)
+		ing, err := s.getIngress(key)
+		if err != nil {
+			klog.Errorf("could not find Ingress %v in local store: %v", key, err)
+			continue
+		}
+		if ing.Namespace != namespace {
+			continue
+		}
+		s.syncIngress(ing)
+	}
+}
+
 // updateSecretIngressMap takes an Ingress and updates all Secret objects it
 // references in secretIngressMap.
 func (s *k8sStore) updateSecretIngressMap(ing *networkingv1beta1.Ingress) {
 	key := k8s.MetaNamespaceKey(ing)
-	klog.V(3).Infof("updating references to secrets for ingress %v", key)
+	logging.V(3).Infof("updating references to secrets for ingress %v", key)
 
 	// delete all existing references first
 	s.secretIngressMap.Delete(key)
@@ -684,9 +1195,12 @@ func (s *k8sStore) updateSecretIngressMap(ing *networkingv1beta1.Ingress) {
 	secretAnnotations := []string{
 		"auth-secret",
 		"auth-tls-secret",
+		"proxy-chain-secret",
+		"proxy-ssl-secret",
 	}
+	withDefaults := s.ingressWithDefaults(ing)
 	for _, ann := range secretAnnotations {
-		secrKey, err := objectRefAnnotationNsKey(ann, ing)
+		secrKey, err := objectRefAnnotationNsKey(ann, withDefaults)
 		if err != nil && !errors.IsMissingAnnotations(err) {
 			klog.Errorf("error reading secret reference in annotation %q: %s", ann, err)
 			continue
@@ -700,6 +1214,57 @@ func (s *k8sStore) updateSecretIngressMap(ing *networkingv1beta1.Ingress) {
 	s.secretIngressMap.Insert(key, refSecrets...)
 }
 
+// updateConfigMapIngressMap takes an Ingress and updates all ConfigMap objects it
+// references in configMapIngressMap.
+func (s *k8sStore) updateConfigMapIngressMap(ing *networkingv1beta1.Ingress) {
+	key := k8s.MetaNamespaceKey(ing)
+	logging.V(3).Infof("updating references to configmaps for ingress %v", key)
+
+	// delete all existing references first
+	s.configMapIngressMap.Delete(key)
+
+	var refConfigMaps []string
+
+	configMapAnnotations := []string{
+		"proxy-ssl-ca-configmap",
+	}
+	withDefaults := s.ingressWithDefaults(ing)
+	for _, ann := range configMapAnnotations {
+		cmKey, err := objectRefAnnotationNsKey(ann, withDefaults)
+		if err != nil && !errors.IsMissingAnnotations(err) {
+			klog.Errorf("error reading configmap reference in annotation %q: %s", ann, err)
+			continue
+		}
+		if cmKey != "" {
+			refConfigMaps = append(refConfigMaps, cmKey)
+		}
+	}
+
+	// populate map with all configmap references
+	s.configMapIngressMap.Insert(key, refConfigMaps...)
+}
+
+// syncConfigMapIngresses parses again the Ingresses referencing the ConfigMap
+// in their annotations. It returns false when the ConfigMap is not referenced.
+func (s *k8sStore) syncConfigMapIngresses(key string) bool {
+	ings := s.configMapIngressMap.Reference(key)
+	if len(ings) == 0 {
+		return false
+	}
+
+	klog.Infof("configmap %v is used in ingress annotations. Parsing...", key)
+	for _, ingKey := range ings {
+		ing, err := s.getIngress(ingKey)
+		if err != nil {
+			klog.Errorf("could not find Ingress %v in local store", ingKey)
+			continue
+		}
+		s.syncIngress(ing)
+	}
+
+	return true
+}
+
 // objectRefAnnotationNsKey returns an object reference formatted as a
 // 'namespace/name' key from the given annotation name.
 func objectRefAnnotationNsKey(ann string, ing *networkingv1beta1.Ingress) (string, error) {
@@ -728,9 +1293,37 @@ func (s *k8sStore) syncSecrets(ing *networkingv1beta1.Ingress) {
 	}
 }
 
+// isConfigurationSecret returns true when the Secret is read by the
+// controller because of the configuration ConfigMap, e.g. the key of the
+// debug tokens, so its changes must be applied
+func (s *k8sStore) isConfigurationSecret(key string) bool {
+	return key == s.GetBackendConfiguration().DebugTokenSecret
+}
+
+// isDefaultSSLCertificate returns true when the Secret contains the default
+// certificate of the controller or of an ingress class
+func (s *k8sStore) isDefaultSSLCertificate(key string) bool {
+	if key == s.defaultSSLCertificate || class.IsDefaultSSLCertificate(key) {
+		return true
+	}
+
+	for _, params := range s.ListIngressClassParams() {
+		if params.Spec.DefaultSSLCertificate == key {
+			return true
+		}
+	}
+
+	return false
+}
+
 // GetSecret returns the Secret matching key.
 func (s *k8sStore) GetSecret(key string) (*corev1.Secret, error) {
-	return s.listers.Secret.ByKey(key)
+	secret, err := s.listers.Secret.ByKey(key)
+	if err == nil || s.secretReader == nil {
+		return secret, err
+	}
+
+	return s.readSecret(key)
 }
 
 // ListLocalSSLCerts returns the list of local SSLCerts
@@ -745,6 +1338,12 @@ func (s *k8sStore) ListLocalSSLCerts() []*ingress.SSLCert {
 	return certs
 }
 
+// GetSecretReferences returns the keys of the Ingresses referencing the Secret
+// matching key, in the TLS section or in an annotation.
+func (s *k8sStore) GetSecretReferences(key string) []string {
+	return s.secretIngressMap.Reference(key)
+}
+
 // GetService returns the Service matching key.
 func (s *k8sStore) GetService(key string) (*corev1.Service, error) {
 	return s.listers.Service.ByKey(key)
@@ -784,6 +1383,51 @@ func (s *k8sStore) ListIngresses(filter IngressFilterFunc) []*ingress.Ingress {
 	return ingresses
 }
 
+// ListHostDelegations returns the list of HostDelegations sorted
+// using the CreationTimestamp field
+func (s *k8sStore) ListHostDelegations() []*v1alpha1.HostDelegation {
+	if s.listers.HostDelegation.Store == nil {
+		return nil
+	}
+
+	delegations := make([]*v1alpha1.HostDelegation, 0)
+	for _, item := range s.listers.HostDelegation.List() {
+		delegations = append(delegations, item.(*v1alpha1.HostDelegation))
+	}
+
+	sort.SliceStable(delegations, func(i, j int) bool {
+		ir := delegations[i].CreationTimestamp
+		jr := delegations[j].CreationTimestamp
+		return ir.Before(&jr)
+	})
+
+	return delegations
+}
+
+// GetIngressClassParams returns the NginxIngressClassParams named like the
+// ingress class
+func (s *k8sStore) GetIngressClassParams(ingressClass string) (*v1alpha1.NginxIngressClassParams, error) {
+	if s.listers.IngressClassParams.Store == nil {
+		return nil, NotExistsError(ingressClass)
+	}
+
+	return s.listers.IngressClassParams.ByKey(ingressClass)
+}
+
+// ListIngressClassParams returns the list of NginxIngressClassParams
+func (s *k8sStore) ListIngressClassParams() []*v1alpha1.NginxIngressClassParams {
+	if s.listers.IngressClassParams.Store == nil {
+		return nil
+	}
+
+	params := make([]*v1alpha1.NginxIngressClassParams, 0)
+	for _, item := range s.listers.IngressClassParams.List() {
+		params = append(params, item.(*v1alpha1.NginxIngressClassParams))
+	}
+
+	return params
+}
+
 // GetLocalSSLCert returns the local copy of a SSLCert
 func (s *k8sStore) GetLocalSSLCert(key string) (*ingress.SSLCert, error) {
 	return s.sslStore.ByKey(key)
@@ -799,6 +1443,37 @@ func (s *k8sStore) GetServiceEndpoints(key string) (*corev1.Endpoints, error) {
 	return s.listers.Endpoint.ByKey(key)
 }
 
+// GetEndpointWeight returns the weight of the endpoint provided by target,
+// read from the endpoint-weight annotation of the Pod. 0 means the default weight.
+func (s *k8sStore) GetEndpointWeight(target *corev1.ObjectReference) int {
+	if !s.endpointWeights || target == nil || target.Kind != "Pod" {
+		return 0
+	}
+
+	pod, err := s.listers.BackendPod.ByKey(fmt.Sprintf("%v/%v", target.Namespace, target.Name))
+	if err != nil {
+		return 0
+	}
+
+	return endpointWeight(pod)
+}
+
+// HasEndpointCondition returns true when the Pod providing the endpoint
+// contains the condition with the status True. The endpoints not provided by
+// a Pod always have the condition, the Pods missing in the store never.
+func (s *k8sStore) HasEndpointCondition(target *corev1.ObjectReference, condition string) bool {
+	if !s.endpointConditions || target == nil || target.Kind != "Pod" {
+		return true
+	}
+
+	pod, err := s.listers.BackendPod.ByKey(fmt.Sprintf("%v/%v", target.Namespace, target.Name))
+	if err != nil {
+		return false
+	}
+
+	return hasCondition(pod, condition)
+}
+
 // GetAuthCertificate is used by the auth-tls annotations to get a cert from a secret
 func (s *k8sStore) GetAuthCertificate(name string) (*resolver.AuthSSLCert, error) {
 	if _, err := s.GetLocalSSLCert(name); err != nil {
@@ -870,6 +1545,16 @@ func (s *k8sStore) setConfig(cmap *corev1.ConfigMap) {
 func (s *k8sStore) Run(stopCh chan struct{}) {
 	// start informers
 	s.informers.Run(stopCh)
+
+	if s.secretReader != nil {
+		go wait.Until(s.refreshSecrets, secretRefreshInterval, stopCh)
+	}
+
+	go wait.Until(s.verifySSLCertificates, sslIntegrityCheckInterval, stopCh)
+
+	if ngx_config.EnableOCSPStapling {
+		go s.runOCSPRefresh(stopCh)
+	}
 }
 
 // GetRunningControllerPodsCount returns the number of Running ingress-nginx controller Pods
@@ -889,6 +1574,23 @@ func (s k8sStore) GetRunningControllerPodsCount() int {
 	return count
 }
 
+// ListControllerPodNames returns the names of the ingress-nginx controller Pods
+func (s k8sStore) ListControllerPodNames() []string {
+	names := []string{}
+
+	for _, i := range s.listers.Pod.List() {
+		pod := i.(*corev1.Pod)
+		names = append(names, pod.Name)
+	}
+
+	return names
+}
+
+// GetObjectsRevision returns the revision of the objects used to build the configuration
+func (s *k8sStore) GetObjectsRevision() (uint64, bool) {
+	return s.revisions.Revision()
+}
+
 var runtimeScheme = k8sruntime.NewScheme()
 
 func init() {
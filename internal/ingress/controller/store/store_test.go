@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -36,13 +38,17 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 
 	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
 	"k8s.io/ingress-nginx/internal/k8s"
+	"k8s.io/ingress-nginx/pkg/apis/nginxingress/v1alpha1"
 	"k8s.io/ingress-nginx/test/e2e/framework"
 )
 
@@ -99,6 +105,12 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
+			false,
+			false,
+			nil,
+			nil,
+			nil,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -179,6 +191,12 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
+			false,
+			false,
+			nil,
+			nil,
+			nil,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -329,6 +347,12 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
+			false,
+			false,
+			nil,
+			nil,
+			nil,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -435,6 +459,12 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
+			false,
+			false,
+			nil,
+			nil,
+			nil,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -524,6 +554,12 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
+			false,
+			false,
+			nil,
+			nil,
+			nil,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -635,6 +671,12 @@ func TestStore(t *testing.T) {
 			fs,
 			updateCh,
 			pod,
+			false,
+			false,
+			nil,
+			nil,
+			nil,
+			false,
 			false)
 
 		storer.Run(stopCh)
@@ -851,14 +893,253 @@ func newStore(t *testing.T) *k8sStore {
 			Ingress:               IngressLister{cache.NewStore(cache.MetaNamespaceKeyFunc)},
 			IngressWithAnnotation: IngressWithAnnotationsLister{cache.NewStore(cache.DeletionHandlingMetaNamespaceKeyFunc)},
 			Pod:                   PodLister{cache.NewStore(cache.MetaNamespaceKeyFunc)},
+			ConfigMap:             ConfigMapLister{cache.NewStore(cache.MetaNamespaceKeyFunc)},
 		},
-		sslStore:         NewSSLCertTracker(),
-		filesystem:       fs,
-		updateCh:         channels.NewRingChannel(10),
-		syncSecretMu:     new(sync.Mutex),
-		backendConfigMu:  new(sync.RWMutex),
-		secretIngressMap: NewObjectRefMap(),
-		pod:              pod,
+		sslStore:            NewSSLCertTracker(),
+		filesystem:          fs,
+		updateCh:            channels.NewRingChannel(10),
+		revisions:           newObjectRevisions(),
+		syncSecretMu:        new(sync.Mutex),
+		backendConfigMu:     new(sync.RWMutex),
+		secretIngressMap:    NewObjectRefMap(),
+		configMapIngressMap: NewObjectRefMap(),
+		pod:                 pod,
+		recorder:            record.NewFakeRecorder(10),
+	}
+}
+
+func TestIngressWithDefaults(t *testing.T) {
+	s := newStore(t)
+
+	s.listers.ConfigMap.Add(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ingress-defaults",
+			Namespace: "testns",
+		},
+		Data: map[string]string{
+			"proxy-body-size": "8m",
+			"ssl-redirect":    "false",
+		},
+	})
+
+	ing := &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "testns",
+			Annotations: map[string]string{
+				parser.GetAnnotationWithPrefix("proxy-body-size"): "1m",
+				parser.GetAnnotationWithPrefix("rewrite-target"):  "/",
+			},
+		},
+	}
+
+	t.Run("without namespace defaults", func(t *testing.T) {
+		s.backendConfig = ngx_config.NewDefault()
+
+		if withDefaults := s.ingressWithDefaults(ing); withDefaults != ing {
+			t.Errorf("expected the same ingress to be returned")
+		}
+	})
+
+	t.Run("with namespace defaults", func(t *testing.T) {
+		s.backendConfig = ngx_config.NewDefault()
+		s.backendConfig.NamespaceDefaultsConfigMap = "ingress-defaults"
+
+		expected := map[string]string{
+			parser.GetAnnotationWithPrefix("proxy-body-size"): "1m",
+			parser.GetAnnotationWithPrefix("rewrite-target"):  "/",
+			parser.GetAnnotationWithPrefix("ssl-redirect"):    "false",
+		}
+
+		withDefaults := s.ingressWithDefaults(ing)
+		if !reflect.DeepEqual(withDefaults.GetAnnotations(), expected) {
+			t.Errorf("expected annotations %v but %v were returned", expected, withDefaults.GetAnnotations())
+		}
+		if len(ing.GetAnnotations()) != 2 {
+			t.Errorf("expected the annotations of the original ingress to be unchanged")
+		}
+	})
+
+	t.Run("with allowed annotation overrides", func(t *testing.T) {
+		s.backendConfig = ngx_config.NewDefault()
+		s.backendConfig.NamespaceDefaultsConfigMap = "ingress-defaults"
+		s.backendConfig.AllowedAnnotationOverrides = []string{"proxy-body-size"}
+
+		expected := map[string]string{
+			parser.GetAnnotationWithPrefix("proxy-body-size"): "1m",
+		}
+
+		withDefaults := s.ingressWithDefaults(ing)
+		if !reflect.DeepEqual(withDefaults.GetAnnotations(), expected) {
+			t.Errorf("expected annotations %v but %v were returned", expected, withDefaults.GetAnnotations())
+		}
+	})
+
+	t.Run("with ingress class parameters", func(t *testing.T) {
+		s.backendConfig = ngx_config.NewDefault()
+		s.backendConfig.NamespaceDefaultsConfigMap = "ingress-defaults"
+		s.listers.IngressClassParams.Store = cache.NewStore(cache.MetaNamespaceKeyFunc)
+		defer func() { s.listers.IngressClassParams.Store = nil }()
+
+		s.listers.IngressClassParams.Add(&v1alpha1.NginxIngressClassParams{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "nginx",
+			},
+			Spec: v1alpha1.NginxIngressClassParamsSpec{
+				AllowedAnnotations: []string{"proxy-body-size", "ssl-redirect", "proxy-read-timeout"},
+				Timeouts: &v1alpha1.Timeouts{
+					Connect: 2,
+					Read:    120,
+				},
+			},
+		})
+
+		expected := map[string]string{
+			parser.GetAnnotationWithPrefix("proxy-body-size"):       "1m",
+			parser.GetAnnotationWithPrefix("ssl-redirect"):          "false",
+			parser.GetAnnotationWithPrefix("proxy-connect-timeout"): "2",
+			parser.GetAnnotationWithPrefix("proxy-read-timeout"):    "120",
+		}
+
+		withDefaults := s.ingressWithDefaults(ing)
+		if !reflect.DeepEqual(withDefaults.GetAnnotations(), expected) {
+			t.Errorf("expected annotations %v but %v were returned", expected, withDefaults.GetAnnotations())
+		}
+
+		other := ing.DeepCopy()
+		other.Annotations[class.IngressKey] = "internal"
+		if withDefaults := s.ingressWithDefaults(other); len(withDefaults.GetAnnotations()) != 4 {
+			t.Errorf("expected the parameters to be ignored for another class but %v were returned", withDefaults.GetAnnotations())
+		}
+	})
+}
+
+func TestRecordInvalidAnnotation(t *testing.T) {
+	s := newStore(t)
+
+	ing := &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "testns",
+		},
+	}
+
+	s.recordInvalidAnnotation(ing, parser.InvalidAnnotation{
+		Name:    parser.GetAnnotationWithPrefix("proxy-connect-timeout"),
+		Value:   "abc",
+		Default: "5",
+	})
+	s.recordInvalidAnnotation(ing, parser.InvalidAnnotation{
+		Name:  parser.GetAnnotationWithPrefix("pod-routing-by"),
+		Value: "x-pod",
+	})
+
+	expected := []string{
+		fmt.Sprintf(`Warning InvalidAnnotation annotation %v contains an invalid value "abc", using the default value "5"`, parser.GetAnnotationWithPrefix("proxy-connect-timeout")),
+		fmt.Sprintf(`Warning InvalidAnnotation annotation %v contains an invalid value "x-pod", using the default value`, parser.GetAnnotationWithPrefix("pod-routing-by")),
+	}
+
+	events := s.recorder.(*record.FakeRecorder).Events
+	for _, e := range expected {
+		select {
+		case event := <-events:
+			if event != e {
+				t.Errorf("expected event %q but %q was recorded", e, event)
+			}
+		default:
+			t.Errorf("expected event %q but none was recorded", e)
+		}
+	}
+}
+
+func TestIsConfigurationSecret(t *testing.T) {
+	s := newStore(t)
+	if s.isConfigurationSecret("default/debug-token") {
+		t.Errorf("expected no configuration secret without debug token secret")
+	}
+
+	s.backendConfig.DebugTokenSecret = "default/debug-token"
+	if !s.isConfigurationSecret("default/debug-token") {
+		t.Errorf("expected the debug token secret to be a configuration secret")
+	}
+	if s.isConfigurationSecret("default/other") {
+		t.Errorf("expected other secrets not to be configuration secrets")
+	}
+}
+
+func TestSyncIngressRecordsChangedInvalidAnnotations(t *testing.T) {
+	s := newStore(t)
+	s.annotations = annotations.NewAnnotationExtractor(s)
+	events := s.recorder.(*record.FakeRecorder).Events
+
+	ing := &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "testns",
+			Annotations: map[string]string{
+				parser.GetAnnotationWithPrefix("ssl-passthrough"): "yes",
+			},
+		},
+	}
+
+	testCases := []struct {
+		name   string
+		update func()
+		events int
+	}{
+		{"new Ingress", func() {}, 1},
+		{"same invalid annotations", func() {}, 0},
+		{"changed invalid value", func() { ing.Annotations[parser.GetAnnotationWithPrefix("ssl-passthrough")] = "no way" }, 1},
+		{"deleted Ingress", func() { s.listers.IngressWithAnnotation.Delete(ing) }, 1},
+	}
+
+	for _, tc := range testCases {
+		tc.update()
+		s.syncIngress(ing)
+
+		if len(events) != tc.events {
+			t.Errorf("%v: expected %v events but %v were recorded", tc.name, tc.events, len(events))
+		}
+
+		for len(events) > 0 {
+			<-events
+		}
+	}
+}
+
+func TestSyncIngressWithStrictAnnotationValidation(t *testing.T) {
+	s := newStore(t)
+	s.annotations = annotations.NewAnnotationExtractor(s)
+	s.strictAnnotationValidation = true
+
+	ing := &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "testns",
+			Annotations: map[string]string{
+				parser.GetAnnotationWithPrefix("proxy-body-size"): "1m",
+			},
+		},
+	}
+
+	s.syncIngress(ing)
+	if len(s.ListIngresses(nil)) != 1 {
+		t.Fatalf("expected the ingress with valid annotations to be added")
+	}
+
+	ing.ObjectMeta.Annotations[parser.GetAnnotationWithPrefix("proxy-body-szie")] = "1m"
+	s.syncIngress(ing)
+	if len(s.ListIngresses(nil)) != 0 {
+		t.Errorf("expected the ingress with an unknown annotation to be removed")
+	}
+
+	select {
+	case event := <-s.recorder.(*record.FakeRecorder).Events:
+		if !strings.HasPrefix(event, "Warning AnnotationValidation") {
+			t.Errorf("unexpected event %q", event)
+		}
+	default:
+		t.Errorf("expected an event but none was recorded")
 	}
 }
 
@@ -926,6 +1207,66 @@ func TestUpdateSecretIngressMap(t *testing.T) {
 	})
 }
 
+func TestUpdateConfigMapIngressMap(t *testing.T) {
+	s := newStore(t)
+
+	ing := &networking.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "testns",
+			Annotations: map[string]string{
+				parser.GetAnnotationWithPrefix("proxy-ssl-ca-configmap"): "testns/ca",
+			},
+		},
+	}
+	s.listers.Ingress.Add(ing)
+	s.updateConfigMapIngressMap(ing)
+
+	if l := s.configMapIngressMap.Len(); !(l == 1 && s.configMapIngressMap.Has("testns/ca")) {
+		t.Errorf("Expected \"testns/ca\" to be the only referenced ConfigMap (got %d)", l)
+	}
+
+	if !s.syncConfigMapIngresses("testns/ca") {
+		t.Errorf("Expected the ingresses referencing \"testns/ca\" to be synced")
+	}
+
+	if s.syncConfigMapIngresses("testns/other") {
+		t.Errorf("Expected no ingress referencing \"testns/other\"")
+	}
+}
+
+func TestGetConfigMapCACertificate(t *testing.T) {
+	s := newStore(t)
+
+	ca, err := base64.StdEncoding.DecodeString(tlsCrt)
+	if err != nil {
+		t.Fatalf("unexpected error decoding the certificate: %v", err)
+	}
+
+	s.listers.ConfigMap.Add(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: "testns"},
+		Data:       map[string]string{"ca.crt": string(ca)},
+	})
+	s.listers.ConfigMap.Add(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "empty", Namespace: "testns"},
+	})
+
+	cert, err := s.GetConfigMapCACertificate("testns/ca")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cert.Secret != "testns/ca" || cert.CAFileName != fmt.Sprintf("%v/ca-configmap-testns-ca.pem", file.DefaultSSLDirectory) {
+		t.Errorf("unexpected CA certificate %+v", cert)
+	}
+
+	for _, name := range []string{"testns/empty", "testns/unknown"} {
+		if _, err := s.GetConfigMapCACertificate(name); err == nil {
+			t.Errorf("expected an error obtaining the CA from %v", name)
+		}
+	}
+}
+
 func TestListIngresses(t *testing.T) {
 	s := newStore(t)
 
@@ -24,6 +24,8 @@ import (
 	"k8s.io/klog"
 
 	"github.com/paultag/sniff/parser"
+
+	"k8s.io/ingress-nginx/internal/logging"
 )
 
 // TCPServer describes a server that works in passthrough mode.
@@ -38,6 +40,11 @@ type TCPServer struct {
 type TCPProxy struct {
 	ServerList []*TCPServer
 	Default    *TCPServer
+
+	// OnClientHello is called with the TLS Client Hello of the connections
+	// proxied to the default server, before it is forwarded. It must not
+	// block the connection.
+	OnClientHello func(hello []byte, conn net.Conn)
 }
 
 // Get returns the TCPServer to use for a given host.
@@ -63,19 +70,19 @@ func (p *TCPProxy) Handle(conn net.Conn) {
 
 	length, err := conn.Read(data)
 	if err != nil {
-		klog.V(4).Infof("Error reading the first 4k of the connection: %v", err)
+		logging.V(4).Infof("Error reading the first 4k of the connection: %v", err)
 		return
 	}
 
 	proxy := p.Default
 	hostname, err := parser.GetHostname(data[:])
 	if err == nil {
-		klog.V(4).Infof("Parsed hostname from TLS Client Hello: %s", hostname)
+		logging.V(4).Infof("Parsed hostname from TLS Client Hello: %s", hostname)
 		proxy = p.Get(hostname)
 	}
 
 	if proxy == nil {
-		klog.V(4).Info("There is no configured proxy for SSL connections.")
+		logging.V(4).Info("There is no configured proxy for SSL connections.")
 		return
 	}
 
@@ -96,13 +103,17 @@ func (p *TCPProxy) Handle(conn net.Conn) {
 			protocol = "TCP6"
 		}
 		proxyProtocolHeader := fmt.Sprintf("PROXY %s %s %s %d %d\r\n", protocol, remoteAddr.IP.String(), localAddr.IP.String(), remoteAddr.Port, localAddr.Port)
-		klog.V(4).Infof("Writing Proxy Protocol header: %s", proxyProtocolHeader)
+		logging.V(4).Infof("Writing Proxy Protocol header: %s", proxyProtocolHeader)
 		_, err = fmt.Fprintf(clientConn, proxyProtocolHeader)
 	}
 	if err != nil {
 		klog.Errorf("Error writing Proxy Protocol header: %v", err)
 		clientConn.Close()
 	} else {
+		if proxy == p.Default && p.OnClientHello != nil {
+			p.OnClientHello(data[:length], clientConn)
+		}
+
 		_, err = clientConn.Write(data[:length])
 		if err != nil {
 			klog.Errorf("Error writing the first 4k of proxy data: %v", err)
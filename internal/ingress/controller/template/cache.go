@@ -0,0 +1,259 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	text_template "text/template"
+
+	"github.com/mitchellh/hashstructure"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/logging"
+)
+
+// serverCache keeps the rendered server blocks of the last configurations,
+// keyed by a hash of the server and of the global configuration used by
+// the SERVER template, so only the servers that changed are rendered again
+type serverCache struct {
+	mu sync.Mutex
+
+	generation uint64
+	entries    map[string]*cachedServer
+}
+
+type cachedServer struct {
+	content    string
+	generation uint64
+}
+
+func newServerCache() *serverCache {
+	return &serverCache{
+		entries: map[string]*cachedServer{},
+	}
+}
+
+// serverRenderer renders the server blocks of a single configuration
+type serverRenderer struct {
+	cache      *serverCache
+	tmpl       *text_template.Template
+	globalHash string
+	generation uint64
+
+	// the backends using SSL passthrough, the only field of the backends
+	// read by the SERVER template
+	passthroughBackends map[string]bool
+
+	// the server blocks rendered before the execution of the main template
+	rendered map[*ingress.Server]string
+
+	hits   int64
+	misses int64
+}
+
+// globalTemplateHash returns a hash of the global configuration used by
+// the SERVER template. The servers, the backends and the fields only used
+// outside the server blocks are excluded, the backends used by a server are
+// part of the key of its block.
+func globalTemplateHash(conf config.TemplateConfig) (string, error) {
+	conf.Servers = nil
+	conf.Backends = nil
+	conf.PassthroughBackends = nil
+	conf.TCPBackends = nil
+	conf.UDPBackends = nil
+	conf.RedirectServers = nil
+	conf.PublishService = nil
+	conf.Cfg.Checksum = ""
+
+	hash, err := hashstructure.Hash(conf, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%v", hash), nil
+}
+
+// newRenderer returns a renderer for a new configuration. If the hash of the
+// global configuration cannot be computed the servers are always rendered.
+func (c *serverCache) newRenderer(tmpl *text_template.Template, conf config.TemplateConfig) *serverRenderer {
+	c.mu.Lock()
+	c.generation++
+	generation := c.generation
+	c.mu.Unlock()
+
+	globalHash, err := globalTemplateHash(conf)
+	if err != nil {
+		klog.Warningf("Unexpected error computing the hash of the configuration, server blocks will not be cached: %v", err)
+	}
+
+	passthroughBackends := map[string]bool{}
+	for _, backend := range conf.Backends {
+		if backend.SSLPassthrough {
+			passthroughBackends[backend.Name] = true
+		}
+	}
+
+	return &serverRenderer{
+		cache:               c,
+		tmpl:                tmpl,
+		globalHash:          globalHash,
+		generation:          generation,
+		passthroughBackends: passthroughBackends,
+	}
+}
+
+// serverHash returns a hash of the server and of the fields of the backends
+// of its locations read by the SERVER template
+func (r *serverRenderer) serverHash(server *ingress.Server) (uint64, error) {
+	passthrough := []string{}
+	for _, location := range server.Locations {
+		if r.passthroughBackends[location.Backend] {
+			passthrough = append(passthrough, location.Backend)
+		}
+	}
+
+	return hashstructure.Hash(struct {
+		Server              *ingress.Server
+		PassthroughBackends []string
+	}{server, passthrough}, nil)
+}
+
+// prerender renders the server blocks of the configuration in parallel,
+// bounded by GOMAXPROCS, so the main template only copies them. The server
+// blocks are independent, the template can be executed concurrently.
+func (r *serverRenderer) prerender(all config.TemplateConfig) error {
+	servers := all.Servers
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(servers) {
+		workers = len(servers)
+	}
+	// the main template renders the servers without the goroutines
+	if workers < 2 {
+		return nil
+	}
+
+	contents := make([]string, len(servers))
+	errs := make([]error, len(servers))
+	next := int64(-1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				j := int(atomic.AddInt64(&next, 1))
+				if j >= len(servers) {
+					return
+				}
+
+				contents[j], errs[j] = r.renderServer(all, servers[j])
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	r.rendered = make(map[*ingress.Server]string, len(servers))
+	for j, server := range servers {
+		r.rendered[server] = contents[j]
+	}
+
+	return nil
+}
+
+// render returns the server block of a server, rendered before the
+// execution of the main template or using the SERVER template
+func (r *serverRenderer) render(all config.TemplateConfig, server *ingress.Server) (string, error) {
+	if content, ok := r.rendered[server]; ok {
+		return content, nil
+	}
+
+	return r.renderServer(all, server)
+}
+
+func (r *serverRenderer) renderServer(all config.TemplateConfig, server *ingress.Server) (string, error) {
+	key := ""
+	if r.globalHash != "" {
+		hash, err := r.serverHash(server)
+		if err == nil {
+			key = fmt.Sprintf("%v-%v", r.globalHash, hash)
+		}
+	}
+
+	if key != "" {
+		r.cache.mu.Lock()
+		entry, ok := r.cache.entries[key]
+		if ok {
+			entry.generation = r.generation
+		}
+		r.cache.mu.Unlock()
+
+		if ok {
+			atomic.AddInt64(&r.hits, 1)
+			return entry.content, nil
+		}
+	}
+
+	atomic.AddInt64(&r.misses, 1)
+
+	var buf bytes.Buffer
+	err := r.tmpl.ExecuteTemplate(&buf, "SERVER", struct{ First, Second interface{} }{all, server})
+	if err != nil {
+		return "", err
+	}
+
+	content := buf.String()
+	if key != "" {
+		r.cache.mu.Lock()
+		r.cache.entries[key] = &cachedServer{
+			content:    content,
+			generation: r.generation,
+		}
+		r.cache.mu.Unlock()
+	}
+
+	return content, nil
+}
+
+// finish removes the server blocks not used by this configuration or the
+// previous one, keeping entries used by a concurrent rendering
+func (r *serverRenderer) finish() {
+	r.cache.mu.Lock()
+	defer r.cache.mu.Unlock()
+
+	for key, entry := range r.cache.entries {
+		if entry.generation+1 < r.generation {
+			delete(r.cache.entries, key)
+		}
+	}
+
+	logging.V(3).Infof("Rendered %v server blocks, %v reused from the previous configuration", r.hits+r.misses, r.hits)
+}
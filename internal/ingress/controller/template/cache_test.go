@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"k8s.io/ingress-nginx/internal/file"
+	"k8s.io/ingress-nginx/internal/ingress/controller/config"
+)
+
+func readTemplateConfig(t *testing.T) config.TemplateConfig {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	return dat
+}
+
+func newTestTemplate(t *testing.T) *Template {
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	return ngxTpl
+}
+
+func write(t *testing.T, ngxTpl *Template, dat config.TemplateConfig) string {
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	return string(rt)
+}
+
+func TestServerCache(t *testing.T) {
+	dat := readTemplateConfig(t)
+	ngxTpl := newTestTemplate(t)
+
+	first := write(t, ngxTpl, dat)
+	if len(ngxTpl.servers.entries) != len(dat.Servers) {
+		t.Errorf("expected %v cached servers but there are %v", len(dat.Servers), len(ngxTpl.servers.entries))
+	}
+
+	second := write(t, ngxTpl, dat)
+	if first != second {
+		t.Errorf("expected the same configuration rendering the cached servers")
+	}
+
+	// a change in a server renders only its block again
+	dat.Servers[1].Locations[0].Path = "/cache-test"
+	changed := write(t, ngxTpl, dat)
+	if !strings.Contains(changed, "location /cache-test") {
+		t.Errorf("expected the configuration to contain the changed location")
+	}
+	if changed != write(t, newTestTemplate(t), dat) {
+		t.Errorf("expected the same configuration without cached servers")
+	}
+
+	// a change of the backend of a location renders its server block again
+	for _, backend := range dat.Backends {
+		if backend.Name == dat.Servers[1].Locations[0].Backend {
+			backend.SSLPassthrough = !backend.SSLPassthrough
+		}
+	}
+	if write(t, ngxTpl, dat) != write(t, newTestTemplate(t), dat) {
+		t.Errorf("expected the same configuration without cached servers")
+	}
+
+	// a change in the global configuration renders all the blocks again
+	dat.Cfg.UseProxyProtocol = !dat.Cfg.UseProxyProtocol
+	global := write(t, ngxTpl, dat)
+	if global != write(t, newTestTemplate(t), dat) {
+		t.Errorf("expected the same configuration without cached servers")
+	}
+
+	// the blocks of the previous configurations are removed
+	write(t, ngxTpl, dat)
+	if len(ngxTpl.servers.entries) != len(dat.Servers) {
+		t.Errorf("expected %v cached servers but there are %v", len(dat.Servers), len(ngxTpl.servers.entries))
+	}
+}
+
+func TestParallelRendering(t *testing.T) {
+	dat := readTemplateConfig(t)
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	sequential := write(t, newTestTemplate(t), dat)
+
+	runtime.GOMAXPROCS(4)
+	ngxTpl := newTestTemplate(t)
+	if parallel := write(t, ngxTpl, dat); parallel != sequential {
+		t.Errorf("expected the same configuration rendering the servers in parallel")
+	}
+	if len(ngxTpl.servers.entries) != len(dat.Servers) {
+		t.Errorf("expected %v cached servers but there are %v", len(dat.Servers), len(ngxTpl.servers.entries))
+	}
+
+	// the cached servers are reused by the goroutines
+	if parallel := write(t, ngxTpl, dat); parallel != sequential {
+		t.Errorf("expected the same configuration rendering the cached servers in parallel")
+	}
+}
@@ -19,6 +19,8 @@ package template
 import (
 	"fmt"
 	"net"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -32,35 +34,75 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
 	ing_net "k8s.io/ingress-nginx/internal/net"
+	"k8s.io/ingress-nginx/internal/net/dns"
 	"k8s.io/ingress-nginx/internal/runtime"
+	"k8s.io/ingress-nginx/internal/syslog"
 )
 
 const (
-	customHTTPErrors          = "custom-http-errors"
-	skipAccessLogUrls         = "skip-access-log-urls"
-	whitelistSourceRange      = "whitelist-source-range"
-	proxyRealIPCIDR           = "proxy-real-ip-cidr"
-	bindAddress               = "bind-address"
-	httpRedirectCode          = "http-redirect-code"
-	blockCIDRs                = "block-cidrs"
-	blockUserAgents           = "block-user-agents"
-	blockReferers             = "block-referers"
-	proxyStreamResponses      = "proxy-stream-responses"
-	hideHeaders               = "hide-headers"
-	nginxStatusIpv4Whitelist  = "nginx-status-ipv4-whitelist"
-	nginxStatusIpv6Whitelist  = "nginx-status-ipv6-whitelist"
-	proxyHeaderTimeout        = "proxy-protocol-header-timeout"
-	workerProcesses           = "worker-processes"
-	globalAuthURL             = "global-auth-url"
-	globalAuthMethod          = "global-auth-method"
-	globalAuthSignin          = "global-auth-signin"
-	globalAuthResponseHeaders = "global-auth-response-headers"
-	globalAuthRequestRedirect = "global-auth-request-redirect"
-	globalAuthSnippet         = "global-auth-snippet"
+	customHTTPErrors                  = "custom-http-errors"
+	skipAccessLogUrls                 = "skip-access-log-urls"
+	whitelistSourceRange              = "whitelist-source-range"
+	proxyRealIPCIDR                   = "proxy-real-ip-cidr"
+	bindAddress                       = "bind-address"
+	httpRedirectCode                  = "http-redirect-code"
+	blockCIDRs                        = "block-cidrs"
+	blockUserAgents                   = "block-user-agents"
+	blockReferers                     = "block-referers"
+	proxyStreamResponses              = "proxy-stream-responses"
+	hideHeaders                       = "hide-headers"
+	nginxStatusIpv4Whitelist          = "nginx-status-ipv4-whitelist"
+	nginxStatusIpv6Whitelist          = "nginx-status-ipv6-whitelist"
+	proxyHeaderTimeout                = "proxy-protocol-header-timeout"
+	workerProcesses                   = "worker-processes"
+	globalAuthURL                     = "global-auth-url"
+	globalAuthMethod                  = "global-auth-method"
+	globalAuthSignin                  = "global-auth-signin"
+	globalAuthResponseHeaders         = "global-auth-response-headers"
+	globalAuthRequestRedirect         = "global-auth-request-redirect"
+	globalAuthSnippet                 = "global-auth-snippet"
+	globalAuthResponseHeadersToClient = "global-auth-response-headers-to-client"
+	globalAuthForwardHeaders          = "global-auth-forward-headers"
+	globalAuthRequestHeaders          = "global-auth-request-headers"
+	globalAuthMaxBodySize             = "global-auth-max-body-size"
+	globalAuthCacheCookie             = "global-auth-cache-cookie"
+	allowedAnnotationOverrides        = "allowed-annotation-overrides"
+	accessLogDestinations             = "access-log-destinations"
+	errorLogDestinations              = "error-log-destinations"
+	accessEventsSink                  = "access-events-sink"
+	usageExportSink                   = "usage-export-sink"
+	usageExportFormat                 = "usage-export-format"
+	sharedStateBackend                = "shared-state-backend"
+	resolvers                         = "resolvers"
+	zoneResolvers                     = "zone-resolvers"
+	modsecurityAuditSink              = "modsecurity-audit-sink"
+	wafEngine                         = "waf-engine"
+	corazaURL                         = "coraza-url"
 )
 
 var (
 	validRedirectCodes = sets.NewInt([]int{301, 302, 307, 308}...)
+
+	validLogSeverities = sets.NewString("debug", "info", "notice", "warn", "error", "crit", "alert", "emerg")
+
+	validWAFEngines = sets.NewString("modsecurity", "coraza")
+
+	validUsageExportFormats = sets.NewString("json", "csv")
+
+	// logFormats maps the formats of the access log destinations to
+	// the name of the log_format defined in the template
+	logFormats = map[string]string{
+		"upstreaminfo": "upstreaminfo",
+		"json":         "upstreaminfo_json",
+	}
+
+	syslogTagRegex = regexp.MustCompile(`^[A-Za-z0-9_]{1,32}$`)
+
+	accessEventsSinkRegex     = regexp.MustCompile(`^(http|kafka)://[a-zA-Z0-9.-]+(:[0-9]+)?/[a-zA-Z0-9._~/-]*$`)
+	usageExportSinkRegex      = regexp.MustCompile(`^((http|kafka)://[a-zA-Z0-9.-]+(:[0-9]+)?/[a-zA-Z0-9._~/-]*|file://[a-zA-Z0-9._-]+)$`)
+	modsecurityAuditSinkRegex = regexp.MustCompile(`^https?://[a-zA-Z0-9.-]+(:[0-9]+)?/[a-zA-Z0-9._~/-]*$`)
+	corazaURLRegex            = regexp.MustCompile(`^https?://[a-zA-Z0-9.-]+(:[0-9]+)?/[a-zA-Z0-9._~/-]*$`)
+	sharedStateBackendRegex   = regexp.MustCompile(`^redis://(:[^@/]+@)?[a-zA-Z0-9.-]+(:[0-9]+)?(/[0-9]+)?$`)
 )
 
 // ReadConfig obtains the configuration defined by the user merged with the defaults.
@@ -85,6 +127,7 @@ func ReadConfig(src map[string]string) config.Configuration {
 	blockUserAgentList := make([]string, 0)
 	blockRefererList := make([]string, 0)
 	responseHeaders := make([]string, 0)
+	allowedOverridesList := make([]string, 0)
 
 	if val, ok := conf[customHTTPErrors]; ok {
 		delete(conf, customHTTPErrors)
@@ -143,6 +186,100 @@ func ReadConfig(src map[string]string) config.Configuration {
 		delete(conf, blockReferers)
 		blockRefererList = strings.Split(val, ",")
 	}
+	if val, ok := conf[allowedAnnotationOverrides]; ok {
+		delete(conf, allowedAnnotationOverrides)
+		for _, name := range strings.Split(val, ",") {
+			name = strings.TrimSpace(name)
+			if len(name) > 0 {
+				allowedOverridesList = append(allowedOverridesList, name)
+			}
+		}
+	}
+
+	if val, ok := conf[accessLogDestinations]; ok {
+		delete(conf, accessLogDestinations)
+		to.AccessLogDestinations = parseLogDestinations(val, true)
+	}
+	if val, ok := conf[errorLogDestinations]; ok {
+		delete(conf, errorLogDestinations)
+		to.ErrorLogDestinations = parseLogDestinations(val, false)
+	}
+
+	if val, ok := conf[accessEventsSink]; ok {
+		delete(conf, accessEventsSink)
+		if val != "" && !accessEventsSinkRegex.MatchString(val) {
+			klog.Warningf("%v is not a valid access events sink, the access events are disabled", val)
+		} else {
+			to.AccessEventsSink = val
+		}
+	}
+
+	if val, ok := conf[usageExportSink]; ok {
+		delete(conf, usageExportSink)
+		if val != "" && !usageExportSinkRegex.MatchString(val) {
+			klog.Warningf("%v is not a valid usage export sink, the export of the usage is disabled", val)
+		} else {
+			to.UsageExportSink = val
+		}
+	}
+
+	if val, ok := conf[usageExportFormat]; ok {
+		delete(conf, usageExportFormat)
+		if !validUsageExportFormats.Has(val) {
+			klog.Warningf("%v is not a valid usage export format, using %v", val, to.UsageExportFormat)
+		} else {
+			to.UsageExportFormat = val
+		}
+	}
+
+	if val, ok := conf[modsecurityAuditSink]; ok {
+		delete(conf, modsecurityAuditSink)
+		if val != "" && !modsecurityAuditSinkRegex.MatchString(val) {
+			klog.Warningf("%v is not a valid ModSecurity audit sink, the shipping of the audit entries is disabled", val)
+		} else {
+			to.ModsecurityAuditSink = val
+		}
+	}
+
+	if val, ok := conf[wafEngine]; ok {
+		delete(conf, wafEngine)
+		if !validWAFEngines.Has(val) {
+			klog.Warningf("%v is not a valid WAF engine, using %v", val, to.WAFEngine)
+		} else {
+			to.WAFEngine = val
+		}
+	}
+
+	if val, ok := conf[corazaURL]; ok {
+		delete(conf, corazaURL)
+		if val != "" && !corazaURLRegex.MatchString(val) {
+			klog.Warningf("%v is not a valid Coraza proxy URL", val)
+		} else {
+			to.CorazaURL = val
+		}
+	}
+
+	if to.WAFEngine == "coraza" && to.CorazaURL == "" {
+		klog.Warningf("the Coraza WAF engine requires the %v key, the ModSecurity annotations are applied by ModSecurity", corazaURL)
+	}
+
+	if val, ok := conf[sharedStateBackend]; ok {
+		delete(conf, sharedStateBackend)
+		if val != "" && !sharedStateBackendRegex.MatchString(val) {
+			klog.Warningf("the shared state backend is not a valid Redis URL, the shared state is disabled")
+		} else {
+			to.SharedStateBackend = val
+		}
+	}
+
+	if val, ok := conf[resolvers]; ok {
+		delete(conf, resolvers)
+		to.Resolvers = parseResolvers(val)
+	}
+	if val, ok := conf[zoneResolvers]; ok {
+		delete(conf, zoneResolvers)
+		to.ZoneResolvers = parseZoneResolvers(val)
+	}
 
 	if val, ok := conf[httpRedirectCode]; ok {
 		delete(conf, httpRedirectCode)
@@ -203,7 +340,7 @@ func ReadConfig(src map[string]string) config.Configuration {
 			for _, header := range harr {
 				header = strings.TrimSpace(header)
 				if len(header) > 0 {
-					if !authreq.ValidHeader(header) {
+					if !authreq.ValidResponseHeader(header) {
 						klog.Warningf("Global auth location denied - %v.", "invalid headers list")
 					} else {
 						responseHeaders = append(responseHeaders, header)
@@ -214,6 +351,17 @@ func ReadConfig(src map[string]string) config.Configuration {
 		to.GlobalExternalAuth.ResponseHeaders = responseHeaders
 	}
 
+	if val, ok := conf[globalAuthResponseHeadersToClient]; ok {
+		delete(conf, globalAuthResponseHeadersToClient)
+
+		toClient, err := strconv.ParseBool(val)
+		if err != nil {
+			klog.Warningf("%v is not a valid boolean for %v: %v", val, globalAuthResponseHeadersToClient, err)
+		} else {
+			to.GlobalExternalAuth.ResponseHeadersToClient = toClient
+		}
+	}
+
 	if val, ok := conf[globalAuthRequestRedirect]; ok {
 		delete(conf, globalAuthRequestRedirect)
 
@@ -226,6 +374,51 @@ func ReadConfig(src map[string]string) config.Configuration {
 		to.GlobalExternalAuth.AuthSnippet = val
 	}
 
+	// Verify that the configured global external authorization forwarded headers are valid. if not, set the default value
+	if val, ok := conf[globalAuthForwardHeaders]; ok {
+		delete(conf, globalAuthForwardHeaders)
+
+		forwardHeaders, err := authreq.ParseHeaderList(val)
+		if err != nil {
+			klog.Warningf("Global auth location denied - %v.", err)
+		} else {
+			to.GlobalExternalAuth.ForwardHeaders = forwardHeaders
+		}
+	}
+
+	// Verify that the configured global external authorization request headers are valid. if not, set the default value
+	if val, ok := conf[globalAuthRequestHeaders]; ok {
+		delete(conf, globalAuthRequestHeaders)
+
+		requestHeaders, err := authreq.ParseRequestHeaders(val)
+		if err != nil {
+			klog.Warningf("Global auth location denied - %v.", err)
+		} else {
+			to.GlobalExternalAuth.RequestHeaders = requestHeaders
+		}
+	}
+
+	// Verify that the configured global external authorization body size is valid. if not, set the default value
+	if val, ok := conf[globalAuthMaxBodySize]; ok {
+		delete(conf, globalAuthMaxBodySize)
+
+		if len(val) != 0 && !authreq.ValidBodySize(val) {
+			klog.Warningf("Global auth location denied - %v.", "invalid body size")
+		} else {
+			to.GlobalExternalAuth.MaxBodySize = val
+		}
+	}
+
+	if val, ok := conf[globalAuthCacheCookie]; ok {
+		delete(conf, globalAuthCacheCookie)
+
+		if len(val) != 0 && !authreq.ValidCookieName(val) {
+			klog.Warningf("Global auth location denied - %v.", "invalid cache cookie name")
+		} else {
+			to.GlobalExternalAuth.CacheCookie = val
+		}
+	}
+
 	// Verify that the configured timeout is parsable as a duration. if not, set the default value
 	if val, ok := conf[proxyHeaderTimeout]; ok {
 		delete(conf, proxyHeaderTimeout)
@@ -284,6 +477,7 @@ func ReadConfig(src map[string]string) config.Configuration {
 	to.BlockUserAgents = blockUserAgentList
 	to.BlockReferers = blockRefererList
 	to.HideHeaders = hideHeadersList
+	to.AllowedAnnotationOverrides = allowedOverridesList
 	to.ProxyStreamResponses = streamResponses
 	to.DisableIpv6DNS = !ing_net.IsIPv6Enabled()
 
@@ -315,6 +509,184 @@ func ReadConfig(src map[string]string) config.Configuration {
 	return to
 }
 
+// parseLogDestinations parses a comma separated list of log destinations,
+// ignoring the invalid ones
+func parseLogDestinations(val string, access bool) []config.LogDestination {
+	destinations := []config.LogDestination{}
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		destination, err := parseLogDestination(entry, access)
+		if err != nil {
+			klog.Warningf("Ignoring log destination %v: %v", entry, err)
+			continue
+		}
+
+		destinations = append(destinations, *destination)
+	}
+
+	return destinations
+}
+
+// parseResolvers parses a comma separated list of DNS servers, ignoring
+// the invalid ones
+func parseResolvers(val string) []dns.Upstream {
+	upstreams := []dns.Upstream{}
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		upstream, err := dns.ParseUpstream(entry)
+		if err != nil {
+			klog.Warningf("Ignoring resolver: %v", err)
+			continue
+		}
+
+		upstreams = append(upstreams, upstream)
+	}
+
+	return upstreams
+}
+
+// parseZoneResolvers parses a semicolon separated list of zones with the
+// format <zone>=<resolver>[,<resolver>...], ignoring the zones without a
+// valid resolver
+func parseZoneResolvers(val string) map[string][]dns.Upstream {
+	zones := map[string][]dns.Upstream{}
+	for _, entry := range strings.Split(val, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		zone := strings.Trim(strings.ToLower(strings.TrimSpace(parts[0])), ".")
+		if len(parts) != 2 || zone == "" {
+			klog.Warningf("Ignoring zone resolvers %v: the format is <zone>=<resolver>[,<resolver>...]", entry)
+			continue
+		}
+
+		upstreams := parseResolvers(parts[1])
+		if len(upstreams) == 0 {
+			klog.Warningf("Ignoring zone %v: no valid resolver", zone)
+			continue
+		}
+
+		zones[zone] = append(zones[zone], upstreams...)
+	}
+
+	return zones
+}
+
+// parseLogDestination parses a log destination with the format
+// <destination>[?format=<format>&severity=<severity>&tag=<tag>] where
+// destination is stderr, a file path, file://<path> or
+// syslog[+udp|+tcp|+tls]://<host>[:<port>]
+func parseLogDestination(entry string, access bool) (*config.LogDestination, error) {
+	u, err := url.Parse(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	params := u.Query()
+	destination := &config.LogDestination{
+		Severity: params.Get("severity"),
+	}
+
+	if access {
+		destination.Format = "upstreaminfo"
+		if format := params.Get("format"); format != "" {
+			name, ok := logFormats[format]
+			if !ok {
+				return nil, fmt.Errorf("invalid format %v", format)
+			}
+			destination.Format = name
+		}
+	} else if params.Get("format") != "" {
+		return nil, fmt.Errorf("the format is only supported by the access logs")
+	}
+
+	if destination.Severity != "" && !validLogSeverities.Has(destination.Severity) {
+		return nil, fmt.Errorf("invalid severity %v", destination.Severity)
+	}
+
+	tag := params.Get("tag")
+	if tag != "" && !syslogTagRegex.MatchString(tag) {
+		return nil, fmt.Errorf("invalid tag %v", tag)
+	}
+
+	switch u.Scheme {
+	case "":
+		if u.Path == "stderr" {
+			destination.Type = "stderr"
+			destination.Target = "/dev/stderr"
+		} else {
+			destination.Type = "file"
+			destination.Target = u.Path
+		}
+	case "file":
+		destination.Type = "file"
+		destination.Target = u.Path
+	case "syslog", "syslog+udp", "syslog+tcp", "syslog+tls":
+		destination.Type = "syslog"
+	default:
+		return nil, fmt.Errorf("unsupported destination %v", u.Scheme)
+	}
+
+	if destination.Type != "syslog" {
+		if !strings.HasPrefix(destination.Target, "/") {
+			return nil, fmt.Errorf("the path of the file must be absolute")
+		}
+		if tag != "" {
+			return nil, fmt.Errorf("the tag is only supported by syslog destinations")
+		}
+		if access && destination.Severity != "" {
+			return nil, fmt.Errorf("the severity of the access logs is only supported by syslog destinations")
+		}
+
+		return destination, nil
+	}
+
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("the syslog server is missing")
+	}
+
+	protocol := strings.TrimPrefix(strings.TrimPrefix(u.Scheme, "syslog"), "+")
+	port := u.Port()
+	if port == "" {
+		port = "514"
+		if protocol == syslog.TLS {
+			port = "6514"
+		}
+	}
+	address := net.JoinHostPort(u.Hostname(), port)
+
+	server := address
+	if protocol == syslog.TCP || protocol == syslog.TLS {
+		destination.Relay = &syslog.Remote{
+			Protocol: protocol,
+			Address:  address,
+		}
+		server = "unix:" + destination.Relay.Socket()
+	}
+
+	destination.Target = "syslog:server=" + server
+	if tag != "" {
+		destination.Target += ",tag=" + tag
+	}
+	if access && destination.Severity != "" {
+		destination.Target += ",severity=" + destination.Severity
+		destination.Severity = ""
+	}
+
+	return destination, nil
+}
+
 func filterErrors(codes []int) []int {
 	var fa []int
 	for _, code := range codes {
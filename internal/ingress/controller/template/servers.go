@@ -0,0 +1,97 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"bytes"
+	"strings"
+	text_template "text/template"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/controller/config"
+)
+
+const (
+	startServerMarker = "## start server "
+	endServerMarker   = "## end server "
+)
+
+// WriteServers renders a configuration and returns its server blocks,
+// including the redirect servers, keyed by hostname. The server blocks
+// are not cached, so rendering a subset of the servers does not evict the
+// blocks of the running configuration.
+func (t *Template) WriteServers(conf config.TemplateConfig) (map[string]string, error) {
+	tmplBuf := t.bp.Get()
+	defer t.bp.Put(tmplBuf)
+
+	tmpl, err := t.tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl.Funcs(text_template.FuncMap{
+		"renderServer": func(all config.TemplateConfig, server *ingress.Server) (string, error) {
+			var buf bytes.Buffer
+			err := tmpl.ExecuteTemplate(&buf, "SERVER", struct{ First, Second interface{} }{all, server})
+			return buf.String(), err
+		},
+	})
+
+	err = tmpl.Execute(tmplBuf, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return serverBlocks(tmplBuf.Bytes()), nil
+}
+
+// serverBlocks extracts the server blocks found between the start and end
+// markers of a NGINX configuration, removing the empty lines
+func serverBlocks(content []byte) map[string]string {
+	servers := map[string]string{}
+
+	hostname := ""
+	var block strings.Builder
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		trimmed := strings.TrimSpace(line)
+
+		if hostname == "" {
+			if strings.HasPrefix(trimmed, startServerMarker) {
+				hostname = strings.TrimPrefix(trimmed, startServerMarker)
+				block.Reset()
+			}
+			continue
+		}
+
+		if trimmed == endServerMarker+hostname {
+			servers[hostname] += block.String()
+			hostname = ""
+			continue
+		}
+
+		if trimmed == "" {
+			continue
+		}
+
+		block.WriteString(line)
+		block.WriteString("\n")
+	}
+
+	return servers
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestServerBlocks(t *testing.T) {
+	content := `
+http {
+    ## start server www.example.com
+    server {
+        server_name www.example.com;
+
+        return 308 https://example.com$request_uri;
+    }
+    ## end server www.example.com
+
+    ## start server example.com
+    server {
+        server_name example.com ;
+
+        location / {
+            proxy_pass http://upstream_balancer;
+        }
+    }
+    ## end server example.com
+}
+`
+
+	expected := map[string]string{
+		"www.example.com": `    server {
+        server_name www.example.com;
+        return 308 https://example.com$request_uri;
+    }
+`,
+		"example.com": `    server {
+        server_name example.com ;
+        location / {
+            proxy_pass http://upstream_balancer;
+        }
+    }
+`,
+	}
+
+	servers := serverBlocks([]byte(content))
+	if !reflect.DeepEqual(servers, expected) {
+		t.Errorf("expected %v but returned %v", expected, servers)
+	}
+}
+
+func TestWriteServers(t *testing.T) {
+	dat := readTemplateConfig(t)
+	ngxTpl := newTestTemplate(t)
+
+	servers, err := ngxTpl.WriteServers(dat)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, server := range dat.Servers {
+		block, ok := servers[server.Hostname]
+		if !ok {
+			t.Errorf("expected a server block for %v", server.Hostname)
+			continue
+		}
+
+		if !strings.Contains(block, "server_name "+server.Hostname) {
+			t.Errorf("expected the server block of %v to contain its server_name but returned %v", server.Hostname, block)
+		}
+	}
+
+	if len(ngxTpl.servers.entries) != 0 {
+		t.Errorf("expected the server blocks not to be cached but there are %v entries", len(ngxTpl.servers.entries))
+	}
+}
@@ -30,18 +30,26 @@ import (
 	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	text_template "text/template"
 	"time"
 
 	"github.com/pkg/errors"
 
+	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/influxdb"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/plainhttp"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/timewindow"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/logging"
 	ing_net "k8s.io/ingress-nginx/internal/net"
 	"k8s.io/klog"
 )
@@ -55,6 +63,8 @@ const (
 // TemplateWriter is the interface to render a template
 type TemplateWriter interface {
 	Write(conf config.TemplateConfig) ([]byte, error)
+	// WriteServers returns the server blocks of the configuration keyed by hostname
+	WriteServers(conf config.TemplateConfig) (map[string]string, error)
 }
 
 // Template ...
@@ -62,6 +72,8 @@ type Template struct {
 	tmpl *text_template.Template
 	//fw   watch.FileWatcher
 	bp *BufferPool
+
+	servers *serverCache
 }
 
 //NewTemplate returns a new Template instance or an
@@ -78,8 +90,9 @@ func NewTemplate(file string, fs file.Filesystem) (*Template, error) {
 	}
 
 	return &Template{
-		tmpl: tmpl,
-		bp:   NewBufferPool(defBufferSize),
+		tmpl:    tmpl,
+		bp:      NewBufferPool(defBufferSize),
+		servers: newServerCache(),
 	}, nil
 }
 
@@ -97,7 +110,7 @@ func (t *Template) Write(conf config.TemplateConfig) ([]byte, error) {
 		conf.Cfg.DisableLuaRestyWAF = true
 	}
 
-	if klog.V(3) {
+	if logging.V(3) {
 		b, err := json.Marshal(conf)
 		if err != nil {
 			klog.Errorf("unexpected error: %v", err)
@@ -105,11 +118,31 @@ func (t *Template) Write(conf config.TemplateConfig) ([]byte, error) {
 		klog.Infof("NGINX configuration: %v", string(b))
 	}
 
-	err := t.tmpl.Execute(tmplBuf, conf)
+	// the server blocks are rendered in parallel before the execution of a
+	// copy of the template, reusing the blocks of the servers not changed.
+	// The template itself is only parsed again when the file changes.
+	tmpl, err := t.tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	renderer := t.servers.newRenderer(tmpl, conf)
+	tmpl.Funcs(text_template.FuncMap{
+		"renderServer": renderer.render,
+	})
+
+	err = renderer.prerender(conf)
 	if err != nil {
 		return nil, err
 	}
 
+	err = tmpl.Execute(tmplBuf, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer.finish()
+
 	// squeezes multiple adjacent empty lines to be single
 	// spaced this is to avoid the use of regular expressions
 	cmd := exec.Command("/ingress-controller/clean-nginx-conf.sh")
@@ -139,13 +172,32 @@ var (
 		"buildAuthLocation":          buildAuthLocation,
 		"shouldApplyGlobalAuth":      shouldApplyGlobalAuth,
 		"buildAuthResponseHeaders":   buildAuthResponseHeaders,
+		"buildAuthRequestHeaders":    buildAuthRequestHeaders,
 		"buildProxyPass":             buildProxyPass,
+		"buildProxySSL":              buildProxySSL,
+		"buildUpstreamHost":          buildUpstreamHost,
+		"buildPlainHTTPErrorPage":    buildPlainHTTPErrorPage,
 		"filterRateLimits":           filterRateLimits,
 		"buildRateLimitZones":        buildRateLimitZones,
 		"buildRateLimit":             buildRateLimit,
+		"buildConnectionLimitZones":  buildConnectionLimitZones,
+		"buildConnectionLimits":      buildConnectionLimits,
 		"buildResolversForLua":       buildResolversForLua,
 		"configForLua":               configForLua,
 		"locationConfigForLua":       locationConfigForLua,
+		"bodyTransformConfigForLua":  bodyTransformConfigForLua,
+		"trafficCaptureConfigForLua": trafficCaptureConfigForLua,
+		"corazaConfigForLua":         corazaConfigForLua,
+		"botChallengeConfigForLua":   botChallengeConfigForLua,
+		"tlsFingerprintConfigForLua": tlsFingerprintConfigForLua,
+		"forwardedCertConfigForLua":  forwardedCertConfigForLua,
+		"accessEventsConfigForLua":   accessEventsConfigForLua,
+		"usageConfigForLua":          usageConfigForLua,
+		"sharedStateConfigForLua":    sharedStateConfigForLua,
+		"noEndpointsConfigForLua":    noEndpointsConfigForLua,
+		"normalizationConfigForLua":  normalizationConfigForLua,
+		"canonicalPathConfigForLua":  canonicalPathConfigForLua,
+		"timeWindowConfigForLua":     timeWindowConfigForLua,
 		"buildResolvers":             buildResolvers,
 		"buildUpstreamName":          buildUpstreamName,
 		"isLocationInLocationList":   isLocationInLocationList,
@@ -165,7 +217,12 @@ var (
 		"serverConfig": func(all config.TemplateConfig, server *ingress.Server) interface{} {
 			return struct{ First, Second interface{} }{all, server}
 		},
+		"renderServer": func(all config.TemplateConfig, server *ingress.Server) (string, error) {
+			return "", fmt.Errorf("renderServer is only available writing a configuration")
+		},
 		"isValidByteSize":                    isValidByteSize,
+		"proxyBuffersSize":                   proxyBuffersSize,
+		"buildAuthResponseHeaderPrefixes":    buildAuthResponseHeaderPrefixes,
 		"buildForwardedFor":                  buildForwardedFor,
 		"buildAuthSignURL":                   buildAuthSignURL,
 		"buildOpentracing":                   buildOpentracing,
@@ -177,6 +234,13 @@ var (
 		"opentracingPropagateContext":        opentracingPropagateContext,
 		"buildCustomErrorLocationsPerServer": buildCustomErrorLocationsPerServer,
 		"shouldLoadModSecurityModule":        shouldLoadModSecurityModule,
+		"buildHostRegex":                     buildHostRegex,
+		"buildListenerServers":               buildListenerServers,
+		"buildAdditionalHTTPSListen":         buildAdditionalHTTPSListen,
+		"buildInternalListen":                buildInternalListen,
+		"buildInternalRedirectListen":        buildInternalRedirectListen,
+		"shouldCheckClientCertificate":       shouldCheckClientCertificate,
+		"buildVerifyClient":                  buildVerifyClient,
 	}
 )
 
@@ -194,6 +258,249 @@ func escapeLiteralDollar(input interface{}) string {
 	return strings.Replace(inputStr, `$`, `${literal_dollar}`, -1)
 }
 
+// shouldCheckClientCertificate returns true if the client certificate of the
+// server must be verified in the access phase of the location, so it can be
+// combined with the other authentication methods using the satisfy directive,
+// or not required in the locations excluded from the authentication. This is
+// the case of the locations, except the excluded ones, of a server requiring a
+// client certificate with a location using satisfy any or excluded.
+func shouldCheckClientCertificate(s interface{}, l interface{}) bool {
+	server, ok := s.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Server' type but %T was returned", s)
+		return false
+	}
+
+	location, ok := l.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was returned", l)
+		return false
+	}
+
+	return !location.CertificateAuthExcluded && checksClientCertificateInLocations(server)
+}
+
+// buildVerifyClient returns the value of the ssl_verify_client directive of
+// the server. A required client certificate is verified in the access phase
+// of the locations when the server has a location using satisfy any or
+// excluded from the authentication, since NGINX rejects the requests without
+// a valid certificate before checking the other authentication methods.
+func buildVerifyClient(s interface{}) string {
+	server, ok := s.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Server' type but %T was returned", s)
+		return ""
+	}
+
+	if checksClientCertificateInLocations(server) {
+		return "optional"
+	}
+
+	return server.CertificateAuth.VerifyClient
+}
+
+func checksClientCertificateInLocations(server *ingress.Server) bool {
+	if server.AuthTLSError != "" || server.CertificateAuth.CAFileName == "" || server.CertificateAuth.VerifyClient != "on" {
+		return false
+	}
+
+	for _, location := range server.Locations {
+		if location.Satisfy == "any" || location.CertificateAuthExcluded {
+			return true
+		}
+	}
+
+	return false
+}
+
+// buildListenerServers returns the servers followed by a copy of the servers
+// with TLS for each of their listeners, rendered as a distinct server block
+// bound to the port of the listener with its client certificate authentication
+func buildListenerServers(input interface{}) []*ingress.Server {
+	servers, ok := input.([]*ingress.Server)
+	if !ok {
+		klog.Errorf("expected a '[]*ingress.Server' type but %T was returned", input)
+		return []*ingress.Server{}
+	}
+
+	result := make([]*ingress.Server, 0, len(servers))
+	result = append(result, servers...)
+	for _, server := range servers {
+		if server.SSLCert.PemFileName == "" || server.SSLPassthrough {
+			continue
+		}
+
+		for _, listener := range server.Listeners {
+			ls := *server
+			ls.Listeners = nil
+			ls.ListenPort = listener.Port
+			ls.CertificateAuth = listener.CertificateAuth
+			result = append(result, &ls)
+		}
+	}
+
+	return result
+}
+
+// buildAdditionalHTTPSListen returns the listen directives of the additional
+// HTTPS ports of a server block, the port of its listener or all the ports
+// for the catch-all server, the default server of the ports
+func buildAdditionalHTTPSListen(c interface{}, s interface{}) []string {
+	all, ok := c.(config.TemplateConfig)
+	if !ok {
+		klog.Errorf("expected a 'config.TemplateConfig' type but %T was returned", c)
+		return []string{}
+	}
+
+	server, ok := s.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Server' type but %T was returned", s)
+		return []string{}
+	}
+
+	options := ""
+	if all.Cfg.UseProxyProtocol {
+		options += " proxy_protocol"
+	}
+
+	ports := []int{server.ListenPort}
+	if server.Hostname == "_" {
+		ports = all.ListenPorts.AdditionalHTTPS
+		options += " default_server"
+		if all.Cfg.ReusePort {
+			options += " reuseport"
+		}
+		options += fmt.Sprintf(" backlog=%v", all.BacklogSize)
+	} else if server.ListenPort == 0 {
+		return []string{}
+	}
+
+	options += " ssl"
+	if all.Cfg.UseHTTP2 {
+		options += " http2"
+	}
+
+	return listenDirectives(all, ports, options)
+}
+
+// buildInternalListen returns the listen directives of the internal listener
+// of a server block: the internal ports for an internal server or the catch-all
+// server, the default server of the ports, and nothing for the others
+func buildInternalListen(c interface{}, s interface{}) []string {
+	all, ok := c.(config.TemplateConfig)
+	if !ok {
+		klog.Errorf("expected a 'config.TemplateConfig' type but %T was returned", c)
+		return []string{}
+	}
+
+	server, ok := s.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Server' type but %T was returned", s)
+		return []string{}
+	}
+
+	if server.ListenPort != 0 {
+		return []string{}
+	}
+
+	if server.Hostname == "_" {
+		return internalListen(all, true, true)
+	}
+
+	if !server.Internal {
+		return []string{}
+	}
+
+	return internalListen(all, false, server.SSLCert.PemFileName != "")
+}
+
+// buildInternalRedirectListen returns the listen directives of the server
+// block redirecting to an internal server
+func buildInternalRedirectListen(c interface{}) []string {
+	all, ok := c.(config.TemplateConfig)
+	if !ok {
+		klog.Errorf("expected a 'config.TemplateConfig' type but %T was returned", c)
+		return []string{}
+	}
+
+	return internalListen(all, false, true)
+}
+
+// internalListen returns the listen directives of the internal HTTP and HTTPS ports
+func internalListen(all config.TemplateConfig, defaultServer, https bool) []string {
+	options := ""
+	if all.Cfg.UseProxyProtocol {
+		options += " proxy_protocol"
+	}
+	if defaultServer {
+		options += " default_server"
+		if all.Cfg.ReusePort {
+			options += " reuseport"
+		}
+		options += fmt.Sprintf(" backlog=%v", all.BacklogSize)
+	}
+
+	listen := []string{}
+	if all.ListenPorts.InternalHTTP != 0 {
+		listen = append(listen, listenDirectives(all, []int{all.ListenPorts.InternalHTTP}, options)...)
+	}
+
+	if https && all.ListenPorts.InternalHTTPS != 0 {
+		options += " ssl"
+		if all.Cfg.UseHTTP2 {
+			options += " http2"
+		}
+		listen = append(listen, listenDirectives(all, []int{all.ListenPorts.InternalHTTPS}, options)...)
+	}
+
+	return listen
+}
+
+// listenDirectives returns the listen directives of the ports on the IPv4 and
+// IPv6 bind addresses
+func listenDirectives(all config.TemplateConfig, ports []int, options string) []string {
+	addresses := []string{}
+	for _, address := range all.Cfg.BindAddressIpv4 {
+		addresses = append(addresses, address+":")
+	}
+	if len(addresses) == 0 {
+		addresses = append(addresses, "")
+	}
+	if all.IsIPV6Enabled {
+		if len(all.Cfg.BindAddressIpv6) == 0 {
+			addresses = append(addresses, "[::]:")
+		}
+		for _, address := range all.Cfg.BindAddressIpv6 {
+			addresses = append(addresses, address+":")
+		}
+	}
+
+	listen := []string{}
+	for _, port := range ports {
+		for _, address := range addresses {
+			listen = append(listen, fmt.Sprintf("listen %v%v%v;", address, port, options))
+		}
+	}
+
+	return listen
+}
+
+// buildHostRegex returns the regular expression of the server as a quoted
+// server_name, escaping the backslashes unescaped by the NGINX parser
+func buildHostRegex(input interface{}) string {
+	server, ok := input.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Server' type but %T was returned", input)
+		return ""
+	}
+
+	if server.HostRegex.Regex == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(`"~%v"`, strings.Replace(server.HostRegex.Regex, `\\`, `\\\\`, -1))
+}
+
 // formatIP will wrap IPv6 addresses in [] and return IPv4 addresses
 // without modification. If the input cannot be parsed as an IP address
 // it is returned without modification.
@@ -226,6 +533,7 @@ func buildLuaSharedDictionaries(s interface{}, disableLuaRestyWAF bool) string {
 	out := []string{
 		"lua_shared_dict configuration_data 15M",
 		"lua_shared_dict certificate_data 16M",
+		"lua_shared_dict canary_weights 1M",
 	}
 
 	if !disableLuaRestyWAF {
@@ -244,6 +552,21 @@ func buildLuaSharedDictionaries(s interface{}, disableLuaRestyWAF bool) string {
 		}
 	}
 
+	// request counters of the rate trigger of the bot challenges
+	botChallengeRateEnabled := func() bool {
+		for _, server := range servers {
+			for _, location := range server.Locations {
+				if location.BotChallenge.Rate > 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}()
+	if botChallengeRateEnabled {
+		out = append(out, "lua_shared_dict bot_challenge 10M")
+	}
+
 	return strings.Join(out, ";\n\r") + ";"
 }
 
@@ -317,10 +640,389 @@ func locationConfigForLua(l interface{}, s interface{}, a interface{}) string {
 	forceSSLRedirect := location.Rewrite.ForceSSLRedirect || (len(server.SSLCert.PemFileName) > 0 && location.Rewrite.SSLRedirect)
 	forceSSLRedirect = forceSSLRedirect && !isLocationInLocationList(l, all.Cfg.NoTLSRedirectLocations)
 
+	redirectCode := location.Rewrite.SSLRedirectCode
+	if redirectCode == 0 {
+		redirectCode = all.Cfg.HTTPRedirectCode
+	}
+
+	// the table is built for each request, the paths are only decoded when
+	// there are some
+	excludePaths := "{}"
+	if len(location.Rewrite.SSLRedirectExcludePaths) > 0 {
+		excludePaths = luaJSON(location.Rewrite.SSLRedirectExcludePaths)
+	}
+
 	return fmt.Sprintf(`{
 		force_ssl_redirect = %t,
 		use_port_in_redirects = %t,
-	}`, forceSSLRedirect, location.UsePortInRedirects)
+		ssl_redirect_code = %v,
+		ssl_redirect_port = %v,
+		ssl_redirect_exclude_paths = %v,
+	}`, forceSSLRedirect, location.UsePortInRedirects, redirectCode,
+		location.Rewrite.SSLRedirectPort, excludePaths)
+}
+
+// canonicalPathConfigForLua returns the path canonicalization of the location
+// as a Lua table, or an empty string when the paths are not canonicalized
+func canonicalPathConfigForLua(l interface{}) string {
+	location, ok := l.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was given", l)
+		return ""
+	}
+
+	cp := location.CanonicalPath
+	if cp.MergeSlashes == "" && cp.TrailingSlash == "" && !cp.NormalizePercentEncoding {
+		return ""
+	}
+
+	return fmt.Sprintf(`{
+		merge_slashes = %q,
+		trailing_slash = %q,
+		normalize_percent_encoding = %t,
+	}`, cp.MergeSlashes, cp.TrailingSlash, cp.NormalizePercentEncoding)
+}
+
+// timeWindowConfigForLua returns the time windows during which the requests
+// are allowed in the location as a Lua table, or an empty string when the
+// requests are allowed at any time
+func timeWindowConfigForLua(l interface{}) string {
+	location, ok := l.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was given", l)
+		return ""
+	}
+
+	if !location.TimeWindow.Enabled() {
+		return ""
+	}
+
+	return buildTimeWindowConfig(location.TimeWindow, time.Now())
+}
+
+// buildTimeWindowConfig returns the time windows as a Lua table. The offsets
+// to UTC of the time zone are included from the beginning of the year of now
+// to two years later, the last offset is used afterwards.
+func buildTimeWindowConfig(tw timewindow.Config, now time.Time) string {
+	loc, err := timewindow.LoadLocation(tw.TimeZone)
+	if err != nil {
+		klog.Errorf("unexpected error loading time zone %v: %v", tw.TimeZone, err)
+		return ""
+	}
+
+	windows := func(exprs []string) string {
+		tables := []string{}
+		for _, expr := range exprs {
+			w, err := timewindow.ParseWindow(expr)
+			if err != nil {
+				klog.Errorf("unexpected error parsing time window %q: %v", expr, err)
+				continue
+			}
+
+			fields := []string{}
+			for _, f := range []struct {
+				name   string
+				values []int
+			}{
+				{"minutes", w.Minutes},
+				{"hours", w.Hours},
+				{"days", w.Days},
+				{"months", w.Months},
+				{"weekdays", w.Weekdays},
+			} {
+				if f.values == nil {
+					continue
+				}
+
+				values := []string{}
+				for _, v := range f.values {
+					values = append(values, fmt.Sprintf("[%d] = true", v))
+				}
+				fields = append(fields, fmt.Sprintf("%s = { %s }", f.name, strings.Join(values, ", ")))
+			}
+
+			tables = append(tables, fmt.Sprintf("{ %s }", strings.Join(fields, ", ")))
+		}
+
+		return strings.Join(tables, ", ")
+	}
+
+	from := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	offsets, last := timewindow.Offsets(loc, from, from.AddDate(2, 0, 0))
+
+	transitions := []string{}
+	for _, o := range offsets {
+		transitions = append(transitions, fmt.Sprintf("{ ends = %d, offset = %d }", o.Ends, o.Offset))
+	}
+
+	return fmt.Sprintf(`{
+		status = %d,
+		offset = %d,
+		offsets = { %s },
+		allowed = { %s },
+		denied = { %s },
+	}`, tw.StatusCode, last, strings.Join(transitions, ", "), windows(tw.Allowed), windows(tw.Denied))
+}
+
+// bodyTransformConfigForLua returns the body transformations of the location as a Lua table
+func bodyTransformConfigForLua(l interface{}) string {
+	location, ok := l.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was given", l)
+		return "{}"
+	}
+
+	fields := []string{}
+	for _, field := range location.BodyTransform.RedactJSONFields {
+		fields = append(fields, fmt.Sprintf("%q", field))
+	}
+
+	return fmt.Sprintf(`{
+		request_encoding = %q,
+		redact_json_fields = { %s },
+		max_size = %d,
+	}`, location.BodyTransform.RequestEncoding, strings.Join(fields, ", "), location.BodyTransform.MaxSize)
+}
+
+// trafficCaptureConfigForLua returns the traffic capture of the location as a Lua table
+func trafficCaptureConfigForLua(l interface{}) string {
+	location, ok := l.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was given", l)
+		return "{}"
+	}
+
+	quote := func(items []string) string {
+		quoted := []string{}
+		for _, item := range items {
+			quoted = append(quoted, fmt.Sprintf("%q", item))
+		}
+		return strings.Join(quoted, ", ")
+	}
+
+	c := location.TrafficCapture
+	return fmt.Sprintf(`{
+		sink = %q,
+		sample_rate = %v,
+		max_rps = %d,
+		headers = { %s },
+		args = { %s },
+		body = %t,
+	}`, c.Sink, c.SampleRate, c.MaxRPS, quote(c.Headers), quote(c.Args), c.Body)
+}
+
+// corazaConfigForLua returns the ModSecurity annotations of the location as a Lua table
+// when they are applied by the Coraza proxy, or an empty string otherwise
+func corazaConfigForLua(l interface{}, c interface{}) string {
+	location, ok := l.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was given", l)
+		return ""
+	}
+
+	cfg, ok := c.(config.Configuration)
+	if !ok {
+		klog.Errorf("expected a 'config.Configuration' type but %T was given", c)
+		return ""
+	}
+
+	if !cfg.UseCoraza() || !(cfg.EnableModsecurity || location.ModSecurity.Enable) {
+		return ""
+	}
+
+	owaspRules := (cfg.EnableModsecurity && cfg.EnableOWASPCoreRules) || location.ModSecurity.OWASPRules
+	return fmt.Sprintf(`{
+		owasp_core_rules = %t,
+		transaction_id = %q,
+	}`, owaspRules, location.ModSecurity.TransactionID)
+}
+
+// botChallengeConfigForLua returns the bot challenge of the location as a Lua table
+func botChallengeConfigForLua(l interface{}) string {
+	location, ok := l.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was given", l)
+		return "{}"
+	}
+
+	quote := func(items []string) string {
+		quoted := []string{}
+		for _, item := range items {
+			quoted = append(quoted, fmt.Sprintf("%q", item))
+		}
+		return strings.Join(quoted, ", ")
+	}
+
+	c := location.BotChallenge
+	return fmt.Sprintf(`{
+		mode = %q,
+		difficulty = %d,
+		rate = %d,
+		asns = { %s },
+		user_agents = { %s },
+		exempt_cidrs = { %s },
+		exempt_user_agents = { %s },
+	}`, c.Mode, c.Difficulty, c.Rate, quote(c.ASNs), quote(c.UserAgents), quote(c.ExemptCIDRs), quote(c.ExemptUserAgents))
+}
+
+// tlsFingerprintConfigForLua returns the TLS fingerprints allowed or denied in the location as a Lua table
+func tlsFingerprintConfigForLua(l interface{}) string {
+	location, ok := l.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was given", l)
+		return "{}"
+	}
+
+	quote := func(items []string) string {
+		quoted := []string{}
+		for _, item := range items {
+			quoted = append(quoted, fmt.Sprintf("%q", item))
+		}
+		return strings.Join(quoted, ", ")
+	}
+
+	c := location.TLSFingerprint
+	return fmt.Sprintf(`{
+		allowed = { %s },
+		denied = { %s },
+	}`, quote(c.Allowed), quote(c.Denied))
+}
+
+// forwardedCertConfigForLua returns the elements of the X-Forwarded-Client-Cert
+// header of the server as a Lua table, or an empty string when the header is not sent
+func forwardedCertConfigForLua(s interface{}) string {
+	server, ok := s.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Server' type but %T was given", s)
+		return ""
+	}
+
+	if server.AuthTLSError != "" || server.CertificateAuth.CAFileName == "" || len(server.CertificateAuth.ForwardedClientCert) == 0 {
+		return ""
+	}
+
+	quoted := []string{}
+	for _, element := range server.CertificateAuth.ForwardedClientCert {
+		quoted = append(quoted, fmt.Sprintf("%q", element))
+	}
+
+	return fmt.Sprintf("{ %s }", strings.Join(quoted, ", "))
+}
+
+// accessEventsConfigForLua returns the configuration of the access events sink as a Lua table
+func accessEventsConfigForLua(c interface{}) string {
+	cfg, ok := c.(config.Configuration)
+	if !ok {
+		klog.Errorf("expected a 'config.Configuration' type but %T was given", c)
+		return "{}"
+	}
+
+	return fmt.Sprintf(`{
+		sink = %q,
+		batch_size = %d,
+		flush_interval = %d,
+		buffer_size = %d,
+	}`, cfg.AccessEventsSink, cfg.AccessEventsBatchSize, cfg.AccessEventsFlushInterval, cfg.AccessEventsBufferSize)
+}
+
+// usageConfigForLua returns the configuration of the export of the usage as a Lua table.
+// The Ingresses of the servers are listed so the counters of the removed ones are deleted.
+func usageConfigForLua(c interface{}, s interface{}) string {
+	cfg, ok := c.(config.Configuration)
+	if !ok {
+		klog.Errorf("expected a 'config.Configuration' type but %T was given", c)
+		return "{}"
+	}
+
+	servers, ok := s.([]*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '[]*ingress.Server' type but %T was given", s)
+		return "{}"
+	}
+
+	ingresses := sets.NewString()
+	for _, server := range servers {
+		for _, location := range server.Locations {
+			if location.Ingress == nil {
+				continue
+			}
+			ingresses.Insert(fmt.Sprintf("%q", location.Ingress.Namespace+" "+location.Ingress.Name))
+		}
+	}
+
+	return fmt.Sprintf(`{
+		sink = %q,
+		format = %q,
+		interval = %d,
+		ingresses = { %v },
+	}`, cfg.UsageExportSink, cfg.UsageExportFormat, cfg.UsageExportInterval, strings.Join(ingresses.List(), ", "))
+}
+
+// sharedStateConfigForLua returns the configuration of the shared state backend as a Lua table
+func sharedStateConfigForLua(c interface{}) string {
+	cfg, ok := c.(config.Configuration)
+	if !ok {
+		klog.Errorf("expected a 'config.Configuration' type but %T was given", c)
+		return "{}"
+	}
+
+	return fmt.Sprintf(`{
+		backend = %q,
+		prefix = %q,
+		sync_interval = %d,
+		timeout = %d,
+	}`, cfg.SharedStateBackend, cfg.SharedStatePrefix, cfg.SharedStateSyncInterval, cfg.SharedStateTimeout)
+}
+
+// luaJSON returns a Lua expression decoding the JSON encoding of v with
+// cjson. The strings quoted by Go are not valid Lua strings when they
+// contain escape sequences Lua does not support, like \u00e9.
+func luaJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		klog.Errorf("unexpected error encoding %v: %v", v, err)
+		return "nil"
+	}
+
+	// the JSON is written in a long bracket whose level does not appear in it
+	level := ""
+	for strings.Index(string(b)+"]"+level+"]", "]"+level+"]") != len(b) {
+		level += "="
+	}
+
+	return fmt.Sprintf(`require("cjson.safe").decode([%v[%s]%v])`, level, b, level)
+}
+
+// noEndpointsConfigForLua returns the responses of the backends without endpoints as a Lua table
+func noEndpointsConfigForLua(c interface{}) string {
+	cfg, ok := c.(config.Configuration)
+	if !ok {
+		klog.Errorf("expected a 'config.Configuration' type but %T was given", c)
+		return "{}"
+	}
+
+	return fmt.Sprintf(`{
+		retry_after = %d,
+		retry_after_max = %d,
+		page = %v,
+	}`, cfg.NoEndpointsRetryAfter, cfg.NoEndpointsRetryAfterMax, luaJSON(cfg.NoEndpointsPage))
+}
+
+// normalizationConfigForLua returns the request normalization rules as a Lua table
+func normalizationConfigForLua(c interface{}) string {
+	cfg, ok := c.(config.Configuration)
+	if !ok {
+		klog.Errorf("expected a 'config.Configuration' type but %T was given", c)
+		return "{}"
+	}
+
+	return fmt.Sprintf(`{
+		reject_ambiguous_requests = %t,
+		normalize_duplicate_headers = %t,
+		max_headers = %d,
+		max_header_size = %d,
+		decode_path_traversal = %t,
+	}`, cfg.RejectAmbiguousRequests, cfg.NormalizeDuplicateHeaders, cfg.MaxRequestHeaders, cfg.MaxRequestHeaderSize, cfg.DecodePathTraversal)
 }
 
 // buildResolvers returns the resolvers reading the /etc/resolv.conf file
@@ -437,7 +1139,7 @@ func shouldApplyGlobalAuth(input interface{}, globalExternalAuthURL string) bool
 	return false
 }
 
-func buildAuthResponseHeaders(headers []string) []string {
+func buildAuthResponseHeaders(headers []string, toClient bool) []string {
 	res := []string{}
 
 	if len(headers) == 0 {
@@ -445,11 +1147,66 @@ func buildAuthResponseHeaders(headers []string) []string {
 	}
 
 	for i, h := range headers {
-		hvar := strings.ToLower(h)
+		from, to, isPrefix, err := authreq.ParseResponseHeader(h)
+		if err != nil || isPrefix {
+			continue
+		}
+
+		hvar := strings.ToLower(from)
 		hvar = strings.NewReplacer("-", "_").Replace(hvar)
 		res = append(res, fmt.Sprintf("auth_request_set $authHeader%v $upstream_http_%v;", i, hvar))
-		res = append(res, fmt.Sprintf("proxy_set_header '%v' $authHeader%v;", h, i))
+		res = append(res, fmt.Sprintf("proxy_set_header '%v' $authHeader%v;", to, i))
+		if toClient {
+			res = append(res, fmt.Sprintf("add_header '%v' $authHeader%v;", to, i))
+		}
+	}
+	return res
+}
+
+// buildAuthResponseHeaderPrefixes returns a Lua table with the prefixes of
+// the auth response headers that must be copied by the auth_headers module.
+// An empty string is returned if there are no prefixes.
+func buildAuthResponseHeaderPrefixes(headers []string) string {
+	prefixes := []string{}
+
+	for _, h := range headers {
+		from, to, isPrefix, err := authreq.ParseResponseHeader(h)
+		if err != nil || !isPrefix {
+			continue
+		}
+
+		prefixes = append(prefixes, fmt.Sprintf(`{ prefix = "%v", replacement = "%v" }`, strings.ToLower(from), to))
 	}
+
+	if len(prefixes) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("{ %v }", strings.Join(prefixes, ", "))
+}
+
+// buildAuthRequestHeaders returns the proxy_set_header directives used to
+// forward a subset of the client headers and to add static headers to the
+// external authentication subrequest
+func buildAuthRequestHeaders(forwardHeaders []string, requestHeaders map[string]string) []string {
+	res := []string{}
+
+	for _, h := range forwardHeaders {
+		hvar := strings.ToLower(h)
+		hvar = strings.NewReplacer("-", "_").Replace(hvar)
+		res = append(res, fmt.Sprintf("proxy_set_header '%v' $http_%v;", h, hvar))
+	}
+
+	names := make([]string, 0, len(requestHeaders))
+	for name := range requestHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		res = append(res, fmt.Sprintf("proxy_set_header '%v' '%v';", name, requestHeaders[name]))
+	}
+
 	return res
 }
 
@@ -500,6 +1257,10 @@ func buildProxyPass(host string, b interface{}, loc interface{}) string {
 	}
 
 	upstreamName := "upstream_balancer"
+	if location.ProxyChain.Address != "" && proxyPass != "ajp_pass" {
+		// resolved at request time, the proxy does not need to exist when NGINX starts
+		upstreamName = "$proxy_chain_address"
+	}
 
 	for _, backend := range backends {
 		if backend.Name == location.Backend {
@@ -539,6 +1300,83 @@ rewrite "(?i)%s" %s break;
 	return defProxyPass
 }
 
+// buildProxySSL returns the directives used to verify the certificate of
+// HTTPS and GRPCS backends. The name of the service is used to verify the
+// certificate when proxy-ssl-name is not defined.
+func buildProxySSL(loc interface{}) []string {
+	location, ok := loc.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was returned", loc)
+		return []string{}
+	}
+
+	prefix := "proxy"
+	switch location.BackendProtocol {
+	case "HTTPS":
+	case "GRPCS":
+		prefix = "grpc"
+	default:
+		return []string{}
+	}
+
+	cfg := location.ProxySSL
+	serverName := cfg.ServerName
+	if serverName == "" && prefix == "proxy" && location.Service != nil && location.Service.Spec.Type == apiv1.ServiceTypeExternalName {
+		// the external services usually expect the SNI to match the Host header
+		serverName = location.UpstreamHost.HostName()
+		if location.UpstreamHost.Preserve {
+			serverName = "$host"
+		}
+	}
+	if serverName == "" && cfg.Verify && location.Service != nil {
+		serverName = fmt.Sprintf("%v.%v.svc", location.Service.Name, location.Service.Namespace)
+	}
+
+	res := []string{}
+	if cfg.CACert.CAFileName != "" {
+		verify := "off"
+		if cfg.Verify {
+			verify = "on"
+		}
+
+		res = append(res,
+			fmt.Sprintf("%v_ssl_trusted_certificate %v;", prefix, cfg.CACert.CAFileName),
+			fmt.Sprintf("%v_ssl_verify %v;", prefix, verify),
+			fmt.Sprintf("%v_ssl_verify_depth %v;", prefix, cfg.VerifyDepth))
+	}
+
+	if serverName != "" {
+		res = append(res,
+			fmt.Sprintf("%v_ssl_server_name on;", prefix),
+			fmt.Sprintf("%v_ssl_name %v;", prefix, serverName))
+	}
+
+	return res
+}
+
+// buildUpstreamHost returns the Host header sent to the upstream servers, the
+// host of the request unless it is defined or preserved by the annotations
+func buildUpstreamHost(loc interface{}) string {
+	location, ok := loc.(*ingress.Location)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Location' type but %T was returned", loc)
+		return "$best_http_host"
+	}
+
+	switch {
+	case location.UpstreamHost.Preserve:
+		return "$http_host"
+	case location.UpstreamHost.IsVariable():
+		return location.UpstreamHost.Host
+	case location.UpstreamHost.Host != "":
+		return fmt.Sprintf("\"%v\"", location.UpstreamHost.Host)
+	case location.UpstreamVhost != "":
+		return fmt.Sprintf("\"%v\"", location.UpstreamVhost)
+	}
+
+	return "$best_http_host"
+}
+
 // TODO: Needs Unit Tests
 func filterRateLimits(input interface{}) []ratelimit.Config {
 	ratelimits := []ratelimit.Config{}
@@ -657,6 +1495,110 @@ func buildRateLimit(input interface{}) []string {
 	return limits
 }
 
+// buildConnectionLimitZones produces the zones and variables used by the limits
+// of concurrent connections of all the servers and of each server. Nothing is
+// produced when neither limit is configured.
+func buildConnectionLimitZones(s interface{}, c interface{}) []string {
+	zones := []string{}
+
+	servers, ok := s.([]*ingress.Server)
+	if !ok {
+		klog.Errorf("expected a '[]*ingress.Server' type but %T was returned", s)
+		return zones
+	}
+
+	cfg, ok := c.(config.Configuration)
+	if !ok {
+		klog.Errorf("expected a 'config.Configuration' type but %T was returned", c)
+		return zones
+	}
+
+	enabled := cfg.MaxConnections > 0 || cfg.MaxConnectionsPerHost > 0
+	for _, server := range servers {
+		if server.MaxConnections > 0 {
+			enabled = true
+		}
+	}
+
+	if !enabled {
+		return zones
+	}
+
+	zones = append(zones,
+		`map "" $connection_limit_global { default "global"; }`,
+		"limit_conn_zone $connection_limit_global zone=global_connections:1m;",
+		"limit_conn_zone $server_name zone=host_connections:10m;",
+		// only the requests rejected before reaching the upstream servers after the
+		// rewrite phase, where $connection_limit_checked is set, receive the header.
+		// The Retry-After header returned by the upstream servers is kept.
+		fmt.Sprintf(`map "$status:$upstream_status:$connection_limit_checked" $connection_limit_retry_after { "%v::1" "%v"; default $upstream_http_retry_after; }`,
+			connectionLimitStatusCode(cfg), cfg.ConnectionLimitRetryAfter),
+	)
+
+	return zones
+}
+
+// connectionLimitStatusCode returns the status code of the requests rejected by
+// the limits of concurrent connections. Without connection-limit-status-code
+// the status code of limit-conn-status-code is used.
+func connectionLimitStatusCode(cfg config.Configuration) int {
+	if cfg.ConnectionLimitStatusCode > 0 {
+		return cfg.ConnectionLimitStatusCode
+	}
+
+	return cfg.LimitConnStatusCode
+}
+
+// buildConnectionLimits produces the limit_conn of the concurrent connections
+// to be used inside the locations of a server. The annotation of the server
+// replaces the limit per host of the configuration. limit_conn_status is only
+// produced when connection-limit-status-code is set, so the limit-connections
+// annotation keeps limit-conn-status-code otherwise.
+func buildConnectionLimits(s interface{}, c interface{}) []string {
+	limits := []string{}
+
+	server, ok := s.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected an '*ingress.Server' type but %T was returned", s)
+		return limits
+	}
+
+	cfg, ok := c.(config.Configuration)
+	if !ok {
+		klog.Errorf("expected a 'config.Configuration' type but %T was returned", c)
+		return limits
+	}
+
+	perHost := cfg.MaxConnectionsPerHost
+	if server.MaxConnections > 0 {
+		perHost = server.MaxConnections
+	}
+
+	if perHost > 0 {
+		limits = append(limits, fmt.Sprintf("limit_conn host_connections %v;", perHost))
+	}
+
+	if cfg.MaxConnections > 0 {
+		limits = append(limits, fmt.Sprintf("limit_conn global_connections %v;", cfg.MaxConnections))
+	}
+
+	if len(limits) == 0 {
+		return limits
+	}
+
+	if cfg.ConnectionLimitStatusCode > 0 {
+		limits = append(limits, fmt.Sprintf("limit_conn_status %v;", cfg.ConnectionLimitStatusCode))
+	}
+
+	// set to 1 by the rewrite_by_lua_block of the location
+	limits = append(limits,
+		`set $connection_limit_checked "";`,
+		fmt.Sprintf(`more_set_headers -s %v "Retry-After: $connection_limit_retry_after";`, connectionLimitStatusCode(cfg)),
+	)
+
+	return limits
+}
+
 func isLocationInLocationList(location interface{}, rawLocationList string) bool {
 	loc, ok := location.(*ingress.Location)
 	if !ok {
@@ -691,6 +1633,8 @@ func isLocationAllowed(input interface{}) bool {
 
 var (
 	denyPathSlugMap = map[string]string{}
+	// the server blocks are rendered concurrently
+	denyPathSlugLock sync.Mutex
 )
 
 // buildDenyVariable returns a nginx variable for a location in a
@@ -705,6 +1649,9 @@ func buildDenyVariable(a interface{}) string {
 		return ""
 	}
 
+	denyPathSlugLock.Lock()
+	defer denyPathSlugLock.Unlock()
+
 	if _, ok := denyPathSlugMap[l]; !ok {
 		denyPathSlugMap[l] = randomString()
 	}
@@ -770,7 +1717,7 @@ func isValidByteSize(input interface{}, isOffset bool) bool {
 
 	s = strings.TrimSpace(s)
 	if s == "" {
-		klog.V(2).Info("empty byte size, hence it will not be set")
+		logging.V(2).Info("empty byte size, hence it will not be set")
 		return false
 	}
 
@@ -781,6 +1728,40 @@ func isValidByteSize(input interface{}, isOffset bool) bool {
 	return nginxSizeRegex.MatchString(s)
 }
 
+// proxyBuffersSize returns the size in bytes of the memory buffers of the
+// responses of a location, empty when the responses are not buffered
+func proxyBuffersSize(input interface{}) string {
+	p, ok := input.(proxy.Config)
+	if !ok {
+		klog.Errorf("expected a 'proxy.Config' type but %T was returned", input)
+		return ""
+	}
+
+	if p.ProxyBuffering != "on" || !isValidByteSize(p.BufferSize, false) {
+		return ""
+	}
+
+	s := strings.TrimSpace(p.BufferSize)
+	multiplier := 1
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		multiplier = 1024
+	case 'm', 'M':
+		multiplier = 1024 * 1024
+	}
+	if multiplier != 1 {
+		s = s[:len(s)-1]
+	}
+
+	size, err := strconv.Atoi(s)
+	if err != nil {
+		return ""
+	}
+
+	// proxy_buffer_size holds the beginning of the response, proxy_buffers the rest
+	return strconv.Itoa(size * multiplier * (p.BuffersNumber + 1))
+}
+
 type ingressInformation struct {
 	Namespace   string
 	Rule        string
@@ -985,6 +1966,9 @@ func buildCustomErrorDeps(upstreamName string, errorCodes []int, enableMetrics b
 	}
 }
 
+// plainHTTPCode is the code of the plain HTTP requests sent to the HTTPS port
+const plainHTTPCode = 497
+
 type errorLocation struct {
 	UpstreamName string
 	Codes        []int
@@ -1020,6 +2004,15 @@ func buildCustomErrorLocationsPerServer(input interface{}) interface{} {
 		codesMap[backendUpstream] = dedupedCodes
 	}
 
+	// the page of the plain HTTP requests sent to the HTTPS port
+	if server.PlainHTTP.Action == plainhttp.ActionPage {
+		backendUpstream := plainHTTPPageUpstream(server)
+		if _, ok := codesMap[backendUpstream]; !ok {
+			codesMap[backendUpstream] = make(map[int]bool)
+		}
+		codesMap[backendUpstream][plainHTTPCode] = true
+	}
+
 	errorLocations := []errorLocation{}
 
 	for upstream, dedupedCodes := range codesMap {
@@ -1041,6 +2034,41 @@ func buildCustomErrorLocationsPerServer(input interface{}) interface{} {
 	return errorLocations
 }
 
+// plainHTTPPageUpstream returns the default backend serving the page of the
+// plain HTTP requests sent to the HTTPS port, the one of the root location
+func plainHTTPPageUpstream(server *ingress.Server) string {
+	for _, loc := range server.Locations {
+		if loc.Path == "/" && loc.DefaultBackendUpstreamName != "" {
+			return loc.DefaultBackendUpstreamName
+		}
+	}
+
+	return "upstream-default-backend"
+}
+
+// buildPlainHTTPErrorPage returns the error page of the plain HTTP requests
+// sent to the HTTPS port of the server, an empty string to keep the default
+// response of NGINX
+func buildPlainHTTPErrorPage(input interface{}) string {
+	server, ok := input.(*ingress.Server)
+	if !ok {
+		klog.Errorf("expected a '*ingress.Server' type but %T was returned", input)
+		return ""
+	}
+
+	switch server.PlainHTTP.Action {
+	case plainhttp.ActionRedirect:
+		// the Host header contains the port used by the client, the HTTPS port
+		return fmt.Sprintf("=%v https://$http_host$request_uri", server.PlainHTTP.RedirectCode)
+	case plainhttp.ActionReject:
+		return "= @plain_http_reject"
+	case plainhttp.ActionPage:
+		return fmt.Sprintf("= @custom_%v_%v", plainHTTPPageUpstream(server), plainHTTPCode)
+	}
+
+	return ""
+}
+
 func opentracingPropagateContext(loc interface{}) string {
 	location, ok := loc.(*ingress.Location)
 	if !ok {
@@ -1071,6 +2099,11 @@ func shouldLoadModSecurityModule(c interface{}, s interface{}) bool {
 		return false
 	}
 
+	// The ModSecurity annotations are applied by the Coraza proxy instead.
+	if cfg.UseCoraza() {
+		return false
+	}
+
 	// Determine if ModSecurity is enabled globally.
 	if cfg.EnableModsecurity {
 		return true
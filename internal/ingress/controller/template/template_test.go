@@ -17,28 +17,49 @@ limitations under the License.
 package template
 
 import (
+	"encoding/base64"
+	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
 	"path"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
-
-	"encoding/base64"
-	"fmt"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
+	apiv1 "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
 	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/bodystreaming"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/bodytransform"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/botchallenge"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/canonicalpath"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/hostregex"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/influxdb"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/ipwhitelist"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/luarestywaf"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/modsecurity"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/plainhttp"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxychain"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/secureupstream"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/timewindow"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/tlsfingerprint"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/trafficcapture"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamhost"
 	"k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
 var (
@@ -198,6 +219,15 @@ func TestBuildLuaSharedDictionaries(t *testing.T) {
 	if !strings.Contains(configuration, "lua_shared_dict waf_storage") {
 		t.Errorf("expected to configure 'waf_storage', but got %s", configuration)
 	}
+	if strings.Contains(configuration, "bot_challenge") {
+		t.Errorf("expected to not include 'bot_challenge' but got %s", configuration)
+	}
+
+	servers[0].Locations[0].BotChallenge = botchallenge.Config{Mode: "js", Rate: 60}
+	configuration = buildLuaSharedDictionaries(servers, false)
+	if !strings.Contains(configuration, "lua_shared_dict bot_challenge") {
+		t.Errorf("expected to configure 'bot_challenge', but got %s", configuration)
+	}
 }
 
 func TestFormatIP(t *testing.T) {
@@ -219,6 +249,115 @@ func TestFormatIP(t *testing.T) {
 	}
 }
 
+func TestShouldCheckClientCertificate(t *testing.T) {
+	cases := map[string]struct {
+		Satisfy      string
+		CAFileName   string
+		VerifyClient string
+		AuthTLSError string
+		Output       bool
+		VerifyOutput string
+	}{
+		"without satisfy":              {"", "/etc/ingress-controller/ssl/ca.pem", "on", "", false, "on"},
+		"satisfy all":                  {"all", "/etc/ingress-controller/ssl/ca.pem", "on", "", false, "on"},
+		"without client certificate":   {"any", "", "", "", false, ""},
+		"verify client on":             {"any", "/etc/ingress-controller/ssl/ca.pem", "on", "", true, "optional"},
+		"verify client optional":       {"any", "/etc/ingress-controller/ssl/ca.pem", "optional", "", false, "optional"},
+		"verify client optional_no_ca": {"any", "/etc/ingress-controller/ssl/ca.pem", "optional_no_ca", "", false, "optional_no_ca"},
+		"invalid client certificate":   {"any", "/etc/ingress-controller/ssl/ca.pem", "on", "secret not found", false, "on"},
+	}
+	for k, tc := range cases {
+		// the other locations of the server verify the certificate too
+		location := &ingress.Location{}
+		server := &ingress.Server{
+			CertificateAuth: authtls.Config{
+				AuthSSLCert: resolver.AuthSSLCert{
+					CAFileName: tc.CAFileName,
+				},
+				VerifyClient: tc.VerifyClient,
+			},
+			AuthTLSError: tc.AuthTLSError,
+			Locations: []*ingress.Location{
+				location,
+				{Satisfy: tc.Satisfy},
+			},
+		}
+
+		res := shouldCheckClientCertificate(server, location)
+		if res != tc.Output {
+			t.Errorf("%s: expected '%v' but returned '%v'", k, tc.Output, res)
+		}
+		if verify := buildVerifyClient(server); verify != tc.VerifyOutput {
+			t.Errorf("%s: expected ssl_verify_client '%v' but returned '%v'", k, tc.VerifyOutput, verify)
+		}
+	}
+
+	if shouldCheckClientCertificate(&ingress.Location{}, &ingress.Location{}) {
+		t.Errorf("expected false with an invalid server type")
+	}
+	if shouldCheckClientCertificate(&ingress.Server{}, &ingress.Server{}) {
+		t.Errorf("expected false with an invalid location type")
+	}
+	if verify := buildVerifyClient(&ingress.Location{}); verify != "" {
+		t.Errorf("expected an empty string with an invalid type but returned '%v'", verify)
+	}
+
+	// a location excluded from the authentication does not require the
+	// certificate, the other locations verify it in the access phase
+	excluded := &ingress.Location{Path: "/healthz", CertificateAuthExcluded: true}
+	location := &ingress.Location{Path: "/"}
+	server := &ingress.Server{
+		CertificateAuth: authtls.Config{
+			AuthSSLCert: resolver.AuthSSLCert{
+				CAFileName: "/etc/ingress-controller/ssl/ca.pem",
+			},
+			VerifyClient: "on",
+		},
+		Locations: []*ingress.Location{location, excluded},
+	}
+
+	if shouldCheckClientCertificate(server, excluded) {
+		t.Errorf("expected no client certificate check in the excluded location")
+	}
+	if !shouldCheckClientCertificate(server, location) {
+		t.Errorf("expected a client certificate check in the other locations")
+	}
+	if verify := buildVerifyClient(server); verify != "optional" {
+		t.Errorf("expected ssl_verify_client 'optional' but returned '%v'", verify)
+	}
+
+	// satisfy any does not require the certificate in the excluded location
+	location.Satisfy = "any"
+	excluded.Satisfy = "any"
+	if shouldCheckClientCertificate(server, excluded) {
+		t.Errorf("expected no client certificate check in the excluded location using satisfy any")
+	}
+}
+
+func TestBuildHostRegex(t *testing.T) {
+	cases := map[string]struct {
+		Regex, Output string
+	}{
+		"without regex":     {"", ""},
+		"regex":             {`^api-(?<tenant>.+)\.example\.com$`, `"~^api-(?<tenant>.+)\.example\.com$"`},
+		"quantifier":        {`^[a-z]{2,3}\.example\.com$`, `"~^[a-z]{2,3}\.example\.com$"`},
+		"escaped backslash": {`^api\\.example\.com$`, `"~^api\\\\.example\.com$"`},
+	}
+	for k, tc := range cases {
+		server := &ingress.Server{
+			HostRegex: hostregex.Config{Regex: tc.Regex},
+		}
+		res := buildHostRegex(server)
+		if res != tc.Output {
+			t.Errorf("%s: called buildHostRegex('%s'); expected '%v' but returned '%v'", k, tc.Regex, tc.Output, res)
+		}
+	}
+
+	if res := buildHostRegex(&ingress.Location{}); res != "" {
+		t.Errorf("expected an empty string with an invalid type but returned '%v'", res)
+	}
+}
+
 func TestBuildLocation(t *testing.T) {
 	invalidType := &ingress.Ingress{}
 	expected := "/"
@@ -281,132 +420,1670 @@ func TestBuildProxyPass(t *testing.T) {
 	}
 }
 
-func TestBuildAuthLocation(t *testing.T) {
-	invalidType := &ingress.Ingress{}
-	expected := ""
-	actual := buildAuthLocation(invalidType, "")
-
-	if !reflect.DeepEqual(expected, actual) {
-		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+func TestBuildProxyPassWithProxyChain(t *testing.T) {
+	backends := []*ingress.Backend{{Name: "upstream-name"}}
+
+	testCases := []struct {
+		protocol string
+		target   string
+		expected string
+	}{
+		{"", "/", "proxy_pass http://$proxy_chain_address;"},
+		{"HTTPS", "/", "proxy_pass https://$proxy_chain_address;"},
+		{"GRPCS", "/", "grpc_pass grpcs://$proxy_chain_address;"},
+		{"AJP", "/", "ajp_pass upstream_balancer;"},
+		{"", "/jobs/$1", `
+rewrite "(?i)/" /jobs/$1 break;
+proxy_pass http://$proxy_chain_address;`},
+	}
+
+	for _, tc := range testCases {
+		loc := &ingress.Location{
+			Path:            "/",
+			Rewrite:         rewrite.Config{Target: tc.target},
+			Backend:         "upstream-name",
+			BackendProtocol: tc.protocol,
+			ProxyChain:      proxychain.Config{Address: "egress.proxy.svc:3128"},
+		}
+
+		pp := buildProxyPass("example.com", backends, loc)
+		if pp != tc.expected {
+			t.Errorf("%v: expected \n'%v'\nbut returned \n'%v'", tc.protocol, tc.expected, pp)
+		}
+	}
+}
+
+func TestBuildAuthLocation(t *testing.T) {
+	invalidType := &ingress.Ingress{}
+	expected := ""
+	actual := buildAuthLocation(invalidType, "")
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
+	}
+
+	authURL := "foo.com/auth"
+	globalAuthURL := "foo.com/global-auth"
+
+	loc := &ingress.Location{
+		ExternalAuth: authreq.Config{
+			URL: authURL,
+		},
+		Path:             "/cat",
+		EnableGlobalAuth: true,
+	}
+
+	encodedAuthURL := strings.Replace(base64.URLEncoding.EncodeToString([]byte(loc.Path)), "=", "", -1)
+	externalAuthPath := fmt.Sprintf("/_external-auth-%v", encodedAuthURL)
+
+	testCases := []struct {
+		title                    string
+		authURL                  string
+		globalAuthURL            string
+		enableglobalExternalAuth bool
+		expected                 string
+	}{
+		{"authURL, globalAuthURL and enabled", authURL, globalAuthURL, true, externalAuthPath},
+		{"authURL, globalAuthURL and disabled", authURL, globalAuthURL, false, externalAuthPath},
+		{"authURL, empty globalAuthURL and enabled", authURL, "", true, externalAuthPath},
+		{"authURL, empty globalAuthURL and disabled", authURL, "", false, externalAuthPath},
+		{"globalAuthURL and enabled", "", globalAuthURL, true, externalAuthPath},
+		{"globalAuthURL and disabled", "", globalAuthURL, false, ""},
+		{"all empty and enabled", "", "", true, ""},
+		{"all empty and disabled", "", "", false, ""},
+	}
+
+	for _, testCase := range testCases {
+		loc.ExternalAuth.URL = testCase.authURL
+		loc.EnableGlobalAuth = testCase.enableglobalExternalAuth
+
+		str := buildAuthLocation(loc, testCase.globalAuthURL)
+		if str != testCase.expected {
+			t.Errorf("%v: expected '%v' but returned '%v'", testCase.title, testCase.expected, str)
+		}
+	}
+}
+
+func TestShouldApplyGlobalAuth(t *testing.T) {
+
+	authURL := "foo.com/auth"
+	globalAuthURL := "foo.com/global-auth"
+
+	loc := &ingress.Location{
+		ExternalAuth: authreq.Config{
+			URL: authURL,
+		},
+		Path:             "/cat",
+		EnableGlobalAuth: true,
+	}
+
+	testCases := []struct {
+		title                    string
+		authURL                  string
+		globalAuthURL            string
+		enableglobalExternalAuth bool
+		expected                 bool
+	}{
+		{"authURL, globalAuthURL and enabled", authURL, globalAuthURL, true, false},
+		{"authURL, globalAuthURL and disabled", authURL, globalAuthURL, false, false},
+		{"authURL, empty globalAuthURL and enabled", authURL, "", true, false},
+		{"authURL, empty globalAuthURL and disabled", authURL, "", false, false},
+		{"globalAuthURL and enabled", "", globalAuthURL, true, true},
+		{"globalAuthURL and disabled", "", globalAuthURL, false, false},
+		{"all empty and enabled", "", "", true, false},
+		{"all empty and disabled", "", "", false, false},
+	}
+
+	for _, testCase := range testCases {
+		loc.ExternalAuth.URL = testCase.authURL
+		loc.EnableGlobalAuth = testCase.enableglobalExternalAuth
+
+		result := shouldApplyGlobalAuth(loc, testCase.globalAuthURL)
+		if result != testCase.expected {
+			t.Errorf("%v: expected '%v' but returned '%v'", testCase.title, testCase.expected, result)
+		}
+	}
+}
+
+func TestBuildProxySSL(t *testing.T) {
+	service := &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}}
+	externalName := &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "external", Namespace: "default"},
+		Spec:       apiv1.ServiceSpec{Type: apiv1.ServiceTypeExternalName, ExternalName: "api.example.com"},
+	}
+	ca := resolver.AuthSSLCert{Secret: "default/ca", CAFileName: "/etc/ingress-controller/ssl/ca-default-ca.pem"}
+
+	testCases := []struct {
+		title    string
+		location *ingress.Location
+		expected []string
+	}{
+		{"HTTP backend", &ingress.Location{BackendProtocol: "HTTP", Service: service, ProxySSL: secureupstream.Config{CACert: ca, Verify: true, VerifyDepth: 1}}, []string{}},
+		{"HTTPS backend without CA", &ingress.Location{BackendProtocol: "HTTPS", Service: service}, []string{}},
+		{"HTTPS backend with CA", &ingress.Location{BackendProtocol: "HTTPS", Service: service, ProxySSL: secureupstream.Config{CACert: ca, Verify: true, VerifyDepth: 2}}, []string{
+			"proxy_ssl_trusted_certificate /etc/ingress-controller/ssl/ca-default-ca.pem;",
+			"proxy_ssl_verify on;",
+			"proxy_ssl_verify_depth 2;",
+			"proxy_ssl_server_name on;",
+			"proxy_ssl_name app.default.svc;",
+		}},
+		{"GRPCS backend with server name", &ingress.Location{BackendProtocol: "GRPCS", Service: service, ProxySSL: secureupstream.Config{CACert: ca, Verify: true, VerifyDepth: 1, ServerName: "grpc.example.com"}}, []string{
+			"grpc_ssl_trusted_certificate /etc/ingress-controller/ssl/ca-default-ca.pem;",
+			"grpc_ssl_verify on;",
+			"grpc_ssl_verify_depth 1;",
+			"grpc_ssl_server_name on;",
+			"grpc_ssl_name grpc.example.com;",
+		}},
+		{"HTTPS backend with verification disabled", &ingress.Location{BackendProtocol: "HTTPS", Service: service, ProxySSL: secureupstream.Config{CACert: ca, VerifyDepth: 1}}, []string{
+			"proxy_ssl_trusted_certificate /etc/ingress-controller/ssl/ca-default-ca.pem;",
+			"proxy_ssl_verify off;",
+			"proxy_ssl_verify_depth 1;",
+		}},
+		{"ExternalName backend with upstream host", &ingress.Location{BackendProtocol: "HTTPS", Service: externalName, UpstreamHost: upstreamhost.Config{Host: "api.example.com:443"}}, []string{
+			"proxy_ssl_server_name on;",
+			"proxy_ssl_name api.example.com;",
+		}},
+		{"ExternalName backend with preserved host", &ingress.Location{BackendProtocol: "HTTPS", Service: externalName, UpstreamHost: upstreamhost.Config{Preserve: true}}, []string{
+			"proxy_ssl_server_name on;",
+			"proxy_ssl_name $host;",
+		}},
+		{"ExternalName backend with server name", &ingress.Location{BackendProtocol: "HTTPS", Service: externalName, UpstreamHost: upstreamhost.Config{Host: "api.example.com"}, ProxySSL: secureupstream.Config{ServerName: "sni.example.com"}}, []string{
+			"proxy_ssl_server_name on;",
+			"proxy_ssl_name sni.example.com;",
+		}},
+		{"GRPCS ExternalName backend with upstream host", &ingress.Location{BackendProtocol: "GRPCS", Service: externalName, UpstreamHost: upstreamhost.Config{Host: "api.example.com"}}, []string{}},
+		{"ExternalName backend with variable host", &ingress.Location{BackendProtocol: "HTTPS", Service: externalName, UpstreamHost: upstreamhost.Config{Host: "$http_x_original_host"}}, []string{}},
+		{"upstream host of a cluster service", &ingress.Location{BackendProtocol: "HTTPS", Service: service, UpstreamHost: upstreamhost.Config{Host: "api.example.com"}}, []string{}},
+	}
+
+	for _, testCase := range testCases {
+		result := buildProxySSL(testCase.location)
+		if !reflect.DeepEqual(testCase.expected, result) {
+			t.Errorf("%v: expected '%v' but returned '%v'", testCase.title, testCase.expected, result)
+		}
+	}
+
+	if result := buildProxySSL(&ingress.Server{}); len(result) != 0 {
+		t.Errorf("expected no directives with an invalid location but returned '%v'", result)
+	}
+}
+
+func TestBuildUpstreamHost(t *testing.T) {
+	testCases := []struct {
+		title    string
+		location *ingress.Location
+		expected string
+	}{
+		{"default", &ingress.Location{}, "$best_http_host"},
+		{"upstream vhost", &ingress.Location{UpstreamVhost: "vhost.example.com"}, `"vhost.example.com"`},
+		{"static host", &ingress.Location{UpstreamVhost: "vhost.example.com", UpstreamHost: upstreamhost.Config{Host: "api.example.com:8443"}}, `"api.example.com:8443"`},
+		{"variable host", &ingress.Location{UpstreamHost: upstreamhost.Config{Host: "$http_x_original_host"}}, "$http_x_original_host"},
+		{"preserved host", &ingress.Location{UpstreamVhost: "vhost.example.com", UpstreamHost: upstreamhost.Config{Preserve: true}}, "$http_host"},
+	}
+
+	for _, testCase := range testCases {
+		if result := buildUpstreamHost(testCase.location); result != testCase.expected {
+			t.Errorf("%v: expected '%v' but returned '%v'", testCase.title, testCase.expected, result)
+		}
+	}
+
+	if result := buildUpstreamHost(&ingress.Server{}); result != "$best_http_host" {
+		t.Errorf("expected the host of the request with an invalid location but returned '%v'", result)
+	}
+}
+
+func TestBuildPlainHTTPErrorPage(t *testing.T) {
+	locations := []*ingress.Location{
+		{Path: "/api", DefaultBackendUpstreamName: "upstream-default-backend"},
+		{Path: "/", DefaultBackendUpstreamName: "custom-default-backend-errors"},
+	}
+
+	testCases := []struct {
+		title    string
+		config   plainhttp.Config
+		expected string
+	}{
+		{"default", plainhttp.Config{}, ""},
+		{"redirect", plainhttp.Config{Action: plainhttp.ActionRedirect, RedirectCode: 308}, "=308 https://$http_host$request_uri"},
+		{"reject", plainhttp.Config{Action: plainhttp.ActionReject}, "= @plain_http_reject"},
+		{"page", plainhttp.Config{Action: plainhttp.ActionPage}, "= @custom_custom-default-backend-errors_497"},
+	}
+
+	for _, testCase := range testCases {
+		server := &ingress.Server{Locations: locations, PlainHTTP: testCase.config}
+		if result := buildPlainHTTPErrorPage(server); result != testCase.expected {
+			t.Errorf("%v: expected '%v' but returned '%v'", testCase.title, testCase.expected, result)
+		}
+	}
+
+	if result := buildPlainHTTPErrorPage(&ingress.Location{}); result != "" {
+		t.Errorf("expected no error page with an invalid server but returned '%v'", result)
+	}
+}
+
+func TestBodyTransformConfigForLua(t *testing.T) {
+	loc := &ingress.Location{
+		BodyTransform: bodytransform.Config{
+			RequestEncoding:  "identity",
+			RedactJSONFields: []string{"password", "user.ssn"},
+			MaxSize:          1024,
+		},
+	}
+
+	expected := `{
+		request_encoding = "identity",
+		redact_json_fields = { "password", "user.ssn" },
+		max_size = 1024,
+	}`
+	if actual := bodyTransformConfigForLua(loc); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	if actual := bodyTransformConfigForLua(&ingress.Server{}); actual != "{}" {
+		t.Errorf("expected '{}' with an invalid location but returned '%v'", actual)
+	}
+}
+
+func TestLocationConfigForLua(t *testing.T) {
+	all := config.TemplateConfig{Cfg: config.Configuration{HTTPRedirectCode: 308}}
+	server := &ingress.Server{SSLCert: ingress.SSLCert{PemFileName: "/etc/ingress-controller/ssl/default-tls.pem"}}
+
+	location := &ingress.Location{Path: "/", Rewrite: rewrite.Config{SSLRedirect: true}}
+	expected := `{
+		force_ssl_redirect = true,
+		use_port_in_redirects = false,
+		ssl_redirect_code = 308,
+		ssl_redirect_port = 0,
+		ssl_redirect_exclude_paths = {},
+	}`
+	if result := locationConfigForLua(location, server, all); result != expected {
+		t.Errorf("expected '%v' but returned '%v'", expected, result)
+	}
+
+	location = &ingress.Location{Path: "/", Rewrite: rewrite.Config{
+		ForceSSLRedirect:        true,
+		SSLRedirectCode:         301,
+		SSLRedirectExcludePaths: []string{"/.well-known/acme-challenge/", "/healthz"},
+		SSLRedirectPort:         30443,
+	}}
+	expected = `{
+		force_ssl_redirect = true,
+		use_port_in_redirects = false,
+		ssl_redirect_code = 301,
+		ssl_redirect_port = 30443,
+		ssl_redirect_exclude_paths = require("cjson.safe").decode([=[["/.well-known/acme-challenge/","/healthz"]]=]),
+	}`
+	if result := locationConfigForLua(location, &ingress.Server{}, all); result != expected {
+		t.Errorf("expected '%v' but returned '%v'", expected, result)
+	}
+
+	if result := locationConfigForLua(&ingress.Server{}, server, all); result != "{}" {
+		t.Errorf("expected an empty table with an invalid location but returned '%v'", result)
+	}
+}
+
+func TestCanonicalPathConfigForLua(t *testing.T) {
+	if actual := canonicalPathConfigForLua(&ingress.Location{}); actual != "" {
+		t.Errorf("expected an empty string without path canonicalization but returned '%v'", actual)
+	}
+
+	loc := &ingress.Location{
+		CanonicalPath: canonicalpath.Config{
+			MergeSlashes:             "off",
+			TrailingSlash:            "add",
+			NormalizePercentEncoding: true,
+		},
+	}
+
+	expected := `{
+		merge_slashes = "off",
+		trailing_slash = "add",
+		normalize_percent_encoding = true,
+	}`
+	if actual := canonicalPathConfigForLua(loc); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	if actual := canonicalPathConfigForLua(&ingress.Server{}); actual != "" {
+		t.Errorf("expected an empty string with an invalid location but returned '%v'", actual)
+	}
+}
+
+func TestTimeWindowConfigForLua(t *testing.T) {
+	if actual := timeWindowConfigForLua(&ingress.Location{}); actual != "" {
+		t.Errorf("expected an empty string without time windows but returned '%v'", actual)
+	}
+
+	tw := timewindow.Config{
+		Allowed:    []string{"* 9-11 * * mon-fri", "* * * * sat"},
+		Denied:     []string{"0-29 10 1 * *"},
+		TimeZone:   "UTC",
+		StatusCode: 503,
+	}
+
+	expected := `{
+		status = 503,
+		offset = 0,
+		offsets = {  },
+		allowed = { { hours = { [9] = true, [10] = true, [11] = true }, weekdays = { [1] = true, [2] = true, [3] = true, [4] = true, [5] = true } }, { weekdays = { [6] = true } } },
+		denied = { { minutes = { [0] = true, [1] = true, [2] = true, [3] = true, [4] = true, [5] = true, [6] = true, [7] = true, [8] = true, [9] = true, [10] = true, [11] = true, [12] = true, [13] = true, [14] = true, [15] = true, [16] = true, [17] = true, [18] = true, [19] = true, [20] = true, [21] = true, [22] = true, [23] = true, [24] = true, [25] = true, [26] = true, [27] = true, [28] = true, [29] = true }, hours = { [10] = true }, days = { [1] = true } } },
+	}`
+	if actual := timeWindowConfigForLua(&ingress.Location{TimeWindow: tw}); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	tw.TimeZone = "Europe/Paris"
+	if _, err := timewindow.LoadLocation(tw.TimeZone); err == nil {
+		now := time.Date(2019, time.July, 1, 0, 0, 0, 0, time.UTC)
+		actual := buildTimeWindowConfig(tw, now)
+		// the offsets of 2019 and 2020
+		transitions := "offsets = { { ends = 1553994000, offset = 3600 }, { ends = 1572138000, offset = 7200 }, { ends = 1585443600, offset = 3600 }, { ends = 1603587600, offset = 7200 } }"
+		if !strings.Contains(actual, transitions) || !strings.Contains(actual, "offset = 3600,") {
+			t.Errorf("expected the offsets of the time zone in '%v'", actual)
+		}
+	}
+
+	if actual := timeWindowConfigForLua(&ingress.Server{}); actual != "" {
+		t.Errorf("expected an empty string with an invalid location but returned '%v'", actual)
+	}
+}
+
+func TestTrafficCaptureConfigForLua(t *testing.T) {
+	loc := &ingress.Location{
+		TrafficCapture: trafficcapture.Config{
+			Sink:       "kafka://kafka-rest:8082/replay",
+			SampleRate: 0.05,
+			MaxRPS:     100,
+			Headers:    []string{"user-agent", "accept"},
+			Args:       []string{"page"},
+		},
+	}
+
+	expected := `{
+		sink = "kafka://kafka-rest:8082/replay",
+		sample_rate = 0.05,
+		max_rps = 100,
+		headers = { "user-agent", "accept" },
+		args = { "page" },
+		body = false,
+	}`
+	if actual := trafficCaptureConfigForLua(loc); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	if actual := trafficCaptureConfigForLua(&ingress.Server{}); actual != "{}" {
+		t.Errorf("expected '{}' with an invalid location but returned '%v'", actual)
+	}
+}
+
+func TestCorazaConfigForLua(t *testing.T) {
+	loc := &ingress.Location{
+		ModSecurity: modsecurity.Config{
+			Enable:        true,
+			OWASPRules:    true,
+			TransactionID: "$request_id",
+		},
+	}
+
+	cfg := config.NewDefault()
+	if actual := corazaConfigForLua(loc, cfg); actual != "" {
+		t.Errorf("expected an empty configuration with ModSecurity but returned '%v'", actual)
+	}
+
+	cfg.WAFEngine = "coraza"
+	cfg.CorazaURL = "http://coraza.waf:8080/"
+
+	expected := `{
+		owasp_core_rules = true,
+		transaction_id = "$request_id",
+	}`
+	if actual := corazaConfigForLua(loc, cfg); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	if actual := corazaConfigForLua(&ingress.Location{}, cfg); actual != "" {
+		t.Errorf("expected an empty configuration without ModSecurity but returned '%v'", actual)
+	}
+
+	cfg.EnableModsecurity = true
+	cfg.EnableOWASPCoreRules = true
+	expected = `{
+		owasp_core_rules = true,
+		transaction_id = "",
+	}`
+	if actual := corazaConfigForLua(&ingress.Location{}, cfg); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+}
+
+func TestBotChallengeConfigForLua(t *testing.T) {
+	loc := &ingress.Location{
+		BotChallenge: botchallenge.Config{
+			Mode:             "pow",
+			Difficulty:       16,
+			Rate:             120,
+			ASNs:             []string{"16509"},
+			UserAgents:       []string{"^curl/"},
+			ExemptCIDRs:      []string{"10.0.0.0/8"},
+			ExemptUserAgents: []string{"Googlebot"},
+		},
+	}
+
+	expected := `{
+		mode = "pow",
+		difficulty = 16,
+		rate = 120,
+		asns = { "16509" },
+		user_agents = { "^curl/" },
+		exempt_cidrs = { "10.0.0.0/8" },
+		exempt_user_agents = { "Googlebot" },
+	}`
+	if actual := botChallengeConfigForLua(loc); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	if actual := botChallengeConfigForLua(&ingress.Server{}); actual != "{}" {
+		t.Errorf("expected '{}' with an invalid location but returned '%v'", actual)
+	}
+}
+
+func TestTLSFingerprintConfigForLua(t *testing.T) {
+	loc := &ingress.Location{
+		TLSFingerprint: tlsfingerprint.Config{
+			Allowed: []string{"t13d1516h2_8daaf6152771_e5627efa2ab1"},
+			Denied:  []string{"e7d705a3286e19ea42f587b344ee6865"},
+		},
+	}
+
+	expected := `{
+		allowed = { "t13d1516h2_8daaf6152771_e5627efa2ab1" },
+		denied = { "e7d705a3286e19ea42f587b344ee6865" },
+	}`
+	if actual := tlsFingerprintConfigForLua(loc); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	expected = `{
+		allowed = {  },
+		denied = {  },
+	}`
+	if actual := tlsFingerprintConfigForLua(&ingress.Location{}); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	if actual := tlsFingerprintConfigForLua(&ingress.Server{}); actual != "{}" {
+		t.Errorf("expected '{}' with an invalid location but returned '%v'", actual)
+	}
+}
+
+func TestAccessEventsConfigForLua(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.AccessEventsSink = "kafka://kafka-rest:8082/access-events"
+
+	expected := `{
+		sink = "kafka://kafka-rest:8082/access-events",
+		batch_size = 100,
+		flush_interval = 1,
+		buffer_size = 10000,
+	}`
+	if actual := accessEventsConfigForLua(cfg); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	if actual := accessEventsConfigForLua(&ingress.Server{}); actual != "{}" {
+		t.Errorf("expected '{}' with an invalid configuration but returned '%v'", actual)
+	}
+}
+
+func TestUsageConfigForLua(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.EnableUsageAccounting = true
+	cfg.UsageExportSink = "file://usage.csv"
+	cfg.UsageExportFormat = "csv"
+
+	ing := func(namespace, name string) *ingress.Ingress {
+		return &ingress.Ingress{Ingress: networking.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}}
+	}
+	servers := []*ingress.Server{
+		{Locations: []*ingress.Location{{Ingress: ing("shop", "web")}, {}}},
+		{Locations: []*ingress.Location{{Ingress: ing("default", "api")}, {Ingress: ing("shop", "web")}}},
+	}
+
+	expected := `{
+		sink = "file://usage.csv",
+		format = "csv",
+		interval = 60,
+		ingresses = { "default api", "shop web" },
+	}`
+	if actual := usageConfigForLua(cfg, servers); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	if actual := usageConfigForLua(&ingress.Server{}, servers); actual != "{}" {
+		t.Errorf("expected '{}' with an invalid configuration but returned '%v'", actual)
+	}
+
+	if actual := usageConfigForLua(cfg, &ingress.Server{}); actual != "{}" {
+		t.Errorf("expected '{}' with invalid servers but returned '%v'", actual)
+	}
+}
+
+func TestSharedStateConfigForLua(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.SharedStateBackend = "redis://redis.ingress-nginx:6379/2"
+
+	expected := `{
+		backend = "redis://redis.ingress-nginx:6379/2",
+		prefix = "ingress-nginx",
+		sync_interval = 1,
+		timeout = 100,
+	}`
+	if actual := sharedStateConfigForLua(cfg); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	if actual := sharedStateConfigForLua(&ingress.Server{}); actual != "{}" {
+		t.Errorf("expected '{}' with an invalid configuration but returned '%v'", actual)
+	}
+}
+
+func TestNoEndpointsConfigForLua(t *testing.T) {
+	cfg := config.NewDefault()
+	cfg.NoEndpointsRetryAfter = 5
+	cfg.NoEndpointsRetryAfterMax = 60
+	cfg.NoEndpointsPage = "<p>\"app\" est en cours de déploiement</p>\n"
+
+	expected := `{
+		retry_after = 5,
+		retry_after_max = 60,
+		page = require("cjson.safe").decode([["\u003cp\u003e\"app\" est en cours de déploiement\u003c/p\u003e\n"]]),
+	}`
+	if actual := noEndpointsConfigForLua(cfg); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	if actual := noEndpointsConfigForLua(&ingress.Server{}); actual != "{}" {
+		t.Errorf("expected '{}' with an invalid configuration but returned '%v'", actual)
+	}
+}
+
+func TestLuaJSON(t *testing.T) {
+	testCases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{"app", `require("cjson.safe").decode([["app"]])`},
+		{[]string{"/a]]", "/b"}, `require("cjson.safe").decode([=[["/a]]","/b"]]=])`},
+		{[]string{"/a"}, `require("cjson.safe").decode([=[["/a"]]=])`},
+		{[]string{}, `require("cjson.safe").decode([=[[]]=])`},
+	}
+
+	for _, tc := range testCases {
+		if actual := luaJSON(tc.value); actual != tc.expected {
+			t.Errorf("expected %v but returned %v", tc.expected, actual)
+		}
+	}
+}
+
+func TestNormalizationConfigForLua(t *testing.T) {
+	cfg := config.NewDefault()
+	if cfg.RequestNormalizationEnabled() {
+		t.Errorf("expected the request normalization to be disabled by default")
+	}
+
+	cfg.RejectAmbiguousRequests = true
+	cfg.MaxRequestHeaders = 100
+	cfg.DecodePathTraversal = true
+
+	if !cfg.RequestNormalizationEnabled() {
+		t.Errorf("expected the request normalization to be enabled")
+	}
+
+	expected := `{
+		reject_ambiguous_requests = true,
+		normalize_duplicate_headers = false,
+		max_headers = 100,
+		max_header_size = 0,
+		decode_path_traversal = true,
+	}`
+	if actual := normalizationConfigForLua(cfg); actual != expected {
+		t.Errorf("expected \n'%v'\nbut returned \n'%v'", expected, actual)
+	}
+
+	if actual := normalizationConfigForLua(&ingress.Server{}); actual != "{}" {
+		t.Errorf("expected '{}' with an invalid configuration but returned '%v'", actual)
+	}
+}
+
+func TestBuildAuthResponseHeaders(t *testing.T) {
+	externalAuthResponseHeaders := []string{"h1", "H-With-Caps-And-Dashes"}
+	expected := []string{
+		"auth_request_set $authHeader0 $upstream_http_h1;",
+		"proxy_set_header 'h1' $authHeader0;",
+		"auth_request_set $authHeader1 $upstream_http_h_with_caps_and_dashes;",
+		"proxy_set_header 'H-With-Caps-And-Dashes' $authHeader1;",
+	}
+
+	headers := buildAuthResponseHeaders(externalAuthResponseHeaders, false)
+
+	if !reflect.DeepEqual(expected, headers) {
+		t.Errorf("Expected \n'%v'\nbut returned \n'%v'", expected, headers)
+	}
+}
+
+func TestBuildAuthResponseHeadersWithRenaming(t *testing.T) {
+	externalAuthResponseHeaders := []string{"X-Auth-User:X-User", "X-Auth-*", "h1"}
+	expected := []string{
+		"auth_request_set $authHeader0 $upstream_http_x_auth_user;",
+		"proxy_set_header 'X-User' $authHeader0;",
+		"add_header 'X-User' $authHeader0;",
+		"auth_request_set $authHeader2 $upstream_http_h1;",
+		"proxy_set_header 'h1' $authHeader2;",
+		"add_header 'h1' $authHeader2;",
+	}
+
+	headers := buildAuthResponseHeaders(externalAuthResponseHeaders, true)
+
+	if !reflect.DeepEqual(expected, headers) {
+		t.Errorf("Expected \n'%v'\nbut returned \n'%v'", expected, headers)
+	}
+}
+
+func TestBuildAuthResponseHeaderPrefixes(t *testing.T) {
+	testCases := []struct {
+		title    string
+		headers  []string
+		expected string
+	}{
+		{"no headers", []string{}, ""},
+		{"no prefixes", []string{"h1", "X-Auth-User:X-User"}, ""},
+		{"prefix", []string{"h1", "X-Auth-*"}, `{ { prefix = "x-auth-", replacement = "X-Auth-" } }`},
+		{"renamed prefixes", []string{"X-Auth-*:X-*", "X-Remote-*:*"},
+			`{ { prefix = "x-auth-", replacement = "X-" }, { prefix = "x-remote-", replacement = "" } }`},
+	}
+
+	for _, tc := range testCases {
+		result := buildAuthResponseHeaderPrefixes(tc.headers)
+		if result != tc.expected {
+			t.Errorf("%v: expected '%v' but returned '%v'", tc.title, tc.expected, result)
+		}
+	}
+}
+
+func TestBuildAuthRequestHeaders(t *testing.T) {
+	forwardHeaders := []string{"Authorization", "X-Forwarded-Client-Cert"}
+	requestHeaders := map[string]string{"X-Service-Id": "ingress", "X-Env": "prod"}
+	expected := []string{
+		"proxy_set_header 'Authorization' $http_authorization;",
+		"proxy_set_header 'X-Forwarded-Client-Cert' $http_x_forwarded_client_cert;",
+		"proxy_set_header 'X-Env' 'prod';",
+		"proxy_set_header 'X-Service-Id' 'ingress';",
+	}
+
+	headers := buildAuthRequestHeaders(forwardHeaders, requestHeaders)
+
+	if !reflect.DeepEqual(expected, headers) {
+		t.Errorf("Expected \n'%v'\nbut returned \n'%v'", expected, headers)
+	}
+}
+
+func TestTemplateWithData(t *testing.T) {
+	pwd, _ := os.Getwd()
+	f, err := os.Open(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Errorf("unexpected error reading json file: %v", err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Error("unexpected error reading json file: ", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Errorf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Errorf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Errorf("invalid NGINX template: %v", err)
+	}
+
+	if !strings.Contains(string(rt), "listen [2001:db8:a0b:12f0::1]") {
+		t.Errorf("invalid NGINX template, expected IPV6 listen address not present")
+	}
+
+	if !strings.Contains(string(rt), "listen [3731:54:65fe:2::a7]") {
+		t.Errorf("invalid NGINX template, expected IPV6 listen address not present")
+	}
+
+	if !strings.Contains(string(rt), "listen 2.2.2.2") {
+		t.Errorf("invalid NGINX template, expected IPV4 listen address not present")
+	}
+}
+
+func TestTemplateWithStreamMetrics(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	dat.EnableMetrics = true
+	dat.TCPBackends = []ingress.L4Service{{
+		Port: 5432,
+		Backend: ingress.L4Backend{
+			Namespace: "databases",
+			Name:      "postgres",
+			Port:      intstr.FromInt(5432),
+			Protocol:  apiv1.ProtocolTCP,
+		},
+	}}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	expected := []string{
+		"tcp_udp_monitor.init_worker()",
+		`ngx.var.namespace="databases";`,
+		`ngx.var.service_name="postgres";`,
+		`ngx.var.service_port="5432";`,
+		"tcp_udp_monitor.call()",
+	}
+	for _, e := range expected {
+		if !strings.Contains(string(rt), e) {
+			t.Errorf("invalid NGINX template, expected %q not present", e)
+		}
+	}
+}
+
+func TestTemplateWithRequestNormalization(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+	if strings.Contains(string(rt), "request_normalization") {
+		t.Errorf("invalid NGINX template, unexpected request normalization without rule")
+	}
+
+	dat.Cfg.NormalizeDuplicateHeaders = true
+	rt, err = ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	expected := []string{
+		"request_normalization.set_config({",
+		"normalize_duplicate_headers = true,",
+		"request_normalization.rewrite()",
+	}
+	for _, e := range expected {
+		if !strings.Contains(string(rt), e) {
+			t.Errorf("invalid NGINX template, expected %q not present", e)
+		}
+	}
+	if strings.Count(string(rt), "request_normalization.rewrite()") != strings.Count(string(rt), "lua_ingress.rewrite(") {
+		t.Errorf("invalid NGINX template, expected the request normalization in every location")
+	}
+}
+
+func TestTemplateWithUDPOptions(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	responses := 0
+	dat.Cfg.ProxyStreamResponses = 1
+	dat.Cfg.ProxyStreamTimeout = "600s"
+	dat.UDPBackends = []ingress.L4Service{
+		{
+			Port:    514,
+			Backend: ingress.L4Backend{Namespace: "logging", Name: "syslog", Port: intstr.FromInt(514), Protocol: apiv1.ProtocolUDP, ProxyResponses: &responses, ProxyTimeout: "10s"},
+		},
+		{
+			Port:    53,
+			Backend: ingress.L4Backend{Namespace: "kube-system", Name: "kube-dns", Port: intstr.FromInt(53), Protocol: apiv1.ProtocolUDP},
+		},
+	}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := regexp.MustCompile(`\s+`).ReplaceAllString(string(rt), " ")
+	expected := []string{
+		`"udp-logging-syslog-514";.*? proxy_responses 0; proxy_timeout 10s;`,
+		`"udp-kube-system-kube-dns-53";.*? proxy_responses 1; proxy_timeout 600s;`,
+	}
+	for _, e := range expected {
+		if !regexp.MustCompile(e).MatchString(conf) {
+			t.Errorf("invalid NGINX template, expected %q not present", e)
+		}
+	}
+}
+
+func TestTemplateWithLogDestinations(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	cfg := ReadConfig(map[string]string{
+		"access-log-destinations": "stderr?format=json,syslog+tls://logs.example.com",
+		"error-log-destinations":  "syslog://10.0.0.1?severity=warn",
+	})
+	dat.Cfg.DisableAccessLog = false
+	dat.Cfg.AccessLogPath = "/var/log/nginx/access.log"
+	dat.Cfg.AccessLogParams = "buffer=4k"
+	dat.Cfg.AccessLogDestinations = cfg.AccessLogDestinations
+	dat.Cfg.ErrorLogDestinations = cfg.ErrorLogDestinations
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	socket := cfg.AccessLogDestinations[1].Relay.Socket()
+	expected := []string{
+		"log_format upstreaminfo_json escape=json",
+		"access_log /dev/stderr upstreaminfo_json if=$loggable;",
+		fmt.Sprintf("access_log syslog:server=unix:%v upstreaminfo if=$loggable;", socket),
+		"error_log syslog:server=10.0.0.1:514 warn;",
+		"log_format log_stream_json escape=json",
+		"access_log /dev/stderr log_stream_json;",
+		fmt.Sprintf("access_log syslog:server=unix:%v log_stream;", socket),
+	}
+	for _, e := range expected {
+		if !strings.Contains(string(rt), e) {
+			t.Errorf("invalid NGINX template, expected %q not present", e)
+		}
+	}
+
+	if strings.Contains(string(rt), dat.Cfg.AccessLogPath) {
+		t.Errorf("invalid NGINX template, unexpected access log path %v", dat.Cfg.AccessLogPath)
+	}
+}
+
+func TestTemplateWithDebugHosts(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	dat.Cfg.ErrorLogPath = "/var/log/nginx/error.log"
+	dat.DebugHosts = map[string]bool{"foo-898.bar.com": true}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	if c := strings.Count(string(rt), "error_log  /var/log/nginx/error.log debug;"); c != 1 {
+		t.Errorf("invalid NGINX template, expected the debug logs in one server but found %v", c)
+	}
+
+	start := strings.Index(string(rt), "## start server foo-898.bar.com")
+	end := strings.Index(string(rt), "## end server foo-898.bar.com")
+	if start < 0 || end < start || !strings.Contains(string(rt)[start:end], "error.log debug;") {
+		t.Errorf("invalid NGINX template, expected the debug logs in the server foo-898.bar.com")
+	}
+}
+
+func TestTemplateWithModsecurityAudit(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	dat.Cfg.EnableModsecurity = true
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	if strings.Contains(string(rt), "SecAuditLog ") {
+		t.Errorf("invalid NGINX template, unexpected audit log without metrics or sink")
+	}
+
+	dat.Cfg.ModsecurityAuditMetrics = true
+	rt, err = ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	if c := strings.Count(string(rt), "SecAuditLog /var/log/nginx/modsec_audit.json"); c != 1 {
+		t.Errorf("invalid NGINX template, expected the audit log read by the controller once but found %v", c)
+	}
+}
+
+func TestTemplateWithCoraza(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	dat.Cfg.EnableModsecurity = true
+	dat.Cfg.WAFEngine = "coraza"
+	dat.Cfg.CorazaURL = "http://coraza.waf:8080/"
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	if strings.Contains(string(rt), "modsecurity on;") || strings.Contains(string(rt), "ngx_http_modsecurity_module") {
+		t.Errorf("invalid NGINX template, unexpected ModSecurity with the Coraza WAF engine")
+	}
+
+	if c, s := strings.Count(string(rt), "location = /_coraza-waf {"), len(dat.Servers); c != s {
+		t.Errorf("invalid NGINX template, expected the Coraza location in the %v servers but found %v", s, c)
+	}
+
+	if !strings.Contains(string(rt), `set $coraza_url             "http://coraza.waf:8080/";`) {
+		t.Errorf("invalid NGINX template, expected the requests proxied to the Coraza proxy")
+	}
+
+	if !strings.Contains(string(rt), "coraza.access({") {
+		t.Errorf("invalid NGINX template, expected the requests inspected by Coraza")
+	}
+}
+
+func TestTemplateWithTLSFingerprints(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	// the fingerprints are computed by the SSL passthrough proxy
+	dat.Cfg.EnableTLSFingerprints = true
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	if strings.Contains(string(rt), "tls_fingerprint.rewrite(") {
+		t.Errorf("invalid NGINX template, unexpected TLS fingerprints without SSL passthrough")
+	}
+
+	// the locations allowing some fingerprints deny the requests without fingerprint
+	dat.Servers[1].Locations[0].TLSFingerprint.Allowed = []string{"t13d1516h2_8daaf6152771_e5627efa2ab1"}
+	rt, err = ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	if strings.Count(string(rt), "tls_fingerprint.rewrite(") != 1 {
+		t.Errorf("invalid NGINX template, expected the allowed TLS fingerprints without SSL passthrough")
+	}
+
+	dat.IsSSLPassthroughEnabled = true
+	rt, err = ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	if !strings.Contains(string(rt), "lua_shared_dict tls_fingerprints 10M;") {
+		t.Errorf("invalid NGINX template, expected the shared dictionary of the TLS fingerprints")
+	}
+
+	if !strings.Contains(string(rt), `set $tls_ja4        "";`) || !strings.Contains(string(rt), "tls_fingerprint.rewrite({") {
+		t.Errorf("invalid NGINX template, expected the TLS fingerprints in the locations")
+	}
+}
+
+func TestTemplateWithTimeoutBudget(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	for _, server := range dat.Servers {
+		for _, location := range server.Locations {
+			location.Proxy.TimeoutBudget = 30
+			location.BackendProtocol = "GRPC"
+		}
+	}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	if !strings.Contains(string(rt), "return request_budget.deadline(30)") || !strings.Contains(string(rt), "grpc_set_header X-Request-Deadline     $request_deadline;") {
+		t.Errorf("invalid NGINX template, expected the deadline of the timeout budget")
+	}
+
+	if !strings.Contains(string(rt), "grpc_set_header grpc-timeout $request_grpc_timeout;") {
+		t.Errorf("invalid NGINX template, expected the grpc-timeout of the timeout budget")
+	}
+}
+
+func TestTemplateWithBodyStreaming(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	for _, server := range dat.Servers {
+		for _, location := range server.Locations {
+			location.Proxy.BodySize = "1m"
+			location.Proxy.RequestBuffering = "on"
+			location.BodyStreaming = bodystreaming.Config{Enabled: true, MaxSize: "10g", RequireContentLength: true}
+		}
+	}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	if !strings.Contains(string(rt), "client_max_body_size                    10g;") || strings.Contains(string(rt), "client_max_body_size                    1m;") {
+		t.Errorf("invalid NGINX template, expected the maximum size of the streamed bodies")
+	}
+
+	if !strings.Contains(string(rt), "proxy_request_buffering                 off;") {
+		t.Errorf("invalid NGINX template, expected the request buffering to be disabled")
+	}
+
+	if !strings.Contains(string(rt), `if ($http_transfer_encoding != "") {`) {
+		t.Errorf("invalid NGINX template, expected the bodies with a transfer coding to be rejected")
+	}
+}
+
+func TestTemplateWithAuthRequestBody(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	for _, server := range dat.Servers {
+		for _, location := range server.Locations {
+			location.Proxy.BodySize = "1m"
+			location.ExternalAuth = authreq.Config{URL: "http://auth.example.com", Host: "auth.example.com", MaxBodySize: "8k"}
+		}
+	}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	if !strings.Contains(string(rt), "proxy_pass_request_body     on;") {
+		t.Errorf("invalid NGINX template, expected the body to be forwarded to the authentication service")
+	}
+
+	if !strings.Contains(string(rt), "ngx.req.read_body()") {
+		t.Errorf("invalid NGINX template, expected the body to be read before the authentication request")
+	}
+
+	if !strings.Contains(string(rt), "client_max_body_size                    8k;") || strings.Contains(string(rt), "client_max_body_size                    1m;") {
+		t.Errorf("invalid NGINX template, expected the body size of the locations to be capped")
+	}
+}
+
+func TestTemplateWithConnectionLimits(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	dat.Cfg.MaxConnections = 10000
+	dat.Cfg.ConnectionLimitStatusCode = 429
+	dat.Cfg.ConnectionLimitRetryAfter = 5
+	for _, server := range dat.Servers {
+		server.MaxConnections = 100
+	}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	for _, expected := range []string{
+		"limit_conn_zone $server_name zone=host_connections:10m;",
+		`map "$status:$upstream_status:$connection_limit_checked" $connection_limit_retry_after { "429::1" "5"; default $upstream_http_retry_after; }`,
+		"limit_conn host_connections 100;",
+		"limit_conn global_connections 10000;",
+		"limit_conn_status 429;",
+		`set $connection_limit_checked "";`,
+		`ngx.var.connection_limit_checked = "1"`,
+		`more_set_headers -s 429 "Retry-After: $connection_limit_retry_after";`,
+	} {
+		if !strings.Contains(string(rt), expected) {
+			t.Errorf("invalid NGINX template, expected %v", expected)
+		}
+	}
+}
+
+func TestTemplateWithClientCertificateAuth(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	if len(dat.Servers) < 2 {
+		t.Fatalf("expected at least two servers")
+	}
+
+	for _, server := range dat.Servers {
+		server.SSLCert.PemFileName = "/etc/ingress-controller/ssl/default-tls.pem"
+	}
+	dat.Servers[0].CertificateAuth = authtls.Config{
+		AuthSSLCert:         resolver.AuthSSLCert{CAFileName: "/etc/ingress-controller/ssl/ca-default-migration.pem"},
+		VerifyClient:        "optional_no_ca",
+		ValidationDepth:     3,
+		ErrorPage:           "https://login.example.com/certificate",
+		ForwardedClientCert: []string{"Hash", "URI"},
+	}
+	dat.Servers[1].CertificateAuth = authtls.Config{
+		AuthSSLCert:     resolver.AuthSSLCert{CAFileName: "/etc/ingress-controller/ssl/ca-default-strict.pem"},
+		VerifyClient:    "on",
+		ValidationDepth: 1,
+	}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	// the directives of each server block follow its client certificate
+	conf := regexp.MustCompile(`\s+`).ReplaceAllString(string(rt), " ")
+	for _, expected := range []string{
+		"ssl_client_certificate /etc/ingress-controller/ssl/ca-default-migration.pem; ssl_verify_client optional_no_ca; ssl_verify_depth 3; error_page 495 496 = https://login.example.com/certificate;",
+		"ssl_client_certificate /etc/ingress-controller/ssl/ca-default-strict.pem; ssl_verify_client on; ssl_verify_depth 1;",
+		`set_by_lua_block $forwarded_client_cert { return forwarded_client_cert.header({ "Hash", "URI" }) }`,
+	} {
+		if !strings.Contains(conf, expected) {
+			t.Errorf("invalid NGINX template, expected %v", expected)
+		}
+	}
+
+	if strings.Count(conf, "error_page 495 496") != 1 {
+		t.Errorf("expected the error page only in the server with an error page")
+	}
+
+	if strings.Count(conf, "X-Forwarded-Client-Cert") != len(dat.Servers[0].Locations) {
+		t.Errorf("expected the X-Forwarded-Client-Cert header only in the locations of the server with the header")
+	}
+}
+
+func TestTemplateWithCertificateAuthExcluded(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+	if len(dat.Servers) < 2 || len(dat.Servers[1].Locations) < 2 {
+		t.Fatalf("expected a second server with at least two locations")
+	}
+
+	server := dat.Servers[1]
+	server.SSLCert.PemFileName = "/etc/ingress-controller/ssl/default-tls.pem"
+	server.CertificateAuth = authtls.Config{
+		AuthSSLCert:     resolver.AuthSSLCert{CAFileName: "/etc/ingress-controller/ssl/ca-default-strict.pem"},
+		VerifyClient:    "on",
+		ValidationDepth: 1,
+	}
+	server.Locations[1].CertificateAuthExcluded = true
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	// the certificate is requested by the server and verified by the
+	// locations which are not excluded from the authentication
+	conf := regexp.MustCompile(`\s+`).ReplaceAllString(string(rt), " ")
+	expected := "ssl_client_certificate /etc/ingress-controller/ssl/ca-default-strict.pem; ssl_verify_client optional; ssl_verify_depth 1;"
+	if !strings.Contains(conf, expected) {
+		t.Errorf("invalid NGINX template, expected %v", expected)
+	}
+
+	checks := strings.Count(conf, `if ngx.var.ssl_client_verify ~= "SUCCESS" then`)
+	if checks != len(server.Locations)-1 {
+		t.Errorf("expected %v client certificate checks but found %v", len(server.Locations)-1, checks)
+	}
+}
+
+func TestTemplateWithServerListeners(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	dat.ListenPorts = &config.ListenPorts{HTTP: 80, HTTPS: 443, AdditionalHTTPS: []int{8443}}
+	dat.IsIPV6Enabled = false
+	dat.Cfg.UseHTTP2 = false
+	dat.Cfg.BindAddressIpv4 = []string{}
+
+	for _, server := range dat.Servers {
+		server.SSLCert.PemFileName = "/etc/ingress-controller/ssl/default-tls.pem"
+	}
+	dat.Servers[1].Listeners = []ingress.ServerListener{{
+		Port: 8443,
+		CertificateAuth: authtls.Config{
+			AuthSSLCert:     resolver.AuthSSLCert{CAFileName: "/etc/ingress-controller/ssl/ca-default-partners.pem"},
+			VerifyClient:    "on",
+			ValidationDepth: 1,
+		},
+	}}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := regexp.MustCompile(`\s+`).ReplaceAllString(string(rt), " ")
+
+	// the server block of the listener only listens on its port and
+	// requires the client certificates, the public server block does not
+	listener := regexp.MustCompile(`## start server bar.baz.com port 8443 server \{ server_name bar.baz.com\s*;.*? listen 8443 ssl; # PEM sha`)
+	if !listener.MatchString(conf) {
+		t.Errorf("invalid NGINX template, expected a server block listening on 8443 for bar.baz.com")
+	}
+	if strings.Count(conf, "ssl_client_certificate /etc/ingress-controller/ssl/ca-default-partners.pem;") != 1 {
+		t.Errorf("expected the client certificates only in the server block of the listener")
+	}
+	if !strings.Contains(conf, "listen 8443 default_server backlog=32768 ssl;") {
+		t.Errorf("expected the catch-all server to be the default server of the additional port")
+	}
+}
+
+func TestTemplateWithPlainHTTP(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
 	}
+	dat.ListenPorts = &config.ListenPorts{HTTP: 80, HTTPS: 443}
 
-	authURL := "foo.com/auth"
-	globalAuthURL := "foo.com/global-auth"
+	for _, server := range dat.Servers {
+		server.SSLCert.PemFileName = "/etc/ingress-controller/ssl/default-tls.pem"
+	}
+	dat.Servers[0].PlainHTTP = plainhttp.Config{Action: plainhttp.ActionPage}
+	dat.Servers[1].PlainHTTP = plainhttp.Config{Action: plainhttp.ActionReject}
 
-	loc := &ingress.Location{
-		ExternalAuth: authreq.Config{
-			URL: authURL,
-		},
-		Path:             "/cat",
-		EnableGlobalAuth: true,
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
 
-	encodedAuthURL := strings.Replace(base64.URLEncoding.EncodeToString([]byte(loc.Path)), "=", "", -1)
-	externalAuthPath := fmt.Sprintf("/_external-auth-%v", encodedAuthURL)
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
 
-	testCases := []struct {
-		title                    string
-		authURL                  string
-		globalAuthURL            string
-		enableglobalExternalAuth bool
-		expected                 string
-	}{
-		{"authURL, globalAuthURL and enabled", authURL, globalAuthURL, true, externalAuthPath},
-		{"authURL, globalAuthURL and disabled", authURL, globalAuthURL, false, externalAuthPath},
-		{"authURL, empty globalAuthURL and enabled", authURL, "", true, externalAuthPath},
-		{"authURL, empty globalAuthURL and disabled", authURL, "", false, externalAuthPath},
-		{"globalAuthURL and enabled", "", globalAuthURL, true, externalAuthPath},
-		{"globalAuthURL and disabled", "", globalAuthURL, false, ""},
-		{"all empty and enabled", "", "", true, ""},
-		{"all empty and disabled", "", "", false, ""},
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
 	}
 
-	for _, testCase := range testCases {
-		loc.ExternalAuth.URL = testCase.authURL
-		loc.EnableGlobalAuth = testCase.enableglobalExternalAuth
+	conf := regexp.MustCompile(`\s+`).ReplaceAllString(string(rt), " ")
 
-		str := buildAuthLocation(loc, testCase.globalAuthURL)
-		if str != testCase.expected {
-			t.Errorf("%v: expected '%v' but returned '%v'", testCase.title, testCase.expected, str)
-		}
+	upstream := plainHTTPPageUpstream(dat.Servers[0])
+	if !strings.Contains(conf, fmt.Sprintf("error_page 497 = @custom_%v_497;", upstream)) ||
+		!strings.Contains(conf, fmt.Sprintf("location @custom_%v_497 {", upstream)) {
+		t.Errorf("expected the plain HTTP requests of server %q to be served the page of the default backend", dat.Servers[0].Hostname)
+	}
+	if !strings.Contains(conf, "error_page 497 = @plain_http_reject; location @plain_http_reject { internal; return 444; }") {
+		t.Errorf("expected the plain HTTP requests of server %q to be rejected", dat.Servers[1].Hostname)
+	}
+	if strings.Count(conf, "error_page 497 ") != 2 {
+		t.Errorf("expected only the servers configuring the plain HTTP requests to define an error page")
 	}
 }
 
-func TestShouldApplyGlobalAuth(t *testing.T) {
-
-	authURL := "foo.com/auth"
-	globalAuthURL := "foo.com/global-auth"
-
-	loc := &ingress.Location{
-		ExternalAuth: authreq.Config{
-			URL: authURL,
-		},
-		Path:             "/cat",
-		EnableGlobalAuth: true,
+func TestTemplateWithOCSPStapling(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
 	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	dat.ListenPorts = &config.ListenPorts{HTTP: 80, HTTPS: 443}
 
-	testCases := []struct {
-		title                    string
-		authURL                  string
-		globalAuthURL            string
-		enableglobalExternalAuth bool
-		expected                 bool
-	}{
-		{"authURL, globalAuthURL and enabled", authURL, globalAuthURL, true, false},
-		{"authURL, globalAuthURL and disabled", authURL, globalAuthURL, false, false},
-		{"authURL, empty globalAuthURL and enabled", authURL, "", true, false},
-		{"authURL, empty globalAuthURL and disabled", authURL, "", false, false},
-		{"globalAuthURL and enabled", "", globalAuthURL, true, true},
-		{"globalAuthURL and disabled", "", globalAuthURL, false, false},
-		{"all empty and enabled", "", "", true, false},
-		{"all empty and disabled", "", "", false, false},
+	for _, server := range dat.Servers {
+		server.SSLCert.PemFileName = "/etc/ingress-controller/ssl/default-tls.pem"
 	}
+	dat.Servers[1].SSLCert.OCSPResponseFile = "/etc/ingress-controller/ssl/default-tls.ocsp"
+	dat.Servers[1].SSLCert.OCSPResponseSHA = "0123456789abcdef"
 
-	for _, testCase := range testCases {
-		loc.ExternalAuth.URL = testCase.authURL
-		loc.EnableGlobalAuth = testCase.enableglobalExternalAuth
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
-		result := shouldApplyGlobalAuth(loc, testCase.globalAuthURL)
-		if result != testCase.expected {
-			t.Errorf("%v: expected '%v' but returned '%v'", testCase.title, testCase.expected, result)
-		}
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
 	}
-}
 
-func TestBuildAuthResponseHeaders(t *testing.T) {
-	externalAuthResponseHeaders := []string{"h1", "H-With-Caps-And-Dashes"}
-	expected := []string{
-		"auth_request_set $authHeader0 $upstream_http_h1;",
-		"proxy_set_header 'h1' $authHeader0;",
-		"auth_request_set $authHeader1 $upstream_http_h_with_caps_and_dashes;",
-		"proxy_set_header 'H-With-Caps-And-Dashes' $authHeader1;",
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
 	}
 
-	headers := buildAuthResponseHeaders(externalAuthResponseHeaders)
+	conf := regexp.MustCompile(`\s+`).ReplaceAllString(string(rt), " ")
 
-	if !reflect.DeepEqual(expected, headers) {
-		t.Errorf("Expected \n'%v'\nbut returned \n'%v'", expected, headers)
+	if !strings.Contains(conf, "# OCSP sha: 0123456789abcdef ssl_stapling on; ssl_stapling_file /etc/ingress-controller/ssl/default-tls.ocsp;") {
+		t.Errorf("expected the OCSP response of server %q to be stapled", dat.Servers[1].Hostname)
+	}
+	if strings.Count(conf, "ssl_stapling on;") != 1 {
+		t.Errorf("expected only the servers with an OCSP response to enable stapling")
 	}
 }
 
-func TestTemplateWithData(t *testing.T) {
+func TestTemplateWithInternalServers(t *testing.T) {
 	pwd, _ := os.Getwd()
-	f, err := os.Open(path.Join(pwd, "../../../../test/data/config.json"))
-	if err != nil {
-		t.Errorf("unexpected error reading json file: %v", err)
-	}
-	defer f.Close()
-	data, err := ioutil.ReadFile(f.Name())
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
 	if err != nil {
-		t.Error("unexpected error reading json file: ", err)
+		t.Fatalf("unexpected error reading json file: %v", err)
 	}
 	var dat config.TemplateConfig
 	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
-		t.Errorf("unexpected error unmarshalling json: %v", err)
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
 	}
-	if dat.ListenPorts == nil {
-		dat.ListenPorts = &config.ListenPorts{}
+	dat.ListenPorts = &config.ListenPorts{HTTP: 80, HTTPS: 443, InternalHTTP: 8080, InternalHTTPS: 8444}
+	dat.IsIPV6Enabled = false
+	dat.Cfg.UseHTTP2 = false
+	dat.Cfg.BindAddressIpv4 = []string{}
+
+	for _, server := range dat.Servers {
+		server.SSLCert.PemFileName = "/etc/ingress-controller/ssl/default-tls.pem"
 	}
+	dat.Servers[1].Internal = true
 
 	fs, err := file.NewFakeFS()
 	if err != nil {
@@ -415,24 +2092,172 @@ func TestTemplateWithData(t *testing.T) {
 
 	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
 	if err != nil {
-		t.Errorf("invalid NGINX template: %v", err)
+		t.Fatalf("invalid NGINX template: %v", err)
 	}
 
 	rt, err := ngxTpl.Write(dat)
 	if err != nil {
-		t.Errorf("invalid NGINX template: %v", err)
+		t.Fatalf("invalid NGINX template: %v", err)
 	}
 
-	if !strings.Contains(string(rt), "listen [2001:db8:a0b:12f0::1]") {
-		t.Errorf("invalid NGINX template, expected IPV6 listen address not present")
+	conf := regexp.MustCompile(`\s+`).ReplaceAllString(string(rt), " ")
+
+	// the server block of the internal host only listens on the internal ports
+	internal := regexp.MustCompile(`## start server bar.baz.com server \{ server_name bar.baz.com\s*; listen 8080; listen 8444 ssl; set \$proxy_upstream_name "-";`)
+	if !internal.MatchString(conf) {
+		t.Errorf("invalid NGINX template, expected the server block of bar.baz.com to listen on the internal ports")
+	}
+	if regexp.MustCompile(`## start server bar.baz.com server \{ server_name bar.baz.com\s*;[^#]*? listen (80|443)[ ;]`).MatchString(conf) {
+		t.Errorf("invalid NGINX template, expected the server block of bar.baz.com not to listen on the public ports")
 	}
+	if !strings.Contains(conf, "listen 8080 default_server backlog=32768;") ||
+		!strings.Contains(conf, "listen 8444 default_server backlog=32768 ssl;") {
+		t.Errorf("expected the catch-all server to be the default server of the internal ports")
+	}
+}
 
-	if !strings.Contains(string(rt), "listen [3731:54:65fe:2::a7]") {
-		t.Errorf("invalid NGINX template, expected IPV6 listen address not present")
+func TestBuildAdditionalHTTPSListen(t *testing.T) {
+	all := config.TemplateConfig{
+		ListenPorts: &config.ListenPorts{AdditionalHTTPS: []int{8443, 9443}},
+		BacklogSize: 511,
+		Cfg: config.Configuration{
+			UseHTTP2:        true,
+			BindAddressIpv4: []string{"10.0.0.1"},
+		},
+		IsIPV6Enabled: true,
 	}
 
-	if !strings.Contains(string(rt), "listen 2.2.2.2") {
-		t.Errorf("invalid NGINX template, expected IPV4 listen address not present")
+	testCases := []struct {
+		server   *ingress.Server
+		expected []string
+	}{
+		{&ingress.Server{Hostname: "example.com"}, []string{}},
+		{&ingress.Server{Hostname: "example.com", ListenPort: 8443}, []string{
+			"listen 10.0.0.1:8443 ssl http2;",
+			"listen [::]:8443 ssl http2;",
+		}},
+		{&ingress.Server{Hostname: "_"}, []string{
+			"listen 10.0.0.1:8443 default_server backlog=511 ssl http2;",
+			"listen [::]:8443 default_server backlog=511 ssl http2;",
+			"listen 10.0.0.1:9443 default_server backlog=511 ssl http2;",
+			"listen [::]:9443 default_server backlog=511 ssl http2;",
+		}},
+	}
+
+	for _, tc := range testCases {
+		actual := buildAdditionalHTTPSListen(all, tc.server)
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%v:%v: expected %v but returned %v", tc.server.Hostname, tc.server.ListenPort, tc.expected, actual)
+		}
+	}
+}
+
+func TestBuildInternalListen(t *testing.T) {
+	all := config.TemplateConfig{
+		ListenPorts: &config.ListenPorts{InternalHTTP: 8080, InternalHTTPS: 8444},
+		BacklogSize: 511,
+		Cfg: config.Configuration{
+			UseHTTP2:        true,
+			BindAddressIpv4: []string{"10.0.0.1"},
+		},
+		IsIPV6Enabled: true,
+	}
+
+	testCases := []struct {
+		server   *ingress.Server
+		expected []string
+	}{
+		{&ingress.Server{Hostname: "example.com"}, []string{}},
+		{&ingress.Server{Hostname: "example.com", Internal: true, ListenPort: 8443}, []string{}},
+		{&ingress.Server{Hostname: "example.com", Internal: true}, []string{
+			"listen 10.0.0.1:8080;",
+			"listen [::]:8080;",
+		}},
+		{&ingress.Server{Hostname: "example.com", Internal: true, SSLCert: ingress.SSLCert{PemFileName: "/ssl/example.pem"}}, []string{
+			"listen 10.0.0.1:8080;",
+			"listen [::]:8080;",
+			"listen 10.0.0.1:8444 ssl http2;",
+			"listen [::]:8444 ssl http2;",
+		}},
+		{&ingress.Server{Hostname: "_"}, []string{
+			"listen 10.0.0.1:8080 default_server backlog=511;",
+			"listen [::]:8080 default_server backlog=511;",
+			"listen 10.0.0.1:8444 default_server backlog=511 ssl http2;",
+			"listen [::]:8444 default_server backlog=511 ssl http2;",
+		}},
+	}
+
+	for _, tc := range testCases {
+		actual := buildInternalListen(all, tc.server)
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("%v:%v: expected %v but returned %v", tc.server.Hostname, tc.server.Internal, tc.expected, actual)
+		}
+	}
+
+	all.ListenPorts = &config.ListenPorts{InternalHTTPS: 8444}
+	expected := []string{
+		"listen 10.0.0.1:8444 ssl http2;",
+		"listen [::]:8444 ssl http2;",
+	}
+	actual := buildInternalRedirectListen(all)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v but returned %v", expected, actual)
+	}
+}
+
+func TestBuildListenerServers(t *testing.T) {
+	auth := authtls.Config{AuthSSLCert: resolver.AuthSSLCert{CAFileName: "/etc/ingress-controller/ssl/ca.pem"}, Port: 8443}
+	servers := []*ingress.Server{
+		{Hostname: "public.example.com", SSLCert: ingress.SSLCert{PemFileName: "/ssl/public.pem"}},
+		{
+			Hostname:  "example.com",
+			SSLCert:   ingress.SSLCert{PemFileName: "/ssl/example.pem"},
+			Listeners: []ingress.ServerListener{{Port: 8443, CertificateAuth: auth}},
+		},
+		{Hostname: "plain.example.com", Listeners: []ingress.ServerListener{{Port: 8443, CertificateAuth: auth}}},
+	}
+
+	result := buildListenerServers(servers)
+	if len(result) != 4 {
+		t.Fatalf("expected 4 servers but returned %v", len(result))
+	}
+
+	ls := result[3]
+	if ls.Hostname != "example.com" || ls.ListenPort != 8443 || len(ls.Listeners) != 0 {
+		t.Errorf("unexpected server of the listener: %+v", ls)
+	}
+	if ls.CertificateAuth.CAFileName != auth.CAFileName {
+		t.Errorf("expected the client certificate authentication of the listener but returned %+v", ls.CertificateAuth)
+	}
+	if servers[1].CertificateAuth.CAFileName != "" {
+		t.Errorf("expected the server to be unchanged")
+	}
+}
+
+func TestForwardedCertConfigForLua(t *testing.T) {
+	server := &ingress.Server{
+		CertificateAuth: authtls.Config{
+			AuthSSLCert:         resolver.AuthSSLCert{CAFileName: "/etc/ingress-controller/ssl/ca.pem"},
+			ForwardedClientCert: []string{"Hash", "Subject", "URI"},
+		},
+	}
+
+	expected := `{ "Hash", "Subject", "URI" }`
+	if actual := forwardedCertConfigForLua(server); actual != expected {
+		t.Errorf("expected '%v' but returned '%v'", expected, actual)
+	}
+
+	server.AuthTLSError = "secret not found"
+	if actual := forwardedCertConfigForLua(server); actual != "" {
+		t.Errorf("expected an empty string with an invalid client certificate but returned '%v'", actual)
+	}
+
+	if actual := forwardedCertConfigForLua(&ingress.Server{}); actual != "" {
+		t.Errorf("expected an empty string without client certificate but returned '%v'", actual)
+	}
+
+	if actual := forwardedCertConfigForLua(&ingress.Location{}); actual != "" {
+		t.Errorf("expected an empty string with an invalid server but returned '%v'", actual)
 	}
 }
 
@@ -513,6 +2338,90 @@ func TestBuildByteSize(t *testing.T) {
 	}
 }
 
+func TestProxyBuffersSize(t *testing.T) {
+	cases := []struct {
+		value    interface{}
+		expected string
+	}{
+		{proxy.Config{ProxyBuffering: "on", BufferSize: "4k", BuffersNumber: 8}, "36864"},
+		{proxy.Config{ProxyBuffering: "on", BufferSize: " 1m", BuffersNumber: 1}, "2097152"},
+		{proxy.Config{ProxyBuffering: "on", BufferSize: "512", BuffersNumber: 3}, "2048"},
+		{proxy.Config{ProxyBuffering: "off", BufferSize: "4k", BuffersNumber: 8}, ""},
+		{proxy.Config{ProxyBuffering: "on", BufferSize: "4kb", BuffersNumber: 8}, ""},
+		{&proxy.Config{ProxyBuffering: "on", BufferSize: "4k", BuffersNumber: 8}, ""},
+	}
+
+	for _, tc := range cases {
+		if actual := proxyBuffersSize(tc.value); actual != tc.expected {
+			t.Errorf("Expected '%v' but returned '%v' for %+v", tc.expected, actual, tc.value)
+		}
+	}
+}
+
+func TestBuildConnectionLimitZones(t *testing.T) {
+	cfg := config.Configuration{ConnectionLimitStatusCode: 503, ConnectionLimitRetryAfter: 2}
+
+	zones := buildConnectionLimitZones([]*ingress.Server{{Hostname: "foo.bar"}}, cfg)
+	if len(zones) != 0 {
+		t.Errorf("Expected no zones but returned %v", zones)
+	}
+
+	zones = buildConnectionLimitZones([]*ingress.Server{{Hostname: "foo.bar", MaxConnections: 10}}, cfg)
+	if len(zones) != 4 {
+		t.Fatalf("Expected 4 zones but returned %v", zones)
+	}
+	expected := `map "$status:$upstream_status:$connection_limit_checked" $connection_limit_retry_after { "503::1" "2"; default $upstream_http_retry_after; }`
+	if zones[3] != expected {
+		t.Errorf("Expected '%v' but returned '%v'", expected, zones[3])
+	}
+
+	cfg.ConnectionLimitStatusCode = 0
+	cfg.LimitConnStatusCode = 429
+	zones = buildConnectionLimitZones([]*ingress.Server{{Hostname: "foo.bar", MaxConnections: 10}}, cfg)
+	expected = `map "$status:$upstream_status:$connection_limit_checked" $connection_limit_retry_after { "429::1" "2"; default $upstream_http_retry_after; }`
+	if len(zones) != 4 || zones[3] != expected {
+		t.Errorf("Expected '%v' but returned '%v'", expected, zones)
+	}
+
+	cfg.MaxConnectionsPerHost = 10
+	if zones := buildConnectionLimitZones([]*ingress.Server{}, cfg); len(zones) != 4 {
+		t.Errorf("Expected 4 zones but returned %v", zones)
+	}
+
+	if zones := buildConnectionLimitZones(&ingress.Server{}, cfg); len(zones) != 0 {
+		t.Errorf("Expected no zones for an invalid type but returned %v", zones)
+	}
+}
+
+func TestBuildConnectionLimits(t *testing.T) {
+	checked := `set $connection_limit_checked "";`
+	retryAfter := `more_set_headers -s 429 "Retry-After: $connection_limit_retry_after";`
+
+	cases := []struct {
+		server   interface{}
+		cfg      config.Configuration
+		expected []string
+	}{
+		{&ingress.Server{}, config.Configuration{ConnectionLimitStatusCode: 429}, []string{}},
+		{&ingress.Server{}, config.Configuration{MaxConnectionsPerHost: 10, ConnectionLimitStatusCode: 429},
+			[]string{"limit_conn host_connections 10;", "limit_conn_status 429;", checked, retryAfter}},
+		{&ingress.Server{MaxConnections: 5}, config.Configuration{MaxConnectionsPerHost: 10, MaxConnections: 100, ConnectionLimitStatusCode: 429},
+			[]string{"limit_conn host_connections 5;", "limit_conn global_connections 100;", "limit_conn_status 429;", checked, retryAfter}},
+		{&ingress.Server{}, config.Configuration{MaxConnections: 100, ConnectionLimitStatusCode: 429},
+			[]string{"limit_conn global_connections 100;", "limit_conn_status 429;", checked, retryAfter}},
+		{&ingress.Server{}, config.Configuration{MaxConnections: 100, LimitConnStatusCode: 429},
+			[]string{"limit_conn global_connections 100;", checked, retryAfter}},
+		{ingress.Server{MaxConnections: 5}, config.Configuration{ConnectionLimitStatusCode: 429}, []string{}},
+	}
+
+	for _, tc := range cases {
+		actual := buildConnectionLimits(tc.server, tc.cfg)
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("Expected '%v' but returned '%v' for %+v", tc.expected, actual, tc.server)
+		}
+	}
+}
+
 func TestIsLocationAllowed(t *testing.T) {
 	invalidType := &ingress.Ingress{}
 	expected := false
@@ -1076,6 +2985,24 @@ func TestBuildCustomErrorLocationsPerServer(t *testing.T) {
 				},
 			},
 		},
+		{ // Page of the plain HTTP requests sent to the HTTPS port
+			&ingress.Server{
+				PlainHTTP: plainhttp.Config{Action: plainhttp.ActionPage},
+				Locations: []*ingress.Location{
+					{
+						Path:                       "/",
+						DefaultBackendUpstreamName: "custom-default-backend-test-backend",
+						CustomHTTPErrors:           []int{503},
+					},
+				},
+			},
+			[]errorLocation{
+				{
+					UpstreamName: "custom-default-backend-test-backend",
+					Codes:        []int{497, 503},
+				},
+			},
+		},
 	}
 
 	for _, c := range testCases {
@@ -1265,4 +3192,77 @@ func TestShouldLoadModSecurityModule(t *testing.T) {
 	if expected != actual {
 		t.Errorf("Expected '%v' but returned '%v'", expected, actual)
 	}
+
+	// The module is not loaded when Coraza applies the annotations.
+	configuration.WAFEngine = "coraza"
+	configuration.CorazaURL = "http://coraza.waf:8080/"
+	actual = shouldLoadModSecurityModule(configuration, servers)
+	if actual {
+		t.Errorf("Expected 'false' with the Coraza WAF engine but returned '%v'", actual)
+	}
+}
+
+func TestTemplateWithAuthPrefixHeadersAndSatisfy(t *testing.T) {
+	pwd, _ := os.Getwd()
+	data, err := ioutil.ReadFile(path.Join(pwd, "../../../../test/data/config.json"))
+	if err != nil {
+		t.Fatalf("unexpected error reading json file: %v", err)
+	}
+	var dat config.TemplateConfig
+	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, &dat); err != nil {
+		t.Fatalf("unexpected error unmarshalling json: %v", err)
+	}
+	if dat.ListenPorts == nil {
+		dat.ListenPorts = &config.ListenPorts{}
+	}
+
+	location := &ingress.Location{
+		Path:      "/",
+		Backend:   "default-http-svc-80",
+		Satisfy:   "any",
+		Service:   &apiv1.Service{ObjectMeta: metav1.ObjectMeta{Name: "http-svc", Namespace: "default"}},
+		Port:      intstr.FromInt(80),
+		Proxy:     proxy.Config{ConnectTimeout: 5, SendTimeout: 60, ReadTimeout: 60, BufferSize: "4k", BuffersNumber: 4, RequestBuffering: "on", NextUpstream: "error timeout", NextUpstreamTries: 3},
+		Whitelist: ipwhitelist.SourceRange{CIDR: []string{"10.0.0.0/24"}},
+		ExternalAuth: authreq.Config{
+			URL:             "http://auth.default.svc.cluster.local/verify",
+			Host:            "auth.default.svc.cluster.local",
+			Method:          "GET",
+			ResponseHeaders: []string{"X-Auth-*"},
+		},
+	}
+	dat.Servers = []*ingress.Server{{
+		Hostname:  "auth.example.com",
+		Locations: []*ingress.Location{location},
+	}}
+
+	fs, err := file.NewFakeFS()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ngxTpl, err := NewTemplate("/etc/nginx/template/nginx.tmpl", fs)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	rt, err := ngxTpl.Write(dat)
+	if err != nil {
+		t.Fatalf("invalid NGINX template: %v", err)
+	}
+
+	conf := string(rt)
+	for _, e := range []string{"satisfy any;", "allow 10.0.0.0/24;", "auth_request ", "auth_headers.set_request_headers()"} {
+		if !strings.Contains(conf, e) {
+			t.Errorf("invalid NGINX template, expected %q not present", e)
+		}
+	}
+
+	// reaching the end of the access phase handler would allow the requests
+	// without checking the whitelist and the external authentication
+	block := conf[strings.Index(conf, "access_by_lua_block {"):]
+	block = block[:strings.Index(block, "header_filter_by_lua_block")]
+	if !strings.Contains(block, "return ngx.exit(ngx.DECLINED)") || strings.Contains(block, "ngx.exit(ngx.OK)") {
+		t.Errorf("invalid NGINX template, expected the access phase handler to decline:\n%v", block)
+	}
 }
@@ -0,0 +1,187 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"k8s.io/ingress-nginx/internal/logging"
+	"k8s.io/ingress-nginx/internal/net/tlsfingerprint"
+	"k8s.io/ingress-nginx/internal/nginx"
+)
+
+const (
+	// tlsFingerprintsInterval is the period of the connection counts
+	// exported as metrics
+	tlsFingerprintsInterval = time.Minute
+	// tlsFingerprintsTop is the number of fingerprints exported as metrics
+	tlsFingerprintsTop = 20
+	// maxTLSFingerprints limits the number of fingerprints counted during
+	// an interval, the clients can send arbitrary Client Hello messages
+	maxTLSFingerprints = 10000
+	// tlsFingerprintsQueueSize is the number of fingerprints waiting to be
+	// sent to NGINX, the fingerprints of new connections are dropped when
+	// the queue is full
+	tlsFingerprintsQueueSize = 4096
+	// tlsFingerprintsBatchSize is the maximum number of fingerprints sent
+	// to NGINX in a request
+	tlsFingerprintsBatchSize = 256
+)
+
+// tlsFingerprintData is sent to NGINX for each connection proxied by the SSL
+// passthrough proxy, while its Client Hello is forwarded. Connection contains
+// the local and remote addresses of the connection to NGINX. The fingerprints
+// are empty when the Client Hello is invalid, removing the fingerprint of a
+// previous connection using the same addresses.
+type tlsFingerprintData struct {
+	Connection string `json:"connection"`
+	JA3        string `json:"ja3"`
+	JA4        string `json:"ja4"`
+}
+
+// tlsFingerprintCounter counts the connections of each JA4 fingerprint
+type tlsFingerprintCounter struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+func (c *tlsFingerprintCounter) add(ja4 string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.counts == nil {
+		c.counts = map[string]int{}
+	}
+
+	if _, ok := c.counts[ja4]; !ok && len(c.counts) >= maxTLSFingerprints {
+		return
+	}
+
+	c.counts[ja4]++
+}
+
+// reset returns the counts since the last call
+func (c *tlsFingerprintCounter) reset() map[string]int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	counts := c.counts
+	c.counts = nil
+	return counts
+}
+
+// recordTLSFingerprint is called by the SSL passthrough proxy with the Client
+// Hello of the connections proxied to NGINX. The fingerprint is queued, the
+// Client Hello is forwarded without waiting for NGINX.
+func (n *NGINXController) recordTLSFingerprint(data []byte, conn net.Conn) {
+	if !n.store.GetBackendConfiguration().EnableTLSFingerprints {
+		return
+	}
+
+	fingerprint := tlsFingerprintData{
+		Connection: fmt.Sprintf("%v-%v", conn.LocalAddr(), conn.RemoteAddr()),
+	}
+
+	hello, err := tlsfingerprint.Parse(data)
+	if err != nil {
+		logging.V(4).Infof("Error parsing the TLS Client Hello of %v: %v", fingerprint.Connection, err)
+	} else {
+		fingerprint.JA3 = hello.JA3Hash()
+		fingerprint.JA4 = hello.JA4()
+		n.tlsFingerprints.add(fingerprint.JA4)
+	}
+
+	select {
+	case n.tlsFingerprintsQueue <- fingerprint:
+	default:
+		logging.V(2).Infof("Dropping the TLS fingerprint of %v, too many fingerprints are waiting to be sent to NGINX", fingerprint.Connection)
+	}
+}
+
+// sendTLSFingerprints sends the queued fingerprints to NGINX until the
+// channel is closed. The fingerprints queued while a request is sent are
+// sent together by the next request.
+func (n *NGINXController) sendTLSFingerprints(stopCh <-chan struct{}) {
+	for {
+		var batch []tlsFingerprintData
+
+		select {
+		case fingerprint := <-n.tlsFingerprintsQueue:
+			batch = append(batch, fingerprint)
+		case <-stopCh:
+			return
+		}
+
+	pending:
+		for len(batch) < tlsFingerprintsBatchSize {
+			select {
+			case fingerprint := <-n.tlsFingerprintsQueue:
+				batch = append(batch, fingerprint)
+			default:
+				break pending
+			}
+		}
+
+		statusCode, _, err := nginx.NewPostStatusRequest("/configuration/tls-fingerprints", "application/json", batch)
+		if err == nil && statusCode != http.StatusCreated {
+			err = fmt.Errorf("unexpected error code: %d", statusCode)
+		}
+		if err != nil {
+			logging.V(2).Infof("Error sending %v TLS fingerprints to NGINX: %v", len(batch), err)
+		}
+	}
+}
+
+// exportTLSFingerprints exports the connection counts of the most frequent
+// TLS fingerprints until the channel is closed
+func (n *NGINXController) exportTLSFingerprints(stopCh <-chan struct{}) {
+	wait.Until(func() {
+		n.metricCollector.SetTLSFingerprints(topTLSFingerprints(n.tlsFingerprints.reset(), tlsFingerprintsTop))
+	}, tlsFingerprintsInterval, stopCh)
+}
+
+// topTLSFingerprints returns the max fingerprints with the most connections
+func topTLSFingerprints(counts map[string]int, max int) map[string]int {
+	fingerprints := make([]string, 0, len(counts))
+	for fingerprint := range counts {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+
+	sort.Slice(fingerprints, func(i, j int) bool {
+		if counts[fingerprints[i]] != counts[fingerprints[j]] {
+			return counts[fingerprints[i]] > counts[fingerprints[j]]
+		}
+		return fingerprints[i] < fingerprints[j]
+	})
+
+	if len(fingerprints) > max {
+		fingerprints = fingerprints[:max]
+	}
+
+	top := make(map[string]int, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		top[fingerprint] = counts[fingerprint]
+	}
+	return top
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/nginx"
+)
+
+func TestRecordTLSFingerprint(t *testing.T) {
+	client, conn := net.Pipe()
+	defer conn.Close()
+
+	go func() {
+		tls.Client(client, &tls.Config{ServerName: "example.com"}).Handshake()
+		client.Close()
+	}()
+
+	hello := make([]byte, 4096)
+	length, err := conn.Read(hello)
+	if err != nil {
+		t.Fatalf("unexpected error reading the client hello: %v", err)
+	}
+
+	cfg := ngx_config.NewDefault()
+	n := &NGINXController{
+		store:                fakeIngressStore{configuration: cfg},
+		tlsFingerprints:      &tlsFingerprintCounter{},
+		tlsFingerprintsQueue: make(chan tlsFingerprintData, 1),
+	}
+
+	// disabled by default
+	n.recordTLSFingerprint(hello[:length], conn)
+	if len(n.tlsFingerprintsQueue) != 0 {
+		t.Fatalf("expected no fingerprint but %v were queued", len(n.tlsFingerprintsQueue))
+	}
+
+	cfg.EnableTLSFingerprints = true
+	n.store = fakeIngressStore{configuration: cfg}
+
+	n.recordTLSFingerprint(hello[:length], conn)
+	if len(n.tlsFingerprintsQueue) != 1 {
+		t.Fatalf("expected one fingerprint but %v were queued", len(n.tlsFingerprintsQueue))
+	}
+
+	// the queue is full, the connection is not blocked
+	n.recordTLSFingerprint(hello[:length], conn)
+
+	fingerprint := <-n.tlsFingerprintsQueue
+	connection := fmt.Sprintf("%v-%v", conn.LocalAddr(), conn.RemoteAddr())
+	if fingerprint.Connection != connection || len(fingerprint.JA3) != 32 || !strings.HasPrefix(fingerprint.JA4, "t13d") {
+		t.Errorf("unexpected TLS fingerprint %+v", fingerprint)
+	}
+
+	counts := n.tlsFingerprints.reset()
+	if counts[fingerprint.JA4] != 2 {
+		t.Errorf("expected two connections with the fingerprint %v but returned %v", fingerprint.JA4, counts)
+	}
+
+	// not a TLS connection, the fingerprint of a previous connection is removed
+	n.recordTLSFingerprint([]byte("GET / HTTP/1.1\r\n\r\n"), conn)
+	fingerprint = <-n.tlsFingerprintsQueue
+	if fingerprint.Connection != connection || fingerprint.JA3 != "" || fingerprint.JA4 != "" {
+		t.Errorf("expected an empty fingerprint for an invalid Client Hello but returned %+v", fingerprint)
+	}
+}
+
+func TestSendTLSFingerprints(t *testing.T) {
+	listener, err := net.Listen("unix", nginx.StatusSocket)
+	if err != nil {
+		t.Fatalf("crating unix listener: %s", err)
+	}
+	defer listener.Close()
+	defer os.Remove(nginx.StatusSocket)
+
+	requests := make(chan []tlsFingerprintData, 10)
+	server := &httptest.Server{
+		Listener: listener,
+		Config: &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/configuration/tls-fingerprints" {
+					t.Errorf("unknown request to %s", r.URL.Path)
+				}
+
+				b, _ := ioutil.ReadAll(r.Body)
+				var fingerprints []tlsFingerprintData
+				if err := json.Unmarshal(b, &fingerprints); err != nil {
+					t.Errorf("unexpected TLS fingerprints %s: %v", b, err)
+				}
+				requests <- fingerprints
+				w.WriteHeader(http.StatusCreated)
+			}),
+		},
+	}
+	defer server.Close()
+	server.Start()
+
+	n := &NGINXController{
+		tlsFingerprintsQueue: make(chan tlsFingerprintData, 10),
+	}
+
+	expected := []tlsFingerprintData{
+		{Connection: "127.0.0.1:54321-127.0.0.1:442", JA3: "e7d705a3286e19ea42f587b344ee6865", JA4: "t13d1516h2_8daaf6152771_e5627efa2ab1"},
+		{Connection: "127.0.0.1:54322-127.0.0.1:442"},
+	}
+	for _, fingerprint := range expected {
+		n.tlsFingerprintsQueue <- fingerprint
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go n.sendTLSFingerprints(stopCh)
+
+	// the queued fingerprints are sent by a single request
+	fingerprints := <-requests
+	if !reflect.DeepEqual(fingerprints, expected) {
+		t.Errorf("expected %+v but %+v were sent", expected, fingerprints)
+	}
+}
+
+func TestTLSFingerprintCounter(t *testing.T) {
+	c := tlsFingerprintCounter{}
+	for i := 0; i < maxTLSFingerprints+10; i++ {
+		c.add(strconv.Itoa(i))
+	}
+	c.add("0")
+
+	counts := c.reset()
+	if len(counts) != maxTLSFingerprints {
+		t.Errorf("expected %v fingerprints but returned %v", maxTLSFingerprints, len(counts))
+	}
+	if counts["0"] != 2 {
+		t.Errorf("expected two connections of a counted fingerprint but returned %v", counts["0"])
+	}
+
+	if counts := c.reset(); len(counts) != 0 {
+		t.Errorf("expected the counts to be reset but returned %v", counts)
+	}
+}
+
+func TestTopTLSFingerprints(t *testing.T) {
+	counts := map[string]int{"a": 1, "b": 5, "c": 3, "d": 3}
+
+	top := topTLSFingerprints(counts, 3)
+	expected := map[string]int{"b": 5, "c": 3, "d": 3}
+	if !reflect.DeepEqual(top, expected) {
+		t.Errorf("expected %v but returned %v", expected, top)
+	}
+
+	top = topTLSFingerprints(counts, 2)
+	expected = map[string]int{"b": 5, "c": 3}
+	if !reflect.DeepEqual(top, expected) {
+		t.Errorf("expected %v but returned %v", expected, top)
+	}
+
+	if top := topTLSFingerprints(nil, 2); len(top) != 0 {
+		t.Errorf("expected no fingerprint but returned %v", top)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/net/ssl"
+)
+
+// checkTLSSecrets validates the TLS Secrets referenced by the Ingress contain
+// a certificate and key, not expired at now and valid for the hosts of their
+// TLS section. Without a valid Secret, the hosts are served with the default
+// certificate.
+func checkTLSSecrets(ing *networking.Ingress, getSecret func(string) (*apiv1.Secret, error), now time.Time) error {
+	for _, tls := range ing.Spec.TLS {
+		// the hosts use the default certificate
+		if tls.SecretName == "" {
+			continue
+		}
+
+		key := fmt.Sprintf("%v/%v", ing.Namespace, tls.SecretName)
+		secret, err := getSecret(key)
+		if err != nil {
+			return fmt.Errorf("the TLS Secret %v does not exist", key)
+		}
+
+		sslCert, err := ssl.CreateTLSSecretCert(secret.Data)
+		if err != nil {
+			return fmt.Errorf("the TLS Secret %v is invalid: %v", key, err)
+		}
+
+		certs := []*ingress.SSLCert{sslCert}
+		if sslCert.ECDSA != nil {
+			certs = append(certs, sslCert.ECDSA)
+		}
+
+		for _, cert := range certs {
+			if now.After(cert.ExpireTime) {
+				return fmt.Errorf("the certificate of the TLS Secret %v expired on %v", key, cert.ExpireTime.UTC().Format(time.RFC3339))
+			}
+
+			for _, host := range tls.Hosts {
+				if !ssl.IsValidHostname(host, cert.CN) {
+					return fmt.Errorf("the certificate of the TLS Secret %v is not valid for the host %q (names: %v)", key, host, cert.CN)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTLSSecretData returns the content of a TLS Secret with a self signed
+// certificate valid for the hosts until notAfter
+func newTLSSecretData(t *testing.T, notAfter time.Time, hosts ...string) map[string][]byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error creating private key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		DNSNames:     hosts,
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate: %v", err)
+	}
+
+	return map[string][]byte{
+		apiv1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		apiv1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+	}
+}
+
+func TestCheckTLSSecrets(t *testing.T) {
+	now := time.Now()
+	valid := newTLSSecretData(t, now.Add(24*time.Hour), "app.example.com", "*.app.example.com")
+	expired := newTLSSecretData(t, now.Add(-time.Hour), "app.example.com")
+
+	secrets := map[string]*apiv1.Secret{
+		"example/valid":   {Data: valid},
+		"example/expired": {Data: expired},
+		"example/no-key":  {Data: map[string][]byte{apiv1.TLSCertKey: valid[apiv1.TLSCertKey]}},
+		"example/invalid": {Data: map[string][]byte{apiv1.TLSCertKey: []byte("invalid"), apiv1.TLSPrivateKeyKey: []byte("invalid")}},
+		"example/mismatch": {Data: map[string][]byte{
+			apiv1.TLSCertKey:       valid[apiv1.TLSCertKey],
+			apiv1.TLSPrivateKeyKey: expired[apiv1.TLSPrivateKeyKey],
+		}},
+	}
+	getSecret := func(key string) (*apiv1.Secret, error) {
+		if secret, ok := secrets[key]; ok {
+			return secret, nil
+		}
+		return nil, fmt.Errorf("secret %v not found", key)
+	}
+
+	newIngress := func(secretName string, hosts ...string) *networking.Ingress {
+		return &networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "example"},
+			Spec: networking.IngressSpec{
+				TLS: []networking.IngressTLS{{Hosts: hosts, SecretName: secretName}},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		ing       *networking.Ingress
+		expectErr bool
+	}{
+		{"valid secret", newIngress("valid", "app.example.com", "api.app.example.com"), false},
+		{"default certificate", newIngress("", "app.example.com"), false},
+		{"no TLS section", &networking.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "example"}}, false},
+		{"missing secret", newIngress("missing", "app.example.com"), true},
+		{"missing key", newIngress("no-key", "app.example.com"), true},
+		{"invalid certificate", newIngress("invalid", "app.example.com"), true},
+		{"key of another certificate", newIngress("mismatch", "app.example.com"), true},
+		{"expired certificate", newIngress("expired", "app.example.com"), true},
+		{"host not covered", newIngress("valid", "other.example.com"), true},
+		{"host not covered by the wildcard", newIngress("valid", "a.b.app.example.com"), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkTLSSecrets(tc.ing, getSecret, now)
+			if tc.expectErr && err == nil {
+				t.Errorf("expected an error but none was returned")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/traffic"
+)
+
+const canaryWeightsPath = "/configuration/canary-weights"
+
+// IsCanaryBackend returns true if the backend is an alternative
+// backend of the running configuration
+func (n *NGINXController) IsCanaryBackend(backend string) bool {
+	cfg := n.RunningConfiguration()
+	for _, b := range cfg.Backends {
+		for _, alternative := range b.AlternativeBackends {
+			if alternative == backend {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// SetCanaryWeight updates the weight of a canary backend in the Lua balancer.
+// The weight takes precedence over the canary-weight annotation.
+func (n *NGINXController) SetCanaryWeight(cw *traffic.CanaryWeight) error {
+	statusCode, _, err := nginx.NewPostStatusRequest(canaryWeightsPath, "application/json", cw)
+	if err != nil {
+		return err
+	}
+
+	if statusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	return nil
+}
+
+// CanaryWeights returns the weights of the canary backends configured
+// through the traffic management API
+func (n *NGINXController) CanaryWeights() (map[string]int, error) {
+	statusCode, body, err := nginx.NewGetStatusRequest(canaryWeightsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected error code: %d", statusCode)
+	}
+
+	weights := map[string]int{}
+	err = json.Unmarshal(body, &weights)
+	if err != nil {
+		return nil, err
+	}
+
+	return weights, nil
+}
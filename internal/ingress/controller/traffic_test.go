@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/traffic"
+)
+
+func TestIsCanaryBackend(t *testing.T) {
+	n := &NGINXController{
+		runningConfig: &ingress.Configuration{
+			Backends: []*ingress.Backend{
+				{Name: "default-app-80", AlternativeBackends: []string{"default-canary-80"}},
+				{Name: "default-canary-80", NoServer: true},
+			},
+		},
+		runningConfigLock: &sync.RWMutex{},
+	}
+
+	if !n.IsCanaryBackend("default-canary-80") {
+		t.Errorf("expected default-canary-80 to be a canary backend")
+	}
+
+	if n.IsCanaryBackend("default-app-80") {
+		t.Errorf("expected default-app-80 to not be a canary backend")
+	}
+}
+
+func TestSetCanaryWeight(t *testing.T) {
+	listener, err := net.Listen("unix", nginx.StatusSocket)
+	if err != nil {
+		t.Fatalf("crating unix listener: %s", err)
+	}
+	defer listener.Close()
+	defer os.Remove(nginx.StatusSocket)
+
+	weight := 25
+	server := &httptest.Server{
+		Listener: listener,
+		Config: &http.Server{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != canaryWeightsPath {
+					t.Errorf("unexpected path %v", r.URL.Path)
+				}
+
+				if r.Method == http.MethodGet {
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte(`{"default-canary-80":25}`))
+					return
+				}
+
+				w.WriteHeader(http.StatusCreated)
+
+				cw := &traffic.CanaryWeight{}
+				err := json.NewDecoder(r.Body).Decode(cw)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if cw.Backend != "default-canary-80" || cw.Weight == nil || *cw.Weight != weight {
+					t.Errorf("unexpected canary weight %v", cw)
+				}
+			}),
+		},
+	}
+	defer server.Close()
+	server.Start()
+
+	n := &NGINXController{}
+	err = n.SetCanaryWeight(&traffic.CanaryWeight{Backend: "default-canary-80", Weight: &weight})
+	if err != nil {
+		t.Errorf("unexpected error setting the canary weight: %v", err)
+	}
+
+	weights, err := n.CanaryWeights()
+	if err != nil {
+		t.Errorf("unexpected error obtaining the canary weights: %v", err)
+	}
+
+	if weights["default-canary-80"] != weight {
+		t.Errorf("expected a weight of %v but returned %v", weight, weights)
+	}
+}
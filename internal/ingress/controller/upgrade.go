@@ -0,0 +1,105 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress/controller/process"
+	"k8s.io/ingress-nginx/internal/nginx"
+)
+
+const (
+	// upgradeTimeout is the maximum time to wait for the new NGINX master
+	// process to start after the USR2 signal
+	upgradeTimeout = 30 * time.Second
+)
+
+// UpgradeNginx replaces the running NGINX master process with a new one
+// started from the NGINX binary currently on disk. The new master process
+// inherits the listening sockets of the running one, which is then
+// gracefully stopped, so established connections are not dropped.
+func (n *NGINXController) UpgradeNginx() error {
+	n.stopLock.Lock()
+	defer n.stopLock.Unlock()
+
+	if n.isShuttingDown {
+		return fmt.Errorf("shutdown in progress")
+	}
+
+	// avoid reloads while the master processes are replaced
+	n.syncLock.Lock()
+	defer n.syncLock.Unlock()
+
+	o, err := n.command.Test(cfgPath)
+	if err != nil {
+		return errors.Wrapf(err, "testing the NGINX binary:\n%v", string(o))
+	}
+
+	oldPID, err := process.ReadPID(nginx.PID)
+	if err != nil {
+		return errors.Wrap(err, "reading the NGINX master process ID")
+	}
+
+	klog.Infof("Upgrading NGINX master process %v", oldPID)
+	err = syscall.Kill(oldPID, syscall.SIGUSR2)
+	if err != nil {
+		return errors.Wrapf(err, "sending USR2 to NGINX master process %v", oldPID)
+	}
+
+	var newPID int
+	err = wait.PollImmediate(100*time.Millisecond, upgradeTimeout, func() (bool, error) {
+		pid, err := process.ReadPID(nginx.PID)
+		if err != nil || pid == oldPID || !process.IsProcessRunning(pid) {
+			return false, nil
+		}
+
+		newPID = pid
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("the new NGINX master process did not start, %v is still running", oldPID)
+	}
+
+	klog.Infof("NGINX master process %v started, stopping %v", newPID, oldPID)
+	err = syscall.Kill(oldPID, syscall.SIGQUIT)
+	if err != nil {
+		return errors.Wrapf(err, "sending QUIT to NGINX master process %v", oldPID)
+	}
+
+	// the new master process is not a child of the controller so it cannot
+	// be waited. Watch it to respawn NGINX if it dies.
+	if n.upgradeStopCh != nil {
+		close(n.upgradeStopCh)
+	}
+	n.upgradeStopCh = make(chan struct{})
+
+	go func(pid int, stopCh chan struct{}) {
+		err := process.WaitForExit(pid, time.Second, stopCh)
+		if err != nil {
+			n.ngxErrCh <- err
+		}
+	}(newPID, n.upgradeStopCh)
+
+	return nil
+}
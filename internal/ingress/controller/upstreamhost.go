@@ -0,0 +1,81 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+)
+
+// checkUpstreamHost validates the Host header is defined by a single
+// annotation, and that the Host header defined with the upstream-host
+// annotation matches the name sent using SNI to the HTTPS ExternalName
+// Services of the Ingress, the external servers usually reject the requests
+// when they differ
+func checkUpstreamHost(ing *networking.Ingress, parsed *annotations.Ingress, getService func(string) (*apiv1.Service, error)) error {
+	if _, err := parser.GetStringAnnotation("upstream-vhost", ing); err == nil {
+		if _, err := parser.GetStringAnnotation("upstream-host", ing); err == nil {
+			return fmt.Errorf("the annotations upstream-host and upstream-vhost cannot be both defined")
+		}
+
+		if preserve, _ := parser.GetBoolAnnotation("preserve-host", ing); preserve {
+			return fmt.Errorf("the annotations preserve-host and upstream-vhost cannot be both defined")
+		}
+	}
+
+	hostName := parsed.UpstreamHost.HostName()
+	serverName := parsed.SecureUpstream.ServerName
+	if hostName == "" || serverName == "" || strings.EqualFold(hostName, serverName) {
+		return nil
+	}
+
+	// the Host header is not sent to the gRPC backends
+	if parsed.BackendProtocol != "HTTPS" {
+		return nil
+	}
+
+	var backends []*networking.IngressBackend
+	if ing.Spec.Backend != nil {
+		backends = append(backends, ing.Spec.Backend)
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for i := range rule.HTTP.Paths {
+			backends = append(backends, &rule.HTTP.Paths[i].Backend)
+		}
+	}
+
+	for _, backend := range backends {
+		svc, err := getService(fmt.Sprintf("%v/%v", ing.Namespace, backend.ServiceName))
+		if err != nil || svc.Spec.Type != apiv1.ServiceTypeExternalName {
+			continue
+		}
+
+		return fmt.Errorf("the upstream host %q does not match the proxy-ssl-name %q sent to the ExternalName Service %v/%v",
+			hostName, serverName, ing.Namespace, backend.ServiceName)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress/annotations"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/parser"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/secureupstream"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamhost"
+)
+
+func TestCheckUpstreamHost(t *testing.T) {
+	services := map[string]*apiv1.Service{
+		"example/external": {
+			ObjectMeta: metav1.ObjectMeta{Name: "external", Namespace: "example"},
+			Spec:       apiv1.ServiceSpec{Type: apiv1.ServiceTypeExternalName, ExternalName: "api.example.com"},
+		},
+		"example/internal": {
+			ObjectMeta: metav1.ObjectMeta{Name: "internal", Namespace: "example"},
+			Spec:       apiv1.ServiceSpec{Type: apiv1.ServiceTypeClusterIP},
+		},
+	}
+	getService := func(key string) (*apiv1.Service, error) {
+		if svc, ok := services[key]; ok {
+			return svc, nil
+		}
+		return nil, fmt.Errorf("service %v not found", key)
+	}
+
+	newIngress := func(service string) *networking.Ingress {
+		return &networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "example"},
+			Spec: networking.IngressSpec{
+				Rules: []networking.IngressRule{{
+					Host: "app.example.com",
+					IngressRuleValue: networking.IngressRuleValue{
+						HTTP: &networking.HTTPIngressRuleValue{
+							Paths: []networking.HTTPIngressPath{{
+								Path:    "/",
+								Backend: networking.IngressBackend{ServiceName: service},
+							}},
+						},
+					},
+				}},
+			},
+		}
+	}
+
+	newAnnotations := func(protocol, host, serverName string) *annotations.Ingress {
+		return &annotations.Ingress{
+			BackendProtocol: protocol,
+			UpstreamHost:    upstreamhost.Config{Host: host},
+			SecureUpstream:  secureupstream.Config{ServerName: serverName},
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		service   string
+		parsed    *annotations.Ingress
+		expectErr bool
+	}{
+		{"no upstream host", "external", newAnnotations("HTTPS", "", "sni.example.com"), false},
+		{"no server name", "external", newAnnotations("HTTPS", "api.example.com", ""), false},
+		{"matching server name", "external", newAnnotations("HTTPS", "API.example.com:443", "api.example.com"), false},
+		{"variable upstream host", "external", newAnnotations("HTTPS", "$http_x_original_host", "sni.example.com"), false},
+		{"HTTP backend", "external", newAnnotations("HTTP", "api.example.com", "sni.example.com"), false},
+		{"cluster service", "internal", newAnnotations("HTTPS", "api.example.com", "sni.example.com"), false},
+		{"missing service", "missing", newAnnotations("HTTPS", "api.example.com", "sni.example.com"), false},
+		{"different server name", "external", newAnnotations("HTTPS", "api.example.com", "sni.example.com"), true},
+		{"GRPCS backend", "external", newAnnotations("GRPCS", "api.example.com", "sni.example.com"), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkUpstreamHost(newIngress(tc.service), tc.parsed, getService)
+			if tc.expectErr && err == nil {
+				t.Errorf("expected an error but none was returned")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	conflicts := []struct {
+		name        string
+		annotations map[string]string
+		expectErr   bool
+	}{
+		{"upstream-vhost", map[string]string{"upstream-vhost": "vhost.example.com"}, false},
+		{"upstream-host and upstream-vhost", map[string]string{"upstream-host": "api.example.com", "upstream-vhost": "vhost.example.com"}, true},
+		{"preserve-host and upstream-vhost", map[string]string{"preserve-host": "true", "upstream-vhost": "vhost.example.com"}, true},
+	}
+
+	for _, tc := range conflicts {
+		t.Run(tc.name, func(t *testing.T) {
+			ing := newIngress("internal")
+			ing.Annotations = map[string]string{}
+			for name, value := range tc.annotations {
+				ing.Annotations[parser.GetAnnotationWithPrefix(name)] = value
+			}
+
+			err := checkUpstreamHost(ing, &annotations.Ingress{}, getService)
+			if tc.expectErr && err == nil {
+				t.Errorf("expected an error but none was returned")
+			}
+			if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
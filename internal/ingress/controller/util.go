@@ -22,8 +22,13 @@ import (
 	"syscall"
 
 	api "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/logging"
 	"k8s.io/klog"
 	"k8s.io/kubernetes/pkg/util/sysctl"
 )
@@ -47,13 +52,43 @@ func upstreamName(namespace string, service string, port intstr.IntOrString) str
 	return fmt.Sprintf("%v-%v-%v", namespace, service, port.String())
 }
 
+// updateConfigMap applies update to a ConfigMap, creating it if it does not
+// exist, and retries on conflicts
+func updateConfigMap(client clientset.Interface, namespace, name string, update func(cm *api.ConfigMap)) error {
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(name, metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			cm = &api.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace,
+				},
+			}
+			update(cm)
+
+			_, err = configMaps.Create(cm)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		update(cm)
+
+		_, err = configMaps.Update(cm)
+		return err
+	})
+}
+
 // sysctlSomaxconn returns the maximum number of connections that can be queued
 // for acceptance (value of net.core.somaxconn)
 // http://nginx.org/en/docs/http/ngx_http_core_module.html#listen
 func sysctlSomaxconn() int {
 	maxConns, err := sysctl.New().GetSysctl("net/core/somaxconn")
 	if err != nil || maxConns < 512 {
-		klog.V(3).Infof("net.core.somaxconn=%v (using system default)", maxConns)
+		logging.V(3).Infof("net.core.somaxconn=%v (using system default)", maxConns)
 		return 511
 	}
 
@@ -68,13 +103,14 @@ func rlimitMaxNumFiles() int {
 		klog.Errorf("Error reading system maximum number of open file descriptors (RLIMIT_NOFILE): %v", err)
 		return 0
 	}
-	klog.V(2).Infof("rlimit.max=%v", rLimit.Max)
+	logging.V(2).Infof("rlimit.max=%v", rLimit.Max)
 	return int(rLimit.Max)
 }
 
 const (
 	defBinary = "/usr/local/openresty/nginx/sbin/nginx"
 	cfgPath   = "/etc/nginx/nginx.conf"
+	luaPath   = "/etc/nginx/lua"
 )
 
 // NginxExecTester defines the interface to execute
@@ -0,0 +1,197 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// warmup keeps the readiness of a new replica failing until it is able to
+// serve the traffic of the load balancer with the configuration of the
+// cluster, instead of returning 404 responses and the fake certificate
+type warmup struct {
+	lock sync.Mutex
+
+	started time.Time
+	// storeSynced is set once the informers listed all the objects
+	storeSynced bool
+	// certificatesWritten is the time when the certificates of all the
+	// Ingresses were found written on disk
+	certificatesWritten time.Time
+	// synced is the time of the last synchronization leaving the running
+	// configuration up to date with the objects of the store
+	synced time.Time
+	// reason is the last reason of the warm-up failure, logged when it changes
+	reason string
+	done   bool
+}
+
+func newWarmup() *warmup {
+	return &warmup{started: time.Now()}
+}
+
+func (w *warmup) setStoreSynced() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.storeSynced = true
+}
+
+func (w *warmup) setSynced() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.synced = time.Now()
+}
+
+// CheckWarmup returns an error until the store is synced, the certificates
+// are written, a configuration using them is applied and the self-test of
+// each warm-up host succeeds. Once the warm-up completed it always returns nil.
+func (n *NGINXController) CheckWarmup(_ *http.Request) error {
+	w := n.warmup
+
+	w.lock.Lock()
+	done, storeSynced := w.done, w.storeSynced
+	certificatesWritten, synced := w.certificatesWritten, w.synced
+	w.lock.Unlock()
+
+	if done {
+		return nil
+	}
+
+	// the lock is not held while the certificates are read and the warm-up
+	// hosts are probed, it would block the synchronization calling setSynced
+	certificatesWritten, err := n.checkWarmup(storeSynced, certificatesWritten, synced)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.done {
+		return nil
+	}
+
+	if w.certificatesWritten.IsZero() {
+		w.certificatesWritten = certificatesWritten
+	}
+
+	if err == nil {
+		klog.Infof("Warm-up completed in %v, the controller is ready.", time.Since(w.started).Round(time.Second))
+		w.done = true
+		return nil
+	}
+
+	if n.cfg.WarmupTimeout > 0 && time.Since(w.started) > n.cfg.WarmupTimeout {
+		klog.Warningf("Warm-up not completed after %v (%v), the controller is ready anyway.", n.cfg.WarmupTimeout, err)
+		w.done = true
+		return nil
+	}
+
+	if reason := err.Error(); reason != w.reason {
+		klog.Infof("Warm-up in progress: %v", reason)
+		w.reason = reason
+	}
+
+	return err
+}
+
+// checkWarmup returns the time when the certificates were found written on
+// disk, zero while they are not, and the reason of the warm-up failure
+func (n *NGINXController) checkWarmup(storeSynced bool, certificatesWritten, synced time.Time) (time.Time, error) {
+	if !storeSynced {
+		return certificatesWritten, fmt.Errorf("waiting for the synchronization of the store")
+	}
+
+	if certificatesWritten.IsZero() {
+		pending := pendingCertificates(n.store.ListIngresses(nil), n.store.GetSecret, n.store.GetLocalSSLCert)
+		if len(pending) > 0 {
+			return certificatesWritten, fmt.Errorf("waiting for the certificates %v", strings.Join(pending, ", "))
+		}
+
+		certificatesWritten = time.Now()
+	}
+
+	// the configuration must be built after the certificates were written
+	if synced.Before(certificatesWritten) {
+		return certificatesWritten, fmt.Errorf("waiting for the configuration to be applied")
+	}
+
+	if len(n.cfg.WarmupTargets) == 0 {
+		return certificatesWritten, nil
+	}
+
+	servers := n.runningServers()
+	for _, target := range n.cfg.WarmupTargets {
+		if _, ok := servers[target.Host]; !ok {
+			return certificatesWritten, fmt.Errorf("waiting for the server of the host %v", target.Host)
+		}
+
+		if err := n.probe(servers, target); err != nil {
+			return certificatesWritten, fmt.Errorf("self-test of %v%v failed: %v", target.Host, target.Path, err)
+		}
+	}
+
+	return certificatesWritten, nil
+}
+
+// pendingCertificates returns the keys of the TLS secrets referenced by the
+// Ingresses which exist but are not written on disk yet
+func pendingCertificates(ings []*ingress.Ingress,
+	getSecret func(string) (*apiv1.Secret, error),
+	getLocalSSLCert func(string) (*ingress.SSLCert, error)) []string {
+
+	pending := map[string]bool{}
+	for _, ing := range ings {
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName == "" {
+				continue
+			}
+
+			key := fmt.Sprintf("%v/%v", ing.Namespace, tls.SecretName)
+			secret, err := getSecret(key)
+			if err != nil {
+				// the default certificate is used for the missing secrets
+				continue
+			}
+
+			if _, ok := secret.Data[apiv1.TLSCertKey]; !ok {
+				continue
+			}
+
+			if _, err := getLocalSSLCert(key); err != nil {
+				pending[key] = true
+			}
+		}
+	}
+
+	keys := []string{}
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+func TestPendingCertificates(t *testing.T) {
+	ing := &ingress.Ingress{
+		Ingress: networking.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"},
+			Spec: networking.IngressSpec{
+				TLS: []networking.IngressTLS{
+					{SecretName: "written"},
+					{SecretName: "pending"},
+					{SecretName: "missing"},
+					{SecretName: "ca-only"},
+					{Hosts: []string{"default-certificate.example.com"}},
+				},
+			},
+		},
+	}
+
+	getSecret := func(key string) (*apiv1.Secret, error) {
+		switch key {
+		case "default/written", "default/pending":
+			return &apiv1.Secret{Data: map[string][]byte{apiv1.TLSCertKey: []byte("cert")}}, nil
+		case "default/ca-only":
+			return &apiv1.Secret{Data: map[string][]byte{"ca.crt": []byte("ca")}}, nil
+		}
+		return nil, fmt.Errorf("secret %v not found", key)
+	}
+
+	getLocalSSLCert := func(key string) (*ingress.SSLCert, error) {
+		if key == "default/written" {
+			return &ingress.SSLCert{}, nil
+		}
+		return nil, fmt.Errorf("certificate %v not found", key)
+	}
+
+	pending := pendingCertificates([]*ingress.Ingress{ing}, getSecret, getLocalSSLCert)
+	if !reflect.DeepEqual(pending, []string{"default/pending"}) {
+		t.Errorf("expected only default/pending but returned %v", pending)
+	}
+}
+
+func TestCheckWarmup(t *testing.T) {
+	n := &NGINXController{
+		cfg:               &Configuration{},
+		store:             fakeIngressStore{},
+		runningConfig:     &ingress.Configuration{},
+		runningConfigLock: &sync.RWMutex{},
+		warmup:            newWarmup(),
+	}
+
+	if err := n.CheckWarmup(nil); err == nil {
+		t.Fatalf("expected an error before the synchronization of the store")
+	}
+
+	n.warmup.setStoreSynced()
+	if err := n.CheckWarmup(nil); err == nil {
+		t.Fatalf("expected an error before the first configuration")
+	}
+
+	n.warmup.setSynced()
+	if err := n.CheckWarmup(nil); err != nil {
+		t.Fatalf("unexpected error after the first configuration: %v", err)
+	}
+
+	// the warm-up is not checked again once it completed
+	n.warmup.storeSynced = false
+	if err := n.CheckWarmup(nil); err != nil {
+		t.Errorf("unexpected error after the warm-up: %v", err)
+	}
+}
+
+func TestCheckWarmupTimeout(t *testing.T) {
+	n := &NGINXController{
+		cfg:    &Configuration{WarmupTimeout: time.Minute},
+		warmup: newWarmup(),
+	}
+
+	if err := n.CheckWarmup(nil); err == nil {
+		t.Fatalf("expected an error before the timeout")
+	}
+
+	n.warmup.started = time.Now().Add(-2 * time.Minute)
+	if err := n.CheckWarmup(nil); err != nil {
+		t.Errorf("unexpected error after the timeout: %v", err)
+	}
+}
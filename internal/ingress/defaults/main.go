@@ -111,6 +111,11 @@ type Backend struct {
 	// This is useful if doing SSL offloading outside of cluster eg AWS ELB
 	ForceSSLRedirect bool `json:"force-ssl-redirect"`
 
+	// Port used in the redirects to HTTPS, the HTTPS port exposed to the
+	// clients when the controller is behind a NAT
+	// Default: 0 (the port of the request)
+	SSLRedirectPort int `json:"ssl-redirect-port"`
+
 	// Enables or disables the specification of port in redirects
 	// Default: false
 	UsePortInRedirects bool `json:"use-port-in-redirects"`
@@ -150,4 +155,19 @@ type Backend struct {
 	// Enables or disables buffering of responses from the proxied server.
 	// http://nginx.org/en/docs/http/ngx_http_proxy_module.html#proxy_buffering
 	ProxyBuffering string `json:"proxy-buffering"`
+
+	// TrafficCaptureMaxSampleRate is the upper bound of the traffic-capture-sample-rate
+	// annotation, the fraction of the requests captured
+	TrafficCaptureMaxSampleRate float64 `json:"traffic-capture-max-sample-rate"`
+
+	// TrafficCaptureMaxRPS is the maximum number of requests captured per second
+	// for each sink by each NGINX worker
+	TrafficCaptureMaxRPS int `json:"traffic-capture-max-rps"`
+
+	// TrafficCaptureDeniedHeaders is a comma-separated list of headers that can't
+	// be captured because they usually contain credentials or personal data
+	TrafficCaptureDeniedHeaders string `json:"traffic-capture-denied-headers"`
+
+	// AllowTrafficCaptureBody allows the traffic-capture-body annotation
+	AllowTrafficCaptureBody bool `json:"allow-traffic-capture-body"`
 }
@@ -43,7 +43,9 @@ func NewInvalidAnnotationConfiguration(name string, reason string) error {
 // NewInvalidAnnotationContent returns a new InvalidContent error
 func NewInvalidAnnotationContent(name string, val interface{}) error {
 	return InvalidContent{
-		Name: fmt.Sprintf("the annotation %v does not contain a valid value (%v)", name, val),
+		Name:       fmt.Sprintf("the annotation %v does not contain a valid value (%v)", name, val),
+		Annotation: name,
+		Value:      fmt.Sprintf("%v", val),
 	}
 }
 
@@ -66,6 +68,10 @@ func (e InvalidConfiguration) Error() string {
 // InvalidContent error
 type InvalidContent struct {
 	Name string
+	// Annotation contains the name of the annotation with the invalid value
+	Annotation string
+	// Value contains the invalid value
+	Value string
 }
 
 func (e InvalidContent) Error() string {
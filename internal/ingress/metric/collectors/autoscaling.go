@@ -0,0 +1,259 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ncabatoff/process-exporter/proc"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/nginx"
+	"k8s.io/ingress-nginx/internal/runtime"
+)
+
+// AutoscalingSampleInterval is the interval between two samples of the
+// autoscaling signals. The signals do not depend on the scrapes, so several
+// consumers (i.e. Prometheus and KEDA) can scrape them at any interval.
+const AutoscalingSampleInterval = 10 * time.Second
+
+// autoscalingSample contains the counters read from NGINX at a point in time
+type autoscalingSample struct {
+	time              time.Time
+	activeConnections int
+	requests          int
+	cpuSeconds        float64
+}
+
+// autoscalingSignals contains the signals computed between two samples
+type autoscalingSignals struct {
+	activeConnections      float64
+	requestsPerSecond      float64
+	connectionsUtilization float64
+	cpuUtilization         float64
+	saturation             float64
+}
+
+// Autoscaling exports the signals used to autoscale the controller pods,
+// the active connections, the requests per second and the saturation of
+// NGINX, in a dedicated registry
+type Autoscaling struct {
+	sample func() (*autoscalingSample, error)
+	// cpus is the number of CPUs the pod can use
+	cpus float64
+	// capacity is the maximum number of connections of all the workers
+	capacity int64
+
+	lock    sync.RWMutex
+	last    *autoscalingSample
+	signals *autoscalingSignals
+
+	stopCh chan struct{}
+
+	activeConnections      *prometheus.Desc
+	requestsPerSecond      *prometheus.Desc
+	connectionsUtilization *prometheus.Desc
+	cpuUtilization         *prometheus.Desc
+	saturation             *prometheus.Desc
+}
+
+// NewAutoscaling returns a new prometheus collector of the autoscaling signals
+func NewAutoscaling(pod, namespace, ingressClass string) (*Autoscaling, error) {
+	fs, err := proc.NewFS("/proc", false)
+	if err != nil {
+		return nil, err
+	}
+
+	grouper := proc.NewGrouper(BinaryNameMatcher{Name: name, Binary: binary}, true, false, false)
+
+	a := newAutoscaling(pod, namespace, ingressClass, runtime.CPULimit(), func() (*autoscalingSample, error) {
+		return readAutoscalingSample(fs, grouper)
+	})
+
+	return a, nil
+}
+
+func newAutoscaling(pod, namespace, ingressClass string, cpus float64, sample func() (*autoscalingSample, error)) *Autoscaling {
+	constLabels := prometheus.Labels{
+		"controller_namespace": namespace,
+		"controller_class":     ingressClass,
+		"controller_pod":       pod,
+	}
+
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(PrometheusNamespace, "autoscaling", name), help, nil, constLabels)
+	}
+
+	return &Autoscaling{
+		sample: sample,
+		cpus:   cpus,
+		stopCh: make(chan struct{}),
+
+		activeConnections: desc("active_connections",
+			"Average number of active client connections during the last sample interval"),
+		requestsPerSecond: desc("requests_per_second",
+			"Number of client requests per second during the last sample interval"),
+		connectionsUtilization: desc("connections_utilization",
+			"Ratio of the active client connections to the maximum number of connections of the NGINX workers"),
+		cpuUtilization: desc("cpu_utilization",
+			"Ratio of the CPU used by the NGINX processes to the CPU limit of the pod during the last sample interval"),
+		saturation: desc("saturation",
+			"Maximum of the connections and the CPU utilizations, 1 indicates NGINX can not handle more traffic"),
+	}
+}
+
+// readAutoscalingSample reads the counters of the NGINX status module and
+// the CPU time of the NGINX processes
+func readAutoscalingSample(fs *proc.FS, grouper *proc.Grouper) (*autoscalingSample, error) {
+	status, data, err := nginx.NewGetStatusRequest(nginx.StatusPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if status < 200 || status >= 400 {
+		return nil, fmt.Errorf("unexpected status code %v", status)
+	}
+
+	s := parse(string(data))
+
+	_, groups, err := grouper.Update(fs.AllProcs())
+	if err != nil {
+		return nil, err
+	}
+
+	cpuSeconds := 0.0
+	for _, gcounts := range groups {
+		cpuSeconds += gcounts.CPUUserTime + gcounts.CPUSystemTime
+	}
+
+	return &autoscalingSample{
+		time:              time.Now(),
+		activeConnections: s.Active,
+		requests:          s.Requests,
+		cpuSeconds:        cpuSeconds,
+	}, nil
+}
+
+// SetConnectionsCapacity sets the maximum number of client connections of all
+// the NGINX workers, used to compute the connections utilization
+func (a *Autoscaling) SetConnectionsCapacity(capacity int) {
+	atomic.StoreInt64(&a.capacity, int64(capacity))
+}
+
+// Start samples the signals until Stop is called
+func (a *Autoscaling) Start() {
+	ticker := time.NewTicker(AutoscalingSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s, err := a.sample()
+			if err != nil {
+				klog.Warningf("unexpected error sampling the autoscaling signals: %v", err)
+				continue
+			}
+
+			a.update(s)
+		case <-a.stopCh:
+			return
+		}
+	}
+}
+
+// Stop stops the sampling of the signals
+func (a *Autoscaling) Stop() {
+	close(a.stopCh)
+}
+
+// update computes the signals between the last sample and s
+func (a *Autoscaling) update(s *autoscalingSample) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	last := a.last
+	a.last = s
+
+	if last == nil {
+		return
+	}
+
+	elapsed := s.time.Sub(last.time).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+
+	// the counters are reset when NGINX restarts
+	requests := s.requests - last.requests
+	if requests < 0 {
+		requests = s.requests
+	}
+
+	cpuSeconds := s.cpuSeconds - last.cpuSeconds
+	if cpuSeconds < 0 {
+		cpuSeconds = s.cpuSeconds
+	}
+
+	signals := &autoscalingSignals{
+		activeConnections: float64(last.activeConnections+s.activeConnections) / 2,
+		requestsPerSecond: float64(requests) / elapsed,
+	}
+
+	if capacity := atomic.LoadInt64(&a.capacity); capacity > 0 {
+		signals.connectionsUtilization = signals.activeConnections / float64(capacity)
+	}
+
+	if a.cpus > 0 {
+		signals.cpuUtilization = cpuSeconds / elapsed / a.cpus
+	}
+
+	signals.saturation = math.Max(signals.connectionsUtilization, signals.cpuUtilization)
+
+	a.signals = signals
+}
+
+// Describe implements prometheus.Collector
+func (a *Autoscaling) Describe(ch chan<- *prometheus.Desc) {
+	ch <- a.activeConnections
+	ch <- a.requestsPerSecond
+	ch <- a.connectionsUtilization
+	ch <- a.cpuUtilization
+	ch <- a.saturation
+}
+
+// Collect implements prometheus.Collector. The signals are not exported
+// before two samples were taken, so a starting pod does not report zeros.
+func (a *Autoscaling) Collect(ch chan<- prometheus.Metric) {
+	a.lock.RLock()
+	signals := a.signals
+	a.lock.RUnlock()
+
+	if signals == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(a.activeConnections, prometheus.GaugeValue, signals.activeConnections)
+	ch <- prometheus.MustNewConstMetric(a.requestsPerSecond, prometheus.GaugeValue, signals.requestsPerSecond)
+	ch <- prometheus.MustNewConstMetric(a.connectionsUtilization, prometheus.GaugeValue, signals.connectionsUtilization)
+	ch <- prometheus.MustNewConstMetric(a.cpuUtilization, prometheus.GaugeValue, signals.cpuUtilization)
+	ch <- prometheus.MustNewConstMetric(a.saturation, prometheus.GaugeValue, signals.saturation)
+}
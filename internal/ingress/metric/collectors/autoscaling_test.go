@@ -0,0 +1,112 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestAutoscaling(t *testing.T) {
+	const metadata = `
+		# HELP nginx_ingress_controller_autoscaling_active_connections Average number of active client connections during the last sample interval
+		# TYPE nginx_ingress_controller_autoscaling_active_connections gauge
+		# HELP nginx_ingress_controller_autoscaling_connections_utilization Ratio of the active client connections to the maximum number of connections of the NGINX workers
+		# TYPE nginx_ingress_controller_autoscaling_connections_utilization gauge
+		# HELP nginx_ingress_controller_autoscaling_cpu_utilization Ratio of the CPU used by the NGINX processes to the CPU limit of the pod during the last sample interval
+		# TYPE nginx_ingress_controller_autoscaling_cpu_utilization gauge
+		# HELP nginx_ingress_controller_autoscaling_requests_per_second Number of client requests per second during the last sample interval
+		# TYPE nginx_ingress_controller_autoscaling_requests_per_second gauge
+		# HELP nginx_ingress_controller_autoscaling_saturation Maximum of the connections and the CPU utilizations, 1 indicates NGINX can not handle more traffic
+		# TYPE nginx_ingress_controller_autoscaling_saturation gauge
+	`
+	metrics := []string{
+		"nginx_ingress_controller_autoscaling_active_connections",
+		"nginx_ingress_controller_autoscaling_connections_utilization",
+		"nginx_ingress_controller_autoscaling_cpu_utilization",
+		"nginx_ingress_controller_autoscaling_requests_per_second",
+		"nginx_ingress_controller_autoscaling_saturation",
+	}
+
+	start := time.Unix(1000, 0)
+
+	cases := []struct {
+		name    string
+		samples []*autoscalingSample
+		want    string
+	}{
+		{
+			name: "should not export the signals before two samples",
+			samples: []*autoscalingSample{
+				{time: start, activeConnections: 10, requests: 100, cpuSeconds: 1},
+			},
+			want: ``,
+		},
+		{
+			name: "should compute the signals between two samples",
+			samples: []*autoscalingSample{
+				{time: start, activeConnections: 100, requests: 1000, cpuSeconds: 10},
+				{time: start.Add(10 * time.Second), activeConnections: 300, requests: 6000, cpuSeconds: 15},
+			},
+			want: metadata + `
+				nginx_ingress_controller_autoscaling_active_connections{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 200
+				nginx_ingress_controller_autoscaling_connections_utilization{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 0.2
+				nginx_ingress_controller_autoscaling_cpu_utilization{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 0.25
+				nginx_ingress_controller_autoscaling_requests_per_second{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 500
+				nginx_ingress_controller_autoscaling_saturation{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 0.25
+			`,
+		},
+		{
+			name: "should handle a restart of NGINX",
+			samples: []*autoscalingSample{
+				{time: start, activeConnections: 800, requests: 5000, cpuSeconds: 100},
+				{time: start.Add(10 * time.Second), activeConnections: 1000, requests: 1000, cpuSeconds: 2},
+			},
+			want: metadata + `
+				nginx_ingress_controller_autoscaling_active_connections{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 900
+				nginx_ingress_controller_autoscaling_connections_utilization{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 0.9
+				nginx_ingress_controller_autoscaling_cpu_utilization{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 0.1
+				nginx_ingress_controller_autoscaling_requests_per_second{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 100
+				nginx_ingress_controller_autoscaling_saturation{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 0.9
+			`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a := newAutoscaling("pod", "default", "nginx", 2, nil)
+			a.SetConnectionsCapacity(1000)
+
+			reg := prometheus.NewPedanticRegistry()
+			if err := reg.Register(a); err != nil {
+				t.Errorf("registering collector failed: %s", err)
+			}
+
+			for _, s := range c.samples {
+				a.update(s)
+			}
+
+			if err := GatherAndCompare(a, c.want, metrics, reg); err != nil {
+				t.Errorf("unexpected collecting result:\n%s", err)
+			}
+
+			reg.Unregister(a)
+		})
+	}
+}
@@ -18,11 +18,14 @@ package collectors
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/inventory"
+	"k8s.io/ingress-nginx/internal/logging"
 	"k8s.io/klog"
 )
 
@@ -42,6 +45,7 @@ type Controller struct {
 
 	reloadOperation             *prometheus.CounterVec
 	reloadOperationErrors       *prometheus.CounterVec
+	reloadOperationSkipped      *prometheus.CounterVec
 	checkIngressOperation       *prometheus.CounterVec
 	checkIngressOperationErrors *prometheus.CounterVec
 	sslExpireTime               *prometheus.GaugeVec
@@ -50,6 +54,27 @@ type Controller struct {
 	labels      prometheus.Labels
 
 	leaderElection *prometheus.GaugeVec
+	leaderTask     *prometheus.GaugeVec
+
+	probeSuccess  *prometheus.GaugeVec
+	probeDuration *prometheus.GaugeVec
+
+	inventory *prometheus.GaugeVec
+
+	geoIPBuildTime *prometheus.GaugeVec
+
+	modsecurityRuleHits     *prometheus.CounterVec
+	modsecurityAuditEntries *prometheus.CounterVec
+
+	tlsFingerprintConnections *prometheus.GaugeVec
+
+	unusedSSLCertificates      *prometheus.GaugeVec
+	defaultSSLCertificateHosts *prometheus.GaugeVec
+	sslStaleFiles              *prometheus.CounterVec
+	sslCAExpireTime            *prometheus.GaugeVec
+	sslCertificateExpireTime   *prometheus.GaugeVec
+
+	backendWithoutEndpoints *prometheus.GaugeVec
 }
 
 // NewController creates a new prometheus collector for the
@@ -107,6 +132,14 @@ func NewController(pod, namespace, class string) *Controller {
 			},
 			operation,
 		),
+		reloadOperationSkipped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: PrometheusNamespace,
+				Name:      "skipped_reloads",
+				Help:      `Cumulative number of reloads skipped because the rendered configuration and the files it includes did not change`,
+			},
+			operation,
+		),
 		checkIngressOperationErrors: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Namespace: PrometheusNamespace,
@@ -141,6 +174,132 @@ func NewController(pod, namespace, class string) *Controller {
 			},
 			[]string{"name"},
 		),
+		leaderTask: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "leader_task_status",
+				Help:        "Gauge reporting if the pod runs a task of the leader, 1 indicates the task is running in the pod. 'task' is the name of the task",
+				ConstLabels: constLabels,
+			},
+			[]string{"task"},
+		),
+		probeSuccess: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "probe_success",
+				Help:        "Gauge reporting if the last synthetic request sent through NGINX to a host and a path succeeded, 1 indicates a success",
+				ConstLabels: constLabels,
+			},
+			[]string{"host", "path"},
+		),
+		probeDuration: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "probe_duration_seconds",
+				Help:        "Duration of the last synthetic request sent through NGINX to a host and a path",
+				ConstLabels: constLabels,
+			},
+			[]string{"host", "path"},
+		),
+		inventory: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "inventory_info",
+				Help:        "Modules, template and Lua code used by NGINX since the last reload. 'kind' is module, template, lua or plugin, 'sha256' is the checksum of the file",
+				ConstLabels: constLabels,
+			},
+			[]string{"kind", "name", "sha256"},
+		),
+		geoIPBuildTime: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "geoip_database_build_time_seconds",
+				Help:        "Number of seconds since 1970 to the build date of the GeoIP2 databases downloaded by the controller. 'edition' is the MaxMind edition ID",
+				ConstLabels: constLabels,
+			},
+			[]string{"edition"},
+		),
+		modsecurityRuleHits: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "modsecurity_rule_hits_total",
+				Help:        "Cumulative number of ModSecurity audit entries matching a rule. 'rule_id' is the ID of the rule",
+				ConstLabels: constLabels,
+			},
+			[]string{"rule_id"},
+		),
+		modsecurityAuditEntries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "modsecurity_audit_entries_total",
+				Help:        "Cumulative number of ModSecurity audit entries. 'state' is read, shipped or dropped",
+				ConstLabels: constLabels,
+			},
+			[]string{"state"},
+		),
+		tlsFingerprintConnections: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "tls_fingerprint_connections",
+				Help:        "Number of TLS connections of the most frequent client fingerprints during the last minute. 'ja4' is the JA4 fingerprint",
+				ConstLabels: constLabels,
+			},
+			[]string{"ja4"},
+		),
+		unusedSSLCertificates: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "ssl_certificate_unused",
+				Help:        "SSL certificates synced to disk not used anymore. 'state' is unreferenced when no Ingress references the secret, soon_orphaned when only Ingresses being deleted reference it",
+				ConstLabels: constLabels,
+			},
+			[]string{"namespace", "secret_name", "state"},
+		),
+		defaultSSLCertificateHosts: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "ssl_default_certificate_host",
+				Help:        "Hosts serving the default SSL certificate although their Ingress references a secret",
+				ConstLabels: constLabels,
+			},
+			[]string{"host", "namespace", "secret_name"},
+		),
+		sslStaleFiles: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "ssl_stale_files_total",
+				Help:        "Cumulative number of PEM files of the SSL directory no certificate uses anymore. 'action' is removed, or dry_run when the files are only logged",
+				ConstLabels: constLabels,
+			},
+			[]string{"action"},
+		),
+		sslCAExpireTime: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "ssl_ca_expire_time_seconds",
+				Help:        "Number of seconds since 1970 to the expiration of each certificate of the CA bundles. 'serial_number' is the hexadecimal serial number of the certificate",
+				ConstLabels: constLabels,
+			},
+			[]string{"namespace", "secret_name", "subject", "serial_number"},
+		),
+		sslCertificateExpireTime: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "ssl_certificate_expire_time_seconds",
+				Help:        "Number of seconds since 1970 to the expiration of the certificate of each TLS secret, for each host serving it. 'host' is empty when no host serves the certificate",
+				ConstLabels: constLabels,
+			},
+			[]string{"namespace", "secret_name", "host"},
+		),
+		backendWithoutEndpoints: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   PrometheusNamespace,
+				Name:        "backend_without_endpoints",
+				Help:        "Backends of the Ingresses without any endpoint, their requests are answered with a 503",
+				ConstLabels: constLabels,
+			},
+			[]string{"namespace", "ingress", "backend"},
+		),
 	}
 
 	return cm
@@ -156,6 +315,11 @@ func (cm *Controller) IncReloadErrorCount() {
 	cm.reloadOperationErrors.With(cm.constLabels).Inc()
 }
 
+// IncReloadSkippedCount increment the skipped reload counter
+func (cm *Controller) IncReloadSkippedCount() {
+	cm.reloadOperationSkipped.With(cm.constLabels).Inc()
+}
+
 // OnStartedLeading indicates the pod was elected as the leader
 func (cm *Controller) OnStartedLeading(electionID string) {
 	cm.leaderElection.WithLabelValues(electionID).Set(1.0)
@@ -166,6 +330,156 @@ func (cm *Controller) OnStoppedLeading(electionID string) {
 	cm.leaderElection.WithLabelValues(electionID).Set(0)
 }
 
+// SetLeaderTask indicates if the pod runs a task of the leader
+func (cm *Controller) SetLeaderTask(task string, running bool) {
+	value := 0.0
+	if running {
+		value = 1.0
+	}
+	cm.leaderTask.WithLabelValues(task).Set(value)
+}
+
+// SetProbe sets the result and the latency of the last probe of a host and a path
+func (cm *Controller) SetProbe(host, path string, success bool, latency time.Duration) {
+	value := 0.0
+	if success {
+		value = 1.0
+	}
+	cm.probeSuccess.WithLabelValues(host, path).Set(value)
+	cm.probeDuration.WithLabelValues(host, path).Set(latency.Seconds())
+}
+
+// SetInventory sets the modules, the template and the Lua code used by NGINX
+func (cm *Controller) SetInventory(components []inventory.Component) {
+	cm.inventory.Reset()
+	for _, c := range components {
+		cm.inventory.WithLabelValues(c.Kind, c.Name, c.SHA256).Set(1)
+	}
+}
+
+// SetGeoIPDatabase sets the build date of a GeoIP2 database
+func (cm *Controller) SetGeoIPDatabase(edition string, buildDate time.Time) {
+	cm.geoIPBuildTime.WithLabelValues(edition).Set(float64(buildDate.Unix()))
+}
+
+// IncModSecurityRuleHit counts a ModSecurity audit entry matching a rule
+func (cm *Controller) IncModSecurityRuleHit(ruleID string) {
+	cm.modsecurityRuleHits.WithLabelValues(ruleID).Inc()
+}
+
+// AddModSecurityAuditEntries counts the ModSecurity audit entries read, shipped or dropped
+func (cm *Controller) AddModSecurityAuditEntries(state string, count int) {
+	cm.modsecurityAuditEntries.WithLabelValues(state).Add(float64(count))
+}
+
+// SetTLSFingerprints replaces the number of connections of the most frequent TLS fingerprints
+func (cm *Controller) SetTLSFingerprints(connections map[string]int) {
+	cm.tlsFingerprintConnections.Reset()
+	for ja4, count := range connections {
+		cm.tlsFingerprintConnections.WithLabelValues(ja4).Set(float64(count))
+	}
+}
+
+// SetSSLCertificateUsage replaces the secrets not used anymore and the hosts
+// serving the default certificate. The secrets are namespace/name keys.
+func (cm *Controller) SetSSLCertificateUsage(unreferenced, soonOrphaned []string, defaultCertificateHosts map[string]string) {
+	cm.unusedSSLCertificates.Reset()
+	for _, key := range unreferenced {
+		namespace, name := splitSecretKey(key)
+		cm.unusedSSLCertificates.WithLabelValues(namespace, name, "unreferenced").Set(1)
+	}
+	for _, key := range soonOrphaned {
+		namespace, name := splitSecretKey(key)
+		cm.unusedSSLCertificates.WithLabelValues(namespace, name, "soon_orphaned").Set(1)
+	}
+
+	cm.defaultSSLCertificateHosts.Reset()
+	for host, key := range defaultCertificateHosts {
+		namespace, name := splitSecretKey(key)
+		cm.defaultSSLCertificateHosts.WithLabelValues(host, namespace, name).Set(1)
+	}
+}
+
+// AddSSLStaleFiles counts the PEM files no certificate uses anymore, removed or only logged
+func (cm *Controller) AddSSLStaleFiles(action string, count int) {
+	cm.sslStaleFiles.WithLabelValues(action).Add(float64(count))
+}
+
+// SetSSLCAExpireTime replaces the expiration time of the certificates of the CA bundles
+func (cm *Controller) SetSSLCAExpireTime(certs []*ingress.SSLCert) {
+	cm.sslCAExpireTime.Reset()
+	for _, cert := range certs {
+		for _, ca := range cert.CACertificates {
+			cm.sslCAExpireTime.WithLabelValues(cert.Namespace, cert.Name, ca.Subject.String(), fmt.Sprintf("%x", ca.SerialNumber)).Set(float64(ca.NotAfter.Unix()))
+		}
+	}
+}
+
+// SetSSLCertificateExpireTime replaces the expiration time of the certificates
+// of the TLS secrets, with the hosts of the servers using them
+func (cm *Controller) SetSSLCertificateExpireTime(certs []*ingress.SSLCert, servers []*ingress.Server) {
+	cm.sslCertificateExpireTime.Reset()
+
+	hosts := map[string][]string{}
+	for _, s := range servers {
+		if s.Hostname != "" && s.SSLCert.Name != "" {
+			key := s.SSLCert.Namespace + "/" + s.SSLCert.Name
+			hosts[key] = append(hosts[key], s.Hostname)
+		}
+	}
+
+	for _, cert := range certs {
+		// the secrets containing only a CA bundle have no expiration time
+		if cert.ExpireTime.IsZero() {
+			continue
+		}
+
+		expireTime := float64(cert.ExpireTime.Unix())
+
+		certHosts := hosts[cert.Namespace+"/"+cert.Name]
+		if len(certHosts) == 0 {
+			cm.sslCertificateExpireTime.WithLabelValues(cert.Namespace, cert.Name, "").Set(expireTime)
+			continue
+		}
+
+		for _, host := range certHosts {
+			cm.sslCertificateExpireTime.WithLabelValues(cert.Namespace, cert.Name, host).Set(expireTime)
+		}
+	}
+}
+
+// SetBackendsWithoutEndpoints replaces the backends without endpoints used by
+// the locations of the Ingresses
+func (cm *Controller) SetBackendsWithoutEndpoints(cfg *ingress.Configuration) {
+	cm.backendWithoutEndpoints.Reset()
+
+	empty := map[string]bool{}
+	for _, backend := range cfg.Backends {
+		if len(backend.Endpoints) == 0 {
+			empty[backend.Name] = true
+		}
+	}
+
+	for _, server := range cfg.Servers {
+		for _, location := range server.Locations {
+			if location.IsDefBackend || location.Ingress == nil || !empty[location.Backend] {
+				continue
+			}
+
+			cm.backendWithoutEndpoints.WithLabelValues(location.Ingress.Namespace, location.Ingress.Name, location.Backend).Set(1)
+		}
+	}
+}
+
+func splitSecretKey(key string) (string, string) {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 {
+		return "", key
+	}
+
+	return parts[0], parts[1]
+}
+
 // IncCheckCount increment the check counter
 func (cm *Controller) IncCheckCount(namespace, name string) {
 	labels := prometheus.Labels{
@@ -206,10 +520,25 @@ func (cm Controller) Describe(ch chan<- *prometheus.Desc) {
 	cm.configSuccessTime.Describe(ch)
 	cm.reloadOperation.Describe(ch)
 	cm.reloadOperationErrors.Describe(ch)
+	cm.reloadOperationSkipped.Describe(ch)
 	cm.checkIngressOperation.Describe(ch)
 	cm.checkIngressOperationErrors.Describe(ch)
 	cm.sslExpireTime.Describe(ch)
 	cm.leaderElection.Describe(ch)
+	cm.leaderTask.Describe(ch)
+	cm.probeSuccess.Describe(ch)
+	cm.inventory.Describe(ch)
+	cm.probeDuration.Describe(ch)
+	cm.geoIPBuildTime.Describe(ch)
+	cm.modsecurityRuleHits.Describe(ch)
+	cm.modsecurityAuditEntries.Describe(ch)
+	cm.tlsFingerprintConnections.Describe(ch)
+	cm.unusedSSLCertificates.Describe(ch)
+	cm.defaultSSLCertificateHosts.Describe(ch)
+	cm.sslStaleFiles.Describe(ch)
+	cm.sslCAExpireTime.Describe(ch)
+	cm.sslCertificateExpireTime.Describe(ch)
+	cm.backendWithoutEndpoints.Describe(ch)
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -219,10 +548,25 @@ func (cm Controller) Collect(ch chan<- prometheus.Metric) {
 	cm.configSuccessTime.Collect(ch)
 	cm.reloadOperation.Collect(ch)
 	cm.reloadOperationErrors.Collect(ch)
+	cm.reloadOperationSkipped.Collect(ch)
 	cm.checkIngressOperation.Collect(ch)
 	cm.checkIngressOperationErrors.Collect(ch)
 	cm.sslExpireTime.Collect(ch)
 	cm.leaderElection.Collect(ch)
+	cm.leaderTask.Collect(ch)
+	cm.probeSuccess.Collect(ch)
+	cm.inventory.Collect(ch)
+	cm.probeDuration.Collect(ch)
+	cm.geoIPBuildTime.Collect(ch)
+	cm.modsecurityRuleHits.Collect(ch)
+	cm.modsecurityAuditEntries.Collect(ch)
+	cm.tlsFingerprintConnections.Collect(ch)
+	cm.unusedSSLCertificates.Collect(ch)
+	cm.defaultSSLCertificateHosts.Collect(ch)
+	cm.sslStaleFiles.Collect(ch)
+	cm.sslCAExpireTime.Collect(ch)
+	cm.sslCertificateExpireTime.Collect(ch)
+	cm.backendWithoutEndpoints.Collect(ch)
 }
 
 // SetSSLExpireTime sets the expiration time of SSL Certificates
@@ -283,10 +627,10 @@ func (cm *Controller) removeSSLExpireMetrics(onlyDefinedHosts bool, hosts []stri
 				continue
 			}
 
-			klog.V(2).Infof("Removing prometheus metric from gauge %v for host %v", metricName, host)
+			logging.V(2).Infof("Removing prometheus metric from gauge %v for host %v", metricName, host)
 			removed := cm.sslExpireTime.Delete(labels)
 			if !removed {
-				klog.V(2).Infof("metric %v for host %v with labels not removed: %v", metricName, host, labels)
+				logging.V(2).Infof("metric %v for host %v with labels not removed: %v", metricName, host, labels)
 			}
 		}
 	}
@@ -17,11 +17,18 @@ limitations under the License.
 package collectors
 
 import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	networking "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/inventory"
 )
 
 func TestControllerCounters(t *testing.T) {
@@ -70,6 +77,18 @@ func TestControllerCounters(t *testing.T) {
 			`,
 			metrics: []string{"nginx_ingress_controller_errors"},
 		},
+		{
+			name: "single increase in skipped reload count should return 1",
+			test: func(cm *Controller) {
+				cm.IncReloadSkippedCount()
+			},
+			want: `
+				# HELP nginx_ingress_controller_skipped_reloads Cumulative number of reloads skipped because the rendered configuration and the files it includes did not change
+				# TYPE nginx_ingress_controller_skipped_reloads counter
+				nginx_ingress_controller_skipped_reloads{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_skipped_reloads"},
+		},
 		{
 			name: "should set SSL certificates metrics",
 			test: func(cm *Controller) {
@@ -100,6 +119,229 @@ func TestControllerCounters(t *testing.T) {
 			`,
 			metrics: []string{"nginx_ingress_controller_ssl_expire_time_seconds"},
 		},
+		{
+			name: "should set the probe metrics",
+			test: func(cm *Controller) {
+				cm.SetProbe("demo", "/", true, 250*time.Millisecond)
+				cm.SetProbe("demo", "/missing", false, 10*time.Millisecond)
+			},
+			want: `
+				# HELP nginx_ingress_controller_probe_duration_seconds Duration of the last synthetic request sent through NGINX to a host and a path
+				# TYPE nginx_ingress_controller_probe_duration_seconds gauge
+				nginx_ingress_controller_probe_duration_seconds{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="demo",path="/"} 0.25
+				nginx_ingress_controller_probe_duration_seconds{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="demo",path="/missing"} 0.01
+				# HELP nginx_ingress_controller_probe_success Gauge reporting if the last synthetic request sent through NGINX to a host and a path succeeded, 1 indicates a success
+				# TYPE nginx_ingress_controller_probe_success gauge
+				nginx_ingress_controller_probe_success{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="demo",path="/"} 1
+				nginx_ingress_controller_probe_success{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="demo",path="/missing"} 0
+			`,
+			metrics: []string{"nginx_ingress_controller_probe_success", "nginx_ingress_controller_probe_duration_seconds"},
+		},
+		{
+			name: "should set the build date of the GeoIP2 databases",
+			test: func(cm *Controller) {
+				cm.SetGeoIPDatabase("GeoLite2-ASN", time.Date(2019, 12, 17, 0, 0, 0, 0, time.UTC))
+			},
+			want: `
+				# HELP nginx_ingress_controller_geoip_database_build_time_seconds Number of seconds since 1970 to the build date of the GeoIP2 databases downloaded by the controller. 'edition' is the MaxMind edition ID
+				# TYPE nginx_ingress_controller_geoip_database_build_time_seconds gauge
+				nginx_ingress_controller_geoip_database_build_time_seconds{controller_class="nginx",controller_namespace="default",controller_pod="pod",edition="GeoLite2-ASN"} 1.5765408e+09
+			`,
+			metrics: []string{"nginx_ingress_controller_geoip_database_build_time_seconds"},
+		},
+		{
+			name: "should count the ModSecurity audit entries",
+			test: func(cm *Controller) {
+				cm.IncModSecurityRuleHit("920350")
+				cm.IncModSecurityRuleHit("920350")
+				cm.AddModSecurityAuditEntries("read", 2)
+			},
+			want: `
+				# HELP nginx_ingress_controller_modsecurity_audit_entries_total Cumulative number of ModSecurity audit entries. 'state' is read, shipped or dropped
+				# TYPE nginx_ingress_controller_modsecurity_audit_entries_total counter
+				nginx_ingress_controller_modsecurity_audit_entries_total{controller_class="nginx",controller_namespace="default",controller_pod="pod",state="read"} 2
+				# HELP nginx_ingress_controller_modsecurity_rule_hits_total Cumulative number of ModSecurity audit entries matching a rule. 'rule_id' is the ID of the rule
+				# TYPE nginx_ingress_controller_modsecurity_rule_hits_total counter
+				nginx_ingress_controller_modsecurity_rule_hits_total{controller_class="nginx",controller_namespace="default",controller_pod="pod",rule_id="920350"} 2
+			`,
+			metrics: []string{"nginx_ingress_controller_modsecurity_rule_hits_total", "nginx_ingress_controller_modsecurity_audit_entries_total"},
+		},
+		{
+			name: "should replace the TLS fingerprints",
+			test: func(cm *Controller) {
+				cm.SetTLSFingerprints(map[string]int{"t13d1516h2_8daaf6152771_e5627efa2ab1": 3})
+				cm.SetTLSFingerprints(map[string]int{"t12i0704h1_0b6b1cf8ee27_b3a2d4a5e3b1": 5})
+			},
+			want: `
+				# HELP nginx_ingress_controller_tls_fingerprint_connections Number of TLS connections of the most frequent client fingerprints during the last minute. 'ja4' is the JA4 fingerprint
+				# TYPE nginx_ingress_controller_tls_fingerprint_connections gauge
+				nginx_ingress_controller_tls_fingerprint_connections{controller_class="nginx",controller_namespace="default",controller_pod="pod",ja4="t12i0704h1_0b6b1cf8ee27_b3a2d4a5e3b1"} 5
+			`,
+			metrics: []string{"nginx_ingress_controller_tls_fingerprint_connections"},
+		},
+		{
+			name: "should replace the SSL certificate usage",
+			test: func(cm *Controller) {
+				cm.SetSSLCertificateUsage([]string{"default/old-tls"}, nil, map[string]string{"foo.bar": "default/foo-tls"})
+				cm.SetSSLCertificateUsage([]string{"default/bar-tls"}, []string{"other/deleted-tls"}, map[string]string{})
+			},
+			want: `
+				# HELP nginx_ingress_controller_ssl_certificate_unused SSL certificates synced to disk not used anymore. 'state' is unreferenced when no Ingress references the secret, soon_orphaned when only Ingresses being deleted reference it
+				# TYPE nginx_ingress_controller_ssl_certificate_unused gauge
+				nginx_ingress_controller_ssl_certificate_unused{controller_class="nginx",controller_namespace="default",controller_pod="pod",namespace="default",secret_name="bar-tls",state="unreferenced"} 1
+				nginx_ingress_controller_ssl_certificate_unused{controller_class="nginx",controller_namespace="default",controller_pod="pod",namespace="other",secret_name="deleted-tls",state="soon_orphaned"} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_ssl_certificate_unused", "nginx_ingress_controller_ssl_default_certificate_host"},
+		},
+		{
+			name: "should set the hosts serving the default SSL certificate",
+			test: func(cm *Controller) {
+				cm.SetSSLCertificateUsage(nil, nil, map[string]string{"foo.bar": "default/foo-tls"})
+			},
+			want: `
+				# HELP nginx_ingress_controller_ssl_default_certificate_host Hosts serving the default SSL certificate although their Ingress references a secret
+				# TYPE nginx_ingress_controller_ssl_default_certificate_host gauge
+				nginx_ingress_controller_ssl_default_certificate_host{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="foo.bar",namespace="default",secret_name="foo-tls"} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_ssl_default_certificate_host"},
+		},
+		{
+			name: "should replace the expiration time of the CA certificates",
+			test: func(cm *Controller) {
+				root := &x509.Certificate{
+					Subject:      pkix.Name{CommonName: "root-ca"},
+					SerialNumber: big.NewInt(10),
+					NotAfter:     time.Unix(1600000000, 0),
+				}
+				intermediate := &x509.Certificate{
+					Subject:      pkix.Name{CommonName: "intermediate-ca"},
+					SerialNumber: big.NewInt(11),
+					NotAfter:     time.Unix(1500000000, 0),
+				}
+
+				old := &ingress.SSLCert{CACertificates: []*x509.Certificate{root}}
+				old.Namespace = "default"
+				old.Name = "old-ca"
+				cm.SetSSLCAExpireTime([]*ingress.SSLCert{old})
+
+				bundle := &ingress.SSLCert{CACertificates: []*x509.Certificate{intermediate, root}}
+				bundle.Namespace = "default"
+				bundle.Name = "client-ca"
+				cm.SetSSLCAExpireTime([]*ingress.SSLCert{bundle, {}})
+			},
+			want: `
+				# HELP nginx_ingress_controller_ssl_ca_expire_time_seconds Number of seconds since 1970 to the expiration of each certificate of the CA bundles. 'serial_number' is the hexadecimal serial number of the certificate
+				# TYPE nginx_ingress_controller_ssl_ca_expire_time_seconds gauge
+				nginx_ingress_controller_ssl_ca_expire_time_seconds{controller_class="nginx",controller_namespace="default",controller_pod="pod",namespace="default",secret_name="client-ca",serial_number="a",subject="CN=root-ca"} 1.6e+09
+				nginx_ingress_controller_ssl_ca_expire_time_seconds{controller_class="nginx",controller_namespace="default",controller_pod="pod",namespace="default",secret_name="client-ca",serial_number="b",subject="CN=intermediate-ca"} 1.5e+09
+			`,
+			metrics: []string{"nginx_ingress_controller_ssl_ca_expire_time_seconds"},
+		},
+		{
+			name: "should replace the expiration time of the SSL certificates",
+			test: func(cm *Controller) {
+				old := &ingress.SSLCert{ExpireTime: time.Unix(1400000000, 0)}
+				old.Namespace = "default"
+				old.Name = "deleted-tls"
+				cm.SetSSLCertificateExpireTime([]*ingress.SSLCert{old}, nil)
+
+				foo := &ingress.SSLCert{ExpireTime: time.Unix(1600000000, 0)}
+				foo.Namespace = "default"
+				foo.Name = "foo-tls"
+
+				unused := &ingress.SSLCert{ExpireTime: time.Unix(1500000000, 0)}
+				unused.Namespace = "other"
+				unused.Name = "unused-tls"
+
+				ca := &ingress.SSLCert{CACertificates: []*x509.Certificate{{}}}
+				ca.Namespace = "default"
+				ca.Name = "client-ca"
+
+				servers := []*ingress.Server{
+					{Hostname: "foo.bar", SSLCert: *foo},
+					{Hostname: "www.foo.bar", SSLCert: *foo},
+					{Hostname: "plain.bar"},
+				}
+				cm.SetSSLCertificateExpireTime([]*ingress.SSLCert{foo, unused, ca}, servers)
+			},
+			want: `
+				# HELP nginx_ingress_controller_ssl_certificate_expire_time_seconds Number of seconds since 1970 to the expiration of the certificate of each TLS secret, for each host serving it. 'host' is empty when no host serves the certificate
+				# TYPE nginx_ingress_controller_ssl_certificate_expire_time_seconds gauge
+				nginx_ingress_controller_ssl_certificate_expire_time_seconds{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="",namespace="other",secret_name="unused-tls"} 1.5e+09
+				nginx_ingress_controller_ssl_certificate_expire_time_seconds{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="foo.bar",namespace="default",secret_name="foo-tls"} 1.6e+09
+				nginx_ingress_controller_ssl_certificate_expire_time_seconds{controller_class="nginx",controller_namespace="default",controller_pod="pod",host="www.foo.bar",namespace="default",secret_name="foo-tls"} 1.6e+09
+			`,
+			metrics: []string{"nginx_ingress_controller_ssl_certificate_expire_time_seconds"},
+		},
+		{
+			name: "should count the stale SSL files",
+			test: func(cm *Controller) {
+				cm.AddSSLStaleFiles("removed", 2)
+				cm.AddSSLStaleFiles("removed", 1)
+			},
+			want: `
+				# HELP nginx_ingress_controller_ssl_stale_files_total Cumulative number of PEM files of the SSL directory no certificate uses anymore. 'action' is removed, or dry_run when the files are only logged
+				# TYPE nginx_ingress_controller_ssl_stale_files_total counter
+				nginx_ingress_controller_ssl_stale_files_total{action="removed",controller_class="nginx",controller_namespace="default",controller_pod="pod"} 3
+			`,
+			metrics: []string{"nginx_ingress_controller_ssl_stale_files_total"},
+		},
+		{
+			name: "should replace the inventory",
+			test: func(cm *Controller) {
+				cm.SetInventory([]inventory.Component{
+					{Kind: "module", Name: "ngx_http_geoip2_module", SHA256: "a1"},
+				})
+				cm.SetInventory([]inventory.Component{
+					{Kind: "plugin", Name: "hello_world/main.lua", SHA256: "b2"},
+					{Kind: "template", Name: "nginx.tmpl", SHA256: "c3"},
+				})
+			},
+			want: `
+				# HELP nginx_ingress_controller_inventory_info Modules, template and Lua code used by NGINX since the last reload. 'kind' is module, template, lua or plugin, 'sha256' is the checksum of the file
+				# TYPE nginx_ingress_controller_inventory_info gauge
+				nginx_ingress_controller_inventory_info{controller_class="nginx",controller_namespace="default",controller_pod="pod",kind="plugin",name="hello_world/main.lua",sha256="b2"} 1
+				nginx_ingress_controller_inventory_info{controller_class="nginx",controller_namespace="default",controller_pod="pod",kind="template",name="nginx.tmpl",sha256="c3"} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_inventory_info"},
+		},
+		{
+			name: "should replace the backends without endpoints",
+			test: func(cm *Controller) {
+				ing := &ingress.Ingress{
+					Ingress: networking.Ingress{
+						ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+					},
+				}
+
+				cm.SetBackendsWithoutEndpoints(&ingress.Configuration{
+					Backends: []*ingress.Backend{{Name: "default-api-80"}},
+					Servers: []*ingress.Server{{
+						Locations: []*ingress.Location{{Backend: "default-api-80", Ingress: ing}},
+					}},
+				})
+				cm.SetBackendsWithoutEndpoints(&ingress.Configuration{
+					Backends: []*ingress.Backend{
+						{Name: "upstream-default-backend"},
+						{Name: "default-web-80"},
+						{Name: "default-web-8080", Endpoints: []ingress.Endpoint{{Address: "10.0.0.1", Port: "8080"}}},
+					},
+					Servers: []*ingress.Server{{
+						Locations: []*ingress.Location{
+							{Path: "/", Backend: "upstream-default-backend", IsDefBackend: true},
+							{Path: "/app", Backend: "default-web-80", Ingress: ing},
+							{Path: "/api", Backend: "default-web-8080", Ingress: ing},
+						},
+					}},
+				})
+			},
+			want: `
+				# HELP nginx_ingress_controller_backend_without_endpoints Backends of the Ingresses without any endpoint, their requests are answered with a 503
+				# TYPE nginx_ingress_controller_backend_without_endpoints gauge
+				nginx_ingress_controller_backend_without_endpoints{backend="default-web-80",controller_class="nginx",controller_namespace="default",controller_pod="pod",ingress="web",namespace="default"} 1
+			`,
+			metrics: []string{"nginx_ingress_controller_backend_without_endpoints"},
+		},
 	}
 
 	for _, c := range cases {
@@ -17,11 +17,14 @@ limitations under the License.
 package collectors
 
 import (
+	"encoding/json"
 	"log"
+	"net/http"
 	"regexp"
 	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/ingress-nginx/internal/logging"
 	"k8s.io/ingress-nginx/internal/nginx"
 	"k8s.io/klog"
 )
@@ -34,6 +37,9 @@ var (
 	waiting = regexp.MustCompile(`Waiting: (\d+)`)
 )
 
+// usageSubSystem is the subsystem of the counters of the usage of each Ingress
+const usageSubSystem = "usage"
+
 type (
 	nginxStatusCollector struct {
 		scrapeChan chan scrapeRequest
@@ -45,6 +51,34 @@ type (
 		connectionsTotal *prometheus.Desc
 		requestsTotal    *prometheus.Desc
 		connections      *prometheus.Desc
+
+		accessEventsTotal         *prometheus.Desc
+		accessEventsFailedBatches *prometheus.Desc
+		accessEventsBuffered      *prometheus.Desc
+
+		usageRequestsTotal  *prometheus.Desc
+		usageBytesTotal     *prometheus.Desc
+		usageExportFailures *prometheus.Desc
+	}
+
+	// accessEventsStatus contains the counters of the access events module
+	accessEventsStatus struct {
+		Sent          int `json:"sent"`
+		Dropped       int `json:"dropped"`
+		FailedBatches int `json:"failedBatches"`
+		Buffered      int `json:"buffered"`
+	}
+
+	// usageStatus contains the counters of each Ingress of the usage module
+	usageStatus struct {
+		Ingresses []struct {
+			Namespace     string `json:"namespace"`
+			Ingress       string `json:"ingress"`
+			Requests      int64  `json:"requests"`
+			BytesReceived int64  `json:"bytesReceived"`
+			BytesSent     int64  `json:"bytesSent"`
+		} `json:"ingresses"`
+		FailedExports int `json:"failedExports"`
 	}
 
 	basicStatus struct {
@@ -101,6 +135,36 @@ func NewNGINXStatus(podName, namespace, ingressClass string) (NGINXStatusCollect
 			prometheus.BuildFQName(PrometheusNamespace, subSystem, "connections"),
 			"current number of client connections with state {active, reading, writing, waiting}",
 			[]string{"state"}, constLabels),
+
+		accessEventsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, subSystem, "access_events_total"),
+			"total number of access events with state {sent, dropped}",
+			[]string{"state"}, constLabels),
+
+		accessEventsFailedBatches: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, subSystem, "access_events_failed_batches_total"),
+			"total number of batches of access events that could not be sent",
+			nil, constLabels),
+
+		accessEventsBuffered: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, subSystem, "access_events_buffered"),
+			"current number of access events waiting to be sent",
+			nil, constLabels),
+
+		usageRequestsTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, usageSubSystem, "requests_total"),
+			"total number of requests of each Ingress",
+			[]string{"namespace", "ingress"}, constLabels),
+
+		usageBytesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, usageSubSystem, "bytes_total"),
+			"total number of bytes of each Ingress with direction {received, sent}",
+			[]string{"namespace", "ingress", "direction"}, constLabels),
+
+		usageExportFailures: prometheus.NewDesc(
+			prometheus.BuildFQName(PrometheusNamespace, usageSubSystem, "export_failures_total"),
+			"total number of exports of the usage that could not be sent",
+			nil, constLabels),
 	}
 
 	return p, nil
@@ -111,6 +175,12 @@ func (p nginxStatusCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- p.data.connectionsTotal
 	ch <- p.data.requestsTotal
 	ch <- p.data.connections
+	ch <- p.data.accessEventsTotal
+	ch <- p.data.accessEventsFailedBatches
+	ch <- p.data.accessEventsBuffered
+	ch <- p.data.usageRequestsTotal
+	ch <- p.data.usageBytesTotal
+	ch <- p.data.usageExportFailures
 }
 
 // Collect implements prometheus.Collector.
@@ -165,7 +235,7 @@ func parse(data string) *basicStatus {
 
 // nginxStatusCollector scrape the nginx status
 func (p nginxStatusCollector) scrape(ch chan<- prometheus.Metric) {
-	klog.V(3).Infof("start scraping socket: %v", nginx.StatusPath)
+	logging.V(3).Infof("start scraping socket: %v", nginx.StatusPath)
 	status, data, err := nginx.NewGetStatusRequest(nginx.StatusPath)
 	if err != nil {
 		log.Printf("%v", err)
@@ -194,4 +264,78 @@ func (p nginxStatusCollector) scrape(ch chan<- prometheus.Metric) {
 		prometheus.GaugeValue, float64(s.Writing), "writing")
 	ch <- prometheus.MustNewConstMetric(p.data.connections,
 		prometheus.GaugeValue, float64(s.Waiting), "waiting")
+
+	p.scrapeAccessEvents(ch)
+	p.scrapeUsage(ch)
+}
+
+// scrapeAccessEvents scrapes the counters of the access events module,
+// only available when an access events sink is configured
+func (p nginxStatusCollector) scrapeAccessEvents(ch chan<- prometheus.Metric) {
+	status, data, err := nginx.NewGetStatusRequest(nginx.AccessEventsStatusPath)
+	if err != nil {
+		klog.Warningf("unexpected error obtaining access events status info: %v", err)
+		return
+	}
+
+	if status == http.StatusNotFound {
+		return
+	}
+
+	if status < 200 || status >= 400 {
+		klog.Warningf("unexpected error obtaining access events status info (status %v)", status)
+		return
+	}
+
+	var s accessEventsStatus
+	if err := json.Unmarshal(data, &s); err != nil {
+		logging.V(3).Infof("unexpected access events status info: %v", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(p.data.accessEventsTotal,
+		prometheus.CounterValue, float64(s.Sent), "sent")
+	ch <- prometheus.MustNewConstMetric(p.data.accessEventsTotal,
+		prometheus.CounterValue, float64(s.Dropped), "dropped")
+	ch <- prometheus.MustNewConstMetric(p.data.accessEventsFailedBatches,
+		prometheus.CounterValue, float64(s.FailedBatches))
+	ch <- prometheus.MustNewConstMetric(p.data.accessEventsBuffered,
+		prometheus.GaugeValue, float64(s.Buffered))
+}
+
+// scrapeUsage scrapes the counters of each Ingress of the usage module,
+// only available when the usage accounting is enabled
+func (p nginxStatusCollector) scrapeUsage(ch chan<- prometheus.Metric) {
+	status, data, err := nginx.NewGetStatusRequest(nginx.UsageStatusPath)
+	if err != nil {
+		klog.Warningf("unexpected error obtaining usage status info: %v", err)
+		return
+	}
+
+	if status == http.StatusNotFound {
+		return
+	}
+
+	if status < 200 || status >= 400 {
+		klog.Warningf("unexpected error obtaining usage status info (status %v)", status)
+		return
+	}
+
+	var s usageStatus
+	if err := json.Unmarshal(data, &s); err != nil {
+		logging.V(3).Infof("unexpected usage status info: %v", err)
+		return
+	}
+
+	for _, i := range s.Ingresses {
+		ch <- prometheus.MustNewConstMetric(p.data.usageRequestsTotal,
+			prometheus.CounterValue, float64(i.Requests), i.Namespace, i.Ingress)
+		ch <- prometheus.MustNewConstMetric(p.data.usageBytesTotal,
+			prometheus.CounterValue, float64(i.BytesReceived), i.Namespace, i.Ingress, "received")
+		ch <- prometheus.MustNewConstMetric(p.data.usageBytesTotal,
+			prometheus.CounterValue, float64(i.BytesSent), i.Namespace, i.Ingress, "sent")
+	}
+
+	ch <- prometheus.MustNewConstMetric(p.data.usageExportFailures,
+		prometheus.CounterValue, float64(s.FailedExports))
 }
@@ -31,10 +31,12 @@ import (
 
 func TestStatusCollector(t *testing.T) {
 	cases := []struct {
-		name    string
-		mock    string
-		metrics []string
-		want    string
+		name         string
+		mock         string
+		accessEvents string
+		usage        string
+		metrics      []string
+		want         string
 	}{
 		{
 			name: "should return empty metrics",
@@ -93,6 +95,60 @@ func TestStatusCollector(t *testing.T) {
 				"nginx_ingress_controller_nginx_process_connections",
 			},
 		},
+		{
+			name: "should return access events metrics",
+			mock: `
+				Active connections: 15
+				server accepts handled requests
+				1 2 3
+				Reading: 4 Writing: 5 Waiting: 6
+			`,
+			accessEvents: `{"sent":120,"dropped":3,"failedBatches":2,"buffered":40}`,
+			want: `
+				# HELP nginx_ingress_controller_nginx_process_access_events_buffered current number of access events waiting to be sent
+				# TYPE nginx_ingress_controller_nginx_process_access_events_buffered gauge
+				nginx_ingress_controller_nginx_process_access_events_buffered{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 40
+				# HELP nginx_ingress_controller_nginx_process_access_events_failed_batches_total total number of batches of access events that could not be sent
+				# TYPE nginx_ingress_controller_nginx_process_access_events_failed_batches_total counter
+				nginx_ingress_controller_nginx_process_access_events_failed_batches_total{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 2
+				# HELP nginx_ingress_controller_nginx_process_access_events_total total number of access events with state {sent, dropped}
+				# TYPE nginx_ingress_controller_nginx_process_access_events_total counter
+				nginx_ingress_controller_nginx_process_access_events_total{controller_class="nginx",controller_namespace="default",controller_pod="pod",state="dropped"} 3
+				nginx_ingress_controller_nginx_process_access_events_total{controller_class="nginx",controller_namespace="default",controller_pod="pod",state="sent"} 120
+			`,
+			metrics: []string{
+				"nginx_ingress_controller_nginx_process_access_events_total",
+				"nginx_ingress_controller_nginx_process_access_events_failed_batches_total",
+				"nginx_ingress_controller_nginx_process_access_events_buffered",
+			},
+		},
+		{
+			name: "should return usage metrics",
+			mock: `
+				Active connections: 15
+				server accepts handled requests
+				1 2 3
+				Reading: 4 Writing: 5 Waiting: 6
+			`,
+			usage: `{"ingresses":[{"namespace":"shop","ingress":"web","requests":12,"bytesReceived":3400,"bytesSent":56000}],"failedExports":1}`,
+			want: `
+				# HELP nginx_ingress_controller_usage_bytes_total total number of bytes of each Ingress with direction {received, sent}
+				# TYPE nginx_ingress_controller_usage_bytes_total counter
+				nginx_ingress_controller_usage_bytes_total{controller_class="nginx",controller_namespace="default",controller_pod="pod",direction="received",ingress="web",namespace="shop"} 3400
+				nginx_ingress_controller_usage_bytes_total{controller_class="nginx",controller_namespace="default",controller_pod="pod",direction="sent",ingress="web",namespace="shop"} 56000
+				# HELP nginx_ingress_controller_usage_export_failures_total total number of exports of the usage that could not be sent
+				# TYPE nginx_ingress_controller_usage_export_failures_total counter
+				nginx_ingress_controller_usage_export_failures_total{controller_class="nginx",controller_namespace="default",controller_pod="pod"} 1
+				# HELP nginx_ingress_controller_usage_requests_total total number of requests of each Ingress
+				# TYPE nginx_ingress_controller_usage_requests_total counter
+				nginx_ingress_controller_usage_requests_total{controller_class="nginx",controller_namespace="default",controller_pod="pod",ingress="web",namespace="shop"} 12
+			`,
+			metrics: []string{
+				"nginx_ingress_controller_usage_requests_total",
+				"nginx_ingress_controller_usage_bytes_total",
+				"nginx_ingress_controller_usage_export_failures_total",
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -105,6 +161,28 @@ func TestStatusCollector(t *testing.T) {
 			server := &httptest.Server{
 				Listener: listener,
 				Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/access_events" {
+						if c.accessEvents == "" {
+							w.WriteHeader(http.StatusNotFound)
+							return
+						}
+
+						w.WriteHeader(http.StatusOK)
+						fmt.Fprint(w, c.accessEvents)
+						return
+					}
+
+					if r.URL.Path == "/usage" {
+						if c.usage == "" {
+							w.WriteHeader(http.StatusNotFound)
+							return
+						}
+
+						w.WriteHeader(http.StatusOK)
+						fmt.Fprint(w, c.usage)
+						return
+					}
+
 					w.WriteHeader(http.StatusOK)
 
 					if r.URL.Path == "/nginx_status" {
@@ -27,15 +27,38 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/logging"
 )
 
 type upstream struct {
 	Latency        float64 `json:"upstreamLatency"`
 	ResponseLength float64 `json:"upstreamResponseLength"`
 	ResponseTime   float64 `json:"upstreamResponseTime"`
+	// TLSConnectTimes contains the connect times, TLS handshake included, of
+	// the new connections with HTTPS and GRPCS upstream servers, retries included
+	TLSConnectTimes []float64 `json:"upstreamTLSConnectTimes"`
 	//Status         string  `json:"upstreamStatus"`
 }
 
+type buffering struct {
+	// RequestBodyTempFileSize is the size of a request body buffered to a temporary file
+	RequestBodyTempFileSize float64 `json:"requestBodyTempFileSize"`
+	// ProxyBuffersExcess is the part of a response exceeding the size of the proxy buffers
+	ProxyBuffersExcess float64 `json:"proxyBuffersExcess"`
+}
+
+// stream describes a session of a TCP or UDP service
+type stream struct {
+	// Protocol is only sent for the sessions of the stream services, TCP or UDP
+	Protocol string `json:"protocol"`
+	Port     string `json:"port"`
+
+	BytesSent     float64 `json:"bytesSent"`
+	BytesReceived float64 `json:"bytesReceived"`
+	SessionTime   float64 `json:"sessionTime"`
+}
+
 type socketData struct {
 	Host   string `json:"host"`
 	Status string `json:"status"`
@@ -48,11 +71,20 @@ type socketData struct {
 	RequestTime   float64 `json:"requestTime"`
 
 	upstream
+	buffering
+	stream
 
 	Namespace string `json:"namespace"`
 	Ingress   string `json:"ingress"`
 	Service   string `json:"service"`
 	Path      string `json:"path"`
+
+	Canary       string  `json:"canary"`
+	CanaryWeight float64 `json:"canaryWeight"`
+
+	// RequestNormalizations maps the request normalization rules applied
+	// to the request to their action, normalized or rejected
+	RequestNormalizations map[string]string `json:"requestNormalizations"`
 }
 
 // SocketCollector stores prometheus metrics and ingress meta-data
@@ -67,13 +99,32 @@ type SocketCollector struct {
 
 	upstreamLatency *prometheus.SummaryVec
 
+	upstreamTLSHandshakes      *prometheus.CounterVec
+	upstreamTLSConnectDuration *prometheus.HistogramVec
+
 	bytesSent *prometheus.HistogramVec
 
+	requestBodyTempFiles           *prometheus.CounterVec
+	requestBodyTempFileBytes       *prometheus.CounterVec
+	proxyBuffersOversizedResponses *prometheus.CounterVec
+	proxyBuffersOversizedBytes     *prometheus.CounterVec
+
+	requestNormalizations *prometheus.CounterVec
+
 	requests *prometheus.CounterVec
 
+	canaryWeight *prometheus.GaugeVec
+
+	streamSessions        *prometheus.CounterVec
+	streamSessionTime     *prometheus.HistogramVec
+	streamBytesSent       *prometheus.CounterVec
+	streamBytesReceived   *prometheus.CounterVec
+	streamUpstreamLatency *prometheus.HistogramVec
+
 	listener net.Listener
 
-	metricMapping map[string]interface{}
+	metricMapping       map[string]interface{}
+	streamMetricMapping map[string]interface{}
 
 	hosts sets.String
 
@@ -90,6 +141,24 @@ var (
 		"namespace",
 		"ingress",
 		"service",
+
+		"canary",
+	}
+
+	// the buffering metrics are attributed to the location
+	locationTags = []string{
+		"ingress",
+		"namespace",
+		"service",
+		"path",
+	}
+
+	// the stream metrics are attributed to the exposed service
+	streamTags = []string{
+		"protocol",
+		"namespace",
+		"service",
+		"port",
 	}
 )
 
@@ -169,7 +238,17 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*Soc
 				Namespace:   PrometheusNamespace,
 				ConstLabels: constLabels,
 			},
-			[]string{"ingress", "namespace", "status", "service"},
+			[]string{"ingress", "namespace", "status", "service", "canary"},
+		),
+
+		canaryWeight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "canary_weight",
+				Help:        "The canary weight used for the last routing decision",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"ingress", "namespace", "service"},
 		),
 
 		bytesSent: prometheus.NewHistogramVec(
@@ -183,6 +262,56 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*Soc
 			requestTags,
 		),
 
+		requestBodyTempFiles: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "request_body_temp_files",
+				Help:        "The number of request bodies larger than client-body-buffer-size buffered to a temporary file",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			locationTags,
+		),
+
+		requestBodyTempFileBytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "request_body_temp_file_bytes",
+				Help:        "The number of bytes of the request bodies buffered to a temporary file",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			locationTags,
+		),
+
+		proxyBuffersOversizedResponses: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "proxy_buffers_oversized_responses",
+				Help:        "The number of responses larger than the proxy buffers, which are buffered to a temporary file only when the client is slower than the upstream server",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			locationTags,
+		),
+
+		proxyBuffersOversizedBytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "proxy_buffers_oversized_bytes",
+				Help:        "The number of bytes of the responses exceeding the size of the proxy buffers",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			locationTags,
+		),
+
+		requestNormalizations: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "request_normalizations",
+				Help:        "The number of requests normalized or rejected by a request normalization rule",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			append(locationTags, "rule", "action"),
+		),
+
 		upstreamLatency: prometheus.NewSummaryVec(
 			prometheus.SummaryOpts{
 				Name:        "ingress_upstream_latency_seconds",
@@ -192,6 +321,79 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*Soc
 			},
 			[]string{"ingress", "namespace", "service"},
 		),
+
+		upstreamTLSHandshakes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "upstream_tls_handshakes",
+				Help:        "The number of new connections, including a TLS handshake, established with HTTPS and GRPCS upstream servers",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			[]string{"ingress", "namespace", "service"},
+		),
+
+		upstreamTLSConnectDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "upstream_tls_connect_duration_seconds",
+				Help:        "The time spent on establishing new connections with HTTPS and GRPCS upstream servers, TCP connect and TLS handshake",
+				Namespace:   PrometheusNamespace,
+				Buckets:     []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+				ConstLabels: constLabels,
+			},
+			[]string{"ingress", "namespace", "service"},
+		),
+
+		streamSessions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "stream_sessions",
+				Help:        "The total number of sessions of the TCP and UDP services by status, 200 if the session completed successfully",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			append(streamTags, "status"),
+		),
+
+		streamSessionTime: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "stream_session_duration_seconds",
+				Help:        "The duration of the sessions of the TCP and UDP services",
+				Namespace:   PrometheusNamespace,
+				Buckets:     prometheus.ExponentialBuckets(0.005, 4, 10), // 10 buckets from 5ms to about 22 minutes.
+				ConstLabels: constLabels,
+			},
+			streamTags,
+		),
+
+		streamBytesSent: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "stream_bytes_sent",
+				Help:        "The number of bytes sent to the clients of the TCP and UDP services",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			streamTags,
+		),
+
+		streamBytesReceived: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "stream_bytes_received",
+				Help:        "The number of bytes received from the clients of the TCP and UDP services",
+				Namespace:   PrometheusNamespace,
+				ConstLabels: constLabels,
+			},
+			streamTags,
+		),
+
+		streamUpstreamLatency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "stream_upstream_connect_duration_seconds",
+				Help:        "The time spent on connecting to the upstream server of the TCP and UDP services",
+				Namespace:   PrometheusNamespace,
+				Buckets:     []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+				ConstLabels: constLabels,
+			},
+			streamTags,
+		),
 	}
 
 	sc.metricMapping = map[string]interface{}{
@@ -203,14 +405,33 @@ func NewSocketCollector(pod, namespace, class string, metricsPerHost bool) (*Soc
 
 		prometheus.BuildFQName(PrometheusNamespace, "", "bytes_sent"): sc.bytesSent,
 
+		prometheus.BuildFQName(PrometheusNamespace, "", "request_body_temp_files"):           sc.requestBodyTempFiles,
+		prometheus.BuildFQName(PrometheusNamespace, "", "request_body_temp_file_bytes"):      sc.requestBodyTempFileBytes,
+		prometheus.BuildFQName(PrometheusNamespace, "", "proxy_buffers_oversized_responses"): sc.proxyBuffersOversizedResponses,
+		prometheus.BuildFQName(PrometheusNamespace, "", "proxy_buffers_oversized_bytes"):     sc.proxyBuffersOversizedBytes,
+		prometheus.BuildFQName(PrometheusNamespace, "", "request_normalizations"):            sc.requestNormalizations,
+
 		prometheus.BuildFQName(PrometheusNamespace, "", "ingress_upstream_latency_seconds"): sc.upstreamLatency,
+
+		prometheus.BuildFQName(PrometheusNamespace, "", "canary_weight"): sc.canaryWeight,
+
+		prometheus.BuildFQName(PrometheusNamespace, "", "upstream_tls_handshakes"):               sc.upstreamTLSHandshakes,
+		prometheus.BuildFQName(PrometheusNamespace, "", "upstream_tls_connect_duration_seconds"): sc.upstreamTLSConnectDuration,
+	}
+
+	sc.streamMetricMapping = map[string]interface{}{
+		prometheus.BuildFQName(PrometheusNamespace, "", "stream_sessions"):                          sc.streamSessions,
+		prometheus.BuildFQName(PrometheusNamespace, "", "stream_session_duration_seconds"):          sc.streamSessionTime,
+		prometheus.BuildFQName(PrometheusNamespace, "", "stream_bytes_sent"):                        sc.streamBytesSent,
+		prometheus.BuildFQName(PrometheusNamespace, "", "stream_bytes_received"):                    sc.streamBytesReceived,
+		prometheus.BuildFQName(PrometheusNamespace, "", "stream_upstream_connect_duration_seconds"): sc.streamUpstreamLatency,
 	}
 
 	return sc, nil
 }
 
 func (sc *SocketCollector) handleMessage(msg []byte) {
-	klog.V(5).Infof("msg: %v", string(msg))
+	logging.V(5).Infof("msg: %v", string(msg))
 
 	// Unmarshal bytes
 	var statsBatch []socketData
@@ -221,8 +442,13 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 	}
 
 	for _, stats := range statsBatch {
+		if stats.Protocol != "" {
+			sc.handleStreamSession(stats)
+			continue
+		}
+
 		if !sc.hosts.Has(stats.Host) {
-			klog.V(3).Infof("skiping metric for host %v that is not being served", stats.Host)
+			logging.V(3).Infof("skiping metric for host %v that is not being served", stats.Host)
 			continue
 		}
 
@@ -234,6 +460,7 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 			"namespace": stats.Namespace,
 			"ingress":   stats.Ingress,
 			"service":   stats.Service,
+			"canary":    stats.Canary,
 		}
 		if sc.metricsPerHost {
 			requestLabels["host"] = stats.Host
@@ -244,6 +471,7 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 			"ingress":   stats.Ingress,
 			"status":    stats.Status,
 			"service":   stats.Service,
+			"canary":    stats.Canary,
 		}
 
 		latencyLabels := prometheus.Labels{
@@ -252,6 +480,13 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 			"service":   stats.Service,
 		}
 
+		locationLabels := prometheus.Labels{
+			"namespace": stats.Namespace,
+			"ingress":   stats.Ingress,
+			"service":   stats.Service,
+			"path":      stats.Path,
+		}
+
 		requestsMetric, err := sc.requests.GetMetricWith(collectorLabels)
 		if err != nil {
 			klog.Errorf("Error fetching requests metric: %v", err)
@@ -268,6 +503,84 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 			}
 		}
 
+		// each new connection, one per attempt of a retried request, requires a TLS handshake
+		if len(stats.TLSConnectTimes) > 0 {
+			handshakesMetric, err := sc.upstreamTLSHandshakes.GetMetricWith(latencyLabels)
+			if err != nil {
+				klog.Errorf("Error fetching upstream TLS handshakes metric: %v", err)
+			} else {
+				handshakesMetric.Add(float64(len(stats.TLSConnectTimes)))
+			}
+
+			connectDurationMetric, err := sc.upstreamTLSConnectDuration.GetMetricWith(latencyLabels)
+			if err != nil {
+				klog.Errorf("Error fetching upstream TLS connect duration metric: %v", err)
+			} else {
+				for _, connectTime := range stats.TLSConnectTimes {
+					connectDurationMetric.Observe(connectTime)
+				}
+			}
+		}
+
+		if stats.RequestBodyTempFileSize > 0 {
+			tempFilesMetric, err := sc.requestBodyTempFiles.GetMetricWith(locationLabels)
+			if err != nil {
+				klog.Errorf("Error fetching request body temp files metric: %v", err)
+			} else {
+				tempFilesMetric.Inc()
+			}
+
+			tempFileBytesMetric, err := sc.requestBodyTempFileBytes.GetMetricWith(locationLabels)
+			if err != nil {
+				klog.Errorf("Error fetching request body temp file bytes metric: %v", err)
+			} else {
+				tempFileBytesMetric.Add(stats.RequestBodyTempFileSize)
+			}
+		}
+
+		if stats.ProxyBuffersExcess > 0 {
+			oversizedMetric, err := sc.proxyBuffersOversizedResponses.GetMetricWith(locationLabels)
+			if err != nil {
+				klog.Errorf("Error fetching proxy buffers oversized responses metric: %v", err)
+			} else {
+				oversizedMetric.Inc()
+			}
+
+			oversizedBytesMetric, err := sc.proxyBuffersOversizedBytes.GetMetricWith(locationLabels)
+			if err != nil {
+				klog.Errorf("Error fetching proxy buffers oversized bytes metric: %v", err)
+			} else {
+				oversizedBytesMetric.Add(stats.ProxyBuffersExcess)
+			}
+		}
+
+		for rule, action := range stats.RequestNormalizations {
+			normalizationLabels := prometheus.Labels{
+				"namespace": stats.Namespace,
+				"ingress":   stats.Ingress,
+				"service":   stats.Service,
+				"path":      stats.Path,
+				"rule":      rule,
+				"action":    action,
+			}
+
+			normalizationsMetric, err := sc.requestNormalizations.GetMetricWith(normalizationLabels)
+			if err != nil {
+				klog.Errorf("Error fetching request normalizations metric: %v", err)
+			} else {
+				normalizationsMetric.Inc()
+			}
+		}
+
+		if stats.Canary != "" && stats.CanaryWeight != -1 {
+			canaryWeightMetric, err := sc.canaryWeight.GetMetricWith(latencyLabels)
+			if err != nil {
+				klog.Errorf("Error fetching canary weight metric: %v", err)
+			} else {
+				canaryWeightMetric.Set(stats.CanaryWeight)
+			}
+		}
+
 		if stats.RequestTime != -1 {
 			requestTimeMetric, err := sc.requestTime.GetMetricWith(requestLabels)
 			if err != nil {
@@ -313,6 +626,65 @@ func (sc *SocketCollector) handleMessage(msg []byte) {
 	}
 }
 
+// handleStreamSession updates the metrics of the TCP and UDP services with a session
+func (sc *SocketCollector) handleStreamSession(stats socketData) {
+	streamLabels := prometheus.Labels{
+		"protocol":  stats.Protocol,
+		"namespace": stats.Namespace,
+		"service":   stats.Service,
+		"port":      stats.Port,
+	}
+
+	sessionsMetric, err := sc.streamSessions.GetMetricWith(prometheus.Labels{
+		"protocol":  stats.Protocol,
+		"namespace": stats.Namespace,
+		"service":   stats.Service,
+		"port":      stats.Port,
+		"status":    stats.Status,
+	})
+	if err != nil {
+		klog.Errorf("Error fetching stream sessions metric: %v", err)
+	} else {
+		sessionsMetric.Inc()
+	}
+
+	if stats.SessionTime != -1 {
+		sessionTimeMetric, err := sc.streamSessionTime.GetMetricWith(streamLabels)
+		if err != nil {
+			klog.Errorf("Error fetching stream session duration metric: %v", err)
+		} else {
+			sessionTimeMetric.Observe(stats.SessionTime)
+		}
+	}
+
+	if stats.BytesSent > 0 {
+		bytesSentMetric, err := sc.streamBytesSent.GetMetricWith(streamLabels)
+		if err != nil {
+			klog.Errorf("Error fetching stream bytes sent metric: %v", err)
+		} else {
+			bytesSentMetric.Add(stats.BytesSent)
+		}
+	}
+
+	if stats.BytesReceived > 0 {
+		bytesReceivedMetric, err := sc.streamBytesReceived.GetMetricWith(streamLabels)
+		if err != nil {
+			klog.Errorf("Error fetching stream bytes received metric: %v", err)
+		} else {
+			bytesReceivedMetric.Add(stats.BytesReceived)
+		}
+	}
+
+	if stats.Latency != -1 {
+		latencyMetric, err := sc.streamUpstreamLatency.GetMetricWith(streamLabels)
+		if err != nil {
+			klog.Errorf("Error fetching stream upstream connect duration metric: %v", err)
+		} else {
+			latencyMetric.Observe(stats.Latency)
+		}
+	}
+}
+
 // Start listen for connections in the unix socket and spawns a goroutine to process the content
 func (sc *SocketCollector) Start() {
 	for {
@@ -341,7 +713,7 @@ func (sc *SocketCollector) RemoveMetrics(ingresses []string, registry prometheus
 	}
 
 	// 1. remove metrics of removed ingresses
-	klog.V(2).Infof("removing ingresses %v from metrics", ingresses)
+	logging.V(2).Infof("removing ingresses %v from metrics", ingresses)
 	for _, mf := range mfs {
 		metricName := mf.GetName()
 		metric, ok := sc.metricMapping[metricName]
@@ -373,13 +745,13 @@ func (sc *SocketCollector) RemoveMetrics(ingresses []string, registry prometheus
 				continue
 			}
 
-			klog.V(2).Infof("Removing prometheus metric from histogram %v for ingress %v", metricName, ingKey)
+			logging.V(2).Infof("Removing prometheus metric from histogram %v for ingress %v", metricName, ingKey)
 
 			h, ok := metric.(*prometheus.HistogramVec)
 			if ok {
 				removed := h.Delete(labels)
 				if !removed {
-					klog.V(2).Infof("metric %v for ingress %v with labels not removed: %v", metricName, ingKey, labels)
+					logging.V(2).Infof("metric %v for ingress %v with labels not removed: %v", metricName, ingKey, labels)
 				}
 			}
 
@@ -387,7 +759,23 @@ func (sc *SocketCollector) RemoveMetrics(ingresses []string, registry prometheus
 			if ok {
 				removed := s.Delete(labels)
 				if !removed {
-					klog.V(2).Infof("metric %v for ingress %v with labels not removed: %v", metricName, ingKey, labels)
+					logging.V(2).Infof("metric %v for ingress %v with labels not removed: %v", metricName, ingKey, labels)
+				}
+			}
+
+			g, ok := metric.(*prometheus.GaugeVec)
+			if ok {
+				removed := g.Delete(labels)
+				if !removed {
+					logging.V(2).Infof("metric %v for ingress %v with labels not removed: %v", metricName, ingKey, labels)
+				}
+			}
+
+			c, ok := metric.(*prometheus.CounterVec)
+			if ok {
+				removed := c.Delete(labels)
+				if !removed {
+					logging.V(2).Infof("metric %v for ingress %v with labels not removed: %v", metricName, ingKey, labels)
 				}
 			}
 		}
@@ -395,6 +783,57 @@ func (sc *SocketCollector) RemoveMetrics(ingresses []string, registry prometheus
 
 }
 
+// RemoveStreamMetrics deletes the metrics of the TCP and UDP services not exposed
+// anymore, identified by protocol/namespace/service/port
+func (sc *SocketCollector) RemoveStreamMetrics(services []string, registry prometheus.Gatherer) {
+	if len(services) == 0 {
+		return
+	}
+
+	mfs, err := registry.Gather()
+	if err != nil {
+		klog.Errorf("Error gathering metrics: %v", err)
+		return
+	}
+
+	logging.V(2).Infof("removing stream services %v from metrics", services)
+	toRemove := sets.NewString(services...)
+	for _, mf := range mfs {
+		metricName := mf.GetName()
+		metric, ok := sc.streamMetricMapping[metricName]
+		if !ok {
+			continue
+		}
+
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string, len(m.GetLabel()))
+			for _, labelPair := range m.GetLabel() {
+				labels[*labelPair.Name] = *labelPair.Value
+			}
+
+			// remove labels that are constant
+			deleteConstants(labels)
+
+			key := fmt.Sprintf("%v/%v/%v/%v", labels["protocol"], labels["namespace"], labels["service"], labels["port"])
+			if !toRemove.Has(key) {
+				continue
+			}
+
+			removed := false
+			switch vec := metric.(type) {
+			case *prometheus.HistogramVec:
+				removed = vec.Delete(labels)
+			case *prometheus.CounterVec:
+				removed = vec.Delete(labels)
+			}
+
+			if !removed {
+				logging.V(2).Infof("metric %v for stream service %v with labels not removed: %v", metricName, key, labels)
+			}
+		}
+	}
+}
+
 // Describe implements prometheus.Collector
 func (sc SocketCollector) Describe(ch chan<- *prometheus.Desc) {
 	sc.requestTime.Describe(ch)
@@ -408,6 +847,23 @@ func (sc SocketCollector) Describe(ch chan<- *prometheus.Desc) {
 	sc.responseLength.Describe(ch)
 
 	sc.bytesSent.Describe(ch)
+
+	sc.requestBodyTempFiles.Describe(ch)
+	sc.requestBodyTempFileBytes.Describe(ch)
+	sc.proxyBuffersOversizedResponses.Describe(ch)
+	sc.proxyBuffersOversizedBytes.Describe(ch)
+	sc.requestNormalizations.Describe(ch)
+
+	sc.canaryWeight.Describe(ch)
+
+	sc.upstreamTLSHandshakes.Describe(ch)
+	sc.upstreamTLSConnectDuration.Describe(ch)
+
+	sc.streamSessions.Describe(ch)
+	sc.streamSessionTime.Describe(ch)
+	sc.streamBytesSent.Describe(ch)
+	sc.streamBytesReceived.Describe(ch)
+	sc.streamUpstreamLatency.Describe(ch)
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -423,6 +879,23 @@ func (sc SocketCollector) Collect(ch chan<- prometheus.Metric) {
 	sc.responseLength.Collect(ch)
 
 	sc.bytesSent.Collect(ch)
+
+	sc.requestBodyTempFiles.Collect(ch)
+	sc.requestBodyTempFileBytes.Collect(ch)
+	sc.proxyBuffersOversizedResponses.Collect(ch)
+	sc.proxyBuffersOversizedBytes.Collect(ch)
+	sc.requestNormalizations.Collect(ch)
+
+	sc.canaryWeight.Collect(ch)
+
+	sc.upstreamTLSHandshakes.Collect(ch)
+	sc.upstreamTLSConnectDuration.Collect(ch)
+
+	sc.streamSessions.Collect(ch)
+	sc.streamSessionTime.Collect(ch)
+	sc.streamBytesSent.Collect(ch)
+	sc.streamBytesReceived.Collect(ch)
+	sc.streamUpstreamLatency.Collect(ch)
 }
 
 // SetHosts sets the hostnames that are being served by the ingress controller
@@ -17,8 +17,11 @@ limitations under the License.
 package metric
 
 import (
+	"time"
+
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/ingress-nginx/internal/ingress"
+	"k8s.io/ingress-nginx/internal/inventory"
 )
 
 // NewDummyCollector returns a dummy metric collector
@@ -38,6 +41,9 @@ func (dc DummyCollector) IncReloadCount() {}
 // IncReloadErrorCount ...
 func (dc DummyCollector) IncReloadErrorCount() {}
 
+// IncReloadSkippedCount ...
+func (dc DummyCollector) IncReloadSkippedCount() {}
+
 // IncCheckCount ...
 func (dc DummyCollector) IncCheckCount(string, string) {}
 
@@ -47,6 +53,9 @@ func (dc DummyCollector) IncCheckErrorCount(string, string) {}
 // RemoveMetrics ...
 func (dc DummyCollector) RemoveMetrics(ingresses, endpoints []string) {}
 
+// RemoveStreamMetrics ...
+func (dc DummyCollector) RemoveStreamMetrics([]string) {}
+
 // Start ...
 func (dc DummyCollector) Start() {}
 
@@ -56,6 +65,15 @@ func (dc DummyCollector) Stop() {}
 // SetSSLExpireTime ...
 func (dc DummyCollector) SetSSLExpireTime([]*ingress.Server) {}
 
+// SetBackendsWithoutEndpoints ...
+func (dc DummyCollector) SetBackendsWithoutEndpoints(*ingress.Configuration) {}
+
+// SetSSLCAExpireTime ...
+func (dc DummyCollector) SetSSLCAExpireTime([]*ingress.SSLCert) {}
+
+// SetSSLCertificateExpireTime ...
+func (dc DummyCollector) SetSSLCertificateExpireTime([]*ingress.SSLCert, []*ingress.Server) {}
+
 // SetHosts ...
 func (dc DummyCollector) SetHosts(hosts sets.String) {}
 
@@ -64,3 +82,34 @@ func (dc DummyCollector) OnStartedLeading(electionID string) {}
 
 // OnStoppedLeading indicates the pod is not the current leader
 func (dc DummyCollector) OnStoppedLeading(electionID string) {}
+
+// SetLeaderTask ...
+func (dc DummyCollector) SetLeaderTask(task string, running bool) {}
+
+// SetProbe ...
+func (dc DummyCollector) SetProbe(host, path string, success bool, latency time.Duration) {}
+
+// SetConnectionsCapacity ...
+func (dc DummyCollector) SetConnectionsCapacity(capacity int) {}
+
+// SetInventory ...
+func (dc DummyCollector) SetInventory(components []inventory.Component) {}
+
+// SetGeoIPDatabase ...
+func (dc DummyCollector) SetGeoIPDatabase(edition string, buildDate time.Time) {}
+
+// IncModSecurityRuleHit ...
+func (dc DummyCollector) IncModSecurityRuleHit(ruleID string) {}
+
+// AddModSecurityAuditEntries ...
+func (dc DummyCollector) AddModSecurityAuditEntries(state string, count int) {}
+
+// SetTLSFingerprints ...
+func (dc DummyCollector) SetTLSFingerprints(connections map[string]int) {}
+
+// AddSSLStaleFiles ...
+func (dc DummyCollector) AddSSLStaleFiles(action string, count int) {}
+
+// SetSSLCertificateUsage ...
+func (dc DummyCollector) SetSSLCertificateUsage(unreferenced, soonOrphaned []string, defaultCertificateHosts map[string]string) {
+}
@@ -22,12 +22,13 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"k8s.io/klog"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/ingress-nginx/internal/ingress"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/class"
 	"k8s.io/ingress-nginx/internal/ingress/metric/collectors"
+	"k8s.io/ingress-nginx/internal/inventory"
+	"k8s.io/ingress-nginx/internal/logging"
 )
 
 // Collector defines the interface for a metric collector
@@ -36,16 +37,57 @@ type Collector interface {
 
 	IncReloadCount()
 	IncReloadErrorCount()
+	// IncReloadSkippedCount counts the reloads skipped because the
+	// configuration did not change
+	IncReloadSkippedCount()
 
 	OnStartedLeading(string)
 	OnStoppedLeading(string)
+	// SetLeaderTask indicates if the pod runs a task of the leader
+	SetLeaderTask(string, bool)
+
+	// SetProbe sets the result and the latency of the last probe of a host and a path
+	SetProbe(string, string, bool, time.Duration)
+
+	// SetConnectionsCapacity sets the maximum number of client connections of the NGINX workers
+	SetConnectionsCapacity(int)
+
+	// SetInventory sets the modules, the template and the Lua code used by NGINX
+	SetInventory([]inventory.Component)
+
+	// SetGeoIPDatabase sets the build date of a GeoIP2 database downloaded by the controller
+	SetGeoIPDatabase(string, time.Time)
+
+	// IncModSecurityRuleHit counts a ModSecurity audit entry matching a rule
+	IncModSecurityRuleHit(string)
+	// AddModSecurityAuditEntries counts the ModSecurity audit entries read, shipped or dropped
+	AddModSecurityAuditEntries(string, int)
+
+	// SetTLSFingerprints sets the number of connections of the most frequent TLS fingerprints
+	SetTLSFingerprints(map[string]int)
+
+	// SetSSLCertificateUsage sets the secrets synced to disk not used anymore
+	// and the hosts serving the default certificate unexpectedly
+	SetSSLCertificateUsage([]string, []string, map[string]string)
+	// AddSSLStaleFiles counts the PEM files no certificate uses anymore, removed or only logged
+	AddSSLStaleFiles(string, int)
 
 	IncCheckCount(string, string)
 	IncCheckErrorCount(string, string)
 
 	RemoveMetrics(ingresses, endpoints []string)
+	// RemoveStreamMetrics deletes the metrics of the TCP and UDP services not exposed anymore
+	RemoveStreamMetrics([]string)
 
 	SetSSLExpireTime([]*ingress.Server)
+	// SetSSLCAExpireTime sets the expiration time of the certificates of the CA bundles
+	SetSSLCAExpireTime([]*ingress.SSLCert)
+	// SetSSLCertificateExpireTime sets the expiration time of the certificates of
+	// the TLS secrets and the hosts of the servers using them
+	SetSSLCertificateExpireTime([]*ingress.SSLCert, []*ingress.Server)
+
+	// SetBackendsWithoutEndpoints sets the backends of the Ingresses without any endpoint
+	SetBackendsWithoutEndpoints(*ingress.Configuration)
 
 	// SetHosts sets the hostnames that are being served by the ingress controller
 	SetHosts(sets.String)
@@ -62,11 +104,15 @@ type collector struct {
 
 	socket *collectors.SocketCollector
 
-	registry *prometheus.Registry
+	autoscaling *collectors.Autoscaling
+
+	registry            *prometheus.Registry
+	autoscalingRegistry *prometheus.Registry
 }
 
-// NewCollector creates a new metric collector the for ingress controller
-func NewCollector(metricsPerHost bool, registry *prometheus.Registry) (Collector, error) {
+// NewCollector creates a new metric collector the for ingress controller.
+// The autoscaling signals are registered in their own registry.
+func NewCollector(metricsPerHost bool, registry, autoscalingRegistry *prometheus.Registry) (Collector, error) {
 	podNamespace := os.Getenv("POD_NAMESPACE")
 	if podNamespace == "" {
 		podNamespace = "default"
@@ -91,6 +137,11 @@ func NewCollector(metricsPerHost bool, registry *prometheus.Registry) (Collector
 
 	ic := collectors.NewController(podName, podNamespace, class.IngressClass)
 
+	as, err := collectors.NewAutoscaling(podName, podNamespace, class.IngressClass)
+	if err != nil {
+		return nil, err
+	}
+
 	return Collector(&collector{
 		nginxStatus:  nc,
 		nginxProcess: pc,
@@ -99,7 +150,10 @@ func NewCollector(metricsPerHost bool, registry *prometheus.Registry) (Collector
 
 		socket: s,
 
-		registry: registry,
+		autoscaling: as,
+
+		registry:            registry,
+		autoscalingRegistry: autoscalingRegistry,
 	}), nil
 }
 
@@ -123,16 +177,25 @@ func (c *collector) IncReloadErrorCount() {
 	c.ingressController.IncReloadErrorCount()
 }
 
+func (c *collector) IncReloadSkippedCount() {
+	c.ingressController.IncReloadSkippedCount()
+}
+
 func (c *collector) RemoveMetrics(ingresses, hosts []string) {
 	c.socket.RemoveMetrics(ingresses, c.registry)
 	c.ingressController.RemoveMetrics(hosts, c.registry)
 }
 
+func (c *collector) RemoveStreamMetrics(services []string) {
+	c.socket.RemoveStreamMetrics(services, c.registry)
+}
+
 func (c *collector) Start() {
 	c.registry.MustRegister(c.nginxStatus)
 	c.registry.MustRegister(c.nginxProcess)
 	c.registry.MustRegister(c.ingressController)
 	c.registry.MustRegister(c.socket)
+	c.autoscalingRegistry.MustRegister(c.autoscaling)
 
 	// the default nginx.conf does not contains
 	// a server section with the status port
@@ -142,6 +205,7 @@ func (c *collector) Start() {
 	}()
 	go c.nginxProcess.Start()
 	go c.socket.Start()
+	go c.autoscaling.Start()
 }
 
 func (c *collector) Stop() {
@@ -149,10 +213,12 @@ func (c *collector) Stop() {
 	c.registry.Unregister(c.nginxProcess)
 	c.registry.Unregister(c.ingressController)
 	c.registry.Unregister(c.socket)
+	c.autoscalingRegistry.Unregister(c.autoscaling)
 
 	c.nginxStatus.Stop()
 	c.nginxProcess.Stop()
 	c.socket.Stop()
+	c.autoscaling.Stop()
 }
 
 func (c *collector) SetSSLExpireTime(servers []*ingress.Server) {
@@ -160,10 +226,31 @@ func (c *collector) SetSSLExpireTime(servers []*ingress.Server) {
 		return
 	}
 
-	klog.V(2).Infof("Updating ssl expiration metrics.")
+	logging.V(2).Infof("Updating ssl expiration metrics.")
 	c.ingressController.SetSSLExpireTime(servers)
 }
 
+func (c *collector) SetSSLCAExpireTime(certs []*ingress.SSLCert) {
+	if !isLeader() {
+		return
+	}
+
+	c.ingressController.SetSSLCAExpireTime(certs)
+}
+
+func (c *collector) SetSSLCertificateExpireTime(certs []*ingress.SSLCert, servers []*ingress.Server) {
+	if !isLeader() {
+		return
+	}
+
+	c.ingressController.SetSSLCertificateExpireTime(certs, servers)
+}
+
+// SetBackendsWithoutEndpoints sets the backends of the Ingresses without any endpoint
+func (c *collector) SetBackendsWithoutEndpoints(cfg *ingress.Configuration) {
+	c.ingressController.SetBackendsWithoutEndpoints(cfg)
+}
+
 func (c *collector) SetHosts(hosts sets.String) {
 	c.socket.SetHosts(hosts)
 }
@@ -179,6 +266,59 @@ func (c *collector) OnStoppedLeading(electionID string) {
 	setLeader(false)
 	c.ingressController.OnStoppedLeading(electionID)
 	c.ingressController.RemoveAllSSLExpireMetrics(c.registry)
+	c.ingressController.SetSSLCAExpireTime(nil)
+	c.ingressController.SetSSLCertificateExpireTime(nil, nil)
+}
+
+// SetLeaderTask indicates if the pod runs a task of the leader
+func (c *collector) SetLeaderTask(task string, running bool) {
+	c.ingressController.SetLeaderTask(task, running)
+}
+
+// SetProbe sets the result and the latency of the last probe of a host and a path
+func (c *collector) SetProbe(host, path string, success bool, latency time.Duration) {
+	c.ingressController.SetProbe(host, path, success, latency)
+}
+
+// SetConnectionsCapacity sets the maximum number of client connections of the NGINX workers
+func (c *collector) SetConnectionsCapacity(capacity int) {
+	c.autoscaling.SetConnectionsCapacity(capacity)
+}
+
+// SetInventory sets the modules, the template and the Lua code used by NGINX
+func (c *collector) SetInventory(components []inventory.Component) {
+	c.ingressController.SetInventory(components)
+}
+
+// SetGeoIPDatabase sets the build date of a GeoIP2 database downloaded by the controller
+func (c *collector) SetGeoIPDatabase(edition string, buildDate time.Time) {
+	c.ingressController.SetGeoIPDatabase(edition, buildDate)
+}
+
+// IncModSecurityRuleHit counts a ModSecurity audit entry matching a rule
+func (c *collector) IncModSecurityRuleHit(ruleID string) {
+	c.ingressController.IncModSecurityRuleHit(ruleID)
+}
+
+// AddModSecurityAuditEntries counts the ModSecurity audit entries read, shipped or dropped
+func (c *collector) AddModSecurityAuditEntries(state string, count int) {
+	c.ingressController.AddModSecurityAuditEntries(state, count)
+}
+
+// SetTLSFingerprints sets the number of connections of the most frequent TLS fingerprints
+func (c *collector) SetTLSFingerprints(connections map[string]int) {
+	c.ingressController.SetTLSFingerprints(connections)
+}
+
+// AddSSLStaleFiles counts the PEM files no certificate uses anymore, removed or only logged
+func (c *collector) AddSSLStaleFiles(action string, count int) {
+	c.ingressController.AddSSLStaleFiles(action, count)
+}
+
+// SetSSLCertificateUsage sets the secrets synced to disk not used anymore
+// and the hosts serving the default certificate unexpectedly
+func (c *collector) SetSSLCertificateUsage(unreferenced, soonOrphaned []string, defaultCertificateHosts map[string]string) {
+	c.ingressController.SetSSLCertificateUsage(unreferenced, soonOrphaned, defaultCertificateHosts)
 }
 
 var (
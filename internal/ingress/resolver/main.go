@@ -37,6 +37,10 @@ type Resolver interface {
 	//   tls.key: contains the server key
 	GetAuthCertificate(string) (*AuthSSLCert, error)
 
+	// GetConfigMapCACertificate resolves a given configmap name into a CA certificate.
+	// The configmap must contain the key ca.crt
+	GetConfigMapCACertificate(string) (*AuthSSLCert, error)
+
 	// GetService searches for services containing the namespace and name using a the character /
 	GetService(string) (*apiv1.Service, error)
 }
@@ -43,6 +43,12 @@ func (m Mock) GetAuthCertificate(string) (*AuthSSLCert, error) {
 	return nil, nil
 }
 
+// GetConfigMapCACertificate resolves a given configmap name into a CA certificate.
+// The configmap must contain the key ca.crt
+func (m Mock) GetConfigMapCACertificate(string) (*AuthSSLCert, error) {
+	return nil, nil
+}
+
 // GetService searches for services contenating the namespace and name using a the character /
 func (m Mock) GetService(string) (*apiv1.Service, error) {
 	return nil, nil
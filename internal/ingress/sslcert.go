@@ -30,6 +30,8 @@ type SSLCert struct {
 	Certificate       *x509.Certificate `json:"certificate,omitempty"`
 	// CAFileName contains the path to the file with the root certificate
 	CAFileName string `json:"caFileName"`
+	// CASHA contains the sha1 of the CA file when it is not the pem file
+	CASHA string `json:"caSha,omitempty"`
 	// PemFileName contains the path to the file with the certificate and key concatenated
 	PemFileName string `json:"pemFileName"`
 	// PemSHA contains the sha1 of the pem file.
@@ -41,6 +43,34 @@ type SSLCert struct {
 	ExpireTime time.Time `json:"expires"`
 	// Pem encoded certificate and key concatenated
 	PemCertKey string `json:"pemCertKey,omitempty"`
+	// PemCertKeyFileName contains the path to the file with the certificate and
+	// key when PemCertKey is not kept in memory
+	PemCertKeyFileName string `json:"pemCertKeyFileName,omitempty"`
+	// PemCertKeyCompressed contains the certificate and key concatenated
+	// compressed with gzip when they are kept in memory without PemCertKey
+	PemCertKeyCompressed []byte `json:"-"`
+	// PemCertKeySHA contains the sha1 of the certificate and key concatenated.
+	// This is used to detect changes when the content is not kept in memory
+	PemCertKeySHA string `json:"pemCertKeySha,omitempty"`
+	// CACertificates contains all the certificates of the CA bundle, including
+	// the intermediate certificates of a partial chain
+	CACertificates []*x509.Certificate `json:"-"`
+	// CAExpireTime contains the earliest expiration of the certificates of the CA bundle
+	CAExpireTime time.Time `json:"caExpires,omitempty"`
+	// OCSPResponse contains the DER encoded OCSP response stapled to the certificate
+	OCSPResponse []byte `json:"ocspResponse,omitempty"`
+	// OCSPResponseFile contains the path to the file with the OCSP response
+	OCSPResponseFile string `json:"ocspResponseFile,omitempty"`
+	// OCSPResponseSHA contains the sha1 of the OCSP response.
+	// This is used to detect changes when the response is fetched again
+	OCSPResponseSHA string `json:"ocspResponseSha,omitempty"`
+	// OCSPRefreshTime contains when the OCSP response must be fetched again
+	OCSPRefreshTime time.Time `json:"-"`
+	// OCSPNextUpdate contains when the OCSP response expires
+	OCSPNextUpdate time.Time `json:"-"`
+	// ECDSA contains the ECDSA certificate and key of a Secret also containing an
+	// RSA certificate and key, served to the clients supporting ECDSA
+	ECDSA *SSLCert `json:"ecdsa,omitempty"`
 }
 
 // GetObjectKind implements the ObjectKind interface as a noop
@@ -50,5 +80,5 @@ func (s SSLCert) GetObjectKind() schema.ObjectKind {
 
 // HashInclude defines if a field should be used or not to calculate the hash
 func (s SSLCert) HashInclude(field string, v interface{}) (bool, error) {
-	return (field != "PemSHA" && field != "ExpireTime"), nil
+	return (field != "PemSHA" && field != "CASHA" && field != "ExpireTime" && field != "PemCertKeyCompressed" && field != "CACertificates" && field != "CAExpireTime" && field != "OCSPResponse" && field != "OCSPRefreshTime" && field != "OCSPNextUpdate"), nil
 }
@@ -25,17 +25,29 @@ import (
 	"k8s.io/ingress-nginx/internal/ingress/annotations/auth"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authreq"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/authtls"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/bodystreaming"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/bodytransform"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/botchallenge"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/canonicalpath"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/connection"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/cors"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/hostregex"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/influxdb"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ipwhitelist"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/log"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/luarestywaf"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/modsecurity"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/plainhttp"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/proxy"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/proxychain"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/ratelimit"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/redirect"
 	"k8s.io/ingress-nginx/internal/ingress/annotations/rewrite"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/secureupstream"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/timewindow"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/tlsfingerprint"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/trafficcapture"
+	"k8s.io/ingress-nginx/internal/ingress/annotations/upstreamhost"
 	"k8s.io/ingress-nginx/internal/ingress/resolver"
 )
 
@@ -106,6 +118,26 @@ type Backend struct {
 	// Contains a list of backends without servers that are associated with this backend.
 	// +optional
 	AlternativeBackends []string `json:"alternativeBackends,omitempty"`
+	// PodRoutingBy is the NGINX variable that contains the name or ordinal of the
+	// pod that must receive the request.
+	// +optional
+	PodRoutingBy string `json:"podRoutingBy,omitempty"`
+	// PodBackends maps pod names and ordinals to the backend containing only
+	// the endpoint of that pod. It is populated when PodRoutingBy is set.
+	// +optional
+	PodBackends map[string]string `json:"podBackends,omitempty"`
+	// Failover describes the backend receiving the requests when all the
+	// endpoints of this backend are down.
+	// +optional
+	Failover FailoverConfig `json:"failover,omitempty"`
+	// EndpointCondition is the condition of the pods of the endpoints, from
+	// the endpoint-condition annotation.
+	// +optional
+	EndpointCondition string `json:"endpointCondition,omitempty"`
+	// ServiceUpstream is true when the endpoint is the ClusterIP of the
+	// Service instead of its Endpoints.
+	// +optional
+	ServiceUpstream bool `json:"serviceUpstream,omitempty"`
 }
 
 // TrafficShapingPolicy describes the policies to put in place when a backend has no server and is used as an
@@ -159,6 +191,16 @@ type UpstreamHashByConfig struct {
 	UpstreamHashBySubsetSize int    `json:"upstream-hash-by-subset-size,omitempty"`
 }
 
+// FailoverConfig described setting from the failover-* annotations.
+type FailoverConfig struct {
+	// Backend is the name of the backend receiving the requests
+	Backend string `json:"backend,omitempty"`
+	// MaxFails is the number of consecutive failed requests marking the backend down
+	MaxFails int `json:"maxFails,omitempty"`
+	// FailTimeout is the number of seconds the backend is considered down
+	FailTimeout int `json:"failTimeout,omitempty"`
+}
+
 // Endpoint describes a kubernetes endpoint in a backend
 // +k8s:deepcopy-gen=true
 type Endpoint struct {
@@ -168,6 +210,10 @@ type Endpoint struct {
 	Port string `json:"port"`
 	// Target returns a reference to the object providing the endpoint
 	Target *apiv1.ObjectReference `json:"target,omitempty"`
+	// Weight is the share of the traffic sent to the endpoint, between 1 and 100,
+	// read from the endpoint-weight annotation of the pod. 0 means the default weight.
+	// +optional
+	Weight int `json:"weight,omitempty"`
 }
 
 // Server describes a website
@@ -183,8 +229,19 @@ type Server struct {
 	Locations []*Location `json:"locations,omitempty"`
 	// Alias return the alias of the server name
 	Alias string `json:"alias,omitempty"`
+	// HostRegex contains the regular expression matched against the
+	// Host header in addition to the server name
+	// +optional
+	HostRegex hostregex.Config `json:"hostRegex,omitempty"`
 	// RedirectFromToWWW returns if a redirect to/from prefix www is required
 	RedirectFromToWWW bool `json:"redirectFromToWWW,omitempty"`
+	// RedirectFromAliases contains the list of hosts redirected to the server
+	// +optional
+	RedirectFromAliases []string `json:"redirectFromAliases,omitempty"`
+	// RedirectFromAliasesCode returns the status code used in the redirects from
+	// the aliases
+	// +optional
+	RedirectFromAliasesCode int `json:"redirectFromAliasesCode,omitempty"`
 	// CertificateAuth indicates the this server requires mutual authentication
 	// +optional
 	CertificateAuth authtls.Config `json:"certificateAuth"`
@@ -193,8 +250,35 @@ type Server struct {
 	ServerSnippet string `json:"serverSnippet"`
 	// SSLCiphers returns list of ciphers to be enabled
 	SSLCiphers string `json:"sslCiphers,omitempty"`
+	// PlainHTTP describes the response to the plain HTTP requests sent to
+	// the HTTPS port of the server
+	// +optional
+	PlainHTTP plainhttp.Config `json:"plainHTTP,omitempty"`
+	// MaxConnections is the maximum number of concurrent connections of the
+	// server, replacing max-connections-per-host of the ConfigMap
+	// +optional
+	MaxConnections int `json:"maxConnections,omitempty"`
 	// AuthTLSError contains the reason why the access to a server should be denied
 	AuthTLSError string `json:"authTLSError,omitempty"`
+	// Listeners contains the additional HTTPS ports of the server with their
+	// own client certificate authentication, sorted by port
+	// +optional
+	Listeners []ServerListener `json:"listeners,omitempty"`
+	// ListenPort is the only HTTPS port of the server block generated for a
+	// listener. The server block listens on the default ports when it is 0.
+	// +optional
+	ListenPort int `json:"listenPort,omitempty"`
+	// Internal indicates the server only listens on the internal listener
+	// of the controller, set by the Ingresses with the internal annotation
+	// +optional
+	Internal bool `json:"internal,omitempty"`
+}
+
+// ServerListener describes an additional HTTPS port of a server, generating a
+// distinct server block bound to the port
+type ServerListener struct {
+	Port            int            `json:"port"`
+	CertificateAuth authtls.Config `json:"certificateAuth"`
 }
 
 // Location describes an URI inside a server.
@@ -233,6 +317,10 @@ type Location struct {
 	// vhost of the incoming request.
 	// +optional
 	UpstreamVhost string `json:"upstream-vhost"`
+	// UpstreamHost describes the Host header sent to the upstream servers,
+	// it takes precedence over UpstreamVhost
+	// +optional
+	UpstreamHost upstreamhost.Config `json:"upstreamHost"`
 	// BasicDigestAuth returns authentication configuration for
 	// an Ingress rule.
 	// +optional
@@ -250,6 +338,10 @@ type Location struct {
 	// EnableGlobalAuth indicates if the access to this location requires
 	// authentication using an external provider defined in controller's config
 	EnableGlobalAuth bool `json:"enableGlobalAuth"`
+	// CertificateAuthExcluded indicates the location does not require the
+	// client certificate of the server, see auth-exclude-paths
+	// +optional
+	CertificateAuthExcluded bool `json:"certificateAuthExcluded,omitempty"`
 	// HTTP2PushPreload allows to configure the HTTP2 Push Preload from backend
 	// original location.
 	// +optional
@@ -308,6 +400,38 @@ type Location struct {
 	// BackendProtocol indicates which protocol should be used to communicate with the service
 	// By default this is HTTP
 	BackendProtocol string `json:"backend-protocol"`
+	// ProxySSL contains the CA and the server name used to verify the
+	// certificate of HTTPS and GRPCS backends
+	// +optional
+	ProxySSL secureupstream.Config `json:"proxySSL"`
+	// ProxyChain contains the upstream proxy used to reach the backend
+	// +optional
+	ProxyChain proxychain.Config `json:"proxyChain"`
+	// BodyTransform contains the transformations applied to the request
+	// and response bodies
+	// +optional
+	BodyTransform bodytransform.Config `json:"bodyTransform"`
+	// BodyStreaming describes the streaming of the request bodies to the
+	// upstream without buffering
+	// +optional
+	BodyStreaming bodystreaming.Config `json:"bodyStreaming"`
+	// CanonicalPath describes the canonicalization of the paths of the requests
+	// +optional
+	CanonicalPath canonicalpath.Config `json:"canonicalPath"`
+	// TrafficCapture describes the capture of a sample of the requests
+	// used to replay the traffic
+	// +optional
+	TrafficCapture trafficcapture.Config `json:"trafficCapture"`
+	// BotChallenge describes the challenge issued to the suspicious clients
+	// +optional
+	BotChallenge botchallenge.Config `json:"botChallenge"`
+	// TLSFingerprint contains the TLS client fingerprints allowed or denied
+	// +optional
+	TLSFingerprint tlsfingerprint.Config `json:"tlsFingerprint"`
+	// TimeWindow describes the time windows during which the requests
+	// are allowed
+	// +optional
+	TimeWindow timewindow.Config `json:"timeWindow"`
 	// CustomHTTPErrors specifies the error codes that should be intercepted.
 	// +optional
 	CustomHTTPErrors []int `json:"custom-http-errors"`
@@ -351,6 +475,15 @@ type L4Backend struct {
 	Protocol  apiv1.Protocol     `json:"protocol"`
 	// +optional
 	ProxyProtocol ProxyProtocol `json:"proxyProtocol"`
+	// ProxyTimeout replaces proxy-stream-timeout for the UDP service
+	// +optional
+	ProxyTimeout string `json:"proxyTimeout,omitempty"`
+	// ProxyResponses replaces proxy-stream-responses for the UDP service
+	// +optional
+	ProxyResponses *int `json:"proxyResponses,omitempty"`
+	// Affinity sends the datagrams of a client to the same endpoint: source-ip or empty
+	// +optional
+	Affinity string `json:"affinity,omitempty"`
 }
 
 // ProxyProtocol describes the proxy protocol configuration
@@ -143,6 +143,27 @@ func (b1 *Backend) Equal(b2 *Backend) bool {
 		return false
 	}
 
+	if b1.PodRoutingBy != b2.PodRoutingBy {
+		return false
+	}
+	if len(b1.PodBackends) != len(b2.PodBackends) {
+		return false
+	}
+	for pod, backend := range b1.PodBackends {
+		if b2.PodBackends[pod] != backend {
+			return false
+		}
+	}
+	if b1.Failover != b2.Failover {
+		return false
+	}
+	if b1.EndpointCondition != b2.EndpointCondition {
+		return false
+	}
+	if b1.ServiceUpstream != b2.ServiceUpstream {
+		return false
+	}
+
 	return true
 }
 
@@ -223,6 +244,9 @@ func (e1 *Endpoint) Equal(e2 *Endpoint) bool {
 	if e1.Port != e2.Port {
 		return false
 	}
+	if e1.Weight != e2.Weight {
+		return false
+	}
 
 	if e1.Target != e2.Target {
 		if e1.Target == nil || e2.Target == nil {
@@ -277,9 +301,18 @@ func (s1 *Server) Equal(s2 *Server) bool {
 	if s1.Alias != s2.Alias {
 		return false
 	}
+	if !(&s1.HostRegex).Equal(&s2.HostRegex) {
+		return false
+	}
 	if s1.RedirectFromToWWW != s2.RedirectFromToWWW {
 		return false
 	}
+	if !sets.StringElementsMatch(s1.RedirectFromAliases, s2.RedirectFromAliases) {
+		return false
+	}
+	if s1.RedirectFromAliasesCode != s2.RedirectFromAliasesCode {
+		return false
+	}
 	if !(&s1.CertificateAuth).Equal(&s2.CertificateAuth) {
 		return false
 	}
@@ -289,9 +322,31 @@ func (s1 *Server) Equal(s2 *Server) bool {
 	if s1.SSLCiphers != s2.SSLCiphers {
 		return false
 	}
+	if !(&s1.PlainHTTP).Equal(&s2.PlainHTTP) {
+		return false
+	}
+	if s1.MaxConnections != s2.MaxConnections {
+		return false
+	}
 	if s1.AuthTLSError != s2.AuthTLSError {
 		return false
 	}
+	if s1.ListenPort != s2.ListenPort {
+		return false
+	}
+	if s1.Internal != s2.Internal {
+		return false
+	}
+
+	if len(s1.Listeners) != len(s2.Listeners) {
+		return false
+	}
+	for idx, l1 := range s1.Listeners {
+		l2 := s2.Listeners[idx]
+		if l1.Port != l2.Port || !(&l1.CertificateAuth).Equal(&l2.CertificateAuth) {
+			return false
+		}
+	}
 
 	if len(s1.Locations) != len(s2.Locations) {
 		return false
@@ -355,6 +410,9 @@ func (l1 *Location) Equal(l2 *Location) bool {
 	if l1.EnableGlobalAuth != l2.EnableGlobalAuth {
 		return false
 	}
+	if l1.CertificateAuthExcluded != l2.CertificateAuthExcluded {
+		return false
+	}
 	if l1.HTTP2PushPreload != l2.HTTP2PushPreload {
 		return false
 	}
@@ -385,6 +443,9 @@ func (l1 *Location) Equal(l2 *Location) bool {
 	if l1.UpstreamVhost != l2.UpstreamVhost {
 		return false
 	}
+	if !(&l1.UpstreamHost).Equal(&l2.UpstreamHost) {
+		return false
+	}
 	if l1.XForwardedPrefix != l2.XForwardedPrefix {
 		return false
 	}
@@ -406,6 +467,42 @@ func (l1 *Location) Equal(l2 *Location) bool {
 		return false
 	}
 
+	if !(&l1.ProxySSL).Equal(&l2.ProxySSL) {
+		return false
+	}
+
+	if !(&l1.ProxyChain).Equal(&l2.ProxyChain) {
+		return false
+	}
+
+	if !(&l1.BodyTransform).Equal(&l2.BodyTransform) {
+		return false
+	}
+
+	if !(&l1.BodyStreaming).Equal(&l2.BodyStreaming) {
+		return false
+	}
+
+	if !(&l1.CanonicalPath).Equal(&l2.CanonicalPath) {
+		return false
+	}
+
+	if !(&l1.TrafficCapture).Equal(&l2.TrafficCapture) {
+		return false
+	}
+
+	if !(&l1.BotChallenge).Equal(&l2.BotChallenge) {
+		return false
+	}
+
+	if !(&l1.TLSFingerprint).Equal(&l2.TLSFingerprint) {
+		return false
+	}
+
+	if !(&l1.TimeWindow).Equal(&l2.TimeWindow) {
+		return false
+	}
+
 	match := compareInts(l1.CustomHTTPErrors, l2.CustomHTTPErrors)
 	if !match {
 		return false
@@ -502,6 +599,18 @@ func (l4b1 *L4Backend) Equal(l4b2 *L4Backend) bool {
 	if l4b1.Protocol != l4b2.Protocol {
 		return false
 	}
+	if l4b1.ProxyTimeout != l4b2.ProxyTimeout {
+		return false
+	}
+	if (l4b1.ProxyResponses == nil) != (l4b2.ProxyResponses == nil) {
+		return false
+	}
+	if l4b1.ProxyResponses != nil && *l4b1.ProxyResponses != *l4b2.ProxyResponses {
+		return false
+	}
+	if l4b1.Affinity != l4b2.Affinity {
+		return false
+	}
 
 	return true
 }
@@ -526,6 +635,21 @@ func (s1 *SSLCert) Equal(s2 *SSLCert) bool {
 	if s1.PemCertKey != s2.PemCertKey {
 		return false
 	}
+	if s1.PemCertKeyFileName != s2.PemCertKeyFileName {
+		return false
+	}
+	if s1.PemCertKeySHA != s2.PemCertKeySHA {
+		return false
+	}
+	if s1.OCSPResponseFile != s2.OCSPResponseFile {
+		return false
+	}
+	if s1.OCSPResponseSHA != s2.OCSPResponseSHA {
+		return false
+	}
+	if !s1.ECDSA.Equal(s2.ECDSA) {
+		return false
+	}
 
 	match := sets.StringElementsMatch(s1.CN, s2.CN)
 	if !match {
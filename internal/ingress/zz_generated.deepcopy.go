@@ -49,6 +49,14 @@ func (in *Backend) DeepCopyInto(out *Backend) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.PodBackends != nil {
+		in, out := &in.PodBackends, &out.PodBackends
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	out.Failover = in.Failover
 	return
 }
 
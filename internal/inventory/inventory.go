@@ -0,0 +1,157 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+const (
+	// KindModule is a dynamic module loaded by NGINX
+	KindModule = "module"
+	// KindPlugin is a file of a Lua plugin
+	KindPlugin = "plugin"
+	// KindLua is a Lua module of the controller
+	KindLua = "lua"
+	// KindTemplate is the template of the NGINX configuration
+	KindTemplate = "template"
+)
+
+// the Lua plugins are installed in a directory each
+const pluginsDirectory = "plugins"
+
+// the tests of the Lua modules are not loaded by NGINX
+const testsDirectory = "test"
+
+var loadModuleRegex = regexp.MustCompile(`(?m)^\s*load_module\s+([^;\s]+)\s*;`)
+
+// Component is a piece of code used by NGINX
+type Component struct {
+	Kind string `json:"kind"`
+	// Name identifies the component: the name of a module or a plugin,
+	// the path of a Lua module relative to the Lua directory
+	Name string `json:"name"`
+	Path string `json:"path"`
+	// SHA256 is the checksum of the file, empty when the file can not be read
+	SHA256 string `json:"sha256"`
+}
+
+// Collect returns the dynamic modules loaded by the NGINX configuration,
+// the template of the configuration and the Lua modules and plugins of the
+// directory luaDirectory. The components are sorted by kind and name.
+func Collect(configuration, template, luaDirectory string) []Component {
+	components := []Component{
+		newComponent(KindTemplate, filepath.Base(template), template),
+	}
+
+	components = append(components, modules(configuration)...)
+	components = append(components, luaModules(luaDirectory)...)
+
+	sort.SliceStable(components, func(i, j int) bool {
+		if components[i].Kind != components[j].Kind {
+			return components[i].Kind < components[j].Kind
+		}
+		return components[i].Name < components[j].Name
+	})
+
+	return components
+}
+
+func modules(configuration string) []Component {
+	content, err := ioutil.ReadFile(configuration)
+	if err != nil {
+		klog.Warningf("Error reading NGINX configuration %v: %v", configuration, err)
+		return nil
+	}
+
+	var components []Component
+	for _, match := range loadModuleRegex.FindAllStringSubmatch(string(content), -1) {
+		path := match[1]
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		components = append(components, newComponent(KindModule, name, path))
+	}
+
+	return components
+}
+
+func luaModules(luaDirectory string) []Component {
+	var components []Component
+
+	err := filepath.Walk(luaDirectory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(luaDirectory, path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if name == testsDirectory {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(path) != ".lua" {
+			return nil
+		}
+
+		kind := KindLua
+		if strings.HasPrefix(name, pluginsDirectory+string(filepath.Separator)) {
+			kind = KindPlugin
+			name = strings.TrimPrefix(name, pluginsDirectory+string(filepath.Separator))
+		}
+
+		components = append(components, newComponent(kind, filepath.ToSlash(name), path))
+		return nil
+	})
+	if err != nil {
+		klog.Warningf("Error reading Lua modules in %v: %v", luaDirectory, err)
+	}
+
+	return components
+}
+
+func newComponent(kind, name, path string) Component {
+	c := Component{
+		Kind: kind,
+		Name: name,
+		Path: path,
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		klog.Warningf("Error reading %v %v: %v", kind, path, err)
+		return c
+	}
+
+	sum := sha256.Sum256(content)
+	c.SHA256 = hex.EncodeToString(sum[:])
+
+	return c
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inventory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) string {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unexpected error creating directory: %v", err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error writing file: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCollect(t *testing.T) {
+	dir, err := ioutil.TempDir("", "inventory")
+	if err != nil {
+		t.Fatalf("unexpected error creating temporal directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	module := filepath.Join(dir, "modules", "ngx_http_geoip2_module.so")
+	moduleSHA := writeFile(t, module, "module")
+
+	configuration := filepath.Join(dir, "nginx.conf")
+	writeFile(t, configuration, `
+load_module `+module+`;
+load_module /missing/ngx_http_opentracing_module.so;
+
+# load_module /commented/ngx_http_modsecurity_module.so;
+daemon off;
+`)
+
+	template := filepath.Join(dir, "template", "nginx.tmpl")
+	templateSHA := writeFile(t, template, "template")
+
+	lua := filepath.Join(dir, "lua")
+	balancerSHA := writeFile(t, filepath.Join(lua, "balancer.lua"), "balancer")
+	ewmaSHA := writeFile(t, filepath.Join(lua, "balancer", "ewma.lua"), "ewma")
+	pluginSHA := writeFile(t, filepath.Join(lua, "plugins", "hello_world", "main.lua"), "plugin")
+	writeFile(t, filepath.Join(lua, "test", "balancer_test.lua"), "test")
+	writeFile(t, filepath.Join(lua, "README.md"), "readme")
+
+	expected := []Component{
+		{Kind: KindLua, Name: "balancer.lua", Path: filepath.Join(lua, "balancer.lua"), SHA256: balancerSHA},
+		{Kind: KindLua, Name: "balancer/ewma.lua", Path: filepath.Join(lua, "balancer", "ewma.lua"), SHA256: ewmaSHA},
+		{Kind: KindModule, Name: "ngx_http_geoip2_module", Path: module, SHA256: moduleSHA},
+		{Kind: KindModule, Name: "ngx_http_opentracing_module", Path: "/missing/ngx_http_opentracing_module.so"},
+		{Kind: KindPlugin, Name: "hello_world/main.lua", Path: filepath.Join(lua, "plugins", "hello_world", "main.lua"), SHA256: pluginSHA},
+		{Kind: KindTemplate, Name: "nginx.tmpl", Path: template, SHA256: templateSHA},
+	}
+
+	components := Collect(configuration, template, lua)
+	if !reflect.DeepEqual(components, expected) {
+		t.Errorf("expected %+v but returned %+v", expected, components)
+	}
+}
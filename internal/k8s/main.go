@@ -24,6 +24,7 @@ import (
 	"k8s.io/klog"
 
 	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/version"
 	clientset "k8s.io/client-go/kubernetes"
@@ -137,3 +138,23 @@ func NetworkingIngressAvailable(client clientset.Interface) bool {
 
 	return runningVersion.AtLeast(version114)
 }
+
+// CustomResourceAvailable checks if the resource of the group version, i.e.
+// defined by a custom resource definition, is served by the API server
+func CustomResourceAvailable(client clientset.Interface, groupVersion, resource string) (bool, error) {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	for _, r := range resources.APIResources {
+		if r.Name == resource {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
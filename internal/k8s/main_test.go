@@ -22,6 +22,7 @@ import (
 
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	testclient "k8s.io/client-go/kubernetes/fake"
 )
 
@@ -240,3 +241,23 @@ func TestGetPodDetails(t *testing.T) {
 		t.Errorf("expected a PodInfo but returned nil")
 	}
 }
+
+func TestCustomResourceAvailable(t *testing.T) {
+	fkClient := testclient.NewSimpleClientset()
+	fkClient.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "nginx.ingress.kubernetes.io/v1alpha1",
+			APIResources: []metav1.APIResource{{Name: "hostdelegations"}},
+		},
+	}
+
+	available, err := CustomResourceAvailable(fkClient, "nginx.ingress.kubernetes.io/v1alpha1", "hostdelegations")
+	if err != nil || !available {
+		t.Errorf("expected hostdelegations to be available but returned %v (%v)", available, err)
+	}
+
+	available, err = CustomResourceAvailable(fkClient, "nginx.ingress.kubernetes.io/v1alpha1", "nginxingressclassparams")
+	if err != nil || available {
+		t.Errorf("expected nginxingressclassparams not to be available but returned %v (%v)", available, err)
+	}
+}
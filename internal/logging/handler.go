@@ -0,0 +1,106 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// Handler returns the verbosity of the components (GET) and changes the
+// verbosity of a component (PUT ?component=store&level=3). Without level,
+// the component uses the default verbosity again. With a duration
+// (duration=10m), the previous verbosity is restored at the end of it.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if err := update(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeLevels(w)
+}
+
+// ReadOnlyHandler returns the verbosity of the components (GET). It is
+// served on the status port, reachable without authentication.
+func ReadOnlyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed, use the dbg tool to change the verbosity", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeLevels(w)
+}
+
+func writeLevels(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Levels())
+}
+
+func update(r *http.Request) error {
+	component := r.URL.Query().Get("component")
+	if component == "" {
+		return fmt.Errorf("missing component")
+	}
+
+	value := r.URL.Query().Get("level")
+	if value == "" {
+		if component == Default {
+			return fmt.Errorf("missing level")
+		}
+		return ResetLevel(component)
+	}
+
+	level, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid level %v", value)
+	}
+
+	if value := r.URL.Query().Get("duration"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %v", value)
+		}
+
+		if err := SetLevelFor(component, klog.Level(level), duration); err != nil {
+			return err
+		}
+
+		klog.Infof("Changed the verbosity of the component %v to %v for %v", component, level, duration)
+		return nil
+	}
+
+	if err := SetLevel(component, klog.Level(level)); err != nil {
+		return err
+	}
+
+	klog.Infof("Changed the verbosity of the component %v to %v", component, level)
+	return nil
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// Entry is a log line in the JSON format
+type Entry struct {
+	Time      string `json:"time"`
+	Level     string `json:"level,omitempty"`
+	Component string `json:"component,omitempty"`
+	Caller    string `json:"caller,omitempty"`
+	Message   string `json:"message"`
+}
+
+var severities = map[byte]string{
+	'I': "info",
+	'W': "warning",
+	'E': "error",
+	'F': "fatal",
+}
+
+// the length of the header of klog, "Lmmdd hh:mm:ss.uuuuuu threadid "
+const headerLength = 30
+
+// jsonWriter converts the lines written by klog to JSON
+type jsonWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+	now  func() time.Time
+}
+
+// EnableJSON writes the logs in the JSON format, one object by line.
+// The fatal errors are also written to the standard error in the text
+// format by klog, followed by the stacks of the goroutines.
+func EnableJSON(w io.Writer) error {
+	for name, value := range map[string]string{
+		"logtostderr":     "false",
+		"alsologtostderr": "false",
+		"stderrthreshold": "FATAL",
+	} {
+		if err := flag.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	// klog writes the warnings and errors to the files of the lower
+	// severities too, each line is written once in the file of the infos
+	klog.SetOutputBySeverity("INFO", &jsonWriter{w: w, now: time.Now})
+	klog.SetOutputBySeverity("WARNING", ioutil.Discard)
+	klog.SetOutputBySeverity("ERROR", ioutil.Discard)
+	klog.SetOutputBySeverity("FATAL", ioutil.Discard)
+
+	return nil
+}
+
+// Write implements the io.Writer interface, it is called by klog from the
+// goroutine of the caller
+func (jw *jsonWriter) Write(data []byte) (int, error) {
+	entry := parseLine(string(data))
+	entry.Time = jw.now().UTC().Format(time.RFC3339Nano)
+	entry.Component = callerOfKlog()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	jw.lock.Lock()
+	defer jw.lock.Unlock()
+
+	if _, err := jw.w.Write(append(line, '\n')); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// parseLine returns the severity, the caller and the message of a line
+// formatted by klog, "Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg"
+func parseLine(line string) Entry {
+	line = strings.TrimRight(line, "\n")
+
+	if len(line) <= headerLength || line[5] != ' ' {
+		return Entry{Message: line}
+	}
+
+	severity, ok := severities[line[0]]
+	if !ok {
+		return Entry{Message: line}
+	}
+
+	end := strings.Index(line[headerLength:], "] ")
+	if end < 0 {
+		return Entry{Message: line}
+	}
+
+	return Entry{
+		Level:   severity,
+		Caller:  line[headerLength : headerLength+end],
+		Message: line[headerLength+end+2:],
+	}
+}
+
+// callerOfKlog returns the component of the first function of the stack
+// outside of klog and this package
+func callerOfKlog() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "k8s.io/klog.") &&
+			!strings.HasPrefix(frame.Function, packagePrefix+"internal/logging.") {
+			return component(frame.Function, frame.File)
+		}
+
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected Entry
+	}{
+		{
+			"I0614 10:00:00.123456   12345 store.go:123] Ingress default/app\n",
+			Entry{Level: "info", Caller: "store.go:123", Message: "Ingress default/app"},
+		},
+		{
+			"E0614 10:00:00.123456       1 nginx.go:80] Error reloading NGINX: exit status 1\n\nnginx: [emerg]\n",
+			Entry{Level: "error", Caller: "nginx.go:80", Message: "Error reloading NGINX: exit status 1\n\nnginx: [emerg]"},
+		},
+		{
+			"goroutine 1 [running]:\n",
+			Entry{Message: "goroutine 1 [running]:"},
+		},
+	}
+
+	for _, test := range tests {
+		if entry := parseLine(test.line); entry != test.expected {
+			t.Errorf("%q: expected %+v but got %+v", test.line, test.expected, entry)
+		}
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := &jsonWriter{
+		w:   &buf,
+		now: func() time.Time { return time.Date(2019, time.June, 14, 10, 0, 0, 0, time.UTC) },
+	}
+
+	w.Write([]byte("W0614 10:00:00.123456   12345 backend_ssl.go:60] Secret default/tls not found\n"))
+
+	entry := Entry{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := Entry{
+		Time:  "2019-06-14T10:00:00Z",
+		Level: "warning",
+		// the tests are part of the libraries
+		Component: "library",
+		Caller:    "backend_ssl.go:60",
+		Message:   "Secret default/tls not found",
+	}
+	if entry != expected {
+		t.Errorf("expected %+v but got %+v", expected, entry)
+	}
+}
@@ -0,0 +1,378 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// Default is the name used for the level of the components without
+	// their own level and of the libraries of the controller (-v)
+	Default = "default"
+
+	// the logs of the libraries of the controller
+	libraryComponent = "library"
+
+	packagePrefix = "k8s.io/ingress-nginx/"
+)
+
+// Components are the parts of the controller with their own verbosity
+var Components = []string{"controller", "nginx", "ssl", "store", "template"}
+
+// the packages of the components, the other packages of the controller are
+// part of the controller component
+var componentPackages = map[string]string{
+	"internal/ingress/controller/store":    "store",
+	"internal/net/ssl":                     "ssl",
+	"internal/ingress/controller/template": "template",
+	"internal/ingress/controller/process":  "nginx",
+	"internal/nginx":                       "nginx",
+}
+
+// the files of the controller package managing the NGINX process
+var componentFiles = map[string]string{
+	"nginx.go": "nginx",
+}
+
+var (
+	lock         sync.RWMutex
+	defaultLevel klog.Level
+	levels       = map[string]klog.Level{}
+
+	// the highest level, to skip the lookup of the component of the
+	// caller when the level is not enabled at all
+	maxLevel int32
+
+	// the component of each caller
+	callers sync.Map
+
+	revertLock sync.Mutex
+	// the changes of verbosity reverted after a while, by component
+	reverts = map[string]*revert{}
+)
+
+// revert restores the verbosity of a component changed temporarily
+type revert struct {
+	timer *time.Timer
+	// the verbosity before the first temporary change
+	level    klog.Level
+	override bool
+}
+
+// V reports whether the verbosity of the component of the caller is at
+// least the requested level. It replaces klog.V in the controller.
+func V(level klog.Level) klog.Verbose {
+	if int32(level) > atomic.LoadInt32(&maxLevel) {
+		return klog.Verbose(false)
+	}
+
+	pc, _, _, ok := runtime.Caller(1)
+	if !ok {
+		return klog.V(level)
+	}
+
+	component, ok := callers.Load(pc)
+	if !ok {
+		component = callerComponent(pc)
+		callers.Store(pc, component)
+	}
+
+	return klog.Verbose(level <= Level(component.(string)))
+}
+
+func callerComponent(pc uintptr) string {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return libraryComponent
+	}
+
+	file, _ := fn.FileLine(pc)
+	return component(fn.Name(), file)
+}
+
+// component returns the component of a function, in the form
+// <package path>.<name>, defined in the file
+func component(function, file string) string {
+	// the main package of the controller
+	if strings.HasPrefix(function, "main.") {
+		return "controller"
+	}
+
+	if !strings.HasPrefix(function, packagePrefix) {
+		return libraryComponent
+	}
+
+	pkg := strings.TrimPrefix(function, packagePrefix)
+	slash := strings.LastIndex(pkg, "/")
+	if dot := strings.Index(pkg[slash+1:], "."); dot >= 0 {
+		pkg = pkg[:slash+1+dot]
+	}
+
+	if c, ok := componentPackages[pkg]; ok {
+		return c
+	}
+
+	if pkg == "internal/ingress/controller" {
+		if c, ok := componentFiles[filepath.Base(file)]; ok {
+			return c
+		}
+	}
+
+	return "controller"
+}
+
+// Level returns the verbosity of a component
+func Level(component string) klog.Level {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if level, ok := levels[component]; ok {
+		return level
+	}
+
+	return defaultLevel
+}
+
+// Levels returns the verbosity of each component and the default one
+func Levels() map[string]klog.Level {
+	result := map[string]klog.Level{
+		Default: Level(Default),
+	}
+
+	for _, c := range Components {
+		result[c] = Level(c)
+	}
+
+	return result
+}
+
+// SetLevel changes the verbosity of a component, or the default verbosity
+// and the one of the libraries (-v)
+func SetLevel(component string, level klog.Level) error {
+	if err := setLevel(component, level); err != nil {
+		return err
+	}
+
+	cancelRevert(component)
+	return nil
+}
+
+// SetLevelFor changes the verbosity of a component during the duration,
+// then restores the verbosity it had before the change
+func SetLevelFor(component string, level klog.Level, duration time.Duration) error {
+	if duration <= 0 {
+		return fmt.Errorf("the duration must be positive")
+	}
+
+	revertLock.Lock()
+	defer revertLock.Unlock()
+
+	lock.RLock()
+	previous, override := levels[component]
+	if component == Default {
+		previous, override = defaultLevel, true
+	}
+	lock.RUnlock()
+
+	if err := setLevel(component, level); err != nil {
+		return err
+	}
+
+	// a new temporary change extends the previous one
+	if r, ok := reverts[component]; ok {
+		r.timer.Stop()
+		previous, override = r.level, r.override
+	}
+
+	r := &revert{level: previous, override: override}
+	r.timer = time.AfterFunc(duration, func() {
+		revertLock.Lock()
+		defer revertLock.Unlock()
+
+		if reverts[component] != r {
+			return
+		}
+		delete(reverts, component)
+
+		var err error
+		if r.override {
+			err = setLevel(component, r.level)
+		} else {
+			err = resetLevel(component)
+		}
+		if err != nil {
+			klog.Errorf("Unexpected error restoring the verbosity of the component %v: %v", component, err)
+			return
+		}
+
+		klog.Infof("Restored the verbosity of the component %v to %v", component, Level(component))
+	})
+	reverts[component] = r
+
+	return nil
+}
+
+// cancelRevert keeps the current verbosity of a component changed
+// temporarily
+func cancelRevert(component string) {
+	revertLock.Lock()
+	defer revertLock.Unlock()
+
+	if r, ok := reverts[component]; ok {
+		r.timer.Stop()
+		delete(reverts, component)
+	}
+}
+
+func setLevel(component string, level klog.Level) error {
+	if level < 0 {
+		return fmt.Errorf("the level must be a positive number or zero")
+	}
+
+	if component == Default {
+		// the flags of klog are not registered in the tests
+		if f := flag.Lookup("v"); f != nil {
+			if err := f.Value.Set(strconv.Itoa(int(level))); err != nil {
+				return err
+			}
+		}
+
+		lock.Lock()
+		defaultLevel = level
+		updateMaxLevel()
+		lock.Unlock()
+		return nil
+	}
+
+	if !isComponent(component) {
+		return fmt.Errorf("unknown component %v (%v)", component, strings.Join(Components, ", "))
+	}
+
+	lock.Lock()
+	levels[component] = level
+	updateMaxLevel()
+	lock.Unlock()
+	return nil
+}
+
+// ResetLevel uses the default verbosity for a component
+func ResetLevel(component string) error {
+	if err := resetLevel(component); err != nil {
+		return err
+	}
+
+	cancelRevert(component)
+	return nil
+}
+
+func resetLevel(component string) error {
+	if !isComponent(component) {
+		return fmt.Errorf("unknown component %v (%v)", component, strings.Join(Components, ", "))
+	}
+
+	lock.Lock()
+	delete(levels, component)
+	updateMaxLevel()
+	lock.Unlock()
+	return nil
+}
+
+// Configure reads the default verbosity from the -v flag and sets the
+// verbosity of the components, in the form "store=3,ssl=5"
+func Configure(spec string) error {
+	if f := flag.Lookup("v"); f != nil {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			if level, ok := getter.Get().(klog.Level); ok {
+				lock.Lock()
+				defaultLevel = level
+				updateMaxLevel()
+				lock.Unlock()
+			}
+		}
+	}
+
+	parsed, err := ParseLevels(spec)
+	if err != nil {
+		return err
+	}
+
+	for component, level := range parsed {
+		if err := SetLevel(component, level); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseLevels parses the verbosity of components, in the form "store=3,ssl=5"
+func ParseLevels(spec string) (map[string]klog.Level, error) {
+	parsed := map[string]klog.Level{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.Split(entry, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid format (component=level) found in '%v'", entry)
+		}
+
+		component := strings.TrimSpace(parts[0])
+		if !isComponent(component) {
+			return nil, fmt.Errorf("unknown component %v (%v)", component, strings.Join(Components, ", "))
+		}
+
+		level, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || level < 0 {
+			return nil, fmt.Errorf("invalid level in '%v'", entry)
+		}
+
+		parsed[component] = klog.Level(level)
+	}
+
+	return parsed, nil
+}
+
+func isComponent(component string) bool {
+	i := sort.SearchStrings(Components, component)
+	return i < len(Components) && Components[i] == component
+}
+
+// updateMaxLevel must be called with the lock held
+func updateMaxLevel() {
+	max := defaultLevel
+	for _, level := range levels {
+		if level > max {
+			max = level
+		}
+	}
+	atomic.StoreInt32(&maxLevel, int32(max))
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/klog"
+)
+
+func init() {
+	klog.InitFlags(nil)
+}
+
+func resetLevels() {
+	lock.Lock()
+	defaultLevel = 0
+	levels = map[string]klog.Level{}
+	updateMaxLevel()
+	lock.Unlock()
+
+	revertLock.Lock()
+	for component, r := range reverts {
+		r.timer.Stop()
+		delete(reverts, component)
+	}
+	revertLock.Unlock()
+}
+
+func TestComponent(t *testing.T) {
+	tests := []struct {
+		function  string
+		file      string
+		component string
+	}{
+		{"k8s.io/ingress-nginx/internal/ingress/controller/store.(*k8sStore).syncSecret", "backend_ssl.go", "store"},
+		{"k8s.io/ingress-nginx/internal/ingress/controller/store.New.func1", "store.go", "store"},
+		{"k8s.io/ingress-nginx/internal/net/ssl.CreateSSLCert", "ssl.go", "ssl"},
+		{"k8s.io/ingress-nginx/internal/ingress/controller/template.(*Template).Write", "template.go", "template"},
+		{"k8s.io/ingress-nginx/internal/ingress/controller.(*NGINXController).OnUpdate", "/go/src/k8s.io/ingress-nginx/internal/ingress/controller/nginx.go", "nginx"},
+		{"k8s.io/ingress-nginx/internal/ingress/controller/process.WaitUntilPortIsAvailable", "nginx.go", "nginx"},
+		{"k8s.io/ingress-nginx/internal/ingress/controller.(*NGINXController).syncIngress", "controller.go", "controller"},
+		{"k8s.io/ingress-nginx/internal/ingress/controller/store/secret.Get", "secret.go", "controller"},
+		{"main.main", "main.go", "controller"},
+		{"k8s.io/client-go/tools/cache.(*Reflector).ListAndWatch", "reflector.go", "library"},
+	}
+
+	for _, test := range tests {
+		if c := component(test.function, test.file); c != test.component {
+			t.Errorf("%v: expected the component %v but got %v", test.function, test.component, c)
+		}
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	levels, err := ParseLevels(" store=3, ssl=5,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(levels) != 2 || levels["store"] != 3 || levels["ssl"] != 5 {
+		t.Errorf("unexpected levels %v", levels)
+	}
+
+	for _, invalid := range []string{"store", "store=a", "store=-1", "unknown=2", "default=3"} {
+		if _, err := ParseLevels(invalid); err == nil {
+			t.Errorf("%v: expected an error", invalid)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	defer resetLevels()
+	resetLevels()
+
+	if V(1) {
+		t.Errorf("expected the level 1 to be disabled")
+	}
+
+	if err := SetLevel("store", 4); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the tests are part of the libraries
+	if V(1) {
+		t.Errorf("expected the level 1 to be disabled outside of the store")
+	}
+
+	if Level("store") != 4 || Level("ssl") != 0 {
+		t.Errorf("unexpected levels %v", Levels())
+	}
+
+	if err := SetLevel(Default, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !V(2) || V(3) {
+		t.Errorf("expected the default level 2")
+	}
+
+	if !klog.V(2) {
+		t.Errorf("expected the level of the libraries to be changed")
+	}
+
+	if err := ResetLevel("store"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if Level("store") != 2 {
+		t.Errorf("expected the default level for the store but got %v", Level("store"))
+	}
+
+	if err := SetLevel("unknown", 2); err == nil {
+		t.Errorf("expected an error with an unknown component")
+	}
+
+	if err := SetLevel(Default, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	defer resetLevels()
+	resetLevels()
+
+	tests := []struct {
+		method string
+		query  string
+		code   int
+	}{
+		{http.MethodPut, "component=template&level=5", http.StatusOK},
+		{http.MethodPut, "component=template&level=x", http.StatusBadRequest},
+		{http.MethodPut, "component=unknown&level=1", http.StatusBadRequest},
+		{http.MethodPut, "level=1", http.StatusBadRequest},
+		{http.MethodPut, "component=default", http.StatusBadRequest},
+		{http.MethodPut, "component=store&level=4&duration=1h", http.StatusOK},
+		{http.MethodPut, "component=store&level=4&duration=x", http.StatusBadRequest},
+		{http.MethodPut, "component=store&level=4&duration=-1m", http.StatusBadRequest},
+		{http.MethodPost, "component=template&level=1", http.StatusMethodNotAllowed},
+		{http.MethodGet, "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		Handler(w, httptest.NewRequest(test.method, "/log-levels?"+test.query, nil))
+		if w.Code != test.code {
+			t.Errorf("%v %v: expected the status %v but got %v", test.method, test.query, test.code, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodGet, "/log-levels", nil))
+
+	levels := map[string]int{}
+	if err := json.Unmarshal(w.Body.Bytes(), &levels); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if levels["template"] != 5 || levels["store"] != 4 || levels[Default] != 0 {
+		t.Errorf("unexpected levels %v", levels)
+	}
+
+	w = httptest.NewRecorder()
+	Handler(w, httptest.NewRequest(http.MethodPut, "/log-levels?component=template", nil))
+	if Level("template") != 0 {
+		t.Errorf("expected the default level for the template after a reset")
+	}
+}
+
+func TestReadOnlyHandler(t *testing.T) {
+	defer resetLevels()
+	resetLevels()
+
+	w := httptest.NewRecorder()
+	ReadOnlyHandler(w, httptest.NewRequest(http.MethodPut, "/log-levels?component=store&level=5", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected the status %v but got %v", http.StatusMethodNotAllowed, w.Code)
+	}
+	if Level("store") != 0 {
+		t.Errorf("expected the verbosity of the store to be unchanged")
+	}
+
+	w = httptest.NewRecorder()
+	ReadOnlyHandler(w, httptest.NewRequest(http.MethodGet, "/log-levels", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the status %v but got %v", http.StatusOK, w.Code)
+	}
+}
+
+func TestSetLevelFor(t *testing.T) {
+	defer resetLevels()
+	resetLevels()
+
+	if err := SetLevel("ssl", 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := SetLevelFor("ssl", 5, 50*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the second change extends the first one and restores the same level
+	if err := SetLevelFor("ssl", 6, 100*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := SetLevelFor("store", 5, 100*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := SetLevelFor("template", 5, 0); err == nil {
+		t.Errorf("expected an error without duration")
+	}
+
+	time.Sleep(75 * time.Millisecond)
+	if Level("ssl") != 6 || Level("store") != 5 {
+		t.Errorf("expected the temporary levels, got ssl=%v store=%v", Level("ssl"), Level("store"))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if Level("ssl") != 2 {
+		t.Errorf("expected the level 2 to be restored for ssl but got %v", Level("ssl"))
+	}
+	if Level("store") != 0 {
+		t.Errorf("expected the default level to be restored for the store but got %v", Level("store"))
+	}
+
+	// a permanent change cancels the revert
+	if err := SetLevelFor("nginx", 5, 50*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := SetLevel("nginx", 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	time.Sleep(75 * time.Millisecond)
+	if Level("nginx") != 3 {
+		t.Errorf("expected the level 3 to be kept for nginx but got %v", Level("nginx"))
+	}
+}
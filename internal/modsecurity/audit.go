@@ -0,0 +1,367 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package modsecurity reads the audit log written by ModSecurity to count
+// the rules matched by the requests and ship the audit entries to an HTTP
+// endpoint
+package modsecurity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/logging"
+)
+
+// AuditLog is the file ModSecurity writes the audit entries to, in the
+// JSON format with one entry by line
+const AuditLog = "/var/log/nginx/modsec_audit.json"
+
+const (
+	pollInterval = time.Second
+
+	// maxAuditLogSize is the size the audit log is truncated at once read
+	maxAuditLogSize = 64 * 1024 * 1024
+
+	// maxEntrySize is the maximum size of an audit entry, the longer
+	// entries are ignored
+	maxEntrySize = 1024 * 1024
+
+	// batchSize is the maximum number of entries sent at once to the sink
+	batchSize = 100
+	// bufferSize is the maximum number of entries waiting to be sent, the
+	// new entries are dropped when it is reached
+	bufferSize = 10000
+
+	// maxBackoff is the upper bound of the delay between two attempts to
+	// send a batch to an unavailable sink
+	maxBackoff = 30 * time.Second
+
+	sendTimeout = 10 * time.Second
+)
+
+const (
+	// Read counts the audit entries read in the audit log
+	Read = "read"
+	// Shipped counts the audit entries sent to the sink
+	Shipped = "shipped"
+	// Dropped counts the audit entries not sent because the sink is unavailable
+	Dropped = "dropped"
+)
+
+// Recorder records the metrics of the audit entries
+type Recorder interface {
+	// IncModSecurityRuleHit counts an audit entry matching a rule
+	IncModSecurityRuleHit(ruleID string)
+	// AddModSecurityAuditEntries counts the audit entries read, shipped or dropped
+	AddModSecurityAuditEntries(state string, count int)
+}
+
+// Config defines what is done with the audit entries
+type Config struct {
+	// Metrics counts the audit entries by rule ID
+	Metrics bool
+	// Sink is the HTTP endpoint the audit entries are sent to, as a JSON
+	// array. Empty disables the shipping.
+	Sink string
+}
+
+// Enabled returns true when the audit log must be read
+func (c Config) Enabled() bool {
+	return c.Metrics || c.Sink != ""
+}
+
+// entry contains the fields of an audit entry used by the metrics
+type entry struct {
+	Transaction struct {
+		Messages []struct {
+			Details struct {
+				RuleID string `json:"ruleId"`
+			} `json:"details"`
+		} `json:"messages"`
+	} `json:"transaction"`
+}
+
+// Auditor follows the audit log while the configuration is enabled
+type Auditor struct {
+	path     string
+	recorder Recorder
+	client   *http.Client
+
+	mu     sync.Mutex
+	config Config
+	stopCh chan struct{}
+
+	// the state of the audit log, only used by the running goroutine
+	offset  int64
+	partial []byte
+	// skipping is true while the rest of a too long entry is read
+	skipping bool
+
+	buffer  [][]byte
+	backoff time.Duration
+	retryAt time.Time
+}
+
+// NewAuditor creates an auditor of the audit log path, started by Sync
+func NewAuditor(path string, recorder Recorder) *Auditor {
+	return &Auditor{
+		path:     path,
+		recorder: recorder,
+		client:   &http.Client{Timeout: sendTimeout},
+	}
+}
+
+// Sync starts following the audit log when the configuration is enabled,
+// updates the configuration of a running auditor and stops it otherwise
+func (a *Auditor) Sync(cfg Config) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.config = cfg
+
+	if !cfg.Enabled() {
+		if a.stopCh != nil {
+			klog.Infof("Stopping the reader of the ModSecurity audit log")
+			close(a.stopCh)
+			a.stopCh = nil
+		}
+		return
+	}
+
+	if a.stopCh != nil {
+		return
+	}
+
+	klog.Infof("Starting the reader of the ModSecurity audit log %v", a.path)
+	a.stopCh = make(chan struct{})
+	go a.run(a.stopCh)
+}
+
+// Close stops following the audit log
+func (a *Auditor) Close() {
+	a.Sync(Config{})
+}
+
+func (a *Auditor) getConfig() Config {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.config
+}
+
+func (a *Auditor) run(stopCh chan struct{}) {
+	// the entries written before the start were already read by the
+	// previous process
+	a.offset, a.partial, a.skipping, a.buffer = 0, nil, false, nil
+	if info, err := os.Stat(a.path); err == nil {
+		a.offset = info.Size()
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case now := <-ticker.C:
+			cfg := a.getConfig()
+
+			err := a.poll(cfg)
+			if err != nil {
+				logging.V(2).Infof("Error reading the ModSecurity audit log: %v", err)
+			}
+
+			a.flush(cfg, now)
+		}
+	}
+}
+
+// poll reads the entries written since the last poll, and truncates the
+// audit log when it is too large. ModSecurity appends the entries, so the
+// next ones are written at the beginning of the file.
+func (a *Auditor) poll(cfg Config) error {
+	f, err := os.Open(a.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// the file was truncated or replaced
+	if info.Size() < a.offset {
+		a.offset, a.partial, a.skipping = 0, nil, false
+	}
+
+	_, err = f.Seek(a.offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			a.offset += int64(n)
+			a.consume(cfg, buf[:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.offset >= maxAuditLogSize {
+		logging.V(2).Infof("Truncating the ModSecurity audit log (%v bytes)", a.offset)
+		err = os.Truncate(a.path, 0)
+		if err != nil {
+			return err
+		}
+		a.offset, a.partial, a.skipping = 0, nil, false
+	}
+
+	return nil
+}
+
+// consume handles the complete lines of data, keeping the last partial line
+func (a *Auditor) consume(cfg Config, data []byte) {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			if !a.skipping && len(a.partial)+len(data) > maxEntrySize {
+				// the rest of the entry is ignored until the next line
+				a.skipping, a.partial = true, nil
+			}
+			if !a.skipping {
+				a.partial = append(a.partial, data...)
+			}
+			return
+		}
+
+		line := append(a.partial, data[:i]...)
+		skipped := a.skipping || len(line) > maxEntrySize
+		a.partial, a.skipping = nil, false
+		data = data[i+1:]
+
+		if len(line) > 0 && !skipped {
+			a.handle(cfg, line)
+		}
+	}
+}
+
+func (a *Auditor) handle(cfg Config, line []byte) {
+	e := entry{}
+	err := json.Unmarshal(line, &e)
+	if err != nil {
+		logging.V(3).Infof("Ignoring invalid ModSecurity audit entry: %v", err)
+		return
+	}
+
+	a.recorder.AddModSecurityAuditEntries(Read, 1)
+
+	if cfg.Metrics {
+		for _, message := range e.Transaction.Messages {
+			if message.Details.RuleID != "" {
+				a.recorder.IncModSecurityRuleHit(message.Details.RuleID)
+			}
+		}
+	}
+
+	if cfg.Sink == "" {
+		return
+	}
+
+	// backpressure: the entries are dropped while the sink can't keep up
+	if len(a.buffer) >= bufferSize {
+		a.recorder.AddModSecurityAuditEntries(Dropped, 1)
+		return
+	}
+
+	a.buffer = append(a.buffer, append([]byte{}, line...))
+}
+
+// flush sends the buffered entries in batches until the sink fails
+func (a *Auditor) flush(cfg Config, now time.Time) {
+	if cfg.Sink == "" {
+		a.buffer = nil
+		return
+	}
+
+	if len(a.buffer) == 0 || now.Before(a.retryAt) {
+		return
+	}
+
+	for len(a.buffer) > 0 {
+		count := batchSize
+		if len(a.buffer) < count {
+			count = len(a.buffer)
+		}
+
+		err := a.send(cfg.Sink, a.buffer[:count])
+		if err != nil {
+			a.backoff *= 2
+			if a.backoff < pollInterval {
+				a.backoff = pollInterval
+			}
+			if a.backoff > maxBackoff {
+				a.backoff = maxBackoff
+			}
+			a.retryAt = now.Add(a.backoff)
+
+			klog.Warningf("Error sending %v ModSecurity audit entries to %v, retrying in %v: %v", count, cfg.Sink, a.backoff, err)
+			return
+		}
+
+		a.buffer = a.buffer[count:]
+		a.backoff = 0
+		a.recorder.AddModSecurityAuditEntries(Shipped, count)
+	}
+}
+
+func (a *Auditor) send(sink string, entries [][]byte) error {
+	body := bytes.NewBufferString("[")
+	body.Write(bytes.Join(entries, []byte(",")))
+	body.WriteString("]")
+
+	resp, err := a.client.Post(sink, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package modsecurity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeRecorder struct {
+	mu      sync.Mutex
+	hits    map[string]int
+	entries map[string]int
+}
+
+func newFakeRecorder() *fakeRecorder {
+	return &fakeRecorder{hits: map[string]int{}, entries: map[string]int{}}
+}
+
+func (r *fakeRecorder) IncModSecurityRuleHit(ruleID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hits[ruleID]++
+}
+
+func (r *fakeRecorder) AddModSecurityAuditEntries(state string, count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[state] += count
+}
+
+func auditEntry(id string, rules ...string) string {
+	messages := []string{}
+	for _, rule := range rules {
+		messages = append(messages, fmt.Sprintf(`{"message":"rule %v","details":{"ruleId":%q,"severity":"2"}}`, rule, rule))
+	}
+
+	return fmt.Sprintf(`{"transaction":{"unique_id":%q,"request":{"method":"GET","uri":"/"},"messages":[%v]}}`, id, strings.Join(messages, ","))
+}
+
+func appendFile(t *testing.T, path, data string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func newTestAuditor(t *testing.T) (*Auditor, *fakeRecorder, string, func()) {
+	dir, err := ioutil.TempDir("", "modsecurity")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	recorder := newFakeRecorder()
+	path := filepath.Join(dir, "audit.json")
+	return NewAuditor(path, recorder), recorder, path, func() { os.RemoveAll(dir) }
+}
+
+func TestAuditorMetrics(t *testing.T) {
+	a, recorder, path, cleanup := newTestAuditor(t)
+	defer cleanup()
+
+	cfg := Config{Metrics: true}
+
+	// the audit log does not exist until the first entry
+	if err := a.poll(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	appendFile(t, path, auditEntry("1", "920350", "949110")+"\n"+auditEntry("2", "920350")+"\n{\"transaction\":")
+	if err := a.poll(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if recorder.hits["920350"] != 2 || recorder.hits["949110"] != 1 || recorder.entries[Read] != 2 {
+		t.Errorf("unexpected metrics: %v %v", recorder.hits, recorder.entries)
+	}
+
+	// the end of the partial entry
+	appendFile(t, path, "{}}\n")
+	a.poll(cfg)
+	if recorder.entries[Read] != 3 {
+		t.Errorf("expected the partial entry to be read once complete but read %v entries", recorder.entries[Read])
+	}
+
+	// the audit log was truncated
+	os.Truncate(path, 0)
+	appendFile(t, path, auditEntry("3", "930100")+"\n")
+	a.poll(cfg)
+	if recorder.hits["930100"] != 1 {
+		t.Errorf("expected the entries written after the truncation to be read: %v", recorder.hits)
+	}
+}
+
+func TestAuditorSkipsLongEntries(t *testing.T) {
+	a, recorder, path, cleanup := newTestAuditor(t)
+	defer cleanup()
+
+	cfg := Config{Metrics: true}
+	appendFile(t, path, `{"transaction":{"messages":[],"data":"`+strings.Repeat("a", maxEntrySize)+`"}}`+"\n"+auditEntry("1", "920350")+"\n")
+	a.poll(cfg)
+
+	if recorder.entries[Read] != 1 || recorder.hits["920350"] != 1 {
+		t.Errorf("expected only the short entry to be read: %v %v", recorder.hits, recorder.entries)
+	}
+}
+
+func TestAuditorShipping(t *testing.T) {
+	var mu sync.Mutex
+	received := []json.RawMessage{}
+	failing := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		batch := []json.RawMessage{}
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received = append(received, batch...)
+	}))
+	defer server.Close()
+
+	a, recorder, path, cleanup := newTestAuditor(t)
+	defer cleanup()
+
+	cfg := Config{Sink: server.URL}
+	entries := ""
+	for i := 0; i < batchSize+10; i++ {
+		entries += auditEntry(fmt.Sprintf("%v", i), "920350") + "\n"
+	}
+	appendFile(t, path, entries)
+	a.poll(cfg)
+
+	// the entries are kept while the sink is unavailable
+	now := time.Now()
+	a.flush(cfg, now)
+	if len(a.buffer) != batchSize+10 || a.backoff != pollInterval {
+		t.Errorf("expected the entries to be kept with a backoff of %v but buffered %v with %v", pollInterval, len(a.buffer), a.backoff)
+	}
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+
+	// no attempt before the end of the backoff
+	a.flush(cfg, now)
+	if len(a.buffer) != batchSize+10 {
+		t.Errorf("expected no attempt before the end of the backoff")
+	}
+
+	a.flush(cfg, now.Add(2*pollInterval))
+	if len(a.buffer) != 0 || len(received) != batchSize+10 || recorder.entries[Shipped] != batchSize+10 {
+		t.Errorf("expected the entries to be shipped but buffered %v, received %v", len(a.buffer), len(received))
+	}
+
+	if recorder.hits["920350"] != 0 {
+		t.Errorf("expected no rule metric when the metrics are disabled")
+	}
+}
+
+func TestAuditorSync(t *testing.T) {
+	a, _, _, cleanup := newTestAuditor(t)
+	defer cleanup()
+
+	a.Sync(Config{Metrics: true})
+	if a.stopCh == nil {
+		t.Fatalf("expected the auditor to be started")
+	}
+
+	a.Sync(Config{})
+	if a.stopCh != nil {
+		t.Errorf("expected the auditor to be stopped")
+	}
+}
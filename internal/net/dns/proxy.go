@@ -0,0 +1,788 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/logging"
+)
+
+const (
+	// UDP sends the queries to a plain DNS server
+	UDP = "udp"
+	// TLS sends the queries using DNS over TLS (RFC 7858)
+	TLS = "tls"
+	// HTTPS sends the queries using DNS over HTTPS (RFC 8484)
+	HTTPS = "https"
+
+	exchangeTimeout = 5 * time.Second
+	// idleTimeout is the time the TCP connections of the clients and the
+	// DNS over TLS connections to the servers are kept open without queries
+	idleTimeout = 10 * time.Second
+	// maxIdleConns is the number of DNS over TLS connections kept open for
+	// each server
+	maxIdleConns = 4
+
+	// maxMessageSize is the maximum size of a DNS message
+	maxMessageSize = 65535
+	// minUDPSize is the size of the UDP answers accepted by all the
+	// clients, larger sizes are announced with EDNS0 (RFC 6891)
+	minUDPSize = 512
+
+	headerSize    = 12
+	rcodeNXDomain = 3
+	typeSOA       = 6
+	typeOPT       = 41
+	flagTruncated = 1 << 9
+)
+
+// Upstream defines a DNS server the queries are forwarded to
+type Upstream struct {
+	// Protocol is udp, tls or https
+	Protocol string
+	// Address of the server in the format host:port, or the URL of the
+	// DNS over HTTPS endpoint
+	Address string
+	// ServerName is the name verified in the certificate of a DNS over TLS server
+	ServerName string
+}
+
+// ParseUpstream parses udp://<host>[:<port>], tls://<host>[:<port>][#<server name>]
+// and https://<host>[:<port>]/<path>
+func ParseUpstream(val string) (Upstream, error) {
+	u, err := url.Parse(strings.TrimSpace(val))
+	if err != nil {
+		return Upstream{}, fmt.Errorf("invalid resolver %v: %v", val, err)
+	}
+
+	if u.Hostname() == "" {
+		return Upstream{}, fmt.Errorf("invalid resolver %v: the host is missing", val)
+	}
+
+	switch u.Scheme {
+	case UDP, TLS:
+		if u.Path != "" || u.RawQuery != "" || u.User != nil {
+			return Upstream{}, fmt.Errorf("invalid resolver %v: only the host and the port are allowed", val)
+		}
+
+		port := u.Port()
+		if port == "" {
+			port = "53"
+			if u.Scheme == TLS {
+				port = "853"
+			}
+		}
+
+		upstream := Upstream{
+			Protocol: u.Scheme,
+			Address:  net.JoinHostPort(u.Hostname(), port),
+		}
+		if u.Scheme == TLS {
+			upstream.ServerName = u.Hostname()
+			if u.Fragment != "" {
+				upstream.ServerName = u.Fragment
+			}
+		} else if u.Fragment != "" {
+			return Upstream{}, fmt.Errorf("invalid resolver %v: the server name is only used by tls resolvers", val)
+		}
+
+		return upstream, nil
+	case HTTPS:
+		u.Fragment = ""
+		return Upstream{Protocol: HTTPS, Address: u.String()}, nil
+	}
+
+	return Upstream{}, fmt.Errorf("unsupported resolver protocol %v", u.Scheme)
+}
+
+func (u Upstream) String() string {
+	switch u.Protocol {
+	case HTTPS:
+		return u.Address
+	case TLS:
+		return fmt.Sprintf("%v://%v#%v", u.Protocol, u.Address, u.ServerName)
+	}
+
+	return fmt.Sprintf("%v://%v", u.Protocol, u.Address)
+}
+
+// Config defines the DNS servers the proxy forwards the queries to
+type Config struct {
+	// Upstreams resolve the names not included in a zone
+	Upstreams []Upstream
+	// Zones are the DNS servers resolving the names of a zone and its subdomains
+	Zones map[string][]Upstream
+	// NegativeTTL is the maximum TTL, in seconds, of the NXDOMAIN and
+	// empty answers. 0 keeps the TTL returned by the servers.
+	NegativeTTL uint32
+}
+
+// Enabled returns true when the queries must be sent to the proxy
+func (c Config) Enabled() bool {
+	return len(c.Upstreams) > 0 || len(c.Zones) > 0 || c.NegativeTTL > 0
+}
+
+// upstreams returns the servers of the longest zone containing name
+func (c Config) upstreams(name string) []Upstream {
+	zone := ""
+	for z := range c.Zones {
+		if len(z) <= len(zone) {
+			continue
+		}
+
+		if name == z || strings.HasSuffix(name, "."+z) {
+			zone = z
+		}
+	}
+
+	if zone != "" {
+		return c.Zones[zone]
+	}
+
+	return c.Upstreams
+}
+
+// Proxy receives the DNS queries sent to a local UDP and TCP socket by
+// NGINX and the controller and forwards them to the servers of the
+// configuration, using the protocols NGINX does not support (DNS over TLS
+// and HTTPS)
+type Proxy struct {
+	address string
+	// fallback resolves the names not included in the zones when the
+	// configuration does not define default servers
+	fallback []Upstream
+
+	mu       sync.RWMutex
+	config   Config
+	conn     net.PacketConn
+	listener net.Listener
+
+	// idle contains the open DNS over TLS connections of each server, nil
+	// when the proxy is stopped
+	idleMu sync.Mutex
+	idle   map[string][]idleConn
+
+	client *http.Client
+}
+
+type idleConn struct {
+	conn  net.Conn
+	since time.Time
+}
+
+// NewProxy creates a proxy listening on address once started by Sync.
+// The nameservers are used when the configuration does not define
+// default servers.
+func NewProxy(address string, nameservers []net.IP) *Proxy {
+	fallback := []Upstream{}
+	for _, ns := range nameservers {
+		fallback = append(fallback, Upstream{
+			Protocol: UDP,
+			Address:  net.JoinHostPort(ns.String(), "53"),
+		})
+	}
+
+	return &Proxy{
+		address:  address,
+		fallback: fallback,
+		client:   &http.Client{Timeout: exchangeTimeout},
+	}
+}
+
+// Address returns the address of the socket the queries must be sent to
+func (p *Proxy) Address() string {
+	return p.address
+}
+
+// Sync starts the proxy when the configuration is enabled, updates the
+// configuration of a running proxy and stops it otherwise
+func (p *Proxy) Sync(cfg Config) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	enabled := cfg.Enabled()
+	if len(cfg.Upstreams) == 0 {
+		cfg.Upstreams = p.fallback
+	}
+	p.config = cfg
+
+	if !enabled {
+		if p.conn != nil {
+			klog.Infof("Stopping DNS proxy on %v", p.address)
+			p.conn.Close()
+			p.listener.Close()
+			p.conn = nil
+			p.listener = nil
+			p.closeIdle()
+		}
+		return nil
+	}
+
+	if p.conn != nil {
+		return nil
+	}
+
+	conn, err := net.ListenPacket("udp", p.address)
+	if err != nil {
+		return fmt.Errorf("error starting DNS proxy on %v: %v", p.address, err)
+	}
+
+	listener, err := net.Listen("tcp", p.address)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error starting DNS proxy on %v: %v", p.address, err)
+	}
+
+	klog.Infof("Starting DNS proxy on %v", p.address)
+	p.conn = conn
+	p.listener = listener
+
+	p.idleMu.Lock()
+	p.idle = map[string][]idleConn{}
+	p.idleMu.Unlock()
+
+	go p.run(conn)
+	go p.runTCP(listener)
+
+	return nil
+}
+
+// Close stops the proxy
+func (p *Proxy) Close() {
+	p.Sync(Config{})
+}
+
+func (p *Proxy) run(conn net.PacketConn) {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		query := make([]byte, n)
+		copy(query, buf[:n])
+		go p.serve(conn, addr, query)
+	}
+}
+
+func (p *Proxy) serve(conn net.PacketConn, addr net.Addr, query []byte) {
+	resp := p.resolve(addr, query)
+	if resp == nil {
+		return
+	}
+
+	conn.WriteTo(truncate(resp, udpSize(query)), addr)
+}
+
+func (p *Proxy) runTCP(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go p.serveTCP(conn)
+	}
+}
+
+// serveTCP answers the queries of a client until it closes the connection
+// or stops sending queries (RFC 7766)
+func (p *Proxy) serveTCP(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		conn.SetDeadline(time.Now().Add(idleTimeout))
+		query, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+
+		resp := p.resolve(conn.RemoteAddr(), query)
+		if resp == nil {
+			return
+		}
+
+		err = writeFrame(conn, resp)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// resolve returns the answer of the first server answering the query, or
+// nil when the query is invalid or no server answered
+func (p *Proxy) resolve(addr net.Addr, query []byte) []byte {
+	name, err := questionName(query)
+	if err != nil {
+		logging.V(3).Infof("Invalid DNS query from %v: %v", addr, err)
+		return nil
+	}
+
+	p.mu.RLock()
+	cfg := p.config
+	p.mu.RUnlock()
+
+	for _, upstream := range cfg.upstreams(name) {
+		resp, err := p.exchange(upstream, query)
+		if err != nil {
+			logging.V(2).Infof("Error resolving %v with %v: %v", name, upstream, err)
+			continue
+		}
+
+		if cfg.NegativeTTL > 0 {
+			capNegativeTTL(resp, cfg.NegativeTTL)
+		}
+
+		return resp
+	}
+
+	klog.Warningf("Error resolving %v: no DNS server answered", name)
+	return nil
+}
+
+// exchange sends the query to the server and returns the answer with the
+// ID of the query
+func (p *Proxy) exchange(upstream Upstream, query []byte) ([]byte, error) {
+	id := binary.BigEndian.Uint16(query)
+
+	msg := make([]byte, len(query))
+	copy(msg, query)
+
+	var resp []byte
+	var err error
+	switch upstream.Protocol {
+	case UDP:
+		resp, err = exchangeUDP(upstream, msg)
+		if err == nil && binary.BigEndian.Uint16(resp[2:])&flagTruncated != 0 {
+			resp, err = exchangeTCP(upstream, msg)
+		}
+	case TLS:
+		resp, err = p.exchangeTLS(upstream, msg)
+	case HTTPS:
+		// the ID must be 0 so the answers can be cached by HTTP proxies
+		binary.BigEndian.PutUint16(msg, 0)
+		resp, err = p.exchangeHTTPS(upstream, msg)
+	default:
+		err = fmt.Errorf("unsupported protocol %v", upstream.Protocol)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp) < headerSize {
+		return nil, fmt.Errorf("invalid answer of %v bytes", len(resp))
+	}
+
+	binary.BigEndian.PutUint16(resp, id)
+	return resp, nil
+}
+
+func exchangeUDP(upstream Upstream, msg []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", upstream.Address, exchangeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(exchangeTimeout))
+	_, err = conn.Write(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+
+		// ignore the answers to other queries
+		if n >= headerSize && bytes.Equal(buf[:2], msg[:2]) {
+			return buf[:n], nil
+		}
+	}
+}
+
+// exchangeTCP sends the query over a new TCP connection, used when the
+// answer of a UDP server is truncated
+func exchangeTCP(upstream Upstream, msg []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", upstream.Address, exchangeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return exchangeConn(conn, msg)
+}
+
+// exchangeTLS sends the query over an idle connection to the server, or a
+// new one when there is none. The connection is kept open for the next
+// queries once answered.
+func (p *Proxy) exchangeTLS(upstream Upstream, msg []byte) ([]byte, error) {
+	conn := p.idleConn(upstream)
+	if conn != nil {
+		resp, err := exchangeConn(conn, msg)
+		if err == nil {
+			p.releaseConn(upstream, conn)
+			return resp, nil
+		}
+
+		// the server may have closed the connection
+		conn.Close()
+	}
+
+	dialer := &net.Dialer{Timeout: exchangeTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", upstream.Address, &tls.Config{ServerName: upstream.ServerName})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := exchangeConn(conn, msg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	p.releaseConn(upstream, conn)
+	return resp, nil
+}
+
+// idleConn returns an open connection to the server, or nil when there is
+// none
+func (p *Proxy) idleConn(upstream Upstream) net.Conn {
+	p.idleMu.Lock()
+	defer p.idleMu.Unlock()
+
+	conns := p.idle[upstream.String()]
+	for len(conns) > 0 {
+		c := conns[len(conns)-1]
+		conns = conns[:len(conns)-1]
+
+		if time.Since(c.since) < idleTimeout {
+			p.idle[upstream.String()] = conns
+			return c.conn
+		}
+
+		c.conn.Close()
+	}
+
+	delete(p.idle, upstream.String())
+	return nil
+}
+
+// releaseConn keeps the connection open for the next queries to the
+// server, or closes it when the proxy is stopped or enough connections
+// are open
+func (p *Proxy) releaseConn(upstream Upstream, conn net.Conn) {
+	p.idleMu.Lock()
+	defer p.idleMu.Unlock()
+
+	conns := p.idle[upstream.String()]
+	if p.idle == nil || len(conns) >= maxIdleConns {
+		conn.Close()
+		return
+	}
+
+	p.idle[upstream.String()] = append(conns, idleConn{conn: conn, since: time.Now()})
+}
+
+func (p *Proxy) closeIdle() {
+	p.idleMu.Lock()
+	defer p.idleMu.Unlock()
+
+	for _, conns := range p.idle {
+		for _, c := range conns {
+			c.conn.Close()
+		}
+	}
+
+	p.idle = nil
+}
+
+// exchangeConn sends the query using the two bytes length prefix of DNS
+// over TCP
+func exchangeConn(conn net.Conn, msg []byte) ([]byte, error) {
+	conn.SetDeadline(time.Now().Add(exchangeTimeout))
+
+	err := writeFrame(conn, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		resp, err := readFrame(conn)
+		if err != nil {
+			return nil, err
+		}
+
+		// ignore the answers to the queries of a previous exchange
+		if len(resp) >= headerSize && bytes.Equal(resp[:2], msg[:2]) {
+			return resp, nil
+		}
+	}
+}
+
+func writeFrame(w io.Writer, msg []byte) error {
+	frame := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(frame, uint16(len(msg)))
+	copy(frame[2:], msg)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	length := make([]byte, 2)
+	_, err := io.ReadFull(r, length)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := make([]byte, binary.BigEndian.Uint16(length))
+	_, err = io.ReadFull(r, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (p *Proxy) exchangeHTTPS(upstream Upstream, msg []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, upstream.Address, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	ctx, cancel := context.WithTimeout(context.Background(), exchangeTimeout)
+	defer cancel()
+
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %v", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+}
+
+// questionName returns the name of the first question of a query, in
+// lower case and without the trailing dot
+func questionName(msg []byte) (string, error) {
+	if len(msg) < headerSize {
+		return "", fmt.Errorf("message too short")
+	}
+
+	if binary.BigEndian.Uint16(msg[4:]) == 0 {
+		return "", fmt.Errorf("the query has no question")
+	}
+
+	name, _, err := readName(msg, headerSize)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(name), nil
+}
+
+// questionsEnd returns the offset of the data after the questions
+func questionsEnd(msg []byte) (int, error) {
+	offset := headerSize
+	for i := 0; i < int(binary.BigEndian.Uint16(msg[4:])); i++ {
+		_, end, err := readName(msg, offset)
+		if err != nil {
+			return 0, err
+		}
+		// type and class
+		offset = end + 4
+	}
+
+	if offset > len(msg) {
+		return 0, fmt.Errorf("question out of the message")
+	}
+
+	return offset, nil
+}
+
+// udpSize returns the size of the UDP answers accepted by the client, from
+// the OPT record of the query
+func udpSize(query []byte) int {
+	offset, err := questionsEnd(query)
+	if err != nil {
+		return minUDPSize
+	}
+
+	records := int(binary.BigEndian.Uint16(query[6:])) +
+		int(binary.BigEndian.Uint16(query[8:])) +
+		int(binary.BigEndian.Uint16(query[10:]))
+	for i := 0; i < records; i++ {
+		_, end, err := readName(query, offset)
+		if err != nil || end+10 > len(query) {
+			return minUDPSize
+		}
+
+		if binary.BigEndian.Uint16(query[end:]) == typeOPT {
+			// the class of the OPT record is the UDP payload size
+			size := int(binary.BigEndian.Uint16(query[end+2:]))
+			if size < minUDPSize {
+				return minUDPSize
+			}
+			return size
+		}
+
+		offset = end + 10 + int(binary.BigEndian.Uint16(query[end+8:]))
+	}
+
+	return minUDPSize
+}
+
+// truncate removes the records of an answer larger than size and sets its
+// TC flag, so the client sends the query again over TCP (RFC 7766)
+func truncate(msg []byte, size int) []byte {
+	if len(msg) <= size {
+		return msg
+	}
+
+	// the questions are kept when they fit
+	counts := []int{6, 8, 10}
+	end, err := questionsEnd(msg)
+	if err != nil || end > size {
+		end = headerSize
+		counts = append(counts, 4)
+	}
+
+	resp := make([]byte, end)
+	copy(resp, msg)
+	binary.BigEndian.PutUint16(resp[2:], binary.BigEndian.Uint16(resp[2:])|flagTruncated)
+	for _, count := range counts {
+		binary.BigEndian.PutUint16(resp[count:], 0)
+	}
+
+	return resp
+}
+
+// readName reads the name at offset, following the compression pointers,
+// and returns the offset of the data after the name
+func readName(msg []byte, offset int) (string, int, error) {
+	labels := []string{}
+	end := -1
+
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, fmt.Errorf("name out of the message")
+		}
+
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case length&0xC0 == 0xC0:
+			if offset+1 >= len(msg) {
+				return "", 0, fmt.Errorf("name out of the message")
+			}
+
+			jumps++
+			if jumps > 10 {
+				return "", 0, fmt.Errorf("too many compression pointers")
+			}
+
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3FFF)
+		default:
+			if offset+1+length > len(msg) {
+				return "", 0, fmt.Errorf("label out of the message")
+			}
+
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// capNegativeTTL limits the TTL and the minimum field of the SOA records
+// returned in the NXDOMAIN and empty answers, which define how long the
+// absence of a record is cached (RFC 2308)
+func capNegativeTTL(msg []byte, max uint32) {
+	rcode := binary.BigEndian.Uint16(msg[2:]) & 0xF
+	answers := binary.BigEndian.Uint16(msg[6:])
+	if rcode != rcodeNXDomain && !(rcode == 0 && answers == 0) {
+		return
+	}
+
+	offset, err := questionsEnd(msg)
+	if err != nil {
+		return
+	}
+
+	records := int(answers) + int(binary.BigEndian.Uint16(msg[8:]))
+	for i := 0; i < records; i++ {
+		_, end, err := readName(msg, offset)
+		if err != nil || end+10 > len(msg) {
+			return
+		}
+
+		rtype := binary.BigEndian.Uint16(msg[end:])
+		rdata := end + 10
+		rdataEnd := rdata + int(binary.BigEndian.Uint16(msg[end+8:]))
+		if rdataEnd > len(msg) {
+			return
+		}
+
+		if rtype == typeSOA {
+			capUint32(msg[end+4:], max)
+			if rdataEnd-rdata >= 4 {
+				capUint32(msg[rdataEnd-4:], max)
+			}
+		}
+
+		offset = rdataEnd
+	}
+}
+
+func capUint32(b []byte, max uint32) {
+	if binary.BigEndian.Uint32(b) > max {
+		binary.BigEndian.PutUint32(b, max)
+	}
+}
@@ -0,0 +1,381 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dns
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseUpstream(t *testing.T) {
+	testCases := []struct {
+		val      string
+		expected Upstream
+		err      bool
+	}{
+		{"udp://10.0.0.10", Upstream{Protocol: UDP, Address: "10.0.0.10:53"}, false},
+		{"udp://[2001:db8::1]:5353", Upstream{Protocol: UDP, Address: "[2001:db8::1]:5353"}, false},
+		{"tls://1.1.1.1", Upstream{Protocol: TLS, Address: "1.1.1.1:853", ServerName: "1.1.1.1"}, false},
+		{"tls://1.1.1.1:8853#cloudflare-dns.com", Upstream{Protocol: TLS, Address: "1.1.1.1:8853", ServerName: "cloudflare-dns.com"}, false},
+		{"https://dns.example.com/dns-query", Upstream{Protocol: HTTPS, Address: "https://dns.example.com/dns-query"}, false},
+		{"udp://10.0.0.10#name", Upstream{}, true},
+		{"udp://10.0.0.10/path", Upstream{}, true},
+		{"tcp://10.0.0.10", Upstream{}, true},
+		{"10.0.0.10", Upstream{}, true},
+		{"https:///dns-query", Upstream{}, true},
+	}
+
+	for _, tc := range testCases {
+		upstream, err := ParseUpstream(tc.val)
+		if tc.err {
+			if err == nil {
+				t.Errorf("expected an error parsing %v", tc.val)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("unexpected error parsing %v: %v", tc.val, err)
+			continue
+		}
+
+		if upstream != tc.expected {
+			t.Errorf("expected %+v parsing %v but returned %+v", tc.expected, tc.val, upstream)
+		}
+	}
+}
+
+func buildQuery(id uint16, name string) []byte {
+	msg := make([]byte, headerSize)
+	binary.BigEndian.PutUint16(msg, id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+
+	for _, label := range strings.Split(name, ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+
+	// type A, class IN
+	return append(msg, 0, 0, 1, 0, 1)
+}
+
+// buildAnswer answers the query with an A record, or with the SOA record of
+// the zone when the rcode is not 0
+func buildAnswer(query []byte, rcode uint16) []byte {
+	msg := append([]byte{}, query...)
+	binary.BigEndian.PutUint16(msg[2:], 0x8180|rcode)
+
+	record := func(rtype uint16, ttl uint32, rdata []byte) {
+		// the name is a pointer to the name of the question
+		msg = append(msg, 0xC0, headerSize)
+		buf := make([]byte, 10)
+		binary.BigEndian.PutUint16(buf, rtype)
+		binary.BigEndian.PutUint16(buf[2:], 1)
+		binary.BigEndian.PutUint32(buf[4:], ttl)
+		binary.BigEndian.PutUint16(buf[8:], uint16(len(rdata)))
+		msg = append(msg, buf...)
+		msg = append(msg, rdata...)
+	}
+
+	if rcode == 0 {
+		binary.BigEndian.PutUint16(msg[6:], 1)
+		record(1, 300, []byte{10, 0, 0, 1})
+		return msg
+	}
+
+	binary.BigEndian.PutUint16(msg[8:], 1)
+	// mname, rname, serial, refresh, retry, expire and minimum
+	soa := []byte{0xC0, headerSize, 0xC0, headerSize}
+	for _, v := range []uint32{1, 7200, 3600, 1209600, 3600} {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, v)
+		soa = append(soa, buf...)
+	}
+	record(typeSOA, 3600, soa)
+
+	return msg
+}
+
+// soaTTLs returns the TTL and the minimum field of the SOA record of an
+// answer built by buildAnswer
+func soaTTLs(msg []byte) (uint32, uint32) {
+	_, offset, _ := readName(msg, headerSize)
+	offset += 4 + 2
+	return binary.BigEndian.Uint32(msg[offset+4:]), binary.BigEndian.Uint32(msg[len(msg)-4:])
+}
+
+// fakeServer answers all the queries with rcode
+func fakeServer(t *testing.T, rcode uint16) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	go func() {
+		buf := make([]byte, maxMessageSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(buildAnswer(buf[:n], rcode), addr)
+		}
+	}()
+
+	return "udp://" + conn.LocalAddr().String()
+}
+
+func freeAddress(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().String()
+}
+
+func query(t *testing.T, address, name string) []byte {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	_, err = conn.Write(buildQuery(0x1234, name))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected error resolving %v: %v", name, err)
+	}
+
+	if binary.BigEndian.Uint16(buf) != 0x1234 {
+		t.Errorf("expected the ID of the query in the answer")
+	}
+
+	return buf[:n]
+}
+
+func queryTCP(t *testing.T, address, name string) []byte {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	err = writeFrame(conn, buildQuery(0x1234, name))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := readFrame(conn)
+	if err != nil {
+		t.Fatalf("unexpected error resolving %v: %v", name, err)
+	}
+
+	if binary.BigEndian.Uint16(resp) != 0x1234 {
+		t.Errorf("expected the ID of the query in the answer")
+	}
+
+	return resp
+}
+
+func TestProxy(t *testing.T) {
+	defaultServer, _ := ParseUpstream(fakeServer(t, rcodeNXDomain))
+	zoneServer, _ := ParseUpstream(fakeServer(t, 0))
+
+	p := NewProxy(freeAddress(t), nil)
+	defer p.Close()
+
+	err := p.Sync(Config{
+		Upstreams:   []Upstream{defaultServer},
+		Zones:       map[string][]Upstream{"corp.example.com": {zoneServer}},
+		NegativeTTL: 30,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp := query(t, p.Address(), "WWW.Corp.Example.com")
+	if rcode := resp[3] & 0xF; rcode != 0 {
+		t.Errorf("expected the answer of the server of the zone but returned rcode %v", rcode)
+	}
+
+	resp = query(t, p.Address(), "missing.example.org")
+	if rcode := resp[3] & 0xF; rcode != rcodeNXDomain {
+		t.Fatalf("expected the answer of the default server but returned rcode %v", rcode)
+	}
+
+	ttl, minimum := soaTTLs(resp)
+	if ttl != 30 || minimum != 30 {
+		t.Errorf("expected the negative TTL to be capped to 30 but returned %v and %v", ttl, minimum)
+	}
+
+	resp = queryTCP(t, p.Address(), "WWW.Corp.Example.com")
+	if rcode := resp[3] & 0xF; rcode != 0 {
+		t.Errorf("expected the answer of the server of the zone over TCP but returned rcode %v", rcode)
+	}
+
+	err = p.Sync(Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp", p.Address())
+	if err != nil {
+		t.Fatalf("expected the proxy to be stopped: %v", err)
+	}
+	conn.Close()
+}
+
+func TestConfigUpstreams(t *testing.T) {
+	def := []Upstream{{Protocol: UDP, Address: "10.0.0.1:53"}}
+	corp := []Upstream{{Protocol: UDP, Address: "10.0.0.2:53"}}
+	dev := []Upstream{{Protocol: UDP, Address: "10.0.0.3:53"}}
+
+	cfg := Config{
+		Upstreams: def,
+		Zones: map[string][]Upstream{
+			"corp.example.com":     corp,
+			"dev.corp.example.com": dev,
+		},
+	}
+
+	testCases := map[string][]Upstream{
+		"corp.example.com":         corp,
+		"www.corp.example.com":     corp,
+		"api.dev.corp.example.com": dev,
+		"notcorp.example.com":      def,
+		"example.com":              def,
+	}
+
+	for name, expected := range testCases {
+		if upstreams := cfg.upstreams(name); upstreams[0] != expected[0] {
+			t.Errorf("expected %v to be resolved by %v but returned %v", name, expected, upstreams)
+		}
+	}
+}
+
+func TestExchangeHTTPS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		query, _ := ioutil.ReadAll(r.Body)
+		if binary.BigEndian.Uint16(query) != 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(buildAnswer(query, 0))
+	}))
+	defer server.Close()
+
+	p := NewProxy("127.0.0.1:0", nil)
+	resp, err := p.exchange(Upstream{Protocol: HTTPS, Address: server.URL}, buildQuery(0x4321, "www.example.com"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if id := binary.BigEndian.Uint16(resp); id != 0x4321 {
+		t.Errorf("expected the ID of the query but returned %x", id)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	query := buildQuery(0x1234, "www.example.com")
+	if size := udpSize(query); size != minUDPSize {
+		t.Errorf("expected %v bytes without EDNS0 but returned %v", minUDPSize, size)
+	}
+
+	// OPT record of the root name announcing 4096 bytes
+	edns := append([]byte{}, query...)
+	binary.BigEndian.PutUint16(edns[10:], 1)
+	edns = append(edns, 0, 0, typeOPT, 0x10, 0, 0, 0, 0, 0, 0, 0)
+	if size := udpSize(edns); size != 4096 {
+		t.Errorf("expected 4096 bytes with EDNS0 but returned %v", size)
+	}
+
+	answer := buildAnswer(query, 0)
+	if resp := truncate(answer, len(answer)); len(resp) != len(answer) {
+		t.Errorf("expected the answer not to be truncated")
+	}
+
+	resp := truncate(answer, len(answer)-1)
+	if binary.BigEndian.Uint16(resp[2:])&flagTruncated == 0 {
+		t.Errorf("expected the TC flag in the truncated answer")
+	}
+
+	if answers := binary.BigEndian.Uint16(resp[6:]); answers != 0 {
+		t.Errorf("expected no answers in the truncated answer but returned %v", answers)
+	}
+
+	if name, err := questionName(resp); err != nil || name != "www.example.com" {
+		t.Errorf("expected the question in the truncated answer but returned %v (%v)", name, err)
+	}
+}
+
+func TestIdleConns(t *testing.T) {
+	upstream := Upstream{Protocol: TLS, Address: "10.0.0.1:853", ServerName: "dns.example.com"}
+
+	p := NewProxy(freeAddress(t), nil)
+	err := p.Sync(Config{Upstreams: []Upstream{upstream}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if conn := p.idleConn(upstream); conn != nil {
+		t.Fatalf("expected no idle connection")
+	}
+
+	client, server := net.Pipe()
+	defer server.Close()
+
+	p.releaseConn(upstream, client)
+	if conn := p.idleConn(upstream); conn != client {
+		t.Errorf("expected the released connection to be reused")
+	}
+
+	p.releaseConn(upstream, client)
+	p.Close()
+
+	if conn := p.idleConn(upstream); conn != nil {
+		t.Errorf("expected no idle connection once the proxy is stopped")
+	}
+
+	server.SetDeadline(time.Now().Add(time.Second))
+	if _, err := server.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected the idle connection to be closed but returned %v", err)
+	}
+}
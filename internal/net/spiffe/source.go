@@ -0,0 +1,343 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spiffe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"k8s.io/klog"
+)
+
+const (
+	// the delays between two connections to the Workload API when it is unavailable
+	minRetryInterval = time.Second
+	maxRetryInterval = 30 * time.Second
+)
+
+// Source keeps the X.509 SVID of the controller up to date using the
+// Workload API. The SVID is rotated each time a new one is sent by the API.
+type Source struct {
+	socket string
+
+	lock  sync.RWMutex
+	svid  *svid
+	err   error
+	ready chan struct{}
+	once  sync.Once
+}
+
+type svid struct {
+	id          string
+	certificate *tls.Certificate
+	bundle      *x509.CertPool
+}
+
+// ParseAddress returns the path of the unix socket of a Workload API
+// address, in the form "unix:///path/to/socket" or "/path/to/socket"
+func ParseAddress(address string) (string, error) {
+	if strings.HasPrefix(address, "/") {
+		return address, nil
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme != "unix" || u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		return "", fmt.Errorf("%v is not the address of a unix socket (unix:///path/to/socket)", address)
+	}
+
+	return u.Path, nil
+}
+
+// ParseID validates a SPIFFE ID, in the form spiffe://<trust domain>/<path>
+func ParseID(id string) (string, error) {
+	u, err := url.Parse(id)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme != "spiffe" || u.Host == "" || u.User != nil || u.Port() != "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("%v is not a SPIFFE ID (spiffe://<trust domain>/<path>)", id)
+	}
+
+	return u.String(), nil
+}
+
+// NewSource watches the X.509 SVID of the controller sent by the
+// Workload API listening on the address
+func NewSource(address string) (*Source, error) {
+	socket, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Source{
+		socket: socket,
+		err:    fmt.Errorf("no SVID received from the SPIFFE Workload API yet"),
+		ready:  make(chan struct{}),
+	}
+
+	go s.watch()
+
+	return s, nil
+}
+
+// Ready returns a channel closed when the first SVID is received
+func (s *Source) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// ID returns the SPIFFE ID of the current SVID
+func (s *Source) ID() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.svid == nil {
+		return ""
+	}
+
+	return s.svid.id
+}
+
+// GetCertificate implements the tls.Config.GetCertificate interface
+func (s *Source) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.svid == nil {
+		return nil, s.err
+	}
+
+	return s.svid.certificate, nil
+}
+
+// VerifyPeerCertificate returns a tls.Config.VerifyPeerCertificate function
+// accepting the peers presenting an SVID signed by the current trust bundle
+// with one of the authorized SPIFFE IDs
+func (s *Source) VerifyPeerCertificate(authorizedIDs []string) func([][]byte, [][]*x509.Certificate) error {
+	authorized := make(map[string]bool, len(authorizedIDs))
+	for _, id := range authorizedIDs {
+		authorized[id] = true
+	}
+
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		s.lock.RLock()
+		current := s.svid
+		s.lock.RUnlock()
+
+		if current == nil {
+			return s.err
+		}
+
+		id, err := verifySVID(rawCerts, current.bundle)
+		if err != nil {
+			return err
+		}
+
+		if !authorized[id] {
+			return fmt.Errorf("the SPIFFE ID %v is not authorized", id)
+		}
+
+		return nil
+	}
+}
+
+// verifySVID verifies a certificate chain and returns its SPIFFE ID
+func verifySVID(rawCerts [][]byte, bundle *x509.CertPool) (string, error) {
+	if len(rawCerts) == 0 {
+		return "", fmt.Errorf("no certificate presented by the peer")
+	}
+
+	intermediates := x509.NewCertPool()
+	var leaf *x509.Certificate
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return "", fmt.Errorf("unexpected error parsing the certificate of the peer: %v", err)
+		}
+
+		if i == 0 {
+			leaf = cert
+		} else {
+			intermediates.AddCert(cert)
+		}
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         bundle,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return "", fmt.Errorf("the certificate of the peer is not signed by the trust bundle: %v", err)
+	}
+
+	// an SVID contains exactly one URI SAN, its SPIFFE ID
+	if len(leaf.URIs) != 1 || leaf.URIs[0].Scheme != "spiffe" {
+		return "", fmt.Errorf("the certificate of the peer is not an X.509 SVID")
+	}
+
+	return leaf.URIs[0].String(), nil
+}
+
+func (s *Source) watch() {
+	retry := minRetryInterval
+	for {
+		received, err := s.fetch()
+		if received {
+			retry = minRetryInterval
+		}
+
+		// the last SVID is kept until it is replaced or expires
+		klog.Warningf("Error watching the SVID from the SPIFFE Workload API %v, retrying in %v: %v", s.socket, retry, err)
+		time.Sleep(retry)
+
+		retry *= 2
+		if retry > maxRetryInterval {
+			retry = maxRetryInterval
+		}
+	}
+}
+
+// fetch receives the SVIDs from the Workload API until the stream is
+// interrupted. It returns true when at least one SVID was received.
+func (s *Source) fetch() (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	conn, err := grpc.DialContext(ctx, s.socket, grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", address)
+		}))
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	ctx = metadata.AppendToOutgoingContext(ctx, workloadAPIHeader, "true")
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fetchX509SVIDMethod)
+	if err != nil {
+		return false, err
+	}
+
+	if err := stream.SendMsg(&X509SVIDRequest{}); err != nil {
+		return false, err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return false, err
+	}
+
+	received := false
+	for {
+		resp := &X509SVIDResponse{}
+		if err := stream.RecvMsg(resp); err != nil {
+			return received, err
+		}
+
+		svid, err := parseSVID(resp)
+		if err != nil {
+			klog.Warningf("Invalid SVID received from the SPIFFE Workload API: %v", err)
+			continue
+		}
+
+		klog.Infof("Received the SVID %v (expires %v)", svid.id, svid.certificate.Leaf.NotAfter)
+		s.update(svid)
+		received = true
+	}
+}
+
+func (s *Source) update(svid *svid) {
+	s.lock.Lock()
+	s.svid, s.err = svid, nil
+	s.lock.Unlock()
+
+	s.once.Do(func() {
+		close(s.ready)
+	})
+}
+
+// parseSVID returns the first SVID of a response, the default identity
+// of the workload
+func parseSVID(resp *X509SVIDResponse) (*svid, error) {
+	if len(resp.SVIDs) == 0 {
+		return nil, fmt.Errorf("the response does not contain any SVID")
+	}
+
+	r := resp.SVIDs[0]
+
+	id, err := ParseID(r.SpiffeID)
+	if err != nil {
+		return nil, err
+	}
+
+	certs, err := x509.ParseCertificates(r.X509SVID)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error parsing the certificates of %v: %v", id, err)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("the SVID %v does not contain any certificate", id)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(r.X509SVIDKey)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error parsing the private key of %v: %v", id, err)
+	}
+
+	bundle, err := x509.ParseCertificates(r.Bundle)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error parsing the trust bundle of %v: %v", id, err)
+	}
+
+	if len(bundle) == 0 {
+		return nil, fmt.Errorf("the trust bundle of %v does not contain any certificate", id)
+	}
+
+	pool := x509.NewCertPool()
+	for _, cert := range bundle {
+		pool.AddCert(cert)
+	}
+
+	chain := make([][]byte, 0, len(certs))
+	for _, cert := range certs {
+		chain = append(chain, cert.Raw)
+	}
+
+	return &svid{
+		id: id,
+		certificate: &tls.Certificate{
+			Certificate: chain,
+			PrivateKey:  key,
+			Leaf:        certs[0],
+		},
+		bundle: pool,
+	}, nil
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spiffe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newAuthority(t *testing.T) *authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "example.org"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return &authority{cert: cert, key: key}
+}
+
+func (a *authority) newSVID(t *testing.T, id string, serial int64) *X509SVID {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	u, err := url.Parse(id)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{u},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return &X509SVID{
+		SpiffeID:    id,
+		X509SVID:    der,
+		X509SVIDKey: pkcs8,
+		Bundle:      a.cert.Raw,
+	}
+}
+
+// startWorkloadAPI serves the FetchX509SVID stream on a unix socket,
+// sending each response written in the channel
+func startWorkloadAPI(t *testing.T, responses chan *X509SVIDResponse) (string, func()) {
+	dir, err := ioutil.TempDir("", "spiffe")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	socket := filepath.Join(dir, "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "SpiffeWorkloadAPI",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{
+			{
+				StreamName:    "FetchX509SVID",
+				ServerStreams: true,
+				Handler: func(_ interface{}, stream grpc.ServerStream) error {
+					md, _ := metadata.FromIncomingContext(stream.Context())
+					if v := md.Get(workloadAPIHeader); len(v) != 1 || v[0] != "true" {
+						return fmt.Errorf("missing %v header", workloadAPIHeader)
+					}
+
+					if err := stream.RecvMsg(&X509SVIDRequest{}); err != nil {
+						return err
+					}
+
+					for {
+						select {
+						case resp := <-responses:
+							if err := stream.SendMsg(resp); err != nil {
+								return err
+							}
+						case <-stream.Context().Done():
+							return nil
+						}
+					}
+				},
+			},
+		},
+	}, struct{}{})
+
+	go server.Serve(l)
+
+	return "unix://" + socket, func() {
+		server.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		socket  string
+		err     bool
+	}{
+		{"unix:///run/spire/sockets/agent.sock", "/run/spire/sockets/agent.sock", false},
+		{"/run/spire/sockets/agent.sock", "/run/spire/sockets/agent.sock", false},
+		{"unix://run/agent.sock", "", true},
+		{"tcp://127.0.0.1:8081", "", true},
+		{"agent.sock", "", true},
+	}
+
+	for _, test := range tests {
+		socket, err := ParseAddress(test.address)
+		if test.err != (err != nil) {
+			t.Errorf("%v: expected error %v but got %v", test.address, test.err, err)
+		}
+
+		if socket != test.socket {
+			t.Errorf("%v: expected socket %v but got %v", test.address, test.socket, socket)
+		}
+	}
+}
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		id  string
+		err bool
+	}{
+		{"spiffe://example.org/ns/default/sa/kube-apiserver", false},
+		{"spiffe://example.org", false},
+		{"spiffe:///ns/default", true},
+		{"spiffe://example.org:8443/ns/default", true},
+		{"spiffe://example.org/ns/default?sa=x", true},
+		{"https://example.org/ns/default", true},
+	}
+
+	for _, test := range tests {
+		_, err := ParseID(test.id)
+		if test.err != (err != nil) {
+			t.Errorf("%v: expected error %v but got %v", test.id, test.err, err)
+		}
+	}
+}
+
+func TestSource(t *testing.T) {
+	ca := newAuthority(t)
+	responses := make(chan *X509SVIDResponse, 1)
+	address, stop := startWorkloadAPI(t, responses)
+	defer stop()
+
+	s, err := NewSource(address)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := s.GetCertificate(nil); err == nil {
+		t.Errorf("expected an error before the first SVID")
+	}
+
+	id := "spiffe://example.org/ns/ingress-nginx/sa/nginx-ingress"
+	responses <- &X509SVIDResponse{SVIDs: []*X509SVID{ca.newSVID(t, id, 2)}}
+
+	select {
+	case <-s.Ready():
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no SVID received")
+	}
+
+	if s.ID() != id {
+		t.Errorf("expected the ID %v but got %v", id, s.ID())
+	}
+
+	cert, err := s.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cert.Leaf.SerialNumber.Int64() != 2 {
+		t.Errorf("expected the serial number 2 but got %v", cert.Leaf.SerialNumber)
+	}
+
+	// rotation
+	responses <- &X509SVIDResponse{SVIDs: []*X509SVID{ca.newSVID(t, id, 3)}}
+
+	err = wait(func() bool {
+		cert, _ := s.GetCertificate(nil)
+		return cert.Leaf.SerialNumber.Int64() == 3
+	})
+	if err != nil {
+		t.Errorf("the SVID was not rotated")
+	}
+}
+
+func TestVerifyPeerCertificate(t *testing.T) {
+	ca := newAuthority(t)
+	responses := make(chan *X509SVIDResponse, 1)
+	address, stop := startWorkloadAPI(t, responses)
+	defer stop()
+
+	s, err := NewSource(address)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	verify := s.VerifyPeerCertificate([]string{"spiffe://example.org/ns/kube-system/sa/kube-apiserver"})
+
+	client := ca.newSVID(t, "spiffe://example.org/ns/kube-system/sa/kube-apiserver", 4)
+	if err := verify([][]byte{client.X509SVID}, nil); err == nil {
+		t.Errorf("expected an error before the first SVID")
+	}
+
+	responses <- &X509SVIDResponse{SVIDs: []*X509SVID{ca.newSVID(t, "spiffe://example.org/ns/ingress-nginx/sa/nginx-ingress", 2)}}
+	<-s.Ready()
+
+	tests := []struct {
+		name  string
+		certs [][]byte
+		err   bool
+	}{
+		{"authorized", [][]byte{client.X509SVID}, false},
+		{"not authorized", [][]byte{ca.newSVID(t, "spiffe://example.org/ns/default/sa/default", 5).X509SVID}, true},
+		{"other trust domain", [][]byte{newAuthority(t).newSVID(t, "spiffe://example.org/ns/kube-system/sa/kube-apiserver", 6).X509SVID}, true},
+		{"no certificate", nil, true},
+	}
+
+	for _, test := range tests {
+		err := verify(test.certs, nil)
+		if test.err != (err != nil) {
+			t.Errorf("%v: expected error %v but got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func wait(condition func() bool) error {
+	for i := 0; i < 100; i++ {
+		if condition() {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("timeout")
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spiffe
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// The messages of the SPIFFE Workload API used to fetch the X.509 SVIDs.
+// Only the fields read by the controller are declared, the other ones are
+// ignored when the responses are decoded.
+// https://github.com/spiffe/go-spiffe/blob/master/proto/spiffe/workload/workload.proto
+
+const (
+	fetchX509SVIDMethod = "/SpiffeWorkloadAPI/FetchX509SVID"
+	// the Workload API rejects the requests without this header
+	workloadAPIHeader = "workload.spiffe.io"
+)
+
+// X509SVIDRequest is the request of the FetchX509SVID stream
+type X509SVIDRequest struct{}
+
+// Reset implements the proto.Message interface
+func (m *X509SVIDRequest) Reset() { *m = X509SVIDRequest{} }
+
+// String implements the proto.Message interface
+func (m *X509SVIDRequest) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements the proto.Message interface
+func (*X509SVIDRequest) ProtoMessage() {}
+
+// X509SVIDResponse is sent by the Workload API each time the SVIDs of the
+// workload are rotated
+type X509SVIDResponse struct {
+	SVIDs []*X509SVID `protobuf:"bytes,1,rep,name=svids,proto3" json:"svids,omitempty"`
+}
+
+// Reset implements the proto.Message interface
+func (m *X509SVIDResponse) Reset() { *m = X509SVIDResponse{} }
+
+// String implements the proto.Message interface
+func (m *X509SVIDResponse) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements the proto.Message interface
+func (*X509SVIDResponse) ProtoMessage() {}
+
+// X509SVID contains an identity of the workload
+type X509SVID struct {
+	// SpiffeID is the SPIFFE ID of the SVID
+	SpiffeID string `protobuf:"bytes,1,opt,name=spiffe_id,json=spiffeId,proto3" json:"spiffe_id,omitempty"`
+	// X509SVID contains the ASN.1 DER encoded certificate chain
+	X509SVID []byte `protobuf:"bytes,2,opt,name=x509_svid,json=x509Svid,proto3" json:"x509_svid,omitempty"`
+	// X509SVIDKey contains the ASN.1 DER encoded PKCS#8 private key
+	X509SVIDKey []byte `protobuf:"bytes,3,opt,name=x509_svid_key,json=x509SvidKey,proto3" json:"x509_svid_key,omitempty"`
+	// Bundle contains the ASN.1 DER encoded certificates of the trust domain
+	Bundle []byte `protobuf:"bytes,4,opt,name=bundle,proto3" json:"bundle,omitempty"`
+}
+
+// Reset implements the proto.Message interface
+func (m *X509SVID) Reset() { *m = X509SVID{} }
+
+// String implements the proto.Message interface
+func (m *X509SVID) String() string { return proto.CompactTextString(m) }
+
+// ProtoMessage implements the proto.Message interface
+func (*X509SVID) ProtoMessage() {}
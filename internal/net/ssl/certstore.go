@@ -0,0 +1,222 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+
+	"k8s.io/ingress-nginx/internal/file"
+	"k8s.io/ingress-nginx/internal/ingress"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/logging"
+)
+
+// ecdsaSuffix is appended to the name of the files of the ECDSA certificate
+// of a Secret, it is not a valid character of the names of the Secrets
+const ecdsaSuffix = "_ecdsa"
+
+// SSLCertStore keeps the certificate and key of the SSL certificates created
+// from the Secrets until NGINX or the controller reads them
+type SSLCertStore interface {
+	// Store keeps the certificate and key of sslCert, name is the name of the
+	// Secret with its namespace. ca is the bundle of the Secret used to
+	// authenticate the clients, if any.
+	Store(name string, sslCert *ingress.SSLCert, ca []byte) error
+	// StoreOCSPResponse keeps the OCSP response of sslCert, fetched again
+	// after its certificate and key are stored
+	StoreOCSPResponse(name string, sslCert *ingress.SSLCert) error
+}
+
+// NewDiskSSLCertStore returns a SSLCertStore writing the certificates and
+// keys to the filesystem. With dynamic certificates, only the files read by
+// the controller are written, unless NGINX reads the CA bundle of the Secret.
+func NewDiskSSLCertStore(fs file.Filesystem) SSLCertStore {
+	return diskSSLCertStore{fs}
+}
+
+type diskSSLCertStore struct {
+	fs file.Filesystem
+}
+
+func (s diskSSLCertStore) Store(name string, sslCert *ingress.SSLCert, ca []byte) error {
+	if !ngx_config.EnableDynamicCertificates || len(ca) > 0 {
+		err := StoreSSLCertOnDisk(s.fs, name, sslCert)
+		if err != nil {
+			return fmt.Errorf("error while storing certificate and key: %v", err)
+		}
+	}
+
+	if len(ca) > 0 {
+		err := ConfigureCACertWithCertAndKey(s.fs, name, ca, sslCert)
+		if err != nil {
+			return fmt.Errorf("error configuring CA certificate: %v", err)
+		}
+	}
+
+	// the certificate and key are read from disk when they are required
+	err := StorePemCertKeyOnDisk(s.fs, name, sslCert)
+	if err != nil {
+		return fmt.Errorf("error while storing certificate and key: %v", err)
+	}
+
+	if ngx_config.EnableOCSPStapling {
+		err = StoreOCSPResponseOnDisk(s.fs, name, sslCert)
+		if err != nil {
+			return fmt.Errorf("error while storing OCSP response: %v", err)
+		}
+	}
+
+	if sslCert.ECDSA == nil {
+		return nil
+	}
+
+	// the CA bundle is only appended to the file of the RSA certificate
+	ecdsaName := name + ecdsaSuffix
+	if !ngx_config.EnableDynamicCertificates {
+		err = StoreSSLCertOnDisk(s.fs, ecdsaName, sslCert.ECDSA)
+		if err != nil {
+			return fmt.Errorf("error while storing ECDSA certificate and key: %v", err)
+		}
+	}
+
+	err = StorePemCertKeyOnDisk(s.fs, ecdsaName, sslCert.ECDSA)
+	if err != nil {
+		return fmt.Errorf("error while storing ECDSA certificate and key: %v", err)
+	}
+
+	return nil
+}
+
+func (s diskSSLCertStore) StoreOCSPResponse(name string, sslCert *ingress.SSLCert) error {
+	return StoreOCSPResponseOnDisk(s.fs, name, sslCert)
+}
+
+// NewMemorySSLCertStore returns a SSLCertStore keeping the certificates and
+// keys compressed in memory, for the certificates configured dynamically by
+// Lua. Only the CA bundles used to authenticate the clients, which NGINX
+// reads from its configuration, are written to the filesystem.
+func NewMemorySSLCertStore(fs file.Filesystem) SSLCertStore {
+	return memorySSLCertStore{fs}
+}
+
+type memorySSLCertStore struct {
+	fs file.Filesystem
+}
+
+func (s memorySSLCertStore) Store(name string, sslCert *ingress.SSLCert, ca []byte) error {
+	if len(ca) > 0 {
+		err := configureCACertWithoutKey(s.fs, name, ca, sslCert)
+		if err != nil {
+			return fmt.Errorf("error configuring CA certificate: %v", err)
+		}
+	}
+
+	err := compressPemCertKey(sslCert)
+	if err != nil {
+		return err
+	}
+
+	if sslCert.ECDSA != nil {
+		err = compressPemCertKey(sslCert.ECDSA)
+		if err != nil {
+			return fmt.Errorf("ECDSA certificate: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// StoreOCSPResponse does nothing, the OCSP response is stapled by Lua from memory
+func (s memorySSLCertStore) StoreOCSPResponse(name string, sslCert *ingress.SSLCert) error {
+	return nil
+}
+
+// compressPemCertKey replaces PemCertKey of sslCert with its compressed content
+func compressPemCertKey(sslCert *ingress.SSLCert) error {
+	if sslCert.PemCertKey == "" {
+		return nil
+	}
+
+	compressed, err := compressPem([]byte(sslCert.PemCertKey))
+	if err != nil {
+		return fmt.Errorf("error while compressing certificate and key: %v", err)
+	}
+
+	sslCert.PemCertKeyCompressed = compressed
+	sslCert.PemCertKey = ""
+	// the OCSP response is stapled by Lua from memory
+	sslCert.OCSPResponseFile = ""
+
+	return nil
+}
+
+// configureCACertWithoutKey writes the CA bundle of a Secret also containing
+// a certificate and key to its own file, so the private key is not written
+func configureCACertWithoutKey(fs file.Filesystem, name string, ca []byte, sslCert *ingress.SSLCert) error {
+	certs, err := verifyCABundle(ca, sslCert)
+	if err != nil {
+		return err
+	}
+
+	caName := fmt.Sprintf("ca-%v.pem", name)
+	fileName := fmt.Sprintf("%v/%v", file.DefaultSSLDirectory, caName)
+
+	err = writeFileAtomically(fs, fileName, ca)
+	if err != nil {
+		return fmt.Errorf("could not write CA file %v: %v", fileName, err)
+	}
+
+	sslCert.CAFileName = fileName
+	sslCert.CACertificates = certs
+	sslCert.CAExpireTime = earliestExpireTime(certs)
+	// the CA file is the only file of the certificate, its checksum is used
+	// to detect the changes of the client authentication
+	sslCert.CASHA = fileSHA1(fs, fileName)
+	sslCert.PemSHA = sslCert.CASHA
+
+	logging.V(3).Infof("Created CA Certificate for Authentication: %v", fileName)
+
+	return nil
+}
+
+func compressPem(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decompressPem(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/x509"
+	"testing"
+
+	"k8s.io/ingress-nginx/internal/file"
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+)
+
+func TestDiskSSLCertStore(t *testing.T) {
+	cert, _, err := generateRSACerts("echoheaders")
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	defer func() {
+		ngx_config.EnableDynamicCertificates = true
+	}()
+
+	testCases := []struct {
+		dynamic bool
+		pemFile string
+	}{
+		{false, "/default-echoheaders.pem"},
+		// the certificate and key are only read by the controller
+		{true, ""},
+	}
+
+	for _, tc := range testCases {
+		ngx_config.EnableDynamicCertificates = tc.dynamic
+
+		sslCert, err := CreateSSLCert(encodeCertPEM(cert.Cert), encodePrivateKeyPEM(cert.Key))
+		if err != nil {
+			t.Fatalf("unexpected error creating SSL certificate: %v", err)
+		}
+
+		pemCertKey := sslCert.PemCertKey
+
+		fs := newFS(t)
+		err = NewDiskSSLCertStore(fs).Store("default-echoheaders", sslCert, nil)
+		if err != nil {
+			t.Fatalf("unexpected error storing certificate and key: %v", err)
+		}
+
+		if tc.pemFile != "" {
+			tc.pemFile = file.DefaultSSLDirectory + tc.pemFile
+		}
+		if sslCert.PemFileName != tc.pemFile {
+			t.Errorf("expected PEM file %q with dynamic certificates %v but returned %q", tc.pemFile, tc.dynamic, sslCert.PemFileName)
+		}
+
+		content, err := fs.ReadFile(sslCert.PemCertKeyFileName)
+		if err != nil {
+			t.Fatalf("unexpected error reading the certificate and key: %v", err)
+		}
+		if string(content) != pemCertKey {
+			t.Errorf("expected the certificate and key in %v", sslCert.PemCertKeyFileName)
+		}
+	}
+}
+
+func TestMemorySSLCertStore(t *testing.T) {
+	cert, CA, err := generateRSACerts("echoheaders")
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	t.Run("without CA", func(t *testing.T) {
+		sslCert, err := CreateSSLCert(encodeCertPEM(cert.Cert), encodePrivateKeyPEM(cert.Key))
+		if err != nil {
+			t.Fatalf("unexpected error creating SSL certificate: %v", err)
+		}
+
+		pemCertKey := sslCert.PemCertKey
+
+		fs := newFS(t)
+		err = NewMemorySSLCertStore(fs).Store("default-echoheaders", sslCert, nil)
+		if err != nil {
+			t.Fatalf("unexpected error storing certificate and key: %v", err)
+		}
+
+		if sslCert.PemCertKey != "" {
+			t.Errorf("expected PemCertKey to be compressed")
+		}
+		if sslCert.PemFileName != "" || sslCert.PemCertKeyFileName != "" {
+			t.Errorf("expected no file but returned %q and %q", sslCert.PemFileName, sslCert.PemCertKeyFileName)
+		}
+		if _, err := fs.Stat(file.DefaultSSLDirectory + "/default-echoheaders.pem"); err == nil {
+			t.Errorf("expected the certificate and key not to be written")
+		}
+
+		content, err := ReadPemCertKey(fs, sslCert)
+		if err != nil {
+			t.Fatalf("unexpected error reading the certificate and key: %v", err)
+		}
+		if content != pemCertKey {
+			t.Errorf("expected the certificate and key to be decompressed")
+		}
+
+		if err := VerifyPemFiles(fs, sslCert); err != nil {
+			t.Errorf("unexpected error verifying the files: %v", err)
+		}
+	})
+
+	t.Run("with CA", func(t *testing.T) {
+		sslCert, err := CreateSSLCert(encodeCertPEM(cert.Cert), encodePrivateKeyPEM(cert.Key))
+		if err != nil {
+			t.Fatalf("unexpected error creating SSL certificate: %v", err)
+		}
+
+		ca := encodeCertPEM(CA.Cert)
+
+		fs := newFS(t)
+		err = NewMemorySSLCertStore(fs).Store("default-echoheaders", sslCert, ca)
+		if err != nil {
+			t.Fatalf("unexpected error storing certificate and key: %v", err)
+		}
+
+		if sslCert.CAFileName != file.DefaultSSLDirectory+"/ca-default-echoheaders.pem" {
+			t.Errorf("unexpected CA file %v", sslCert.CAFileName)
+		}
+		if sslCert.PemFileName != "" {
+			t.Errorf("expected no PEM file but returned %q", sslCert.PemFileName)
+		}
+		if len(sslCert.CACertificates) != 1 {
+			t.Errorf("expected 1 CA certificate but got %v", len(sslCert.CACertificates))
+		}
+
+		content, err := fs.ReadFile(sslCert.CAFileName)
+		if err != nil {
+			t.Fatalf("unexpected error reading the CA: %v", err)
+		}
+		if !bytes.Equal(content, ca) {
+			t.Errorf("expected only the CA in %v", sslCert.CAFileName)
+		}
+
+		other, _, err := generateRSACerts("other")
+		if err != nil {
+			t.Fatalf("unexpected error creating SSL certificate: %v", err)
+		}
+
+		err = NewMemorySSLCertStore(fs).Store("default-echoheaders", sslCert, encodeCertPEM(other.Cert))
+		if err == nil {
+			t.Errorf("expected an error with a CA not issuing the certificate")
+		}
+	})
+}
+
+func TestStoreDualSSLCert(t *testing.T) {
+	rsaKey, err := newPrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error creating key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error creating key: %v", err)
+	}
+
+	rsaCert, rsaPem := newSelfSignedCert(t, rsaKey, x509.SHA256WithRSA)
+	ecdsaCert, ecdsaPem := newSelfSignedCert(t, ecdsaKey, x509.ECDSAWithSHA256)
+
+	defer func() {
+		ngx_config.EnableDynamicCertificates = true
+	}()
+	ngx_config.EnableDynamicCertificates = false
+
+	testCases := []struct {
+		name     string
+		newStore func(file.Filesystem) SSLCertStore
+		pemFile  string
+	}{
+		{"disk", NewDiskSSLCertStore, file.DefaultSSLDirectory + "/default-echoheaders_ecdsa.pem"},
+		{"memory", NewMemorySSLCertStore, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sslCert, err := CreateDualSSLCert(rsaCert, rsaPem, ecdsaCert, ecdsaPem)
+			if err != nil {
+				t.Fatalf("unexpected error creating SSL certificate: %v", err)
+			}
+
+			pemCertKey := sslCert.ECDSA.PemCertKey
+
+			fs := newFS(t)
+			err = tc.newStore(fs).Store("default-echoheaders", sslCert, nil)
+			if err != nil {
+				t.Fatalf("unexpected error storing certificate and key: %v", err)
+			}
+
+			if sslCert.ECDSA.PemFileName != tc.pemFile {
+				t.Errorf("expected ECDSA PEM file %q but returned %q", tc.pemFile, sslCert.ECDSA.PemFileName)
+			}
+
+			var content []byte
+			if sslCert.ECDSA.PemCertKeyFileName != "" {
+				content, err = fs.ReadFile(sslCert.ECDSA.PemCertKeyFileName)
+			} else {
+				var decompressed string
+				decompressed, err = ReadPemCertKey(fs, sslCert.ECDSA)
+				content = []byte(decompressed)
+			}
+			if err != nil {
+				t.Fatalf("unexpected error reading the ECDSA certificate and key: %v", err)
+			}
+			if string(content) != pemCertKey {
+				t.Errorf("expected the ECDSA certificate and key to be stored")
+			}
+		})
+	}
+}
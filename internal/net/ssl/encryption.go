@@ -0,0 +1,100 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// encryptedPemSuffix is appended to the name of the PEM files encrypted at rest
+const encryptedPemSuffix = ".enc"
+
+var (
+	pemEncryptionMu sync.RWMutex
+	// pemEncryption encrypts the PEM files read only by the controller,
+	// nil when the files are not encrypted
+	pemEncryption cipher.AEAD
+)
+
+// SetPemEncryptionKey enables the encryption of the PEM files only read by the
+// controller, so the private keys are not stored in clear on the filesystem.
+// The key must contain 32 bytes, or 32 bytes encoded in base64. The key is only
+// kept in memory.
+func SetPemEncryptionKey(key []byte) error {
+	key = bytes.TrimSpace(key)
+	if len(key) != 32 {
+		decoded, err := base64.StdEncoding.DecodeString(string(key))
+		if err != nil || len(decoded) != 32 {
+			return fmt.Errorf("the encryption key must contain 32 bytes or 32 bytes encoded in base64")
+		}
+		key = decoded
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	pemEncryptionMu.Lock()
+	defer pemEncryptionMu.Unlock()
+
+	pemEncryption = aead
+	return nil
+}
+
+// PemEncryptionEnabled returns true when SetPemEncryptionKey was called
+func PemEncryptionEnabled() bool {
+	return getPemEncryption() != nil
+}
+
+func getPemEncryption() cipher.AEAD {
+	pemEncryptionMu.RLock()
+	defer pemEncryptionMu.RUnlock()
+
+	return pemEncryption
+}
+
+// encryptPem returns the content encrypted with AES-GCM, prefixed with the nonce
+func encryptPem(aead cipher.AEAD, content []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, content, nil), nil
+}
+
+func decryptPem(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted content too short")
+	}
+
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestSetPemEncryptionKey(t *testing.T) {
+	defer func() { pemEncryption = nil }()
+
+	key := bytes.Repeat([]byte("k"), 32)
+
+	testCases := []struct {
+		key   []byte
+		valid bool
+	}{
+		{key, true},
+		{[]byte(base64.StdEncoding.EncodeToString(key) + "\n"), true},
+		{[]byte("short"), false},
+		{[]byte(base64.StdEncoding.EncodeToString(key[:16])), false},
+	}
+
+	for _, tc := range testCases {
+		err := SetPemEncryptionKey(tc.key)
+		if tc.valid && err != nil {
+			t.Errorf("unexpected error with the key %q: %v", tc.key, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("expected an error with the key %q", tc.key)
+		}
+	}
+}
+
+func TestStorePemCertKeyOnDiskEncrypted(t *testing.T) {
+	defer func() { pemEncryption = nil }()
+
+	cert, _, err := generateRSACerts("echoheaders")
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	sslCert, err := CreateSSLCert(encodeCertPEM(cert.Cert), encodePrivateKeyPEM(cert.Key))
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	pemCertKey := sslCert.PemCertKey
+
+	err = SetPemEncryptionKey(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("unexpected error setting the encryption key: %v", err)
+	}
+
+	fs := newFS(t)
+	err = StorePemCertKeyOnDisk(fs, "default-echoheaders", sslCert)
+	if err != nil {
+		t.Fatalf("unexpected error storing certificate and key: %v", err)
+	}
+
+	if !strings.HasSuffix(sslCert.PemCertKeyFileName, encryptedPemSuffix) {
+		t.Errorf("expected an encrypted file but returned %v", sslCert.PemCertKeyFileName)
+	}
+
+	content, err := fs.ReadFile(sslCert.PemCertKeyFileName)
+	if err != nil {
+		t.Fatalf("unexpected error reading the certificate and key: %v", err)
+	}
+	if bytes.Contains(content, []byte("PRIVATE KEY")) {
+		t.Errorf("expected the private key to be encrypted in %v", sslCert.PemCertKeyFileName)
+	}
+
+	read, err := ReadPemCertKey(fs, sslCert)
+	if err != nil {
+		t.Fatalf("unexpected error reading the certificate and key: %v", err)
+	}
+	if read != pemCertKey {
+		t.Errorf("expected %v but returned %v", pemCertKey, read)
+	}
+
+	// the content can not be read with another key
+	err = SetPemEncryptionKey(bytes.Repeat([]byte("x"), 32))
+	if err != nil {
+		t.Fatalf("unexpected error setting the encryption key: %v", err)
+	}
+
+	_, err = ReadPemCertKey(fs, sslCert)
+	if err == nil {
+		t.Errorf("expected an error reading the file with another key")
+	}
+}
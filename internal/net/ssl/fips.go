@@ -0,0 +1,98 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+// minimum size of the RSA keys approved in FIPS mode
+const fipsMinRSAKeySize = 2048
+
+// cipher suites approved in FIPS mode
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// curves approved in FIPS mode
+var fipsCurves = []tls.CurveID{
+	tls.CurveP256,
+	tls.CurveP384,
+}
+
+// signature algorithms of the certificates approved in FIPS mode
+var fipsSignatureAlgorithms = map[x509.SignatureAlgorithm]bool{
+	x509.SHA256WithRSA:    true,
+	x509.SHA384WithRSA:    true,
+	x509.SHA512WithRSA:    true,
+	x509.SHA256WithRSAPSS: true,
+	x509.SHA384WithRSAPSS: true,
+	x509.SHA512WithRSAPSS: true,
+	x509.ECDSAWithSHA256:  true,
+	x509.ECDSAWithSHA384:  true,
+	x509.ECDSAWithSHA512:  true,
+}
+
+// BoringCrypto returns true when the controller was built with the
+// BoringCrypto FIPS validated module, see the boringcrypto build tag
+func BoringCrypto() bool {
+	return boringCrypto
+}
+
+// verifyFIPSCertificate returns an error when the key or the signature of the
+// certificate do not use algorithms approved in FIPS mode
+func verifyFIPSCertificate(cert *x509.Certificate) error {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if size := key.N.BitLen(); size < fipsMinRSAKeySize {
+			return fmt.Errorf("RSA keys of %v bits are not approved in FIPS mode, the minimum is %v bits", size, fipsMinRSAKeySize)
+		}
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		default:
+			return fmt.Errorf("the ECDSA curve %v is not approved in FIPS mode", key.Curve.Params().Name)
+		}
+	default:
+		return fmt.Errorf("the %v keys are not approved in FIPS mode", cert.PublicKeyAlgorithm)
+	}
+
+	if !fipsSignatureAlgorithms[cert.SignatureAlgorithm] {
+		return fmt.Errorf("the signature algorithm %v is not approved in FIPS mode", cert.SignatureAlgorithm)
+	}
+
+	return nil
+}
+
+// restrictFIPSConfig restricts the TLS configuration to the versions,
+// cipher suites and curves approved in FIPS mode
+func restrictFIPSConfig(cfg *tls.Config) {
+	// the cipher suites of TLS 1.3 are not configurable
+	cfg.MinVersion = tls.VersionTLS12
+	cfg.MaxVersion = tls.VersionTLS12
+	cfg.CipherSuites = fipsCipherSuites
+	cfg.CurvePreferences = fipsCurves
+	cfg.PreferServerCipherSuites = true
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+)
+
+// newSelfSignedCert returns a self-signed certificate and its key encoded in PEM
+func newSelfSignedCert(t *testing.T, key crypto.Signer, algorithm x509.SignatureAlgorithm) ([]byte, []byte) {
+	template := &x509.Certificate{
+		SerialNumber:       big.NewInt(1),
+		Subject:            pkix.Name{CommonName: "echoheaders"},
+		DNSNames:           []string{"echoheaders"},
+		NotBefore:          time.Now(),
+		NotAfter:           time.Now().Add(time.Hour),
+		SignatureAlgorithm: algorithm,
+	}
+
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate: %v", err)
+	}
+
+	var keyBlock *pem.Block
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		keyBlock = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+	case *ecdsa.PrivateKey:
+		b, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			t.Fatalf("unexpected error encoding key: %v", err)
+		}
+		keyBlock = &pem.Block{Type: "EC PRIVATE KEY", Bytes: b}
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(keyBlock)
+}
+
+func TestCreateSSLCertFIPSMode(t *testing.T) {
+	ngx_config.EnableFIPSMode = true
+	defer func() { ngx_config.EnableFIPSMode = false }()
+
+	rsa1024, err := rsa.GenerateKey(cryptorand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("unexpected error creating key: %v", err)
+	}
+	rsa2048, err := rsa.GenerateKey(cryptorand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unexpected error creating key: %v", err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error creating key: %v", err)
+	}
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error creating key: %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		key       crypto.Signer
+		algorithm x509.SignatureAlgorithm
+		valid     bool
+	}{
+		{"RSA 2048 bits", rsa2048, x509.SHA256WithRSA, true},
+		{"ECDSA P-256", p256, x509.ECDSAWithSHA256, true},
+		{"RSA 1024 bits", rsa1024, x509.SHA256WithRSA, false},
+		{"ECDSA P-224", p224, x509.ECDSAWithSHA256, false},
+		{"SHA-1 signature", rsa2048, x509.SHA1WithRSA, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cert, key := newSelfSignedCert(t, tc.key, tc.algorithm)
+
+			_, err := CreateSSLCert(cert, key)
+			if tc.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("expected an error in FIPS mode")
+			}
+		})
+	}
+
+	// the certificates are not verified outside of FIPS mode
+	ngx_config.EnableFIPSMode = false
+
+	cert, key := newSelfSignedCert(t, p224, x509.ECDSAWithSHA256)
+	if _, err := CreateSSLCert(cert, key); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTLSConfigFIPSMode(t *testing.T) {
+	tl := &TLSListener{}
+
+	cfg := tl.TLSConfig()
+	if cfg.MinVersion != 0 || len(cfg.CipherSuites) != 0 {
+		t.Errorf("expected the default TLS configuration outside of FIPS mode")
+	}
+
+	ngx_config.EnableFIPSMode = true
+	defer func() { ngx_config.EnableFIPSMode = false }()
+
+	cfg = tl.TLSConfig()
+	if cfg.MinVersion != tls.VersionTLS12 || cfg.MaxVersion != tls.VersionTLS12 {
+		t.Errorf("expected only TLS 1.2 in FIPS mode")
+	}
+	if len(cfg.CipherSuites) != len(fipsCipherSuites) {
+		t.Errorf("expected the cipher suites %v but returned %v", fipsCipherSuites, cfg.CipherSuites)
+	}
+}
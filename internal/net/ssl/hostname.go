@@ -0,0 +1,195 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// hostnameProfile converts the internationalized labels to the A-labels
+// used in the certificates (RFC 6125 section 6.4.2)
+var hostnameProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.StrictDomainName(false))
+
+// NormalizeHostname returns the hostname in lower case without trailing dot,
+// converting the internationalized labels to A-labels. A wildcard label is
+// returned as is.
+func NormalizeHostname(hostname string) (string, error) {
+	trimmed := strings.TrimSuffix(hostname, ".")
+	if trimmed == "" {
+		return "", fmt.Errorf("empty hostname")
+	}
+
+	labels := strings.Split(trimmed, ".")
+	for i, label := range labels {
+		if label == "" {
+			return "", fmt.Errorf("hostname %q contains an empty label", hostname)
+		}
+
+		if isASCII(label) {
+			labels[i] = toLowerCaseASCII(label)
+			continue
+		}
+
+		aLabel, err := hostnameProfile.ToASCII(label)
+		if err != nil {
+			return "", fmt.Errorf("hostname %q contains an invalid internationalized label: %v", hostname, err)
+		}
+		labels[i] = aLabel
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// MatchHostname returns true if the host matches the pattern, a name of a
+// certificate, following RFC 6125 section 6.4:
+//
+//   - the names are compared in lower case, using the A-labels of the
+//     internationalized names
+//   - a wildcard is only allowed as the complete leftmost label of the pattern
+//     and matches exactly one label, "*.example.com" does not match
+//     "example.com" nor "a.b.example.com" and "f*.example.com" never matches
+//   - an IP address is only matched by the same IP address
+//
+// A wildcard host, i.e. the host of an Ingress, only matches the same pattern.
+func MatchHostname(pattern, host string) bool {
+	if ip := parseHostIP(host); ip != nil {
+		patternIP := parseHostIP(pattern)
+		return patternIP != nil && ip.Equal(patternIP)
+	}
+
+	if parseHostIP(pattern) != nil {
+		return false
+	}
+
+	pattern, err := NormalizeHostname(pattern)
+	if err != nil {
+		return false
+	}
+
+	host, err = NormalizeHostname(host)
+	if err != nil {
+		return false
+	}
+
+	if pattern == host {
+		return true
+	}
+
+	patternLabels := strings.Split(pattern, ".")
+	hostLabels := strings.Split(host, ".")
+
+	if len(patternLabels) < 2 || len(patternLabels) != len(hostLabels) {
+		return false
+	}
+
+	if patternLabels[0] != "*" || hostLabels[0] == "*" {
+		return false
+	}
+
+	for i := 1; i < len(patternLabels); i++ {
+		if strings.Contains(patternLabels[i], "*") || patternLabels[i] != hostLabels[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsValidHostname checks if a hostname is valid in a list of common names
+func IsValidHostname(hostname string, commonNames []string) bool {
+	for _, cn := range commonNames {
+		if MatchHostname(cn, hostname) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VerifyHostname returns nil if the certificate is valid for the host. An IP
+// address is matched against the IP SANs, a hostname against the DNS SANs or,
+// if the certificate has no DNS SAN, against the common name.
+// Since Go 1.9 the common name is not used by x509.Certificate.VerifyHostname,
+// it is kept to not break the clusters with certificates without SAN.
+// Please check https://github.com/golang/go/issues/22922
+func VerifyHostname(host string, cert *x509.Certificate) error {
+	if cert == nil {
+		return fmt.Errorf("no certificate to verify host %q", host)
+	}
+
+	if ip := parseHostIP(host); ip != nil {
+		for _, candidate := range cert.IPAddresses {
+			if ip.Equal(candidate) {
+				return nil
+			}
+		}
+
+		return x509.HostnameError{Certificate: cert, Host: host}
+	}
+
+	names := cert.DNSNames
+	if len(names) == 0 {
+		names = []string{cert.Subject.CommonName}
+	}
+
+	for _, name := range names {
+		if parseHostIP(name) == nil && MatchHostname(name, host) {
+			return nil
+		}
+	}
+
+	return x509.HostnameError{Certificate: cert, Host: host}
+}
+
+// parseHostIP returns the IP address of the host, which may be written in [ ]
+func parseHostIP(host string) net.IP {
+	if len(host) >= 3 && host[0] == '[' && host[len(host)-1] == ']' {
+		host = host[1 : len(host)-1]
+	}
+
+	return net.ParseIP(host)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// toLowerCaseASCII returns a lower-case version of in. See RFC 6125 6.4.1. We use
+// an explicitly ASCII function to avoid any sharp corners resulting from
+// performing Unicode operations on DNS labels.
+func toLowerCaseASCII(in string) string {
+	out := []byte(in)
+	for i, c := range out {
+		if 'A' <= c && c <= 'Z' {
+			out[i] += 'a' - 'A'
+		}
+	}
+
+	return string(out)
+}
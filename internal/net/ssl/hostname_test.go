@@ -0,0 +1,178 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+)
+
+func TestNormalizeHostname(t *testing.T) {
+	cases := map[string]struct {
+		Hostname string
+		Output   string
+		Error    bool
+	}{
+		"lower case":                {"foo.bar", "foo.bar", false},
+		"upper case":                {"Foo.BAR", "foo.bar", false},
+		"trailing dot":              {"foo.bar.", "foo.bar", false},
+		"wildcard":                  {"*.Foo.bar", "*.foo.bar", false},
+		"underscore":                {"_acme.foo.bar", "_acme.foo.bar", false},
+		"internationalized":         {"bücher.example", "xn--bcher-kva.example", false},
+		"internationalized upper":   {"BÜCHER.example", "xn--bcher-kva.example", false},
+		"A-label":                   {"XN--BCHER-KVA.example", "xn--bcher-kva.example", false},
+		"empty":                     {"", "", true},
+		"only a dot":                {".", "", true},
+		"empty label":               {"foo..bar", "", true},
+		"leading dot":               {".foo.bar", "", true},
+		"invalid internationalized": {"a\u05d0.example", "", true},
+	}
+
+	for k, tc := range cases {
+		output, err := NormalizeHostname(tc.Hostname)
+		if tc.Error {
+			if err == nil {
+				t.Errorf("%s: expected an error but returned %v", k, output)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", k, err)
+			continue
+		}
+		if output != tc.Output {
+			t.Errorf("%s: expected '%v' but returned '%v'", k, tc.Output, output)
+		}
+	}
+}
+
+func TestMatchHostname(t *testing.T) {
+	cases := []struct {
+		Pattern string
+		Host    string
+		Match   bool
+	}{
+		// exact matches
+		{"foo.bar", "foo.bar", true},
+		{"foo.bar", "FOO.Bar", true},
+		{"FOO.BAR", "foo.bar", true},
+		{"foo.bar.", "foo.bar", true},
+		{"foo.bar", "foo.bar.", true},
+		{"foo.bar", "foo.baz", false},
+		{"foo.bar", "www.foo.bar", false},
+		{"www.foo.bar", "foo.bar", false},
+		{"", "foo.bar", false},
+		{"foo.bar", "", false},
+		{"localhost", "localhost", true},
+
+		// wildcards
+		{"*.foo.bar", "www.foo.bar", true},
+		{"*.foo.bar", "WWW.Foo.Bar", true},
+		{"*.foo.bar", "www.foo.bar.", true},
+		{"*.bar", "foo.bar", true},
+		{"*.foo.bar", "foo.bar", false},
+		{"*.foo.bar", "a.b.foo.bar", false},
+		{"*.foo.bar", ".foo.bar", false},
+		{"*", "foo", false},
+		{"*.*.bar", "foo.foo.bar", false},
+		{"foo.*.bar", "foo.foo.bar", false},
+		{"*.foo.*", "www.foo.bar", false},
+
+		// partial label wildcards are not supported
+		{"f*.foo.bar", "fa.foo.bar", false},
+		{"*a.foo.bar", "ba.foo.bar", false},
+		{"w*w.foo.bar", "www.foo.bar", false},
+		{"**.foo.bar", "www.foo.bar", false},
+
+		// wildcard hosts
+		{"*.foo.bar", "*.foo.bar", true},
+		{"*.Foo.bar", "*.foo.BAR", true},
+		{"*.bar", "*.foo.bar", false},
+		{"www.foo.bar", "*.foo.bar", false},
+
+		// internationalized names
+		{"bücher.example", "xn--bcher-kva.example", true},
+		{"xn--bcher-kva.example", "bücher.example", true},
+		{"xn--bcher-kva.example", "BÜCHER.example", true},
+		{"*.example", "bücher.example", true},
+		{"*.xn--bcher-kva.example", "www.bücher.example", true},
+		{"bucher.example", "bücher.example", false},
+
+		// IP addresses
+		{"10.0.0.1", "10.0.0.1", true},
+		{"10.0.0.1", "10.0.0.2", false},
+		{"::1", "[::1]", true},
+		{"2001:db8::1", "2001:0db8:0:0::1", true},
+		{"*.0.0.1", "10.0.0.1", false},
+		{"10.0.0.1", "foo.bar", false},
+		{"foo.bar", "10.0.0.1", false},
+	}
+
+	for _, tc := range cases {
+		match := MatchHostname(tc.Pattern, tc.Host)
+		if match != tc.Match {
+			t.Errorf("MatchHostname(%q, %q): expected %v but returned %v", tc.Pattern, tc.Host, tc.Match, match)
+		}
+	}
+}
+
+func TestVerifyHostname(t *testing.T) {
+	san := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "legacy.foo.bar"},
+		DNSNames:    []string{"foo.bar", "*.foo.bar", "bücher.example"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")},
+	}
+	commonName := &x509.Certificate{
+		Subject: pkix.Name{CommonName: "*.legacy.bar"},
+	}
+	ipCommonName := &x509.Certificate{
+		Subject: pkix.Name{CommonName: "10.0.0.1"},
+	}
+
+	cases := map[string]struct {
+		Certificate *x509.Certificate
+		Host        string
+		Valid       bool
+	}{
+		"DNS SAN":                      {san, "foo.bar", true},
+		"wildcard DNS SAN":             {san, "www.foo.bar", true},
+		"wildcard host":                {san, "*.foo.bar", true},
+		"internationalized DNS SAN":    {san, "xn--bcher-kva.example", true},
+		"too many labels":              {san, "a.www.foo.bar", false},
+		"common name with a SAN":       {san, "legacy.foo.bar", true},
+		"IP SAN":                       {san, "10.0.0.1", true},
+		"IPv6 SAN":                     {san, "[2001:db8::1]", true},
+		"missing IP SAN":               {san, "10.0.0.2", false},
+		"common name":                  {commonName, "www.legacy.bar", true},
+		"common name without wildcard": {commonName, "legacy.bar", false},
+		"IP in the common name":        {ipCommonName, "10.0.0.1", false},
+		"no certificate":               {nil, "foo.bar", false},
+	}
+
+	for k, tc := range cases {
+		err := VerifyHostname(tc.Host, tc.Certificate)
+		if tc.Valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", k, err)
+		}
+		if !tc.Valid && err == nil {
+			t.Errorf("%s: expected an error", k)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zakjan/cert-chain-resolver/certUtil"
+	"golang.org/x/crypto/ocsp"
+	"k8s.io/ingress-nginx/internal/file"
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+const (
+	// ocspResponseSuffix is the suffix of the file containing the OCSP
+	// response of a certificate, next to its .pem file
+	ocspResponseSuffix = ".ocsp"
+
+	// ocspMinRefreshMargin is the minimum time before the next update of an
+	// OCSP response when it is fetched again
+	ocspMinRefreshMargin = time.Hour
+
+	// ocspRetryInterval is the time before an OCSP response is fetched again
+	// after an error
+	ocspRetryInterval = 5 * time.Minute
+
+	// ocspTimeout is the timeout of the requests to the OCSP responders and
+	// to the issuing certificate URLs
+	ocspTimeout = 5 * time.Second
+
+	// ocspMaxSize is the maximum size of the OCSP responses and of the
+	// certificates of the issuers
+	ocspMaxSize = 1 << 20
+)
+
+// parseOCSPResponse verifies the DER encoded OCSP response of cert signed by
+// issuer or by a responder it delegated and returns it when the certificate
+// is not revoked
+func parseOCSPResponse(der []byte, cert, issuer *x509.Certificate, now time.Time) (*ocsp.Response, error) {
+	resp, err := ocsp.ParseResponseForCert(der, cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	// the signature of the delegated responder is verified by the parser
+	if resp.Certificate != nil && !bytes.Equal(resp.Certificate.Raw, issuer.Raw) {
+		if !hasExtKeyUsage(resp.Certificate, x509.ExtKeyUsageOCSPSigning) {
+			return nil, fmt.Errorf("certificate of the responder without OCSP signing usage")
+		}
+
+		if now.Before(resp.Certificate.NotBefore) || now.After(resp.Certificate.NotAfter) {
+			return nil, fmt.Errorf("certificate of the responder not valid at %v", now)
+		}
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+	case ocsp.Revoked:
+		return nil, fmt.Errorf("certificate revoked at %v", resp.RevokedAt)
+	default:
+		return nil, fmt.Errorf("unknown certificate status")
+	}
+
+	if resp.NextUpdate.IsZero() {
+		return nil, fmt.Errorf("response without next update")
+	}
+
+	if !now.Before(resp.NextUpdate) {
+		return nil, fmt.Errorf("response expired at %v", resp.NextUpdate)
+	}
+
+	return resp, nil
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range cert.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ocspClient returns the client of the requests to the OCSP responders and
+// to the issuing certificate URLs
+func ocspClient() *http.Client {
+	client := chainClient()
+	client.Timeout = ocspTimeout
+
+	return client
+}
+
+// readOCSPBody returns the body of a response of an OCSP responder or of an
+// issuing certificate URL
+func readOCSPBody(url string, resp *http.Response) ([]byte, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v returned the status code %v", url, resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, ocspMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > ocspMaxSize {
+		return nil, fmt.Errorf("%v returned more than %v bytes", url, ocspMaxSize)
+	}
+
+	return data, nil
+}
+
+// findIssuer returns the issuer of cert, from the chain of the PEM file or
+// from its issuing certificate URL
+func findIssuer(cert *x509.Certificate, chain []*x509.Certificate) (*x509.Certificate, error) {
+	for _, c := range chain {
+		if !bytes.Equal(c.Raw, cert.Raw) && cert.CheckSignatureFrom(c) == nil {
+			return c, nil
+		}
+	}
+
+	if len(cert.IssuingCertificateURL) == 0 {
+		return nil, fmt.Errorf("issuer not found in the certificate chain and no issuing certificate URL")
+	}
+
+	url := cert.IssuingCertificateURL[0]
+	resp, err := ocspClient().Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := readOCSPBody(url, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := certUtil.DecodeCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cert.CheckSignatureFrom(issuer); err != nil {
+		return nil, fmt.Errorf("certificate not signed by the certificate of %v: %v", url, err)
+	}
+
+	return issuer, nil
+}
+
+// fetchOCSPResponse returns the DER encoded response of the first OCSP
+// responder of cert and the response it contains
+func fetchOCSPResponse(cert *x509.Certificate, chain []*x509.Certificate) ([]byte, *ocsp.Response, error) {
+	issuer, err := findIssuer(cert, chain)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	responder := cert.OCSPServer[0]
+	resp, err := ocspClient().Post(responder, "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	der, err := readOCSPBody(responder, resp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	status, err := parseOCSPResponse(der, cert, issuer, time.Now())
+	if err != nil {
+		return nil, nil, fmt.Errorf("OCSP responder %v: %v", responder, err)
+	}
+
+	return der, status, nil
+}
+
+// ocspRefreshTime returns when an OCSP response must be fetched again, half
+// way through its validity but at least ocspMinRefreshMargin before it expires
+func ocspRefreshTime(status *ocsp.Response) time.Time {
+	margin := status.NextUpdate.Sub(status.ThisUpdate) / 2
+	if margin < ocspMinRefreshMargin {
+		margin = ocspMinRefreshMargin
+	}
+
+	return status.NextUpdate.Add(-margin)
+}
+
+// staplesOCSPResponse returns true when the OCSP response of the certificate
+// of sslCert is stapled. OpenSSL staples the same response whatever the
+// certificate served, the responses of the Secrets with an ECDSA certificate
+// are not stapled.
+func staplesOCSPResponse(sslCert *ingress.SSLCert) bool {
+	return sslCert.Certificate != nil && len(sslCert.Certificate.OCSPServer) > 0 && sslCert.ECDSA == nil
+}
+
+// UpdateOCSPResponse fetches the OCSP response of the certificate of sslCert.
+// On errors, the previous response is kept until it expires and the response
+// is fetched again after ocspRetryInterval.
+func UpdateOCSPResponse(fs file.Filesystem, sslCert *ingress.SSLCert) error {
+	if !staplesOCSPResponse(sslCert) {
+		return nil
+	}
+
+	cert := sslCert.Certificate
+
+	now := time.Now()
+	sslCert.OCSPRefreshTime = now.Add(ocspRetryInterval)
+	if !now.Before(sslCert.OCSPNextUpdate) {
+		setOCSPResponse(sslCert, nil)
+		sslCert.OCSPNextUpdate = time.Time{}
+	}
+
+	// the issuer is searched in the chain, completed or not
+	pemCertKey, err := ReadPemCertKey(fs, sslCert)
+	if err != nil {
+		return err
+	}
+
+	chain, err := parseCACertificates([]byte(pemCertKey))
+	if err != nil {
+		return fmt.Errorf("unexpected error parsing the certificate chain: %v", err)
+	}
+
+	der, status, err := fetchOCSPResponse(cert, chain)
+	if err != nil {
+		return err
+	}
+
+	setOCSPResponse(sslCert, der)
+	sslCert.OCSPNextUpdate = status.NextUpdate
+	sslCert.OCSPRefreshTime = ocspRefreshTime(status)
+
+	return nil
+}
+
+func setOCSPResponse(sslCert *ingress.SSLCert, der []byte) {
+	sslCert.OCSPResponse = der
+	sslCert.OCSPResponseSHA = ""
+	if len(der) > 0 {
+		sslCert.OCSPResponseSHA = fmt.Sprintf("%x", sha1.Sum(der))
+	}
+}
+
+// NeedsOCSPRefresh returns true when the OCSP response of sslCert must be
+// fetched, for the first time or again
+func NeedsOCSPRefresh(sslCert *ingress.SSLCert, now time.Time) bool {
+	if !staplesOCSPResponse(sslCert) {
+		return false
+	}
+
+	return !now.Before(sslCert.OCSPRefreshTime)
+}
+
+// CopyOCSPResponse copies the OCSP response of from to sslCert when both
+// contain the same certificate, so an updated Secret does not fetch it again
+func CopyOCSPResponse(sslCert, from *ingress.SSLCert) {
+	if !staplesOCSPResponse(sslCert) || from.Certificate == nil || !sslCert.Certificate.Equal(from.Certificate) {
+		return
+	}
+
+	sslCert.OCSPResponse = from.OCSPResponse
+	sslCert.OCSPResponseSHA = from.OCSPResponseSHA
+	sslCert.OCSPRefreshTime = from.OCSPRefreshTime
+	sslCert.OCSPNextUpdate = from.OCSPNextUpdate
+}
+
+// StoreOCSPResponseOnDisk writes the OCSP response of sslCert next to its
+// .pem file and sets OCSPResponseFile. A previous response is removed when
+// there is none to staple.
+func StoreOCSPResponseOnDisk(fs file.Filesystem, name string, sslCert *ingress.SSLCert) error {
+	pemFileName, _ := getPemFileName(name)
+	ocspFileName := strings.TrimSuffix(pemFileName, ".pem") + ocspResponseSuffix
+
+	if len(sslCert.OCSPResponse) == 0 {
+		sslCert.OCSPResponseFile = ""
+
+		err := fs.Remove(ocspFileName)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not remove OCSP response file %v: %v", ocspFileName, err)
+		}
+
+		return nil
+	}
+
+	err := writeFileAtomically(fs, ocspFileName, sslCert.OCSPResponse)
+	if err != nil {
+		return fmt.Errorf("could not write data to OCSP response file %v: %v", ocspFileName, err)
+	}
+
+	sslCert.OCSPResponseFile = ocspFileName
+
+	return nil
+}
@@ -0,0 +1,330 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"bytes"
+	cryptorand "crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"k8s.io/ingress-nginx/internal/ingress"
+)
+
+// newOCSPCert creates a certificate signed by ca with the given OCSP responder
+func newOCSPCert(t *testing.T, ca *keyPair, responder string, usages ...x509.ExtKeyUsage) *keyPair {
+	return newOCSPCertValidUntil(t, ca, time.Now().Add(duration365d), responder, usages...)
+}
+
+// newOCSPCertValidUntil creates a certificate signed by ca with the given OCSP
+// responder which expires at notAfter
+func newOCSPCertValidUntil(t *testing.T, ca *keyPair, notAfter time.Time, responder string, usages ...x509.ExtKeyUsage) *keyPair {
+	key, err := newPrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error creating private key: %v", err)
+	}
+
+	serial, err := cryptorand.Int(cryptorand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("unexpected error creating serial: %v", err)
+	}
+
+	certTmpl := x509.Certificate{
+		Subject:      pkix.Name{CommonName: "ocsp.example.com"},
+		DNSNames:     []string{"ocsp.example.com"},
+		SerialNumber: serial,
+		NotBefore:    ca.Cert.NotBefore,
+		NotAfter:     notAfter.UTC(),
+		KeyUsage:     x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usages,
+	}
+	if responder != "" {
+		certTmpl.OCSPServer = []string{responder}
+	}
+
+	der, err := x509.CreateCertificate(cryptorand.Reader, &certTmpl, ca.Cert, key.Public(), ca.Key)
+	if err != nil {
+		t.Fatalf("unexpected error creating certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unexpected error parsing certificate: %v", err)
+	}
+
+	return &keyPair{Key: key, Cert: cert}
+}
+
+// newOCSPResponse returns an OCSP response with the status of cert signed by
+// signer, which is embedded in the response when it is not the issuer
+func newOCSPResponse(t *testing.T, cert *x509.Certificate, issuer, signer *keyPair, revoked bool, nextUpdate time.Time) []byte {
+	tmpl := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: cert.SerialNumber,
+		ThisUpdate:   time.Now().Add(-time.Hour).UTC().Truncate(time.Second),
+		NextUpdate:   nextUpdate.UTC().Truncate(time.Second),
+	}
+	if revoked {
+		tmpl.Status = ocsp.Revoked
+		tmpl.RevokedAt = tmpl.ThisUpdate
+	}
+	if signer != issuer {
+		tmpl.Certificate = signer.Cert
+	}
+
+	der, err := ocsp.CreateResponse(issuer.Cert, signer.Cert, tmpl, signer.Key)
+	if err != nil {
+		t.Fatalf("unexpected error creating OCSP response: %v", err)
+	}
+
+	return der
+}
+
+func TestParseOCSPResponse(t *testing.T) {
+	ca, err := newCA("ocsp-ca")
+	if err != nil {
+		t.Fatalf("unexpected error creating CA: %v", err)
+	}
+
+	other, err := newCA("other-ca")
+	if err != nil {
+		t.Fatalf("unexpected error creating CA: %v", err)
+	}
+
+	cert := newOCSPCert(t, ca, "http://ocsp.example.com")
+	responder := newOCSPCert(t, ca, "", x509.ExtKeyUsageOCSPSigning)
+	expiredResponder := newOCSPCertValidUntil(t, ca, time.Now().Add(-time.Minute), "", x509.ExtKeyUsageOCSPSigning)
+	server := newOCSPCert(t, ca, "", x509.ExtKeyUsageServerAuth)
+	otherResponder := newOCSPCert(t, other, "", x509.ExtKeyUsageOCSPSigning)
+	otherCert := newOCSPCert(t, ca, "http://ocsp.example.com")
+
+	nextUpdate := time.Now().Add(24 * time.Hour)
+
+	testCases := []struct {
+		name      string
+		response  []byte
+		expectErr bool
+	}{
+		{"signed by the issuer", newOCSPResponse(t, cert.Cert, ca, ca, false, nextUpdate), false},
+		{"signed by a delegated responder", newOCSPResponse(t, cert.Cert, ca, responder, false, nextUpdate), false},
+		{"revoked", newOCSPResponse(t, cert.Cert, ca, ca, true, nextUpdate), true},
+		{"expired", newOCSPResponse(t, cert.Cert, ca, ca, false, time.Now().Add(-time.Minute)), true},
+		{"signed by another CA", newOCSPResponse(t, cert.Cert, ca, other, false, nextUpdate), true},
+		{"signed by a responder without OCSP signing usage", newOCSPResponse(t, cert.Cert, ca, server, false, nextUpdate), true},
+		{"signed by an expired responder", newOCSPResponse(t, cert.Cert, ca, expiredResponder, false, nextUpdate), true},
+		{"signed by a responder of another CA", newOCSPResponse(t, cert.Cert, ca, otherResponder, false, nextUpdate), true},
+		{"status of another certificate", newOCSPResponse(t, otherCert.Cert, ca, ca, false, nextUpdate), true},
+		{"invalid", []byte("invalid"), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, err := parseOCSPResponse(tc.response, cert.Cert, ca.Cert, time.Now())
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected an error but none was returned")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !status.NextUpdate.Equal(nextUpdate.UTC().Truncate(time.Second)) {
+				t.Errorf("expected next update %v but %v was returned", nextUpdate, status.NextUpdate)
+			}
+		})
+	}
+}
+
+func TestOCSPRefreshTime(t *testing.T) {
+	thisUpdate := time.Now()
+
+	testCases := []struct {
+		validity time.Duration
+		expected time.Duration
+	}{
+		{7 * 24 * time.Hour, 84 * time.Hour},
+		{90 * time.Minute, 30 * time.Minute},
+		{30 * time.Minute, -30 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		status := &ocsp.Response{ThisUpdate: thisUpdate, NextUpdate: thisUpdate.Add(tc.validity)}
+		refresh := ocspRefreshTime(status)
+		if !refresh.Equal(thisUpdate.Add(tc.expected)) {
+			t.Errorf("expected the response valid %v to be refreshed after %v but %v was returned", tc.validity, tc.expected, refresh.Sub(thisUpdate))
+		}
+	}
+}
+
+func TestUpdateOCSPResponse(t *testing.T) {
+	ca, err := newCA("ocsp-ca")
+	if err != nil {
+		t.Fatalf("unexpected error creating CA: %v", err)
+	}
+
+	var response []byte
+	var requested []byte
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested, _ = ioutil.ReadAll(r.Body)
+		w.Write(response)
+	}))
+	defer s.Close()
+
+	cert := newOCSPCert(t, ca, s.URL, x509.ExtKeyUsageServerAuth)
+	response = newOCSPResponse(t, cert.Cert, ca, ca, false, time.Now().Add(48*time.Hour))
+
+	// the issuer is the second certificate of the chain
+	chain := append(encodeCertPEM(cert.Cert), encodeCertPEM(ca.Cert)...)
+
+	sslCert, err := CreateSSLCert(chain, encodePrivateKeyPEM(cert.Key))
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	if requested != nil {
+		t.Fatalf("expected the OCSP response not to be fetched while the certificate is created")
+	}
+
+	if !NeedsOCSPRefresh(sslCert, time.Now()) {
+		t.Fatalf("expected the OCSP response of a new certificate to be fetched")
+	}
+
+	fs := newFS(t)
+
+	err = UpdateOCSPResponse(fs, sslCert)
+	if err != nil {
+		t.Fatalf("unexpected error fetching the OCSP response: %v", err)
+	}
+
+	req, err := ocsp.ParseRequest(requested)
+	if err != nil {
+		t.Fatalf("unexpected error parsing the OCSP request: %v", err)
+	}
+
+	if req.SerialNumber.Cmp(cert.Cert.SerialNumber) != 0 {
+		t.Errorf("expected the OCSP request of the certificate to be sent")
+	}
+
+	if !bytes.Equal(sslCert.OCSPResponse, response) {
+		t.Fatalf("expected the OCSP response to be returned")
+	}
+
+	if sslCert.OCSPResponseSHA == "" {
+		t.Errorf("expected the sha of the OCSP response to be set")
+	}
+
+	if NeedsOCSPRefresh(sslCert, time.Now()) {
+		t.Errorf("expected the OCSP response not to be fetched again before half of its validity")
+	}
+
+	if !NeedsOCSPRefresh(sslCert, time.Now().Add(24*time.Hour)) {
+		t.Errorf("expected the OCSP response to be fetched again after half of its validity")
+	}
+
+	// the response is kept when the certificate is synced again
+	synced, err := CreateSSLCert(chain, encodePrivateKeyPEM(cert.Key))
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	CopyOCSPResponse(synced, sslCert)
+	if synced.OCSPResponseSHA != sslCert.OCSPResponseSHA || NeedsOCSPRefresh(synced, time.Now()) {
+		t.Errorf("expected the OCSP response to be copied to the certificate synced again")
+	}
+
+	// the response is not stapled with an ECDSA certificate
+	dual, err := CreateSSLCert(chain, encodePrivateKeyPEM(cert.Key))
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	dual.ECDSA = &ingress.SSLCert{}
+	CopyOCSPResponse(dual, sslCert)
+	if len(dual.OCSPResponse) != 0 || NeedsOCSPRefresh(dual, time.Now()) {
+		t.Errorf("expected no OCSP response for a certificate with an ECDSA certificate")
+	}
+
+	err = StoreOCSPResponseOnDisk(fs, "default-ocsp", sslCert)
+	if err != nil {
+		t.Fatalf("unexpected error storing OCSP response: %v", err)
+	}
+
+	if sslCert.OCSPResponseFile != "/etc/ingress-controller/ssl/default-ocsp.ocsp" {
+		t.Errorf("unexpected OCSP response file %v", sslCert.OCSPResponseFile)
+	}
+
+	content, err := fs.ReadFile(sslCert.OCSPResponseFile)
+	if err != nil {
+		t.Fatalf("unexpected error reading OCSP response file: %v", err)
+	}
+
+	if !bytes.Equal(content, response) {
+		t.Errorf("expected the OCSP response to be written")
+	}
+
+	// the previous response is stapled until it expires when the responder fails
+	valid := response
+	response = []byte("invalid")
+
+	err = UpdateOCSPResponse(fs, sslCert)
+	if err == nil {
+		t.Fatalf("expected an error fetching an invalid OCSP response")
+	}
+
+	if !bytes.Equal(sslCert.OCSPResponse, valid) {
+		t.Errorf("expected the previous OCSP response to be kept")
+	}
+
+	if NeedsOCSPRefresh(sslCert, time.Now()) || !NeedsOCSPRefresh(sslCert, time.Now().Add(ocspRetryInterval)) {
+		t.Errorf("expected the OCSP response to be fetched again after %v", ocspRetryInterval)
+	}
+
+	sslCert.OCSPNextUpdate = time.Now().Add(-time.Minute)
+
+	err = UpdateOCSPResponse(fs, sslCert)
+	if err == nil {
+		t.Fatalf("expected an error fetching an invalid OCSP response")
+	}
+
+	if len(sslCert.OCSPResponse) != 0 || sslCert.OCSPResponseSHA != "" {
+		t.Errorf("expected the expired OCSP response to be removed")
+	}
+
+	err = StoreOCSPResponseOnDisk(fs, "default-ocsp", sslCert)
+	if err != nil {
+		t.Fatalf("unexpected error storing OCSP response: %v", err)
+	}
+
+	if sslCert.OCSPResponseFile != "" {
+		t.Errorf("expected no OCSP response file but %v was returned", sslCert.OCSPResponseFile)
+	}
+
+	if _, err := fs.Stat("/etc/ingress-controller/ssl/default-ocsp.ocsp"); err == nil {
+		t.Errorf("expected the previous OCSP response file to be removed")
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// SPKIPin returns the pin of the public key of the certificate, the base64
+// encoded SHA-256 hash of its subject public key info with the sha256/ prefix
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// VerifyPins checks the public key of the certificate matches one of the pins
+func VerifyPins(cert *x509.Certificate, pins []string) error {
+	if cert == nil {
+		return fmt.Errorf("no certificate")
+	}
+
+	pin := SPKIPin(cert)
+	for _, p := range pins {
+		if p == pin {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("the public key pin %v does not match any of the pins %v", pin, strings.Join(pins, ", "))
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ssl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyPins(t *testing.T) {
+	cert, ca, err := generateRSACerts("echoheaders")
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	pin := SPKIPin(cert.Cert)
+	if !strings.HasPrefix(pin, "sha256/") || len(pin) != 51 {
+		t.Fatalf("unexpected pin %v", pin)
+	}
+	if pin == SPKIPin(ca.Cert) {
+		t.Fatalf("expected different pins for different public keys")
+	}
+
+	if err := VerifyPins(cert.Cert, []string{SPKIPin(ca.Cert), pin}); err != nil {
+		t.Errorf("unexpected error verifying the pins: %v", err)
+	}
+
+	err = VerifyPins(cert.Cert, []string{SPKIPin(ca.Cert)})
+	if err == nil {
+		t.Fatalf("expected an error verifying the pins")
+	}
+	if !strings.Contains(err.Error(), pin) {
+		t.Errorf("expected the error to contain the pin of the certificate: %v", err)
+	}
+
+	if err := VerifyPins(cert.Cert, nil); err == nil {
+		t.Errorf("expected an error without pins")
+	}
+	if err := VerifyPins(nil, []string{pin}); err == nil {
+		t.Errorf("expected an error without certificate")
+	}
+}
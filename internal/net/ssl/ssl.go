@@ -18,8 +18,10 @@ package ssl
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha1"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -29,16 +31,21 @@ import (
 	"fmt"
 	"math/big"
 	"net"
+	"net/http"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/zakjan/cert-chain-resolver/certUtil"
+	apiv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/ingress-nginx/internal/file"
 	"k8s.io/ingress-nginx/internal/ingress"
 	ngx_config "k8s.io/ingress-nginx/internal/ingress/controller/config"
+	"k8s.io/ingress-nginx/internal/logging"
+	"k8s.io/ingress-nginx/internal/net/spiffe"
 	"k8s.io/ingress-nginx/internal/watch"
 	"k8s.io/klog"
 )
@@ -49,6 +56,11 @@ var (
 
 const (
 	fakeCertificateName = "default-fake-certificate"
+
+	// keys of the ECDSA certificate of a TLS Secret also containing an RSA
+	// certificate
+	ecdsaCertKey       = "tls-ecdsa.crt"
+	ecdsaPrivateKeyKey = "tls-ecdsa.key"
 )
 
 // getPemFileName returns absolute file path and file name of pem cert related to given fullSecretName
@@ -57,11 +69,87 @@ func getPemFileName(fullSecretName string) (string, string) {
 	return fmt.Sprintf("%v/%v", file.DefaultSSLDirectory, pemName), pemName
 }
 
-func verifyPemCertAgainstRootCA(pemCert *x509.Certificate, ca []byte) error {
-	bundle := x509.NewCertPool()
-	bundle.AppendCertsFromPEM(ca)
+// parseCACertificates returns all the certificates of a PEM encoded CA bundle.
+// Blocks other than certificates after the first one are ignored.
+func parseCACertificates(ca []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	rest := ca
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			// If the first certificate does not start with 'BEGIN CERTIFICATE' it's invalid and must not be used.
+			if len(certs) == 0 {
+				return nil, fmt.Errorf("no certificate PEM data found, make sure certificate content starts with 'BEGIN CERTIFICATE'")
+			}
+
+			logging.V(3).Infof("ignoring PEM block of type %v in CA bundle", block.Type)
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no valid PEM formatted block found")
+	}
+
+	return certs, nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// earliestExpireTime returns the first expiration of the given certificates
+func earliestExpireTime(certs []*x509.Certificate) time.Time {
+	var expireTime time.Time
+	for _, cert := range certs {
+		if expireTime.IsZero() || cert.NotAfter.Before(expireTime) {
+			expireTime = cert.NotAfter
+		}
+	}
+
+	return expireTime
+}
+
+// verifyPemCertAgainstRootCA verifies pemCert using the self-signed certificates
+// of the CA bundle as roots and the others as intermediates. A bundle without
+// self-signed certificate is a partial chain, all its certificates are trusted.
+func verifyPemCertAgainstRootCA(pemCert *x509.Certificate, ca []*x509.Certificate) error {
+	roots := x509.NewCertPool()
+	intermediates := x509.NewCertPool()
+
+	hasRoot := false
+	for _, cert := range ca {
+		if isSelfSigned(cert) {
+			hasRoot = true
+			break
+		}
+	}
+
+	for _, cert := range ca {
+		if hasRoot && !isSelfSigned(cert) {
+			intermediates.AddCert(cert)
+			continue
+		}
+
+		roots.AddCert(cert)
+	}
+
 	opts := x509.VerifyOptions{
-		Roots: bundle,
+		Roots:         roots,
+		Intermediates: intermediates,
 	}
 
 	_, err := pemCert.Verify(opts)
@@ -74,6 +162,75 @@ func verifyPemCertAgainstRootCA(pemCert *x509.Certificate, ca []byte) error {
 
 // CreateSSLCert validates cert and key, extracts common names and returns corresponding SSLCert object
 func CreateSSLCert(cert, key []byte) (*ingress.SSLCert, error) {
+	sslCert, err := newSSLCert(cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return sslCert, nil
+}
+
+// CreateDualSSLCert is similar to CreateSSLCert but it also validates the ECDSA
+// ecdsaCert and ecdsaKey served instead of cert and key to the clients supporting
+// them. cert must not be an ECDSA certificate.
+func CreateDualSSLCert(cert, key, ecdsaCert, ecdsaKey []byte) (*ingress.SSLCert, error) {
+	sslCert, err := newSSLCert(cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if sslCert.Certificate.PublicKeyAlgorithm == x509.ECDSA {
+		return nil, fmt.Errorf("the certificate is an ECDSA certificate, an RSA certificate is expected with an ECDSA certificate")
+	}
+
+	ecdsa, err := newSSLCert(ecdsaCert, ecdsaKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ECDSA certificate: %v", err)
+	}
+
+	if ecdsa.Certificate.PublicKeyAlgorithm != x509.ECDSA {
+		return nil, fmt.Errorf("the ECDSA certificate has a %v public key", ecdsa.Certificate.PublicKeyAlgorithm)
+	}
+
+	// the certificate served is selected by OpenSSL after the OCSP response
+	// is stapled, so the responses are not fetched. The earliest expiration
+	// is reported.
+	sslCert.ECDSA = ecdsa
+	if ecdsa.ExpireTime.Before(sslCert.ExpireTime) {
+		sslCert.ExpireTime = ecdsa.ExpireTime
+	}
+
+	return sslCert, nil
+}
+
+// CreateTLSSecretCert validates the certificate and key of the data of a
+// TLS Secret and returns the corresponding SSLCert object, with the ECDSA
+// certificate of the keys tls-ecdsa.crt and tls-ecdsa.key when present
+func CreateTLSSecretCert(data map[string][]byte) (*ingress.SSLCert, error) {
+	cert, okCert := data[apiv1.TLSCertKey]
+	key, okKey := data[apiv1.TLSPrivateKeyKey]
+	if !okCert || len(cert) == 0 {
+		return nil, fmt.Errorf("key %q is missing", apiv1.TLSCertKey)
+	}
+	if !okKey || len(key) == 0 {
+		return nil, fmt.Errorf("key %q is missing", apiv1.TLSPrivateKeyKey)
+	}
+
+	ecdsaCert, okECDSACert := data[ecdsaCertKey]
+	ecdsaKey, okECDSAKey := data[ecdsaPrivateKeyKey]
+	switch {
+	case okECDSACert && okECDSAKey:
+		return CreateDualSSLCert(cert, key, ecdsaCert, ecdsaKey)
+	case okECDSACert || okECDSAKey:
+		return nil, fmt.Errorf("keys %q and %q must be both present", ecdsaCertKey, ecdsaPrivateKeyKey)
+	}
+
+	return CreateSSLCert(cert, key)
+}
+
+// newSSLCert validates cert and key and returns the corresponding SSLCert
+// object without OCSP response
+func newSSLCert(cert, key []byte) (*ingress.SSLCert, error) {
 	var pemCertBuffer bytes.Buffer
 	pemCertBuffer.Write(cert)
 
@@ -108,6 +265,12 @@ func CreateSSLCert(cert, key []byte) (*ingress.SSLCert, error) {
 		return nil, fmt.Errorf("certificate and private key does not have a matching public key: %v", err)
 	}
 
+	if ngx_config.EnableFIPSMode {
+		if err := verifyFIPSCertificate(pemCert); err != nil {
+			return nil, fmt.Errorf("invalid certificate in FIPS mode: %v", err)
+		}
+	}
+
 	cn := sets.NewString(pemCert.Subject.CommonName)
 	for _, dns := range pemCert.DNSNames {
 		if !cn.Has(dns) {
@@ -116,7 +279,7 @@ func CreateSSLCert(cert, key []byte) (*ingress.SSLCert, error) {
 	}
 
 	if len(pemCert.Extensions) > 0 {
-		klog.V(3).Info("parsing ssl certificate extensions")
+		logging.V(3).Info("parsing ssl certificate extensions")
 		for _, ext := range getExtension(pemCert, oidExtensionSubjectAltName) {
 			dns, _, _, err := parseSANExtension(ext.Value)
 			if err != nil {
@@ -133,32 +296,26 @@ func CreateSSLCert(cert, key []byte) (*ingress.SSLCert, error) {
 	}
 
 	return &ingress.SSLCert{
-		Certificate: pemCert,
-		CN:          cn.List(),
-		ExpireTime:  pemCert.NotAfter,
-		PemCertKey:  pemCertBuffer.String(),
+		Certificate:   pemCert,
+		CN:            cn.List(),
+		ExpireTime:    pemCert.NotAfter,
+		PemCertKey:    pemCertBuffer.String(),
+		PemCertKeySHA: fmt.Sprintf("%x", sha1.Sum(pemCertBuffer.Bytes())),
 	}, nil
 }
 
 // CreateCACert is similar to CreateSSLCert but it creates instance of SSLCert only based on given ca after
 // parsing and validating it
 func CreateCACert(ca []byte) (*ingress.SSLCert, error) {
-	pemCABlock, _ := pem.Decode(ca)
-	if pemCABlock == nil {
-		return nil, fmt.Errorf("no valid PEM formatted block found")
-	}
-	// If the first certificate does not start with 'BEGIN CERTIFICATE' it's invalid and must not be used.
-	if pemCABlock.Type != "CERTIFICATE" {
-		return nil, fmt.Errorf("no certificate PEM data found, make sure certificate content starts with 'BEGIN CERTIFICATE'")
-	}
-
-	pemCert, err := x509.ParseCertificate(pemCABlock.Bytes)
+	certs, err := parseCACertificates(ca)
 	if err != nil {
 		return nil, err
 	}
 
 	return &ingress.SSLCert{
-		Certificate: pemCert,
+		Certificate:    certs[0],
+		CACertificates: certs,
+		CAExpireTime:   earliestExpireTime(certs),
 	}, nil
 }
 
@@ -167,23 +324,149 @@ func CreateCACert(ca []byte) (*ingress.SSLCert, error) {
 func StoreSSLCertOnDisk(fs file.Filesystem, name string, sslCert *ingress.SSLCert) error {
 	pemFileName, _ := getPemFileName(name)
 
-	pemFile, err := fs.Create(pemFileName)
+	err := writeFileAtomically(fs, pemFileName, []byte(sslCert.PemCertKey))
 	if err != nil {
-		return fmt.Errorf("could not create PEM certificate file %v: %v", pemFileName, err)
+		return fmt.Errorf("could not write data to PEM file %v: %v", pemFileName, err)
+	}
+
+	sslCert.PemFileName = pemFileName
+	sslCert.PemSHA = fileSHA1(fs, pemFileName)
+
+	return nil
+}
+
+// StorePemCertKeyOnDisk removes PemCertKey from the given sslCert, keeping only the
+// path of the .pem file containing it. The file is created if the certificate was
+// not stored on disk, or encrypted when SetPemEncryptionKey was called. The content
+// can be read again using ReadPemCertKey.
+func StorePemCertKeyOnDisk(fs file.Filesystem, name string, sslCert *ingress.SSLCert) error {
+	if sslCert.PemCertKey == "" {
+		return nil
 	}
-	defer pemFile.Close()
 
-	_, err = pemFile.Write([]byte(sslCert.PemCertKey))
+	pemFileName, _ := getPemFileName(name)
+
+	if aead := getPemEncryption(); aead != nil {
+		// the file read by NGINX is not reused, it must not be the only copy
+		// of the private key on the filesystem
+		encrypted, err := encryptPem(aead, []byte(sslCert.PemCertKey))
+		if err != nil {
+			return fmt.Errorf("could not encrypt PEM file %v: %v", pemFileName, err)
+		}
+
+		pemFileName += encryptedPemSuffix
+
+		err = writeFileAtomically(fs, pemFileName, encrypted)
+		if err != nil {
+			return fmt.Errorf("could not write data to PEM file %v: %v", pemFileName, err)
+		}
+	} else if !isSSLCertStoredOnDisk(sslCert) {
+		err := writeFileAtomically(fs, pemFileName, []byte(sslCert.PemCertKey))
+		if err != nil {
+			return fmt.Errorf("could not write data to PEM file %v: %v", pemFileName, err)
+		}
+	}
+
+	sslCert.PemCertKeyFileName = pemFileName
+	sslCert.PemCertKey = ""
+
+	return nil
+}
+
+// ReadPemCertKey returns the certificate and key concatenated of the given sslCert,
+// decompressing them or reading them from disk if they are not kept in memory. The
+// CA appended to the file by ConfigureCACertWithCertAndKey is not returned.
+func ReadPemCertKey(fs file.Filesystem, sslCert *ingress.SSLCert) (string, error) {
+	if len(sslCert.PemCertKeyCompressed) > 0 {
+		data, err := decompressPem(sslCert.PemCertKeyCompressed)
+		if err != nil {
+			return "", fmt.Errorf("could not decompress certificate and key: %v", err)
+		}
+
+		return string(data), nil
+	}
+
+	if sslCert.PemCertKey != "" || sslCert.PemCertKeyFileName == "" {
+		return sslCert.PemCertKey, nil
+	}
+
+	data, err := fs.ReadFile(sslCert.PemCertKeyFileName)
 	if err != nil {
-		return fmt.Errorf("could not write data to PEM file %v: %v", pemFileName, err)
+		return "", fmt.Errorf("could not read PEM file %v: %v", sslCert.PemCertKeyFileName, err)
 	}
 
-	sslCert.PemFileName = pemFileName
-	sslCert.PemSHA = file.SHA1(pemFileName)
+	if strings.HasSuffix(sslCert.PemCertKeyFileName, encryptedPemSuffix) {
+		aead := getPemEncryption()
+		if aead == nil {
+			return "", fmt.Errorf("no encryption key to read PEM file %v", sslCert.PemCertKeyFileName)
+		}
+
+		data, err = decryptPem(aead, data)
+		if err != nil {
+			return "", fmt.Errorf("could not decrypt PEM file %v: %v", sslCert.PemCertKeyFileName, err)
+		}
+	}
+
+	// the certificates are followed by the key
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return "", fmt.Errorf("no private key found in PEM file %v", sslCert.PemCertKeyFileName)
+		}
+
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			return string(data[:len(data)-len(rest)]), nil
+		}
+	}
+}
+
+// VerifyPemFiles returns an error when the content of the files of the given
+// sslCert does not match the checksums computed when they were written.
+func VerifyPemFiles(fs file.Filesystem, sslCert *ingress.SSLCert) error {
+	if sslCert.PemFileName != "" && sslCert.PemSHA != "" {
+		if sha := fileSHA1(fs, sslCert.PemFileName); sha != sslCert.PemSHA {
+			return fmt.Errorf("the checksum of PEM file %v is %q instead of %q", sslCert.PemFileName, sha, sslCert.PemSHA)
+		}
+	}
+
+	if sslCert.CAFileName != "" && sslCert.CAFileName != sslCert.PemFileName && sslCert.CASHA != "" {
+		if sha := fileSHA1(fs, sslCert.CAFileName); sha != sslCert.CASHA {
+			return fmt.Errorf("the checksum of CA file %v is %q instead of %q", sslCert.CAFileName, sha, sslCert.CASHA)
+		}
+	}
+
+	if sslCert.PemCertKeyFileName != "" && sslCert.PemCertKeySHA != "" {
+		content, err := ReadPemCertKey(fs, sslCert)
+		if err != nil {
+			return err
+		}
+
+		if sha := fmt.Sprintf("%x", sha1.Sum([]byte(content))); sha != sslCert.PemCertKeySHA {
+			return fmt.Errorf("the checksum of the certificate and key of PEM file %v is %q instead of %q",
+				sslCert.PemCertKeyFileName, sha, sslCert.PemCertKeySHA)
+		}
+	}
+
+	if sslCert.ECDSA != nil {
+		return VerifyPemFiles(fs, sslCert.ECDSA)
+	}
 
 	return nil
 }
 
+// fileSHA1 returns the checksum of a file like file.SHA1, reading it from fs.
+// A file that can not be read has no checksum.
+func fileSHA1(fs file.Filesystem, name string) string {
+	data, err := fs.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", sha1.Sum(data))
+}
+
 func isSSLCertStoredOnDisk(sslCert *ingress.SSLCert) bool {
 	return len(sslCert.PemFileName) > 0
 }
@@ -191,10 +474,9 @@ func isSSLCertStoredOnDisk(sslCert *ingress.SSLCert) bool {
 // ConfigureCACertWithCertAndKey appends ca into existing PEM file consisting of cert and key
 // and sets relevant fields in sslCert object
 func ConfigureCACertWithCertAndKey(fs file.Filesystem, name string, ca []byte, sslCert *ingress.SSLCert) error {
-	err := verifyPemCertAgainstRootCA(sslCert.Certificate, ca)
+	certs, err := verifyCABundle(ca, sslCert)
 	if err != nil {
-		oe := fmt.Sprintf("failed to verify certificate chain: \n\t%s\n", err)
-		return errors.New(oe)
+		return err
 	}
 
 	certAndKey, err := fs.ReadFile(sslCert.PemFileName)
@@ -202,32 +484,37 @@ func ConfigureCACertWithCertAndKey(fs file.Filesystem, name string, ca []byte, s
 		return fmt.Errorf("could not read file %v for writing additional CA chains: %v", sslCert.PemFileName, err)
 	}
 
-	f, err := fs.Create(sslCert.PemFileName)
-	if err != nil {
-		return fmt.Errorf("could not create PEM file %v: %v", sslCert.PemFileName, err)
-	}
-	defer f.Close()
+	bundle := append(append(certAndKey, '\n'), ca...)
 
-	_, err = f.Write(certAndKey)
+	err = writeFileAtomically(fs, sslCert.PemFileName, bundle)
 	if err != nil {
-		return fmt.Errorf("could not write cert and key bundle to cert file %v: %v", sslCert.PemFileName, err)
+		return fmt.Errorf("could not write cert, key and ca bundle to cert file %v: %v", sslCert.PemFileName, err)
 	}
 
-	_, err = f.Write([]byte("\n"))
+	sslCert.CAFileName = sslCert.PemFileName
+	sslCert.CACertificates = certs
+	sslCert.CAExpireTime = earliestExpireTime(certs)
+	// since we updated sslCert.PemFileName we need to recalculate the checksum
+	sslCert.PemSHA = fileSHA1(fs, sslCert.PemFileName)
+
+	return nil
+}
+
+// verifyCABundle returns the certificates of the CA bundle ca after verifying
+// the certificate of sslCert against them
+func verifyCABundle(ca []byte, sslCert *ingress.SSLCert) ([]*x509.Certificate, error) {
+	certs, err := parseCACertificates(ca)
 	if err != nil {
-		return fmt.Errorf("could not append newline to cert file %v: %v", sslCert.PemFileName, err)
+		return nil, fmt.Errorf("could not parse CA bundle: %v", err)
 	}
 
-	_, err = f.Write(ca)
+	err = verifyPemCertAgainstRootCA(sslCert.Certificate, certs)
 	if err != nil {
-		return fmt.Errorf("could not write ca data to cert file %v: %v", sslCert.PemFileName, err)
+		oe := fmt.Sprintf("failed to verify certificate chain: \n\t%s\n", err)
+		return nil, errors.New(oe)
 	}
 
-	sslCert.CAFileName = sslCert.PemFileName
-	// since we updated sslCert.PemFileName we need to recalculate the checksum
-	sslCert.PemSHA = file.SHA1(sslCert.PemFileName)
-
-	return nil
+	return certs, nil
 }
 
 // ConfigureCACert is similar to ConfigureCACertWithCertAndKey but it creates a separate file
@@ -236,22 +523,16 @@ func ConfigureCACert(fs file.Filesystem, name string, ca []byte, sslCert *ingres
 	caName := fmt.Sprintf("ca-%v.pem", name)
 	fileName := fmt.Sprintf("%v/%v", file.DefaultSSLDirectory, caName)
 
-	f, err := fs.Create(fileName)
-	if err != nil {
-		return fmt.Errorf("could not write CA file %v: %v", fileName, err)
-	}
-	defer f.Close()
-
-	_, err = f.Write(ca)
+	err := writeFileAtomically(fs, fileName, ca)
 	if err != nil {
 		return fmt.Errorf("could not write CA file %v: %v", fileName, err)
 	}
 
 	sslCert.PemFileName = fileName
 	sslCert.CAFileName = fileName
-	sslCert.PemSHA = file.SHA1(fileName)
+	sslCert.PemSHA = fileSHA1(fs, fileName)
 
-	klog.V(3).Infof("Created CA Certificate for Authentication: %v", fileName)
+	logging.V(3).Infof("Created CA Certificate for Authentication: %v", fileName)
 
 	return nil
 }
@@ -322,13 +603,44 @@ func parseSANExtension(value []byte) (dnsNames, emailAddresses []string, ipAddre
 	return
 }
 
+// writeFileAtomically writes data to a temporary file of the directory of
+// path and renames it to path. NGINX never reads a truncated file, even when
+// the controller is stopped in the middle of the write.
+func writeFileAtomically(fs file.Filesystem, path string, data []byte) error {
+	tempFile, err := fs.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	_, err = tempFile.Write(data)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = fs.Rename(tempFile.Name(), path)
+	}
+
+	if err != nil {
+		fs.Remove(tempFile.Name())
+		return err
+	}
+
+	return nil
+}
+
 // AddOrUpdateDHParam creates a dh parameters file with the specified name
 func AddOrUpdateDHParam(name string, dh []byte, fs file.Filesystem) (string, error) {
 	pemFileName, pemName := getPemFileName(name)
 
 	tempPemFile, err := fs.TempFile(file.DefaultSSLDirectory, pemName)
 
-	klog.V(3).Infof("Creating temp file %v for DH param: %v", tempPemFile.Name(), pemName)
+	logging.V(3).Infof("Creating temp file %v for DH param: %v", tempPemFile.Name(), pemName)
 	if err != nil {
 		return "", fmt.Errorf("could not create temp pem file %v: %v", pemFileName, err)
 	}
@@ -452,7 +764,7 @@ func fullChainCert(in []byte) ([]byte, error) {
 		return nil, nil
 	}
 
-	certs, err := certUtil.FetchCertificateChain(cert)
+	certs, err := fetchCertificateChain(cert)
 	if err != nil {
 		return nil, err
 	}
@@ -460,22 +772,83 @@ func fullChainCert(in []byte) ([]byte, error) {
 	return certUtil.EncodeCertificates(certs), nil
 }
 
-// IsValidHostname checks if a hostname is valid in a list of common names
-func IsValidHostname(hostname string, commonNames []string) bool {
-	for _, cn := range commonNames {
-		if strings.EqualFold(hostname, cn) {
-			return true
+var (
+	chainResolverLock sync.Mutex
+	// chainResolver is the DNS server (host:port) resolving the hosts of the
+	// URLs of the intermediate certificates
+	chainResolver string
+)
+
+// SetChainResolver sets the DNS server used to fetch the intermediate
+// certificates. The system resolvers are used when address is empty.
+func SetChainResolver(address string) {
+	chainResolverLock.Lock()
+	defer chainResolverLock.Unlock()
+
+	chainResolver = address
+}
+
+// chainClient returns the HTTP client fetching the intermediate certificates
+func chainClient() *http.Client {
+	chainResolverLock.Lock()
+	address := chainResolver
+	chainResolverLock.Unlock()
+
+	if address == "" {
+		return &http.Client{Timeout: 30 * time.Second}
+	}
+
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			// the DNS proxy only receives UDP queries
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				d := net.Dialer{}
+				return d.DialContext(ctx, "udp", address)
+			},
+		},
+	}
+
+	return &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+}
+
+// fetchCertificateChain follows the issuing certificate URLs of the
+// certificates until the root certificate
+func fetchCertificateChain(cert *x509.Certificate) ([]*x509.Certificate, error) {
+	client := chainClient()
+	certs := []*x509.Certificate{cert}
+
+	for certs[len(certs)-1].IssuingCertificateURL != nil {
+		url := certs[len(certs)-1].IssuingCertificateURL[0]
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := readOCSPBody(url, resp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
 		}
 
-		labels := strings.Split(hostname, ".")
-		labels[0] = "*"
-		candidate := strings.Join(labels, ".")
-		if strings.EqualFold(candidate, cn) {
-			return true
+		parent, err := certUtil.DecodeCertificate(data)
+		if err != nil {
+			return nil, err
 		}
+
+		// the root certificate is not part of the chain
+		if parent.CheckSignatureFrom(parent) == nil {
+			break
+		}
+
+		certs = append(certs, parent)
 	}
 
-	return false
+	return certs, nil
 }
 
 // TLSListener implements a dynamic certificate loader
@@ -516,9 +889,34 @@ func (tl *TLSListener) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, e
 
 // TLSConfig instanciates a TLS configuration, always providing an up to date certificate
 func (tl *TLSListener) TLSConfig() *tls.Config {
-	return &tls.Config{
-		GetCertificate: tl.GetCertificate,
+	return NewDynamicTLSConfig(tl.GetCertificate)
+}
+
+// NewDynamicTLSConfig instanciates a TLS configuration serving the certificate returned by getCertificate
+func NewDynamicTLSConfig(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) *tls.Config {
+	cfg := &tls.Config{
+		GetCertificate: getCertificate,
+	}
+
+	if ngx_config.EnableFIPSMode {
+		restrictFIPSConfig(cfg)
 	}
+
+	return cfg
+}
+
+// NewSPIFFETLSConfig instanciates a TLS configuration using the SVID of a SPIFFE Workload API
+// source. When authorizedIDs is not empty, the clients must present an SVID with one of those IDs.
+func NewSPIFFETLSConfig(source *spiffe.Source, authorizedIDs []string) *tls.Config {
+	cfg := NewDynamicTLSConfig(source.GetCertificate)
+
+	if len(authorizedIDs) > 0 {
+		// the chain is verified against the trust bundle of the source, which is rotated
+		cfg.ClientAuth = tls.RequireAnyClientCert
+		cfg.VerifyPeerCertificate = source.VerifyPeerCertificate(authorizedIDs)
+	}
+
+	return cfg
 }
 
 func (tl *TLSListener) load() {
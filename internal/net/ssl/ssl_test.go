@@ -19,6 +19,8 @@ package ssl
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	cryptorand "crypto/rand"
 	"crypto/rsa"
@@ -32,6 +34,7 @@ import (
 	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
@@ -106,6 +109,37 @@ func TestStoreSSLCertOnDisk(t *testing.T) {
 	}
 }
 
+func TestWriteFileAtomically(t *testing.T) {
+	fs := newFS(t)
+
+	path := fmt.Sprintf("%v/test-%v.pem", file.DefaultSSLDirectory, time.Now().UnixNano())
+
+	for _, content := range []string{"first", "second"} {
+		err := writeFileAtomically(fs, path, []byte(content))
+		if err != nil {
+			t.Fatalf("unexpected error writing %v: %v", path, err)
+		}
+
+		data, err := fs.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error reading %v: %v", path, err)
+		}
+		if string(data) != content {
+			t.Errorf("expected %q but %v contains %q", content, path, string(data))
+		}
+	}
+
+	infos, err := fs.ReadDir(file.DefaultSSLDirectory)
+	if err != nil {
+		t.Fatalf("unexpected error reading %v: %v", file.DefaultSSLDirectory, err)
+	}
+	for _, info := range infos {
+		if strings.HasPrefix(info.Name(), filepath.Base(path)) && info.Name() != filepath.Base(path) {
+			t.Errorf("unexpected temporary file %v", info.Name())
+		}
+	}
+}
+
 func TestCACert(t *testing.T) {
 	fs := newFS(t)
 
@@ -142,6 +176,14 @@ func TestCACert(t *testing.T) {
 	if sslCert.CAFileName == "" {
 		t.Fatalf("expected a valid CA file name")
 	}
+
+	if len(sslCert.CACertificates) != 1 {
+		t.Fatalf("expected 1 CA certificate but got %v", len(sslCert.CACertificates))
+	}
+
+	if !sslCert.CAExpireTime.Equal(CA.Cert.NotAfter) {
+		t.Fatalf("expected CA expire time %v but got %v", CA.Cert.NotAfter, sslCert.CAExpireTime)
+	}
 }
 
 func TestGetFakeSSLCert(t *testing.T) {
@@ -200,6 +242,102 @@ func TestConfigureCACert(t *testing.T) {
 	}
 }
 
+func TestCreateCACertWithBundle(t *testing.T) {
+	root, err := newCA("root-ca")
+	if err != nil {
+		t.Fatalf("unexpected error creating CA: %v", err)
+	}
+
+	intermediate, err := newIntermediateCA("intermediate-ca", root, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error creating intermediate CA: %v", err)
+	}
+
+	bundle := append(encodeCertPEM(intermediate.Cert), encodeCertPEM(root.Cert)...)
+
+	sslCert, err := CreateCACert(bundle)
+	if err != nil {
+		t.Fatalf("unexpected error creating CA certificate: %v", err)
+	}
+	if len(sslCert.CACertificates) != 2 {
+		t.Fatalf("expected 2 CA certificates but got %v", len(sslCert.CACertificates))
+	}
+	if sslCert.Certificate != sslCert.CACertificates[0] {
+		t.Fatalf("expected Certificate to be the first certificate of the bundle")
+	}
+	if !sslCert.CAExpireTime.Equal(intermediate.Cert.NotAfter) {
+		t.Fatalf("expected CA expire time %v but got %v", intermediate.Cert.NotAfter, sslCert.CAExpireTime)
+	}
+
+	key := encodePrivateKeyPEM(root.Key)
+	_, err = CreateCACert(append(key, bundle...))
+	if err == nil {
+		t.Fatalf("expected an error creating a CA certificate starting with a private key")
+	}
+
+	sslCert, err = CreateCACert(append(bundle, key...))
+	if err != nil {
+		t.Fatalf("unexpected error creating CA certificate followed by a private key: %v", err)
+	}
+	if len(sslCert.CACertificates) != 2 {
+		t.Fatalf("expected 2 CA certificates but got %v", len(sslCert.CACertificates))
+	}
+}
+
+func TestVerifyPemCertAgainstRootCA(t *testing.T) {
+	root, err := newCA("root-ca")
+	if err != nil {
+		t.Fatalf("unexpected error creating CA: %v", err)
+	}
+
+	intermediate, err := newIntermediateCA("intermediate-ca", root, duration365d)
+	if err != nil {
+		t.Fatalf("unexpected error creating intermediate CA: %v", err)
+	}
+
+	other, err := newCA("other-ca")
+	if err != nil {
+		t.Fatalf("unexpected error creating CA: %v", err)
+	}
+
+	key, err := newPrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error creating private key: %v", err)
+	}
+
+	leaf, err := newSignedCert(certutil.Config{
+		CommonName: "client",
+		Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}, key, intermediate.Cert, intermediate.Key)
+	if err != nil {
+		t.Fatalf("unexpected error signing certificate: %v", err)
+	}
+
+	testCases := []struct {
+		name  string
+		ca    []*x509.Certificate
+		valid bool
+	}{
+		{"root and intermediate", []*x509.Certificate{root.Cert, intermediate.Cert}, true},
+		{"intermediate and root", []*x509.Certificate{intermediate.Cert, root.Cert}, true},
+		{"partial chain", []*x509.Certificate{intermediate.Cert}, true},
+		{"missing intermediate", []*x509.Certificate{root.Cert}, false},
+		{"other root", []*x509.Certificate{other.Cert, intermediate.Cert}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyPemCertAgainstRootCA(leaf, tc.ca)
+			if tc.valid && err != nil {
+				t.Errorf("unexpected error verifying certificate: %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("expected an error verifying certificate")
+			}
+		})
+	}
+}
+
 func newFS(t *testing.T) file.Filesystem {
 	fs, err := file.NewFakeFS()
 	if err != nil {
@@ -240,6 +378,96 @@ func TestCreateSSLCert(t *testing.T) {
 	}
 }
 
+func TestCreateDualSSLCert(t *testing.T) {
+	rsaKey, err := newPrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error creating key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error creating key: %v", err)
+	}
+
+	rsaCert, rsaPem := newSelfSignedCert(t, rsaKey, x509.SHA256WithRSA)
+	ecdsaCert, ecdsaPem := newSelfSignedCert(t, ecdsaKey, x509.ECDSAWithSHA256)
+
+	sslCert, err := CreateDualSSLCert(rsaCert, rsaPem, ecdsaCert, ecdsaPem)
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	if sslCert.Certificate.PublicKeyAlgorithm != x509.RSA {
+		t.Errorf("expected the RSA certificate but %v was returned", sslCert.Certificate.PublicKeyAlgorithm)
+	}
+	if sslCert.ECDSA == nil || sslCert.ECDSA.Certificate.PublicKeyAlgorithm != x509.ECDSA {
+		t.Fatalf("expected the ECDSA certificate to be returned")
+	}
+	if !strings.Contains(sslCert.ECDSA.PemCertKey, "EC PRIVATE KEY") {
+		t.Errorf("expected the ECDSA certificate and key to be concatenated")
+	}
+
+	invalid := []struct {
+		name               string
+		cert, key          []byte
+		ecdsaCert, ecdsKey []byte
+	}{
+		{"two ECDSA certificates", ecdsaCert, ecdsaPem, ecdsaCert, ecdsaPem},
+		{"two RSA certificates", rsaCert, rsaPem, rsaCert, rsaPem},
+		{"ECDSA key not matching", rsaCert, rsaPem, ecdsaCert, rsaPem},
+	}
+
+	for _, tc := range invalid {
+		if _, err := CreateDualSSLCert(tc.cert, tc.key, tc.ecdsaCert, tc.ecdsKey); err == nil {
+			t.Errorf("expected an error with %v", tc.name)
+		}
+	}
+}
+
+func TestCreateTLSSecretCert(t *testing.T) {
+	rsaKey, err := newPrivateKey()
+	if err != nil {
+		t.Fatalf("unexpected error creating key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error creating key: %v", err)
+	}
+
+	rsaCert, rsaPem := newSelfSignedCert(t, rsaKey, x509.SHA256WithRSA)
+	ecdsaCert, ecdsaPem := newSelfSignedCert(t, ecdsaKey, x509.ECDSAWithSHA256)
+
+	testCases := []struct {
+		name  string
+		data  map[string][]byte
+		ecdsa bool
+		err   bool
+	}{
+		{"RSA certificate", map[string][]byte{"tls.crt": rsaCert, "tls.key": rsaPem}, false, false},
+		{"RSA and ECDSA certificates", map[string][]byte{"tls.crt": rsaCert, "tls.key": rsaPem, ecdsaCertKey: ecdsaCert, ecdsaPrivateKeyKey: ecdsaPem}, true, false},
+		{"ECDSA key missing", map[string][]byte{"tls.crt": rsaCert, "tls.key": rsaPem, ecdsaCertKey: ecdsaCert}, false, true},
+		{"key missing", map[string][]byte{"tls.crt": rsaCert}, false, true},
+	}
+
+	for _, tc := range testCases {
+		sslCert, err := CreateTLSSecretCert(tc.data)
+		if tc.err {
+			if err == nil {
+				t.Errorf("expected an error with %v", tc.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("unexpected error with %v: %v", tc.name, err)
+			continue
+		}
+
+		if (sslCert.ECDSA != nil) != tc.ecdsa {
+			t.Errorf("expected the ECDSA certificate to be returned with %v: %v", tc.name, tc.ecdsa)
+		}
+	}
+}
+
 type keyPair struct {
 	Key  *rsa.PrivateKey
 	Cert *x509.Certificate
@@ -263,6 +491,45 @@ func newCA(name string) (*keyPair, error) {
 	}, nil
 }
 
+// newIntermediateCA creates a CA certificate signed by the given parent CA
+func newIntermediateCA(name string, parent *keyPair, validity time.Duration) (*keyPair, error) {
+	key, err := newPrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a private key for a new CA: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).SetInt64(math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+
+	certTmpl := x509.Certificate{
+		Subject: pkix.Name{
+			CommonName: name,
+		},
+		SerialNumber:          serial,
+		NotBefore:             parent.Cert.NotBefore,
+		NotAfter:              time.Now().Add(validity).UTC(),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDERBytes, err := x509.CreateCertificate(cryptorand.Reader, &certTmpl, parent.Cert, key.Public(), parent.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(certDERBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyPair{
+		Key:  key,
+		Cert: cert,
+	}, nil
+}
+
 func TestIsValidHostname(t *testing.T) {
 	cases := map[string]struct {
 		Hostname string
@@ -456,3 +723,176 @@ func TestTLSKeyReloader(t *testing.T) {
 	})
 
 }
+
+func TestStorePemCertKeyOnDisk(t *testing.T) {
+	cert, _, err := generateRSACerts("echoheaders")
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	sslCert, err := CreateSSLCert(encodeCertPEM(cert.Cert), encodePrivateKeyPEM(cert.Key))
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	pemCertKey := sslCert.PemCertKey
+	if sslCert.PemCertKeySHA == "" {
+		t.Fatalf("expected a checksum of the certificate and key")
+	}
+
+	fs := newFS(t)
+	err = StorePemCertKeyOnDisk(fs, "default-echoheaders", sslCert)
+	if err != nil {
+		t.Fatalf("unexpected error storing certificate and key: %v", err)
+	}
+
+	if sslCert.PemCertKey != "" {
+		t.Errorf("expected PemCertKey to be removed from memory")
+	}
+	if sslCert.PemFileName != "" {
+		t.Errorf("expected PemFileName to be empty but returned %v", sslCert.PemFileName)
+	}
+
+	content, err := fs.ReadFile(sslCert.PemCertKeyFileName)
+	if err != nil {
+		t.Fatalf("unexpected error reading the certificate and key: %v", err)
+	}
+	if string(content) != pemCertKey {
+		t.Errorf("expected the certificate and key in %v", sslCert.PemCertKeyFileName)
+	}
+}
+
+func TestReadPemCertKey(t *testing.T) {
+	cert, _, err := generateRSACerts("echoheaders")
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	sslCert, err := CreateSSLCert(encodeCertPEM(cert.Cert), encodePrivateKeyPEM(cert.Key))
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	pemCertKey := sslCert.PemCertKey
+
+	fs := newFS(t)
+
+	content, err := ReadPemCertKey(fs, sslCert)
+	if err != nil || content != pemCertKey {
+		t.Errorf("expected the certificate and key kept in memory, error: %v", err)
+	}
+
+	// the CA appended to the file is not returned
+	ca, _, err := generateRSACerts("ca")
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	fileName := filepath.Join(file.DefaultSSLDirectory, "default-echoheaders.pem")
+	err = writeFileAtomically(fs, fileName, []byte(pemCertKey+"\n"+string(encodeCertPEM(ca.Cert))))
+	if err != nil {
+		t.Fatalf("unexpected error writing the certificate and key: %v", err)
+	}
+
+	sslCert.PemCertKey = ""
+	sslCert.PemCertKeyFileName = fileName
+
+	content, err = ReadPemCertKey(fs, sslCert)
+	if err != nil {
+		t.Fatalf("unexpected error reading the certificate and key: %v", err)
+	}
+	if content != pemCertKey {
+		t.Errorf("expected %v but returned %v", pemCertKey, content)
+	}
+
+	err = writeFileAtomically(fs, fileName, encodeCertPEM(ca.Cert))
+	if err != nil {
+		t.Fatalf("unexpected error writing the certificate: %v", err)
+	}
+
+	_, err = ReadPemCertKey(fs, sslCert)
+	if err == nil {
+		t.Errorf("expected an error reading a file without a private key")
+	}
+}
+
+func TestVerifyPemFiles(t *testing.T) {
+	cert, _, err := generateRSACerts("echoheaders")
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	sslCert, err := CreateSSLCert(encodeCertPEM(cert.Cert), encodePrivateKeyPEM(cert.Key))
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	fs := newFS(t)
+
+	fileName := filepath.Join(file.DefaultSSLDirectory, "default-echoheaders.pem")
+	err = writeFileAtomically(fs, fileName, []byte(sslCert.PemCertKey))
+	if err != nil {
+		t.Fatalf("unexpected error writing the certificate and key: %v", err)
+	}
+
+	sslCert.PemCertKey = ""
+	sslCert.PemCertKeyFileName = fileName
+
+	if err := VerifyPemFiles(fs, sslCert); err != nil {
+		t.Errorf("unexpected error verifying the files: %v", err)
+	}
+
+	// a certificate replaced on disk
+	other, _, err := generateRSACerts("other")
+	if err != nil {
+		t.Fatalf("unexpected error creating SSL certificate: %v", err)
+	}
+
+	err = writeFileAtomically(fs, fileName, append(encodeCertPEM(other.Cert), encodePrivateKeyPEM(other.Key)...))
+	if err != nil {
+		t.Fatalf("unexpected error writing the certificate and key: %v", err)
+	}
+
+	if err := VerifyPemFiles(fs, sslCert); err == nil {
+		t.Errorf("expected an error verifying a modified file")
+	}
+
+	// a file read by NGINX
+	sslCert.PemCertKeyFileName = ""
+	sslCert.PemFileName = fileName
+	sslCert.PemSHA = fileSHA1(fs, fileName)
+
+	if err := VerifyPemFiles(fs, sslCert); err != nil {
+		t.Errorf("unexpected error verifying the files: %v", err)
+	}
+
+	fs.Remove(fileName)
+
+	if err := VerifyPemFiles(fs, sslCert); err == nil {
+		t.Errorf("expected an error verifying a removed file")
+	}
+
+	// a CA file written without the certificate and key
+	caFileName := filepath.Join(file.DefaultSSLDirectory, "ca-default-echoheaders.pem")
+	err = writeFileAtomically(fs, caFileName, encodeCertPEM(cert.Cert))
+	if err != nil {
+		t.Fatalf("unexpected error writing the CA: %v", err)
+	}
+
+	sslCert.PemFileName = ""
+	sslCert.CAFileName = caFileName
+	sslCert.CASHA = fileSHA1(fs, caFileName)
+
+	if err := VerifyPemFiles(fs, sslCert); err != nil {
+		t.Errorf("unexpected error verifying the files: %v", err)
+	}
+
+	err = writeFileAtomically(fs, caFileName, encodeCertPEM(other.Cert))
+	if err != nil {
+		t.Fatalf("unexpected error writing the CA: %v", err)
+	}
+
+	if err := VerifyPemFiles(fs, sslCert); err == nil {
+		t.Errorf("expected an error verifying a modified CA file")
+	}
+}
@@ -0,0 +1,429 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tlsfingerprint computes the JA3 and JA4 fingerprints of the TLS
+// Client Hello messages, identifying the TLS libraries of the clients.
+package tlsfingerprint
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	recordTypeHandshake      = 0x16
+	handshakeTypeClientHello = 0x01
+
+	extensionServerName          = 0x0000
+	extensionSupportedGroups     = 0x000a
+	extensionECPointFormats      = 0x000b
+	extensionSignatureAlgorithms = 0x000d
+	extensionALPN                = 0x0010
+	extensionSupportedVersions   = 0x002b
+)
+
+// errTruncated is returned when the Client Hello is incomplete
+var errTruncated = errors.New("truncated TLS Client Hello")
+
+// ClientHello contains the fields of a TLS Client Hello used by the
+// fingerprints. The GREASE values sent by some clients are ignored.
+type ClientHello struct {
+	Version             uint16
+	CipherSuites        []uint16
+	Extensions          []uint16
+	SupportedGroups     []uint16
+	PointFormats        []uint8
+	SignatureAlgorithms []uint16
+	SupportedVersions   []uint16
+	ALPN                []string
+	ServerName          string
+}
+
+// Parse parses the TLS record containing a Client Hello, as read from the
+// beginning of a connection
+func Parse(data []byte) (*ClientHello, error) {
+	r := reader(data)
+
+	recordType, ok := r.uint8()
+	if !ok {
+		return nil, errTruncated
+	}
+	if recordType != recordTypeHandshake {
+		return nil, fmt.Errorf("unexpected TLS record type %d", recordType)
+	}
+
+	// the version of the record is not the version of the client
+	record, ok := r.skip(2).vector16()
+	if !ok {
+		return nil, errTruncated
+	}
+
+	handshakeType, ok := record.uint8()
+	if !ok {
+		return nil, errTruncated
+	}
+	if handshakeType != handshakeTypeClientHello {
+		return nil, fmt.Errorf("unexpected TLS handshake type %d", handshakeType)
+	}
+
+	body, ok := record.vector24()
+	if !ok {
+		return nil, errTruncated
+	}
+
+	hello := &ClientHello{}
+	hello.Version, ok = body.uint16()
+	if !ok {
+		return nil, errTruncated
+	}
+
+	// random and session ID
+	if _, ok = body.skip(32).vector8(); !ok {
+		return nil, errTruncated
+	}
+
+	ciphers, ok := body.vector16()
+	if !ok {
+		return nil, errTruncated
+	}
+	if hello.CipherSuites, ok = ciphers.uint16s(); !ok {
+		return nil, errTruncated
+	}
+
+	// compression methods
+	if _, ok = body.vector8(); !ok {
+		return nil, errTruncated
+	}
+
+	// the extensions are optional
+	if len(body) == 0 {
+		return hello, nil
+	}
+
+	extensions, ok := body.vector16()
+	if !ok {
+		return nil, errTruncated
+	}
+
+	for len(extensions) > 0 {
+		extension, ok := extensions.uint16()
+		if !ok {
+			return nil, errTruncated
+		}
+
+		data, ok := extensions.vector16()
+		if !ok {
+			return nil, errTruncated
+		}
+
+		if isGREASE(extension) {
+			continue
+		}
+
+		hello.Extensions = append(hello.Extensions, extension)
+
+		if err := hello.parseExtension(extension, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return hello, nil
+}
+
+func (h *ClientHello) parseExtension(extension uint16, data reader) error {
+	var ok bool
+
+	switch extension {
+	case extensionServerName:
+		var list reader
+		list, ok = data.vector16()
+		for ok && len(list) > 0 {
+			var nameType uint8
+			var name reader
+			nameType, _ = list.uint8()
+			name, ok = list.vector16()
+			if ok && nameType == 0 {
+				h.ServerName = string(name)
+				return nil
+			}
+		}
+	case extensionSupportedGroups:
+		var groups reader
+		if groups, ok = data.vector16(); ok {
+			h.SupportedGroups, ok = groups.uint16s()
+		}
+	case extensionECPointFormats:
+		var formats reader
+		if formats, ok = data.vector8(); ok {
+			h.PointFormats = []uint8(formats)
+		}
+	case extensionSignatureAlgorithms:
+		var algorithms reader
+		if algorithms, ok = data.vector16(); ok {
+			h.SignatureAlgorithms, ok = algorithms.uint16s()
+		}
+	case extensionALPN:
+		var protocols reader
+		protocols, ok = data.vector16()
+		for ok && len(protocols) > 0 {
+			var protocol reader
+			if protocol, ok = protocols.vector8(); ok {
+				h.ALPN = append(h.ALPN, string(protocol))
+			}
+		}
+	case extensionSupportedVersions:
+		var versions reader
+		if versions, ok = data.vector8(); ok {
+			h.SupportedVersions, ok = versions.uint16s()
+		}
+	default:
+		return nil
+	}
+
+	if !ok {
+		return fmt.Errorf("invalid TLS extension %d", extension)
+	}
+
+	return nil
+}
+
+// JA3 returns the JA3 string of the Client Hello:
+// version,ciphers,extensions,groups,point formats with the values of the
+// lists separated by dashes
+func (h *ClientHello) JA3() string {
+	formats := make([]uint16, len(h.PointFormats))
+	for i, format := range h.PointFormats {
+		formats[i] = uint16(format)
+	}
+
+	return strings.Join([]string{
+		strconv.Itoa(int(h.Version)),
+		joinDecimal(h.CipherSuites),
+		joinDecimal(h.Extensions),
+		joinDecimal(h.SupportedGroups),
+		joinDecimal(formats),
+	}, ",")
+}
+
+// JA3Hash returns the MD5 hash of the JA3 string, the usual form of the
+// JA3 fingerprint
+func (h *ClientHello) JA3Hash() string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(h.JA3())))
+}
+
+// JA4 returns the JA4 fingerprint of the Client Hello received over TCP.
+// Unlike JA3 it does not depend on the order of the ciphers and extensions,
+// randomized by some clients.
+func (h *ClientHello) JA4() string {
+	sni := "i"
+	if h.ServerName != "" {
+		sni = "d"
+	}
+
+	a := fmt.Sprintf("t%v%v%02d%02d%v", h.ja4Version(), sni,
+		minInt(len(h.CipherSuites), 99), minInt(len(h.Extensions), 99), h.ja4ALPN())
+
+	ciphers := sortedHex(h.CipherSuites)
+
+	var extensions []uint16
+	for _, extension := range h.Extensions {
+		if extension != extensionServerName && extension != extensionALPN {
+			extensions = append(extensions, extension)
+		}
+	}
+
+	c := sortedHex(extensions)
+	if len(h.SignatureAlgorithms) > 0 {
+		algorithms := make([]string, len(h.SignatureAlgorithms))
+		for i, algorithm := range h.SignatureAlgorithms {
+			algorithms[i] = fmt.Sprintf("%04x", algorithm)
+		}
+		c += "_" + strings.Join(algorithms, ",")
+	}
+
+	return fmt.Sprintf("%v_%v_%v", a, truncatedHash(ciphers, len(h.CipherSuites)), truncatedHash(c, len(extensions)))
+}
+
+// ja4Version returns the highest supported TLS version
+func (h *ClientHello) ja4Version() string {
+	version := h.Version
+	for _, v := range h.SupportedVersions {
+		if v > version {
+			version = v
+		}
+	}
+
+	switch version {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	default:
+		return "00"
+	}
+}
+
+// ja4ALPN returns the first and last characters of the first ALPN
+// protocol, or of its hexadecimal representation when they are not
+// alphanumeric
+func (h *ClientHello) ja4ALPN() string {
+	if len(h.ALPN) == 0 || h.ALPN[0] == "" {
+		return "00"
+	}
+
+	protocol := h.ALPN[0]
+	first, last := protocol[0], protocol[len(protocol)-1]
+	if !isAlphanumeric(first) || !isAlphanumeric(last) {
+		encoded := fmt.Sprintf("%x", protocol)
+		return encoded[:1] + encoded[len(encoded)-1:]
+	}
+
+	return string([]byte{first, last})
+}
+
+// isGREASE returns true for the values reserved by RFC 8701
+func isGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+func isAlphanumeric(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func joinDecimal(values []uint16) string {
+	s := make([]string, len(values))
+	for i, value := range values {
+		s[i] = strconv.Itoa(int(value))
+	}
+	return strings.Join(s, "-")
+}
+
+func sortedHex(values []uint16) string {
+	s := make([]string, len(values))
+	for i, value := range values {
+		s[i] = fmt.Sprintf("%04x", value)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+// truncatedHash returns the first 12 characters of the SHA-256 hash of s,
+// or zeros when the list is empty
+func truncatedHash(s string, count int) string {
+	if count == 0 {
+		return "000000000000"
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))[:12]
+}
+
+// reader reads the fields of a TLS message, advancing the slice
+type reader []byte
+
+func (r *reader) skip(n int) *reader {
+	if len(*r) < n {
+		*r = nil
+		return r
+	}
+	*r = (*r)[n:]
+	return r
+}
+
+func (r *reader) uint8() (uint8, bool) {
+	if len(*r) < 1 {
+		return 0, false
+	}
+	v := (*r)[0]
+	*r = (*r)[1:]
+	return v, true
+}
+
+func (r *reader) uint16() (uint16, bool) {
+	if len(*r) < 2 {
+		return 0, false
+	}
+	v := binary.BigEndian.Uint16(*r)
+	*r = (*r)[2:]
+	return v, true
+}
+
+func (r *reader) vector(length int) (reader, bool) {
+	if len(*r) < length {
+		return nil, false
+	}
+	v := (*r)[:length]
+	*r = (*r)[length:]
+	return v, true
+}
+
+func (r *reader) vector8() (reader, bool) {
+	length, ok := r.uint8()
+	if !ok {
+		return nil, false
+	}
+	return r.vector(int(length))
+}
+
+func (r *reader) vector16() (reader, bool) {
+	length, ok := r.uint16()
+	if !ok {
+		return nil, false
+	}
+	return r.vector(int(length))
+}
+
+func (r *reader) vector24() (reader, bool) {
+	if len(*r) < 3 {
+		return nil, false
+	}
+	length := int((*r)[0])<<16 | int((*r)[1])<<8 | int((*r)[2])
+	*r = (*r)[3:]
+	return r.vector(length)
+}
+
+// uint16s reads a list of values, ignoring the GREASE values
+func (r *reader) uint16s() ([]uint16, bool) {
+	if len(*r)%2 != 0 {
+		return nil, false
+	}
+
+	var values []uint16
+	for len(*r) > 0 {
+		v, _ := r.uint16()
+		if !isGREASE(v) {
+			values = append(values, v)
+		}
+	}
+	return values, true
+}
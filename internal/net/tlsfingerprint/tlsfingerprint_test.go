@@ -0,0 +1,212 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tlsfingerprint
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+type extension struct {
+	kind uint16
+	data []byte
+}
+
+func u16(values ...uint16) []byte {
+	b := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint16(b[2*i:], v)
+	}
+	return b
+}
+
+func vector8(b []byte) []byte {
+	return append([]byte{byte(len(b))}, b...)
+}
+
+func vector16(b []byte) []byte {
+	return append(u16(uint16(len(b))), b...)
+}
+
+func serverName(name string) extension {
+	entry := append([]byte{0}, vector16([]byte(name))...)
+	return extension{extensionServerName, vector16(entry)}
+}
+
+func alpn(protocols ...string) extension {
+	var list []byte
+	for _, p := range protocols {
+		list = append(list, vector8([]byte(p))...)
+	}
+	return extension{extensionALPN, vector16(list)}
+}
+
+func clientHello(version uint16, ciphers []uint16, extensions ...extension) []byte {
+	body := u16(version)
+	body = append(body, make([]byte, 32)...)
+	body = append(body, vector8(nil)...)
+	body = append(body, vector16(u16(ciphers...))...)
+	body = append(body, vector8([]byte{0})...)
+
+	var exts []byte
+	for _, e := range extensions {
+		exts = append(exts, u16(e.kind)...)
+		exts = append(exts, vector16(e.data)...)
+	}
+	body = append(body, vector16(exts)...)
+
+	handshake := []byte{handshakeTypeClientHello, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	handshake = append(handshake, body...)
+
+	record := []byte{recordTypeHandshake, 0x03, 0x01}
+	return append(record, vector16(handshake)...)
+}
+
+func TestJA3(t *testing.T) {
+	data := clientHello(0x0301,
+		[]uint16{47, 53, 5, 10, 49161, 49162, 49171, 49172, 50, 56, 19, 4},
+		serverName("example.com"),
+		extension{extensionSupportedGroups, vector16(u16(23, 24, 25))},
+		extension{extensionECPointFormats, vector8([]byte{0})},
+	)
+
+	hello, err := Parse(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "769,47-53-5-10-49161-49162-49171-49172-50-56-19-4,0-10-11,23-24-25,0"
+	if hello.JA3() != expected {
+		t.Errorf("expected %v but returned %v", expected, hello.JA3())
+	}
+
+	if hello.JA3Hash() != "ada70206e40642a3e4461f35503241d5" {
+		t.Errorf("unexpected JA3 hash %v", hello.JA3Hash())
+	}
+
+	if hello.ServerName != "example.com" {
+		t.Errorf("expected example.com as server name but returned %v", hello.ServerName)
+	}
+}
+
+func TestJA4(t *testing.T) {
+	grease := uint16(0x1a1a)
+	empty := func(kind uint16) extension { return extension{kind, nil} }
+
+	data := clientHello(0x0303,
+		[]uint16{grease, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035},
+		empty(grease),
+		serverName("example.com"),
+		empty(0x0017),
+		empty(0xff01),
+		extension{extensionSupportedGroups, vector16(u16(grease, 0x001d, 0x0017, 0x0018))},
+		extension{extensionECPointFormats, vector8([]byte{0})},
+		empty(0x0023),
+		alpn("h2", "http/1.1"),
+		empty(0x0005),
+		extension{extensionSignatureAlgorithms, vector16(u16(0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601))},
+		empty(0x0012),
+		empty(0x0033),
+		empty(0x002d),
+		extension{extensionSupportedVersions, vector8(u16(grease, 0x0304, 0x0303))},
+		empty(0x001b),
+		empty(0x4469),
+		empty(0x0015),
+	)
+
+	hello, err := Parse(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "t13d1516h2_8daaf6152771_e5627efa2ab1"
+	if hello.JA4() != expected {
+		t.Errorf("expected %v but returned %v", expected, hello.JA4())
+	}
+
+	if len(hello.CipherSuites) != 15 || len(hello.SupportedGroups) != 3 {
+		t.Errorf("expected the GREASE values to be ignored: %v %v", hello.CipherSuites, hello.SupportedGroups)
+	}
+}
+
+func TestJA4WithoutExtensions(t *testing.T) {
+	data := clientHello(0x0301, []uint16{0x002f})
+
+	hello, err := Parse(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "t10i010000_" + truncatedHash("002f", 1) + "_000000000000"
+	if hello.JA4() != expected {
+		t.Errorf("expected %v but returned %v", expected, hello.JA4())
+	}
+}
+
+func TestParseGoClientHello(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	go func() {
+		conn := tls.Client(client, &tls.Config{ServerName: "example.com", NextProtos: []string{"h2"}})
+		conn.Handshake()
+		conn.Close()
+	}()
+
+	data := make([]byte, 4096)
+	length, err := server.Read(data)
+	if err != nil {
+		t.Fatalf("unexpected error reading the client hello: %v", err)
+	}
+
+	hello, err := Parse(data[:length])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if hello.ServerName != "example.com" {
+		t.Errorf("expected example.com as server name but returned %v", hello.ServerName)
+	}
+
+	if len(hello.ALPN) != 1 || hello.ALPN[0] != "h2" {
+		t.Errorf("expected h2 as ALPN but returned %v", hello.ALPN)
+	}
+
+	if ja4 := hello.JA4(); ja4[:4] != "t13d" || ja4[8:10] != "h2" {
+		t.Errorf("unexpected JA4 %v", ja4)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	data := clientHello(0x0303, []uint16{0x1301}, serverName("example.com"))
+
+	invalid := map[string][]byte{
+		"empty":      {},
+		"truncated":  data[:len(data)-4],
+		"not TLS":    []byte("GET / HTTP/1.1\r\n\r\n"),
+		"not hello":  {recordTypeHandshake, 0x03, 0x01, 0x00, 0x04, 0x02, 0x00, 0x00, 0x00},
+		"bad length": append(data[:5:5], 0x01, 0xff, 0xff, 0xff),
+	}
+
+	for name, d := range invalid {
+		if _, err := Parse(d); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
@@ -45,6 +45,12 @@ var HealthCheckTimeout = 10 * time.Second
 // http://nginx.org/en/docs/http/ngx_http_stub_status_module.html
 var StatusPath = "/nginx_status"
 
+// AccessEventsStatusPath defines the path used to expose the counters of the access events
+var AccessEventsStatusPath = "/access_events"
+
+// UsageStatusPath defines the path used to expose the usage of each Ingress
+var UsageStatusPath = "/usage"
+
 // StreamSocket defines the location of the unix socket used by NGINX for the NGINX stream configuration socket
 var StreamSocket = "/tmp/ingress-stream.sock"
 
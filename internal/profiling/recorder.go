@@ -0,0 +1,241 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiling
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/logging"
+)
+
+const (
+	// CPU is the kind of the CPU profiles
+	CPU = "cpu"
+	// Heap is the kind of the heap profiles. They are the heap profiles of
+	// runtime/pprof: go tool pprof shows the memory in use (inuse_space) by
+	// default, the alloc_space sample is cumulative since the start
+	Heap = "heap"
+
+	// the window of the reloads compared with the threshold
+	reloadWindow = time.Minute
+	// the minimum time between two captures of the same kind, the
+	// profiles are only useful if they don't slow down the controller
+	captureInterval = 5 * time.Minute
+)
+
+var (
+	// cpuProfileDuration is the duration of the CPU profiles
+	cpuProfileDuration = 10 * time.Second
+	// heapCheckInterval is the interval between two checks of the heap
+	heapCheckInterval = 30 * time.Second
+)
+
+// Config defines when the profiles are captured
+type Config struct {
+	// Size is the number of profiles kept, the oldest ones are dropped
+	Size int
+	// ReloadThreshold is the number of reloads of NGINX in a minute
+	// triggering the capture of a CPU profile
+	ReloadThreshold int
+	// HeapThreshold is the growth of the heap, in bytes, since the last
+	// heap profile triggering the capture of a new one
+	HeapThreshold uint64
+}
+
+// Profile describes a captured profile
+type Profile struct {
+	ID     int       `json:"id"`
+	Kind   string    `json:"kind"`
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+	Size   int       `json:"size"`
+
+	data []byte
+}
+
+// Recorder captures profiles of the controller when the reloads or the heap
+// spike and keeps the last ones in memory
+type Recorder struct {
+	cfg Config
+
+	lock     sync.Mutex
+	profiles []Profile
+	nextID   int
+	reloads  []time.Time
+	captured map[string]time.Time
+	// the heap allocated at the last heap profile
+	heapBase uint64
+}
+
+// NewRecorder creates a new profile recorder
+func NewRecorder(cfg Config) *Recorder {
+	return &Recorder{
+		cfg:      cfg,
+		nextID:   1,
+		captured: map[string]time.Time{},
+	}
+}
+
+// Run checks the heap periodically until the channel is closed
+func (r *Recorder) Run(stopCh <-chan struct{}) {
+	r.lock.Lock()
+	r.heapBase = heapAlloc()
+	r.lock.Unlock()
+
+	wait.Until(func() {
+		r.checkHeap(heapAlloc(), time.Now())
+	}, heapCheckInterval, stopCh)
+}
+
+// Reloaded records a reload of NGINX and captures a CPU profile when the
+// reloads in the last minute reach the threshold
+func (r *Recorder) Reloaded(now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	reloads := r.reloads[:0]
+	for _, t := range r.reloads {
+		if now.Sub(t) < reloadWindow {
+			reloads = append(reloads, t)
+		}
+	}
+	r.reloads = append(reloads, now)
+
+	if len(r.reloads) < r.cfg.ReloadThreshold || !r.canCapture(CPU, now) {
+		return
+	}
+
+	reason := fmt.Sprintf("%v reloads in the last %v", len(r.reloads), reloadWindow)
+	go r.captureCPU(reason, now)
+}
+
+func (r *Recorder) checkHeap(alloc uint64, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// the heap shrank, the growth is measured from the lower value
+	if alloc < r.heapBase {
+		r.heapBase = alloc
+		return
+	}
+
+	growth := alloc - r.heapBase
+	if growth < r.cfg.HeapThreshold || !r.canCapture(Heap, now) {
+		return
+	}
+
+	// the memory in use of a heap profile is the one of the last garbage
+	// collection, without it the profile misses the latest growth
+	runtime.GC()
+
+	var buf bytes.Buffer
+	err := pprof.Lookup("heap").WriteTo(&buf, 0)
+	if err != nil {
+		klog.Errorf("Unexpected error capturing a heap profile: %v", err)
+		return
+	}
+
+	r.heapBase = alloc
+	r.add(Heap, fmt.Sprintf("heap grew by %v MB", growth/(1024*1024)), now, buf.Bytes())
+}
+
+func (r *Recorder) captureCPU(reason string, now time.Time) {
+	var buf bytes.Buffer
+	err := pprof.StartCPUProfile(&buf)
+	if err != nil {
+		// another CPU profile is running, i.e. requested on /debug/pprof
+		logging.V(2).Infof("Skipping the capture of a CPU profile: %v", err)
+		return
+	}
+
+	time.Sleep(cpuProfileDuration)
+	pprof.StopCPUProfile()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.add(CPU, reason, now, buf.Bytes())
+}
+
+// canCapture reports whether a profile of the kind can be captured and
+// records the capture. It must be called with the lock held.
+func (r *Recorder) canCapture(kind string, now time.Time) bool {
+	if last, ok := r.captured[kind]; ok && now.Sub(last) < captureInterval {
+		return false
+	}
+
+	r.captured[kind] = now
+	return true
+}
+
+// add keeps a profile, dropping the oldest one when the recorder is full.
+// It must be called with the lock held.
+func (r *Recorder) add(kind, reason string, now time.Time, data []byte) {
+	r.profiles = append(r.profiles, Profile{
+		ID:     r.nextID,
+		Kind:   kind,
+		Reason: reason,
+		Time:   now,
+		Size:   len(data),
+		data:   data,
+	})
+	r.nextID++
+
+	if len(r.profiles) > r.cfg.Size {
+		r.profiles = r.profiles[len(r.profiles)-r.cfg.Size:]
+	}
+
+	klog.Infof("Captured a %v profile (%v)", kind, reason)
+}
+
+// List returns the profiles kept by the recorder, the oldest first
+func (r *Recorder) List() []Profile {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	profiles := make([]Profile, len(r.profiles))
+	copy(profiles, r.profiles)
+	return profiles
+}
+
+// Get returns the content of a profile, in the format of pprof
+func (r *Recorder) Get(id int) ([]byte, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, p := range r.profiles {
+		if p.ID == id {
+			return p.data, true
+		}
+	}
+
+	return nil, false
+}
+
+func heapAlloc() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
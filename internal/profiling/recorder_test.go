@@ -0,0 +1,125 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package profiling
+
+import (
+	"testing"
+	"time"
+)
+
+func waitForProfiles(t *testing.T, r *Recorder, count int) []Profile {
+	for i := 0; i < 100; i++ {
+		if profiles := r.List(); len(profiles) >= count {
+			return profiles
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("expected %v profiles but returned %v", count, len(r.List()))
+	return nil
+}
+
+func TestReloaded(t *testing.T) {
+	cpuProfileDuration = 10 * time.Millisecond
+
+	r := NewRecorder(Config{Size: 5, ReloadThreshold: 3, HeapThreshold: 1024})
+
+	now := time.Now()
+	// the reloads older than a minute are not counted
+	r.Reloaded(now.Add(-2 * time.Minute))
+	r.Reloaded(now.Add(-time.Second))
+	r.Reloaded(now)
+	if len(r.List()) != 0 {
+		t.Fatalf("expected no profile below the threshold")
+	}
+
+	r.Reloaded(now.Add(time.Second))
+	profiles := waitForProfiles(t, r, 1)
+	if profiles[0].Kind != CPU || profiles[0].ID != 1 || profiles[0].Size == 0 {
+		t.Errorf("unexpected profile %v of kind %v and size %v", profiles[0].ID, profiles[0].Kind, profiles[0].Size)
+	}
+	if profiles[0].Reason != "3 reloads in the last 1m0s" {
+		t.Errorf("unexpected reason %q", profiles[0].Reason)
+	}
+
+	data, ok := r.Get(1)
+	if !ok || len(data) != profiles[0].Size {
+		t.Errorf("expected the content of the profile 1")
+	}
+	if _, ok := r.Get(2); ok {
+		t.Errorf("unexpected profile 2")
+	}
+
+	// a single capture during the capture interval
+	r.Reloaded(now.Add(2 * time.Second))
+	time.Sleep(50 * time.Millisecond)
+	if len(r.List()) != 1 {
+		t.Errorf("expected a single profile during the capture interval but returned %v", len(r.List()))
+	}
+}
+
+func TestCheckHeap(t *testing.T) {
+	r := NewRecorder(Config{Size: 2, ReloadThreshold: 10, HeapThreshold: 1024 * 1024})
+	r.heapBase = 10 * 1024 * 1024
+
+	now := time.Now()
+	r.checkHeap(10*1024*1024+1024, now)
+	if len(r.List()) != 0 {
+		t.Fatalf("expected no profile below the threshold")
+	}
+
+	r.checkHeap(12*1024*1024, now)
+	profiles := r.List()
+	if len(profiles) != 1 || profiles[0].Kind != Heap || profiles[0].Reason != "heap grew by 2 MB" {
+		t.Fatalf("unexpected profiles %v", ids(profiles))
+	}
+
+	// the growth is measured from the last profile
+	r.checkHeap(12*1024*1024+1024, now.Add(captureInterval))
+	if len(r.List()) != 1 {
+		t.Errorf("expected no new profile below the threshold")
+	}
+
+	// the heap shrank, the growth is measured from the lower value
+	r.checkHeap(4*1024*1024, now.Add(captureInterval))
+	r.checkHeap(6*1024*1024, now.Add(captureInterval))
+	profiles = r.List()
+	if len(profiles) != 2 || profiles[1].ID != 2 || profiles[1].Reason != "heap grew by 2 MB" {
+		t.Fatalf("unexpected profiles %v", ids(profiles))
+	}
+
+	// a single capture during the capture interval
+	r.checkHeap(20*1024*1024, now.Add(captureInterval+time.Minute))
+	if len(r.List()) != 2 {
+		t.Errorf("expected no new profile during the capture interval")
+	}
+
+	// only the last profiles are kept
+	r.checkHeap(20*1024*1024, now.Add(2*captureInterval))
+	profiles = r.List()
+	if len(profiles) != 2 || profiles[0].ID != 2 || profiles[1].ID != 3 {
+		t.Errorf("unexpected profiles %v", ids(profiles))
+	}
+}
+
+func ids(profiles []Profile) []int {
+	result := []int{}
+	for _, p := range profiles {
+		result = append(result, p.ID)
+	}
+	return result
+}
@@ -32,7 +32,13 @@ import (
 // as formula
 //  https://www.kernel.org/doc/Documentation/scheduler/sched-bwc.txt
 func NumCPU() int {
-	cpus := runtime.NumCPU()
+	return int(math.Ceil(CPULimit()))
+}
+
+// CPULimit returns the number of CPUs the current process can use, which is
+// a fraction when the CPU cgroups limits are configured (cfs_quota_us / cfs_period_us)
+func CPULimit() float64 {
+	cpus := float64(runtime.NumCPU())
 
 	cgroupPath, err := libcontainercgroups.FindCgroupMountpoint("cpu")
 	if err != nil {
@@ -46,7 +52,7 @@ func NumCPU() int {
 		return cpus
 	}
 
-	return int(math.Ceil(float64(cpuQuota) / float64(cpuPeriod)))
+	return float64(cpuQuota) / float64(cpuPeriod)
 }
 
 func readCgroupFileToInt64(cgroupPath, cgroupFile string) int64 {
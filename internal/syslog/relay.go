@@ -0,0 +1,229 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package syslog relays the logs NGINX sends to a local socket to syslog
+// servers reached with TCP or TLS, which NGINX does not support.
+package syslog
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"k8s.io/ingress-nginx/internal/logging"
+)
+
+const (
+	// TCP sends the logs using a plain TCP connection
+	TCP = "tcp"
+	// TLS sends the logs using a TLS connection (RFC 5425)
+	TLS = "tls"
+
+	dialTimeout = 5 * time.Second
+
+	// maxMessageSize is the maximum size of a syslog message sent by NGINX
+	maxMessageSize = 64 * 1024
+)
+
+// socketDirectory contains the sockets NGINX sends the logs to
+var socketDirectory = os.TempDir()
+
+// Remote defines a syslog server
+type Remote struct {
+	// Protocol is tcp or tls
+	Protocol string
+	// Address of the syslog server in the format host:port
+	Address string
+}
+
+// Socket returns the path of the unix socket NGINX must send the logs to
+func (r Remote) Socket() string {
+	sum := sha1.Sum([]byte(r.Protocol + "://" + r.Address))
+	return filepath.Join(socketDirectory, fmt.Sprintf("syslog-%x.sock", sum[:6]))
+}
+
+func (r Remote) String() string {
+	return fmt.Sprintf("%v://%v", r.Protocol, r.Address)
+}
+
+// Relay receives the syslog messages sent by NGINX to a unix datagram
+// socket and forwards them to a remote syslog server
+type Relay struct {
+	remote   Remote
+	listener *net.UnixConn
+
+	dial func() (net.Conn, error)
+	conn net.Conn
+
+	// failing avoids logging every failed attempt to reach the server
+	failing bool
+}
+
+// NewRelay creates the socket of the relay to the remote server
+func NewRelay(remote Remote) (*Relay, error) {
+	if remote.Protocol != TCP && remote.Protocol != TLS {
+		return nil, fmt.Errorf("unsupported syslog protocol %v", remote.Protocol)
+	}
+
+	host, _, err := net.SplitHostPort(remote.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog server address %v: %v", remote.Address, err)
+	}
+
+	path := remote.Socket()
+	// remove the socket left by a previous process
+	os.Remove(path)
+
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Relay{
+		remote:   remote,
+		listener: listener,
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	r.dial = func() (net.Conn, error) {
+		if remote.Protocol == TLS {
+			return tls.DialWithDialer(dialer, "tcp", remote.Address, &tls.Config{ServerName: host})
+		}
+
+		return dialer.Dial("tcp", remote.Address)
+	}
+
+	return r, nil
+}
+
+// Run forwards the messages until the relay is closed
+func (r *Relay) Run() {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, err := r.listener.Read(buf)
+		if err != nil {
+			if r.conn != nil {
+				r.conn.Close()
+			}
+			return
+		}
+
+		r.forward(buf[:n])
+	}
+}
+
+// forward sends a message to the remote server using the octet counting
+// framing, reconnecting once if the connection was closed
+func (r *Relay) forward(msg []byte) {
+	frame := []byte(fmt.Sprintf("%d %s", len(msg), msg))
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if r.conn == nil {
+			conn, err := r.dial()
+			if err != nil {
+				if !r.failing {
+					klog.Warningf("Error connecting to syslog server %v: %v", r.remote, err)
+				}
+				r.failing = true
+				return
+			}
+
+			if r.failing {
+				klog.Infof("Connected to syslog server %v", r.remote)
+			}
+			r.failing = false
+			r.conn = conn
+		}
+
+		_, err := r.conn.Write(frame)
+		if err == nil {
+			return
+		}
+
+		logging.V(2).Infof("Error sending log to syslog server %v: %v", r.remote, err)
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+// Close stops the relay and removes its socket
+func (r *Relay) Close() error {
+	err := r.listener.Close()
+	os.Remove(r.remote.Socket())
+	return err
+}
+
+// Relays manages the relays to the syslog servers used in the configuration
+type Relays struct {
+	mu     sync.Mutex
+	relays map[Remote]*Relay
+}
+
+// NewRelays creates an empty set of relays
+func NewRelays() *Relays {
+	return &Relays{
+		relays: make(map[Remote]*Relay),
+	}
+}
+
+// Sync starts the relays to the remote servers not running yet and stops
+// the ones not present in remotes
+func (rs *Relays) Sync(remotes []Remote) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	wanted := make(map[Remote]bool)
+	for _, remote := range remotes {
+		wanted[remote] = true
+
+		if _, ok := rs.relays[remote]; ok {
+			continue
+		}
+
+		relay, err := NewRelay(remote)
+		if err != nil {
+			return err
+		}
+
+		klog.Infof("Starting relay of the logs to syslog server %v", remote)
+		rs.relays[remote] = relay
+		go relay.Run()
+	}
+
+	for remote, relay := range rs.relays {
+		if wanted[remote] {
+			continue
+		}
+
+		klog.Infof("Stopping relay of the logs to syslog server %v", remote)
+		relay.Close()
+		delete(rs.relays, remote)
+	}
+
+	return nil
+}
+
+// Close stops all the relays
+func (rs *Relays) Close() {
+	rs.Sync(nil)
+}
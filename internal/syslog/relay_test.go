@@ -0,0 +1,136 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syslog
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func setupSocketDirectory(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "syslog")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	previous := socketDirectory
+	socketDirectory = dir
+
+	return func() {
+		socketDirectory = previous
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNewRelayInvalid(t *testing.T) {
+	defer setupSocketDirectory(t)()
+
+	remotes := []Remote{
+		{Protocol: "udp", Address: "127.0.0.1:514"},
+		{Protocol: TCP, Address: "127.0.0.1"},
+	}
+
+	for _, remote := range remotes {
+		if _, err := NewRelay(remote); err == nil {
+			t.Errorf("expected an error creating a relay to %v", remote)
+		}
+	}
+}
+
+func TestRelay(t *testing.T) {
+	defer setupSocketDirectory(t)()
+
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer server.Close()
+
+	remote := Remote{Protocol: TCP, Address: server.Addr().String()}
+
+	relay, err := NewRelay(remote)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer relay.Close()
+	go relay.Run()
+
+	client, err := net.Dial("unixgram", remote.Socket())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	_, err = client.Write([]byte("<190>nginx: GET /"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	conn, err := server.Accept()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('/')
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "17 <190>nginx: GET /"
+	if line != expected {
+		t.Errorf("expected %q but returned %q", expected, line)
+	}
+}
+
+func TestRelaysSync(t *testing.T) {
+	defer setupSocketDirectory(t)()
+
+	first := Remote{Protocol: TCP, Address: "127.0.0.1:601"}
+	second := Remote{Protocol: TLS, Address: "logs.example.com:6514"}
+
+	relays := NewRelays()
+	defer relays.Close()
+
+	err := relays.Sync([]Remote{first, second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, remote := range []Remote{first, second} {
+		if _, err := os.Stat(remote.Socket()); err != nil {
+			t.Errorf("expected the socket of %v to exist: %v", remote, err)
+		}
+	}
+
+	err = relays.Sync([]Remote{second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := os.Stat(first.Socket()); !os.IsNotExist(err) {
+		t.Errorf("expected the socket of %v to be removed", first)
+	}
+	if _, err := os.Stat(second.Socket()); err != nil {
+		t.Errorf("expected the socket of %v to exist: %v", second, err)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package task
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -26,19 +27,46 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
+
+	"k8s.io/ingress-nginx/internal/logging"
 )
 
 var (
 	keyFunc = cache.DeletionHandlingMetaNamespaceKeyFunc
 )
 
+// Priority is the priority of an element of the queue
+type Priority int
+
+const (
+	// NormalPriority is the priority of most of the elements
+	NormalPriority Priority = iota
+	// HighPriority elements are processed before the elements with a normal
+	// priority enqueued before them
+	HighPriority
+)
+
+// maxPriorityElements is the maximum number of elements with a high priority
+// processed before each element with a normal priority
+const maxPriorityElements = 10
+
+// ErrPartialSync is returned by the sync function when an element with a high
+// priority only applied part of the changes. Otherwise the synchronization of
+// the element also skips the elements with a normal priority enqueued before.
+var ErrPartialSync = errors.New("only part of the changes was applied")
+
 // Queue manages a time work queue through an independent worker that invokes the
 // given sync function for every work item inserted.
 // The queue uses an internal timestamp that allows the removal of certain elements
 // which timestamp is older than the last successful get operation.
+// The elements with a high priority are kept in their own work queue, the worker
+// processes up to maxPriorityElements of them before the next element with a
+// normal priority.
 type Queue struct {
 	// queue is the work queue the worker polls
 	queue workqueue.RateLimitingInterface
+	// priorityQueue contains the elements with a high priority
+	priorityQueue workqueue.RateLimitingInterface
 	// sync is called for each item in the queue
 	sync func(interface{}) error
 	// workerDone is closed when the worker exits
@@ -47,6 +75,9 @@ type Queue struct {
 	fn func(obj interface{}) (interface{}, error)
 	// lastSync is the Unix epoch time of the last execution of 'sync'
 	lastSync int64
+	// lastPrioritySync is the Unix epoch time of the last execution of 'sync'
+	// for an element with a high priority which returned ErrPartialSync
+	lastPrioritySync int64
 }
 
 // Element represents one item of the queue
@@ -54,6 +85,7 @@ type Element struct {
 	Key         interface{}
 	Timestamp   int64
 	IsSkippable bool
+	Priority    Priority
 }
 
 // Run starts processing elements in the queue
@@ -63,17 +95,25 @@ func (t *Queue) Run(period time.Duration, stopCh <-chan struct{}) {
 
 // EnqueueTask enqueues ns/name of the given api object in the task queue.
 func (t *Queue) EnqueueTask(obj interface{}) {
-	t.enqueue(obj, false)
+	t.enqueue(obj, false, NormalPriority)
 }
 
 // EnqueueSkippableTask enqueues ns/name of the given api object in
 // the task queue that can be skipped
 func (t *Queue) EnqueueSkippableTask(obj interface{}) {
-	t.enqueue(obj, true)
+	t.enqueue(obj, true, NormalPriority)
+}
+
+// EnqueueSkippablePriorityTask enqueues ns/name of the given api object in
+// the task queue that can be skipped, with a high priority. The element is
+// processed a second time with a normal priority, after the elements
+// enqueued before it.
+func (t *Queue) EnqueueSkippablePriorityTask(obj interface{}) {
+	t.enqueue(obj, true, HighPriority)
 }
 
 // enqueue enqueues ns/name of the given api object in the task queue.
-func (t *Queue) enqueue(obj interface{}, skippable bool) {
+func (t *Queue) enqueue(obj interface{}, skippable bool, priority Priority) {
 	if t.IsShuttingDown() {
 		klog.Errorf("queue has been shutdown, failed to enqueue: %v", obj)
 		return
@@ -84,12 +124,24 @@ func (t *Queue) enqueue(obj interface{}, skippable bool) {
 		// make sure the timestamp is bigger than lastSync
 		ts = time.Now().Add(24 * time.Hour).UnixNano()
 	}
-	klog.V(3).Infof("queuing item %v", obj)
+	logging.V(3).Infof("queuing item %v", obj)
 	key, err := t.fn(obj)
 	if err != nil {
 		klog.Errorf("%v", err)
 		return
 	}
+
+	if priority == HighPriority {
+		t.priorityQueue.Add(Element{
+			Key:       key,
+			Timestamp: ts,
+			Priority:  HighPriority,
+		})
+	}
+
+	// the elements with a high priority are also added to the queue polled
+	// by the worker, which would not process them otherwise until the next
+	// element with a normal priority
 	t.queue.Add(Element{
 		Key:       key,
 		Timestamp: ts,
@@ -115,32 +167,65 @@ func (t *Queue) worker() {
 			}
 			return
 		}
-		ts := time.Now().UnixNano()
-
-		item := key.(Element)
-		if t.lastSync > item.Timestamp {
-			klog.V(3).Infof("skipping %v sync (%v > %v)", item.Key, t.lastSync, item.Timestamp)
-			t.queue.Forget(key)
-			t.queue.Done(key)
-			continue
-		}
 
-		klog.V(3).Infof("syncing %v", item.Key)
-		if err := t.sync(key); err != nil {
-			klog.Warningf("requeuing %v, err %v", item.Key, err)
-			t.queue.AddRateLimited(Element{
-				Key:       item.Key,
-				Timestamp: time.Now().UnixNano(),
-			})
-		} else {
-			t.queue.Forget(key)
-			t.lastSync = ts
+		// the elements with a high priority enqueued in the meantime are
+		// processed first, the other elements are not delayed indefinitely
+		for i := 0; i < maxPriorityElements && t.priorityQueue.Len() > 0; i++ {
+			priorityKey, quit := t.priorityQueue.Get()
+			if quit {
+				break
+			}
+
+			t.process(t.priorityQueue, priorityKey)
 		}
 
-		t.queue.Done(key)
+		t.process(t.queue, key)
 	}
 }
 
+// process invokes sync for an element of the given work queue, unless a
+// synchronization started after the element was enqueued
+func (t *Queue) process(queue workqueue.RateLimitingInterface, key interface{}) {
+	defer queue.Done(key)
+
+	ts := time.Now().UnixNano()
+
+	item := key.(Element)
+	lastSync := t.lastSync
+	if item.Priority == HighPriority && t.lastPrioritySync > lastSync {
+		lastSync = t.lastPrioritySync
+	}
+
+	if lastSync > item.Timestamp {
+		logging.V(3).Infof("skipping %v sync (%v > %v)", item.Key, lastSync, item.Timestamp)
+		queue.Forget(key)
+		return
+	}
+
+	logging.V(3).Infof("syncing %v", item.Key)
+	err := t.sync(key)
+	if err == ErrPartialSync && item.Priority == HighPriority {
+		// a partial synchronization only skips the elements with a high
+		// priority
+		queue.Forget(key)
+		t.lastPrioritySync = ts
+		return
+	}
+
+	if err != nil {
+		klog.Warningf("requeuing %v, err %v", item.Key, err)
+		queue.AddRateLimited(Element{
+			Key:       item.Key,
+			Timestamp: time.Now().UnixNano(),
+			Priority:  item.Priority,
+		})
+		return
+	}
+
+	queue.Forget(key)
+	t.lastSync = ts
+}
+
 func isClosed(ch <-chan bool) bool {
 	select {
 	case <-ch:
@@ -153,6 +238,7 @@ func isClosed(ch <-chan bool) bool {
 
 // Shutdown shuts down the work queue and waits for the worker to ACK
 func (t *Queue) Shutdown() {
+	t.priorityQueue.ShutDown()
 	t.queue.ShutDown()
 	<-t.workerDone
 }
@@ -171,10 +257,11 @@ func NewTaskQueue(syncFn func(interface{}) error) *Queue {
 // NewCustomTaskQueue ...
 func NewCustomTaskQueue(syncFn func(interface{}) error, fn func(interface{}) (interface{}, error)) *Queue {
 	q := &Queue{
-		queue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
-		sync:       syncFn,
-		workerDone: make(chan bool),
-		fn:         fn,
+		queue:         workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		priorityQueue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		sync:          syncFn,
+		workerDone:    make(chan bool),
+		fn:            fn,
 	}
 
 	if fn == nil {
@@ -18,6 +18,7 @@ package task
 
 import (
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -157,3 +158,162 @@ func TestSkipEnqueue(t *testing.T) {
 	// shutdown queue before exit
 	q.Shutdown()
 }
+
+// partialPrioritySync returns a sync function recording the elements, the
+// synchronizations of the elements with a high priority are partial
+func partialPrioritySync(lock *sync.Mutex, synced *[]Element) func(interface{}) error {
+	return func(key interface{}) error {
+		lock.Lock()
+		defer lock.Unlock()
+		*synced = append(*synced, key.(Element))
+		if key.(Element).Priority == HighPriority {
+			return ErrPartialSync
+		}
+		return nil
+	}
+}
+
+func TestPriorityEnqueue(t *testing.T) {
+	var lock sync.Mutex
+	var synced []Element
+	q := NewTaskQueue(partialPrioritySync(&lock, &synced))
+	stopCh := make(chan struct{})
+
+	q.EnqueueTask(GetDummyObject("first"))
+	q.EnqueueTask(GetDummyObject("second"))
+	q.EnqueueSkippablePriorityTask(GetDummyObject("certificate"))
+	// run queue
+	go q.Run(time.Second, stopCh)
+	// wait for the sync function
+	time.Sleep(time.Millisecond * 10)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// the element with a high priority is processed first, the synchronizations
+	// of the other elements make its second processing unnecessary
+	expected := []struct {
+		key      string
+		priority Priority
+	}{
+		{"certificate", HighPriority},
+		{"first", NormalPriority},
+		{"second", NormalPriority},
+	}
+
+	if len(synced) != len(expected) {
+		t.Fatalf("expected %v synchronizations but %v were executed: %v", len(expected), len(synced), synced)
+	}
+
+	for i, e := range expected {
+		if synced[i].Key != e.key || synced[i].Priority != e.priority {
+			t.Errorf("expected the synchronization %v of %v with priority %v but %v with priority %v was executed", i, e.key, e.priority, synced[i].Key, synced[i].Priority)
+		}
+	}
+
+	// shutdown queue before exit
+	q.Shutdown()
+}
+
+func TestPriorityEnqueueWithoutBacklog(t *testing.T) {
+	var lock sync.Mutex
+	var synced []Element
+	q := NewTaskQueue(partialPrioritySync(&lock, &synced))
+	stopCh := make(chan struct{})
+	// run queue
+	go q.Run(time.Second, stopCh)
+
+	q.EnqueueSkippablePriorityTask(GetDummyObject("certificate"))
+	// wait for the sync function
+	time.Sleep(time.Millisecond * 10)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// the element is processed again with a normal priority
+	if len(synced) != 2 {
+		t.Fatalf("expected 2 synchronizations but %v were executed: %v", len(synced), synced)
+	}
+
+	if synced[0].Priority != HighPriority || synced[1].Priority != NormalPriority {
+		t.Errorf("expected a synchronization with a high priority followed by a normal one but %v were executed", synced)
+	}
+
+	// shutdown queue before exit
+	q.Shutdown()
+}
+
+func TestPriorityEnqueueFullSync(t *testing.T) {
+	var lock sync.Mutex
+	var synced []Element
+	q := NewTaskQueue(func(key interface{}) error {
+		lock.Lock()
+		defer lock.Unlock()
+		synced = append(synced, key.(Element))
+		return nil
+	})
+	stopCh := make(chan struct{})
+
+	q.EnqueueSkippableTask(GetDummyObject("first"))
+	q.EnqueueSkippablePriorityTask(GetDummyObject("certificate"))
+	// run queue
+	go q.Run(time.Second, stopCh)
+	// wait for the sync function
+	time.Sleep(time.Millisecond * 10)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// the synchronization with a high priority applied all the changes, e.g.
+	// after a restart, the elements enqueued before it are skipped
+	if len(synced) != 1 || synced[0].Priority != HighPriority {
+		t.Errorf("expected a single synchronization with a high priority but %v were executed", synced)
+	}
+
+	// shutdown queue before exit
+	q.Shutdown()
+}
+
+func TestPriorityEnqueueLimit(t *testing.T) {
+	var lock sync.Mutex
+	var synced []Element
+	var q *Queue
+	q = NewTaskQueue(func(key interface{}) error {
+		lock.Lock()
+		defer lock.Unlock()
+		synced = append(synced, key.(Element))
+		if key.(Element).Priority != HighPriority {
+			return nil
+		}
+
+		// the elements with a high priority keep coming
+		if len(synced) < 5*maxPriorityElements {
+			q.EnqueueSkippablePriorityTask(GetDummyObject(fmt.Sprintf("certificate-%v", len(synced))))
+		}
+		return ErrPartialSync
+	})
+	stopCh := make(chan struct{})
+
+	q.EnqueueTask(GetDummyObject("first"))
+	q.EnqueueSkippablePriorityTask(GetDummyObject("certificate"))
+	// run queue
+	go q.Run(time.Second, stopCh)
+	// wait for the sync function
+	time.Sleep(time.Millisecond * 50)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// the element with a normal priority is processed after at most
+	// maxPriorityElements elements with a high priority
+	if len(synced) <= maxPriorityElements {
+		t.Fatalf("expected more than %v synchronizations but %v were executed", maxPriorityElements, len(synced))
+	}
+
+	if synced[maxPriorityElements].Key != "first" {
+		t.Errorf("expected the synchronization %v of first but %v was executed", maxPriorityElements, synced[maxPriorityElements].Key)
+	}
+
+	// shutdown queue before exit
+	q.Shutdown()
+}
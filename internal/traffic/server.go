@@ -0,0 +1,206 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package traffic
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// Path defines the location of the canary weights in the traffic management API
+const Path = "/canary-weights"
+
+// CanaryWeight defines the weight of the traffic sent to a canary backend.
+// A nil Weight restores the weight defined in the canary annotations.
+type CanaryWeight struct {
+	Backend string `json:"backend"`
+	Weight  *int   `json:"weight"`
+}
+
+// Manager applies the canary weights to the running NGINX instance
+type Manager interface {
+	// IsCanaryBackend returns true if the backend is configured as canary
+	IsCanaryBackend(backend string) bool
+	// SetCanaryWeight updates the weight of a canary backend
+	SetCanaryWeight(*CanaryWeight) error
+	// CanaryWeights returns the weights configured through the API
+	CanaryWeights() (map[string]int, error)
+}
+
+// Server implements the HTTP API used by progressive delivery controllers
+// (i.e. Flagger or Argo Rollouts) to adjust the weight of canary backends
+// without updating the canary annotations
+type Server struct {
+	Manager Manager
+	Token   string
+}
+
+// NewServer creates a new traffic management API server. Requests must
+// contain the token in the Authorization header using the Bearer scheme.
+func NewServer(token string, m Manager) *Server {
+	return &Server{
+		Manager: m,
+		Token:   token,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != Path {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		weights, err := s.Manager.CanaryWeights()
+		if err != nil {
+			klog.Errorf("Unexpected error obtaining canary weights: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(weights)
+	case http.MethodPost, http.MethodPut:
+		cw := &CanaryWeight{}
+		err := json.NewDecoder(r.Body).Decode(cw)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid canary weight: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if cw.Weight == nil {
+			http.Error(w, "weight must be specified", http.StatusBadRequest)
+			return
+		}
+
+		s.setCanaryWeight(w, cw)
+	case http.MethodDelete:
+		s.setCanaryWeight(w, &CanaryWeight{Backend: r.URL.Query().Get("backend")})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) setCanaryWeight(w http.ResponseWriter, cw *CanaryWeight) {
+	if cw.Backend == "" {
+		http.Error(w, "backend must be specified", http.StatusBadRequest)
+		return
+	}
+
+	if cw.Weight != nil && (*cw.Weight < 0 || *cw.Weight > 100) {
+		http.Error(w, "weight must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+
+	if !s.Manager.IsCanaryBackend(cw.Backend) {
+		http.Error(w, fmt.Sprintf("backend %v is not a canary backend", cw.Backend), http.StatusNotFound)
+		return
+	}
+
+	err := s.Manager.SetCanaryWeight(cw)
+	if err != nil {
+		klog.Errorf("Unexpected error updating the weight of canary backend %v: %v", cw.Backend, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if cw.Weight == nil {
+		klog.Infof("Restored the annotation weight of canary backend %v", cw.Backend)
+	} else {
+		klog.Infof("Updated the weight of canary backend %v to %v", cw.Backend, *cw.Weight)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if s.Token == "" || !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) == 1
+}
+
+// Listen announces on the address of the traffic management API. The address
+// can be a unix socket using the form unix:/path/to/socket or a TCP address
+// of the loopback interface using the form <host>:<port>
+func Listen(address string) (net.Listener, error) {
+	if strings.HasPrefix(address, "unix:") {
+		socket := strings.TrimPrefix(address, "unix:")
+		// remove the socket of a previous execution
+		os.Remove(socket)
+
+		listener, err := net.Listen("unix", socket)
+		if err != nil {
+			return nil, err
+		}
+
+		err = os.Chmod(socket, 0600)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+
+		return listener, nil
+	}
+
+	return net.Listen("tcp", address)
+}
+
+// ValidateAddress checks the address is a unix socket or a TCP address
+// of the loopback interface
+func ValidateAddress(address string) error {
+	if strings.HasPrefix(address, "unix:") {
+		if strings.TrimPrefix(address, "unix:") == "" {
+			return fmt.Errorf("the path of the unix socket is empty")
+		}
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if host == "localhost" {
+		return nil
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%v is not an address of the loopback interface", host)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package traffic
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type mockManager struct {
+	weights map[string]int
+	err     error
+}
+
+func (m *mockManager) IsCanaryBackend(backend string) bool {
+	return backend == "default-canary-80"
+}
+
+func (m *mockManager) SetCanaryWeight(cw *CanaryWeight) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	if cw.Weight == nil {
+		delete(m.weights, cw.Backend)
+		return nil
+	}
+
+	m.weights[cw.Backend] = *cw.Weight
+	return nil
+}
+
+func (m *mockManager) CanaryWeights() (map[string]int, error) {
+	return m.weights, m.err
+}
+
+func TestServer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     string
+		err      error
+		expected int
+		weights  map[string]int
+	}{
+		{"without token", http.MethodPost, Path, "", `{"backend":"default-canary-80","weight":20}`, nil, http.StatusUnauthorized, map[string]int{}},
+		{"invalid token", http.MethodPost, Path, "invalid", `{"backend":"default-canary-80","weight":20}`, nil, http.StatusUnauthorized, map[string]int{}},
+		{"invalid path", http.MethodPost, "/other", "secret", `{"backend":"default-canary-80","weight":20}`, nil, http.StatusNotFound, map[string]int{}},
+		{"set weight", http.MethodPost, Path, "secret", `{"backend":"default-canary-80","weight":20}`, nil, http.StatusNoContent, map[string]int{"default-canary-80": 20}},
+		{"invalid json", http.MethodPost, Path, "secret", `{"backend":`, nil, http.StatusBadRequest, map[string]int{}},
+		{"missing weight", http.MethodPost, Path, "secret", `{"backend":"default-canary-80"}`, nil, http.StatusBadRequest, map[string]int{}},
+		{"missing backend", http.MethodPost, Path, "secret", `{"weight":20}`, nil, http.StatusBadRequest, map[string]int{}},
+		{"weight out of range", http.MethodPost, Path, "secret", `{"backend":"default-canary-80","weight":120}`, nil, http.StatusBadRequest, map[string]int{}},
+		{"unknown backend", http.MethodPost, Path, "secret", `{"backend":"default-app-80","weight":20}`, nil, http.StatusNotFound, map[string]int{}},
+		{"error updating weight", http.MethodPost, Path, "secret", `{"backend":"default-canary-80","weight":20}`, fmt.Errorf("nginx is not running"), http.StatusInternalServerError, map[string]int{}},
+		{"delete weight", http.MethodDelete, Path + "?backend=default-canary-80", "secret", "", nil, http.StatusNoContent, map[string]int{}},
+		{"invalid method", http.MethodPatch, Path, "secret", "", nil, http.StatusMethodNotAllowed, map[string]int{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := &mockManager{weights: map[string]int{}, err: tc.err}
+			if tc.method == http.MethodDelete {
+				m.weights["default-canary-80"] = 30
+			}
+
+			r := httptest.NewRequest(tc.method, "http://localhost"+tc.path, strings.NewReader(tc.body))
+			if tc.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+
+			NewServer("secret", m).ServeHTTP(w, r)
+			if w.Code != tc.expected {
+				t.Errorf("expected status code %v but returned %v (%v)", tc.expected, w.Code, w.Body.String())
+			}
+
+			if len(m.weights) != len(tc.weights) {
+				t.Errorf("expected weights %v but returned %v", tc.weights, m.weights)
+			}
+			for backend, weight := range tc.weights {
+				if m.weights[backend] != weight {
+					t.Errorf("expected weights %v but returned %v", tc.weights, m.weights)
+				}
+			}
+		})
+	}
+}
+
+func TestServerCanaryWeights(t *testing.T) {
+	m := &mockManager{weights: map[string]int{"default-canary-80": 30}}
+
+	r := httptest.NewRequest(http.MethodGet, "http://localhost"+Path, nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+
+	NewServer("secret", m).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status code %v but returned %v", http.StatusOK, w.Code)
+	}
+
+	expected := `{"default-canary-80":30}`
+	if strings.TrimSpace(w.Body.String()) != expected {
+		t.Errorf("expected %v but returned %v", expected, w.Body.String())
+	}
+}
+
+func TestServerWithoutToken(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://localhost"+Path, nil)
+	r.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+
+	NewServer("", &mockManager{}).ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected status code %v but returned %v", http.StatusUnauthorized, w.Code)
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	testCases := map[string]bool{
+		"127.0.0.1:10247":          true,
+		"[::1]:10247":              true,
+		"localhost:10247":          true,
+		"unix:/tmp/traffic.sock":   true,
+		"unix:":                    false,
+		"0.0.0.0:10247":            false,
+		"10.0.0.1:10247":           false,
+		":10247":                   false,
+		"127.0.0.1":                false,
+		"ingress.example.com:1024": false,
+	}
+
+	for address, valid := range testCases {
+		err := ValidateAddress(address)
+		if valid && err != nil {
+			t.Errorf("expected %v to be valid but returned %v", address, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("expected %v to be invalid", address)
+		}
+	}
+}
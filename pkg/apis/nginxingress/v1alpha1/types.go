@@ -0,0 +1,131 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HostDelegation declares the namespace of the object as the owner of a host
+// and delegates path prefixes of the host to Ingresses in other namespaces.
+// The Ingress controller merges the rules of the owner and of the delegated
+// Ingresses in a single server and ignores the rules that are not delegated.
+type HostDelegation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the desired state of the HostDelegation.
+	Spec HostDelegationSpec `json:"spec"`
+}
+
+// HostDelegationSpec describes the host and the delegated path prefixes
+type HostDelegationSpec struct {
+	// Host is the fully qualified domain name owned by the namespace
+	// of the HostDelegation.
+	Host string `json:"host"`
+
+	// Delegations is the list of path prefixes delegated to other namespaces.
+	// +optional
+	Delegations []Delegation `json:"delegations,omitempty"`
+}
+
+// Delegation allows Ingresses of a list of namespaces to define
+// rules for paths under a prefix.
+type Delegation struct {
+	// PathPrefix is the prefix of the delegated paths, i.e. /api
+	PathPrefix string `json:"pathPrefix"`
+
+	// Namespaces is the list of namespaces allowed to use the prefix.
+	Namespaces []string `json:"namespaces"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// HostDelegationList is a list of HostDelegation
+type HostDelegationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is the list of HostDelegation.
+	Items []HostDelegation `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +resourceName=nginxingressclassparams
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NginxIngressClassParams contains the policies of the Ingresses of the class
+// named like the object. The parameters override, only for the class, the
+// default certificate, the allowed annotations and the default timeouts of
+// the controller.
+type NginxIngressClassParams struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the desired state of the NginxIngressClassParams.
+	Spec NginxIngressClassParamsSpec `json:"spec"`
+}
+
+// NginxIngressClassParamsSpec describes the policies of an ingress class
+type NginxIngressClassParamsSpec struct {
+	// DefaultSSLCertificate is the Secret (namespace/name) containing the
+	// certificate used instead of the default certificate by the hosts of
+	// the Ingresses of the class.
+	// +optional
+	DefaultSSLCertificate string `json:"defaultSSLCertificate,omitempty"`
+
+	// AllowedAnnotations is the list of annotations (without prefix) that
+	// can be set by the Ingresses of the class and the namespace defaults.
+	// All the annotations are allowed if the list is empty.
+	// +optional
+	AllowedAnnotations []string `json:"allowedAnnotations,omitempty"`
+
+	// Timeouts contains the default proxy timeouts of the Ingresses of the
+	// class, used when an Ingress does not define the annotation.
+	// +optional
+	Timeouts *Timeouts `json:"timeouts,omitempty"`
+}
+
+// Timeouts defines proxy timeouts in seconds
+type Timeouts struct {
+	// Connect is the default value of the proxy-connect-timeout annotation.
+	// +optional
+	Connect int `json:"connect,omitempty"`
+
+	// Send is the default value of the proxy-send-timeout annotation.
+	// +optional
+	Send int `json:"send,omitempty"`
+
+	// Read is the default value of the proxy-read-timeout annotation.
+	// +optional
+	Read int `json:"read,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NginxIngressClassParamsList is a list of NginxIngressClassParams
+type NginxIngressClassParamsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	// Items is the list of NginxIngressClassParams.
+	Items []NginxIngressClassParams `json:"items"`
+}
@@ -0,0 +1,187 @@
+local cjson = require("cjson.safe")
+local sink_util = require("util.sink")
+
+local math_max = math.max
+local math_min = math.min
+local string_format = string.format
+local table_insert = table.insert
+
+-- measured in seconds, upper bound of the delay between two attempts
+-- to send a batch to an unavailable sink
+local MAX_BACKOFF = 30
+
+local _M = {}
+
+local config
+local sink
+local buffer = {}
+-- delay before the next attempt while the sink is unavailable
+local backoff = 0
+local retry_at = 0
+local flushing = false
+
+-- the counters are kept in a shared dictionary, so the status endpoint
+-- returns the values of all the workers
+local function incr(key, value)
+  local stats = ngx.shared.access_events
+  if not stats then
+    return
+  end
+
+  local _, err = stats:incr(key, value, 0)
+  if err then
+    ngx.log(ngx.WARN, string_format("could not increment %s: %s", key, tostring(err)))
+  end
+end
+
+local function set_buffered()
+  local stats = ngx.shared.access_events
+  if stats then
+    stats:set("buffered:" .. tostring(ngx.worker.id()), #buffer)
+  end
+end
+
+local function number(value)
+  return tonumber(value) or -1
+end
+
+local function event()
+  return {
+    time = ngx.var.time_iso8601,
+    request_id = ngx.var.req_id,
+    remote_addr = ngx.var.remote_addr,
+    host = ngx.var.host,
+    method = ngx.var.request_method,
+    uri = ngx.var.uri,
+    status = number(ngx.var.status),
+    request_length = number(ngx.var.request_length),
+    request_time = number(ngx.var.request_time),
+    bytes_sent = number(ngx.var.bytes_sent),
+    user_agent = ngx.var.http_user_agent,
+
+    namespace = ngx.var.namespace,
+    ingress = ngx.var.ingress_name,
+    service = ngx.var.service_name,
+    path = ngx.var.location_path,
+    canary = ngx.var.canary_variant,
+
+    upstream_name = ngx.var.proxy_upstream_name,
+    upstream_addr = ngx.var.upstream_addr,
+    upstream_status = ngx.var.upstream_status,
+    upstream_response_time = ngx.var.upstream_response_time,
+  }
+end
+
+-- removes the first count events, keeping the ones added while the batch was sent
+local function shift(count)
+  local remaining = {}
+  for i = count + 1, #buffer do
+    table_insert(remaining, buffer[i])
+  end
+  buffer = remaining
+end
+
+local function flush(premature)
+  if flushing or #buffer == 0 then
+    return
+  end
+
+  -- the sink is not retried before the end of the backoff, except to send
+  -- the last events when the worker exits
+  if not premature and ngx.now() < retry_at then
+    return
+  end
+
+  flushing = true
+
+  while #buffer > 0 do
+    local batch = {}
+    for i = 1, math_min(config.batch_size, #buffer) do
+      batch[i] = buffer[i]
+    end
+
+    local ok, err = sink_util.send(sink, batch)
+    if not ok then
+      incr("failed_batches", 1)
+      backoff = math_min(math_max(backoff * 2, config.flush_interval), MAX_BACKOFF)
+      retry_at = ngx.now() + backoff
+      ngx.log(ngx.WARN, string_format("could not send %d access events to %s, retrying in %ds: %s",
+        #batch, config.sink, backoff, tostring(err)))
+      break
+    end
+
+    shift(#batch)
+    incr("sent", #batch)
+    backoff = 0
+  end
+
+  set_buffered()
+  flushing = false
+end
+
+function _M.init_worker(access_events_config)
+  config = access_events_config
+
+  sink = sink_util.parse(config.sink)
+  if not sink then
+    ngx.log(ngx.ERR, "invalid access events sink: " .. tostring(config.sink))
+    return
+  end
+
+  local _, err = ngx.timer.every(config.flush_interval, flush)
+  if err then
+    ngx.log(ngx.ERR, string_format("error when setting up timer.every for access events: %s", tostring(err)))
+  end
+end
+
+function _M.log()
+  if not sink then
+    return
+  end
+
+  -- backpressure: the events are dropped while the sink can't keep up
+  if #buffer >= config.buffer_size then
+    incr("dropped", 1)
+    return
+  end
+
+  local encoded, err = cjson.encode(event())
+  if not encoded then
+    ngx.log(ngx.ERR, "could not encode the access event: ", tostring(err))
+    return
+  end
+
+  table_insert(buffer, encoded)
+end
+
+-- returns the counters of all the workers, read by the metrics collector
+function _M.status()
+  local stats = ngx.shared.access_events
+  if not stats then
+    return ngx.exit(ngx.HTTP_NOT_FOUND)
+  end
+
+  local status = {
+    sent = stats:get("sent") or 0,
+    dropped = stats:get("dropped") or 0,
+    failedBatches = stats:get("failed_batches") or 0,
+    buffered = 0,
+  }
+
+  for _, key in ipairs(stats:get_keys(0)) do
+    if key:find("^buffered:") then
+      status.buffered = status.buffered + (stats:get(key) or 0)
+    end
+  end
+
+  ngx.header.content_type = "application/json"
+  ngx.say(cjson.encode(status))
+end
+
+if _TEST then
+  _M.flush = flush
+  _M.buffer = function() return buffer end
+  _M.backoff = function() return backoff end
+end
+
+return _M
@@ -0,0 +1,70 @@
+local cjson = require("cjson.safe")
+
+local string_sub = string.sub
+local string_lower = string.lower
+
+local _M = {}
+
+-- the captured headers are stored in a variable because the
+-- auth_request subrequest shares the variables with the parent request
+local VARIABLE = "auth_prefixed_headers"
+
+local function captured_headers()
+  local raw = ngx.var[VARIABLE]
+  if not raw or raw == "" then
+    return nil
+  end
+
+  local headers, err = cjson.decode(raw)
+  if not headers then
+    ngx.log(ngx.ERR, "could not decode auth response headers: ", tostring(err))
+    return nil
+  end
+
+  return headers
+end
+
+-- copies the headers of the auth response matching any of the prefixes,
+-- replacing the prefix with the configured replacement.
+-- It must be called in the header filter phase of the auth location.
+function _M.capture(prefixes)
+  local headers = {}
+
+  for name, value in pairs(ngx.resp.get_headers()) do
+    name = string_lower(name)
+    for _, p in ipairs(prefixes) do
+      if string_sub(name, 1, #p.prefix) == p.prefix and #name > #p.prefix then
+        headers[p.replacement .. string_sub(name, #p.prefix + 1)] = value
+        break
+      end
+    end
+  end
+
+  ngx.var[VARIABLE] = cjson.encode(headers)
+end
+
+-- adds the captured headers to the request sent to the upstream
+function _M.set_request_headers()
+  local headers = captured_headers()
+  if not headers then
+    return
+  end
+
+  for name, value in pairs(headers) do
+    ngx.req.set_header(name, value)
+  end
+end
+
+-- adds the captured headers to the response sent to the client
+function _M.set_response_headers()
+  local headers = captured_headers()
+  if not headers then
+    return
+  end
+
+  for name, value in pairs(headers) do
+    ngx.header[name] = value
+  end
+end
+
+return _M
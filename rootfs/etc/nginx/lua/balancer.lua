@@ -3,28 +3,58 @@ local cjson = require("cjson.safe")
 local util = require("util")
 local dns_util = require("util.dns")
 local configuration = require("configuration")
+local shared_state = require("shared_state")
 local round_robin = require("balancer.round_robin")
 local chash = require("balancer.chash")
 local chashsubset = require("balancer.chashsubset")
 local sticky = require("balancer.sticky")
 local ewma = require("balancer.ewma")
+local least_conn_weighted = require("balancer.least_conn_weighted")
+local peak_ewma = require("balancer.peak_ewma")
 
 -- measured in seconds
 -- for an Nginx worker to pick up the new list of upstream peers
 -- it will take <the delay until controller POSTed the backend object to the Nginx endpoint> + BACKENDS_SYNC_INTERVAL
 local BACKENDS_SYNC_INTERVAL = 1
+-- measured in seconds
+-- interval between two checks of the version of the backends, the changes
+-- POSTed by the controller are picked up within this interval
+local BACKENDS_VERSION_CHECK_INTERVAL = 0.1
 
 local DEFAULT_LB_ALG = "round_robin"
+-- defaults of the failover-max-fails and failover-fail-timeout annotations
+local DEFAULT_FAILOVER_MAX_FAILS = 3
+local DEFAULT_FAILOVER_FAIL_TIMEOUT = 10
+-- shared map containing the end of the down period of the backends marked
+-- down by any replica
+local FAILOVER_STATE_MAP = "failover_down_until"
 local IMPLEMENTATIONS = {
   round_robin = round_robin,
   chash = chash,
   chashsubset = chashsubset,
   sticky = sticky,
   ewma = ewma,
+  least_conn_weighted = least_conn_weighted,
+  peak_ewma = peak_ewma,
 }
 
 local _M = {}
 local balancers = {}
+-- failover configuration of the backends, kept even when the backend has no
+-- balancer because all of its endpoints are gone
+local failovers = {}
+-- number of consecutive failures and end of the down period of the backends
+-- with a failover, tracked per worker
+local failover_states = {}
+-- time since the backends have no endpoint, the Retry-After header of their
+-- 503 responses grows with it
+local no_endpoints_since = {}
+-- Retry-After header and page of the 503 responses of the backends without endpoints
+local no_endpoints_config = {}
+-- version of the backends the balancers were synchronized with
+local backends_version
+
+shared_state.register(FAILOVER_STATE_MAP)
 
 local function get_implementation(backend)
   local name = backend["load-balance"] or DEFAULT_LB_ALG
@@ -73,18 +103,83 @@ local function format_ipv6_endpoints(endpoints)
   return formatted_endpoints
 end
 
+local function set_pod_routing(balancer, backend)
+  if util.is_blank(backend.podRoutingBy) then
+    balancer.pod_routing_by = nil
+    balancer.pod_backends = nil
+    return
+  end
+
+  balancer.pod_routing_by = backend.podRoutingBy
+  balancer.pod_backends = backend.podBackends or {}
+end
+
+-- returns the key of an endpoint as returned by the balancer implementations
+local function endpoint_key(endpoint)
+  local address = endpoint.address
+  if not address:match("^%d+.%d+.%d+.%d+$") and not address:match("^%[") then
+    address = string.format("[%s]", address)
+  end
+  return address .. ":" .. endpoint.port
+end
+
+-- keeps the namespace, service and pod of the endpoints, sent by the
+-- controller when enable-backend-metadata is enabled
+local function set_backend_metadata(balancer, backend)
+  local service = backend.service and backend.service.metadata
+  if not service or util.is_blank(service.name) then
+    balancer.backend_metadata = nil
+    return
+  end
+
+  local pods = {}
+  for _, endpoint in ipairs(backend.endpoints) do
+    if endpoint.target then
+      pods[endpoint_key(endpoint)] = endpoint.target.name
+    end
+  end
+
+  balancer.backend_metadata = {
+    namespace = service.namespace,
+    service = service.name,
+    pods = pods,
+  }
+end
+
+local function set_failover(backend)
+  local failover = backend.failover
+  if not failover or util.is_blank(failover.backend) then
+    failovers[backend.name] = nil
+    failover_states[backend.name] = nil
+    return
+  end
+
+  failovers[backend.name] = {
+    backend = failover.backend,
+    max_fails = failover.maxFails or DEFAULT_FAILOVER_MAX_FAILS,
+    fail_timeout = failover.failTimeout or DEFAULT_FAILOVER_FAIL_TIMEOUT,
+  }
+end
+
 local function sync_backend(backend)
+  set_failover(backend)
+
   if not backend.endpoints or #backend.endpoints == 0 then
     ngx.log(ngx.INFO, string.format("there is no endpoint for backend %s. Removing...", backend.name))
     balancers[backend.name] = nil
+    no_endpoints_since[backend.name] = no_endpoints_since[backend.name] or ngx.now()
     return
   end
 
+  no_endpoints_since[backend.name] = nil
+
   local implementation = get_implementation(backend)
   local balancer = balancers[backend.name]
 
   if not balancer then
     balancers[backend.name] = implementation:new(backend)
+    set_pod_routing(balancers[backend.name], backend)
+    set_backend_metadata(balancers[backend.name], backend)
     return
   end
 
@@ -95,9 +190,14 @@ local function sync_backend(backend)
     ngx.log(ngx.INFO,
       string.format("LB algorithm changed from %s to %s, resetting the instance", balancer.name, implementation.name))
     balancers[backend.name] = implementation:new(backend)
+    set_pod_routing(balancers[backend.name], backend)
+    set_backend_metadata(balancers[backend.name], backend)
     return
   end
 
+  set_pod_routing(balancer, backend)
+  set_backend_metadata(balancer, backend)
+
   local service_type = backend.service and backend.service.spec and backend.service.spec["type"]
   if service_type == "ExternalName" then
     backend = resolve_external_names(backend)
@@ -109,6 +209,9 @@ local function sync_backend(backend)
 end
 
 local function sync_backends()
+  -- the version is read first, a change made meanwhile is synchronized again
+  backends_version = configuration.get_backends_version()
+
   local backends_data = configuration.get_backends_data()
   if not backends_data then
     balancers = {}
@@ -122,9 +225,11 @@ local function sync_backends()
   end
 
   local balancers_to_keep = {}
+  local backends_to_keep = {}
   for _, new_backend in ipairs(new_backends) do
     sync_backend(new_backend)
     balancers_to_keep[new_backend.name] = balancers[new_backend.name]
+    backends_to_keep[new_backend.name] = true
   end
 
   for backend_name, _ in pairs(balancers) do
@@ -132,6 +237,55 @@ local function sync_backends()
       balancers[backend_name] = nil
     end
   end
+
+  for backend_name, _ in pairs(failovers) do
+    if not backends_to_keep[backend_name] then
+      failovers[backend_name] = nil
+      failover_states[backend_name] = nil
+    end
+  end
+
+  for backend_name, _ in pairs(no_endpoints_since) do
+    if not backends_to_keep[backend_name] then
+      no_endpoints_since[backend_name] = nil
+    end
+  end
+end
+
+local function sync_changed_backends()
+  if configuration.get_backends_version() == backends_version then
+    return
+  end
+
+  sync_backends()
+end
+
+-- the Retry-After header doubles from retry_after, up to retry_after_max,
+-- while the backend has no endpoint
+local function get_no_endpoints_retry_after(config, backend_name)
+  local retry_after = config.retry_after or 0
+  if retry_after <= 0 then
+    return nil
+  end
+
+  local retry_after_max = config.retry_after_max or 0
+  local since = no_endpoints_since[backend_name]
+  if retry_after_max <= retry_after or not since then
+    return retry_after
+  end
+
+  local elapsed = ngx.now() - since
+  while retry_after < elapsed and retry_after < retry_after_max do
+    retry_after = retry_after * 2
+  end
+
+  return math.min(retry_after, retry_after_max)
+end
+
+-- the weight set through the traffic management API takes precedence
+-- over the weight defined in the canary annotations
+local function get_canary_weight(backend_name, traffic_shaping_policy)
+  return configuration.get_canary_weight(backend_name) or traffic_shaping_policy.weight or 0
 end
 
 local function route_to_alternative_balancer(balancer)
@@ -182,45 +336,234 @@ local function route_to_alternative_balancer(balancer)
     end
   end
 
-  if math.random(100) <= traffic_shaping_policy.weight then
+  if math.random(100) <= get_canary_weight(backend_name, traffic_shaping_policy) then
     return true
   end
 
   return false
 end
 
+local function route_to_pod_balancer(balancer)
+  if not balancer.pod_routing_by then
+    return nil
+  end
+
+  local pod = util.lua_ngx_var(balancer.pod_routing_by)
+  if util.is_blank(pod) then
+    return nil
+  end
+
+  local backend_name = balancer.pod_backends[pod]
+  if not backend_name then
+    ngx.log(ngx.WARN, string.format("no pod %s found for pod routing", tostring(pod)))
+    return nil
+  end
+
+  return backend_name
+end
+
+local function is_down(backend_name)
+  local state = failover_states[backend_name]
+  if state ~= nil and state.down_until > ngx.now() then
+    return true
+  end
+
+  -- the backend may have been marked down by another replica
+  local down_until = tonumber(shared_state.get(FAILOVER_STATE_MAP, backend_name))
+  return down_until ~= nil and down_until > ngx.now()
+end
+
+-- returns the name of the failover backend when the backend has no endpoint
+-- or was marked down after too many consecutive failures
+local function route_to_failover_balancer(backend_name, balancer)
+  local failover = failovers[backend_name]
+  if not failover then
+    return nil
+  end
+
+  if balancer and not is_down(backend_name) then
+    return nil
+  end
+
+  if not balancers[failover.backend] then
+    ngx.log(ngx.WARN, "no balancer for failover backend: " .. tostring(failover.backend))
+    return nil
+  end
+
+  return failover.backend
+end
+
+local function is_failed_request()
+  local status = ngx.var.upstream_status
+  if util.is_blank(status) then
+    return false
+  end
+
+  -- with retries the status of every tried peer is listed, the last one
+  -- is the response sent to the client
+  local last = tonumber(status:match("(%d+)[^%d]*$"))
+  return last == 502 or last == 503 or last == 504
+end
+
+-- counts the consecutive failures of the requests sent to a backend with a
+-- failover. When the down period ends the requests are sent to the backend
+-- again: a single failure marks it down again while a success fails the
+-- traffic back to the backend.
+local function record_failover_result(backend_name)
+  local failover = failovers[backend_name]
+  if not failover then
+    return
+  end
+
+  local state = failover_states[backend_name]
+  if not state then
+    state = { fails = 0, down_until = 0, probing = false }
+    failover_states[backend_name] = state
+  end
+
+  if not is_failed_request() then
+    if state.probing then
+      ngx.log(ngx.INFO, string.format("backend %s is up again, failing back", backend_name))
+      shared_state.delete(FAILOVER_STATE_MAP, backend_name)
+    end
+    state.fails = 0
+    state.probing = false
+    return
+  end
+
+  state.fails = state.fails + 1
+  if state.probing or state.fails >= failover.max_fails then
+    ngx.log(ngx.WARN, string.format("backend %s is down, failing over to %s for %ds",
+      backend_name, failover.backend, failover.fail_timeout))
+    state.fails = 0
+    state.down_until = ngx.now() + failover.fail_timeout
+    state.probing = true
+    shared_state.set(FAILOVER_STATE_MAP, backend_name, state.down_until)
+  end
+end
+
+-- records the canary decision taken for the request so it can be used
+-- in the access log and in the metrics sent by the monitor module
+local function set_canary_decision(balancer, is_canary)
+  if not balancer.alternative_backends then
+    return
+  end
+
+  local backend_name = balancer.alternative_backends[1]
+  local alternative_balancer = balancers[backend_name]
+  if not alternative_balancer or not alternative_balancer.traffic_shaping_policy then
+    return
+  end
+
+  ngx.var.canary_variant = is_canary and "canary" or "stable"
+  ngx.var.canary_weight = tostring(get_canary_weight(backend_name, alternative_balancer.traffic_shaping_policy))
+end
+
+-- exposes the namespace, service and pod of the peer chosen for the request
+local function set_backend_variables(balancer, peer)
+  local metadata = balancer.backend_metadata
+  if not metadata then
+    return
+  end
+
+  ngx.var.backend_namespace = metadata.namespace or ""
+  ngx.var.backend_service = metadata.service
+  ngx.var.backend_pod = metadata.pods[peer] or ""
+end
+
 local function get_balancer()
+  -- the decision must be taken only once per request, otherwise the
+  -- rewrite, balancer and log phases could pick different backends
+  if ngx.ctx.balancer then
+    return ngx.ctx.balancer
+  end
+
   local backend_name = ngx.var.proxy_upstream_name
 
   local balancer = balancers[backend_name]
+
+  local failover_backend_name = route_to_failover_balancer(backend_name, balancer)
+  if failover_backend_name then
+    ngx.var.proxy_alternative_upstream_name = failover_backend_name
+
+    ngx.ctx.balancer = balancers[failover_backend_name]
+    return ngx.ctx.balancer
+  end
+
   if not balancer then
     return
   end
 
+  local pod_backend_name = route_to_pod_balancer(balancer)
+  if pod_backend_name and balancers[pod_backend_name] then
+    ngx.var.proxy_alternative_upstream_name = pod_backend_name
+
+    ngx.ctx.balancer = balancers[pod_backend_name]
+    return ngx.ctx.balancer
+  end
+
   if route_to_alternative_balancer(balancer) then
     local alternative_backend_name = balancer.alternative_backends[1]
     ngx.var.proxy_alternative_upstream_name = alternative_backend_name
+    set_canary_decision(balancer, true)
+
+    ngx.ctx.balancer = balancers[alternative_backend_name]
+    return ngx.ctx.balancer
+  end
 
-    return balancers[alternative_backend_name]
+  set_canary_decision(balancer, false)
+
+  if failovers[backend_name] then
+    ngx.ctx.failover_backend_name = backend_name
   end
 
+  ngx.ctx.balancer = balancer
   return balancer
 end
 
-function _M.init_worker()
+function _M.init_worker(config)
+  no_endpoints_config = config or {}
+
   sync_backends() -- when worker starts, sync backends without delay
   local _, err = ngx.timer.every(BACKENDS_SYNC_INTERVAL, sync_backends)
   if err then
     ngx.log(ngx.ERR, string.format("error when setting up timer.every for sync_backends: %s", tostring(err)))
   end
+
+  _, err = ngx.timer.every(BACKENDS_VERSION_CHECK_INTERVAL, sync_changed_backends)
+  if err then
+    ngx.log(ngx.ERR, string.format("error when setting up timer.every for sync_changed_backends: %s", tostring(err)))
+  end
 end
 
 function _M.rewrite()
   local balancer = get_balancer()
-  if not balancer then
+  if balancer then
+    return
+  end
+
+  local retry_after = get_no_endpoints_retry_after(no_endpoints_config, ngx.var.proxy_upstream_name)
+  local page = no_endpoints_config.page
+  if not retry_after and util.is_blank(page) then
     ngx.status = ngx.HTTP_SERVICE_UNAVAILABLE
     return ngx.exit(ngx.status)
   end
+
+  -- the headers set before ngx.exit(503) are dropped by the error pages of
+  -- NGINX, the response is sent here instead
+  ngx.status = ngx.HTTP_SERVICE_UNAVAILABLE
+  if retry_after then
+    ngx.header["Retry-After"] = retry_after
+  end
+  if util.is_blank(page) then
+    ngx.header["Content-Type"] = "text/plain"
+    page = "503 Service Temporarily Unavailable\n"
+  else
+    ngx.header["Content-Type"] = "text/html"
+  end
+  ngx.print(page)
+
+  return ngx.exit(ngx.HTTP_OK)
 end
 
 function _M.balance()
@@ -235,6 +578,10 @@ function _M.balance()
     return
   end
 
+  -- the last peer is the one that served the request when the
+  -- request is retried
+  set_backend_variables(balancer, peer)
+
   ngx_balancer.set_more_tries(1)
 
   local ok, err = ngx_balancer.set_current_peer(peer)
@@ -249,6 +596,10 @@ function _M.log()
     return
   end
 
+  if ngx.ctx.failover_backend_name then
+    record_failover_result(ngx.ctx.failover_backend_name)
+  end
+
   if not balancer.after_balance then
     return
   end
@@ -260,6 +611,12 @@ if _TEST then
   _M.get_implementation = get_implementation
   _M.sync_backend = sync_backend
   _M.route_to_alternative_balancer = route_to_alternative_balancer
+  _M.route_to_pod_balancer = route_to_pod_balancer
+  _M.route_to_failover_balancer = route_to_failover_balancer
+  _M.record_failover_result = record_failover_result
+  _M.get_balancer = get_balancer
+  _M.set_backend_variables = set_backend_variables
+  _M.get_no_endpoints_retry_after = get_no_endpoints_retry_after
 end
 
 return _M
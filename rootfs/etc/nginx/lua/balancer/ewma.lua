@@ -47,7 +47,8 @@ local function score(self, upstream)
   -- Original implementation used names
   -- Endpoints don't have names, so passing in IP:Port as key instead
   local upstream_name = upstream.address .. ":" .. upstream.port
-  return get_or_update_ewma(self, upstream_name, 0, false)
+  local ewma = get_or_update_ewma(self, upstream_name, 0, false)
+  return ewma / util.relative_endpoint_weight(upstream)
 end
 
 -- implementation similar to https://en.wikipedia.org/wiki/Fisher%E2%80%93Yates_shuffle
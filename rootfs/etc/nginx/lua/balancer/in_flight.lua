@@ -0,0 +1,38 @@
+-- counts the requests sent to the peers of a balancer and not completed yet,
+-- per worker. A request retried on several peers is counted on each of them
+-- until it completes.
+local table_insert = table.insert
+
+local _M = {}
+
+function _M.get(balancer, peer)
+  return balancer.in_flight[peer] or 0
+end
+
+function _M.acquire(balancer, peer)
+  balancer.in_flight[peer] = _M.get(balancer, peer) + 1
+
+  local peers = ngx.ctx.in_flight_peers
+  if not peers then
+    peers = {}
+    ngx.ctx.in_flight_peers = peers
+  end
+  table_insert(peers, peer)
+end
+
+-- releases the peers acquired by the request, called in the log phase
+function _M.release(balancer)
+  local peers = ngx.ctx.in_flight_peers
+  if not peers then
+    return
+  end
+
+  for _, peer in ipairs(peers) do
+    local count = _M.get(balancer, peer)
+    balancer.in_flight[peer] = count > 1 and count - 1 or nil
+  end
+
+  ngx.ctx.in_flight_peers = nil
+end
+
+return _M
@@ -0,0 +1,144 @@
+local in_flight = require("balancer.in_flight")
+local util = require("util")
+local split = require("util.split")
+
+local math_exp = math.exp
+local math_max = math.max
+local math_min = math.min
+local math_random = math.random
+local string_format = string.format
+
+-- measured in seconds, decay of the latency of the endpoints
+local DECAY_TIME = 10
+-- bounds of the weight of an endpoint, relative to the average latency of the
+-- endpoints of the backend, so the slow endpoints keep a share of the requests
+local MIN_WEIGHT = 0.1
+local MAX_WEIGHT = 10
+
+local _M = { name = "least_conn_weighted" }
+
+local function peer_key(endpoint)
+  return endpoint.address .. ":" .. endpoint.port
+end
+
+local function average_latency(self)
+  local total, count = 0, 0
+  for _, endpoint in ipairs(self.peers) do
+    local latency = self.latency[peer_key(endpoint)]
+    if latency then
+      total, count = total + latency, count + 1
+    end
+  end
+
+  if count == 0 or total == 0 then
+    return nil
+  end
+
+  return total / count
+end
+
+-- the weight of an endpoint is the average latency of the backend divided by
+-- its latency, the endpoints without latency yet have the average weight.
+-- It is multiplied by the weight of the pod of the endpoint.
+local function weight(self, endpoint, peer, average)
+  local relative_weight = util.relative_endpoint_weight(endpoint)
+
+  local latency = self.latency[peer]
+  if not average or not latency or latency <= 0 then
+    return relative_weight
+  end
+
+  return math_min(math_max(average / latency, MIN_WEIGHT), MAX_WEIGHT) * relative_weight
+end
+
+-- picks the endpoint with the least in-flight requests per weight, the ties
+-- are broken randomly
+function _M.balance(self)
+  local average = average_latency(self)
+
+  local best, best_score
+  local ties = 0
+  for _, endpoint in ipairs(self.peers) do
+    local peer = peer_key(endpoint)
+    local score = (in_flight.get(self, peer) + 1) / weight(self, endpoint, peer, average)
+
+    if not best_score or score < best_score then
+      best, best_score, ties = peer, score, 1
+    elseif score == best_score then
+      ties = ties + 1
+      if math_random(ties) == 1 then
+        best = peer
+      end
+    end
+  end
+
+  in_flight.acquire(self, best)
+  return best
+end
+
+function _M.after_balance(self)
+  in_flight.release(self)
+
+  local upstream = split.get_first_value(ngx.var.upstream_addr)
+  if util.is_blank(upstream) then
+    return
+  end
+
+  local response_time = tonumber(split.get_first_value(ngx.var.upstream_response_time)) or 0
+  local connect_time = tonumber(split.get_first_value(ngx.var.upstream_connect_time)) or 0
+  local rtt = connect_time + response_time
+
+  local now = ngx.now()
+  local latency = self.latency[upstream]
+  if latency then
+    local td = math_max(now - self.latency_touched_at[upstream], 0)
+    local w = math_exp(-td / DECAY_TIME)
+    latency = latency * w + rtt * (1.0 - w)
+  else
+    latency = rtt
+  end
+
+  self.latency[upstream] = latency
+  self.latency_touched_at[upstream] = now
+end
+
+function _M.sync(self, backend)
+  self.traffic_shaping_policy = backend.trafficShapingPolicy
+  self.alternative_backends = backend.alternativeBackends
+
+  if util.deep_compare(self.peers, backend.endpoints) then
+    return
+  end
+
+  ngx.log(ngx.INFO, string_format("[%s] peers have changed for backend %s", self.name, backend.name))
+
+  -- the latency of the remaining endpoints is kept
+  local peers = {}
+  for _, endpoint in ipairs(backend.endpoints) do
+    peers[peer_key(endpoint)] = true
+  end
+  for peer in pairs(self.latency) do
+    if not peers[peer] then
+      self.latency[peer] = nil
+      self.latency_touched_at[peer] = nil
+    end
+  end
+
+  self.peers = backend.endpoints
+end
+
+function _M.new(self, backend)
+  local o = {
+    peers = backend.endpoints,
+    in_flight = {},
+    latency = {},
+    latency_touched_at = {},
+    traffic_shaping_policy = backend.trafficShapingPolicy,
+    alternative_backends = backend.alternativeBackends,
+  }
+  setmetatable(o, self)
+  self.__index = self
+  return o
+end
+
+return _M
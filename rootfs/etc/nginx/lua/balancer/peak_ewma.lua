@@ -0,0 +1,140 @@
+-- Peak EWMA as implemented by Finagle: the cost of an endpoint is its latency
+-- multiplied by its in-flight requests. The latency follows the peaks
+-- immediately and decays slowly, so a slowing endpoint is avoided at once.
+-- https://github.com/twitter/finagle/blob/1bc837c4feafc0096e43c0e98516a8e1c50c4421
+--   /finagle-core/src/main/scala/com/twitter/finagle/loadbalancer/PeakEwma.scala
+local in_flight = require("balancer.in_flight")
+local util = require("util")
+local split = require("util.split")
+
+local math_exp = math.exp
+local math_max = math.max
+local math_random = math.random
+local string_format = string.format
+
+-- measured in seconds, decay of the latency of the endpoints
+local DECAY_TIME = 10
+-- cost of the endpoints with in-flight requests but without latency yet,
+-- to send the first requests of a new endpoint one at a time
+local PENALTY = 1e14
+
+local _M = { name = "peak_ewma" }
+
+local function peer_key(endpoint)
+  return endpoint.address .. ":" .. endpoint.port
+end
+
+local function decayed_latency(self, peer, now)
+  local latency = self.latency[peer]
+  if not latency then
+    return 0
+  end
+
+  local td = math_max(now - self.latency_touched_at[peer], 0)
+  return latency * math_exp(-td / DECAY_TIME)
+end
+
+-- the cost is divided by the weight of the pod of the endpoint
+local function cost(self, endpoint, peer)
+  local latency = decayed_latency(self, peer, ngx.now())
+  local pending = in_flight.get(self, peer)
+
+  if latency == 0 and pending > 0 then
+    return PENALTY + pending
+  end
+
+  return latency * (pending + 1) / util.relative_endpoint_weight(endpoint)
+end
+
+-- picks the endpoint with the lowest cost between two random endpoints
+function _M.balance(self)
+  local peers = self.peers
+  local peer, score = peer_key(peers[1]), -1
+
+  if #peers > 1 then
+    local first = math_random(#peers)
+    local second = math_random(#peers - 1)
+    if second >= first then
+      second = second + 1
+    end
+
+    local first_peer, second_peer = peer_key(peers[first]), peer_key(peers[second])
+    local first_cost = cost(self, peers[first], first_peer)
+    local second_cost = cost(self, peers[second], second_peer)
+    if second_cost < first_cost then
+      peer, score = second_peer, second_cost
+    else
+      peer, score = first_peer, first_cost
+    end
+  end
+
+  ngx.var.balancer_ewma_score = score
+
+  in_flight.acquire(self, peer)
+  return peer
+end
+
+function _M.after_balance(self)
+  in_flight.release(self)
+
+  local upstream = split.get_first_value(ngx.var.upstream_addr)
+  if util.is_blank(upstream) then
+    return
+  end
+
+  local response_time = tonumber(split.get_first_value(ngx.var.upstream_response_time)) or 0
+  local connect_time = tonumber(split.get_first_value(ngx.var.upstream_connect_time)) or 0
+  local rtt = connect_time + response_time
+
+  local now = ngx.now()
+  local latency = self.latency[upstream]
+  if not latency or rtt > decayed_latency(self, upstream, now) then
+    latency = rtt
+  else
+    local w = math_exp(-math_max(now - self.latency_touched_at[upstream], 0) / DECAY_TIME)
+    latency = latency * w + rtt * (1.0 - w)
+  end
+
+  self.latency[upstream] = latency
+  self.latency_touched_at[upstream] = now
+end
+
+function _M.sync(self, backend)
+  self.traffic_shaping_policy = backend.trafficShapingPolicy
+  self.alternative_backends = backend.alternativeBackends
+
+  if util.deep_compare(self.peers, backend.endpoints) then
+    return
+  end
+
+  ngx.log(ngx.INFO, string_format("[%s] peers have changed for backend %s", self.name, backend.name))
+
+  local peers = {}
+  for _, endpoint in ipairs(backend.endpoints) do
+    peers[peer_key(endpoint)] = true
+  end
+  for peer in pairs(self.latency) do
+    if not peers[peer] then
+      self.latency[peer] = nil
+      self.latency_touched_at[peer] = nil
+    end
+  end
+
+  self.peers = backend.endpoints
+end
+
+function _M.new(self, backend)
+  local o = {
+    peers = backend.endpoints,
+    in_flight = {},
+    latency = {},
+    latency_touched_at = {},
+    traffic_shaping_policy = backend.trafficShapingPolicy,
+    alternative_backends = backend.alternativeBackends,
+  }
+  setmetatable(o, self)
+  self.__index = self
+  return o
+end
+
+return _M
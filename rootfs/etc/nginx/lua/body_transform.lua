@@ -0,0 +1,221 @@
+local cjson = require("cjson.safe").new()
+local zlib = require("util.zlib")
+
+local io_open = io.open
+local string_find = string.find
+local string_lower = string.lower
+local table_concat = table.concat
+
+-- keep the empty arrays of the responses
+cjson.decode_array_with_array_mt(true)
+
+local _M = {}
+
+_M.REDACTED = "[REDACTED]"
+
+-- returns the request body, or true as second value when it is larger than max_size
+local function read_request_body(max_size)
+  ngx.req.read_body()
+
+  local body = ngx.req.get_body_data()
+  if not body then
+    -- the body was larger than client_body_buffer_size
+    local filename = ngx.req.get_body_file()
+    if not filename then
+      return nil
+    end
+
+    local f, err = io_open(filename, "rb")
+    if not f then
+      ngx.log(ngx.ERR, "could not open the request body file: ", tostring(err))
+      return nil
+    end
+
+    body = f:read(max_size + 1)
+    f:close()
+  end
+
+  if body and #body > max_size then
+    return nil, true
+  end
+
+  return body
+end
+
+local function gzip_request_body(config)
+  local content_encoding = ngx.var.http_content_encoding
+  if content_encoding and string_lower(content_encoding) ~= "identity" then
+    return
+  end
+
+  local body, too_large = read_request_body(config.max_size)
+  if too_large then
+    ngx.log(ngx.INFO, "request body larger than ", config.max_size, " bytes, sending it uncompressed")
+    return
+  end
+  if not body or body == "" then
+    return
+  end
+
+  local compressed, err = zlib.gzip(body)
+  if not compressed then
+    ngx.log(ngx.ERR, "could not compress the request body: ", err)
+    return
+  end
+
+  ngx.req.set_body_data(compressed)
+  ngx.req.set_header("Content-Encoding", "gzip")
+end
+
+local function gunzip_request_body(config)
+  local content_encoding = ngx.var.http_content_encoding
+  if not content_encoding or string_lower(content_encoding) ~= "gzip" then
+    return
+  end
+
+  local body, too_large = read_request_body(config.max_size)
+  if too_large then
+    return ngx.exit(ngx.HTTP_REQUEST_ENTITY_TOO_LARGE)
+  end
+  if not body then
+    return
+  end
+
+  local decompressed, err = zlib.gunzip(body, config.max_size)
+  if not decompressed then
+    if err == zlib.ERR_TOO_LARGE then
+      return ngx.exit(ngx.HTTP_REQUEST_ENTITY_TOO_LARGE)
+    end
+
+    ngx.log(ngx.INFO, "could not decompress the request body: ", err)
+    return ngx.exit(ngx.HTTP_BAD_REQUEST)
+  end
+
+  ngx.req.set_body_data(decompressed)
+  ngx.req.clear_header("Content-Encoding")
+end
+
+-- rewrites the request body and, when fields must be redacted,
+-- asks the upstream server for an uncompressed response
+function _M.rewrite(config)
+  if config.redact_json_fields and #config.redact_json_fields > 0 then
+    ngx.req.clear_header("Accept-Encoding")
+  end
+
+  if config.request_encoding == "gzip" then
+    gzip_request_body(config)
+  elseif config.request_encoding == "identity" then
+    gunzip_request_body(config)
+  end
+end
+
+local function redact(value, fields, path)
+  if type(value) ~= "table" then
+    return
+  end
+
+  for key, v in pairs(value) do
+    local key_path = path
+    if type(key) == "string" then
+      key_path = path and (path .. "." .. key) or key
+    end
+
+    if type(key) == "string" and (fields[key] or fields[key_path]) then
+      value[key] = _M.REDACTED
+    else
+      redact(v, fields, key_path)
+    end
+  end
+end
+
+-- replaces the values of the fields in a JSON document. A name matches the
+-- fields at any depth, a dotted name the path of the field from the root.
+function _M.redact_json(body, field_names)
+  local document, err = cjson.decode(body)
+  if type(document) ~= "table" then
+    if err then
+      ngx.log(ngx.INFO, "response body is not a valid JSON document: ", err)
+    end
+    return body
+  end
+
+  local fields = {}
+  for _, name in ipairs(field_names) do
+    fields[name] = true
+  end
+
+  redact(document, fields, nil)
+
+  local encoded
+  encoded, err = cjson.encode(document)
+  if not encoded then
+    ngx.log(ngx.ERR, "could not encode the redacted response body: ", err)
+    return ""
+  end
+
+  return encoded
+end
+
+function _M.header_filter(config)
+  if not config.redact_json_fields or #config.redact_json_fields == 0 then
+    return
+  end
+
+  local content_type = ngx.header.content_type
+  if not content_type or not string_find(string_lower(content_type), "json", 1, true) then
+    return
+  end
+
+  local ctx = { chunks = {}, size = 0 }
+
+  local content_encoding = ngx.header.content_encoding
+  if content_encoding and string_lower(content_encoding) ~= "identity" then
+    -- the fields cannot be redacted, the body must not be sent
+    ngx.log(ngx.ERR, "cannot redact a response encoded with ", content_encoding)
+    ngx.status = ngx.HTTP_BAD_GATEWAY
+    ngx.header.content_encoding = nil
+    ctx.dropped = true
+  end
+
+  ngx.header.content_length = nil
+  ngx.ctx.body_transform = ctx
+end
+
+function _M.body_filter(config)
+  local ctx = ngx.ctx.body_transform
+  if not ctx then
+    return
+  end
+
+  if ctx.dropped then
+    ngx.arg[1] = nil
+    return
+  end
+
+  local chunk, eof = ngx.arg[1], ngx.arg[2]
+
+  if chunk and chunk ~= "" then
+    ctx.size = ctx.size + #chunk
+    if ctx.size > config.max_size then
+      -- the status is already sent, the response is truncated
+      -- to avoid sending the fields that must be redacted
+      ngx.log(ngx.ERR, "response body larger than ", config.max_size, " bytes, it cannot be redacted")
+      ctx.chunks = nil
+      ctx.dropped = true
+      ngx.arg[1] = nil
+      ngx.arg[2] = true
+      return
+    end
+
+    ctx.chunks[#ctx.chunks + 1] = chunk
+  end
+
+  if not eof then
+    ngx.arg[1] = nil
+    return
+  end
+
+  ngx.arg[1] = _M.redact_json(table_concat(ctx.chunks), config.redact_json_fields)
+end
+
+return _M
@@ -0,0 +1,330 @@
+local cjson = require("cjson.safe")
+local configuration = require("configuration")
+local cidr = require("util.cidr")
+local resty_sha256 = require("resty.sha256")
+
+local math_floor = math.floor
+local string_byte = string.byte
+local string_format = string.format
+local string_match = string.match
+local table_concat = table.concat
+local bit_band = bit.band
+local bit_bor = bit.bor
+local bit_bxor = bit.bxor
+local bit_lshift = bit.lshift
+
+local _M = {}
+
+-- name of the cookie carrying the clearance of the clients which solved the challenge
+_M.COOKIE = "ingress_bot_clearance"
+-- measured in seconds, window of the request rate trigger
+local RATE_WINDOW = 60
+
+-- the challenge page finds the nonce of the proof of work, with a SHA-256
+-- implementation because crypto.subtle is not available over plain HTTP
+local PAGE_HEAD = [[<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="robots" content="noindex"><title>Checking your browser</title></head>
+<body>
+<p>Checking your browser before accessing the site.</p>
+<noscript><p>JavaScript is required to access this site.</p></noscript>
+<script>
+(function () {
+  var challenge = ]]
+
+local PAGE_TAIL = [[;
+
+  var rotr = function (v, n) { return (v >>> n) | (v << (32 - n)); };
+  var maxWord = Math.pow(2, 32), initial = [], k = [], composite = {}, n = 0, i;
+  for (var c = 2; n < 64; c++) {
+    if (!composite[c]) {
+      for (i = c * c; i < 313; i += c) composite[i] = true;
+      initial[n] = (Math.pow(c, 1 / 2) * maxWord) | 0;
+      k[n++] = (Math.pow(c, 1 / 3) * maxWord) | 0;
+    }
+  }
+  initial = initial.slice(0, 8);
+
+  // returns the SHA-256 hash of an ASCII string as 8 words
+  function sha256(ascii) {
+    var hash = initial.slice(0), bitLength = ascii.length * 8, words = [], i, j;
+    ascii += "\x80";
+    while ((ascii.length & 63) !== 56) ascii += "\x00";
+    for (i = 0; i < ascii.length; i++) words[i >> 2] |= ascii.charCodeAt(i) << ((3 - (i & 3)) * 8);
+    words.push((bitLength / maxWord) | 0, bitLength);
+
+    for (j = 0; j < words.length; j += 16) {
+      var w = words.slice(j, j + 16), h = hash.slice(0);
+      for (i = 0; i < 64; i++) {
+        if (i >= 16) {
+          var w15 = w[i - 15], w2 = w[i - 2];
+          w[i] = (w[i - 16] + (rotr(w15, 7) ^ rotr(w15, 18) ^ (w15 >>> 3)) + w[i - 7] +
+            (rotr(w2, 17) ^ rotr(w2, 19) ^ (w2 >>> 10))) | 0;
+        }
+        var a = h[0], e = h[4];
+        var t1 = h[7] + (rotr(e, 6) ^ rotr(e, 11) ^ rotr(e, 25)) + ((e & h[5]) ^ (~e & h[6])) + k[i] + w[i];
+        var t2 = (rotr(a, 2) ^ rotr(a, 13) ^ rotr(a, 22)) + ((a & h[1]) ^ (a & h[2]) ^ (h[1] & h[2]));
+        h = [(t1 + t2) | 0].concat(h);
+        h[4] = (h[4] + t1) | 0;
+        h.pop();
+      }
+      for (i = 0; i < 8; i++) hash[i] = (hash[i] + h[i]) | 0;
+    }
+    return hash;
+  }
+
+  function done(value) {
+    document.cookie = challenge.cookie + "=" + value + "; path=/; max-age=" + challenge.max_age +
+      "; SameSite=Lax" + (location.protocol === "https:" ? "; Secure" : "");
+    location.reload();
+  }
+
+  if (!challenge.difficulty) {
+    done(challenge.value);
+    return;
+  }
+
+  // the search is split to keep the page responsive
+  var nonce = 0;
+  (function search() {
+    for (var end = nonce + 20000; nonce < end; nonce++) {
+      if (Math.clz32(sha256(challenge.value + "." + nonce)[0]) >= challenge.difficulty) {
+        done(challenge.value + "." + nonce);
+        return;
+      }
+    }
+    setTimeout(search, 0);
+  })();
+})();
+</script>
+</body>
+</html>
+]]
+
+-- the configuration is decoded only when it changes
+local raw_config, config
+
+local function get_config()
+  local raw = configuration.get_bot_challenge_data()
+  if raw ~= raw_config then
+    raw_config = raw
+    config = raw and cjson.decode(raw) or nil
+  end
+
+  if not config or not config.key or config.key == "" then
+    return nil
+  end
+
+  return config
+end
+
+-- compares the strings in a constant time
+local function secure_compare(a, b)
+  if #a ~= #b then
+    return false
+  end
+
+  local result = 0
+  for i = 1, #a do
+    result = bit_bor(result, bit_bxor(string_byte(a, i), string_byte(b, i)))
+  end
+
+  return result == 0
+end
+
+local function base64url(value)
+  return (ngx.encode_base64(value):gsub("%+", "-"):gsub("/", "_"):gsub("=", ""))
+end
+
+-- the clearance is bound to the address and user agent of the client,
+-- and to the mode and difficulty of the challenge
+local function sign(key, challenge, expires)
+  local data = table_concat({
+    challenge.mode,
+    tostring(challenge.difficulty or 0),
+    tostring(expires),
+    ngx.var.remote_addr,
+    ngx.var.http_user_agent or "",
+  }, ":")
+  return base64url(ngx.hmac_sha1(key, data))
+end
+
+local function leading_zero_bits(hash)
+  local bits = 0
+  for i = 1, #hash do
+    local byte = string_byte(hash, i)
+    if byte ~= 0 then
+      while bit_band(byte, 0x80) == 0 do
+        bits = bits + 1
+        byte = bit_lshift(byte, 1)
+      end
+      return bits
+    end
+    bits = bits + 8
+  end
+  return bits
+end
+
+local function sha256(value)
+  local sha = resty_sha256:new()
+  sha:update(value)
+  return sha:final()
+end
+
+-- checks a clearance with the format <expiration timestamp>.<signature>,
+-- followed by .<nonce> for the proofs of work
+local function has_clearance(cfg, challenge)
+  local cookie = ngx.var["cookie_" .. _M.COOKIE]
+  if not cookie then
+    return false
+  end
+
+  local expires, signature, nonce = string_match(cookie, "^(%d+)%.([%w_-]+)%.?(%d*)$")
+  if not expires then
+    return false
+  end
+
+  local now = ngx.time()
+  expires = tonumber(expires)
+  if expires < now or expires > now + cfg.ttl then
+    return false
+  end
+
+  if not secure_compare(signature, sign(cfg.key, challenge, expires)) then
+    return false
+  end
+
+  if challenge.mode ~= "pow" then
+    return nonce == ""
+  end
+
+  return nonce ~= "" and leading_zero_bits(sha256(cookie)) >= challenge.difficulty
+end
+
+local function matches_any(patterns, value)
+  if not value then
+    return false
+  end
+
+  for _, pattern in ipairs(patterns) do
+    if ngx.re.find(value, pattern, "ijo") then
+      return true
+    end
+  end
+
+  return false
+end
+
+-- the parsed networks, by CIDR
+local networks = {}
+
+local function is_exempt(challenge)
+  local address = ngx.var.remote_addr
+  for _, network in ipairs(challenge.exempt_cidrs) do
+    if networks[network] == nil then
+      networks[network] = cidr.parse(network) or false
+    end
+    if networks[network] and cidr.contains(networks[network], address) then
+      return true
+    end
+  end
+
+  return matches_any(challenge.exempt_user_agents, ngx.var.http_user_agent)
+end
+
+-- returns true when the client made more than rate requests in the
+-- current window of the location
+local function exceeds_rate(rate)
+  local counters = ngx.shared.bot_challenge
+  if not counters then
+    return false
+  end
+
+  local window = math_floor(ngx.now() / RATE_WINDOW)
+  local key = string_format("%s%s:%s:%d", ngx.var.host, ngx.var.location_path, ngx.var.remote_addr, window)
+
+  local count, err = counters:incr(key, 1, 0, RATE_WINDOW)
+  if not count then
+    ngx.log(ngx.WARN, string_format("could not count the requests of %s: %s", key, tostring(err)))
+    return false
+  end
+
+  return count > rate
+end
+
+-- the clients are challenged when they match any of the triggers,
+-- or always when the location has no trigger
+local function is_suspicious(challenge)
+  local rate_exceeded = challenge.rate > 0 and exceeds_rate(challenge.rate)
+  if rate_exceeded then
+    return true
+  end
+
+  if #challenge.asns > 0 then
+    local asn = ngx.var.geoip2_asn
+    for _, suspicious in ipairs(challenge.asns) do
+      if asn == suspicious then
+        return true
+      end
+    end
+  end
+
+  if matches_any(challenge.user_agents, ngx.var.http_user_agent) then
+    return true
+  end
+
+  return challenge.rate == 0 and #challenge.asns == 0 and #challenge.user_agents == 0
+end
+
+local function send_challenge(cfg, challenge)
+  ngx.status = ngx.HTTP_FORBIDDEN
+  ngx.header["Cache-Control"] = "no-store"
+
+  -- the challenge is only solved by browsers loading a page
+  local method = ngx.req.get_method()
+  if method ~= "GET" and method ~= "HEAD" then
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+  end
+
+  local expires = ngx.time() + cfg.ttl
+  local page_challenge = {
+    cookie = _M.COOKIE,
+    value = string_format("%d.%s", expires, sign(cfg.key, challenge, expires)),
+    difficulty = challenge.mode == "pow" and challenge.difficulty or 0,
+    max_age = cfg.ttl,
+  }
+
+  ngx.header.content_type = "text/html"
+  ngx.print(PAGE_HEAD, cjson.encode(page_challenge), PAGE_TAIL)
+  return ngx.exit(ngx.HTTP_OK)
+end
+
+-- challenges the suspicious clients without a valid clearance.
+-- It must be called in the access phase of the location.
+function _M.access(challenge)
+  local cfg = get_config()
+  if not cfg then
+    ngx.log(ngx.WARN, "the key of the bot challenges is not configured, the clients are not challenged")
+    return
+  end
+
+  if has_clearance(cfg, challenge) or is_exempt(challenge) then
+    return
+  end
+
+  if not is_suspicious(challenge) then
+    return
+  end
+
+  return send_challenge(cfg, challenge)
+end
+
+if _TEST then
+  _M.sign = sign
+  _M.leading_zero_bits = leading_zero_bits
+  _M.reset = function()
+    raw_config, config = nil, nil
+  end
+end
+
+return _M
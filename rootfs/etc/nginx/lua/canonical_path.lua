@@ -0,0 +1,81 @@
+local ngx_re_find = ngx.re.find
+local string_find = string.find
+local string_format = string.format
+local string_gsub = string.gsub
+local string_match = string.match
+local string_sub = string.sub
+
+local _M = {}
+
+-- the methods redirected with a 301, the others keep their body with a 308
+local SAFE_METHODS = {
+  GET = true,
+  HEAD = true,
+}
+
+local function redirect(path)
+  local code = 308
+  if SAFE_METHODS[ngx.req.get_method()] then
+    code = 301
+  end
+
+  local args = ngx.var.args
+  if args then
+    path = string_format("%s?%s", path, args)
+  end
+
+  return ngx.redirect(path, code)
+end
+
+-- returns the path with or without trailing slash the request is redirected to
+local function trailing_slash_path(path, trailing_slash)
+  local has_trailing_slash = string_sub(path, -1) == "/"
+
+  if trailing_slash == "add" and not has_trailing_slash then
+    return path .. "/"
+  end
+
+  if trailing_slash == "remove" and has_trailing_slash and path ~= "/" then
+    local trimmed = string_gsub(path, "/+$", "")
+    if trimmed ~= "" then
+      return trimmed
+    end
+  end
+
+  return nil
+end
+
+-- rewrite is called in the locations of the Ingresses canonicalizing the
+-- paths, after the HTTPS redirect of lua_ingress
+function _M.rewrite(location_config)
+  local path = string_match(ngx.var.request_uri, "^[^?]*")
+
+  local canonical = trailing_slash_path(path, location_config.trailing_slash)
+  if canonical then
+    return redirect(canonical)
+  end
+
+  local has_slashes = string_find(path, "//", 1, true)
+
+  if location_config.merge_slashes == "off" and has_slashes then
+    -- NGINX merged the slashes to choose the location, choose it again with
+    -- the decoded path of the request unless its dot segments must be resolved
+    local unmerged = ngx.unescape_uri(path)
+    if not ngx.ctx.canonical_path_unmerged and
+        not ngx_re_find(unmerged, [[(^|/)\.\.?(/|$)]], "jo") then
+      ngx.ctx.canonical_path_unmerged = true
+      return ngx.req.set_uri(unmerged, true)
+    end
+
+    has_slashes = false
+  end
+
+  -- the path proxied is the one used to choose the location instead of the
+  -- path of the request
+  if (location_config.merge_slashes == "on" and has_slashes) or
+      (location_config.normalize_percent_encoding and string_find(path, "%", 1, true)) then
+    ngx.req.set_uri(ngx.var.uri)
+  end
+end
+
+return _M
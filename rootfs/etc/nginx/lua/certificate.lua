@@ -1,4 +1,5 @@
 local ssl = require("ngx.ssl")
+local ocsp = require("ngx.ocsp")
 local configuration = require("configuration")
 local re_sub = ngx.re.sub
 
@@ -28,12 +29,14 @@ local function set_pem_cert_key(pem_cert_key)
   end
 end
 
+-- returns the certificate and key of the hostname and the hostname they
+-- are stored with
 local function get_pem_cert_key(raw_hostname)
   local hostname = re_sub(raw_hostname, "\\.$", "", "jo")
 
   local pem_cert_key = configuration.get_pem_cert_key(hostname)
   if pem_cert_key then
-    return pem_cert_key
+    return pem_cert_key, hostname
   end
 
   local wildcard_hosatname, _, err = re_sub(hostname, "^[^\\.]+\\.", "*.", "jo")
@@ -45,7 +48,20 @@ local function get_pem_cert_key(raw_hostname)
   if wildcard_hosatname then
     pem_cert_key = configuration.get_pem_cert_key(wildcard_hosatname)
   end
-  return pem_cert_key
+  return pem_cert_key, wildcard_hosatname
+end
+
+-- staples the OCSP response of the certificate, if any
+local function set_ocsp_response(hostname)
+  local ocsp_response = configuration.get_ocsp_response(hostname)
+  if not ocsp_response then
+    return
+  end
+
+  local ok, err = ocsp.set_ocsp_status_resp(ocsp_response)
+  if not ok then
+    ngx.log(ngx.WARN, "failed to staple the OCSP response of " .. hostname .. ": " .. tostring(err))
+  end
 end
 
 function _M.call()
@@ -59,9 +75,9 @@ function _M.call()
     hostname = DEFAULT_CERT_HOSTNAME
   end
 
-  local pem_cert_key = get_pem_cert_key(hostname)
+  local pem_cert_key, cert_hostname = get_pem_cert_key(hostname)
   if not pem_cert_key then
-    pem_cert_key = get_pem_cert_key(DEFAULT_CERT_HOSTNAME)
+    pem_cert_key, cert_hostname = get_pem_cert_key(DEFAULT_CERT_HOSTNAME)
   end
   if not pem_cert_key then
     ngx.log(ngx.ERR, "certificate not found, falling back to fake certificate for hostname: " .. tostring(hostname))
@@ -79,6 +95,23 @@ function _M.call()
     ngx.log(ngx.ERR, set_pem_cert_key_err)
     return ngx.exit(ngx.ERROR)
   end
+
+  -- OpenSSL keeps a certificate of each key type and serves the ECDSA one
+  -- to the clients supporting it
+  local ecdsa_pem_cert_key = configuration.get_ecdsa_pem_cert_key(cert_hostname)
+  if ecdsa_pem_cert_key then
+    set_pem_cert_key_err = set_pem_cert_key(ecdsa_pem_cert_key)
+    if set_pem_cert_key_err then
+      ngx.log(ngx.ERR, "ECDSA certificate: " .. set_pem_cert_key_err)
+      return ngx.exit(ngx.ERROR)
+    end
+
+    -- the response is stapled whatever the certificate served, it would not
+    -- match the ECDSA certificate
+    return
+  end
+
+  set_ocsp_response(cert_hostname)
 end
 
 return _M
@@ -1,8 +1,16 @@
 local cjson = require("cjson.safe")
+local shared_state = require("shared_state")
+local tls_fingerprint = require("tls_fingerprint")
 
 -- this is the Lua representation of Configuration struct in internal/ingress/types.go
 local configuration_data = ngx.shared.configuration_data
 local certificate_data = ngx.shared.certificate_data
+local canary_weights = ngx.shared.canary_weights
+
+-- shared map containing the canary weights set through the traffic
+-- management API of any replica
+local CANARY_WEIGHTS_MAP = "canary_weights"
+shared_state.register(CANARY_WEIGHTS_MAP)
 
 local _M = {
   nameservers = {}
@@ -12,10 +20,36 @@ function _M.get_backends_data()
   return configuration_data:get("backends")
 end
 
+-- returns the version of the backends, increased by every change, the
+-- workers synchronize their balancers when it differs from the version
+-- they synchronized
+function _M.get_backends_version()
+  return configuration_data:get("backends_version")
+end
+
+local function increase_backends_version()
+  local _, err = configuration_data:incr("backends_version", 1, 0)
+  if err then
+    ngx.log(ngx.ERR, "dynamic-configuration: error increasing the version of the backends: " .. tostring(err))
+  end
+end
+
 function _M.get_general_data()
   return configuration_data:get("general")
 end
 
+-- returns the key and the maximum lifetime of the tokens enabling the debug
+-- headers. There is no GET request to avoid exposing the key.
+function _M.get_debug_token_data()
+  return configuration_data:get("debug_token")
+end
+
+-- returns the key signing the clearances of the bot challenges and their
+-- lifetime. There is no GET request to avoid exposing the key.
+function _M.get_bot_challenge_data()
+  return configuration_data:get("bot_challenge")
+end
+
 local function fetch_request_body()
   ngx.req.read_body()
   local body = ngx.req.get_body_data()
@@ -40,6 +74,40 @@ function _M.get_pem_cert_key(hostname)
   return certificate_data:get(hostname)
 end
 
+-- the OCSP responses are stored next to the certificates, the certificate
+-- is still served without stapling when its response was removed
+local function ocsp_response_key(hostname)
+  return "ocsp:" .. hostname
+end
+
+function _M.get_ocsp_response(hostname)
+  return certificate_data:get(ocsp_response_key(hostname))
+end
+
+-- the ECDSA certificate of a hostname is stored next to its RSA certificate
+local function ecdsa_cert_key(hostname)
+  return "ecdsa:" .. hostname
+end
+
+function _M.get_ecdsa_pem_cert_key(hostname)
+  return certificate_data:get(ecdsa_cert_key(hostname))
+end
+
+-- returns the canary weight of the backend configured through the
+-- traffic management API, or nil to use the weight of the annotation.
+-- The weights of the shared state take precedence while it is available.
+function _M.get_canary_weight(backend_name)
+  if shared_state.available() then
+    return tonumber(shared_state.get(CANARY_WEIGHTS_MAP, backend_name))
+  end
+
+  if not canary_weights then
+    return nil
+  end
+
+  return canary_weights:get(backend_name)
+end
+
 local function handle_servers()
   if ngx.var.request_method ~= "POST" then
     ngx.status = ngx.HTTP_BAD_REQUEST
@@ -68,6 +136,28 @@ local function handle_servers()
         local msg = string.format("certificate_data dictionary is full, LRU entry has been removed to store %s", server.hostname)
         ngx.log(ngx.WARN, msg)
       end
+
+      local ocsp_response = server.sslCert.ocspResponse
+      if success and ocsp_response then
+        local ocsp_success, ocsp_err = certificate_data:set(ocsp_response_key(server.hostname),
+          ngx.decode_base64(ocsp_response))
+        if not ocsp_success then
+          ngx.log(ngx.WARN, "error setting OCSP response for ", server.hostname, ": ", tostring(ocsp_err))
+        end
+      elseif success then
+        certificate_data:delete(ocsp_response_key(server.hostname))
+      end
+
+      local ecdsa = server.sslCert.ecdsa
+      if success and ecdsa and ecdsa.pemCertKey then
+        local ecdsa_success, ecdsa_err = certificate_data:set(ecdsa_cert_key(server.hostname), ecdsa.pemCertKey)
+        if not ecdsa_success then
+          local err_msg = string.format("error setting ECDSA certificate for %s: %s\n", server.hostname, tostring(ecdsa_err))
+          table.insert(err_buf, err_msg)
+        end
+      elseif success then
+        certificate_data:delete(ecdsa_cert_key(server.hostname))
+      end
     else
       ngx.log(ngx.WARN, "hostname or pemCertKey are not present")
     end
@@ -82,6 +172,58 @@ local function handle_servers()
   ngx.status = ngx.HTTP_CREATED
 end
 
+-- replaces the endpoints of the posted backends, the controller posts the
+-- changes of the Endpoints of the Services this way instead of all the
+-- backends
+local function handle_endpoints()
+  if ngx.var.request_method ~= "POST" then
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    ngx.print("Only POST requests are allowed!")
+    return
+  end
+
+  local changes, err = cjson.decode(fetch_request_body())
+  if not changes then
+    ngx.log(ngx.ERR, "could not parse endpoints: ", err)
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    return
+  end
+
+  local backends_data = configuration_data:get("backends")
+  local backends = backends_data and cjson.decode(backends_data)
+  if not backends then
+    ngx.log(ngx.ERR, "dynamic-configuration: no backend to update the endpoints of")
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    return
+  end
+
+  local backends_by_name = {}
+  for _, backend in ipairs(backends) do
+    backends_by_name[backend.name] = backend
+  end
+
+  for _, change in ipairs(changes) do
+    local backend = backends_by_name[change.name]
+    if not backend then
+      ngx.log(ngx.ERR, "dynamic-configuration: unknown backend " .. tostring(change.name))
+      ngx.status = ngx.HTTP_NOT_FOUND
+      return
+    end
+
+    backend.endpoints = change.endpoints
+  end
+
+  local success, err = configuration_data:set("backends", cjson.encode(backends))
+  if not success then
+    ngx.log(ngx.ERR, "dynamic-configuration: error updating configuration: " .. tostring(err))
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    return
+  end
+
+  increase_backends_version()
+  ngx.status = ngx.HTTP_CREATED
+end
+
 local function handle_general()
   if ngx.var.request_method == "GET" then
     ngx.status = ngx.HTTP_OK
@@ -107,6 +249,77 @@ local function handle_general()
   ngx.status = ngx.HTTP_CREATED
 end
 
+local function handle_debug_token()
+  if ngx.var.request_method ~= "POST" then
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    ngx.print("Only POST requests are allowed!")
+    return
+  end
+
+  local config = fetch_request_body()
+
+  local success, err = configuration_data:safe_set("debug_token", config)
+  if not success then
+    ngx.status = ngx.HTTP_INTERNAL_SERVER_ERROR
+    ngx.log(ngx.ERR, "error setting debug token config: " .. tostring(err))
+    return
+  end
+
+  ngx.status = ngx.HTTP_CREATED
+end
+
+local function handle_bot_challenge()
+  if ngx.var.request_method ~= "POST" then
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    ngx.print("Only POST requests are allowed!")
+    return
+  end
+
+  local config = fetch_request_body()
+
+  local success, err = configuration_data:safe_set("bot_challenge", config)
+  if not success then
+    ngx.status = ngx.HTTP_INTERNAL_SERVER_ERROR
+    ngx.log(ngx.ERR, "error setting bot challenge config: " .. tostring(err))
+    return
+  end
+
+  ngx.status = ngx.HTTP_CREATED
+end
+
+local function handle_tls_fingerprints()
+  if ngx.var.request_method ~= "POST" then
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    ngx.print("Only POST requests are allowed!")
+    return
+  end
+
+  local fingerprints = cjson.decode(fetch_request_body() or "")
+  if type(fingerprints) ~= "table" then
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    ngx.print("invalid TLS fingerprints")
+    return
+  end
+
+  for _, fingerprint in ipairs(fingerprints) do
+    if type(fingerprint) ~= "table" or type(fingerprint.connection) ~= "string"
+        or type(fingerprint.ja3) ~= "string" or type(fingerprint.ja4) ~= "string" then
+      ngx.status = ngx.HTTP_BAD_REQUEST
+      ngx.print("invalid TLS fingerprint")
+      return
+    end
+
+    local success, err = tls_fingerprint.set(fingerprint.connection, fingerprint.ja3, fingerprint.ja4)
+    if not success then
+      ngx.status = ngx.HTTP_INTERNAL_SERVER_ERROR
+      ngx.log(ngx.ERR, "error setting TLS fingerprint: " .. tostring(err))
+      return
+    end
+  end
+
+  ngx.status = ngx.HTTP_CREATED
+end
+
 local function handle_certs()
   if ngx.var.request_method ~= "GET" then
     ngx.status = ngx.HTTP_BAD_REQUEST
@@ -133,6 +346,67 @@ local function handle_certs()
   end
 end
 
+local function handle_canary_weights()
+  if ngx.var.request_method == "GET" then
+    local weights = {}
+
+    local shared_weights = shared_state.get_all(CANARY_WEIGHTS_MAP)
+    if shared_weights then
+      for backend_name, weight in pairs(shared_weights) do
+        weights[backend_name] = tonumber(weight)
+      end
+    else
+      for _, backend_name in ipairs(canary_weights:get_keys(0)) do
+        weights[backend_name] = canary_weights:get(backend_name)
+      end
+    end
+
+    ngx.status = ngx.HTTP_OK
+    ngx.print(cjson.encode(weights))
+    return
+  end
+
+  local raw_canary_weight = fetch_request_body()
+
+  local canary_weight, err = cjson.decode(raw_canary_weight)
+  if not canary_weight then
+    ngx.log(ngx.ERR, "could not parse canary weight: ", err)
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    return
+  end
+
+  if type(canary_weight.backend) ~= "string" or canary_weight.backend == "" then
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    ngx.print("backend must be specified.")
+    return
+  end
+
+  -- a null weight restores the weight defined in the canary annotations
+  if canary_weight.weight == nil or canary_weight.weight == cjson.null then
+    canary_weights:delete(canary_weight.backend)
+    shared_state.delete(CANARY_WEIGHTS_MAP, canary_weight.backend)
+    ngx.status = ngx.HTTP_CREATED
+    return
+  end
+
+  local weight = tonumber(canary_weight.weight)
+  if not weight or weight < 0 or weight > 100 then
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    ngx.print("weight must be a number between 0 and 100.")
+    return
+  end
+
+  local success, err = canary_weights:safe_set(canary_weight.backend, weight)
+  if not success then
+    ngx.log(ngx.ERR, "error setting canary weight for " .. canary_weight.backend .. ": " .. tostring(err))
+    ngx.status = ngx.HTTP_INTERNAL_SERVER_ERROR
+    return
+  end
+
+  shared_state.set(CANARY_WEIGHTS_MAP, canary_weight.backend, weight)
+  ngx.status = ngx.HTTP_CREATED
+end
+
 function _M.call()
   if ngx.var.request_method ~= "POST" and ngx.var.request_method ~= "GET" then
     ngx.status = ngx.HTTP_BAD_REQUEST
@@ -150,11 +424,36 @@ function _M.call()
     return
   end
 
+  if ngx.var.request_uri == "/configuration/endpoints" then
+    handle_endpoints()
+    return
+  end
+
+  if ngx.var.request_uri == "/configuration/debug-token" then
+    handle_debug_token()
+    return
+  end
+
+  if ngx.var.request_uri == "/configuration/bot-challenge" then
+    handle_bot_challenge()
+    return
+  end
+
+  if ngx.var.request_uri == "/configuration/tls-fingerprints" then
+    handle_tls_fingerprints()
+    return
+  end
+
   if ngx.var.uri == "/configuration/certs" then
     handle_certs()
     return
   end
 
+  if ngx.var.request_uri == "/configuration/canary-weights" then
+    handle_canary_weights()
+    return
+  end
+
   if ngx.var.request_uri ~= "/configuration/backends" then
     ngx.status = ngx.HTTP_NOT_FOUND
     ngx.print("Not found!")
@@ -181,6 +480,7 @@ function _M.call()
     return
   end
 
+  increase_backends_version()
   ngx.status = ngx.HTTP_CREATED
 end
 
@@ -0,0 +1,68 @@
+local string_format = string.format
+local string_gsub = string.gsub
+
+-- the internal location of each server proxying the requests to Coraza
+local LOCATION = "/_coraza-waf"
+
+local _M = {}
+
+-- replaces the NGINX variables of the transaction ID, like $request_id
+local function expand(value)
+  return (string_gsub(value, "%$([%w_]+)", function(name)
+    return ngx.var[name] or ""
+  end))
+end
+
+-- sends the request to the Coraza proxy and rejects it with the status
+-- returned when a rule was matched. The proxy answers 2xx to let it through.
+function _M.access(config)
+  ngx.req.read_body()
+
+  local method = ngx.req.get_method()
+  local res = ngx.location.capture(LOCATION, {
+    -- the methods without a constant are still inspected by Coraza,
+    -- which reads the original one in X-Original-Method
+    method = ngx["HTTP_" .. method] or ngx.HTTP_POST,
+    always_forward_body = true,
+    ctx = {
+      coraza = {
+        method = method,
+        uri = ngx.var.request_uri,
+        remote_addr = ngx.var.remote_addr,
+        owasp_core_rules = config.owasp_core_rules,
+        transaction_id = expand(config.transaction_id),
+      },
+    },
+  })
+
+  if res.status >= 200 and res.status < 300 and not res.truncated then
+    return
+  end
+
+  if res.status >= 400 and res.status < 500 then
+    return ngx.exit(res.status)
+  end
+
+  ngx.log(ngx.ERR, string_format("could not inspect the request with Coraza, status %d", res.status))
+  return ngx.exit(ngx.HTTP_INTERNAL_SERVER_ERROR)
+end
+
+-- sets the headers of the original request read by the Coraza proxy.
+-- It must be called in the rewrite phase of the internal location.
+function _M.rewrite()
+  local request = ngx.ctx.coraza
+  if not request then
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+  end
+
+  ngx.req.set_header("X-Original-Method", request.method)
+  ngx.req.set_header("X-Original-URI", request.uri)
+  ngx.req.set_header("X-Forwarded-For", request.remote_addr)
+  ngx.req.set_header("X-Coraza-OWASP-CRS", request.owasp_core_rules and "on" or "off")
+
+  if request.transaction_id ~= "" then
+    ngx.req.set_header("X-Coraza-Transaction-ID", request.transaction_id)
+  end
+end
+
+return _M
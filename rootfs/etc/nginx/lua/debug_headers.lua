@@ -0,0 +1,121 @@
+local cjson = require("cjson.safe")
+local configuration = require("configuration")
+
+local string_byte = string.byte
+local string_format = string.format
+local string_match = string.match
+local bit_bor = bit.bor
+local bit_bxor = bit.bxor
+
+local _M = {}
+
+-- name of the request header carrying the token
+local TOKEN_HEADER = "X-Debug-Token"
+
+-- the configuration is decoded only when it changes
+local raw_config, config
+
+local function get_config()
+  local raw = configuration.get_debug_token_data()
+  if raw ~= raw_config then
+    raw_config = raw
+    config = raw and cjson.decode(raw) or nil
+  end
+
+  if not config or not config.key or config.key == "" then
+    return nil
+  end
+
+  return config
+end
+
+-- compares the strings in a constant time
+local function secure_compare(a, b)
+  if #a ~= #b then
+    return false
+  end
+
+  local result = 0
+  for i = 1, #a do
+    result = bit_bor(result, bit_bxor(string_byte(a, i), string_byte(b, i)))
+  end
+
+  return result == 0
+end
+
+local function base64url(value)
+  return (ngx.encode_base64(value):gsub("%+", "-"):gsub("/", "_"):gsub("=", ""))
+end
+
+-- returns the signature of a token valid until expires for the host
+function _M.sign(key, host, expires)
+  return base64url(ngx.hmac_sha1(key, string_format("%s:%s", host, expires)))
+end
+
+-- checks a token with the format <expiration timestamp>.<signature>
+function _M.is_valid(token, host)
+  local cfg = get_config()
+  if not cfg then
+    return false
+  end
+
+  local expires, signature = string_match(token, "^(%d+)%.([%w_-]+)$")
+  if not expires then
+    return false
+  end
+
+  local now = ngx.time()
+  expires = tonumber(expires)
+  if expires < now or expires > now + (cfg.maxTTL or 0) then
+    return false
+  end
+
+  return secure_compare(signature, _M.sign(cfg.key, host, expires))
+end
+
+-- must be called in the rewrite phase, the token is never sent to the backend
+function _M.rewrite()
+  local token = ngx.req.get_headers()[TOKEN_HEADER]
+  if not token then
+    return
+  end
+
+  ngx.req.clear_header(TOKEN_HEADER)
+
+  if type(token) == "table" then
+    token = token[1]
+  end
+
+  if _M.is_valid(token, ngx.var.host) then
+    ngx.ctx.debug_headers = true
+  end
+end
+
+local function set_header(name, value)
+  if value and value ~= "" then
+    ngx.header[name] = value
+  end
+end
+
+-- must be called in the header filter phase
+function _M.header_filter()
+  if not ngx.ctx.debug_headers then
+    return
+  end
+
+  set_header("X-Debug-Upstream", ngx.var.proxy_upstream_name)
+  set_header("X-Debug-Alternative-Upstream", ngx.var.proxy_alternative_upstream_name)
+  set_header("X-Debug-Upstream-Addr", ngx.var.upstream_addr)
+  set_header("X-Debug-Upstream-Time", ngx.var.upstream_response_time)
+  set_header("X-Debug-Pod", ngx.var.backend_pod)
+
+  local canary_variant = ngx.var.canary_variant
+  if canary_variant and canary_variant ~= "" then
+    set_header("X-Debug-Canary", string_format("%s; weight=%s", canary_variant, ngx.var.canary_weight))
+  end
+
+  set_header("X-Debug-Cache-Status", ngx.var.upstream_cache_status)
+  set_header("X-Debug-Auth-Time", ngx.var.auth_response_time)
+end
+
+return _M
@@ -0,0 +1,151 @@
+local resty_sha256 = require("resty.sha256")
+local resty_string = require("resty.string")
+
+local string_byte = string.byte
+local string_find = string.find
+local string_sub = string.sub
+local table_concat = table.concat
+local table_insert = table.insert
+
+local _M = {}
+
+-- DER encoding of the OID of the subject alternative name extension (2.5.29.17)
+local SAN_OID = "\6\3\85\29\17"
+-- DER tags of the elements read from the extension
+local BOOLEAN_TAG = 0x01
+local OCTET_STRING_TAG = 0x04
+local SEQUENCE_TAG = 0x30
+-- context specific tag of the uniformResourceIdentifier general name
+local URI_TAG = 0x86
+
+-- the elements are always added in the order of the Envoy header
+local ELEMENTS = { "Hash", "Cert", "Subject", "URI" }
+
+-- reads the DER element at pos, returns its tag and the position and the
+-- length of its content
+local function read_element(der, pos)
+  local tag, length = string_byte(der, pos, pos + 1)
+  if not tag or not length then
+    return nil
+  end
+
+  pos = pos + 2
+  if length >= 0x80 then
+    local bytes = length - 0x80
+    length = 0
+    for i = 0, bytes - 1 do
+      local byte = string_byte(der, pos + i)
+      if not byte then
+        return nil
+      end
+      length = length * 256 + byte
+    end
+    pos = pos + bytes
+  end
+
+  return tag, pos, length
+end
+
+local function pem_to_der(pem)
+  local body = pem:gsub("%-%-%-%-%-[^%-]+%-%-%-%-%-", ""):gsub("%s", "")
+  return ngx.decode_base64(body)
+end
+
+-- returns the URI subject alternative names of a DER encoded certificate
+local function uri_sans(der)
+  local uris = {}
+
+  local start = string_find(der, SAN_OID, 1, true)
+  if not start then
+    return uris
+  end
+
+  local tag, pos, length = read_element(der, start + #SAN_OID)
+  -- the critical flag is optional
+  if tag == BOOLEAN_TAG then
+    tag, pos, length = read_element(der, pos + length)
+  end
+  if tag ~= OCTET_STRING_TAG then
+    return uris
+  end
+
+  tag, pos, length = read_element(der, pos)
+  if tag ~= SEQUENCE_TAG then
+    return uris
+  end
+
+  local last = pos + length
+  while pos < last do
+    local content
+    tag, content, length = read_element(der, pos)
+    if not tag then
+      break
+    end
+
+    if tag == URI_TAG then
+      table_insert(uris, string_sub(der, content, content + length - 1))
+    end
+    pos = content + length
+  end
+
+  return uris
+end
+
+local function quote(value)
+  return '"' .. value:gsub("\\", "\\\\"):gsub('"', '\\"') .. '"'
+end
+
+-- returns the X-Forwarded-Client-Cert header of the verified client certificate
+-- with the given elements, an empty value removes the header sent by the client
+function _M.header(elements)
+  if ngx.var.ssl_client_verify ~= "SUCCESS" then
+    return ""
+  end
+
+  local pem = ngx.var.ssl_client_raw_cert
+  if not pem or pem == "" then
+    return ""
+  end
+
+  local enabled = {}
+  for _, element in ipairs(elements) do
+    enabled[element] = true
+  end
+
+  local der
+  if enabled.Hash or enabled.URI then
+    der = pem_to_der(pem)
+    if not der then
+      ngx.log(ngx.ERR, "could not decode the client certificate")
+      return ""
+    end
+  end
+
+  local parts = {}
+  for _, element in ipairs(ELEMENTS) do
+    if enabled[element] then
+      if element == "Hash" then
+        local sha256 = resty_sha256:new()
+        sha256:update(der)
+        table_insert(parts, "Hash=" .. resty_string.to_hex(sha256:final()))
+      elseif element == "Cert" then
+        table_insert(parts, "Cert=" .. quote(ngx.var.ssl_client_escaped_cert or ""))
+      elseif element == "Subject" then
+        table_insert(parts, "Subject=" .. quote(ngx.var.ssl_client_s_dn or ""))
+      elseif element == "URI" then
+        for _, uri in ipairs(uri_sans(der)) do
+          table_insert(parts, "URI=" .. uri)
+        end
+      end
+    end
+  end
+
+  return table_concat(parts, ";")
+end
+
+if _TEST then
+  _M.uri_sans = uri_sans
+  _M.pem_to_der = pem_to_der
+end
+
+return _M
@@ -3,6 +3,8 @@ local ngx_re_split = require("ngx.re").split
 local original_randomseed = math.randomseed
 local string_format = string.format
 local ngx_redirect = ngx.redirect
+local string_sub = string.sub
+local ipairs = ipairs
 
 local _M = {}
 
@@ -68,6 +70,43 @@ local function redirect_host()
   return host_port[1];
 end
 
+-- returns true when the path of the request starts with one of the paths
+-- excluded from the redirects to HTTPS
+local function is_redirect_excluded(location_config)
+  local paths = location_config.ssl_redirect_exclude_paths
+  if not paths then
+    return false
+  end
+
+  local uri = ngx.var.uri
+  for _, path in ipairs(paths) do
+    if string_sub(uri, 1, #path) == path then
+      return true
+    end
+  end
+
+  return false
+end
+
+local function redirect_uri(location_config)
+  -- the HTTPS port exposed to the clients, the listen port of the controller
+  -- differs when it is behind a NAT
+  local port = location_config.ssl_redirect_port
+  if port and port > 0 then
+    if port == 443 then
+      return string_format("https://%s%s", redirect_host(), ngx.var.request_uri)
+    end
+
+    return string_format("https://%s:%s%s", redirect_host(), port, ngx.var.request_uri)
+  end
+
+  if location_config.use_port_in_redirects then
+    return string_format("https://%s:%s%s", redirect_host(), config.listen_ports.https, ngx.var.request_uri)
+  end
+
+  return string_format("https://%s%s", redirect_host(), ngx.var.request_uri)
+end
+
 local function parse_x_forwarded_host()
   local hosts, err = ngx_re_split(ngx.var.http_x_forwarded_host, ",")
   if err then
@@ -119,14 +158,10 @@ function _M.rewrite(location_config)
     ngx.var.pass_port = 443
   end
 
-  if location_config.force_ssl_redirect and redirect_to_https() then
-    local uri = string_format("https://%s%s", redirect_host(), ngx.var.request_uri)
-
-    if location_config.use_port_in_redirects then
-      uri = string_format("https://%s:%s%s", redirect_host(), config.listen_ports.https, ngx.var.request_uri)
-    end
-
-    ngx_redirect(uri, config.http_redirect_code)
+  if location_config.force_ssl_redirect and redirect_to_https() and
+      not is_redirect_excluded(location_config) then
+    ngx_redirect(redirect_uri(location_config),
+      location_config.ssl_redirect_code or config.http_redirect_code)
   end
 end
 
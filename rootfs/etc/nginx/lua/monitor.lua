@@ -5,6 +5,7 @@ local new_tab = require "table.new"
 local clear_tab = require "table.clear"
 local clone_tab = require "table.clone"
 local nkeys = require "table.nkeys"
+local split = require("util.split")
 
 -- if an Nginx worker processes more than (MAX_BATCH_SIZE/FLUSH_INTERVAL) RPS then it will start dropping metrics
 local MAX_BATCH_SIZE = 10000
@@ -21,6 +22,58 @@ local function send(payload)
   assert(s:close())
 end
 
+-- returns the value of the last attempt of an upstream variable, the one of
+-- the upstream server that sent the response when the request was retried
+local function last_upstream_value(var)
+  local values = split.split_upstream_var(var) or {}
+  return tonumber(values[#values])
+end
+
+-- returns the size of the body of a request buffered to a temporary file
+local function request_body_temp_file_size()
+  if not ngx.var.request_body_file then
+    return nil
+  end
+
+  return tonumber(ngx.var.content_length) or tonumber(ngx.var.request_length) or 0
+end
+
+-- returns the part of the response exceeding the size of the proxy buffers
+-- of the location. It is the upper bound of the bytes written to a temporary
+-- file, only used when the client is slower than the upstream.
+local function proxy_buffers_excess()
+  local buffers_size = tonumber(ngx.var.proxy_buffers_size)
+  local length = last_upstream_value(ngx.var.upstream_response_length)
+  if not buffers_size or not length or length <= buffers_size then
+    return nil
+  end
+
+  return length - buffers_size
+end
+
+-- returns the connect times of the attempts opening a new connection with a
+-- TLS backend, including the TLS handshake. The connections reused from the
+-- keepalive cache report a connect time of zero.
+local function upstream_tls_connect_times()
+  if ngx.var.proxy_upstream_tls ~= "1" then
+    return nil
+  end
+
+  local times = {}
+  for _, value in ipairs(split.split_upstream_var(ngx.var.upstream_connect_time) or {}) do
+    local time = tonumber(value)
+    if time and time > 0 then
+      times[#times + 1] = time
+    end
+  end
+
+  if #times == 0 then
+    return nil
+  end
+
+  return times
+end
+
 local function metrics()
   return {
     host = ngx.var.host or "-",
@@ -28,6 +81,8 @@ local function metrics()
     ingress = ngx.var.ingress_name or "-",
     service = ngx.var.service_name or "-",
     path = ngx.var.location_path or "-",
+    canary = ngx.var.canary_variant or "",
+    canaryWeight = tonumber(ngx.var.canary_weight) or -1,
 
     method = ngx.var.request_method or "-",
     status = ngx.var.status or "-",
@@ -35,9 +90,16 @@ local function metrics()
     requestTime = tonumber(ngx.var.request_time) or -1,
     responseLength = tonumber(ngx.var.bytes_sent) or -1,
 
-    upstreamLatency = tonumber(ngx.var.upstream_connect_time) or -1,
-    upstreamResponseTime = tonumber(ngx.var.upstream_response_time) or -1,
-    upstreamResponseLength = tonumber(ngx.var.upstream_response_length) or -1,
+    upstreamLatency = last_upstream_value(ngx.var.upstream_connect_time) or -1,
+    upstreamResponseTime = last_upstream_value(ngx.var.upstream_response_time) or -1,
+    upstreamResponseLength = last_upstream_value(ngx.var.upstream_response_length) or -1,
+    -- omitted for plain text backends and reused connections to keep the payload small
+    upstreamTLSConnectTimes = upstream_tls_connect_times(),
+    -- omitted when the request and the response fit in the memory buffers
+    requestBodyTempFileSize = request_body_temp_file_size(),
+    proxyBuffersExcess = proxy_buffers_excess(),
+    -- omitted unless a request normalization rule applied to the request
+    requestNormalizations = ngx.ctx.request_normalizations,
     --upstreamStatus = ngx.var.upstream_status or "-",
   }
 end
@@ -0,0 +1,57 @@
+local math_floor = math.floor
+local string_match = string.match
+
+local _M = {}
+
+-- measured in milliseconds, per unit of the grpc-timeout header
+local GRPC_UNITS = { H = 3600000, M = 60000, S = 1000, m = 1, u = 0.001, n = 0.000001 }
+-- the value of grpc-timeout is limited to 8 digits
+local MAX_GRPC_TIMEOUT = 99999999
+
+local function now_ms()
+  return math_floor(ngx.now() * 1000)
+end
+
+-- returns the deadline of the client in milliseconds since 1970, read from
+-- the X-Request-Deadline or grpc-timeout headers of the request
+local function client_deadline()
+  local deadline = tonumber(ngx.var.http_x_request_deadline)
+
+  local value, unit = string_match(ngx.var.http_grpc_timeout or "", "^(%d+)([HMSmun])$")
+  if value then
+    local grpc_deadline = now_ms() + math_floor(tonumber(value) * GRPC_UNITS[unit])
+    if not deadline or grpc_deadline < deadline then
+      deadline = grpc_deadline
+    end
+  end
+
+  return deadline
+end
+
+-- returns the deadline of the request in milliseconds since 1970: the
+-- budget of the location from the start of the request, lowered by the
+-- deadline of the client
+function _M.deadline(budget)
+  local deadline = math_floor((ngx.req.start_time() + budget) * 1000)
+
+  local client = client_deadline()
+  if client and client < deadline then
+    deadline = client
+  end
+
+  return tostring(deadline)
+end
+
+-- returns the remaining time before the deadline as a grpc-timeout header
+function _M.grpc_timeout(deadline)
+  local remaining = (tonumber(deadline) or 0) - now_ms()
+  if remaining < 0 then
+    remaining = 0
+  elseif remaining > MAX_GRPC_TIMEOUT then
+    remaining = MAX_GRPC_TIMEOUT
+  end
+
+  return tostring(remaining) .. "m"
+end
+
+return _M
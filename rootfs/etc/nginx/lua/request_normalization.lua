@@ -0,0 +1,185 @@
+local ngx_re_find = ngx.re.find
+local string_match = string.match
+local table_concat = table.concat
+local pairs = pairs
+local type = type
+
+local _M = {}
+
+-- names of the rules, used as label of the metrics
+local HEADER_COUNT = "header_count"
+local HEADER_SIZE = "header_size"
+local AMBIGUOUS_FRAMING = "ambiguous_framing"
+local DUPLICATE_HEADERS = "duplicate_headers"
+local PATH_TRAVERSAL = "path_traversal"
+
+-- headers allowing a single value, a request defining them several times
+-- is rejected instead of normalized
+local SINGLE_VALUE_HEADERS = {
+  ["authorization"] = true,
+  ["content-length"] = true,
+  ["content-type"] = true,
+  ["host"] = true,
+  ["proxy-authorization"] = true,
+}
+
+-- the values of the headers are merged with a comma unless specified
+local SEPARATORS = {
+  ["cookie"] = "; ",
+}
+
+-- general configuration passed by the controller
+local config = {}
+
+local function record(rule, action)
+  local normalizations = ngx.ctx.request_normalizations
+  if not normalizations then
+    normalizations = {}
+    ngx.ctx.request_normalizations = normalizations
+  end
+
+  normalizations[rule] = action
+end
+
+local function reject(rule)
+  record(rule, "rejected")
+  ngx.log(ngx.INFO, "request rejected by the request normalization rule ", rule)
+  return ngx.exit(ngx.HTTP_BAD_REQUEST)
+end
+
+local function values(value)
+  if type(value) == "table" then
+    return value
+  end
+
+  return { value }
+end
+
+local function check_limits(headers)
+  local count = 0
+  for name, value in pairs(headers) do
+    for _, v in pairs(values(value)) do
+      count = count + 1
+      if config.max_header_size > 0 and #name + #v > config.max_header_size then
+        return HEADER_SIZE
+      end
+    end
+  end
+
+  if config.max_headers > 0 and count > config.max_headers then
+    return HEADER_COUNT
+  end
+
+  return nil
+end
+
+-- a request is ambiguous when the servers behind the controller could
+-- disagree on where its body ends
+local function is_ambiguous(headers)
+  local transfer_encoding = headers["transfer-encoding"]
+  local content_length = headers["content-length"]
+
+  if transfer_encoding and content_length then
+    return true
+  end
+
+  if type(content_length) == "table" then
+    for i = 2, #content_length do
+      if content_length[i] ~= content_length[1] then
+        return true
+      end
+    end
+  end
+
+  if transfer_encoding then
+    local value = table_concat(values(transfer_encoding), ",")
+    if not ngx_re_find(value, [[^\s*chunked\s*$]], "ijo") then
+      return true
+    end
+  end
+
+  return false
+end
+
+-- returns true when a header allowing a single value is defined several times
+local function has_duplicate_single_value(headers)
+  for name, value in pairs(headers) do
+    if type(value) == "table" and SINGLE_VALUE_HEADERS[name] then
+      return true
+    end
+  end
+
+  return false
+end
+
+local function normalize_headers(headers)
+  local normalized = false
+  for name, value in pairs(headers) do
+    if type(value) == "table" then
+      ngx.req.set_header(name, table_concat(value, SEPARATORS[name] or ", "))
+      normalized = true
+    end
+  end
+
+  if normalized then
+    record(DUPLICATE_HEADERS, "normalized")
+  end
+end
+
+-- decodes the path proxied to the upstream server, returns true when the
+-- path still traverses its parent directories once decoded again
+local function normalize_path()
+  local path = string_match(ngx.var.request_uri, "^[^?]*")
+  -- encoded dots, slashes and backslashes, or percents encoding them twice
+  if not ngx_re_find(path, [[%(25|2e|2f|5c)]], "ijo") then
+    return false
+  end
+
+  -- NGINX decoded the path once to choose the location, a traversal left
+  -- after a second decoding would only be seen by the upstream server
+  local uri = ngx.var.uri
+  if ngx_re_find(ngx.unescape_uri(uri), [[(^|[/\\])\.\.([/\\]|$)]], "jo") then
+    return true
+  end
+
+  -- proxy the decoded path instead of the original one
+  ngx.req.set_uri(uri)
+  record(PATH_TRAVERSAL, "normalized")
+
+  return false
+end
+
+function _M.set_config(new_config)
+  config = new_config
+end
+
+function _M.rewrite()
+  if config.max_headers > 0 or config.max_header_size > 0 or
+      config.reject_ambiguous_requests or config.normalize_duplicate_headers then
+    -- 0 returns all the headers of the request
+    local headers = ngx.req.get_headers(0)
+
+    local rule = check_limits(headers)
+    if rule then
+      return reject(rule)
+    end
+
+    if config.reject_ambiguous_requests and is_ambiguous(headers) then
+      return reject(AMBIGUOUS_FRAMING)
+    end
+
+    if config.normalize_duplicate_headers then
+      if has_duplicate_single_value(headers) then
+        return reject(DUPLICATE_HEADERS)
+      end
+
+      normalize_headers(headers)
+    end
+  end
+
+  if config.decode_path_traversal and normalize_path() then
+    return reject(PATH_TRAVERSAL)
+  end
+end
+
+return _M
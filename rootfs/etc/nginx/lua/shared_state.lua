@@ -0,0 +1,279 @@
+local redis = require("resty.redis")
+
+local string_format = string.format
+local table_insert = table.insert
+
+-- the changes are dropped when the backend is unavailable for too long
+local MAX_PENDING_CHANGES = 1000
+-- measured in milliseconds
+local KEEPALIVE_TIMEOUT = 10000
+local KEEPALIVE_POOL_SIZE = 10
+-- number of sync intervals without a successful sync before the local state
+-- of the replica is used again
+local MAX_MISSED_SYNCS = 3
+
+local _M = {}
+
+local config
+local backend
+
+-- the fields of each map, as returned by the backend in the last sync
+-- and updated by the local changes not written yet
+local maps = {}
+-- changes waiting to be written to the backend
+local pending = {}
+local last_sync = 0
+local syncing = false
+
+-- parses redis://[:<password>@]<host>[:<port>][/<db>]
+local function parse(url)
+  local rest = url:match("^redis://(.+)$")
+  if not rest then
+    return nil
+  end
+
+  local password
+  local auth, address = rest:match("^([^@]*)@(.+)$")
+  if auth then
+    password = auth:match("^:(.+)$")
+    if not password then
+      return nil
+    end
+    rest = address
+  end
+
+  local host_port, db = rest:match("^([^/]+)/?(%d*)$")
+  if not host_port then
+    return nil
+  end
+
+  local host, port = host_port:match("^(.+):(%d+)$")
+  if not host then
+    host, port = host_port, 6379
+  end
+
+  return { host = host, port = tonumber(port), password = password, db = tonumber(db) or 0 }
+end
+
+local function key(map)
+  return config.prefix .. ":" .. map
+end
+
+local function connect()
+  local red = redis:new()
+  red:set_timeouts(config.timeout, config.timeout, config.timeout)
+
+  local ok, err = red:connect(backend.host, backend.port)
+  if not ok then
+    return nil, err
+  end
+
+  -- the connections of the keepalive pool are already authenticated
+  if red:get_reused_times() == 0 then
+    if backend.password then
+      ok, err = red:auth(backend.password)
+      if not ok then
+        red:close()
+        return nil, err
+      end
+    end
+
+    if backend.db ~= 0 then
+      ok, err = red:select(backend.db)
+      if not ok then
+        red:close()
+        return nil, err
+      end
+    end
+  end
+
+  return red
+end
+
+local function apply(change)
+  local fields = maps[change.map]
+  if fields then
+    fields[change.field] = change.value
+  end
+end
+
+local function enqueue(changes)
+  for _, change in ipairs(changes) do
+    if #pending >= MAX_PENDING_CHANGES then
+      ngx.log(ngx.WARN, "too many shared state changes waiting for the backend, dropping "
+        .. change.map .. ":" .. change.field)
+    else
+      table_insert(pending, change)
+    end
+  end
+end
+
+-- puts back the changes that could not be written before the newer ones
+local function restore(changes)
+  local newer = pending
+  pending = {}
+  enqueue(changes)
+  enqueue(newer)
+end
+
+-- writes the local changes and reads the shared maps in a single round trip
+local function sync(premature)
+  if premature or syncing then
+    return
+  end
+
+  syncing = true
+
+  local changes = pending
+  pending = {}
+
+  local red, err = connect()
+  if not red then
+    ngx.log(ngx.WARN, string_format("could not connect to the shared state backend: %s", tostring(err)))
+    restore(changes)
+    syncing = false
+    return
+  end
+
+  red:init_pipeline()
+
+  for _, change in ipairs(changes) do
+    if change.value == nil then
+      red:hdel(key(change.map), change.field)
+    else
+      red:hset(key(change.map), change.field, change.value)
+    end
+  end
+
+  local names = {}
+  for name in pairs(maps) do
+    table_insert(names, name)
+    red:hgetall(key(name))
+  end
+
+  local results
+  results, err = red:commit_pipeline()
+  if not results then
+    ngx.log(ngx.WARN, string_format("could not sync the shared state: %s", tostring(err)))
+    red:close()
+    restore(changes)
+    syncing = false
+    return
+  end
+
+  for i, name in ipairs(names) do
+    local result = results[#changes + i]
+    if type(result) == "table" and result[1] ~= false then
+      maps[name] = red:array_to_hash(result)
+    else
+      local reason = type(result) == "table" and result[2] or result
+      ngx.log(ngx.WARN, string_format("could not read the shared state %s: %s", name, tostring(reason)))
+    end
+  end
+
+  -- the changes made during the sync are not written yet
+  for _, change in ipairs(pending) do
+    apply(change)
+  end
+
+  red:set_keepalive(KEEPALIVE_TIMEOUT, KEEPALIVE_POOL_SIZE)
+
+  last_sync = ngx.now()
+  syncing = false
+end
+
+function _M.init_worker(shared_state_config)
+  config = shared_state_config
+
+  backend = parse(config.backend)
+  if not backend then
+    ngx.log(ngx.ERR, "invalid shared state backend: " .. tostring(config.backend))
+    config = nil
+    return
+  end
+
+  local _, err = ngx.timer.at(0, sync)
+  if err then
+    ngx.log(ngx.ERR, string_format("error when setting up timer.at for the shared state: %s", tostring(err)))
+  end
+
+  _, err = ngx.timer.every(config.sync_interval, sync)
+  if err then
+    ngx.log(ngx.ERR, string_format("error when setting up timer.every for the shared state: %s", tostring(err)))
+  end
+end
+
+-- registers a map shared by the replicas, read from the backend on each sync
+function _M.register(map)
+  if not maps[map] then
+    maps[map] = {}
+  end
+end
+
+-- returns true while the state of the other replicas is known. Otherwise
+-- the decisions must be taken with the local state of the replica.
+function _M.available()
+  if not config or last_sync == 0 then
+    return false
+  end
+
+  return ngx.now() - last_sync <= config.sync_interval * MAX_MISSED_SYNCS
+end
+
+-- returns the value of a field of a shared map, nil when it does not
+-- exist or the shared state is not available
+function _M.get(map, field)
+  if not _M.available() then
+    return nil
+  end
+
+  local fields = maps[map]
+  if not fields then
+    return nil
+  end
+
+  return fields[field]
+end
+
+-- returns a copy of the fields of a shared map, nil when the shared
+-- state is not available
+function _M.get_all(map)
+  if not _M.available() then
+    return nil
+  end
+
+  local fields = {}
+  for field, value in pairs(maps[map] or {}) do
+    fields[field] = value
+  end
+  return fields
+end
+
+-- sets the value of a field of a shared map, written to the backend in the
+-- next sync. A nil value deletes the field.
+function _M.set(map, field, value)
+  if not config then
+    return
+  end
+
+  local change = { map = map, field = field, value = value ~= nil and tostring(value) or nil }
+  apply(change)
+  enqueue({ change })
+end
+
+function _M.delete(map, field)
+  _M.set(map, field, nil)
+end
+
+if _TEST then
+  _M.parse = parse
+  _M.sync = sync
+  _M.pending = function() return pending end
+  _M.reset = function()
+    config, backend = nil, nil
+    maps, pending = {}, {}
+    last_sync, syncing = 0, false
+  end
+end
+
+return _M
@@ -4,6 +4,7 @@ local util = require("util")
 local dns_util = require("util.dns")
 local configuration = require("tcp_udp_configuration")
 local round_robin = require("balancer.round_robin")
+local chash = require("balancer.chash")
 
 -- measured in seconds
 -- for an Nginx worker to pick up the new list of upstream peers
@@ -12,7 +13,9 @@ local BACKENDS_SYNC_INTERVAL = 1
 
 local DEFAULT_LB_ALG = "round_robin"
 local IMPLEMENTATIONS = {
-  round_robin = round_robin
+  round_robin = round_robin,
+  -- the source IP affinity of the UDP services
+  chash = chash,
 }
 
 local _M = {}
@@ -0,0 +1,86 @@
+local socket = ngx.socket.tcp
+local cjson = require("cjson.safe")
+local assert = assert
+local new_tab = require "table.new"
+local clear_tab = require "table.clear"
+local clone_tab = require "table.clone"
+local nkeys = require "table.nkeys"
+
+-- the sessions of the TCP and UDP services are far less frequent than the HTTP
+-- requests, the batches are smaller than the ones of the monitor module
+local MAX_BATCH_SIZE = 1000
+local FLUSH_INTERVAL = 1 -- second
+
+local metrics_batch = new_tab(MAX_BATCH_SIZE, 0)
+
+local _M = {}
+
+local function send(payload)
+  local s = assert(socket())
+  assert(s:connect("unix:/tmp/prometheus-nginx.socket"))
+  assert(s:send(payload))
+  assert(s:close())
+end
+
+local function metrics()
+  return {
+    -- the protocol distinguishes the sessions from the HTTP requests
+    protocol = ngx.var.protocol or "-",
+    namespace = ngx.var.namespace or "-",
+    service = ngx.var.service_name or "-",
+    port = ngx.var.service_port or "-",
+
+    status = ngx.var.status or "-",
+    bytesSent = tonumber(ngx.var.bytes_sent) or -1,
+    bytesReceived = tonumber(ngx.var.bytes_received) or -1,
+    sessionTime = tonumber(ngx.var.session_time) or -1,
+
+    -- the connect time is a list when several upstream servers were tried
+    upstreamLatency = tonumber(ngx.var.upstream_connect_time) or -1,
+  }
+end
+
+local function flush(premature)
+  if premature then
+    return
+  end
+
+  if #metrics_batch == 0 then
+    return
+  end
+
+  local current_metrics_batch = clone_tab(metrics_batch)
+  clear_tab(metrics_batch)
+
+  local payload, err = cjson.encode(current_metrics_batch)
+  if not payload then
+    ngx.log(ngx.ERR, "error while encoding stream metrics: ", err)
+    return
+  end
+
+  send(payload)
+end
+
+function _M.init_worker()
+  local _, err = ngx.timer.every(FLUSH_INTERVAL, flush)
+  if err then
+    ngx.log(ngx.ERR, string.format("error when setting up timer.every: %s", tostring(err)))
+  end
+end
+
+function _M.call()
+  local metrics_size = nkeys(metrics_batch)
+  if metrics_size >= MAX_BATCH_SIZE then
+    ngx.log(ngx.WARN, "omitting metrics for the session, current batch is full")
+    return
+  end
+
+  metrics_batch[metrics_size + 1] = metrics()
+end
+
+if _TEST then
+  _M.flush = flush
+  _M.get_metrics_batch = function() return metrics_batch end
+end
+
+return _M
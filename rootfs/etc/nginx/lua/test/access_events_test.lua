@@ -0,0 +1,113 @@
+_G._TEST = true
+
+local cjson = require("cjson.safe")
+local sink_util = require("util.sink")
+
+local access_events
+local now = 1000
+local original_ngx = ngx
+
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local config = {
+  sink = "http://collector/events",
+  batch_size = 2,
+  flush_interval = 1,
+  buffer_size = 3,
+}
+
+local function reset_access_events()
+  package.loaded["access_events"] = nil
+  access_events = require("access_events")
+
+  ngx.shared.access_events:flush_all()
+  access_events.init_worker(config)
+end
+
+describe("access_events", function()
+  before_each(function()
+    mock_ngx({
+      now = function() return now end,
+      timer = { every = function() return true end },
+      var = { host = "example.com", uri = "/", status = "200", request_method = "GET" },
+    })
+    reset_access_events()
+  end)
+
+  after_each(function()
+    reset_ngx()
+    sink_util.send:revert()
+  end)
+
+  it("buffers the access events", function()
+    stub(sink_util, "send")
+    access_events.log()
+
+    local buffer = access_events.buffer()
+    assert.equal(1, #buffer)
+
+    local event = cjson.decode(buffer[1])
+    assert.equal("example.com", event.host)
+    assert.equal(200, event.status)
+  end)
+
+  it("drops the events when the buffer is full", function()
+    stub(sink_util, "send")
+    for _ = 1, 5 do
+      access_events.log()
+    end
+
+    assert.equal(3, #access_events.buffer())
+    assert.equal(2, ngx.shared.access_events:get("dropped"))
+  end)
+
+  it("sends the events in batches", function()
+    local batches = {}
+    stub(sink_util, "send", function(_, batch)
+      table.insert(batches, #batch)
+      return true
+    end)
+
+    for _ = 1, 3 do
+      access_events.log()
+    end
+    access_events.flush()
+
+    assert.same({ 2, 1 }, batches)
+    assert.equal(0, #access_events.buffer())
+    assert.equal(3, ngx.shared.access_events:get("sent"))
+  end)
+
+  it("keeps the events and backs off when the sink is unavailable", function()
+    stub(sink_util, "send", function() return nil, "connection refused" end)
+
+    access_events.log()
+    access_events.flush()
+    assert.equal(1, #access_events.buffer())
+    assert.equal(1, access_events.backoff())
+    assert.equal(1, ngx.shared.access_events:get("failed_batches"))
+
+    now = now + 1
+    access_events.flush()
+    assert.equal(2, access_events.backoff())
+
+    -- the sink is not retried before the end of the backoff
+    access_events.flush()
+    assert.stub(sink_util.send).was.called(2)
+
+    sink_util.send:revert()
+    stub(sink_util, "send", function() return true end)
+    now = now + 2
+    access_events.flush()
+    assert.equal(0, #access_events.buffer())
+    assert.equal(0, access_events.backoff())
+  end)
+end)
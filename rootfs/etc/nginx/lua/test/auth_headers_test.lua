@@ -0,0 +1,70 @@
+local cjson = require("cjson.safe")
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("auth_headers", function()
+  local auth_headers = require("auth_headers")
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  describe("capture()", function()
+    it("copies the headers matching the prefixes", function()
+      local var = {}
+      mock_ngx({
+        var = var,
+        resp = {
+          get_headers = function()
+            return { ["x-auth-user"] = "jdoe", ["x-remote-group"] = "admins", ["x-auth-"] = "empty", ["content-type"] = "text/plain" }
+          end,
+        },
+      })
+
+      auth_headers.capture({ { prefix = "x-auth-", replacement = "X-" }, { prefix = "x-remote-", replacement = "" } })
+
+      local headers = cjson.decode(var.auth_prefixed_headers)
+      assert.are.same({ ["X-user"] = "jdoe", ["group"] = "admins" }, headers)
+    end)
+  end)
+
+  describe("set_request_headers()", function()
+    it("sets the captured headers in the request", function()
+      local s = spy.new(function() end)
+      mock_ngx({ var = { auth_prefixed_headers = '{"X-user":"jdoe"}' }, req = { set_header = s } })
+
+      auth_headers.set_request_headers()
+
+      assert.spy(s).was_called_with("X-user", "jdoe")
+    end)
+
+    it("does nothing when no headers were captured", function()
+      local s = spy.new(function() end)
+      mock_ngx({ var = { auth_prefixed_headers = "" }, req = { set_header = s } })
+
+      auth_headers.set_request_headers()
+
+      assert.spy(s).was_not_called()
+    end)
+  end)
+
+  describe("set_response_headers()", function()
+    it("sets the captured headers in the response", function()
+      local header = {}
+      mock_ngx({ var = { auth_prefixed_headers = '{"X-user":"jdoe"}' }, header = header })
+
+      auth_headers.set_response_headers()
+
+      assert.are.same({ ["X-user"] = "jdoe" }, header)
+    end)
+  end)
+end)
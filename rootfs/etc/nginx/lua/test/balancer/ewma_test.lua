@@ -48,6 +48,22 @@ describe("Balancer ewma", function()
       local peer = instance:balance()
       assert.equal("10.184.97.100:8080", peer)
     end)
+
+    it("divides the score of the endpoints by their weight", function()
+      local backend = {
+        name = "my-dummy-backend", ["load-balance"] = "ewma",
+        endpoints = {
+          { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 },
+          { address = "10.184.97.100", port = "8080", maxFails = 0, failTimeout = 0, weight = 50 },
+        }
+      }
+      local instance = balancer_ewma:new(backend)
+      instance.ewma =  { ["10.184.7.40:8080"] = 0.5, ["10.184.97.100:8080"] = 0.3 }
+      instance.ewma_last_touched_at =  { ["10.184.7.40:8080"] = ngx.now(), ["10.184.97.100:8080"] = ngx.now() }
+
+      local peer = instance:balance()
+      assert.equal("10.184.7.40:8080", peer)
+    end)
   end)
 
   describe("sync()", function()
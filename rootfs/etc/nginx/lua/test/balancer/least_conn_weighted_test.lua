@@ -0,0 +1,81 @@
+local util = require("util")
+
+describe("Balancer least_conn_weighted", function()
+  local balancer_least_conn_weighted = require("balancer.least_conn_weighted")
+
+  local ngx_now = 1543238266
+  local backend, instance
+
+  before_each(function()
+    _G.ngx.now = function() return ngx_now end
+    _G.ngx.ctx = {}
+    _G.ngx.var = { upstream_response_time = "0.25", upstream_connect_time = "0.02", upstream_addr = "10.184.7.40:8080" }
+
+    backend = {
+      name = "my-dummy-backend", ["load-balance"] = "least_conn_weighted",
+      endpoints = {
+        { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 },
+        { address = "10.184.97.100", port = "8080", maxFails = 0, failTimeout = 0 },
+      }
+    }
+    instance = balancer_least_conn_weighted:new(backend)
+  end)
+
+  describe("balance()", function()
+    it("picks the endpoint with the least in-flight requests", function()
+      instance.in_flight = { ["10.184.7.40:8080"] = 3, ["10.184.97.100:8080"] = 1 }
+
+      assert.equal("10.184.97.100:8080", instance:balance())
+      assert.equal(2, instance.in_flight["10.184.97.100:8080"])
+    end)
+
+    it("weights the in-flight requests by the latency of the endpoints", function()
+      instance.in_flight = { ["10.184.7.40:8080"] = 1, ["10.184.97.100:8080"] = 2 }
+      instance.latency = { ["10.184.7.40:8080"] = 0.9, ["10.184.97.100:8080"] = 0.1 }
+
+      assert.equal("10.184.97.100:8080", instance:balance())
+    end)
+
+    it("weights the in-flight requests by the weight of the endpoints", function()
+      backend.endpoints[1].weight = 25
+      instance.in_flight = { ["10.184.7.40:8080"] = 1, ["10.184.97.100:8080"] = 4 }
+
+      assert.equal("10.184.97.100:8080", instance:balance())
+    end)
+
+    it("spreads the requests without latency", function()
+      local first = instance:balance()
+      local second = instance:balance()
+
+      assert.are_not.equal(first, second)
+      assert.same({ first, second }, ngx.ctx.in_flight_peers)
+    end)
+  end)
+
+  describe("after_balance()", function()
+    it("releases the in-flight requests and updates the latency", function()
+      instance:balance()
+      instance:balance()
+
+      instance:after_balance()
+
+      assert.same({}, instance.in_flight)
+      assert.equal(0.27, instance.latency["10.184.7.40:8080"])
+      assert.equal(ngx_now, instance.latency_touched_at["10.184.7.40:8080"])
+    end)
+  end)
+
+  describe("sync()", function()
+    it("keeps the latency of the remaining endpoints", function()
+      instance.latency = { ["10.184.7.40:8080"] = 0.5, ["10.184.97.100:8080"] = 0.3 }
+      instance.latency_touched_at = { ["10.184.7.40:8080"] = ngx_now, ["10.184.97.100:8080"] = ngx_now }
+
+      local new_backend = util.deepcopy(backend)
+      table.remove(new_backend.endpoints, 2)
+      instance:sync(new_backend)
+
+      assert.same(new_backend.endpoints, instance.peers)
+      assert.same({ ["10.184.7.40:8080"] = 0.5 }, instance.latency)
+    end)
+  end)
+end)
@@ -0,0 +1,100 @@
+local util = require("util")
+
+describe("Balancer peak_ewma", function()
+  local balancer_peak_ewma = require("balancer.peak_ewma")
+
+  local ngx_now = 1543238266
+  local backend, instance
+
+  before_each(function()
+    _G.ngx.now = function() return ngx_now end
+    _G.ngx.ctx = {}
+    _G.ngx.var = { upstream_response_time = "0.25", upstream_connect_time = "0.02", upstream_addr = "10.184.7.40:8080" }
+
+    backend = {
+      name = "my-dummy-backend", ["load-balance"] = "peak_ewma",
+      endpoints = {
+        { address = "10.184.7.40", port = "8080", maxFails = 0, failTimeout = 0 },
+        { address = "10.184.97.100", port = "8080", maxFails = 0, failTimeout = 0 },
+      }
+    }
+    instance = balancer_peak_ewma:new(backend)
+  end)
+
+  describe("balance()", function()
+    it("returns single endpoint when the given backend has only one endpoint", function()
+      table.remove(backend.endpoints, 2)
+
+      assert.equal("10.184.7.40:8080", instance:balance())
+      assert.equal(1, instance.in_flight["10.184.7.40:8080"])
+    end)
+
+    it("picks the endpoint with the lowest latency", function()
+      instance.latency = { ["10.184.7.40:8080"] = 0.5, ["10.184.97.100:8080"] = 0.3 }
+      instance.latency_touched_at = { ["10.184.7.40:8080"] = ngx_now, ["10.184.97.100:8080"] = ngx_now }
+
+      assert.equal("10.184.97.100:8080", instance:balance())
+      assert.equal(0.3, ngx.var.balancer_ewma_score)
+    end)
+
+    it("multiplies the latency by the in-flight requests", function()
+      instance.latency = { ["10.184.7.40:8080"] = 0.5, ["10.184.97.100:8080"] = 0.3 }
+      instance.latency_touched_at = { ["10.184.7.40:8080"] = ngx_now, ["10.184.97.100:8080"] = ngx_now }
+      instance.in_flight = { ["10.184.97.100:8080"] = 2 }
+
+      assert.equal("10.184.7.40:8080", instance:balance())
+    end)
+
+    it("avoids the endpoints without latency with in-flight requests", function()
+      instance.latency = { ["10.184.7.40:8080"] = 5 }
+      instance.latency_touched_at = { ["10.184.7.40:8080"] = ngx_now }
+      instance.in_flight = { ["10.184.97.100:8080"] = 1 }
+
+      assert.equal("10.184.7.40:8080", instance:balance())
+    end)
+  end)
+
+  describe("after_balance()", function()
+    it("releases the in-flight requests and sets the latency", function()
+      instance:balance()
+      instance:after_balance()
+
+      assert.same({}, instance.in_flight)
+      assert.equal(0.27, instance.latency["10.184.7.40:8080"])
+      assert.equal(ngx_now, instance.latency_touched_at["10.184.7.40:8080"])
+    end)
+
+    it("follows the latency peaks immediately", function()
+      instance.latency = { ["10.184.7.40:8080"] = 0.1 }
+      instance.latency_touched_at = { ["10.184.7.40:8080"] = ngx_now }
+
+      instance:after_balance()
+
+      assert.equal(0.27, instance.latency["10.184.7.40:8080"])
+    end)
+
+    it("decays the latency slowly", function()
+      instance.latency = { ["10.184.7.40:8080"] = 1 }
+      instance.latency_touched_at = { ["10.184.7.40:8080"] = ngx_now - 1 }
+
+      instance:after_balance()
+
+      local latency = instance.latency["10.184.7.40:8080"]
+      assert.is_true(latency < 1 and latency > 0.9)
+    end)
+  end)
+
+  describe("sync()", function()
+    it("keeps the latency of the remaining endpoints", function()
+      instance.latency = { ["10.184.7.40:8080"] = 0.5, ["10.184.97.100:8080"] = 0.3 }
+      instance.latency_touched_at = { ["10.184.7.40:8080"] = ngx_now, ["10.184.97.100:8080"] = ngx_now }
+
+      local new_backend = util.deepcopy(backend)
+      table.remove(new_backend.endpoints, 2)
+      instance:sync(new_backend)
+
+      assert.same(new_backend.endpoints, instance.peers)
+      assert.same({ ["10.184.7.40:8080"] = 0.5 }, instance.latency)
+    end)
+  end)
+end)
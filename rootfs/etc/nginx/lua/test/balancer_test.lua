@@ -26,6 +26,8 @@ local function reset_expected_implementations()
     ["my-dummy-app-3"] = package.loaded["balancer.sticky"],
     ["my-dummy-app-4"] = package.loaded["balancer.ewma"],
     ["my-dummy-app-5"] = package.loaded["balancer.sticky"],
+    ["my-dummy-app-6"] = package.loaded["balancer.least_conn_weighted"],
+    ["my-dummy-app-7"] = package.loaded["balancer.peak_ewma"],
   }
 end
 
@@ -62,6 +64,8 @@ local function reset_backends()
       name = "my-dummy-app-5", ["load-balance"] = "ewma", ["upstream-hash-by"] = "$request_uri",
       sessionAffinityConfig = { name = "cookie", cookieSessionAffinity = { name = "route" } }
     },
+    { name = "my-dummy-app-6", ["load-balance"] = "least_conn_weighted", },
+    { name = "my-dummy-app-7", ["load-balance"] = "peak_ewma", },
   }
 end
 
@@ -130,6 +134,14 @@ describe("Balancer", function()
         balancer.sync_backend(backend)
         assert.equal(false, balancer.route_to_alternative_balancer(_balancer))
       end)
+
+      it("uses the weight set through the traffic management API", function()
+        backend.trafficShapingPolicy.weight = 0
+        balancer.sync_backend(backend)
+        ngx.shared.canary_weights:set(backend.name, 100)
+        assert.equal(true, balancer.route_to_alternative_balancer(_balancer))
+        ngx.shared.canary_weights:delete(backend.name)
+      end)
     end)
 
     context("canary by cookie", function()
@@ -254,6 +266,267 @@ describe("Balancer", function()
     end)
   end)
 
+  describe("route_to_pod_balancer()", function()
+    local backend
+
+    before_each(function()
+      backend = backends[1]
+      backend.podRoutingBy = "$http_x_pod_ordinal"
+      backend.podBackends = {
+        ["0"] = "access-router-production-web-80-pod-web-0",
+        ["web-0"] = "access-router-production-web-80-pod-web-0",
+      }
+    end)
+
+    after_each(function()
+      reset_ngx()
+    end)
+
+    it("returns nil when pod routing is not configured", function()
+      backend.podRoutingBy = nil
+      balancer.sync_backend(backend)
+      mock_ngx({ var = { http_x_pod_ordinal = "0" } })
+      assert.is_nil(balancer.route_to_pod_balancer({}))
+    end)
+
+    it("returns the pod backend matching the ordinal or the pod name", function()
+      local _balancer = { pod_routing_by = backend.podRoutingBy, pod_backends = backend.podBackends }
+
+      mock_ngx({ var = { http_x_pod_ordinal = "0" } })
+      assert.equal("access-router-production-web-80-pod-web-0", balancer.route_to_pod_balancer(_balancer))
+
+      mock_ngx({ var = { http_x_pod_ordinal = "web-0" } })
+      assert.equal("access-router-production-web-80-pod-web-0", balancer.route_to_pod_balancer(_balancer))
+    end)
+
+    it("returns nil when the pod does not exist", function()
+      local _balancer = { pod_routing_by = backend.podRoutingBy, pod_backends = backend.podBackends }
+
+      mock_ngx({ var = { http_x_pod_ordinal = "5" } })
+      assert.is_nil(balancer.route_to_pod_balancer(_balancer))
+
+      mock_ngx({ var = {} })
+      assert.is_nil(balancer.route_to_pod_balancer(_balancer))
+    end)
+  end)
+
+  describe("get_balancer()", function()
+    local primary, canary
+
+    before_each(function()
+      canary = backends[1]
+      primary = {
+        name = "access-router-production-web-80-primary", port = "80",
+        endpoints = canary.endpoints,
+        alternativeBackends = { canary.name },
+      }
+    end)
+
+    it("tags the request as canary when routed to the alternative backend", function()
+      canary.trafficShapingPolicy.weight = 100
+      balancer.sync_backend(canary)
+      balancer.sync_backend(primary)
+
+      local ngx_mock = { ctx = {}, var = { proxy_upstream_name = primary.name } }
+      mock_ngx(ngx_mock)
+
+      assert.is_not_nil(balancer.get_balancer())
+      assert.equal(canary.name, ngx_mock.var.proxy_alternative_upstream_name)
+      assert.equal("canary", ngx_mock.var.canary_variant)
+      assert.equal("100", ngx_mock.var.canary_weight)
+    end)
+
+    it("tags the request as stable when routed to the primary backend", function()
+      canary.trafficShapingPolicy.weight = 0
+      balancer.sync_backend(canary)
+      balancer.sync_backend(primary)
+
+      local ngx_mock = { ctx = {}, var = { proxy_upstream_name = primary.name } }
+      mock_ngx(ngx_mock)
+
+      balancer.get_balancer()
+      assert.equal("stable", ngx_mock.var.canary_variant)
+      assert.equal("0", ngx_mock.var.canary_weight)
+    end)
+
+    it("does not tag requests for backends without canary", function()
+      primary.alternativeBackends = nil
+      balancer.sync_backend(primary)
+
+      local ngx_mock = { ctx = {}, var = { proxy_upstream_name = primary.name } }
+      mock_ngx(ngx_mock)
+
+      balancer.get_balancer()
+      assert.is_nil(ngx_mock.var.canary_variant)
+      assert.is_nil(ngx_mock.var.canary_weight)
+    end)
+
+    it("takes the decision only once per request", function()
+      canary.trafficShapingPolicy.weight = 100
+      balancer.sync_backend(canary)
+      balancer.sync_backend(primary)
+
+      local ngx_mock = { ctx = {}, var = { proxy_upstream_name = primary.name } }
+      mock_ngx(ngx_mock)
+
+      local first = balancer.get_balancer()
+      canary.trafficShapingPolicy.weight = 0
+      balancer.sync_backend(canary)
+      assert.equal(first, balancer.get_balancer())
+    end)
+  end)
+
+  describe("failover", function()
+    local primary, secondary, now
+
+    local function request(status)
+      local ngx_mock = {
+        ctx = {},
+        var = { proxy_upstream_name = primary.name, upstream_status = status },
+        now = function() return now end,
+      }
+      mock_ngx(ngx_mock)
+
+      local chosen = balancer.get_balancer()
+      if status then
+        balancer.log()
+      end
+      return chosen, ngx_mock
+    end
+
+    before_each(function()
+      now = 1000
+      secondary = {
+        name = "default-other-cluster-80", port = "80", noServer = true,
+        endpoints = { { address = "10.184.1.1", port = "8080", maxFails = 0, failTimeout = 0 } },
+      }
+      primary = {
+        name = "default-primary-80", port = "80",
+        endpoints = backends[1].endpoints,
+        failover = { backend = secondary.name, maxFails = 2, failTimeout = 30 },
+      }
+      balancer.sync_backend(secondary)
+      balancer.sync_backend(primary)
+    end)
+
+    after_each(function()
+      reset_ngx()
+    end)
+
+    it("routes to the primary backend while it is up", function()
+      local chosen, ngx_mock = request()
+      assert.is_not_nil(chosen)
+      assert.is_nil(ngx_mock.var.proxy_alternative_upstream_name)
+    end)
+
+    it("routes to the failover backend when the primary has no endpoint", function()
+      primary.endpoints = {}
+      balancer.sync_backend(primary)
+
+      local chosen, ngx_mock = request()
+      assert.is_not_nil(chosen)
+      assert.equal(secondary.name, ngx_mock.var.proxy_alternative_upstream_name)
+    end)
+
+    it("does not route anywhere when the failover backend has no balancer", function()
+      primary.endpoints = {}
+      secondary.endpoints = {}
+      balancer.sync_backend(secondary)
+      balancer.sync_backend(primary)
+
+      assert.is_nil(request())
+    end)
+
+    it("fails over after consecutive failures and fails back after the timeout", function()
+      local _, ngx_mock = request("502")
+      assert.is_nil(ngx_mock.var.proxy_alternative_upstream_name)
+      _, ngx_mock = request("200")
+      _, ngx_mock = request("504")
+      assert.is_nil(ngx_mock.var.proxy_alternative_upstream_name)
+      _, ngx_mock = request("502, 503")
+
+      _, ngx_mock = request()
+      assert.equal(secondary.name, ngx_mock.var.proxy_alternative_upstream_name)
+
+      now = now + 31
+      _, ngx_mock = request("200")
+      assert.is_nil(ngx_mock.var.proxy_alternative_upstream_name)
+      _, ngx_mock = request("502")
+      _, ngx_mock = request()
+      assert.is_nil(ngx_mock.var.proxy_alternative_upstream_name)
+    end)
+
+    it("marks the primary down again when it still fails after the timeout", function()
+      request("502")
+      request("502")
+
+      now = now + 31
+      local _, ngx_mock = request("503")
+      assert.is_nil(ngx_mock.var.proxy_alternative_upstream_name)
+
+      _, ngx_mock = request()
+      assert.equal(secondary.name, ngx_mock.var.proxy_alternative_upstream_name)
+    end)
+
+    it("does not count the requests served by the failover backend", function()
+      request("502")
+      request("502")
+
+      request("502")
+      request("502")
+      now = now + 31
+
+      local _, ngx_mock = request()
+      assert.is_nil(ngx_mock.var.proxy_alternative_upstream_name)
+    end)
+  end)
+
+  describe("set_backend_variables()", function()
+    local backend
+
+    before_each(function()
+      backend = {
+        name = "fakenamespace-myapp-80",
+        service = { metadata = { namespace = "fakenamespace", name = "myapp" } },
+        endpoints = {
+          { address = "10.0.0.1", port = "8080", target = { namespace = "fakenamespace", name = "myapp-0" } },
+          { address = "::1", port = "8080", target = { namespace = "fakenamespace", name = "myapp-1" } },
+          { address = "10.0.0.3", port = "8080" },
+        },
+      }
+    end)
+
+    it("sets the namespace, service and pod of the peer", function()
+      balancer.sync_backend(backend)
+
+      local ngx_mock = { ctx = {}, var = { proxy_upstream_name = backend.name } }
+      mock_ngx(ngx_mock)
+
+      local _balancer = balancer.get_balancer()
+      balancer.set_backend_variables(_balancer, "[::1]:8080")
+      assert.equal("fakenamespace", ngx_mock.var.backend_namespace)
+      assert.equal("myapp", ngx_mock.var.backend_service)
+      assert.equal("myapp-1", ngx_mock.var.backend_pod)
+
+      balancer.set_backend_variables(_balancer, "10.0.0.3:8080")
+      assert.equal("myapp", ngx_mock.var.backend_service)
+      assert.equal("", ngx_mock.var.backend_pod)
+    end)
+
+    it("does not set the variables without metadata", function()
+      backend.service = nil
+      balancer.sync_backend(backend)
+
+      local ngx_mock = { ctx = {}, var = { proxy_upstream_name = backend.name } }
+      mock_ngx(ngx_mock)
+
+      balancer.set_backend_variables(balancer.get_balancer(), "10.0.0.1:8080")
+      assert.is_nil(ngx_mock.var.backend_namespace)
+      assert.is_nil(ngx_mock.var.backend_service)
+      assert.is_nil(ngx_mock.var.backend_pod)
+    end)
+  end)
+
   describe("sync_backend()", function()
     local backend, implementation
 
@@ -363,4 +636,60 @@ describe("Balancer", function()
       assert.stub(mock_instance.sync).was_called_with(mock_instance, backend)
     end)
   end)
+
+  describe("get_no_endpoints_retry_after()", function()
+    local backend, now
+
+    before_each(function()
+      now = 1000
+      mock_ngx({ now = function() return now end })
+
+      backend = { name = "default-web-80", port = "80", endpoints = {} }
+      balancer.sync_backend(backend)
+    end)
+
+    after_each(function()
+      reset_ngx()
+    end)
+
+    it("returns nil when the Retry-After header is disabled", function()
+      assert.is_nil(balancer.get_no_endpoints_retry_after({ retry_after = 0 }, backend.name))
+    end)
+
+    it("returns a constant value without maximum", function()
+      now = 2000
+      assert.equal(5, balancer.get_no_endpoints_retry_after({ retry_after = 5 }, backend.name))
+    end)
+
+    it("doubles the value while the backend has no endpoint", function()
+      local config = { retry_after = 5, retry_after_max = 60 }
+
+      assert.equal(5, balancer.get_no_endpoints_retry_after(config, backend.name))
+
+      now = 1008
+      assert.equal(10, balancer.get_no_endpoints_retry_after(config, backend.name))
+
+      now = 1030
+      assert.equal(40, balancer.get_no_endpoints_retry_after(config, backend.name))
+
+      now = 1500
+      assert.equal(60, balancer.get_no_endpoints_retry_after(config, backend.name))
+
+      -- the time since the backend has no endpoint is kept between syncs
+      balancer.sync_backend(backend)
+      assert.equal(60, balancer.get_no_endpoints_retry_after(config, backend.name))
+    end)
+
+    it("starts again once the backend has endpoints", function()
+      local config = { retry_after = 5, retry_after_max = 60 }
+
+      now = 1500
+      backend.endpoints = backends[1].endpoints
+      balancer.sync_backend(backend)
+      backend.endpoints = {}
+      balancer.sync_backend(backend)
+
+      assert.equal(5, balancer.get_no_endpoints_retry_after(config, backend.name))
+    end)
+  end)
 end)
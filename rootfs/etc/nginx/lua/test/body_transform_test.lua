@@ -0,0 +1,130 @@
+local cjson = require("cjson.safe")
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("body_transform", function()
+  local body_transform = require("body_transform")
+  local zlib = require("util.zlib")
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  describe("zlib", function()
+    it("decompresses the data it compressed", function()
+      local data = string.rep("ingress-nginx ", 1000)
+      local compressed = assert(zlib.gzip(data))
+
+      assert.is_true(#compressed < #data)
+      assert.equal(data, zlib.gunzip(compressed, #data))
+    end)
+
+    it("stops decompressing data larger than the limit", function()
+      local compressed = assert(zlib.gzip(string.rep("a", 100000)))
+
+      local decompressed, err = zlib.gunzip(compressed, 1024)
+      assert.is_nil(decompressed)
+      assert.equal(zlib.ERR_TOO_LARGE, err)
+    end)
+
+    it("rejects truncated and invalid data", function()
+      local compressed = assert(zlib.gzip(string.rep("ingress-nginx ", 1000)))
+
+      assert.is_nil(zlib.gunzip(string.sub(compressed, 1, 20), 100000))
+      assert.is_nil(zlib.gunzip("not gzip", 100000))
+    end)
+  end)
+
+  describe("redact_json()", function()
+    it("redacts the fields at any depth", function()
+      local body = cjson.encode({
+        user = { name = "jane", password = "s3cr3t" },
+        sessions = { { token = "abc" }, { token = "def" } },
+      })
+
+      local redacted = cjson.decode(body_transform.redact_json(body, { "password", "token" }))
+      assert.equal("jane", redacted.user.name)
+      assert.equal(body_transform.REDACTED, redacted.user.password)
+      assert.equal(body_transform.REDACTED, redacted.sessions[1].token)
+      assert.equal(body_transform.REDACTED, redacted.sessions[2].token)
+    end)
+
+    it("redacts the dotted fields from the root only", function()
+      local body = cjson.encode({ user = { ssn = "123" }, other = { user = { ssn = "456" } } })
+
+      local redacted = cjson.decode(body_transform.redact_json(body, { "user.ssn" }))
+      assert.equal(body_transform.REDACTED, redacted.user.ssn)
+      assert.equal("456", redacted.other.user.ssn)
+    end)
+
+    it("keeps the empty arrays", function()
+      assert.equal('{"items":[]}', body_transform.redact_json('{"items":[]}', { "password" }))
+    end)
+
+    it("returns the bodies that are not JSON documents", function()
+      assert.equal("not json", body_transform.redact_json("not json", { "password" }))
+      assert.equal('"password"', body_transform.redact_json('"password"', { "password" }))
+    end)
+  end)
+
+  describe("filters", function()
+    local config = { request_encoding = "", redact_json_fields = { "password" }, max_size = 64 }
+
+    local function run_filters(headers, chunks)
+      local ctx = {}
+      mock_ngx({ header = headers, ctx = ctx, arg = {} })
+      body_transform.header_filter(config)
+
+      local output = {}
+      for i, chunk in ipairs(chunks) do
+        ngx.arg[1] = chunk
+        ngx.arg[2] = i == #chunks
+        body_transform.body_filter(config)
+        if ngx.arg[1] then
+          table.insert(output, ngx.arg[1])
+        end
+      end
+
+      return table.concat(output)
+    end
+
+    it("redacts the JSON responses received in several chunks", function()
+      local headers = { content_type = "application/json", content_length = 27 }
+      local body = run_filters(headers, { '{"password":', '"s3cr3t","a":1}' })
+
+      assert.is_nil(headers.content_length)
+      assert.same({ password = body_transform.REDACTED, a = 1 }, cjson.decode(body))
+    end)
+
+    it("does not change the other responses", function()
+      local headers = { content_type = "text/html", content_length = 4 }
+      assert.equal("<p/>", run_filters(headers, { "<p/>" }))
+      assert.equal(4, headers.content_length)
+    end)
+
+    it("drops the responses larger than the limit", function()
+      local headers = { content_type = "application/json" }
+      local chunk = '{"password":"' .. string.rep("a", 40) .. '",'
+      local body = run_filters(headers, { chunk, chunk, '"b":1}' })
+
+      assert.equal("", body)
+    end)
+
+    it("drops the compressed responses", function()
+      local headers = { content_type = "application/json", content_encoding = "gzip" }
+      local body = run_filters(headers, { "compressed" })
+
+      assert.equal("", body)
+      assert.equal(ngx.HTTP_BAD_GATEWAY, ngx.status)
+    end)
+  end)
+end)
@@ -0,0 +1,175 @@
+_G._TEST = true
+
+local cjson = require("cjson.safe")
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local NOW = 1700000000
+
+describe("bot_challenge", function()
+  local configuration = require("configuration")
+  local bot_challenge = require("bot_challenge")
+
+  local var
+  local header
+
+  local function challenge(options)
+    local c = { mode = "js", difficulty = 0, rate = 0, asns = {}, user_agents = {}, exempt_cidrs = {}, exempt_user_agents = {} }
+    for k, v in pairs(options or {}) do
+      c[k] = v
+    end
+    return c
+  end
+
+  before_each(function()
+    configuration.get_bot_challenge_data = function()
+      return cjson.encode({ key = "s3cr3t", ttl = 3600 })
+    end
+
+    var = { remote_addr = "203.0.113.7", http_user_agent = "Mozilla/5.0", host = "example.com", location_path = "/" }
+    header = {}
+    mock_ngx({
+      var = var,
+      header = header,
+      time = function() return NOW end,
+      now = function() return NOW end,
+      print = spy.new(function() end),
+      exit = spy.new(function() end),
+      req = { get_method = function() return "GET" end },
+    })
+  end)
+
+  after_each(function()
+    reset_ngx()
+    bot_challenge.reset()
+  end)
+
+  it("challenges every client of a location without trigger", function()
+    bot_challenge.access(challenge())
+
+    assert.equal(ngx.HTTP_FORBIDDEN, ngx.status)
+    assert.equal("no-store", header["Cache-Control"])
+    assert.spy(ngx.print).was_called()
+    assert.spy(ngx.exit).was_called_with(ngx.HTTP_OK)
+  end)
+
+  it("rejects the requests which can't load the challenge page", function()
+    ngx.req.get_method = function() return "POST" end
+
+    bot_challenge.access(challenge())
+
+    assert.spy(ngx.print).was_not_called()
+    assert.spy(ngx.exit).was_called_with(ngx.HTTP_FORBIDDEN)
+  end)
+
+  it("lets the clients with a valid clearance through", function()
+    local c = challenge()
+    local expires = NOW + 60
+    var["cookie_" .. bot_challenge.COOKIE] = expires .. "." .. bot_challenge.sign("s3cr3t", c, expires)
+
+    bot_challenge.access(c)
+
+    assert.spy(ngx.exit).was_not_called()
+  end)
+
+  it("challenges the clients with a clearance of another address", function()
+    local c = challenge()
+    local expires = NOW + 60
+    var["cookie_" .. bot_challenge.COOKIE] = expires .. "." .. bot_challenge.sign("s3cr3t", c, expires)
+    var.remote_addr = "203.0.113.8"
+
+    bot_challenge.access(c)
+
+    assert.spy(ngx.exit).was_called_with(ngx.HTTP_OK)
+  end)
+
+  it("requires the proof of work of the difficulty", function()
+    local c = challenge({ mode = "pow", difficulty = 8 })
+    local expires = NOW + 60
+    local value = expires .. "." .. bot_challenge.sign("s3cr3t", c, expires)
+
+    local sha256 = require("resty.sha256")
+    local nonce = 0
+    while true do
+      local sha = sha256:new()
+      sha:update(value .. "." .. nonce)
+      if bot_challenge.leading_zero_bits(sha:final()) >= 8 then
+        break
+      end
+      nonce = nonce + 1
+    end
+
+    var["cookie_" .. bot_challenge.COOKIE] = value
+    bot_challenge.access(c)
+    assert.spy(ngx.exit).was_called(1)
+
+    var["cookie_" .. bot_challenge.COOKIE] = value .. "." .. nonce
+    bot_challenge.access(c)
+    assert.spy(ngx.exit).was_called(1)
+  end)
+
+  it("only challenges the clients matching a trigger", function()
+    local c = challenge({ user_agents = { "^curl/" }, asns = { "16509" } })
+
+    bot_challenge.access(c)
+    assert.spy(ngx.exit).was_not_called()
+
+    var.geoip2_asn = "16509"
+    bot_challenge.access(c)
+    assert.spy(ngx.exit).was_called(1)
+
+    var.geoip2_asn = nil
+    var.http_user_agent = "curl/7.64.0"
+    bot_challenge.access(c)
+    assert.spy(ngx.exit).was_called(2)
+  end)
+
+  it("challenges the clients exceeding the request rate", function()
+    local counters = {}
+    ngx.shared = {
+      bot_challenge = {
+        incr = function(_, key, value, init)
+          counters[key] = (counters[key] or init) + value
+          return counters[key]
+        end,
+      },
+    }
+
+    local c = challenge({ rate = 2 })
+    for _ = 1, 2 do
+      bot_challenge.access(c)
+    end
+    assert.spy(ngx.exit).was_not_called()
+
+    bot_challenge.access(c)
+    assert.spy(ngx.exit).was_called(1)
+  end)
+
+  it("never challenges the exempt clients", function()
+    local c = challenge({ exempt_cidrs = { "203.0.113.0/24" }, exempt_user_agents = { "Googlebot" } })
+
+    bot_challenge.access(c)
+    var.remote_addr = "198.51.100.1"
+    var.http_user_agent = "Mozilla/5.0 (compatible; Googlebot/2.1)"
+    bot_challenge.access(c)
+
+    assert.spy(ngx.exit).was_not_called()
+  end)
+
+  it("lets the clients through without key", function()
+    configuration.get_bot_challenge_data = function() return nil end
+
+    bot_challenge.access(challenge())
+
+    assert.spy(ngx.exit).was_not_called()
+  end)
+end)
@@ -0,0 +1,105 @@
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("canonical_path", function()
+  local canonical_path = require("canonical_path")
+
+  local function mock_request(method, request_uri, uri, args)
+    local req_mock = { get_method = function() return method end }
+    stub(req_mock, "set_uri")
+
+    local ctx = {}
+    mock_ngx({
+      req = req_mock,
+      ctx = ctx,
+      var = { request_uri = request_uri, uri = uri, args = args },
+    })
+    stub(ngx, "redirect")
+
+    return req_mock, ctx
+  end
+
+  local function config(merge_slashes, trailing_slash, normalize_percent_encoding)
+    return {
+      merge_slashes = merge_slashes or "",
+      trailing_slash = trailing_slash or "",
+      normalize_percent_encoding = normalize_percent_encoding or false,
+    }
+  end
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  describe("trailing slash", function()
+    it("redirects the paths without a trailing slash", function()
+      mock_request("GET", "/app?page=2", "/app", "page=2")
+      canonical_path.rewrite(config("", "add"))
+      assert.stub(ngx.redirect).was_called_with("/app/?page=2", 301)
+    end)
+
+    it("redirects the paths with trailing slashes", function()
+      mock_request("POST", "/app//", "/app/")
+      canonical_path.rewrite(config("", "remove"))
+      assert.stub(ngx.redirect).was_called_with("/app", 308)
+    end)
+
+    it("does not redirect the canonical paths", function()
+      mock_request("GET", "/app/", "/app/")
+      canonical_path.rewrite(config("", "add"))
+      mock_request("GET", "/", "/")
+      canonical_path.rewrite(config("", "remove"))
+      assert.stub(ngx.redirect).was_not_called()
+    end)
+  end)
+
+  describe("merge slashes", function()
+    it("proxies the merged path", function()
+      local req_mock = mock_request("GET", "/app//users", "/app/users")
+      canonical_path.rewrite(config("on"))
+      assert.stub(req_mock.set_uri).was_called_with("/app/users")
+    end)
+
+    it("chooses the location again with the slashes of the request", function()
+      local req_mock, ctx = mock_request("GET", "/app//users", "/app/users")
+      canonical_path.rewrite(config("off"))
+      assert.stub(req_mock.set_uri).was_called_with("/app//users", true)
+      assert.is_true(ctx.canonical_path_unmerged)
+    end)
+
+    it("chooses the location again only once", function()
+      local req_mock, ctx = mock_request("GET", "/app//users", "/app//users")
+      ctx.canonical_path_unmerged = true
+      canonical_path.rewrite(config("off"))
+      assert.stub(req_mock.set_uri).was_not_called()
+    end)
+
+    it("keeps the location of the paths with dot segments", function()
+      local req_mock = mock_request("GET", "/app//../users", "/users")
+      canonical_path.rewrite(config("off"))
+      assert.stub(req_mock.set_uri).was_not_called()
+    end)
+  end)
+
+  describe("percent-encoding", function()
+    it("proxies the path decoded to choose the location", function()
+      local req_mock = mock_request("GET", "/app/%7Euser/caf%C3%A9", "/app/~user/café")
+      canonical_path.rewrite(config("", "", true))
+      assert.stub(req_mock.set_uri).was_called_with("/app/~user/café")
+    end)
+
+    it("ignores the paths without percent-encoding", function()
+      local req_mock = mock_request("GET", "/app/users?q=%7E", "/app/users", "q=%7E")
+      canonical_path.rewrite(config("", "", true))
+      assert.stub(req_mock.set_uri).was_not_called()
+    end)
+  end)
+end)
@@ -1,5 +1,6 @@
 local certificate = require("certificate")
 local ssl = require("ngx.ssl")
+local ocsp = require("ngx.ocsp")
 
 local function read_file(path)
   local file = assert(io.open(path, "rb"))
@@ -87,6 +88,47 @@ describe("Certificate", function()
       assert_certificate_is_set(EXAMPLE_CERT)
     end)
 
+    it("staples the OCSP response of the certificate", function()
+      ssl.server_name = function() return "sub.hostname", nil end
+      ocsp.set_ocsp_status_resp = function(resp) return true end
+      ngx.shared.certificate_data:set("*.hostname", EXAMPLE_CERT)
+      ngx.shared.certificate_data:set("ocsp:*.hostname", "OCSP response")
+
+      spy.on(ocsp, "set_ocsp_status_resp")
+
+      assert_certificate_is_set(EXAMPLE_CERT)
+      assert.spy(ocsp.set_ocsp_status_resp).was_called_with("OCSP response")
+    end)
+
+    it("does not staple an OCSP response when the certificate has none", function()
+      ngx.shared.certificate_data:set("hostname", EXAMPLE_CERT)
+
+      spy.on(ocsp, "set_ocsp_status_resp")
+
+      assert_certificate_is_set(EXAMPLE_CERT)
+      assert.spy(ocsp.set_ocsp_status_resp).was_not_called()
+    end)
+
+    it("sets the ECDSA certificate and key after the RSA ones", function()
+      ngx.shared.certificate_data:set("hostname", EXAMPLE_CERT)
+      ngx.shared.certificate_data:set("ecdsa:hostname", DEFAULT_CERT)
+
+      assert_certificate_is_set(EXAMPLE_CERT)
+      assert.spy(ssl.set_der_cert).was_called_with(ssl.cert_pem_to_der(DEFAULT_CERT))
+      assert.spy(ssl.set_der_priv_key).was_called_with(ssl.priv_key_pem_to_der(DEFAULT_CERT))
+    end)
+
+    it("does not staple the OCSP response of the RSA certificate with an ECDSA certificate", function()
+      ngx.shared.certificate_data:set("hostname", EXAMPLE_CERT)
+      ngx.shared.certificate_data:set("ecdsa:hostname", DEFAULT_CERT)
+      ngx.shared.certificate_data:set("ocsp:hostname", "OCSP response")
+
+      spy.on(ocsp, "set_ocsp_status_resp")
+
+      assert_certificate_is_set(EXAMPLE_CERT)
+      assert.spy(ocsp.set_ocsp_status_resp).was_not_called()
+    end)
+
     it("logs error message when certificate in dictionary is invalid", function()
       ngx.shared.certificate_data:set("hostname", "something invalid")
 
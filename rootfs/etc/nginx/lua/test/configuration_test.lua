@@ -154,10 +154,69 @@ describe("Configuration", function()
                     assert.has_no.errors(configuration.call)
                     assert.equal(ngx.status, ngx.HTTP_CREATED)
                 end)
+
+                it("increases the version of the backends", function()
+                    local version = configuration.get_backends_version() or 0
+                    assert.has_no.errors(configuration.call)
+                    assert.equal(version + 1, configuration.get_backends_version())
+                end)
             end)
         end)
     end)
 
+    describe("Endpoints", function()
+        before_each(function()
+            ngx.shared.configuration_data:set("backends", cjson.encode(get_backends()))
+            ngx.var.request_method = "POST"
+            ngx.var.request_uri = "/configuration/endpoints"
+        end)
+
+        it("replaces the endpoints of the posted backends", function()
+            local endpoints = { { address = "10.184.7.42", port = "7070" } }
+            ngx.req.get_body_data = function()
+                return cjson.encode({ { name = "my-dummy-backend-2", endpoints = endpoints } })
+            end
+            local version = configuration.get_backends_version() or 0
+
+            assert.has_no.errors(configuration.call)
+            assert.equal(ngx.HTTP_CREATED, ngx.status)
+            assert.equal(version + 1, configuration.get_backends_version())
+
+            local expected = get_backends()
+            expected[2].endpoints = endpoints
+            assert.same(expected, cjson.decode(configuration.get_backends_data()))
+        end)
+
+        it("returns a status of 404 when a backend does not exist", function()
+            ngx.req.get_body_data = function()
+                return cjson.encode({ { name = "unknown-backend", endpoints = {} } })
+            end
+
+            assert.has_no.errors(configuration.call)
+            assert.equal(ngx.HTTP_NOT_FOUND, ngx.status)
+            assert.equal(cjson.encode(get_backends()), configuration.get_backends_data())
+        end)
+
+        it("returns a status of 400 when no backend is configured", function()
+            ngx.shared.configuration_data:delete("backends")
+            ngx.req.get_body_data = function()
+                return cjson.encode({ { name = "my-dummy-backend-2", endpoints = {} } })
+            end
+
+            assert.has_no.errors(configuration.call)
+            assert.equal(ngx.HTTP_BAD_REQUEST, ngx.status)
+        end)
+
+        it("should not accept non POST methods", function()
+            ngx.var.request_method = "GET"
+
+            local s = spy.on(ngx, "print")
+            assert.has_no.errors(configuration.call)
+            assert.spy(s).was_called_with("Only POST requests are allowed!")
+            assert.same(ngx.status, ngx.HTTP_BAD_REQUEST)
+        end)
+    end)
+
     describe("handle_servers()", function()
         it("should not accept non POST methods", function()
             ngx.var.request_method = "GET"
@@ -206,6 +265,58 @@ describe("Configuration", function()
             assert.same(ngx.status, ngx.HTTP_CREATED)
         end)
 
+        it("stores the OCSP responses of the certificates", function()
+            ngx.var.request_method = "POST"
+            certificate_data:set("ocsp:hostname2", "previous OCSP response")
+            local mock_servers = cjson.encode({
+                {
+                    hostname = "hostname",
+                    sslCert = {
+                        pemCertKey = "pemCertKey",
+                        ocspResponse = ngx.encode_base64("OCSP response")
+                    }
+                },
+                {
+                    hostname = "hostname2",
+                    sslCert = {
+                        pemCertKey = "pemCertKey2"
+                    }
+                }
+            })
+            ngx.req.get_body_data = function() return mock_servers end
+
+            assert.has_no.errors(configuration.handle_servers)
+            assert.equal("OCSP response", configuration.get_ocsp_response("hostname"))
+            assert.is_nil(configuration.get_ocsp_response("hostname2"))
+            assert.same(ngx.HTTP_CREATED, ngx.status)
+        end)
+
+        it("stores the ECDSA certificates next to the RSA certificates", function()
+            ngx.var.request_method = "POST"
+            certificate_data:set("ecdsa:hostname2", "previous ECDSA pemCertKey")
+            local mock_servers = cjson.encode({
+                {
+                    hostname = "hostname",
+                    sslCert = {
+                        pemCertKey = "pemCertKey",
+                        ecdsa = { pemCertKey = "ECDSA pemCertKey" }
+                    }
+                },
+                {
+                    hostname = "hostname2",
+                    sslCert = {
+                        pemCertKey = "pemCertKey2"
+                    }
+                }
+            })
+            ngx.req.get_body_data = function() return mock_servers end
+
+            assert.has_no.errors(configuration.handle_servers)
+            assert.equal("ECDSA pemCertKey", configuration.get_ecdsa_pem_cert_key("hostname"))
+            assert.is_nil(configuration.get_ecdsa_pem_cert_key("hostname2"))
+            assert.same(ngx.HTTP_CREATED, ngx.status)
+        end)
+
         it("should log an err and set status to Internal Server Error when a certificate cannot be set", function()
             ngx.var.request_method = "POST"
             ngx.shared.certificate_data.set = function(self, data) return false, "error", nil end
@@ -264,4 +375,57 @@ describe("Configuration", function()
             assert.same(ngx.HTTP_CREATED, ngx.status)
         end)
     end)
+
+    describe("Canary weights", function()
+        before_each(function()
+            ngx.shared.canary_weights:flush_all()
+            ngx.var.request_uri = "/configuration/canary-weights"
+        end)
+
+        it("stores the posted weight of the backend", function()
+            ngx.var.request_method = "POST"
+            ngx.req.get_body_data = function() return cjson.encode({ backend = "default-canary-80", weight = 30 }) end
+
+            assert.has_no.errors(configuration.call)
+            assert.equal(ngx.HTTP_CREATED, ngx.status)
+            assert.equal(30, configuration.get_canary_weight("default-canary-80"))
+        end)
+
+        it("removes the weight of the backend when the weight is null", function()
+            ngx.shared.canary_weights:set("default-canary-80", 30)
+            ngx.var.request_method = "POST"
+            ngx.req.get_body_data = function() return '{"backend":"default-canary-80","weight":null}' end
+
+            assert.has_no.errors(configuration.call)
+            assert.equal(ngx.HTTP_CREATED, ngx.status)
+            assert.is_nil(configuration.get_canary_weight("default-canary-80"))
+        end)
+
+        it("returns a status of 400 when the weight is out of range", function()
+            ngx.var.request_method = "POST"
+            ngx.req.get_body_data = function() return cjson.encode({ backend = "default-canary-80", weight = 101 }) end
+
+            assert.has_no.errors(configuration.call)
+            assert.equal(ngx.HTTP_BAD_REQUEST, ngx.status)
+            assert.is_nil(configuration.get_canary_weight("default-canary-80"))
+        end)
+
+        it("returns a status of 400 when the backend is missing", function()
+            ngx.var.request_method = "POST"
+            ngx.req.get_body_data = function() return cjson.encode({ weight = 10 }) end
+
+            assert.has_no.errors(configuration.call)
+            assert.equal(ngx.HTTP_BAD_REQUEST, ngx.status)
+        end)
+
+        it("returns the configured weights", function()
+            ngx.shared.canary_weights:set("default-canary-80", 30)
+            ngx.var.request_method = "GET"
+
+            local s = spy.on(ngx, "print")
+            assert.has_no.errors(configuration.call)
+            assert.equal(ngx.HTTP_OK, ngx.status)
+            assert.spy(s).was_called_with(cjson.encode({ ["default-canary-80"] = 30 }))
+        end)
+    end)
 end)
@@ -0,0 +1,103 @@
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("coraza", function()
+  local coraza = require("coraza")
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  describe("access()", function()
+    local function mock_capture(status)
+      local captured = {}
+      local exit = spy.new(function() end)
+
+      mock_ngx({
+        var = { request_uri = "/login?next=/", remote_addr = "10.0.0.1", request_id = "abc123" },
+        req = { read_body = function() end, get_method = function() return "POST" end },
+        location = {
+          capture = function(uri, options)
+            captured.uri = uri
+            captured.options = options
+            return { status = status }
+          end,
+        },
+        exit = exit,
+      })
+
+      return captured, exit
+    end
+
+    it("sends the original request to the Coraza proxy", function()
+      local captured, exit = mock_capture(200)
+
+      coraza.access({ owasp_core_rules = true, transaction_id = "tx-$request_id" })
+
+      assert.equal("/_coraza-waf", captured.uri)
+      assert.equal(ngx.HTTP_POST, captured.options.method)
+      assert.is_true(captured.options.always_forward_body)
+      assert.same({
+        method = "POST",
+        uri = "/login?next=/",
+        remote_addr = "10.0.0.1",
+        owasp_core_rules = true,
+        transaction_id = "tx-abc123",
+      }, captured.options.ctx.coraza)
+      assert.spy(exit).was_not_called()
+    end)
+
+    it("rejects the request with the status returned by Coraza", function()
+      local _, exit = mock_capture(403)
+
+      coraza.access({ owasp_core_rules = false, transaction_id = "" })
+
+      assert.spy(exit).was_called_with(403)
+    end)
+
+    it("fails when Coraza is not available", function()
+      local _, exit = mock_capture(502)
+
+      coraza.access({ owasp_core_rules = false, transaction_id = "" })
+
+      assert.spy(exit).was_called_with(ngx.HTTP_INTERNAL_SERVER_ERROR)
+    end)
+  end)
+
+  describe("rewrite()", function()
+    it("sets the headers of the original request", function()
+      local set_header = spy.new(function() end)
+      mock_ngx({
+        ctx = {
+          coraza = { method = "PUT", uri = "/items/1", remote_addr = "10.0.0.1", owasp_core_rules = true, transaction_id = "" },
+        },
+        req = { set_header = set_header },
+      })
+
+      coraza.rewrite()
+
+      assert.spy(set_header).was_called_with("X-Original-Method", "PUT")
+      assert.spy(set_header).was_called_with("X-Original-URI", "/items/1")
+      assert.spy(set_header).was_called_with("X-Forwarded-For", "10.0.0.1")
+      assert.spy(set_header).was_called_with("X-Coraza-OWASP-CRS", "on")
+      assert.spy(set_header).was_not_called_with("X-Coraza-Transaction-ID", "")
+    end)
+
+    it("rejects the requests not sent by access()", function()
+      local exit = spy.new(function() end)
+      mock_ngx({ ctx = {}, exit = exit })
+
+      coraza.rewrite()
+
+      assert.spy(exit).was_called_with(ngx.HTTP_FORBIDDEN)
+    end)
+  end)
+end)
@@ -0,0 +1,132 @@
+local cjson = require("cjson.safe")
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("debug_headers", function()
+  local configuration = require("configuration")
+  local debug_headers = require("debug_headers")
+  local original_get_debug_token_data = configuration.get_debug_token_data
+
+  local key = "s3cr3t"
+  local host = "example.com"
+
+  before_each(function()
+    configuration.get_debug_token_data = function()
+      return cjson.encode({ key = key, maxTTL = 600 })
+    end
+  end)
+
+  after_each(function()
+    configuration.get_debug_token_data = original_get_debug_token_data
+    reset_ngx()
+  end)
+
+  local function token(expires)
+    return expires .. "." .. debug_headers.sign(key, host, expires)
+  end
+
+  describe("is_valid()", function()
+    it("accepts a token signed with the key", function()
+      assert.is_true(debug_headers.is_valid(token(ngx.time() + 60), host))
+    end)
+
+    it("rejects expired tokens and tokens valid for too long", function()
+      assert.is_false(debug_headers.is_valid(token(ngx.time() - 1), host))
+      assert.is_false(debug_headers.is_valid(token(ngx.time() + 601), host))
+    end)
+
+    it("rejects tokens for another host or with an invalid signature", function()
+      assert.is_false(debug_headers.is_valid(token(ngx.time() + 60), "other.example.com"))
+      assert.is_false(debug_headers.is_valid((ngx.time() + 60) .. ".invalid", host))
+      assert.is_false(debug_headers.is_valid("invalid", host))
+    end)
+
+    it("rejects all the tokens without key", function()
+      configuration.get_debug_token_data = function()
+        return cjson.encode({ key = "", maxTTL = 600 })
+      end
+      assert.is_false(debug_headers.is_valid(token(ngx.time() + 60), host))
+    end)
+  end)
+
+  describe("rewrite()", function()
+    it("enables the debug headers and removes the token from the request", function()
+      local clear_header = spy.new(function() end)
+      local ctx = {}
+      mock_ngx({
+        ctx = ctx,
+        var = { host = host },
+        req = {
+          get_headers = function() return { ["X-Debug-Token"] = token(ngx.time() + 60) } end,
+          clear_header = clear_header,
+        },
+      })
+
+      debug_headers.rewrite()
+
+      assert.is_true(ctx.debug_headers)
+      assert.spy(clear_header).was_called_with("X-Debug-Token")
+    end)
+
+    it("does not enable the debug headers with an invalid token", function()
+      local ctx = {}
+      mock_ngx({
+        ctx = ctx,
+        var = { host = host },
+        req = {
+          get_headers = function() return { ["X-Debug-Token"] = "1.invalid" } end,
+          clear_header = function() end,
+        },
+      })
+
+      debug_headers.rewrite()
+
+      assert.is_nil(ctx.debug_headers)
+    end)
+  end)
+
+  describe("header_filter()", function()
+    it("sets the debug headers", function()
+      local header = {}
+      mock_ngx({
+        ctx = { debug_headers = true },
+        header = header,
+        var = {
+          proxy_upstream_name = "default-web-80",
+          proxy_alternative_upstream_name = "",
+          upstream_addr = "10.0.0.1:8080",
+          canary_variant = "stable",
+          canary_weight = "20",
+          upstream_cache_status = "HIT",
+        },
+      })
+
+      debug_headers.header_filter()
+
+      assert.are.same({
+        ["X-Debug-Upstream"] = "default-web-80",
+        ["X-Debug-Upstream-Addr"] = "10.0.0.1:8080",
+        ["X-Debug-Canary"] = "stable; weight=20",
+        ["X-Debug-Cache-Status"] = "HIT",
+      }, header)
+    end)
+
+    it("does nothing without a valid token", function()
+      local header = {}
+      mock_ngx({ ctx = {}, header = header, var = { proxy_upstream_name = "default-web-80" } })
+
+      debug_headers.header_filter()
+
+      assert.are.same({}, header)
+    end)
+  end)
+end)
@@ -0,0 +1,70 @@
+_G._TEST = true
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local function read_file(path)
+  local file = assert(io.open(path, "rb"))
+  local content = file:read("*a")
+  file:close()
+  return content
+end
+
+local CLIENT_CERT = read_file("rootfs/etc/nginx/lua/test/fixtures/client-cert.pem")
+-- sha256 of the DER encoding of the fixture
+local CLIENT_CERT_HASH = "f209b91027e4d3d5c284762e64c152b6d3cd396c04ea9a5028891179a7b80a16"
+
+describe("forwarded_client_cert", function()
+  local forwarded_client_cert = require("forwarded_client_cert")
+
+  local var
+
+  before_each(function()
+    var = {
+      ssl_client_verify = "SUCCESS",
+      ssl_client_raw_cert = CLIENT_CERT,
+      ssl_client_escaped_cert = ngx.escape_uri(CLIENT_CERT),
+      ssl_client_s_dn = "CN=client,O=Example",
+    }
+    mock_ngx({ var = var })
+  end)
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  it("returns the URI subject alternative names", function()
+    local der = forwarded_client_cert.pem_to_der(CLIENT_CERT)
+    assert.same({ "spiffe://cluster.local/ns/default/sa/client" }, forwarded_client_cert.uri_sans(der))
+  end)
+
+  it("returns the elements in the order of Envoy", function()
+    assert.equal(
+      'Hash=' .. CLIENT_CERT_HASH .. ';Subject="CN=client,O=Example";URI=spiffe://cluster.local/ns/default/sa/client',
+      forwarded_client_cert.header({ "URI", "Subject", "Hash" }))
+  end)
+
+  it("quotes the certificate and the subject", function()
+    var.ssl_client_s_dn = 'CN=client "test",O=Example'
+
+    assert.equal('Cert="' .. var.ssl_client_escaped_cert .. '";Subject="CN=client \\"test\\",O=Example"',
+      forwarded_client_cert.header({ "Cert", "Subject" }))
+  end)
+
+  it("returns an empty value when the certificate is not verified", function()
+    var.ssl_client_verify = "FAILED:unable to verify the first certificate"
+    assert.equal("", forwarded_client_cert.header({ "Hash" }))
+
+    var.ssl_client_verify = "NONE"
+    var.ssl_client_raw_cert = ""
+    assert.equal("", forwarded_client_cert.header({ "Hash" }))
+  end)
+end)
@@ -1,4 +1,17 @@
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
 describe("lua_ingress", function()
+  local lua_ingress = require("lua_ingress")
+
   it("patches math.randomseed to not be called more than once per worker", function()
     local s = spy.on(ngx, "log")
 
@@ -6,4 +19,92 @@ describe("lua_ingress", function()
     assert.spy(s).was_called_with(ngx.WARN,
       string.format("ignoring math.randomseed(%d) since PRNG is already seeded for worker %d", 100, ngx.worker.pid()))
   end)
+
+  describe("rewrite", function()
+    local function mock_request(uri, request_uri)
+      mock_ngx({
+        var = {
+          scheme = "http",
+          server_port = "80",
+          http_host = "example.com:30080",
+          uri = uri,
+          request_uri = request_uri or uri,
+        },
+      })
+    end
+
+    local function location_config(config)
+      local new_config = {
+        force_ssl_redirect = true,
+        use_port_in_redirects = false,
+        ssl_redirect_code = 308,
+        ssl_redirect_port = 0,
+        ssl_redirect_exclude_paths = {},
+      }
+      for k, v in pairs(config or {}) do
+        new_config[k] = v
+      end
+      return new_config
+    end
+
+    before_each(function()
+      -- ngx.redirect is cached when the module is loaded
+      stub(ngx, "redirect")
+      package.loaded["lua_ingress"] = nil
+      lua_ingress = require("lua_ingress")
+
+      lua_ingress.set_config({
+        use_forwarded_headers = false,
+        is_ssl_passthrough_enabled = false,
+        http_redirect_code = 308,
+        listen_ports = { ssl_proxy = "442", https = "443" },
+      })
+    end)
+
+    after_each(function()
+      reset_ngx()
+    end)
+
+    it("redirects to HTTPS without the port of the request", function()
+      mock_request("/app", "/app?page=2")
+      lua_ingress.rewrite(location_config())
+      assert.stub(ngx.redirect).was_called_with("https://example.com/app?page=2", 308)
+    end)
+
+    it("redirects with the status code of the location", function()
+      mock_request("/app")
+      lua_ingress.rewrite(location_config({ ssl_redirect_code = 301 }))
+      assert.stub(ngx.redirect).was_called_with("https://example.com/app", 301)
+    end)
+
+    it("redirects to the HTTPS port exposed to the clients", function()
+      mock_request("/app")
+      lua_ingress.rewrite(location_config({ ssl_redirect_port = 30443, use_port_in_redirects = true }))
+      assert.stub(ngx.redirect).was_called_with("https://example.com:30443/app", 308)
+
+      mock_request("/app")
+      lua_ingress.rewrite(location_config({ ssl_redirect_port = 443 }))
+      assert.stub(ngx.redirect).was_called_with("https://example.com/app", 308)
+    end)
+
+    it("redirects to the HTTPS port of the controller", function()
+      mock_request("/app")
+      lua_ingress.rewrite(location_config({ use_port_in_redirects = true }))
+      assert.stub(ngx.redirect).was_called_with("https://example.com:443/app", 308)
+    end)
+
+    it("does not redirect the excluded paths", function()
+      local config = location_config({ ssl_redirect_exclude_paths = { "/.well-known/acme-challenge/", "/healthz" } })
+
+      mock_request("/.well-known/acme-challenge/token")
+      lua_ingress.rewrite(config)
+      mock_request("/healthz")
+      lua_ingress.rewrite(config)
+      assert.stub(ngx.redirect).was_not_called()
+
+      mock_request("/app/healthz")
+      lua_ingress.rewrite(config)
+      assert.stub(ngx.redirect).was_called_with("https://example.com/app/healthz", 308)
+    end)
+  end)
 end)
@@ -41,6 +41,70 @@ describe("Monitor", function()
     assert.equal(10, #monitor.get_metrics_batch())
   end)
 
+  it("returns the connect times of the new connections with TLS backends", function()
+    local monitor = require("monitor")
+
+    mock_ngx({ var = { proxy_upstream_tls = "1", upstream_connect_time = "0.005" } })
+    monitor.call()
+    mock_ngx({ var = { proxy_upstream_tls = "", upstream_connect_time = "0.005" } })
+    monitor.call()
+    mock_ngx({ var = { proxy_upstream_tls = "1", upstream_connect_time = "0.000" } })
+    monitor.call()
+
+    local batch = monitor.get_metrics_batch()
+    assert.same({ 0.005 }, batch[1].upstreamTLSConnectTimes)
+    assert.equal(0.005, batch[1].upstreamLatency)
+    assert.is_nil(batch[2].upstreamTLSConnectTimes)
+    assert.is_nil(batch[3].upstreamTLSConnectTimes)
+  end)
+
+  it("includes the retried requests", function()
+    local monitor = require("monitor")
+
+    mock_ngx({ var = {
+      proxy_upstream_tls = "1",
+      upstream_connect_time = "0.003, 0.000 : 0.004",
+      upstream_response_time = "0.010, 0.020 : 0.030",
+      upstream_response_length = "0, 0 : 512",
+    } })
+    monitor.call()
+
+    local batch = monitor.get_metrics_batch()
+    assert.same({ 0.003, 0.004 }, batch[1].upstreamTLSConnectTimes)
+    assert.equal(0.004, batch[1].upstreamLatency)
+    assert.equal(0.03, batch[1].upstreamResponseTime)
+    assert.equal(512, batch[1].upstreamResponseLength)
+  end)
+
+  it("records the bodies buffered to temporary files", function()
+    local monitor = require("monitor")
+
+    mock_ngx({ var = { request_body_file = "/tmp/client-body/0000000001", content_length = "1048576",
+      proxy_buffers_size = "36864", upstream_response_length = "40960" } })
+    monitor.call()
+    mock_ngx({ var = { proxy_buffers_size = "36864", upstream_response_length = "4096" } })
+    monitor.call()
+
+    local batch = monitor.get_metrics_batch()
+    assert.equal(1048576, batch[1].requestBodyTempFileSize)
+    assert.equal(4096, batch[1].proxyBuffersExcess)
+    assert.is_nil(batch[2].requestBodyTempFileSize)
+    assert.is_nil(batch[2].proxyBuffersExcess)
+  end)
+
+  it("records the request normalization rules applied to the requests", function()
+    local monitor = require("monitor")
+
+    mock_ngx({ var = {}, ctx = { request_normalizations = { duplicate_headers = "normalized" } } })
+    monitor.call()
+    mock_ngx({ var = {}, ctx = {} })
+    monitor.call()
+
+    local batch = monitor.get_metrics_batch()
+    assert.are.same({ duplicate_headers = "normalized" }, batch[1].requestNormalizations)
+    assert.is_nil(batch[2].requestNormalizations)
+  end)
+
   describe("flush", function()
     it("short circuits when premmature is true (when worker is shutting down)", function()
       local tcp_mock = mock_ngx_socket_tcp()
@@ -72,6 +136,8 @@ describe("Monitor", function()
         ingress_name = "example",
         service_name = "http-svc",
         location_path = "/",
+        canary_variant = "canary",
+        canary_weight = "20",
 
         request_method = "GET",
         status = "200",
@@ -96,7 +162,7 @@ describe("Monitor", function()
 
       monitor.flush()
 
-      local expected_payload = '[{"host":"example.com","method":"GET","requestLength":256,"status":"200","upstreamResponseLength":456,"upstreamLatency":0.01,"upstreamResponseTime":0.02,"path":"\\/","requestTime":0.04,"ingress":"example","namespace":"default","service":"http-svc","responseLength":512},{"host":"example.com","method":"POST","requestLength":256,"status":"201","upstreamResponseLength":456,"upstreamLatency":0.01,"upstreamResponseTime":0.02,"path":"\\/","requestTime":0.04,"ingress":"example","namespace":"default","service":"http-svc","responseLength":512}]'
+      local expected_payload = '[{"host":"example.com","method":"GET","requestLength":256,"status":"200","upstreamResponseLength":456,"upstreamLatency":0.01,"upstreamResponseTime":0.02,"path":"\\/","canary":"canary","canaryWeight":20,"requestTime":0.04,"ingress":"example","namespace":"default","service":"http-svc","responseLength":512},{"host":"example.com","method":"POST","requestLength":256,"status":"201","upstreamResponseLength":456,"upstreamLatency":0.01,"upstreamResponseTime":0.02,"path":"\\/","canary":"canary","canaryWeight":20,"requestTime":0.04,"ingress":"example","namespace":"default","service":"http-svc","responseLength":512}]'
 
       assert.stub(tcp_mock.connect).was_called_with(tcp_mock, "unix:/tmp/prometheus-nginx.socket")
       assert.stub(tcp_mock.send).was_called_with(tcp_mock, expected_payload)
@@ -0,0 +1,58 @@
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("request_budget", function()
+  local request_budget = require("request_budget")
+
+  local var
+
+  before_each(function()
+    var = {}
+    mock_ngx({
+      var = var,
+      now = function() return 1000.5 end,
+      req = { start_time = function() return 1000 end },
+    })
+  end)
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  it("returns the deadline of the budget from the start of the request", function()
+    assert.equal("1030000", request_budget.deadline(30))
+  end)
+
+  it("lowers the deadline to the deadline of the client", function()
+    var.http_x_request_deadline = "1010000"
+    assert.equal("1010000", request_budget.deadline(30))
+
+    var.http_x_request_deadline = "2000000"
+    assert.equal("1030000", request_budget.deadline(30))
+  end)
+
+  it("lowers the deadline to the grpc-timeout of the client", function()
+    var.http_grpc_timeout = "5S"
+    assert.equal("1005500", request_budget.deadline(30))
+
+    var.http_grpc_timeout = "2500m"
+    assert.equal("1003000", request_budget.deadline(30))
+
+    var.http_grpc_timeout = "invalid"
+    assert.equal("1030000", request_budget.deadline(30))
+  end)
+
+  it("returns the remaining time as a grpc-timeout", function()
+    assert.equal("29500m", request_budget.grpc_timeout("1030000"))
+    assert.equal("0m", request_budget.grpc_timeout("999000"))
+    assert.equal("99999999m", request_budget.grpc_timeout("999999999999"))
+  end)
+end)
@@ -0,0 +1,182 @@
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("request_normalization", function()
+  local request_normalization = require("request_normalization")
+
+  local default_config = {
+    reject_ambiguous_requests = false,
+    normalize_duplicate_headers = false,
+    max_headers = 0,
+    max_header_size = 0,
+    decode_path_traversal = false,
+  }
+
+  local function configure(config)
+    local new_config = {}
+    for k, v in pairs(default_config) do
+      new_config[k] = v
+    end
+    for k, v in pairs(config) do
+      new_config[k] = v
+    end
+    request_normalization.set_config(new_config)
+  end
+
+  local function mock_request(headers, request_uri, uri)
+    local req_mock = { get_headers = function() return headers end }
+    stub(req_mock, "set_header")
+    stub(req_mock, "set_uri")
+
+    local ctx = {}
+    mock_ngx({
+      req = req_mock,
+      ctx = ctx,
+      var = { request_uri = request_uri or "/", uri = uri or "/" },
+    })
+    stub(ngx, "exit")
+
+    return req_mock, ctx
+  end
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  describe("header limits", function()
+    it("rejects the requests with too many headers", function()
+      configure({ max_headers = 2 })
+
+      local _, ctx = mock_request({ host = "example.com", accept = { "text/html", "application/json" } })
+      request_normalization.rewrite()
+
+      assert.stub(ngx.exit).was_called_with(ngx.HTTP_BAD_REQUEST)
+      assert.are.same({ header_count = "rejected" }, ctx.request_normalizations)
+    end)
+
+    it("rejects the requests with a header too large", function()
+      configure({ max_header_size = 16 })
+
+      local _, ctx = mock_request({ host = "example.com", cookie = "session=0123456789" })
+      request_normalization.rewrite()
+
+      assert.stub(ngx.exit).was_called_with(ngx.HTTP_BAD_REQUEST)
+      assert.are.same({ header_size = "rejected" }, ctx.request_normalizations)
+    end)
+
+    it("accepts the requests within the limits", function()
+      configure({ max_headers = 2, max_header_size = 16 })
+
+      local _, ctx = mock_request({ host = "example.com", accept = "text/html" })
+      request_normalization.rewrite()
+
+      assert.stub(ngx.exit).was_not_called()
+      assert.is_nil(ctx.request_normalizations)
+    end)
+  end)
+
+  describe("ambiguous requests", function()
+    before_each(function()
+      configure({ reject_ambiguous_requests = true })
+    end)
+
+    it("rejects the requests with a Transfer-Encoding and a Content-Length", function()
+      mock_request({ ["transfer-encoding"] = "chunked", ["content-length"] = "42" })
+      request_normalization.rewrite()
+      assert.stub(ngx.exit).was_called_with(ngx.HTTP_BAD_REQUEST)
+    end)
+
+    it("rejects the requests with different Content-Length", function()
+      mock_request({ ["content-length"] = { "42", "24" } })
+      request_normalization.rewrite()
+      assert.stub(ngx.exit).was_called_with(ngx.HTTP_BAD_REQUEST)
+    end)
+
+    it("rejects the requests with a Transfer-Encoding other than chunked", function()
+      local _, ctx = mock_request({ ["transfer-encoding"] = { "chunked", "identity" } })
+      request_normalization.rewrite()
+      assert.stub(ngx.exit).was_called_with(ngx.HTTP_BAD_REQUEST)
+      assert.are.same({ ambiguous_framing = "rejected" }, ctx.request_normalizations)
+    end)
+
+    it("accepts the chunked requests and the identical Content-Length", function()
+      mock_request({ ["transfer-encoding"] = "Chunked" })
+      request_normalization.rewrite()
+      mock_request({ ["content-length"] = { "42", "42" } })
+      request_normalization.rewrite()
+      assert.stub(ngx.exit).was_not_called()
+    end)
+  end)
+
+  describe("duplicate headers", function()
+    before_each(function()
+      configure({ normalize_duplicate_headers = true })
+    end)
+
+    it("merges the values of the duplicate headers", function()
+      local req_mock, ctx = mock_request({ accept = { "text/html", "application/json" }, cookie = { "a=1", "b=2" } })
+      request_normalization.rewrite()
+
+      assert.stub(ngx.exit).was_not_called()
+      assert.stub(req_mock.set_header).was_called_with("accept", "text/html, application/json")
+      assert.stub(req_mock.set_header).was_called_with("cookie", "a=1; b=2")
+      assert.are.same({ duplicate_headers = "normalized" }, ctx.request_normalizations)
+    end)
+
+    it("rejects the duplicate headers allowing a single value", function()
+      local req_mock, ctx = mock_request({ accept = { "text/html", "application/json" }, host = { "a.com", "b.com" } })
+      request_normalization.rewrite()
+
+      assert.stub(ngx.exit).was_called_with(ngx.HTTP_BAD_REQUEST)
+      assert.stub(req_mock.set_header).was_not_called()
+      assert.are.same({ duplicate_headers = "rejected" }, ctx.request_normalizations)
+    end)
+  end)
+
+  describe("path traversal", function()
+    before_each(function()
+      configure({ decode_path_traversal = true })
+    end)
+
+    it("ignores the paths without encoded dot, slash or backslash", function()
+      local req_mock, ctx = mock_request({}, "/app/index.html?q=%2e%2e", "/app/index.html")
+      request_normalization.rewrite()
+
+      assert.stub(req_mock.set_uri).was_not_called()
+      assert.is_nil(ctx.request_normalizations)
+    end)
+
+    it("proxies the decoded path", function()
+      local req_mock, ctx = mock_request({}, "/app/%2E%2E/admin%2fusers", "/admin/users")
+      request_normalization.rewrite()
+
+      assert.stub(ngx.exit).was_not_called()
+      assert.stub(req_mock.set_uri).was_called_with("/admin/users")
+      assert.are.same({ path_traversal = "normalized" }, ctx.request_normalizations)
+    end)
+
+    it("rejects the paths traversing their parent directories once decoded again", function()
+      local req_mock, ctx = mock_request({}, "/app/%252e%252e/admin", "/app/%2e%2e/admin")
+      request_normalization.rewrite()
+
+      assert.stub(ngx.exit).was_called_with(ngx.HTTP_BAD_REQUEST)
+      assert.stub(req_mock.set_uri).was_not_called()
+      assert.are.same({ path_traversal = "rejected" }, ctx.request_normalizations)
+    end)
+
+    it("rejects the paths traversing with backslashes", function()
+      mock_request({}, "/app/..%5c..%5cetc", "/app/..\\..\\etc")
+      request_normalization.rewrite()
+
+      assert.stub(ngx.exit).was_called_with(ngx.HTTP_BAD_REQUEST)
+    end)
+  end)
+end)
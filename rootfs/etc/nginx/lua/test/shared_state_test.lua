@@ -0,0 +1,165 @@
+_G._TEST = true
+
+local shared_state
+local original_ngx = ngx
+
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+-- in memory Redis server supporting the commands used by shared_state
+local server
+local function fake_redis()
+  local red = { commands = {} }
+
+  function red.set_timeouts() end
+  function red.get_reused_times() return 0 end
+  function red.close() end
+  function red.set_keepalive() end
+
+  function red.connect()
+    if server.down then
+      return nil, "connection refused"
+    end
+    return true
+  end
+
+  function red.auth(_, password)
+    server.password = password
+    return "OK"
+  end
+
+  function red.init_pipeline(self)
+    self.commands = {}
+  end
+
+  function red.hset(self, key, field, value)
+    table.insert(self.commands, function()
+      server.data[key] = server.data[key] or {}
+      server.data[key][field] = value
+      return 1
+    end)
+  end
+
+  function red.hdel(self, key, field)
+    table.insert(self.commands, function()
+      if server.data[key] then
+        server.data[key][field] = nil
+      end
+      return 1
+    end)
+  end
+
+  function red.hgetall(self, key)
+    table.insert(self.commands, function()
+      local result = {}
+      for field, value in pairs(server.data[key] or {}) do
+        table.insert(result, field)
+        table.insert(result, value)
+      end
+      return result
+    end)
+  end
+
+  function red.commit_pipeline(self)
+    local results = {}
+    for i, command in ipairs(self.commands) do
+      results[i] = command()
+    end
+    return results
+  end
+
+  function red.array_to_hash(_, t)
+    local h = {}
+    for i = 1, #t, 2 do
+      h[t[i]] = t[i + 1]
+    end
+    return h
+  end
+
+  return red
+end
+
+local config = { backend = "redis://redis:6379", prefix = "ingress-nginx", sync_interval = 1, timeout = 100 }
+
+describe("shared_state", function()
+  before_each(function()
+    server = { data = {} }
+    package.loaded["resty.redis"] = { new = fake_redis }
+    package.loaded["shared_state"] = nil
+    shared_state = require("shared_state")
+
+    mock_ngx({ now = function() return 1000 end, timer = { at = function() end, every = function() end } })
+    shared_state.init_worker(config)
+    shared_state.register("canary_weights")
+  end)
+
+  after_each(function()
+    reset_ngx()
+    shared_state.reset()
+    package.loaded["resty.redis"] = nil
+  end)
+
+  describe("parse()", function()
+    it("parses the Redis URLs", function()
+      assert.same({ host = "redis", port = 6379, db = 0 }, shared_state.parse("redis://redis"))
+      assert.same({ host = "redis", port = 6380, password = "secret", db = 2 },
+        shared_state.parse("redis://:secret@redis:6380/2"))
+      assert.is_nil(shared_state.parse("memcached://cache:11211"))
+      assert.is_nil(shared_state.parse("redis://user@redis"))
+    end)
+  end)
+
+  it("is not available before the first sync", function()
+    assert.is_false(shared_state.available())
+    assert.is_nil(shared_state.get("canary_weights", "default-app-80"))
+  end)
+
+  it("writes the changes and reads the changes of the other replicas", function()
+    server.data["ingress-nginx:canary_weights"] = { ["default-api-80"] = "10" }
+
+    shared_state.set("canary_weights", "default-app-80", 20)
+    shared_state.sync()
+
+    assert.is_true(shared_state.available())
+    assert.equal("20", server.data["ingress-nginx:canary_weights"]["default-app-80"])
+    assert.equal("10", shared_state.get("canary_weights", "default-api-80"))
+    assert.equal("20", shared_state.get("canary_weights", "default-app-80"))
+    assert.same({ ["default-api-80"] = "10", ["default-app-80"] = "20" }, shared_state.get_all("canary_weights"))
+
+    shared_state.delete("canary_weights", "default-api-80")
+    shared_state.sync()
+
+    assert.is_nil(server.data["ingress-nginx:canary_weights"]["default-api-80"])
+    assert.is_nil(shared_state.get("canary_weights", "default-api-80"))
+  end)
+
+  it("keeps the changes while the backend is down", function()
+    server.down = true
+    shared_state.set("canary_weights", "default-app-80", 20)
+    shared_state.sync()
+
+    assert.equal(1, #shared_state.pending())
+    assert.is_false(shared_state.available())
+
+    server.down = false
+    shared_state.sync()
+
+    assert.equal(0, #shared_state.pending())
+    assert.equal("20", server.data["ingress-nginx:canary_weights"]["default-app-80"])
+  end)
+
+  it("is not available when the backend stopped responding", function()
+    shared_state.sync()
+    assert.is_true(shared_state.available())
+
+    mock_ngx({ now = function() return 1004 end })
+    assert.is_false(shared_state.available())
+  end)
+end)
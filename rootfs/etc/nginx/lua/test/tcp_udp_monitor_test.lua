@@ -0,0 +1,115 @@
+_G._TEST = true
+
+local cjson = require("cjson.safe")
+
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local function mock_ngx_socket_tcp()
+  local tcp_mock = { payloads = {} }
+  stub(tcp_mock, "connect", true)
+  tcp_mock.send = function(self, payload)
+    table.insert(self.payloads, payload)
+    return true
+  end
+  stub(tcp_mock, "close", true)
+
+  local socket_mock = {}
+  stub(socket_mock, "tcp", tcp_mock)
+  mock_ngx({ socket = socket_mock })
+
+  return tcp_mock
+end
+
+describe("TCP and UDP monitor", function()
+  after_each(function()
+    reset_ngx()
+    package.loaded["tcp_udp_monitor"] = nil
+  end)
+
+  it("batches metrics", function()
+    local tcp_udp_monitor = require("tcp_udp_monitor")
+    mock_ngx({ var = {} })
+
+    for i = 1,10,1 do
+      tcp_udp_monitor.call()
+    end
+
+    assert.equal(10, #tcp_udp_monitor.get_metrics_batch())
+  end)
+
+  it("ignores the connect time of the sessions retried on several upstream servers", function()
+    local tcp_udp_monitor = require("tcp_udp_monitor")
+
+    mock_ngx({ var = { upstream_connect_time = "0.005" } })
+    tcp_udp_monitor.call()
+    mock_ngx({ var = { upstream_connect_time = "-, 0.005" } })
+    tcp_udp_monitor.call()
+
+    local batch = tcp_udp_monitor.get_metrics_batch()
+    assert.equal(0.005, batch[1].upstreamLatency)
+    assert.equal(-1, batch[2].upstreamLatency)
+  end)
+
+  describe("flush", function()
+    it("short circuits when premmature is true (when worker is shutting down)", function()
+      local tcp_mock = mock_ngx_socket_tcp()
+      local tcp_udp_monitor = require("tcp_udp_monitor")
+      mock_ngx({ var = {} })
+
+      tcp_udp_monitor.call()
+      tcp_udp_monitor.flush(true)
+      assert.stub(tcp_mock.connect).was_not_called()
+    end)
+
+    it("short circuits when there's no metrics batched", function()
+      local tcp_mock = mock_ngx_socket_tcp()
+      local tcp_udp_monitor = require("tcp_udp_monitor")
+
+      tcp_udp_monitor.flush()
+      assert.stub(tcp_mock.connect).was_not_called()
+    end)
+
+    it("JSON encodes and sends the batched metrics", function()
+      local tcp_mock = mock_ngx_socket_tcp()
+      local tcp_udp_monitor = require("tcp_udp_monitor")
+
+      mock_ngx({ var = {
+        protocol = "TCP",
+        namespace = "default",
+        service_name = "postgres",
+        service_port = "5432",
+
+        status = "200",
+        bytes_sent = "4096",
+        bytes_received = "1024",
+        session_time = "12.500",
+
+        upstream_connect_time = "0.002",
+      } })
+      tcp_udp_monitor.call()
+
+      tcp_udp_monitor.flush()
+
+      local expected_payload = {
+        {
+          protocol = "TCP", namespace = "default", service = "postgres", port = "5432",
+          status = "200", bytesSent = 4096, bytesReceived = 1024, sessionTime = 12.5, upstreamLatency = 0.002,
+        },
+      }
+
+      assert.stub(tcp_mock.connect).was_called_with(tcp_mock, "unix:/tmp/prometheus-nginx.socket")
+      assert.equal(1, #tcp_mock.payloads)
+      assert.are.same(expected_payload, cjson.decode(tcp_mock.payloads[1]))
+      assert.stub(tcp_mock.close).was_called_with(tcp_mock)
+    end)
+  end)
+end)
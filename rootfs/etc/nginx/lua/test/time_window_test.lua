@@ -0,0 +1,81 @@
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+describe("time_window", function()
+  local time_window = require("time_window")
+
+  -- Monday 2019-07-01 10:30:00 UTC
+  local monday = 1561977000
+
+  local function mock_time(now)
+    mock_ngx({ time = function() return now end })
+    stub(ngx, "exit")
+  end
+
+  local function office_hours()
+    local hours, weekdays = {}, {}
+    for h = 9, 17 do hours[h] = true end
+    for d = 1, 5 do weekdays[d] = true end
+    return { hours = hours, weekdays = weekdays }
+  end
+
+  local function config(allowed, denied, offsets)
+    return {
+      status = 503,
+      offset = 0,
+      offsets = offsets or {},
+      allowed = allowed or {},
+      denied = denied or {},
+    }
+  end
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  it("allows the requests during the allowed windows", function()
+    mock_time(monday)
+    time_window.rewrite(config({ office_hours() }))
+    assert.stub(ngx.exit).was_not_called()
+  end)
+
+  it("denies the requests outside of the allowed windows", function()
+    mock_time(monday + 10 * 3600)
+    time_window.rewrite(config({ office_hours() }))
+    assert.stub(ngx.exit).was_called_with(503)
+  end)
+
+  it("denies the requests during the denied windows", function()
+    mock_time(monday)
+    time_window.rewrite(config({ office_hours() }, { { hours = { [10] = true } } }))
+    assert.stub(ngx.exit).was_called_with(503)
+  end)
+
+  it("uses the offset of the time zone", function()
+    -- 10:30 UTC is 20:30 in UTC+10 until the first offset ends
+    mock_time(monday)
+    time_window.rewrite(config({ office_hours() }, nil, { { ends = monday + 60, offset = 36000 } }))
+    assert.stub(ngx.exit).was_called_with(503)
+
+    mock_time(monday + 60)
+    time_window.rewrite(config({ office_hours() }, nil, { { ends = monday + 60, offset = 36000 } }))
+    assert.stub(ngx.exit).was_not_called()
+  end)
+
+  it("matches the day of month or the day of week", function()
+    -- Sunday 2019-06-30 is not allowed by day of week but by day of month
+    mock_time(monday - 24 * 3600)
+    local window = office_hours()
+    window.days = { [30] = true }
+    time_window.rewrite(config({ window }))
+    assert.stub(ngx.exit).was_not_called()
+  end)
+end)
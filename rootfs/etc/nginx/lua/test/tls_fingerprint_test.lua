@@ -0,0 +1,108 @@
+local original_ngx = ngx
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local JA3 = "e7d705a3286e19ea42f587b344ee6865"
+local JA4 = "t13d1516h2_8daaf6152771_e5627efa2ab1"
+local CONNECTION = "127.0.0.1:54321-127.0.0.1:442"
+
+describe("tls_fingerprint", function()
+  local tls_fingerprint = require("tls_fingerprint")
+
+  local var
+  local dict
+
+  before_each(function()
+    dict = { data = {} }
+    function dict.set(self, key, value) self.data[key] = value return true end
+    function dict.get(self, key) return self.data[key] end
+    function dict.delete(self, key) self.data[key] = nil end
+
+    var = { https = "on", realip_remote_addr = "127.0.0.1", realip_remote_port = "54321",
+      server_addr = "127.0.0.1", server_port = "442" }
+    mock_ngx({ var = var, shared = { tls_fingerprints = dict }, exit = spy.new(function() end),
+      sleep = spy.new(function() end) })
+  end)
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  it("sets the variables with the fingerprint of the connection", function()
+    assert.is_true(tls_fingerprint.set(CONNECTION, JA3, JA4))
+
+    tls_fingerprint.rewrite({ allowed = {}, denied = {} })
+
+    assert.equal(JA3, var.tls_ja3)
+    assert.equal(JA4, var.tls_ja4)
+    assert.spy(ngx.exit).was_not_called()
+  end)
+
+  it("ignores the plain HTTP requests", function()
+    tls_fingerprint.set(CONNECTION, JA3, JA4)
+    var.https = ""
+
+    tls_fingerprint.rewrite({ allowed = {}, denied = {} })
+
+    assert.is_nil(var.tls_ja3)
+  end)
+
+  it("denies the clients matching a denied fingerprint", function()
+    tls_fingerprint.set(CONNECTION, JA3, JA4)
+
+    tls_fingerprint.rewrite({ allowed = {}, denied = { JA4 } })
+
+    assert.spy(ngx.exit).was_called_with(ngx.HTTP_FORBIDDEN)
+  end)
+
+  it("only allows the clients matching an allowed fingerprint", function()
+    tls_fingerprint.set(CONNECTION, JA3, JA4)
+
+    tls_fingerprint.rewrite({ allowed = { JA3 }, denied = {} })
+    assert.spy(ngx.exit).was_not_called()
+
+    -- the fingerprint of another connection
+    var.realip_remote_port = "54322"
+    tls_fingerprint.rewrite({ allowed = { JA3 }, denied = {} })
+    assert.spy(ngx.exit).was_called_with(ngx.HTTP_FORBIDDEN)
+  end)
+
+  it("removes the fingerprint of a connection with an invalid Client Hello", function()
+    tls_fingerprint.set(CONNECTION, JA3, JA4)
+    assert.is_true(tls_fingerprint.set(CONNECTION, "", ""))
+
+    tls_fingerprint.rewrite({ allowed = {}, denied = {} })
+
+    assert.is_nil(var.tls_ja3)
+  end)
+
+  it("denies the connections without fingerprint when fingerprints are allowed", function()
+    tls_fingerprint.rewrite({ allowed = { JA3 }, denied = {} })
+
+    assert.spy(ngx.sleep).was_called()
+    assert.spy(ngx.exit).was_called_with(ngx.HTTP_FORBIDDEN)
+  end)
+
+  it("denies the requests when the fingerprints are disabled and fingerprints are allowed", function()
+    ngx.shared.tls_fingerprints = nil
+
+    tls_fingerprint.rewrite({ allowed = { JA3 }, denied = {} })
+
+    assert.spy(ngx.exit).was_called_with(ngx.HTTP_FORBIDDEN)
+  end)
+
+  it("returns an error when the fingerprints are disabled", function()
+    ngx.shared.tls_fingerprints = nil
+
+    local ok, err = tls_fingerprint.set(CONNECTION, JA3, JA4)
+    assert.is_nil(ok)
+    assert.equal("the TLS fingerprints are disabled", err)
+  end)
+end)
@@ -0,0 +1,131 @@
+_G._TEST = true
+
+local cjson = require("cjson.safe")
+
+local traffic_capture
+local original_ngx = ngx
+
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local function mock_request(now)
+  mock_ngx({
+    now = function() return now or 1000 end,
+    status = 200,
+    var = { host = "example.com", uri = "/search" },
+    req = {
+      start_time = function() return 999.5 end,
+      get_method = function() return "GET" end,
+      get_headers = function()
+        return { ["user-agent"] = "curl", ["authorization"] = "Bearer secret", ["accept"] = "*/*" }
+      end,
+      get_uri_args = function() return { q = "shoes", token = "secret" } end,
+      get_body_data = function() return "body" end,
+    },
+  })
+end
+
+describe("traffic_capture", function()
+  before_each(function()
+    package.loaded["traffic_capture"] = nil
+    traffic_capture = require("traffic_capture")
+  end)
+
+  after_each(function()
+    reset_ngx()
+  end)
+
+  describe("parse_sink()", function()
+    it("writes the files in the capture directory", function()
+      local sink = traffic_capture.parse_sink("file://replay.log")
+      assert.same({ scheme = "file", path = "/var/log/nginx/capture/replay.log" }, sink)
+    end)
+
+    it("returns nil for unsupported sinks", function()
+      assert.is_nil(traffic_capture.parse_sink("ftp://collector/replay"))
+    end)
+  end)
+
+  describe("allow()", function()
+    it("limits the number of records per second", function()
+      mock_request(1000)
+      assert.is_true(traffic_capture.allow("file://replay.log", 2))
+      assert.is_true(traffic_capture.allow("file://replay.log", 2))
+      assert.is_false(traffic_capture.allow("file://replay.log", 2))
+      assert.is_true(traffic_capture.allow("file://other.log", 2))
+
+      mock_request(1001)
+      assert.is_true(traffic_capture.allow("file://replay.log", 2))
+    end)
+  end)
+
+  describe("build_record()", function()
+    it("only captures the allowed headers and arguments", function()
+      mock_request()
+      local record = traffic_capture.build_record({ headers = { "user-agent" }, args = { "q" } })
+
+      assert.same({
+        time = 999.5,
+        method = "GET",
+        host = "example.com",
+        path = "/search",
+        status = 200,
+        headers = { ["user-agent"] = "curl" },
+        args = { q = "shoes" },
+      }, record)
+    end)
+
+    it("does not capture the body by default", function()
+      mock_request()
+      assert.is_nil(traffic_capture.build_record({}).body)
+      assert.equal(ngx.encode_base64("body"), traffic_capture.build_record({ body = true }).body)
+    end)
+  end)
+
+  describe("log()", function()
+    local config
+
+    before_each(function()
+      config = { sink = "file://replay.log", sample_rate = 1, max_rps = 100, headers = {}, args = {} }
+    end)
+
+    it("buffers the sampled requests", function()
+      mock_request()
+      traffic_capture.log(config)
+
+      local records = traffic_capture.buffers()[config.sink]
+      assert.equal(1, #records)
+      assert.equal("/search", cjson.decode(records[1]).path)
+    end)
+
+    it("skips the requests that are not sampled", function()
+      mock_request()
+      local original_random = math.random
+      math.random = function() return 0.5 end -- luacheck: ignore
+      package.loaded["traffic_capture"] = nil
+      traffic_capture = require("traffic_capture")
+      math.random = original_random -- luacheck: ignore
+
+      config.sample_rate = 0.1
+      traffic_capture.log(config)
+
+      assert.is_nil(traffic_capture.buffers()[config.sink])
+    end)
+
+    it("skips the requests above the rate limit", function()
+      mock_request()
+      config.max_rps = 1
+      traffic_capture.log(config)
+      traffic_capture.log(config)
+
+      assert.equal(1, #traffic_capture.buffers()[config.sink])
+    end)
+  end)
+end)
@@ -0,0 +1,185 @@
+_G._TEST = true
+
+local cjson = require("cjson.safe")
+local sink_util = require("util.sink")
+
+local usage
+local now = 1000
+local original_ngx = ngx
+
+local function reset_ngx()
+  _G.ngx = original_ngx
+end
+
+local function mock_ngx(mock)
+  local _ngx = mock
+  setmetatable(_ngx, { __index = ngx })
+  _G.ngx = _ngx
+end
+
+local function reset_usage(config)
+  package.loaded["usage"] = nil
+  usage = require("usage")
+
+  ngx.shared.usage:flush_all()
+  ngx.shared.usage_exported:flush_all()
+  usage.init_worker(config)
+end
+
+local function request(namespace, ingress, received, sent)
+  ngx.var = { namespace = namespace, ingress_name = ingress, request_length = received, bytes_sent = sent }
+  usage.log()
+end
+
+describe("usage", function()
+  local sent
+
+  before_each(function()
+    sent = {}
+    stub(sink_util, "send", function(_, records, content_type)
+      table.insert(sent, { records = records, content_type = content_type })
+      return true
+    end)
+
+    mock_ngx({
+      time = function() return now end,
+      timer = { every = function() return true end },
+      worker = { id = function() return 0 end },
+      header = {},
+      var = {},
+    })
+    reset_usage({ sink = "http://collector/usage", format = "json", interval = 60 })
+  end)
+
+  after_each(function()
+    reset_ngx()
+    sink_util.send:revert()
+  end)
+
+  it("counts the requests and bytes of each Ingress", function()
+    request("default", "web", "100", "1000")
+    request("default", "web", "50", "500")
+    request("other", "api", "10", "20")
+
+    local dict = ngx.shared.usage
+    assert.equal(2, dict:get("requests default web"))
+    assert.equal(150, dict:get("bytes_received default web"))
+    assert.equal(1500, dict:get("bytes_sent default web"))
+    assert.equal(1, dict:get("requests other api"))
+  end)
+
+  it("ignores the requests without Ingress", function()
+    request(nil, nil, "100", "1000")
+    request("", "", "100", "1000")
+
+    assert.same({}, ngx.shared.usage:get_keys(0))
+  end)
+
+  it("exports the usage since the last export", function()
+    request("default", "web", "100", "1000")
+    usage.export()
+
+    assert.equal(1, #sent)
+    local record = cjson.decode(sent[1].records[1])
+    assert.equal("default", record.namespace)
+    assert.equal("web", record.ingress)
+    assert.equal(1, record.requests)
+    assert.equal(100, record.bytes_received)
+    assert.equal(1000, record.bytes_sent)
+
+    request("default", "web", "50", "500")
+    now = now + 60
+    usage.export()
+
+    assert.equal(2, #sent)
+    record = cjson.decode(sent[2].records[1])
+    assert.equal(1, record.requests)
+    assert.equal(50, record.bytes_received)
+    assert.equal(500, record.bytes_sent)
+
+    -- nothing is sent without new requests
+    usage.export()
+    assert.equal(2, #sent)
+  end)
+
+  it("exports the usage again after a failure", function()
+    sink_util.send:revert()
+    stub(sink_util, "send", function() return nil, "connection refused" end)
+
+    request("default", "web", "100", "1000")
+    usage.export()
+    assert.equal(1, ngx.shared.usage_exported:get("failed_exports"))
+    assert.is_nil(ngx.shared.usage_exported:get("requests default web"))
+
+    sink_util.send:revert()
+    stub(sink_util, "send", function(_, records)
+      table.insert(sent, { records = records })
+      return true
+    end)
+
+    request("default", "web", "100", "1000")
+    usage.export()
+    assert.equal(2, cjson.decode(sent[1].records[1]).requests)
+  end)
+
+  it("exports the counters evicted from the dictionary", function()
+    request("default", "web", "100", "1000")
+    request("default", "web", "100", "1000")
+    usage.export()
+
+    ngx.shared.usage:delete("requests default web")
+    request("default", "web", "100", "1000")
+    now = now + 60
+    usage.export()
+
+    assert.equal(1, cjson.decode(sent[2].records[1]).requests)
+  end)
+
+  it("deletes the counters of the removed Ingresses once exported", function()
+    reset_usage({ sink = "http://collector/usage", format = "json", interval = 60, ingresses = { "default web" } })
+
+    request("default", "web", "100", "1000")
+    request("other", "api", "10", "20")
+    usage.export()
+
+    assert.equal(2, #sent[1].records)
+    assert.equal(1, ngx.shared.usage:get("requests default web"))
+    assert.is_nil(ngx.shared.usage:get("requests other api"))
+    assert.is_nil(ngx.shared.usage_exported:get("requests other api"))
+  end)
+
+  it("deletes the counters of the removed Ingresses without export", function()
+    reset_usage({ sink = "", format = "json", interval = 60, ingresses = { "default web" } })
+
+    request("default", "web", "100", "1000")
+    request("other", "api", "10", "20")
+    usage.prune()
+
+    assert.equal(1, ngx.shared.usage:get("requests default web"))
+    assert.is_nil(ngx.shared.usage:get("requests other api"))
+  end)
+
+  it("exports the usage as CSV", function()
+    reset_usage({ sink = "http://collector/usage", format = "csv", interval = 60 })
+
+    request("default", "web", "100", "1000")
+    usage.export()
+
+    assert.equal("text/csv", sent[1].content_type)
+    assert.equal("start,end,namespace,ingress,requests,bytes_received,bytes_sent", sent[1].records[1])
+    assert.matches(",default,web,1,100,1000$", sent[1].records[2])
+  end)
+
+  it("returns the counters of each Ingress", function()
+    request("default", "web", "100", "1000")
+
+    local output
+    ngx.say = function(content) output = content end
+    usage.status()
+
+    assert.same({
+      ingresses = { { namespace = "default", ingress = "web", requests = 1, bytesReceived = 100, bytesSent = 1000 } },
+      failedExports = 0,
+    }, cjson.decode(output))
+  end)
+end)
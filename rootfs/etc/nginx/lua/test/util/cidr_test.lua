@@ -0,0 +1,42 @@
+local cidr = require("util.cidr")
+
+describe("util.cidr", function()
+  describe("parse()", function()
+    it("parses the IPv4 and IPv6 networks", function()
+      assert.same({ bytes = "\10\0\0\0", prefix = 8 }, cidr.parse("10.0.0.0/8"))
+      assert.equal(16, #cidr.parse("2001:db8::/32").bytes)
+      assert.equal(16, #cidr.parse("::1/128").bytes)
+    end)
+
+    it("returns nil for invalid networks", function()
+      assert.is_nil(cidr.parse("10.0.0.0"))
+      assert.is_nil(cidr.parse("10.0.0.0/33"))
+      assert.is_nil(cidr.parse("10.0.0.256/32"))
+      assert.is_nil(cidr.parse("2001:db8::1::/64"))
+      assert.is_nil(cidr.parse("2001:db8:0:0:0:0:0:0:1/64"))
+      assert.is_nil(cidr.parse("example.com/24"))
+    end)
+  end)
+
+  describe("contains()", function()
+    it("matches the addresses of an IPv4 network", function()
+      local network = cidr.parse("192.168.16.0/20")
+      assert.is_true(cidr.contains(network, "192.168.16.1"))
+      assert.is_true(cidr.contains(network, "192.168.31.255"))
+      assert.is_false(cidr.contains(network, "192.168.32.1"))
+      assert.is_false(cidr.contains(network, "2001:db8::1"))
+    end)
+
+    it("matches the addresses of an IPv6 network", function()
+      local network = cidr.parse("2001:db8::/33")
+      assert.is_true(cidr.contains(network, "2001:db8::1"))
+      assert.is_true(cidr.contains(network, "2001:db8:7fff:ffff:ffff:ffff:ffff:ffff"))
+      assert.is_false(cidr.contains(network, "2001:db8:8000::"))
+      assert.is_false(cidr.contains(network, "10.0.0.1"))
+    end)
+
+    it("matches every address of a network without prefix", function()
+      assert.is_true(cidr.contains(cidr.parse("0.0.0.0/0"), "203.0.113.7"))
+    end)
+  end)
+end)
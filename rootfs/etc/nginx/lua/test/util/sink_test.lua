@@ -0,0 +1,40 @@
+_G._TEST = true
+
+local sink = require("util.sink")
+
+describe("util.sink", function()
+  describe("parse()", function()
+    it("uses the port of the sink or the default one", function()
+      local parsed = sink.parse("http://collector:8080/replay")
+      assert.equal("collector", parsed.host)
+      assert.equal(8080, parsed.port)
+      assert.equal("collector:8080", parsed.host_header)
+      assert.equal("/replay", parsed.path)
+
+      parsed = sink.parse("http://collector/")
+      assert.equal(80, parsed.port)
+    end)
+
+    it("produces the records to the topic through the Kafka REST proxy", function()
+      local parsed = sink.parse("kafka://kafka-rest/replay")
+      assert.equal(8082, parsed.port)
+      assert.equal("/topics/replay", parsed.path)
+    end)
+
+    it("only supports files when a directory is given", function()
+      assert.is_nil(sink.parse("file://events.log"))
+      assert.same({ scheme = "file", path = "/tmp/events.log" }, sink.parse("file://events.log", "/tmp/"))
+    end)
+
+    it("returns nil for unsupported sinks", function()
+      assert.is_nil(sink.parse("ftp://collector/replay"))
+      assert.is_nil(sink.parse("http://collector"))
+      assert.is_nil(sink.parse("collector"))
+    end)
+  end)
+
+  it("sends the records in a single Kafka REST request", function()
+    assert.equal('{"records":[{"value":{"a":1}},{"value":{"b":2}}]}',
+      sink.kafka_payload({ '{"a":1}', '{"b":2}' }))
+  end)
+end)
@@ -33,3 +33,27 @@ describe("lua_ngx_var", function()
     assert.equal(nil, util.lua_ngx_var("$foo_bar"))
   end)
 end)
+
+describe("get_nodes", function()
+  local util = require("util")
+
+  it("returns the same weight for the endpoints without weight", function()
+    local endpoints = {
+      { address = "10.184.7.40", port = "8080" },
+      { address = "10.184.97.100", port = "8080" },
+    }
+
+    assert.same({ ["10.184.7.40:8080"] = 1, ["10.184.97.100:8080"] = 1 }, util.get_nodes(endpoints))
+  end)
+
+  it("returns the weights of the endpoints divided by their greatest common divisor", function()
+    local endpoints = {
+      { address = "10.184.7.40", port = "8080" },
+      { address = "10.184.97.100", port = "8080", weight = 50 },
+      { address = "10.184.97.101", port = "8080", weight = 25 },
+    }
+
+    assert.same({ ["10.184.7.40:8080"] = 4, ["10.184.97.100:8080"] = 2, ["10.184.97.101:8080"] = 1 },
+      util.get_nodes(endpoints))
+  end)
+end)
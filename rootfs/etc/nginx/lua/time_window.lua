@@ -0,0 +1,61 @@
+local os_date = os.date
+local ipairs = ipairs
+
+local _M = {}
+
+-- returns the offset to UTC of the time zone of the windows at the time,
+-- the offsets are sorted by the time at which they end
+local function offset(config, now)
+  for _, o in ipairs(config.offsets) do
+    if now < o.ends then
+      return o.offset
+    end
+  end
+  return config.offset
+end
+
+local function matches_field(values, value)
+  -- a missing field matches any value
+  return values == nil or values[value] == true
+end
+
+-- like cron, the day matches either the day of month or the day of week
+-- when both are restricted
+local function matches(window, t)
+  if not matches_field(window.minutes, t.min) or
+      not matches_field(window.hours, t.hour) or
+      not matches_field(window.months, t.month) then
+    return false
+  end
+
+  -- wday starts with Sunday = 1
+  local weekday = t.wday - 1
+  if window.days and window.weekdays then
+    return window.days[t.day] == true or window.weekdays[weekday] == true
+  end
+
+  return matches_field(window.days, t.day) and matches_field(window.weekdays, weekday)
+end
+
+local function matches_any(windows, t)
+  for _, window in ipairs(windows) do
+    if matches(window, t) then
+      return true
+    end
+  end
+  return false
+end
+
+-- denies the requests during the denied windows of the location, and
+-- outside of its allowed windows when there are any
+function _M.rewrite(config)
+  local now = ngx.time()
+  local t = os_date("!*t", now + offset(config, now))
+
+  if matches_any(config.denied, t) or
+      (#config.allowed > 0 and not matches_any(config.allowed, t)) then
+    return ngx.exit(config.status)
+  end
+end
+
+return _M
@@ -0,0 +1,107 @@
+local string_format = string.format
+local string_match = string.match
+
+-- the fingerprint of a connection expires when it is not used by a request
+-- during this period, before the ephemeral port of the SSL passthrough proxy
+-- is used by another connection
+local FINGERPRINT_TTL = 300
+-- the fingerprint is sent by the SSL passthrough proxy while the Client Hello
+-- is forwarded, the first request of a connection waits for it when the
+-- location only allows some fingerprints
+local FINGERPRINT_WAIT_STEP = 0.01
+local FINGERPRINT_WAIT_STEPS = 10
+
+local _M = {}
+
+-- the fingerprints are sent by the SSL passthrough proxy for each connection,
+-- indexed by the local and remote addresses of the connection to NGINX. The
+-- oldest entries are evicted when the dictionary is full.
+local function fingerprints()
+  return ngx.shared.tls_fingerprints
+end
+
+-- sets the fingerprint of a connection, empty fingerprints remove the
+-- fingerprint of a previous connection using the same addresses
+function _M.set(connection, ja3, ja4)
+  local dict = fingerprints()
+  if not dict then
+    return nil, "the TLS fingerprints are disabled"
+  end
+
+  if ja3 == "" or ja4 == "" then
+    dict:delete(connection)
+    return true
+  end
+
+  local _, err = dict:set(connection, ja3 .. " " .. ja4, FINGERPRINT_TTL)
+  if err then
+    return nil, err
+  end
+
+  return true
+end
+
+-- returns the JA3 hash and the JA4 fingerprint of the connection of the request
+local function get(wait)
+  if ngx.var.https ~= "on" then
+    return nil
+  end
+
+  local dict = fingerprints()
+  if not dict then
+    return nil
+  end
+
+  -- the addresses of the connection to NGINX, before the PROXY protocol
+  local connection = string_format("%s:%s-%s:%s", ngx.var.realip_remote_addr, tostring(ngx.var.realip_remote_port),
+    ngx.var.server_addr, tostring(ngx.var.server_port))
+
+  local value = dict:get(connection)
+  local steps = 0
+  while not value and wait and steps < FINGERPRINT_WAIT_STEPS do
+    ngx.sleep(FINGERPRINT_WAIT_STEP)
+    steps = steps + 1
+    value = dict:get(connection)
+  end
+
+  if not value then
+    return nil
+  end
+
+  -- the fingerprint is kept while the connection is used
+  dict:set(connection, value, FINGERPRINT_TTL)
+
+  return string_match(value, "^(%S+) (%S+)$")
+end
+
+local function contains(list, ja3, ja4)
+  for _, fingerprint in ipairs(list) do
+    if fingerprint == ja3 or fingerprint == ja4 then
+      return true
+    end
+  end
+  return false
+end
+
+-- sets the variables $tls_ja3 and $tls_ja4 and denies the clients matching
+-- the fingerprints of the location
+function _M.rewrite(config)
+  local ja3, ja4 = get(#config.allowed > 0)
+  if ja3 then
+    ngx.var.tls_ja3 = ja3
+    ngx.var.tls_ja4 = ja4
+  end
+
+  -- the clients without fingerprint are not allowed
+  if #config.allowed > 0 and not contains(config.allowed, ja3, ja4) then
+    ngx.log(ngx.INFO, string_format("TLS fingerprint %s %s is not allowed", tostring(ja3), tostring(ja4)))
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+  end
+
+  if ja3 and contains(config.denied, ja3, ja4) then
+    ngx.log(ngx.INFO, string_format("TLS fingerprint %s %s is denied", ja3, ja4))
+    return ngx.exit(ngx.HTTP_FORBIDDEN)
+  end
+end
+
+return _M
@@ -0,0 +1,167 @@
+local cjson = require("cjson.safe")
+local util = require("util")
+local sink_util = require("util.sink")
+
+local math_floor = math.floor
+local math_random = math.random
+local string_format = string.format
+local table_insert = table.insert
+
+-- the files of the file:// sinks are written in this directory
+local CAPTURE_DIR = "/var/log/nginx/capture/"
+-- measured in seconds
+local FLUSH_INTERVAL = 1
+-- the records are dropped when a sink is slower than the traffic
+local MAX_BUFFERED_RECORDS = 1000
+-- larger bodies are not captured
+local MAX_BODY_SIZE = 8192
+local MAX_HEADERS = 100
+local MAX_ARGS = 100
+
+local _M = {}
+
+-- captured records waiting to be sent, by sink
+local buffers = {}
+-- number of records dropped since the last flush, by sink
+local dropped = {}
+-- number of records captured during the current second, by sink
+local rates = {}
+local sinks = {}
+
+local function parse_sink(sink)
+  if not sinks[sink] then
+    sinks[sink] = sink_util.parse(sink, CAPTURE_DIR)
+  end
+  return sinks[sink]
+end
+
+-- returns true while less than max_rps records were captured during the current second
+local function allow(sink, max_rps)
+  local now = math_floor(ngx.now())
+
+  local rate = rates[sink]
+  if not rate or rate.second ~= now then
+    rate = { second = now, count = 0 }
+    rates[sink] = rate
+  end
+
+  if rate.count >= max_rps then
+    return false
+  end
+
+  rate.count = rate.count + 1
+  return true
+end
+
+local function pick(values, names)
+  if #names == 0 then
+    return nil
+  end
+
+  local picked = {}
+  for _, name in ipairs(names) do
+    if values[name] ~= nil then
+      picked[name] = values[name]
+    end
+  end
+  return picked
+end
+
+local function build_record(config)
+  local record = {
+    time = ngx.req.start_time(),
+    method = ngx.req.get_method(),
+    host = ngx.var.host,
+    path = ngx.var.uri,
+    status = ngx.status,
+    -- only the headers and arguments of the allowlists are captured
+    headers = pick(ngx.req.get_headers(MAX_HEADERS), config.headers or {}),
+    args = pick(ngx.req.get_uri_args(MAX_ARGS), config.args or {}),
+  }
+
+  if config.body then
+    -- the body is only available when it was buffered in memory
+    local body = ngx.req.get_body_data()
+    if body and #body <= MAX_BODY_SIZE then
+      record.body = ngx.encode_base64(body)
+    end
+  end
+
+  return record
+end
+
+local function send(sink_name, records)
+  local sink = parse_sink(sink_name)
+  if not sink then
+    return nil, "invalid sink"
+  end
+
+  return sink_util.send(sink, records)
+end
+
+local function flush()
+  local pending, pending_dropped = buffers, dropped
+  buffers, dropped = {}, {}
+
+  for sink_name, records in pairs(pending) do
+    local ok, err = send(sink_name, records)
+    if not ok then
+      ngx.log(ngx.WARN, string_format("could not send %d captured requests to %s: %s",
+        #records, sink_name, tostring(err)))
+    end
+  end
+
+  for sink_name, count in pairs(pending_dropped) do
+    ngx.log(ngx.WARN, string_format("dropped %d captured requests for %s", count, sink_name))
+  end
+end
+
+function _M.init_worker()
+  local _, err = ngx.timer.every(FLUSH_INTERVAL, flush)
+  if err then
+    ngx.log(ngx.ERR, string_format("error when setting up timer.every for traffic capture: %s", tostring(err)))
+  end
+end
+
+function _M.log(config)
+  if util.is_blank(config.sink) then
+    return
+  end
+
+  if math_random() > config.sample_rate then
+    return
+  end
+
+  if not allow(config.sink, config.max_rps) then
+    return
+  end
+
+  local buffer = buffers[config.sink]
+  if not buffer then
+    buffer = {}
+    buffers[config.sink] = buffer
+  end
+
+  if #buffer >= MAX_BUFFERED_RECORDS then
+    dropped[config.sink] = (dropped[config.sink] or 0) + 1
+    return
+  end
+
+  local encoded, err = cjson.encode(build_record(config))
+  if not encoded then
+    ngx.log(ngx.ERR, "could not encode the captured request: ", tostring(err))
+    return
+  end
+
+  table_insert(buffer, encoded)
+end
+
+if _TEST then
+  _M.parse_sink = parse_sink
+  _M.allow = allow
+  _M.build_record = build_record
+  _M.flush = flush
+  _M.buffers = function() return buffers end
+end
+
+return _M
@@ -0,0 +1,352 @@
+local cjson = require("cjson.safe")
+local sink_util = require("util.sink")
+
+local io_open = io.open
+local os_date = os.date
+local string_format = string.format
+local table_concat = table.concat
+local table_insert = table.insert
+
+-- the files of the file:// sinks are written in this directory
+local EXPORT_DIR = "/var/log/nginx/usage/"
+-- maximum number of records sent in one request to the sink
+local BATCH_SIZE = 500
+-- maximum number of Ingresses accounted, bounding the keys read from the dictionary
+local MAX_INGRESSES = 10000
+
+local COUNTERS = { "requests", "bytes_received", "bytes_sent" }
+local CSV_HEADER = "start,end,namespace,ingress,requests,bytes_received,bytes_sent"
+
+local _M = {}
+
+local config
+local sink
+
+-- the counters are kept in a shared dictionary, indexed by the name of the
+-- counter, the namespace and the Ingress separated by spaces, so the
+-- status endpoint and the export return the values of all the workers
+local function usage_dict()
+  return ngx.shared.usage
+end
+
+-- the values of the counters already exported, and the state of the export,
+-- are kept in another dictionary only written with safe_set so they are never
+-- evicted by the counters
+local function exported_dict()
+  return ngx.shared.usage_exported
+end
+
+local function safe_set(dict, key, value)
+  local ok, err = dict:safe_set(key, value)
+  if not ok then
+    ngx.log(ngx.WARN, string_format("could not set %s: %s", key, tostring(err)))
+  end
+end
+
+local function incr(dict, key, value)
+  local _, err, forcible = dict:incr(key, value, 0)
+  if err then
+    ngx.log(ngx.WARN, string_format("could not increment %s: %s", key, tostring(err)))
+  elseif forcible then
+    ngx.log(ngx.WARN, "the usage dictionary is full, the oldest counters were removed")
+  end
+end
+
+-- returns the counters of each Ingress, and the counters already exported
+local function collect(dict, exported)
+  local usages = {}
+  local list = {}
+
+  local max_keys = #COUNTERS * MAX_INGRESSES
+  local keys = dict:get_keys(max_keys)
+  if #keys >= max_keys then
+    ngx.log(ngx.WARN, string_format("the usage of more than %d Ingresses is not reported", MAX_INGRESSES))
+  end
+
+  for _, key in ipairs(keys) do
+    local counter, namespace, ingress = key:match("^(%S+) (%S+) (%S+)$")
+    if counter then
+      local id = namespace .. " " .. ingress
+      local usage = usages[id]
+      if not usage then
+        usage = { id = id, namespace = namespace, ingress = ingress, current = {}, exported = {} }
+        usages[id] = usage
+        table_insert(list, usage)
+      end
+
+      usage.current[counter] = dict:get(key) or 0
+      if exported then
+        usage.exported[counter] = exported:get(key) or 0
+      end
+    end
+  end
+
+  table.sort(list, function(a, b)
+    if a.namespace ~= b.namespace then
+      return a.namespace < b.namespace
+    end
+    return a.ingress < b.ingress
+  end)
+
+  return list
+end
+
+local function csv_record(start, finish, usage, delta)
+  return table_concat({ start, finish, usage.namespace, usage.ingress,
+    delta.requests, delta.bytes_received, delta.bytes_sent }, ",")
+end
+
+local function json_record(start, finish, usage, delta)
+  return cjson.encode({
+    start = start,
+    ["end"] = finish,
+    namespace = usage.namespace,
+    ingress = usage.ingress,
+    requests = delta.requests,
+    bytes_received = delta.bytes_received,
+    bytes_sent = delta.bytes_sent,
+  })
+end
+
+local function send(records, csv)
+  if not csv then
+    return sink_util.send(sink, records)
+  end
+
+  if sink.scheme == "file" then
+    -- the header is only written at the beginning of the file
+    local f = io_open(sink.path, "r")
+    if f then
+      f:close()
+      return sink_util.send(sink, records)
+    end
+  end
+
+  local with_header = { CSV_HEADER }
+  for _, record in ipairs(records) do
+    table_insert(with_header, record)
+  end
+
+  return sink_util.send(sink, with_header, "text/csv")
+end
+
+-- returns true when the Ingress of the counters is not in the configuration.
+-- Without the list of the Ingresses nothing is removed.
+local function is_removed(usage)
+  return config.ingresses ~= nil and not config.ingresses[usage.id]
+end
+
+-- deletes the counters of an Ingress removed from the configuration
+local function remove(dict, exported, usage)
+  for _, counter in ipairs(COUNTERS) do
+    local key = counter .. " " .. usage.id
+    dict:delete(key)
+    exported:delete(key)
+  end
+end
+
+-- deletes the counters of the Ingresses removed from the configuration,
+-- used when the usage is not exported
+local function prune(premature)
+  if premature then
+    return
+  end
+
+  local dict, exported = usage_dict(), exported_dict()
+  if not dict or not exported then
+    return
+  end
+
+  for _, usage in ipairs(collect(dict)) do
+    if is_removed(usage) then
+      remove(dict, exported, usage)
+    end
+  end
+end
+
+-- sends the usage of each Ingress since the last export to the sink
+local function export(premature)
+  if premature then
+    return
+  end
+
+  local dict, exported_values = usage_dict(), exported_dict()
+  if not dict or not exported_values then
+    return
+  end
+
+  local now = ngx.time()
+  local start = os_date("!%Y-%m-%dT%H:%M:%SZ",
+    exported_values:get("exported_at") or exported_values:get("started_at") or now)
+  local finish = os_date("!%Y-%m-%dT%H:%M:%SZ", now)
+
+  -- the Kafka records must be JSON objects
+  local csv = config.format == "csv" and sink.scheme ~= "kafka"
+  local record = csv and csv_record or json_record
+
+  local records, exported, removed = {}, {}, {}
+  local failed = false
+
+  local function flush()
+    if #records == 0 then
+      return
+    end
+
+    local ok, err = send(records, csv)
+    if not ok then
+      failed = true
+      exported_values:incr("failed_exports", 1)
+      ngx.log(ngx.WARN, string_format("could not export the usage of %d Ingresses to %s: %s",
+        #records, config.sink, tostring(err)))
+    else
+      -- the counters incremented during the export are sent the next time
+      for key, value in pairs(exported) do
+        safe_set(exported_values, key, value)
+      end
+      for _, usage in ipairs(removed) do
+        remove(dict, exported_values, usage)
+      end
+    end
+
+    records, exported, removed = {}, {}, {}
+  end
+
+  for _, usage in ipairs(collect(dict, exported_values)) do
+    local delta, changed = {}, false
+    for _, counter in ipairs(COUNTERS) do
+      local current = usage.current[counter] or 0
+      local previous = usage.exported[counter] or 0
+      -- the counter was evicted from the dictionary and started again
+      if current < previous then
+        previous = 0
+      end
+
+      delta[counter] = current - previous
+      if delta[counter] ~= 0 then
+        changed = true
+        exported[counter .. " " .. usage.id] = current
+      end
+    end
+
+    if not changed then
+      if is_removed(usage) then
+        remove(dict, exported_values, usage)
+      end
+    else
+      table_insert(records, record(start, finish, usage, delta))
+      -- the counters of the removed Ingresses are deleted once exported
+      if is_removed(usage) then
+        table_insert(removed, usage)
+      end
+      if #records >= BATCH_SIZE then
+        flush()
+      end
+    end
+  end
+
+  flush()
+
+  -- the next export starts when the last successful one ended
+  if not failed then
+    safe_set(exported_values, "exported_at", now)
+  end
+end
+
+function _M.init_worker(usage_config)
+  config = usage_config
+
+  -- the Ingresses of the configuration, in the form of the keys of the counters
+  if config.ingresses then
+    local ingresses = {}
+    for _, id in ipairs(config.ingresses) do
+      ingresses[id] = true
+    end
+    config.ingresses = ingresses
+  end
+
+  local dict, exported = usage_dict(), exported_dict()
+  if not dict or not exported then
+    return
+  end
+
+  -- measures the first period exported, kept across the reloads
+  exported:safe_add("started_at", ngx.time())
+  exported:safe_add("failed_exports", 0)
+
+  if ngx.worker.id() ~= 0 then
+    return
+  end
+
+  local handler = prune
+  if config.sink ~= "" then
+    sink = sink_util.parse(config.sink, EXPORT_DIR)
+    if not sink then
+      ngx.log(ngx.ERR, "invalid usage export sink: " .. tostring(config.sink))
+      return
+    end
+
+    handler = export
+  end
+
+  local _, err = ngx.timer.every(config.interval, handler)
+  if err then
+    ngx.log(ngx.ERR, string_format("error when setting up timer.every for the usage export: %s", tostring(err)))
+  end
+end
+
+-- counts the request and its bytes for the Ingress of the location
+function _M.log()
+  local dict = usage_dict()
+  if not dict then
+    return
+  end
+
+  local namespace = ngx.var.namespace
+  local ingress = ngx.var.ingress_name
+  if not namespace or namespace == "" or not ingress or ingress == "" then
+    return
+  end
+
+  local id = " " .. namespace .. " " .. ingress
+  incr(dict, "requests" .. id, 1)
+  incr(dict, "bytes_received" .. id, tonumber(ngx.var.request_length) or 0)
+  incr(dict, "bytes_sent" .. id, tonumber(ngx.var.bytes_sent) or 0)
+end
+
+-- returns the counters of each Ingress, read by the metrics collector
+function _M.status()
+  local dict = usage_dict()
+  if not dict then
+    return ngx.exit(ngx.HTTP_NOT_FOUND)
+  end
+
+  local exported = exported_dict()
+
+  local ingresses = {}
+  for _, usage in ipairs(collect(dict)) do
+    table_insert(ingresses, {
+      namespace = usage.namespace,
+      ingress = usage.ingress,
+      requests = usage.current.requests or 0,
+      bytesReceived = usage.current.bytes_received or 0,
+      bytesSent = usage.current.bytes_sent or 0,
+    })
+  end
+
+  if #ingresses == 0 then
+    ingresses = cjson.empty_array
+  end
+
+  ngx.header.content_type = "application/json"
+  ngx.say(cjson.encode({
+    ingresses = ingresses,
+    failedExports = exported and exported:get("failed_exports") or 0,
+  }))
+end
+
+if _TEST then
+  _M.export = export
+  _M.prune = prune
+end
+
+return _M
@@ -1,15 +1,44 @@
 local string_len = string.len
 local string_sub = string.sub
 
+-- weight of the endpoints of the pods without the endpoint-weight annotation
+local DEFAULT_ENDPOINT_WEIGHT = 100
+
 local _M = {}
 
+local function gcd(a, b)
+  while b ~= 0 do
+    a, b = b, a % b
+  end
+  return a
+end
+
+-- returns the weight of an endpoint, between 1 and 100
+function _M.endpoint_weight(endpoint)
+  return endpoint.weight or DEFAULT_ENDPOINT_WEIGHT
+end
+
+-- returns the weight of an endpoint relative to the default weight, the
+-- scores of the latency based balancers are divided by this value
+function _M.relative_endpoint_weight(endpoint)
+  return _M.endpoint_weight(endpoint) / DEFAULT_ENDPOINT_WEIGHT
+end
+
+-- returns the weight of each endpoint, divided by the greatest common divisor
+-- of the weights to keep the number of points of the consistent hashing low
 function _M.get_nodes(endpoints)
   local nodes = {}
-  local weight = 1
+  local divisor = 0
 
   for _, endpoint in pairs(endpoints) do
     local endpoint_string = endpoint.address .. ":" .. endpoint.port
+    local weight = _M.endpoint_weight(endpoint)
     nodes[endpoint_string] = weight
+    divisor = gcd(weight, divisor)
+  end
+
+  for endpoint_string, weight in pairs(nodes) do
+    nodes[endpoint_string] = weight / divisor
   end
 
   return nodes
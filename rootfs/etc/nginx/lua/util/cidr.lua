@@ -0,0 +1,105 @@
+local bit = require("bit")
+
+local string_byte = string.byte
+local string_char = string.char
+local string_sub = string.sub
+local table_concat = table.concat
+local table_insert = table.insert
+local math_floor = math.floor
+
+local _M = {}
+
+-- appends the 16 bits groups of a part of an IPv6 address
+local function append_groups(groups, part)
+  if part == "" then
+    return true
+  end
+
+  for group in (part .. ":"):gmatch("([^:]*):") do
+    if not group:match("^%x%x?%x?%x?$") then
+      return false
+    end
+    table_insert(groups, tonumber(group, 16))
+  end
+
+  return true
+end
+
+-- returns the 4 or 16 bytes of an IPv4 or IPv6 address
+local function to_bytes(address)
+  local a, b, c, d = address:match("^(%d+)%.(%d+)%.(%d+)%.(%d+)$")
+  if a then
+    local octets = { tonumber(a), tonumber(b), tonumber(c), tonumber(d) }
+    for _, octet in ipairs(octets) do
+      if octet > 255 then
+        return nil
+      end
+    end
+    return string_char(unpack(octets))
+  end
+
+  local groups = {}
+  local head, tail = address:match("^(.-)::(.*)$")
+  if head then
+    local tail_groups = {}
+    if not append_groups(groups, head) or not append_groups(tail_groups, tail) then
+      return nil
+    end
+    if #groups + #tail_groups > 7 then
+      return nil
+    end
+    for _ = 1, 8 - #groups - #tail_groups do
+      table_insert(groups, 0)
+    end
+    for _, group in ipairs(tail_groups) do
+      table_insert(groups, group)
+    end
+  elseif not append_groups(groups, address) or #groups ~= 8 then
+    return nil
+  end
+
+  local bytes = {}
+  for _, group in ipairs(groups) do
+    table_insert(bytes, string_char(bit.rshift(group, 8), bit.band(group, 0xff)))
+  end
+  return table_concat(bytes)
+end
+
+-- parses a network with the format <address>/<prefix length>
+function _M.parse(cidr)
+  local address, prefix = cidr:match("^([^/]+)/(%d+)$")
+  if not address then
+    return nil
+  end
+
+  local bytes = to_bytes(address)
+  prefix = tonumber(prefix)
+  if not bytes or prefix > #bytes * 8 then
+    return nil
+  end
+
+  return { bytes = bytes, prefix = prefix }
+end
+
+-- returns true when the address belongs to the network returned by parse()
+function _M.contains(network, address)
+  local bytes = to_bytes(address)
+  if not bytes or #bytes ~= #network.bytes then
+    return false
+  end
+
+  local full = math_floor(network.prefix / 8)
+  if string_sub(bytes, 1, full) ~= string_sub(network.bytes, 1, full) then
+    return false
+  end
+
+  local rest = network.prefix % 8
+  if rest == 0 then
+    return true
+  end
+
+  local mask = bit.band(bit.lshift(0xff, 8 - rest), 0xff)
+  return bit.band(string_byte(bytes, full + 1), mask) == bit.band(string_byte(network.bytes, full + 1), mask)
+end
+
+return _M
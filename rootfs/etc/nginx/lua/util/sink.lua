@@ -0,0 +1,115 @@
+local io_open = io.open
+local table_concat = table.concat
+
+local SOCKET_TIMEOUT = 2000
+
+local DEFAULT_PORTS = {
+  http = 80,
+  kafka = 8082,
+}
+
+local _M = {}
+
+-- parses a sink, file://<name> is only supported when file_dir is set and
+-- kafka://<host>/<topic> produces the records through the Kafka REST proxy
+function _M.parse(sink, file_dir)
+  local scheme, rest = sink:match("^(%a+)://(.+)$")
+  if not scheme then
+    return nil
+  end
+
+  if scheme == "file" then
+    if not file_dir then
+      return nil
+    end
+    return { scheme = scheme, path = file_dir .. rest }
+  end
+
+  local host_port, path = rest:match("^([^/]+)(/.*)$")
+  if not host_port or not DEFAULT_PORTS[scheme] then
+    return nil
+  end
+
+  local host, port = host_port:match("^(.+):(%d+)$")
+  if not host then
+    host, port = host_port, DEFAULT_PORTS[scheme]
+  end
+
+  local parsed = { scheme = scheme, host = host, port = tonumber(port), host_header = host_port, path = path }
+  if scheme == "kafka" then
+    parsed.path = "/topics" .. path
+  end
+
+  return parsed
+end
+
+local function write_file(sink, records)
+  local f, err = io_open(sink.path, "a")
+  if not f then
+    return nil, err
+  end
+
+  f:write(table_concat(records, "\n"), "\n")
+  f:close()
+  return true
+end
+
+local function post(sink, content_type, body)
+  local sock = ngx.socket.tcp()
+  sock:settimeout(SOCKET_TIMEOUT)
+
+  local ok, err = sock:connect(sink.host, sink.port)
+  if not ok then
+    return nil, err
+  end
+
+  local _
+  _, err = sock:send({
+    "POST ", sink.path, " HTTP/1.1\r\n",
+    "Host: ", sink.host_header, "\r\n",
+    "Content-Type: ", content_type, "\r\n",
+    "Content-Length: ", #body, "\r\n",
+    "Connection: close\r\n\r\n",
+    body,
+  })
+  if err then
+    sock:close()
+    return nil, err
+  end
+
+  local line
+  line, err = sock:receive("*l")
+  sock:close()
+  if not line then
+    return nil, err
+  end
+
+  local status = tonumber(line:match("^HTTP/%d%.%d (%d+)"))
+  if not status or status >= 300 then
+    return nil, "unexpected response: " .. line
+  end
+
+  return true
+end
+
+local function kafka_payload(records)
+  return '{"records":[{"value":' .. table_concat(records, '},{"value":') .. '}]}'
+end
+
+-- sends records, a list of JSON encoded objects by default, to a parsed
+-- sink. content_type is the type of the lines posted to HTTP sinks.
+function _M.send(sink, records, content_type)
+  if sink.scheme == "file" then
+    return write_file(sink, records)
+  elseif sink.scheme == "kafka" then
+    return post(sink, "application/vnd.kafka.json.v2+json", kafka_payload(records))
+  end
+
+  return post(sink, content_type or "application/x-ndjson", table_concat(records, "\n") .. "\n")
+end
+
+if _TEST then
+  _M.kafka_payload = kafka_payload
+end
+
+return _M
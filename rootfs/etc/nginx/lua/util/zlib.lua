@@ -0,0 +1,139 @@
+local ffi = require("ffi")
+
+local ffi_new = ffi.new
+local ffi_sizeof = ffi.sizeof
+local ffi_string = ffi.string
+local table_concat = table.concat
+
+local _M = {}
+
+-- returned by gunzip when the decompressed data is larger than the limit
+_M.ERR_TOO_LARGE = "decompressed data too large"
+
+local CHUNK_SIZE = 16384
+
+local Z_OK = 0
+local Z_STREAM_END = 1
+local Z_FINISH = 4
+local Z_NO_FLUSH = 0
+local Z_DEFLATED = 8
+local Z_DEFAULT_COMPRESSION = -1
+local Z_DEFAULT_STRATEGY = 0
+local MEM_LEVEL = 8
+-- 15 bits for the window plus 16 to use the gzip wrapper instead of zlib
+local GZIP_WINDOW_BITS = 31
+
+ffi.cdef[[
+typedef struct {
+  const unsigned char *next_in;
+  unsigned int avail_in;
+  unsigned long total_in;
+  unsigned char *next_out;
+  unsigned int avail_out;
+  unsigned long total_out;
+  const char *msg;
+  void *state;
+  void *zalloc;
+  void *zfree;
+  void *opaque;
+  int data_type;
+  unsigned long adler;
+  unsigned long reserved;
+} ingress_z_stream;
+
+const char *zlibVersion(void);
+int deflateInit2_(ingress_z_stream *strm, int level, int method, int windowBits,
+                  int memLevel, int strategy, const char *version, int stream_size);
+int deflate(ingress_z_stream *strm, int flush);
+int deflateEnd(ingress_z_stream *strm);
+int inflateInit2_(ingress_z_stream *strm, int windowBits, const char *version, int stream_size);
+int inflate(ingress_z_stream *strm, int flush);
+int inflateEnd(ingress_z_stream *strm);
+]]
+
+-- only the runtime library, without the libz.so symlink, is installed in the image
+local zlib
+do
+  local ok, lib = pcall(ffi.load, "z")
+  if not ok then
+    lib = ffi.load("libz.so.1")
+  end
+  zlib = lib
+end
+
+local function new_stream(data)
+  local stream = ffi_new("ingress_z_stream")
+  stream.next_in = data
+  stream.avail_in = #data
+  return stream
+end
+
+-- compresses the data using the gzip format
+function _M.gzip(data)
+  local stream = new_stream(data)
+  local ret = zlib.deflateInit2_(stream, Z_DEFAULT_COMPRESSION, Z_DEFLATED, GZIP_WINDOW_BITS,
+                                 MEM_LEVEL, Z_DEFAULT_STRATEGY, zlib.zlibVersion(), ffi_sizeof(stream))
+  if ret ~= Z_OK then
+    return nil, "deflateInit2 failed with code " .. ret
+  end
+
+  local out = ffi_new("unsigned char[?]", CHUNK_SIZE)
+  local chunks = {}
+
+  repeat
+    stream.next_out = out
+    stream.avail_out = CHUNK_SIZE
+
+    ret = zlib.deflate(stream, Z_FINISH)
+    if ret ~= Z_OK and ret ~= Z_STREAM_END then
+      zlib.deflateEnd(stream)
+      return nil, "deflate failed with code " .. ret
+    end
+
+    chunks[#chunks + 1] = ffi_string(out, CHUNK_SIZE - stream.avail_out)
+  until ret == Z_STREAM_END
+
+  zlib.deflateEnd(stream)
+
+  return table_concat(chunks)
+end
+
+-- decompresses gzip data, failing as soon as the result is larger than max_size
+function _M.gunzip(data, max_size)
+  local stream = new_stream(data)
+  local ret = zlib.inflateInit2_(stream, GZIP_WINDOW_BITS, zlib.zlibVersion(), ffi_sizeof(stream))
+  if ret ~= Z_OK then
+    return nil, "inflateInit2 failed with code " .. ret
+  end
+
+  local out = ffi_new("unsigned char[?]", CHUNK_SIZE)
+  local chunks = {}
+  local size = 0
+
+  repeat
+    stream.next_out = out
+    stream.avail_out = CHUNK_SIZE
+
+    -- a truncated input returns Z_BUF_ERROR once no progress is possible
+    ret = zlib.inflate(stream, Z_NO_FLUSH)
+    if ret ~= Z_OK and ret ~= Z_STREAM_END then
+      zlib.inflateEnd(stream)
+      return nil, "invalid gzip data"
+    end
+
+    local n = CHUNK_SIZE - stream.avail_out
+    size = size + n
+    if size > max_size then
+      zlib.inflateEnd(stream)
+      return nil, _M.ERR_TOO_LARGE
+    end
+
+    chunks[#chunks + 1] = ffi_string(out, n)
+  until ret == Z_STREAM_END
+
+  zlib.inflateEnd(stream)
+
+  return table_concat(chunks)
+end
+
+return _M